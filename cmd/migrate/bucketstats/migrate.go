@@ -0,0 +1,52 @@
+package bucketstats
+
+import (
+	"context"
+	"fmt"
+
+	parsecmdtypes "github.com/forbole/juno/v4/cmd/parse/types"
+	"github.com/forbole/juno/v4/database"
+	"github.com/forbole/juno/v4/log"
+	nodebuilder "github.com/forbole/juno/v4/node/builder"
+	"github.com/forbole/juno/v4/types/config"
+)
+
+// RunMigration recalculates the stats of every bucket of the configured chain, or of the chain of the node when none
+// is configured, out of its objects, so that the buckets stored before the stats were tracked report them.
+// The parser must be stopped while it runs.
+func RunMigration(parseConfig *parsecmdtypes.Config) error {
+	err := parsecmdtypes.UpdatedGlobalCfg(parseConfig)
+	if err != nil {
+		return err
+	}
+	cfg := config.Cfg
+
+	encodingConfig := parseConfig.GetEncodingConfigBuilder()()
+	chainID := cfg.Chain.ChainID
+	if chainID == "" {
+		cp, err := nodebuilder.BuildNode(cfg.Node, &encodingConfig)
+		if err != nil {
+			return fmt.Errorf("failed to start client: %s", err)
+		}
+		chainID, err = cp.ChainID()
+		if err != nil {
+			return fmt.Errorf("failed to get the chain id from the node: %s", err)
+		}
+	}
+
+	databaseCtx := database.NewContext(cfg.Database, &encodingConfig)
+	databaseCtx.ChainID = chainID
+	db, err := parseConfig.GetDBBuilder()(databaseCtx)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	log.Infow("backfilling bucket stats...", "chain_id", chainID)
+	count, err := db.BackfillBucketStats(context.Background())
+	if err != nil {
+		return fmt.Errorf("error while backfilling bucket stats: %s", err)
+	}
+	log.Infow("bucket stats backfilled", "buckets", count)
+	return nil
+}
@@ -8,6 +8,9 @@ import (
 
 	"github.com/spf13/cobra"
 
+	"github.com/forbole/juno/v4/cmd/migrate/bucketstats"
+	"github.com/forbole/juno/v4/cmd/migrate/chainid"
+	"github.com/forbole/juno/v4/cmd/migrate/datastat"
 	v4 "github.com/forbole/juno/v4/cmd/migrate/v4"
 )
 
@@ -15,7 +18,10 @@ type Migrator func(parseCfg *parsecmdtypes.Config) error
 
 var (
 	migrations = map[string]Migrator{
-		"v4": v4.RunMigration,
+		"v4":           v4.RunMigration,
+		"chain-id":     chainid.RunMigration,
+		"bucket-stats": bucketstats.RunMigration,
+		"data-stat":    datastat.RunMigration,
 	}
 )
 
@@ -34,6 +40,15 @@ func NewMigrateCmd(appName string, parseConfig *parsecmdtypes.Config) *cobra.Com
 		Short: "Perform the migrations from the current version to the specified one",
 		Long: `Migrates all the necessary things (config file, database, etc) from the current version to the new one.
 Note that migrations must be performed in order: to migrate from vX to vX+2 you need to do vX -> vX+1 and then vX+1 -> vX+2. 
+
+The chain-id migration assigns the rows stored before each of them recorded its chain id to the configured chain,
+or to the one of the node, so that other chains can be indexed into the same database. Stop the parser before running it.
+
+The bucket-stats migration counts the objects and the stored size of the buckets stored before they were tracked,
+which would report none otherwise. Stop the parser before running it.
+
+The data-stat migration turns the counts of the object statistics, which used to be stored as strings, into numbers.
+Stop the parser before running it.
 `,
 		Example: fmt.Sprintf("%s migrate v3", appName),
 		Args:    cobra.RangeArgs(0, 1),
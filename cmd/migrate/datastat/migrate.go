@@ -0,0 +1,38 @@
+package datastat
+
+import (
+	"context"
+	"fmt"
+
+	"gorm.io/gorm/schema"
+
+	parsecmdtypes "github.com/forbole/juno/v4/cmd/parse/types"
+	"github.com/forbole/juno/v4/database"
+	"github.com/forbole/juno/v4/log"
+	"github.com/forbole/juno/v4/models"
+	"github.com/forbole/juno/v4/types/config"
+)
+
+// RunMigration migrates the counts of the object statistics, which used to be stored as strings, to their numeric
+// type. The parser must be stopped while it runs.
+func RunMigration(parseConfig *parsecmdtypes.Config) error {
+	err := parsecmdtypes.UpdatedGlobalCfg(parseConfig)
+	if err != nil {
+		return err
+	}
+	cfg := config.Cfg
+
+	encodingConfig := parseConfig.GetEncodingConfigBuilder()()
+	db, err := parseConfig.GetDBBuilder()(database.NewContext(cfg.Database, &encodingConfig))
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	log.Infow("migrating the object statistics...")
+	if err := db.AutoMigrate(context.Background(), []schema.Tabler{&models.DataStat{}}); err != nil {
+		return fmt.Errorf("error while migrating the object statistics: %s", err)
+	}
+	log.Infow("object statistics migrated")
+	return nil
+}
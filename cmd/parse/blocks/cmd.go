@@ -16,6 +16,7 @@ func NewBlocksCmd(parseConfig *parsecmdtypes.Config) *cobra.Command {
 	cmd.AddCommand(
 		newAllCmd(parseConfig),
 		newMissingCmd(parseConfig),
+		newReparseCmd(parseConfig),
 	)
 
 	return cmd
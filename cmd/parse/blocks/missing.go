@@ -38,11 +38,22 @@ func newMissingCmd(parseConfig *parsecmdtypes.Config) *cobra.Command {
 				return fmt.Errorf("error while getting db last block height: %s", err)
 			}
 
-			for _, k := range parseCtx.Database.GetMissingHeights(ctx, startHeight, dbLastHeight) {
-				err = worker.Process(k)
+			for fromHeight := startHeight; fromHeight <= dbLastHeight; {
+				missingHeights, err := parseCtx.Database.GetMissingHeights(ctx, fromHeight, dbLastHeight)
 				if err != nil {
-					return fmt.Errorf("error while re-fetching block %d: %s", k, err)
+					return fmt.Errorf("error while getting missing heights: %s", err)
 				}
+				if len(missingHeights) == 0 {
+					break
+				}
+
+				for _, k := range missingHeights {
+					err = worker.Process(k)
+					if err != nil {
+						return fmt.Errorf("error while re-fetching block %d: %s", k, err)
+					}
+				}
+				fromHeight = missingHeights[len(missingHeights)-1] + 1
 			}
 
 			return nil
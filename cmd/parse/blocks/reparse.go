@@ -0,0 +1,83 @@
+package blocks
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	parsecmdtypes "github.com/forbole/juno/v4/cmd/parse/types"
+	"github.com/forbole/juno/v4/parser"
+	"github.com/forbole/juno/v4/types/config"
+)
+
+const (
+	flagModules          = "modules"
+	flagAdvanceEpoch     = "advance-epoch"
+	flagProgressInterval = "progress-interval"
+	flagReplay           = "replay"
+)
+
+// newReparseCmd returns a Cobra command that allows to run the modules again over a range of heights
+func newReparseCmd(parseConfig *parsecmdtypes.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "reparse",
+		Short: "Run the modules handlers again over the blocks ranged from the given start height to the given end height",
+		Long: fmt.Sprintf(`Refetch the blocks in the range given by the %s and %s flags and call the block, tx, message and event
+handlers of the modules on them again, overwriting what they stored the first time. The blocks and transactions
+themselves are left as they are. Only the modules listed by the %s flag are run, or all of them if it is not set.
+The epoch is not updated, unless the %s flag is set. Interrupting the command stops it once the block being
+reparsed is done.
+
+With the %s flag set, the blocks are rebuilt out of the stored blocks, transactions and block results instead of
+being queried from the node. Only the blocks parsed with store_block_results enabled can be replayed, the command
+failing at the first block whose results were not stored. The validators are not stored either, so the handlers
+needing them fail.
+`, flagStart, flagEnd, flagModules, flagAdvanceEpoch, flagReplay),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			parseCtx, err := parsecmdtypes.GetParserContext(config.Cfg, parseConfig)
+			if err != nil {
+				return err
+			}
+
+			// Get the flag values
+			start, _ := cmd.Flags().GetUint64(flagStart)
+			end, _ := cmd.Flags().GetUint64(flagEnd)
+			moduleNames, _ := cmd.Flags().GetStringSlice(flagModules)
+			advanceEpoch, _ := cmd.Flags().GetBool(flagAdvanceEpoch)
+			progressInterval, _ := cmd.Flags().GetUint64(flagProgressInterval)
+			replay, _ := cmd.Flags().GetBool(flagReplay)
+
+			workerCtx := parser.NewContext(parseCtx.EncodingConfig, parseCtx.Node, parseCtx.Database, parseCtx.Modules, nil)
+			reparser, err := parser.NewReparser(workerCtx, parser.ReparseConfig{
+				StartHeight:      start,
+				EndHeight:        end,
+				Modules:          moduleNames,
+				AdvanceEpoch:     advanceEpoch,
+				ProgressInterval: progressInterval,
+				Replay:           replay,
+			})
+			if err != nil {
+				return err
+			}
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			return reparser.Run(ctx)
+		},
+	}
+
+	cmd.Flags().Uint64(flagStart, 0, "Height from which to start reparsing blocks")
+	cmd.Flags().Uint64(flagEnd, 0, "Height at which to finish reparsing blocks, included")
+	cmd.Flags().StringSlice(flagModules, nil, "Names of the modules to run, all the configured ones when empty")
+	cmd.Flags().Bool(flagAdvanceEpoch, false, "Whether or not to move the epoch to the end height once done, when it is behind it (default false)")
+	cmd.Flags().Uint64(flagProgressInterval, parser.DefaultReparseProgressInterval, "Number of blocks after which the progress is logged")
+	cmd.Flags().Bool(flagReplay, false, "Whether or not to rebuild the blocks out of the database instead of querying the node (default false)")
+	_ = cmd.MarkFlagRequired(flagStart)
+	_ = cmd.MarkFlagRequired(flagEnd)
+
+	return cmd
+}
@@ -18,6 +18,8 @@ func NewParseCmd(parseCfg *parsecmdtypes.Config) *cobra.Command {
 		PersistentPreRunE: runPersistentPreRuns(parsecmdtypes.ReadConfigPreRunE(parseCfg)),
 	}
 
+	parsecmdtypes.AddModulesFlags(cmd)
+
 	cmd.AddCommand(
 		parseblocks.NewBlocksCmd(parseCfg),
 		parsegenesis.NewGenesisCmd(parseCfg),
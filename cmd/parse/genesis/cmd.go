@@ -7,6 +7,7 @@ import (
 
 	"github.com/forbole/juno/v4/modules"
 	nodeconfig "github.com/forbole/juno/v4/node/config"
+	"github.com/forbole/juno/v4/types/config"
 	"github.com/forbole/juno/v4/types/utils"
 )
 
@@ -30,6 +31,9 @@ file itself and not the on-chain data.`,
 				return err
 			}
 
+			// The modules filter may have been given through the flags
+			cfg.Parser.Modules = config.Cfg.Parser.Modules
+
 			// Set the node to be of type None so that the node won't be built
 			cfg.Node.Type = nodeconfig.TypeNone
 
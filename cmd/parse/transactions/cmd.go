@@ -15,6 +15,7 @@ func NewTransactionsCmd(parseConfig *parsecmdtypes.Config) *cobra.Command {
 
 	cmd.AddCommand(
 		newTransactionsCmd(parseConfig),
+		newMessagesCmd(parseConfig),
 	)
 
 	return cmd
@@ -0,0 +1,74 @@
+package transactions
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	parsecmdtypes "github.com/forbole/juno/v4/cmd/parse/types"
+	"github.com/forbole/juno/v4/log"
+	"github.com/forbole/juno/v4/types/config"
+)
+
+// messagesBackfillBatch is the number of heights whose messages are backfilled at once
+const messagesBackfillBatch = 1000
+
+// newMessagesCmd returns a Cobra command that allows to populate the messages table from the already stored transactions
+func newMessagesCmd(parseConfig *parsecmdtypes.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "messages",
+		Short: "Backfill the messages of the already stored transactions",
+		Long: fmt.Sprintf(`Store the message rows, used to query transactions by message type, of the transactions saved before they were tracked.
+Only the database is read, so no node connection is needed. You can specify a custom height range by using the %s and %s flags.
+`, flagStart, flagEnd),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			parseCtx, err := parsecmdtypes.GetParserContext(config.Cfg, parseConfig)
+			if err != nil {
+				return err
+			}
+
+			ctx := context.Background()
+
+			// Get the flag values
+			start, _ := cmd.Flags().GetUint64(flagStart)
+			end, _ := cmd.Flags().GetUint64(flagEnd)
+
+			// Get the start height, default to the config's height; use flagStart if set
+			startHeight := config.Cfg.Parser.StartHeight
+			if start > 0 {
+				startHeight = start
+			}
+
+			// Get the end height, default to the last stored height; use flagEnd if set
+			endHeight, err := parseCtx.Database.GetLastBlockHeight(ctx)
+			if err != nil {
+				return fmt.Errorf("error while getting db last block height: %s", err)
+			}
+			if end > 0 {
+				endHeight = end
+			}
+
+			log.Infow("backfilling messages...", "start height", startHeight, "end height", endHeight)
+			for from := startHeight; from <= endHeight; from += messagesBackfillBatch {
+				to := from + messagesBackfillBatch - 1
+				if to > endHeight {
+					to = endHeight
+				}
+
+				count, err := parseCtx.Database.BackfillMessages(ctx, from, to)
+				if err != nil {
+					return fmt.Errorf("error while backfilling messages between heights %d and %d: %s", from, to, err)
+				}
+				log.Infow("messages backfilled", "from", from, "to", to, "count", count)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().Uint64(flagStart, 0, "Height from which to start backfilling messages. If 0, the start height inside the config file will be used instead")
+	cmd.Flags().Uint64(flagEnd, 0, "Height at which to finish backfilling messages. If 0, the last height stored inside the database will be used instead")
+
+	return cmd
+}
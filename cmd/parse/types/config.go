@@ -13,13 +13,47 @@ import (
 	"github.com/forbole/juno/v4/types"
 )
 
+const (
+	FlagModulesInclude = "modules.include"
+	FlagModulesExclude = "modules.exclude"
+)
+
+// AddModulesFlags adds to cmd and its sub-commands the flags filtering the modules run, which override the
+// configured filter when given
+func AddModulesFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().StringSlice(FlagModulesInclude, nil, "Names of the only modules to run, all the configured ones when empty")
+	cmd.PersistentFlags().StringSlice(FlagModulesExclude, nil, "Names of the modules not to run")
+}
+
 // ReadConfigPreRunE represents a Cobra cmd function allowing to read the config before executing the command itself
 func ReadConfigPreRunE(cfg *Config) types.CobraCmdFunc {
-	return func(_ *cobra.Command, _ []string) error {
-		return UpdatedGlobalCfg(cfg)
+	return func(cmd *cobra.Command, _ []string) error {
+		err := UpdatedGlobalCfg(cfg)
+		if err != nil {
+			return err
+		}
+		return readModulesFlags(cmd)
 	}
 }
 
+// readModulesFlags overrides the configured modules filter with the one given through the flags of cmd, if any
+func readModulesFlags(cmd *cobra.Command) error {
+	var err error
+	if cmd.Flags().Changed(FlagModulesInclude) {
+		config.Cfg.Parser.Modules.Include, err = cmd.Flags().GetStringSlice(FlagModulesInclude)
+		if err != nil {
+			return err
+		}
+	}
+	if cmd.Flags().Changed(FlagModulesExclude) {
+		config.Cfg.Parser.Modules.Exclude, err = cmd.Flags().GetStringSlice(FlagModulesExclude)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func NewParseConfigFromToml(tomlConfig *config.TomlConfig) config.Config {
 	config := config.Config{}
 	config.Chain = tomlConfig.Chain
@@ -1,19 +1,30 @@
 package types
 
 import (
+	"context"
 	"fmt"
 	"reflect"
+	"time"
 
 	sdk "github.com/cosmos/cosmos-sdk/types"
 
 	"github.com/forbole/juno/v4/database"
 	"github.com/forbole/juno/v4/log"
+	"github.com/forbole/juno/v4/modules/messages"
 	modsregistrar "github.com/forbole/juno/v4/modules/registrar"
 	nodebuilder "github.com/forbole/juno/v4/node/builder"
 	"github.com/forbole/juno/v4/parser"
 	"github.com/forbole/juno/v4/types/config"
 )
 
+// dbPingTimeout is the max time given to the database to answer when the parser starts
+const dbPingTimeout = 10 * time.Second
+
+// addressesParserProvider is implemented by the registrars telling the addresses involved in each message
+type addressesParserProvider interface {
+	AddressesParser() messages.MessageAddressesParser
+}
+
 // GetParserContext setups all the things that can be used to later parse the chain state
 func GetParserContext(cfg config.Config, parseConfig *Config) (*parser.Context, error) {
 	// Build the codec
@@ -26,17 +37,42 @@ func GetParserContext(cfg config.Config, parseConfig *Config) (*parser.Context,
 		sdkConfig.Seal()
 	}
 
+	// Init the client
+	cp, err := nodebuilder.BuildNode(cfg.Node, &encodingConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start client: %s", err)
+	}
+
+	// Every row is stored for the chain of the node, which must be the configured one if any
+	chainID, err := cp.ChainID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the chain id from the node: %s", err)
+	}
+	if cfg.Chain.ChainID != "" && cfg.Chain.ChainID != chainID {
+		return nil, fmt.Errorf("the node is on chain %s while the configured chain is %s", chainID, cfg.Chain.ChainID)
+	}
+
 	// Get the db
 	databaseCtx := database.NewContext(cfg.Database, &encodingConfig)
+	databaseCtx.ChainID = chainID
+	if r, ok := parseConfig.GetRegistrar().(addressesParserProvider); ok {
+		databaseCtx.AddressesParser = r.AddressesParser()
+	}
 	db, err := parseConfig.GetDBBuilder()(databaseCtx)
 	if err != nil {
 		return nil, err
 	}
 
-	// Init the client
-	cp, err := nodebuilder.BuildNode(cfg.Node, &encodingConfig)
-	if err != nil {
-		return nil, fmt.Errorf("failed to start client: %s", err)
+	// Make sure the database is reachable and holds no rows of an unknown chain before any block gets parsed
+	pingCtx, cancel := context.WithTimeout(context.Background(), dbPingTimeout)
+	defer cancel()
+	if err := db.Ping(pingCtx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to reach the database: %w", err)
+	}
+	if err := db.CheckChainID(pingCtx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("database not ready for chain %s: %w", chainID, err)
 	}
 
 	// Setup the logging
@@ -48,6 +84,13 @@ func GetParserContext(cfg config.Config, parseConfig *Config) (*parser.Context,
 	mods := parseConfig.GetRegistrar().BuildModules(context)
 	registeredModules := modsregistrar.GetModules(mods, cfg.Chain.Modules)
 
+	// The filtered out modules are left out of everything, the periodic and async operations included
+	registeredModules, err = modsregistrar.FilterModules(registeredModules, cfg.Parser.Modules.Include, cfg.Parser.Modules.Exclude)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
 	return parser.NewContext(&encodingConfig, cp, db, registeredModules, nil), nil
 }
 
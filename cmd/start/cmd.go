@@ -2,9 +2,8 @@ package start
 
 import (
 	"context"
-	"os"
+	"fmt"
 	"os/signal"
-	"sync"
 	"syscall"
 	"time"
 
@@ -12,21 +11,19 @@ import (
 	"github.com/spf13/cobra"
 
 	parsecmdtypes "github.com/forbole/juno/v4/cmd/parse/types"
+	"github.com/forbole/juno/v4/database"
 	"github.com/forbole/juno/v4/log"
 	"github.com/forbole/juno/v4/modules"
 	"github.com/forbole/juno/v4/parser"
+	parserconfig "github.com/forbole/juno/v4/parser/config"
 	"github.com/forbole/juno/v4/types"
 	"github.com/forbole/juno/v4/types/config"
 	"github.com/forbole/juno/v4/types/utils"
 )
 
-var (
-	waitGroup sync.WaitGroup
-)
-
 // NewStartCmd returns the command that should be run when we want to start parsing a chain state.
 func NewStartCmd(cmdCfg *parsecmdtypes.Config) *cobra.Command {
-	return &cobra.Command{
+	cmd := &cobra.Command{
 		Use:     "start",
 		Short:   "Start parsing the blockchain data",
 		PreRunE: parsecmdtypes.ReadConfigPreRunE(cmdCfg),
@@ -59,12 +56,58 @@ func NewStartCmd(cmdCfg *parsecmdtypes.Config) *cobra.Command {
 			return Parsing(ctx)
 		},
 	}
+
+	parsecmdtypes.AddModulesFlags(cmd)
+
+	return cmd
 }
 
 // Parsing represents the function that should be called when the parse command is executed
 func Parsing(ctx *parser.Context) error {
 	// Get the config
 	cfg := config.Cfg.Parser
+	if cfg.EndHeight > 0 && cfg.EndHeight < cfg.StartHeight {
+		return fmt.Errorf("end height %d is below start height %d", cfg.EndHeight, cfg.StartHeight)
+	}
+	switch cfg.NewBlocksMode {
+	case "", parserconfig.NewBlocksModePoll, parserconfig.NewBlocksModeSubscribe:
+	default:
+		return fmt.Errorf("unknown new blocks mode %q, expected %q or %q",
+			cfg.NewBlocksMode, parserconfig.NewBlocksModePoll, parserconfig.NewBlocksModeSubscribe)
+	}
+
+	// Parsing stops on SIGTERM or SIGINT, once the heights being processed are done
+	stopCtx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
+	// Create a queue that will collect, aggregate, and export blocks and metadata
+	exportQueue := types.NewQueue(25)
+
+	// The missing heights get a low priority queue of their own, so that they don't hold the new heights back
+	backfillQueue, inFlight := types.NewQueue(25), parser.NewInFlightHeights()
+
+	// The heights failing to be processed are recorded, and enqueued again on a periodic basis when enabled
+	failedBlocks := parser.NewFailedBlocks(ctx.Database)
+	var retrier *parser.FailedBlockRetrier
+	if cfg.FailedBlockRetryInterval > 0 && cfg.EndHeight == 0 {
+		retrier = parser.NewFailedBlockRetrier(
+			ctx.Database, backfillQueue, failedBlocks, cfg.FailedBlockRetryInterval, cfg.FailedBlockMaxRetries,
+		)
+	}
+
+	// With an epoch window the epoch is advanced by a committer, once the heights before it are all stored
+	var epochs *parser.EpochCommitter
+	if cfg.EpochWindow > 0 {
+		epoch, err := ctx.Database.GetEpoch(database.ReadFromPrimary(context.TODO()))
+		if err != nil {
+			return err
+		}
+		next := utils.MaxUint64(uint64(epoch.BlockHeight)+1, cfg.StartHeight)
+		epochs = parser.NewEpochCommitter(ctx.Database, next, cfg.EpochWindow)
+		if retrier != nil {
+			retrier.SetEpochCommitter(epochs)
+		}
+	}
 
 	// Start periodic operations
 	scheduler := gocron.NewScheduler(time.UTC)
@@ -76,25 +119,75 @@ func Parsing(ctx *parser.Context) error {
 			}
 		}
 	}
+	if retrier != nil {
+		if err := retrier.RegisterPeriodicOperations(scheduler); err != nil {
+			return err
+		}
+	}
 	scheduler.StartAsync()
 
-	// Create a queue that will collect, aggregate, and export blocks and metadata
-	exportQueue := types.NewQueue(25)
+	// The heights missing below the epoch are looked for on a periodic basis when enabled
+	var backfiller *parser.Backfiller
+	if cfg.BackfillInterval > 0 && cfg.EndHeight == 0 {
+		backfiller = parser.NewBackfiller(ctx.Database, backfillQueue, inFlight, cfg.BackfillFloor, cfg.BackfillMaxGaps)
+	}
 
-	// Create workers
+	// When syncing concurrently, the heights missing at start are caught up with while following the new ones
+	catchUp := cfg.ParseOldBlocks && cfg.ConcurrentSync && cfg.EpochWindow <= 0 && cfg.EndHeight == 0
+
+	// In one-shot mode only the heights of the range get parsed, the summary telling when they are all done
+	var summary *parser.RangeSummary
+	var firstHeight uint64
+	var parseGenesis bool
+	if cfg.EndHeight > 0 {
+		var err error
+		firstHeight, parseGenesis, err = prepareRange(ctx)
+		if err != nil {
+			return err
+		}
+
+		var total uint64
+		if firstHeight <= cfg.EndHeight {
+			total = cfg.EndHeight - firstHeight + 1
+		}
+		if parseGenesis {
+			total++
+		}
+		summary = parser.NewRangeSummary(total)
+	}
+
+	// Create workers, the first ones taking the low priority heights before the new ones
 	workers := make([]*parser.Worker, cfg.Workers)
+	lowPriority := backfiller != nil || retrier != nil || catchUp
+	reserved := parser.BackfillWorkers(cfg.Workers, cfg.BackfillWorkers)
 	for i := range workers {
 		workers[i] = parser.NewWorker(ctx, exportQueue, i, cfg.ConcurrentSync)
 		if ctx.Indexer != nil {
 			workers[i].SetIndexer(ctx.Indexer)
 		}
+		if epochs != nil {
+			workers[i].SetEpochCommitter(epochs)
+		}
+		if lowPriority {
+			workers[i].SetBackfill(backfillQueue, inFlight)
+			if i < reserved {
+				workers[i].ReserveForBackfill()
+			}
+		}
+		if summary != nil {
+			workers[i].SetRangeSummary(summary)
+		}
+		workers[i].SetFailedBlocks(failedBlocks)
 	}
 
-	waitGroup.Add(1)
+	// The workers and the async operations are waited for before exiting
+	var running parser.ShutdownGroup
 
 	// Run all the async operations
 	for _, module := range ctx.Modules {
-		if module, ok := module.(modules.AsyncOperationsModule); ok {
+		if module, ok := module.(modules.StoppableAsyncOperationsModule); ok {
+			running.Go(func() { module.RunAsyncOperationsUntil(stopCtx) })
+		} else if module, ok := module.(modules.AsyncOperationsModule); ok {
 			go module.RunAsyncOperations()
 		}
 	}
@@ -103,39 +196,134 @@ func Parsing(ctx *parser.Context) error {
 	// off of the export queue.
 	for i, w := range workers {
 		log.Debugw("starting worker...", "number", i+1)
-		go w.Start(context.Background())
+		w := w
+		running.Go(func() { w.Start(stopCtx) })
+	}
+
+	// The epoch committer outlives the workers, so that it records the heights they finish while draining
+	epochsCtx, stopEpochs := context.WithCancel(context.Background())
+	defer stopEpochs()
+	epochsStopped := make(chan struct{})
+
+	if epochs != nil {
+		go func() {
+			defer close(epochsStopped)
+			epochs.Run(epochsCtx)
+		}()
 	}
 
-	// Listen for and trap any OS signal to gracefully shutdown and exit
-	trapSignal(ctx)
+	if summary != nil {
+		go enqueueRange(stopCtx, exportQueue, ctx, epochs, summary, firstHeight, parseGenesis)
+	} else if epochs != nil {
+		go enqueueFromEpoch(stopCtx, exportQueue, ctx, epochs)
+	} else if cfg.ParseOldBlocks {
+		if catchUp {
+			go enqueueMissingBlocks(stopCtx, backfillQueue, ctx, inFlight)
+		} else {
+			enqueueMissingBlocks(stopCtx, exportQueue, ctx, nil)
+		}
+	}
 
-	if cfg.ParseOldBlocks {
-		if cfg.ConcurrentSync {
-			go enqueueMissingBlocks(exportQueue, ctx)
+	if summary == nil && epochs == nil && cfg.ParseNewBlocks {
+		if cfg.NewBlocksMode == parserconfig.NewBlocksModeSubscribe {
+			go subscribeNewBlocks(stopCtx, exportQueue, ctx)
 		} else {
-			enqueueMissingBlocks(exportQueue, ctx)
+			go enqueueNewBlocks(stopCtx, exportQueue, ctx)
 		}
 	}
 
-	if cfg.ParseNewBlocks {
-		go enqueueNewBlocks(exportQueue, ctx)
+	if backfiller != nil {
+		go backfiller.Start(stopCtx, cfg.BackfillInterval)
+	}
+	go parser.ReportQueueDepths(stopCtx, exportQueue, backfillQueue, parser.DefaultQueueDepthInterval)
+	go parser.ReportHeights(stopCtx, ctx.Node, ctx.Database, parser.DefaultMetrics, parser.DefaultHeightsInterval)
+
+	// Block main process until a signal is caught, or until the whole range is parsed in one-shot mode
+	var rangeDone <-chan struct{}
+	if summary != nil {
+		rangeDone = summary.Done()
+	}
+	select {
+	case <-stopCtx.Done():
+		log.Infow("caught signal; shutting down...")
+	case <-rangeDone:
+		log.Infow("height range parsed; shutting down...", "start height", cfg.StartHeight, "end height", cfg.EndHeight)
+	}
+	// Stop everything, a second signal killing the process without waiting
+	stop()
+
+	drainTimeout := cfg.DrainTimeout
+	if drainTimeout <= 0 {
+		drainTimeout = parser.DefaultDrainTimeout
 	}
+	scheduler.Stop()
 
-	// Block main process (signal capture will call WaitGroup's Done)
-	waitGroup.Wait()
+	if !running.Wait(drainTimeout) {
+		log.Errorw("drain timeout passed, some heights being processed are left unfinished", "drain timeout", drainTimeout)
+	}
+	if epochs != nil {
+		stopEpochs()
+		<-epochsStopped
+	}
+
+	ctx.Database.Close()
+	ctx.Node.Stop()
+
+	if summary != nil {
+		return reportRange(summary)
+	}
 	return nil
 }
 
-// enqueueMissingBlocks enqueues jobs (block heights) for missed blocks starting
-// at the startHeight up until the latest known height.
-func enqueueMissingBlocks(exportQueue types.HeightQueue, ctx *parser.Context) {
+// prepareRange returns the first height of the range parsed in one-shot mode, and whether the genesis gets
+// parsed too, which it only does when the range starts at the earliest height of the node.
+// With fast sync the modules download their state at the start height, the range starting after it.
+func prepareRange(ctx *parser.Context) (uint64, bool, error) {
+	cfg := config.Cfg.Parser
+
+	if cfg.FastSync {
+		fastSync(ctx, cfg.StartHeight)
+		return cfg.StartHeight + 1, false, nil
+	}
+
+	earliest, err := ctx.Node.EarliestHeight()
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to get earliest height from node: %s", err)
+	}
+	parseGenesis := cfg.ParseGenesis && cfg.StartHeight <= uint64(earliest)
+
+	// Height 0 stands for the genesis
+	return utils.MaxUint64(cfg.StartHeight, 1), parseGenesis, nil
+}
+
+// reportRange logs how many heights of the range parsed in one-shot mode got processed, returning an error
+// if some of them failed, got skipped, or were left when the process was stopped
+func reportRange(summary *parser.RangeSummary) error {
+	processed, failed, skipped := summary.Counts()
+	log.Infow("height range summary", "processed", processed, "failed", failed, "skipped", skipped)
+
+	select {
+	case <-summary.Done():
+		return summary.Err()
+	default:
+		return fmt.Errorf("parsing stopped before the end of the range, after %d heights processed and %d failed", processed, failed)
+	}
+}
+
+// enqueueMissingBlocks enqueues jobs (block heights) for the blocks left unfinished, then for missed blocks
+// starting at the startHeight up until the latest known height.
+// With inFlight, the heights being processed already are skipped, and the enqueued ones recorded there.
+// It returns early once stopCtx is done.
+func enqueueMissingBlocks(stopCtx context.Context, exportQueue types.HeightQueue, ctx *parser.Context, inFlight *parser.InFlightHeights) {
 	// Get the config
 	cfg := config.Cfg.Parser
 
 	// Get the latest height
 	latestBlockHeight := mustGetLatestHeight(ctx)
 
-	lastDbBlockHeight, err := ctx.Database.GetLastBlockHeight(context.TODO())
+	// Where to resume from must not depend on the replication lag of the read replicas
+	dbCtx := database.ReadFromPrimary(context.TODO())
+	lastDbBlockHeight, err := ctx.Database.GetLastBlockHeight(dbCtx)
 	if err != nil {
 		log.Errorw("failed to get last block height from database", "error", err)
 	}
@@ -149,48 +337,219 @@ func enqueueMissingBlocks(exportQueue types.HeightQueue, ctx *parser.Context) {
 		startHeight = utils.MaxUint64(0, lastDbBlockHeight)
 	}
 
+	// The blocks left unfinished by a crash are stored already, so they are not among the missing ones
+	unfinishedHeights, err := ctx.Database.ListBlockProgressHeights(dbCtx)
+	if err != nil {
+		log.Errorw("failed to get unfinished heights from database", "error", err)
+	}
+	for _, i := range unfinishedHeights {
+		if inFlight != nil && !inFlight.Add(i) {
+			continue
+		}
+		log.Infow("enqueueing unfinished block", "height", i)
+		select {
+		case exportQueue <- i:
+		case <-stopCtx.Done():
+			return
+		}
+	}
+
 	if cfg.FastSync {
 		log.Infow("fast sync is enabled, ignoring all previous blocks", "latest_block_height", latestBlockHeight)
-		for _, module := range ctx.Modules {
-			if mod, ok := module.(modules.FastSyncModule); ok {
-				err := mod.DownloadState(int64(latestBlockHeight))
-				if err != nil {
-					log.Error("error while performing fast sync",
-						"err", err,
-						"last_block_height", latestBlockHeight,
-						"module", module.Name(),
-					)
+		fastSync(ctx, latestBlockHeight)
+	} else {
+		log.Infow("syncing missing blocks...", "latest_block_height", latestBlockHeight)
+		for fromHeight := startHeight; fromHeight <= latestBlockHeight; {
+			missingHeights, err := ctx.Database.GetMissingHeights(dbCtx, fromHeight, latestBlockHeight)
+			if err != nil {
+				log.Errorw("failed to get missing heights from database", "from_height", fromHeight, "error", err)
+				return
+			}
+			if len(missingHeights) == 0 {
+				break
+			}
+
+			for _, i := range missingHeights {
+				if inFlight != nil && !inFlight.Add(i) {
+					continue
+				}
+				log.Debugw("enqueueing missing block", "height", i)
+				select {
+				case exportQueue <- i:
+				case <-stopCtx.Done():
+					return
 				}
 			}
+			fromHeight = missingHeights[len(missingHeights)-1] + 1
 		}
-	} else {
-		log.Infow("syncing missing blocks...", "latest_block_height", latestBlockHeight)
-		for _, i := range ctx.Database.GetMissingHeights(context.TODO(), startHeight, latestBlockHeight) {
-			log.Debugw("enqueueing missing block", "height", i)
-			exportQueue <- i
+	}
+}
+
+// fastSync makes the modules supporting it download their state at the given height
+func fastSync(ctx *parser.Context, height uint64) {
+	for _, module := range ctx.Modules {
+		if mod, ok := module.(modules.FastSyncModule); ok {
+			// A module has no state to download before its activation height
+			if !parser.ModuleActive(module, int64(height)) {
+				log.Infow("skipping fast sync of inactive module", "module", module.Name(), "height", height)
+				continue
+			}
+
+			err := mod.DownloadState(int64(height))
+			if err != nil {
+				log.Error("error while performing fast sync",
+					"err", err,
+					"last_block_height", height,
+					"module", module.Name(),
+				)
+			}
 		}
 	}
 }
 
-// enqueueNewBlocks enqueues new block heights onto the provided queue.
-func enqueueNewBlocks(exportQueue types.HeightQueue, ctx *parser.Context) {
-	currHeight, err := ctx.Database.GetLastBlockHeight(context.TODO())
+// enqueueNewBlocks enqueues new block heights onto the provided queue, until stopCtx is done.
+func enqueueNewBlocks(stopCtx context.Context, exportQueue types.HeightQueue, ctx *parser.Context) {
+	currHeight, err := ctx.Database.GetLastBlockHeight(database.ReadFromPrimary(context.TODO()))
 	if err != nil {
 		log.Errorw("failed to get last block height from database", "error", err)
 	}
 
 	currHeight += 1
 
+	// The polls follow the observed block times when adaptive
+	cfg := config.Cfg.Parser
+	var poller *parser.AdaptivePoller
+	if cfg.AdaptivePollInterval {
+		poller = parser.NewAdaptivePoller(ctx.Database, config.GetAvgBlockTime(), cfg.PollIntervalFloor, cfg.PollIntervalCeiling)
+	}
+
 	// Enqueue upcoming heights
 	for {
 		latestBlockHeight := mustGetLatestHeight(ctx)
+		enqueued := currHeight <= latestBlockHeight
 
 		// Enqueue all heights from the current height up to the latest height
 		for ; currHeight <= latestBlockHeight; currHeight++ {
 			log.Debugw("enqueueing new block", "height", currHeight)
-			exportQueue <- currHeight
+			select {
+			case exportQueue <- currHeight:
+			case <-stopCtx.Done():
+				return
+			}
+		}
+
+		wait := config.GetAvgBlockTime()
+		if poller != nil {
+			wait = poller.Wait(database.ReadFromPrimary(stopCtx), latestBlockHeight, enqueued)
+		}
+		select {
+		case <-time.After(wait):
+		case <-stopCtx.Done():
+			return
+		}
+	}
+}
+
+// subscribeNewBlocks enqueues new block heights onto the provided queue as the node delivers the new blocks,
+// until stopCtx is done.
+func subscribeNewBlocks(stopCtx context.Context, exportQueue types.HeightQueue, ctx *parser.Context) {
+	currHeight, err := ctx.Database.GetLastBlockHeight(database.ReadFromPrimary(context.TODO()))
+	if err != nil {
+		log.Errorw("failed to get last block height from database", "error", err)
+	}
+
+	parser.NewBlockSubscriber(ctx.Node, exportQueue, currHeight+1).Start(stopCtx)
+}
+
+// enqueueFromEpoch enqueues every height following the epoch, the new ones included when they are to be parsed.
+// Each height waits for the epoch to get close enough, so that the workers never run more than the epoch
+// window ahead of it. It returns once stopCtx is done.
+func enqueueFromEpoch(stopCtx context.Context, exportQueue types.HeightQueue, ctx *parser.Context, epochs *parser.EpochCommitter) {
+	cfg := config.Cfg.Parser
+	latestBlockHeight := mustGetLatestHeight(ctx)
+
+	for height := epochs.Next(); ; height++ {
+		for height > latestBlockHeight {
+			if !cfg.ParseNewBlocks {
+				return
+			}
+			select {
+			case <-time.After(config.GetAvgBlockTime()):
+			case <-stopCtx.Done():
+				return
+			}
+			latestBlockHeight = mustGetLatestHeight(ctx)
+		}
+
+		if err := epochs.Wait(stopCtx, height); err != nil {
+			if stopCtx.Err() == nil {
+				log.Errorw("failed to wait for the epoch to advance", "height", height, "error", err)
+			}
+			return
+		}
+		log.Debugw("enqueueing block", "height", height)
+		select {
+		case exportQueue <- height:
+		case <-stopCtx.Done():
+			return
+		}
+	}
+}
+
+// enqueueRange enqueues the genesis when asked, and every height from firstHeight up to the end height,
+// waiting for the chain to reach them. It returns once stopCtx is done.
+// With an epoch window the heights the epoch can't reach after a failed height are skipped, as it stays behind it.
+func enqueueRange(
+	stopCtx context.Context, exportQueue types.HeightQueue, ctx *parser.Context,
+	epochs *parser.EpochCommitter, summary *parser.RangeSummary, firstHeight uint64, parseGenesis bool,
+) {
+	endHeight := config.Cfg.Parser.EndHeight
+
+	if parseGenesis {
+		select {
+		case exportQueue <- 0:
+		case <-stopCtx.Done():
+			return
+		}
+	}
+
+	waitCtx, cancel := context.WithCancel(stopCtx)
+	defer cancel()
+	go func() {
+		select {
+		case <-summary.Failure():
+			cancel()
+		case <-waitCtx.Done():
+		}
+	}()
+
+	latestBlockHeight := mustGetLatestHeight(ctx)
+	for height := firstHeight; height <= endHeight; height++ {
+		for height > latestBlockHeight {
+			select {
+			case <-time.After(config.GetAvgBlockTime()):
+			case <-stopCtx.Done():
+				return
+			}
+			latestBlockHeight = mustGetLatestHeight(ctx)
+		}
+
+		if epochs != nil {
+			if err := epochs.Wait(waitCtx, height); err != nil {
+				if stopCtx.Err() == nil {
+					log.Errorw("skipping the heights of the range the epoch can't reach after a failed height", "from height", height)
+					summary.Skip(endHeight - height + 1)
+				}
+				return
+			}
+		}
+
+		log.Debugw("enqueueing block of the range", "height", height)
+		select {
+		case exportQueue <- height:
+		case <-stopCtx.Done():
+			return
 		}
-		time.Sleep(config.GetAvgBlockTime())
 	}
 }
 
@@ -213,20 +572,3 @@ func mustGetLatestHeight(ctx *parser.Context) uint64 {
 
 	return 0
 }
-
-// trapSignal will listen for any OS signal and invoke Done on the main
-// WaitGroup allowing the main process to gracefully exit.
-func trapSignal(ctx *parser.Context) {
-	var sigCh = make(chan os.Signal, 1)
-
-	signal.Notify(sigCh, syscall.SIGTERM)
-	signal.Notify(sigCh, syscall.SIGINT)
-
-	go func() {
-		sig := <-sigCh
-		log.Infow("caught signal; shutting down...", "signal", sig.String())
-		defer ctx.Node.Stop()
-		defer ctx.Database.Close()
-		defer waitGroup.Done()
-	}()
-}
@@ -0,0 +1,83 @@
+package common
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrInvalidHash is returned when parsing a string which is not a hex encoded hash
+	ErrInvalidHash = errors.New("invalid hash")
+
+	// ErrInvalidAddress is returned when parsing a string which is not a hex encoded address
+	ErrInvalidAddress = errors.New("invalid address")
+)
+
+// ParseHash parses a hex encoded hash, with or without the 0x prefix and in any case.
+// Shorter values are left padded with zeros as HexToHash does, while malformed or longer ones are
+// rejected instead of being silently cropped.
+func ParseHash(s string) (Hash, error) {
+	bz, err := parseHexFixed(s, HashLength)
+	if err != nil {
+		return Hash{}, fmt.Errorf("%w %q: %s", ErrInvalidHash, s, err)
+	}
+	return BytesToHash(bz), nil
+}
+
+// ParseAddress parses a hex encoded address, with or without the 0x prefix and in any case
+func ParseAddress(s string) (Address, error) {
+	bz, err := parseHexFixed(s, AddressLength)
+	if err != nil {
+		return Address{}, fmt.Errorf("%w %q: %s", ErrInvalidAddress, s, err)
+	}
+	return BytesToAddress(bz), nil
+}
+
+// ParseAddressOrZero parses a hex encoded address as ParseAddress does, an empty value giving the zero address
+// as HexToAddress does. The events may carry empty addresses, which are accepted as they always were.
+func ParseAddressOrZero(s string) (Address, error) {
+	if strings.TrimSpace(s) == "" {
+		return Address{}, nil
+	}
+	return ParseAddress(s)
+}
+
+// NormalizeHash returns the canonical representation of a hex encoded hash: 0x prefixed,
+// lowercase and 64 digits long
+func NormalizeHash(s string) (string, error) {
+	h, err := ParseHash(s)
+	if err != nil {
+		return "", err
+	}
+	return h.Hex(), nil
+}
+
+// NormalizeAddress returns the canonical representation of a hex encoded address: 0x prefixed,
+// lowercase and 40 digits long. Address.Hex returns the mixed case checksum encoding instead.
+func NormalizeAddress(s string) (string, error) {
+	a, err := ParseAddress(s)
+	if err != nil {
+		return "", err
+	}
+	return "0x" + hex.EncodeToString(a[:]), nil
+}
+
+// parseHexFixed decodes a hex string holding at most size bytes
+func parseHexFixed(s string, size int) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	if has0xPrefix(s) {
+		s = s[2:]
+	}
+	if s == "" {
+		return nil, errors.New("empty value")
+	}
+	if len(s) > 2*size {
+		return nil, fmt.Errorf("longer than %d bytes", size)
+	}
+	if len(s)%2 == 1 {
+		s = "0" + s
+	}
+	return hex.DecodeString(s)
+}
@@ -0,0 +1,76 @@
+package common
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseHash(t *testing.T) {
+	const lower = "0x00000000000000000000000000000000000000000000000000000000000abcde"
+	for _, input := range []string{
+		lower,
+		strings.ToUpper(lower[2:]),
+		"0X" + strings.ToUpper(lower[2:]),
+		"abcde",
+		" 0xABCDE ",
+	} {
+		h, err := ParseHash(input)
+		if err != nil {
+			t.Fatalf("%q: unexpected error %v", input, err)
+		}
+		if h.Hex() != lower {
+			t.Errorf("%q: got %s, want %s", input, h.Hex(), lower)
+		}
+
+		normalized, err := NormalizeHash(input)
+		if err != nil || normalized != lower {
+			t.Errorf("%q: normalized to %q, %v", input, normalized, err)
+		}
+	}
+
+	for _, input := range []string{"", "0x", "0xzz", "0x" + strings.Repeat("ab", HashLength+1)} {
+		if _, err := ParseHash(input); !errors.Is(err, ErrInvalidHash) {
+			t.Errorf("%q: got %v, want ErrInvalidHash", input, err)
+		}
+	}
+}
+
+func TestNormalizeAddress(t *testing.T) {
+	const checksum = "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"
+	for _, input := range []string{checksum, strings.ToLower(checksum), strings.ToUpper(checksum[2:])} {
+		normalized, err := NormalizeAddress(input)
+		if err != nil {
+			t.Fatalf("%q: unexpected error %v", input, err)
+		}
+		if normalized != strings.ToLower(checksum) {
+			t.Errorf("%q: got %s, want %s", input, normalized, strings.ToLower(checksum))
+		}
+
+		a, err := ParseAddress(input)
+		if err != nil || a != HexToAddress(checksum) {
+			t.Errorf("%q: parsed to %s, %v", input, a.Hex(), err)
+		}
+	}
+
+	for _, input := range []string{"", "0xg0", "0x" + strings.Repeat("ab", AddressLength+1)} {
+		if _, err := ParseAddress(input); !errors.Is(err, ErrInvalidAddress) {
+			t.Errorf("%q: got %v, want ErrInvalidAddress", input, err)
+		}
+	}
+}
+
+func TestParseAddressOrZero(t *testing.T) {
+	for _, input := range []string{"", " "} {
+		a, err := ParseAddressOrZero(input)
+		if err != nil || a != (Address{}) {
+			t.Errorf("%q: parsed to %s, %v", input, a.Hex(), err)
+		}
+	}
+
+	for _, input := range []string{"0x", "0xg0"} {
+		if _, err := ParseAddressOrZero(input); !errors.Is(err, ErrInvalidAddress) {
+			t.Errorf("%q: got %v, want ErrInvalidAddress", input, err)
+		}
+	}
+}
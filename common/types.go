@@ -472,10 +472,20 @@ func (a *Uint32Array) Scan(value interface{}) error {
 		*a = nil
 		return nil
 	}
-	s, ok := value.(string)
-	if !ok {
+	var s string
+	switch v := value.(type) {
+	case string:
+		s = v
+	case []byte:
+		// MySQL returns TEXT columns as raw bytes
+		s = string(v)
+	default:
 		return fmt.Errorf("failed to scan Uint32Array value: %v", value)
 	}
+	if s == "" {
+		*a = nil
+		return nil
+	}
 	r := csv.NewReader(strings.NewReader(s))
 	records, err := r.ReadAll()
 	if err != nil {
@@ -534,3 +534,30 @@ func TestHash_Format(t *testing.T) {
 		})
 	}
 }
+
+func TestUint32Array_Scan(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     interface{}
+		want    Uint32Array
+		wantErr bool
+	}{
+		{name: "string scan", src: "1,2,3", want: Uint32Array{1, 2, 3}},
+		{name: "bytes scan", src: []byte("4,5"), want: Uint32Array{4, 5}},
+		{name: "nil scan", src: nil, want: nil},
+		{name: "empty scan", src: "", want: nil},
+		{name: "invalid number scan", src: "1,a", wantErr: true},
+		{name: "non working scan", src: int64(1), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var a Uint32Array
+			if err := a.Scan(tt.src); (err != nil) != tt.wantErr {
+				t.Errorf("Uint32Array.Scan() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(a, tt.want) {
+				t.Errorf("Uint32Array.Scan() = %v, want %v", a, tt.want)
+			}
+		})
+	}
+}
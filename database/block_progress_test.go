@@ -0,0 +1,49 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/forbole/juno/v4/common"
+	"github.com/forbole/juno/v4/models"
+)
+
+func TestBlockProgress(t *testing.T) {
+	ctx := context.Background()
+	mainnet, testnet := newTestChains(t, &models.BlockProgress{})
+
+	_, err := mainnet.GetBlockProgress(ctx, 7)
+	require.ErrorIs(t, err, ErrBlockProgressNotFound)
+
+	hash := common.HexToHash("0x07")
+	require.NoError(t, mainnet.SaveBlockProgress(ctx, &models.BlockProgress{Height: 7, BlockHash: hash, TxIndex: 0, EventIndex: -1}))
+	require.NoError(t, mainnet.SaveBlockProgress(ctx, &models.BlockProgress{Height: 7, BlockHash: hash, TxIndex: 2, EventIndex: 5}))
+	require.NoError(t, testnet.SaveBlockProgress(ctx, &models.BlockProgress{Height: 7, TxIndex: 1, EventIndex: 1}))
+
+	// Saving again replaces the progress of the height
+	progress, err := mainnet.GetBlockProgress(ctx, 7)
+	require.NoError(t, err)
+	require.Equal(t, hash, progress.BlockHash)
+	require.Equal(t, 2, progress.TxIndex)
+	require.Equal(t, 5, progress.EventIndex)
+
+	require.NoError(t, mainnet.SaveBlockProgress(ctx, &models.BlockProgress{Height: 3, TxIndex: -1, EventIndex: -1}))
+	heights, err := mainnet.ListBlockProgressHeights(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []uint64{3, 7}, heights)
+
+	require.NoError(t, mainnet.DeleteBlockProgress(ctx, 7))
+	require.NoError(t, mainnet.DeleteBlockProgress(ctx, 8))
+	_, err = mainnet.GetBlockProgress(ctx, 7)
+	require.ErrorIs(t, err, ErrBlockProgressNotFound)
+
+	// The other chain is left untouched
+	progress, err = testnet.GetBlockProgress(ctx, 7)
+	require.NoError(t, err)
+	require.Equal(t, 1, progress.EventIndex)
+	heights, err = testnet.ListBlockProgressHeights(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []uint64{7}, heights)
+}
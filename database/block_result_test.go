@@ -0,0 +1,94 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/forbole/juno/v4/models"
+)
+
+// newTestBlockResult returns a JSON document of about the given size, looking like the results of a busy block
+func newTestBlockResult(size int) []byte {
+	var sb strings.Builder
+	sb.WriteString(`{"height":"1","txs_results":[`)
+	for i := 0; sb.Len() < size; i++ {
+		if i > 0 {
+			sb.WriteString(",")
+		}
+		fmt.Fprintf(&sb, `{"code":0,"gas_used":"%d","events":[{"type":"transfer","attributes":[{"key":"amount","value":"%dazkme"}]}]}`, 1000+i, i)
+	}
+	sb.WriteString(`]}`)
+	return []byte(sb.String())
+}
+
+// getStoredBlockResult returns the block result row as it is stored
+func getStoredBlockResult(t *testing.T, db *Impl, height uint64) *models.BlockResult {
+	t.Helper()
+
+	var row models.BlockResult
+	require.NoError(t, db.Db.Table((&models.BlockResult{}).TableName()).Where("block_height = ?", height).Take(&row).Error)
+	return &row
+}
+
+func TestBlockResult(t *testing.T) {
+	ctx := context.Background()
+	db := newTestImpl(t, &models.BlockResult{})
+
+	_, err := db.GetBlockResult(ctx, 1)
+	require.ErrorIs(t, err, ErrBlockResultNotFound)
+
+	// Small results are stored as they are
+	small := newTestBlockResult(1024)
+	require.NoError(t, db.SaveBlockResult(ctx, 1, small))
+	require.Equal(t, models.BlockResultEncodingRaw, getStoredBlockResult(t, db, 1).Encoding)
+	result, err := db.GetBlockResult(ctx, 1)
+	require.NoError(t, err)
+	require.Equal(t, small, result)
+
+	// Large ones are compressed, shrinking well below the 16MB of a mediumtext
+	large := newTestBlockResult(20 << 20)
+	require.NoError(t, db.SaveBlockResult(ctx, 2, large))
+	stored := getStoredBlockResult(t, db, 2)
+	require.Equal(t, models.BlockResultEncodingGzip, stored.Encoding)
+	require.Less(t, len(stored.Result), 4<<20)
+	result, err = db.GetBlockResult(ctx, 2)
+	require.NoError(t, err)
+	require.Equal(t, large, result)
+
+	// Saving the same height again replaces the results along with their encoding
+	require.NoError(t, db.SaveBlockResult(ctx, 2, small))
+	require.Equal(t, models.BlockResultEncodingRaw, getStoredBlockResult(t, db, 2).Encoding)
+	result, err = db.GetBlockResult(ctx, 2)
+	require.NoError(t, err)
+	require.Equal(t, small, result)
+}
+
+func TestBlockResultCompressionThreshold(t *testing.T) {
+	ctx := context.Background()
+	db := newTestImpl(t, &models.BlockResult{})
+	result := newTestBlockResult(2048)
+
+	db.GzipThreshold = len(result)
+	require.NoError(t, db.SaveBlockResult(ctx, 1, result))
+	require.Equal(t, models.BlockResultEncodingRaw, getStoredBlockResult(t, db, 1).Encoding)
+
+	db.GzipThreshold = len(result) - 1
+	require.NoError(t, db.SaveBlockResult(ctx, 2, result))
+	require.Equal(t, models.BlockResultEncodingGzip, getStoredBlockResult(t, db, 2).Encoding)
+
+	// A negative threshold disables the compression
+	db.GzipThreshold = -1
+	large := newTestBlockResult(DefaultBlockResultCompressionThreshold * 2)
+	require.NoError(t, db.SaveBlockResult(ctx, 3, large))
+	require.Equal(t, models.BlockResultEncodingRaw, getStoredBlockResult(t, db, 3).Encoding)
+
+	for height := uint64(1); height <= 2; height++ {
+		stored, err := db.GetBlockResult(ctx, height)
+		require.NoError(t, err)
+		require.Equal(t, result, stored)
+	}
+}
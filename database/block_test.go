@@ -0,0 +1,152 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/forbole/juno/v4/common"
+	"github.com/forbole/juno/v4/models"
+)
+
+func saveTestBlocks(t testing.TB, db *Impl, heights ...uint64) {
+	t.Helper()
+
+	for _, height := range heights {
+		require.NoError(t, db.SaveBlock(context.Background(), &models.Block{
+			BlockID: models.BlockID{Hash: common.BigToHash(new(big.Int).SetUint64(height))},
+			Header:  models.Header{Height: height},
+		}))
+	}
+}
+
+func TestGetMissingHeights(t *testing.T) {
+	ctx := context.Background()
+	db := newTestImpl(t, &models.Block{})
+
+	heights, err := db.GetMissingHeights(ctx, 1, 5)
+	require.NoError(t, err)
+	require.Equal(t, []uint64{1, 2, 3, 4, 5}, heights)
+
+	saveTestBlocks(t, db, 3, 4, 7, 10, 11)
+
+	heights, err = db.GetMissingHeights(ctx, 1, 13)
+	require.NoError(t, err)
+	require.Equal(t, []uint64{1, 2, 5, 6, 8, 9, 12, 13}, heights)
+
+	heights, err = db.GetMissingHeights(ctx, 3, 11)
+	require.NoError(t, err)
+	require.Equal(t, []uint64{5, 6, 8, 9}, heights)
+
+	heights, err = db.GetMissingHeights(ctx, 10, 11)
+	require.NoError(t, err)
+	require.Empty(t, heights)
+
+	heights, err = db.GetMissingHeights(ctx, 5, 1)
+	require.NoError(t, err)
+	require.Empty(t, heights)
+
+	// The result must be capped and resumable from the last returned height
+	db.MaxMissingHeights = 3
+	heights, err = db.GetMissingHeights(ctx, 1, 13)
+	require.NoError(t, err)
+	require.Equal(t, []uint64{1, 2, 5}, heights)
+
+	heights, err = db.GetMissingHeights(ctx, heights[len(heights)-1]+1, 13)
+	require.NoError(t, err)
+	require.Equal(t, []uint64{6, 8, 9}, heights)
+}
+
+// BenchmarkGetMissingHeights measures the gap detection over 5M stored blocks with a gap every 1000 blocks
+func BenchmarkGetMissingHeights(b *testing.B) {
+	db := newTestImpl(b, &models.Block{})
+
+	err := db.Db.Exec(`
+INSERT INTO blocks (height, hash)
+WITH RECURSIVE seq(height) AS (SELECT 1 UNION ALL SELECT height + 1 FROM seq WHERE height < 5000000)
+SELECT height, randomblob(32) FROM seq WHERE height % 1000 != 0`).Error
+	require.NoError(b, err)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		heights, err := db.GetMissingHeights(context.Background(), 1, 5000000)
+		require.NoError(b, err)
+		require.Len(b, heights, 5000)
+	}
+}
+
+func TestBlocksByTime(t *testing.T) {
+	ctx := context.Background()
+	db := newTestImpl(t, &models.Block{})
+
+	for height := uint64(1); height <= 5; height++ {
+		require.NoError(t, db.SaveBlock(ctx, &models.Block{
+			BlockID: models.BlockID{Hash: common.BigToHash(new(big.Int).SetUint64(height))},
+			Header:  models.Header{Height: height, Timestamp: 1000 + height*10},
+		}))
+	}
+
+	// Before genesis
+	_, err := db.GetBlockNearTime(ctx, time.Unix(1009, 0))
+	require.True(t, errors.Is(err, ErrBlockNotFound))
+
+	block, err := db.GetBlockNearTime(ctx, time.Unix(1010, 0))
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), block.Height)
+
+	block, err = db.GetBlockNearTime(ctx, time.Unix(1030, 0))
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), block.Height)
+
+	block, err = db.GetBlockNearTime(ctx, time.Unix(1039, 999))
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), block.Height)
+
+	block, err = db.GetBlockNearTime(ctx, time.Unix(5000, 0))
+	require.NoError(t, err)
+	require.Equal(t, uint64(5), block.Height)
+
+	blockHeights := func(blocks []*models.Block) []uint64 {
+		heights := make([]uint64, len(blocks))
+		for i, block := range blocks {
+			heights[i] = block.Height
+		}
+		return heights
+	}
+
+	// Both from and to are inclusive
+	blocks, err := db.ListBlocksByTimeRange(ctx, time.Unix(1020, 0), time.Unix(1040, 0), 10, 0)
+	require.NoError(t, err)
+	require.Equal(t, []uint64{2, 3, 4}, blockHeights(blocks))
+
+	blocks, err = db.ListBlocksByTimeRange(ctx, time.Unix(1020, 1), time.Unix(1039, 0), 10, 0)
+	require.NoError(t, err)
+	require.Equal(t, []uint64{3}, blockHeights(blocks))
+
+	blocks, err = db.ListBlocksByTimeRange(ctx, time.Unix(0, 0), time.Unix(5000, 0), 2, 2)
+	require.NoError(t, err)
+	require.Equal(t, []uint64{3, 4}, blockHeights(blocks))
+}
+
+func TestSaveBlockReplacesHashAtHeight(t *testing.T) {
+	ctx := context.Background()
+	db := newTestImpl(t, &models.Block{})
+
+	oldHash := common.HexToHash("0xaa")
+	newHash := common.HexToHash("0xbb")
+	for _, hash := range []common.Hash{oldHash, newHash} {
+		require.NoError(t, db.SaveBlock(ctx, &models.Block{
+			BlockID: models.BlockID{Hash: hash},
+			Header:  models.Header{Height: 10},
+		}))
+	}
+
+	var blocks []*models.Block
+	require.NoError(t, db.Db.Table((&models.Block{}).TableName()).Where("height = ?", 10).Find(&blocks).Error)
+	require.Len(t, blocks, 1)
+	require.Equal(t, newHash, blocks[0].Hash)
+}
@@ -0,0 +1,133 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/forbole/juno/v4/common"
+	"github.com/forbole/juno/v4/models"
+)
+
+func TestGetBucket(t *testing.T) {
+	ctx := context.Background()
+	db := newTestImpl(t, &models.Bucket{})
+
+	liveID, removedID := common.HexToHash("0x01"), common.HexToHash("0x02")
+	require.NoError(t, db.SaveBucket(ctx, &models.Bucket{BucketID: liveID, BucketName: "live"}))
+	require.NoError(t, db.SaveBucket(ctx, &models.Bucket{BucketID: removedID, BucketName: "removed"}))
+	require.NoError(t, db.UpdateBucket(ctx, &models.Bucket{BucketID: removedID, Removed: true}))
+
+	bucket, err := db.GetBucketByName(ctx, "live")
+	require.NoError(t, err)
+	require.Equal(t, liveID, bucket.BucketID)
+
+	bucket, err = db.GetBucketByID(ctx, liveID)
+	require.NoError(t, err)
+	require.Equal(t, "live", bucket.BucketName)
+
+	_, err = db.GetBucketByName(ctx, "removed")
+	require.True(t, errors.Is(err, ErrBucketNotFound))
+
+	_, err = db.GetBucketByID(ctx, removedID)
+	require.True(t, errors.Is(err, ErrBucketNotFound))
+
+	bucket, err = db.GetBucketByNameWithRemoved(ctx, "removed")
+	require.NoError(t, err)
+	require.True(t, bucket.Removed)
+
+	bucket, err = db.GetBucketByIDWithRemoved(ctx, removedID)
+	require.NoError(t, err)
+	require.Equal(t, "removed", bucket.BucketName)
+
+	_, err = db.GetBucketByNameWithRemoved(ctx, "missing")
+	require.True(t, errors.Is(err, ErrBucketNotFound))
+}
+
+func TestMultiSaveBuckets(t *testing.T) {
+	ctx := context.Background()
+	db := newTestImpl(t, &models.Bucket{})
+	db.UpsertBatchSize = 300
+
+	buckets := make([]*models.Bucket, 10000)
+	for i := range buckets {
+		buckets[i] = &models.Bucket{
+			BucketID:   common.BigToHash(big.NewInt(int64(i + 1))),
+			BucketName: fmt.Sprintf("bucket-%d", i),
+			UpdateAt:   1,
+		}
+	}
+	require.NoError(t, db.MultiSaveBuckets(ctx, buckets))
+
+	count := func() int64 {
+		var count int64
+		require.NoError(t, db.Db.Table((&models.Bucket{}).TableName()).Count(&count).Error)
+		return count
+	}
+	require.Equal(t, int64(10000), count())
+
+	// Saving them again, as rows freshly decoded from the chain, updates the existing rows instead of adding new ones
+	for _, bucket := range buckets {
+		bucket.ID = 0
+		bucket.UpdateAt = 2
+	}
+	require.NoError(t, db.MultiSaveBuckets(ctx, buckets))
+	require.Equal(t, int64(10000), count())
+
+	bucket, err := db.GetBucketByName(ctx, "bucket-9999")
+	require.NoError(t, err)
+	require.Equal(t, buckets[9999].BucketID, bucket.BucketID)
+	require.Equal(t, int64(2), bucket.UpdateAt)
+
+	// Of the buckets sharing an id, the last one is saved
+	require.NoError(t, db.MultiSaveBuckets(ctx, []*models.Bucket{
+		{BucketID: buckets[0].BucketID, BucketName: "bucket-0", UpdateAt: 3},
+		{BucketID: buckets[1].BucketID, BucketName: "bucket-1", UpdateAt: 3},
+		{BucketID: buckets[0].BucketID, BucketName: "bucket-0", UpdateAt: 4},
+	}))
+	require.Equal(t, int64(10000), count())
+	bucket, err = db.GetBucketByName(ctx, "bucket-0")
+	require.NoError(t, err)
+	require.Equal(t, int64(4), bucket.UpdateAt)
+}
+
+func TestPreparedStatements(t *testing.T) {
+	ctx := context.Background()
+	db := newTestImplWithConfig(t, &gorm.Config{PrepareStmt: true}, &models.Bucket{})
+
+	require.NoError(t, db.SaveBucket(ctx, &models.Bucket{BucketID: common.HexToHash("0x01"), BucketName: "first"}))
+
+	// Transactions keep using the prepared statements of their connection
+	tx := db.Begin(ctx)
+	require.IsType(t, &gorm.PreparedStmtTX{}, tx.Db.Statement.ConnPool)
+	require.NoError(t, tx.SaveBucket(ctx, &models.Bucket{BucketID: common.HexToHash("0x02"), BucketName: "second"}))
+	require.NoError(t, tx.Commit())
+
+	_, err := db.GetBucketByName(ctx, "second")
+	require.NoError(t, err)
+}
+
+func BenchmarkSaveBucket(b *testing.B) {
+	for _, prepareStmt := range []bool{false, true} {
+		b.Run(fmt.Sprintf("prepare_stmt=%t", prepareStmt), func(b *testing.B) {
+			ctx := context.Background()
+			db := newTestImplWithConfig(b, &gorm.Config{PrepareStmt: prepareStmt}, &models.Bucket{})
+
+			b.ResetTimer()
+			for n := 0; n < b.N; n++ {
+				bucket := &models.Bucket{
+					BucketID:   common.BigToHash(big.NewInt(int64(n % 1000))),
+					BucketName: fmt.Sprintf("bucket-%d", n%1000),
+				}
+				if err := db.SaveBucket(ctx, bucket); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
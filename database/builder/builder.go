@@ -7,6 +7,7 @@ import (
 	databaseconfig "github.com/forbole/juno/v4/database/config"
 	"github.com/forbole/juno/v4/database/mysql"
 	"github.com/forbole/juno/v4/database/postgresql"
+	"github.com/forbole/juno/v4/database/sqlite"
 )
 
 // Builder represents a generic Builder implementation that build the proper database
@@ -17,6 +18,8 @@ func Builder(ctx *database.Context) (database.Database, error) {
 		return postgresql.Builder(ctx)
 	case databaseconfig.MySQL:
 		return mysql.Builder(ctx)
+	case databaseconfig.SQLite:
+		return sqlite.Builder(ctx)
 	default:
 		return nil, errors.New("unsupported database type")
 	}
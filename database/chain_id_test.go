@@ -0,0 +1,263 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm/schema"
+
+	"github.com/forbole/juno/v4/common"
+	"github.com/forbole/juno/v4/models"
+	"github.com/forbole/juno/v4/types"
+)
+
+// newTestChains returns two Impls sharing the same database, scoped to two different chains
+func newTestChains(t *testing.T, tables ...schema.Tabler) (mainnet, testnet *Impl) {
+	t.Helper()
+
+	mainnet = newTestImpl(t, tables...)
+	mainnet.ChainID = "mainnet"
+	testnet = mainnet.withDb(mainnet.Db)
+	testnet.ChainID = "testnet"
+	return mainnet, testnet
+}
+
+// saveChainBlocks saves blocks at the given heights, their hashes telling the chain apart
+func saveChainBlocks(t *testing.T, db *Impl, heights ...uint64) {
+	t.Helper()
+
+	for _, height := range heights {
+		require.NoError(t, db.SaveBlock(context.Background(), &models.Block{
+			BlockID: models.BlockID{Hash: common.HexToHash(fmt.Sprintf("%x", db.ChainID+fmt.Sprint(height)))},
+			Header:  models.Header{Height: height},
+		}))
+	}
+}
+
+func TestChainIDScopesBlocks(t *testing.T) {
+	ctx := context.Background()
+	mainnet, testnet := newTestChains(t, &models.Block{}, &models.Epoch{}, &models.Bucket{})
+
+	// Both chains get indexed at the same time
+	saveChainBlocks(t, mainnet, 1)
+	saveChainBlocks(t, testnet, 1)
+	saveChainBlocks(t, mainnet, 2, 3)
+	saveChainBlocks(t, testnet, 2, 5)
+	saveChainBlocks(t, mainnet, 1)
+
+	for _, c := range []struct {
+		db      *Impl
+		last    uint64
+		missing []uint64
+	}{
+		{mainnet, 3, []uint64{4, 5}},
+		{testnet, 5, []uint64{3, 4}},
+	} {
+		height, err := c.db.GetLastBlockHeight(ctx)
+		require.NoError(t, err)
+		require.Equal(t, c.last, height, c.db.ChainID)
+
+		missing, err := c.db.GetMissingHeights(ctx, 1, 5)
+		require.NoError(t, err)
+		require.Equal(t, c.missing, missing, c.db.ChainID)
+
+		require.Equal(t, int64(3), c.db.GetTotalBlocks(ctx))
+	}
+
+	has, err := mainnet.HasBlock(ctx, 5)
+	require.NoError(t, err)
+	require.False(t, has)
+	has, err = testnet.HasBlock(ctx, 5)
+	require.NoError(t, err)
+	require.True(t, has)
+
+	// Each chain has its own epoch
+	require.NoError(t, mainnet.SaveEpoch(ctx, &models.Epoch{OneRowId: true, BlockHeight: 3}))
+	require.NoError(t, testnet.SaveEpoch(ctx, &models.Epoch{OneRowId: true, BlockHeight: 5}))
+	require.NoError(t, mainnet.SaveEpoch(ctx, &models.Epoch{OneRowId: true, BlockHeight: 4}))
+	epoch, err := mainnet.GetEpoch(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(4), epoch.BlockHeight)
+	epoch, err = testnet.GetEpoch(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(5), epoch.BlockHeight)
+
+	// The same bucket id and name may exist on both chains
+	bucketID := common.HexToHash("0x01")
+	for _, db := range []*Impl{mainnet, testnet} {
+		require.NoError(t, db.SaveBucket(ctx, &models.Bucket{BucketID: bucketID, BucketName: "bucket", Visibility: db.ChainID}))
+	}
+	require.NoError(t, testnet.UpdateBucket(ctx, &models.Bucket{BucketID: bucketID, Removed: true}, "removed"))
+
+	bucket, err := mainnet.GetBucketByName(ctx, "bucket")
+	require.NoError(t, err)
+	require.Equal(t, "mainnet", bucket.Visibility)
+	_, err = testnet.GetBucketByID(ctx, bucketID)
+	require.ErrorIs(t, err, ErrBucketNotFound)
+	bucket, err = testnet.GetBucketByIDWithRemoved(ctx, bucketID)
+	require.NoError(t, err)
+	require.Equal(t, "testnet", bucket.Visibility)
+}
+
+func TestChainIDScopesTxsAndObjects(t *testing.T) {
+	ctx := context.Background()
+	mainnet, testnet := newTestChains(t, &models.Tx{}, &models.Message{}, &models.Object{}, &models.CommitSig{}, &models.BlockResult{})
+
+	// Both chains have a tx at the same height and index
+	require.NoError(t, mainnet.SaveTx(ctx, 1700000000, 0, newTestTx(t, "0x01", 10)))
+	require.NoError(t, testnet.SaveTx(ctx, 1700000000, 0, newTestTx(t, "0x02", 10)))
+	// as well as the same signatures and block results
+	for _, db := range []*Impl{mainnet, testnet} {
+		require.NoError(t, db.SaveCommitSignatures(ctx, []*types.CommitSig{
+			types.NewCommitSig("mevalcons1", 10, 0, 10, time.Unix(1700000000, 0)),
+		}))
+		require.NoError(t, db.SaveBlockResult(ctx, 10, []byte(db.ChainID)))
+	}
+
+	for _, c := range []struct {
+		db   *Impl
+		hash string
+	}{{mainnet, "0x01"}, {testnet, "0x02"}} {
+		txs, total, err := c.db.GetTxsByHeight(ctx, 10, 10, 0)
+		require.NoError(t, err)
+		require.Equal(t, int64(1), total)
+		require.Equal(t, common.HexToHash(c.hash), txs[0].Hash)
+		require.Equal(t, c.db.ChainID, txs[0].ChainID)
+
+		txs, err = c.db.ListTxsByMessageType(ctx, "/cosmos.bank.v1beta1.MsgSend", 0, 10, 10, 0)
+		require.NoError(t, err)
+		require.Len(t, txs, 1)
+		require.Equal(t, common.HexToHash(c.hash), txs[0].Hash)
+	}
+	_, err := mainnet.GetTxByHash(ctx, common.HexToHash("0x02"))
+	require.ErrorIs(t, err, ErrTxNotFound)
+
+	// Pruning a chain leaves the txs of the other one
+	require.NoError(t, mainnet.Prune(ctx, 10))
+	_, total, err := mainnet.GetTxsByHeight(ctx, 10, 10, 0)
+	require.NoError(t, err)
+	require.Zero(t, total)
+	_, err = testnet.GetTxByHash(ctx, common.HexToHash("0x02"))
+	require.NoError(t, err)
+	var msgs int64
+	require.NoError(t, testnet.Db.Table((&models.Message{}).TableName()).Count(&msgs).Error)
+	require.Equal(t, int64(1), msgs)
+	var sigs []string
+	require.NoError(t, testnet.Db.Table((&models.CommitSig{}).TableName()).Pluck("chain_id", &sigs).Error)
+	require.Equal(t, []string{"testnet"}, sigs)
+	_, err = mainnet.GetBlockResult(ctx, 10)
+	require.ErrorIs(t, err, ErrBlockResultNotFound)
+	result, err := testnet.GetBlockResult(ctx, 10)
+	require.NoError(t, err)
+	require.Equal(t, []byte("testnet"), result)
+
+	// Objects are scoped as well
+	objectID := common.HexToHash("0x01")
+	require.NoError(t, mainnet.SaveObject(ctx, &models.Object{ObjectID: objectID, BucketName: "bucket", ObjectName: "file", Status: "OBJECT_STATUS_SEALED"}))
+	require.NoError(t, testnet.MultiSaveObjects(ctx, []*models.Object{
+		{ObjectID: objectID, BucketName: "bucket", ObjectName: "file", Status: "OBJECT_STATUS_CREATED"},
+		{ObjectID: common.HexToHash("0x02"), BucketName: "bucket", ObjectName: "other", Removed: true},
+	}))
+
+	object, err := mainnet.GetObjectByBucketAndName(ctx, "bucket", "file")
+	require.NoError(t, err)
+	require.Equal(t, "OBJECT_STATUS_SEALED", object.Status)
+	object, err = testnet.GetObject(ctx, objectID)
+	require.NoError(t, err)
+	require.Equal(t, "OBJECT_STATUS_CREATED", object.Status)
+
+	objectsTotal, sealed, removed, err := mainnet.CountObjects(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []int64{1, 1, 0}, []int64{objectsTotal, sealed, removed})
+	objectsTotal, sealed, removed, err = testnet.CountObjects(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []int64{2, 0, 1}, []int64{objectsTotal, sealed, removed})
+}
+
+// The tables as they were before the rows recorded their chain id
+type (
+	legacyBlock struct {
+		ID       uint64      `gorm:"column:id;primaryKey"`
+		Hash     common.Hash `gorm:"column:hash;type:BINARY(32);not null;uniqueIndex:idx_hash"`
+		Height   uint64      `gorm:"column:height;not null;uniqueIndex:idx_height"`
+		NumTxs   uint64      `gorm:"column:num_txs"`
+		TotalGas uint64      `gorm:"column:total_gas"`
+	}
+	legacyEpoch struct {
+		OneRowId    bool        `gorm:"one_row_id;not null;default:true;primaryKey"`
+		BlockHeight int64       `gorm:"block_height;type:bigint(64)"`
+		BlockHash   common.Hash `gorm:"block_hash;type:BINARY(32)"`
+		UpdateTime  int64       `gorm:"update_time;type:bigint(64)"`
+	}
+	legacyBucket struct {
+		ID           uint64          `gorm:"column:id;primaryKey"`
+		BucketID     common.Hash     `gorm:"column:bucket_id;type:BINARY(32);uniqueIndex:idx_bucket_id"`
+		BucketName   string          `gorm:"column:bucket_name;type:varchar(64);uniqueIndex:idx_bucket_name"`
+		StorageSize  decimal.Decimal `gorm:"column:storage_size;type:DECIMAL(65, 0);not null"`
+		ChargeSize   decimal.Decimal `gorm:"column:charge_size;type:DECIMAL(65, 0);not null"`
+		CreateTxHash common.Hash     `gorm:"column:create_tx_hash;type:BINARY(32);not null"`
+		UpdateTxHash common.Hash     `gorm:"column:update_tx_hash;type:BINARY(32);not null"`
+	}
+	legacyBlockResult struct {
+		BlockHeight uint64 `gorm:"primaryKey"`
+		Result      string `gorm:"column:result"`
+	}
+)
+
+func (*legacyBlock) TableName() string       { return "blocks" }
+func (*legacyEpoch) TableName() string       { return "epoch" }
+func (*legacyBucket) TableName() string      { return "buckets" }
+func (*legacyBlockResult) TableName() string { return "block_result" }
+
+func TestBackfillChainID(t *testing.T) {
+	skipUnlessSQLite(t)
+	ctx := context.Background()
+	mainnet, testnet := newTestChains(t, &legacyBlock{}, &legacyEpoch{}, &legacyBucket{}, &legacyBlockResult{})
+	require.NoError(t, mainnet.Db.Create(&legacyBlock{Hash: common.HexToHash("0x01"), Height: 1}).Error)
+	require.NoError(t, mainnet.Db.Create(&legacyBlockResult{BlockHeight: 1, Result: "{}"}).Error)
+	require.NoError(t, mainnet.Db.Create(&legacyEpoch{OneRowId: true, BlockHeight: 1}).Error)
+	require.NoError(t, mainnet.Db.Create(&legacyBucket{BucketID: common.HexToHash("0x01"), BucketName: "bucket"}).Error)
+
+	require.ErrorIs(t, mainnet.CheckChainID(ctx), ErrChainIDNotBackfilled)
+
+	count, err := mainnet.BackfillChainID(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(4), count)
+	require.NoError(t, mainnet.CheckChainID(ctx))
+	require.NoError(t, testnet.CheckChainID(ctx))
+
+	// The stored rows now belong to the backfilled chain
+	height, err := mainnet.GetLastBlockHeight(ctx)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), height)
+	epoch, err := mainnet.GetEpoch(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), epoch.BlockHeight)
+	_, err = mainnet.GetBucketByName(ctx, "bucket")
+	require.NoError(t, err)
+	result, err := mainnet.GetBlockResult(ctx, 1)
+	require.NoError(t, err)
+	require.Equal(t, []byte("{}"), result)
+
+	// while the other chain can store the same heights and buckets
+	saveChainBlocks(t, testnet, 1)
+	require.NoError(t, testnet.SaveEpoch(ctx, &models.Epoch{OneRowId: true, BlockHeight: 1}))
+	require.NoError(t, testnet.SaveBucket(ctx, &models.Bucket{BucketID: common.HexToHash("0x01"), BucketName: "bucket"}))
+	require.NoError(t, testnet.SaveBlockResult(ctx, 1, []byte("testnet")))
+	height, err = testnet.GetLastBlockHeight(ctx)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), height)
+
+	// Running it again has nothing left to do
+	count, err = mainnet.BackfillChainID(ctx)
+	require.NoError(t, err)
+	require.Zero(t, count)
+
+	// Rows stored without a chain id are reported again
+	require.NoError(t, mainnet.Db.Exec("UPDATE blocks SET chain_id = '' WHERE chain_id = ?", "testnet").Error)
+	require.ErrorIs(t, mainnet.CheckChainID(ctx), ErrChainIDNotBackfilled)
+}
@@ -0,0 +1,42 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/forbole/juno/v4/models"
+	"github.com/forbole/juno/v4/types"
+)
+
+func TestSaveCommitSignatures(t *testing.T) {
+	ctx := context.Background()
+	db := newTestImpl(t, &models.CommitSig{})
+
+	// More signatures than fit inside a single batch
+	timestamp := time.Unix(1700000000, 0).UTC()
+	signatures := make([]*types.CommitSig, 2*commitSigsBatchSize+1)
+	for i := range signatures {
+		signatures[i] = types.NewCommitSig(fmt.Sprintf("mevalcons%d", i), 10, int64(i), 5, timestamp)
+	}
+	require.NoError(t, db.SaveCommitSignatures(ctx, signatures))
+
+	// The same validator signing the same height again, with a different timestamp, is a duplicate
+	duplicate := types.NewCommitSig("mevalcons0", 20, 0, 5, timestamp.Add(time.Second))
+	next := types.NewCommitSig("mevalcons0", 10, 0, 6, timestamp.Add(time.Second))
+	require.NoError(t, db.SaveCommitSignatures(ctx, []*types.CommitSig{duplicate, next}))
+
+	var count int64
+	require.NoError(t, db.Db.Table((&models.CommitSig{}).TableName()).Count(&count).Error)
+	require.Equal(t, int64(len(signatures)+1), count)
+
+	var stored models.CommitSig
+	require.NoError(t, db.Db.Table((&models.CommitSig{}).TableName()).
+		Where("validator_address = ? AND height = ?", "mevalcons0", 5).
+		Take(&stored).Error)
+	require.Equal(t, int64(10), stored.VotingPower)
+	require.True(t, timestamp.Equal(stored.Timestamp))
+}
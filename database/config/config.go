@@ -24,19 +24,52 @@ type DatabaseType string
 const (
 	PostgreSQL DatabaseType = "postgres"
 	MySQL      DatabaseType = "mysql"
+	SQLite     DatabaseType = "sqlite"
 )
 
 type Config struct {
 	Type               DatabaseType `yaml:"type"`
 	DSN                string       `yaml:"dsn"`
 	Secrets            *Params
-	SlowThreshold      Duration
-	MaxOpenConnections int `yaml:"max_open_connections"`
-	MaxIdleConnections int `yaml:"max_idle_connections"`
-	ConnMaxIdleTime    Duration
-	ConnMaxLifetime    Duration
-	PartitionSize      int64 `yaml:"partition_size"`
-	PartitionBatchSize int64 `yaml:"partition_batch"`
+	SlowThreshold      Duration `yaml:"slow_threshold"`
+	TraceSQL           bool     `yaml:"trace_sql"`
+	MaxOpenConnections int      `yaml:"max_open_connections"`
+	MaxIdleConnections int      `yaml:"max_idle_connections"`
+	ConnMaxIdleTime    Duration `yaml:"conn_max_idle_time"`
+	ConnMaxLifetime    Duration `yaml:"conn_max_lifetime"`
+	QueryTimeout       Duration `yaml:"query_timeout"`
+	PartitionSize      int64    `yaml:"partition_size"`
+	PartitionBatchSize int64    `yaml:"partition_batch"`
+	MaxPageSize        int      `yaml:"max_page_size"`
+	MaxMissingHeights  int      `yaml:"max_missing_heights"`
+	MaxRetries         int      `yaml:"max_retries"`
+	TxBatchSize        int      `yaml:"tx_batch_size"`
+	UpsertBatchSize    int      `yaml:"upsert_batch_size"`
+	ReadReplicas       []string `yaml:"read_replicas"`
+
+	// DisablePreparedStatements turns off the caching of prepared statements, which some proxies
+	// like ProxySQL don't handle well
+	DisablePreparedStatements bool `yaml:"disable_prepared_statements"`
+
+	// EnablePartitioning makes new partitions of the txs table get created every PartitionSize heights
+	EnablePartitioning bool `yaml:"enable_partitioning"`
+
+	// EnableMetrics makes every statement get recorded by the Prometheus collectors of the log package
+	EnableMetrics bool `yaml:"enable_metrics"`
+
+	// ArchiveEvents makes every raw event emitted by the chain get stored, so that it can be replayed later on
+	ArchiveEvents bool `yaml:"archive_events"`
+
+	// BlockResultCompressionThreshold is the size in bytes above which the block results get compressed.
+	// The default one is used when zero, while a negative value disables the compression.
+	BlockResultCompressionThreshold int `yaml:"block_result_compression_threshold"`
+
+	// ReadOnly makes every write fail with database.ErrReadOnly, for the instances serving queries out of a replica
+	ReadOnly bool `yaml:"read_only"`
+
+	// TxCompressionThreshold is the size in bytes above which the messages and the logs of a tx get compressed.
+	// The default one is used when zero, while a negative value disables the compression.
+	TxCompressionThreshold int `yaml:"tx_compression_threshold"`
 }
 
 func (c *Config) getURL() *url.URL {
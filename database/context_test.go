@@ -0,0 +1,54 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/forbole/juno/v4/common"
+	"github.com/forbole/juno/v4/models"
+)
+
+func TestExpiredContext(t *testing.T) {
+	db := newTestImpl(t, &models.Block{}, &models.Epoch{}, &models.Tx{}, &models.Message{})
+	saveTestBlocks(t, db, 1, 2, 3)
+
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	start := time.Now()
+
+	_, err := db.HasBlock(ctx, 1)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	_, err = db.GetLastBlockHeight(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	err = db.SaveBlock(ctx, &models.Block{
+		BlockID: models.BlockID{Hash: common.HexToHash("0x04")},
+		Header:  models.Header{Height: 4},
+	})
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	err = db.SaveTx(ctx, 0, 0, newTestTx(t, "0x01", 1))
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// GetTotalBlocks swallows errors, so the best we can check is that nothing got counted
+	require.Zero(t, db.GetTotalBlocks(ctx))
+
+	err = db.SaveEpoch(ctx, &models.Epoch{OneRowId: true, BlockHeight: 1})
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	_, err = db.GetEpoch(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	require.Less(t, time.Since(start), time.Second)
+
+	// Nothing must have been written by the calls above
+	height, err := db.GetLastBlockHeight(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), height)
+	require.Equal(t, int64(3), db.GetTotalBlocks(context.Background()))
+}
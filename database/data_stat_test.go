@@ -0,0 +1,101 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm/schema"
+
+	"github.com/forbole/juno/v4/common"
+	"github.com/forbole/juno/v4/models"
+)
+
+// legacyDataStat is the data_stat table as it was before the counts became numeric
+type legacyDataStat struct {
+	OneRowId         bool   `gorm:"one_row_id;not null;default:true;primaryKey"`
+	BlockHeight      int64  `gorm:"column:block_height;type:bigint(64)"`
+	ObjectTotalCount string `gorm:"column:object_total_count;type:VARCHAR(2048)"`
+	ObjectSealCount  string `gorm:"column:object_seal_count;type:VARCHAR(2048)"`
+	ObjectDelCount   string `gorm:"column:object_del_count;type:VARCHAR(2048)"`
+	UpdateTime       int64  `gorm:"update_time;type:bigint(64)"`
+}
+
+func (*legacyDataStat) TableName() string {
+	return (&models.DataStat{}).TableName()
+}
+
+func TestCountObjects(t *testing.T) {
+	ctx := context.Background()
+	db := newTestImpl(t, &models.Object{})
+
+	total, sealed, removed, err := db.CountObjects(ctx)
+	require.NoError(t, err)
+	require.Zero(t, total)
+	require.Zero(t, sealed)
+	require.Zero(t, removed)
+
+	for i, object := range []*models.Object{
+		{Status: "OBJECT_STATUS_SEALED"},
+		{Status: "OBJECT_STATUS_SEALED"},
+		{Status: "OBJECT_STATUS_CREATED"},
+		// A removed object is not counted as sealed anymore
+		{Status: "OBJECT_STATUS_SEALED", Removed: true},
+		{Status: "OBJECT_STATUS_CREATED", Removed: true},
+	} {
+		object.ObjectID = common.HexToHash(fmt.Sprintf("0x%02x", i+1))
+		require.NoError(t, db.SaveObject(ctx, object))
+	}
+
+	total, sealed, removed, err = db.CountObjects(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(5), total)
+	require.Equal(t, int64(2), sealed)
+	require.Equal(t, int64(2), removed)
+}
+
+func TestSaveDBStatistics(t *testing.T) {
+	ctx := context.Background()
+	db := newTestImpl(t, &models.DataStat{})
+
+	stat, err := db.GetDataStat(ctx)
+	require.NoError(t, err)
+	require.Zero(t, *stat)
+
+	require.NoError(t, db.SaveDBStatistics(ctx, &models.DataStat{
+		BlockHeight: 10, ObjectTotalCount: 5, ObjectSealCount: 3, ObjectDelCount: 1, UpdateTime: 100,
+	}))
+	// Saving again replaces the row instead of adding one, even when a count drops to zero
+	require.NoError(t, db.SaveDBStatistics(ctx, &models.DataStat{
+		BlockHeight: 20, ObjectTotalCount: 6, ObjectSealCount: 4, UpdateTime: 200,
+	}))
+
+	var count int64
+	require.NoError(t, db.Db.Table((&models.DataStat{}).TableName()).Count(&count).Error)
+	require.Equal(t, int64(1), count)
+
+	stat, err = db.GetDataStat(ctx)
+	require.NoError(t, err)
+	require.Equal(t, models.DataStat{
+		OneRowId: true, BlockHeight: 20, ObjectTotalCount: 6, ObjectSealCount: 4, UpdateTime: 200,
+	}, *stat)
+}
+
+func TestMigrateDataStat(t *testing.T) {
+	skipUnlessSQLite(t)
+
+	ctx := context.Background()
+	db := newTestImpl(t, &legacyDataStat{})
+	require.NoError(t, db.Db.Create(&legacyDataStat{
+		OneRowId: true, BlockHeight: 10, ObjectTotalCount: "5", ObjectSealCount: "3", ObjectDelCount: "1",
+	}).Error)
+
+	require.NoError(t, db.AutoMigrate(ctx, []schema.Tabler{&models.DataStat{}}))
+
+	stat, err := db.GetDataStat(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(5), stat.ObjectTotalCount)
+	require.Equal(t, int64(3), stat.ObjectSealCount)
+	require.Equal(t, int64(1), stat.ObjectDelCount)
+}
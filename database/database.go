@@ -1,20 +1,31 @@
 package database
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
 	"database/sql"
 	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"math/big"
 	"strings"
+	"time"
 
 	"cosmossdk.io/simapp/params"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	paymenttypes "github.com/evmos/evmos/v12/x/payment/types"
+	storagetypes "github.com/evmos/evmos/v12/x/storage/types"
+	"github.com/klauspost/compress/zstd"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 	"gorm.io/gorm/schema"
 
 	"github.com/forbole/juno/v4/common"
 	databaseconfig "github.com/forbole/juno/v4/database/config"
+	"github.com/forbole/juno/v4/database/sqlclient"
 	"github.com/forbole/juno/v4/log"
 	"github.com/forbole/juno/v4/models"
 	"github.com/forbole/juno/v4/types"
@@ -28,16 +39,33 @@ type Database interface {
 	// AutoMigrate Automatically migrate your schema, to keep your schema up to date.
 	AutoMigrate(ctx context.Context, tables []schema.Tabler) error
 
+	// CheckChainID makes sure the database can be shared with other chains. It returns ErrChainIDNotBackfilled
+	// when blocks, txs, epoch, buckets or objects got stored before their rows recorded the chain they belong to.
+	CheckChainID(ctx context.Context) error
+
+	// BackfillChainID adds the chain id column to the tables of the blocks, txs, epoch, buckets and objects,
+	// assigns the rows stored before it existed to the chain of this database and replaces the unique indexes
+	// those rows were stored with by the ones including the chain id.
+	// It returns the number of rows assigned to the chain, and must not run while blocks are being parsed.
+	BackfillChainID(ctx context.Context) (int64, error)
+
 	// HasBlock tells whether the database has already stored the block having the given height.
 	// An error is returned if the operation fails.
 	HasBlock(ctx context.Context, height uint64) (bool, error)
 
+	// GetBlockByHeight returns the block having the given height.
+	// ErrBlockNotFound is returned if it has not been stored.
+	GetBlockByHeight(ctx context.Context, height uint64) (*models.Block, error)
+
 	// GetLastBlockHeight returns the last block height stored in database..
 	// An error is returned if the operation fails.
 	GetLastBlockHeight(ctx context.Context) (uint64, error)
 
-	// GetMissingHeights returns a slice of missing block heights between startHeight and endHeight
-	GetMissingHeights(ctx context.Context, startHeight, endHeight uint64) []uint64
+	// GetMissingHeights returns the missing block heights between startHeight and endHeight, in ascending order.
+	// At most the configured max number of missing heights is returned, so callers should continue
+	// from the last returned height until no more heights are found.
+	// An error is returned if the operation fails.
+	GetMissingHeights(ctx context.Context, startHeight, endHeight uint64) ([]uint64, error)
 
 	// SaveBlock will be called when a new block is parsed, passing the block itself
 	// and the transactions contained inside that block.
@@ -45,107 +73,640 @@ type Database interface {
 	// NOTE. For each transaction inside txs, SaveTx will be called as well.
 	SaveBlock(ctx context.Context, block *models.Block) error
 
+	// GetBlockNearTime returns the last block whose timestamp is not after the given time.
+	// ErrBlockNotFound is returned if the time is before the first stored block.
+	GetBlockNearTime(ctx context.Context, t time.Time) (*models.Block, error)
+
+	// ListBlocksByTimeRange returns a page of the blocks whose timestamp is included between from and to,
+	// both inclusive, ordered by height.
+	// An error is returned if the operation fails.
+	ListBlocksByTimeRange(ctx context.Context, from, to time.Time, limit, offset int) ([]*models.Block, error)
+
 	// GetTotalBlocks returns total number of blocks stored in database.
 	GetTotalBlocks(ctx context.Context) int64
 
+	// SaveBlockResult stores the results of the block at the given height, as returned by the node.
+	// Results larger than the configured threshold are compressed.
+	// An error is returned if the operation fails.
+	SaveBlockResult(ctx context.Context, height uint64, result []byte) error
+
+	// GetBlockResult returns the results of the block at the given height, as they were given to SaveBlockResult.
+	// ErrBlockResultNotFound is returned if they have not been stored.
+	GetBlockResult(ctx context.Context, height uint64) ([]byte, error)
+
 	// SaveTx will be called to save each transaction contained inside a block.
 	// An error is returned if the operation fails.
 	SaveTx(ctx context.Context, blockTimestamp uint64, index int, tx *types.Tx) error
 
+	// SaveTxs saves all the transactions contained inside a block at once, their position inside txs
+	// being their index inside the block. It is much faster than calling SaveTx for each of them.
+	// An error is returned if the operation fails, in which case none of them is saved.
+	SaveTxs(ctx context.Context, blockTimestamp uint64, txs []*types.Tx) error
+
+	// SaveTxsFrom behaves like SaveTxs, for txs found inside their block from the index first on, so that the
+	// transactions of a block can be saved a few at a time.
+	// An error is returned if the operation fails, in which case none of them is saved.
+	SaveTxsFrom(ctx context.Context, blockTimestamp uint64, first int, txs []*types.Tx) error
+
+	// GetTxByHash returns the transaction having the given hash.
+	// ErrTxNotFound is returned if no such transaction has been stored.
+	GetTxByHash(ctx context.Context, hash common.Hash) (*models.Tx, error)
+
+	// GetTxsByHeight returns a page of the transactions contained inside the block having the given height,
+	// ordered by their index, together with the total number of transactions of that block.
+	// The limit is capped to the configured max page size.
+	GetTxsByHeight(ctx context.Context, height uint64, limit, offset int) ([]*models.Tx, int64, error)
+
+	// ListTxsByMessageType returns a page of the transactions, included between fromHeight and toHeight,
+	// that contain at least one message having exactly the given proto type URL.
+	// The transactions are ordered by height and index.
+	// An error is returned if the operation fails.
+	ListTxsByMessageType(ctx context.Context, typeURL string, fromHeight, toHeight uint64, limit, offset int) ([]*models.Tx, error)
+
+	// BackfillMessages stores the message rows of the transactions, included between fromHeight and toHeight,
+	// that were saved before the messages table existed, returning the number of rows written.
+	// An error is returned if the operation fails.
+	BackfillMessages(ctx context.Context, fromHeight, toHeight uint64) (int64, error)
+
+	// SaveEvents archives the events emitted by the tx having the given hash, included at txIndex in the block at the
+	// given height.
+	// Nothing is stored unless archiving the events is enabled, and the events archived by a previous call are replaced.
+	// An error is returned if the operation fails.
+	SaveEvents(ctx context.Context, height uint64, txIndex uint32, txHash common.Hash, events []sdk.Event) error
+
+	// SaveBlockEvents behaves like SaveEvents, archiving the events emitted by the begin or the end of a block.
+	SaveBlockEvents(ctx context.Context, height uint64, origin models.EventOrigin, events []sdk.Event) error
+
+	// ListEventsByHeight returns the events archived for the block at the given height, in the order of the block:
+	// the ones of its begin, then the ones of its txs, then the ones of its end.
+	// An error is returned if the operation fails.
+	ListEventsByHeight(ctx context.Context, height uint64) ([]*models.Event, error)
+
 	// SaveCommitSignatures stores a  slice of validator commit signatures.
 	// An error is returned if the operation fails.
 	SaveCommitSignatures(ctx context.Context, signatures []*types.CommitSig) error
 
+	// SaveValidators stores the given validators. The ones already stored are left untouched,
+	// so that their first seen height is the one of the first call.
+	// An error is returned if the operation fails.
+	SaveValidators(ctx context.Context, validators []*models.Validator) error
+
+	// GetValidatorByConsAddr returns the validator having the given consensus address, either bech32 or hex encoded.
+	// ErrValidatorNotFound is returned if no such validator has been stored.
+	GetValidatorByConsAddr(ctx context.Context, addr string) (*models.Validator, error)
+
 	// SaveBucket will be called to save each bucket contained inside a block.
 	// An error is returned if the operation fails.
 	SaveBucket(ctx context.Context, bucket *models.Bucket) error
 
+	// MultiSaveBuckets saves the given buckets as SaveBucket does, writing many of them with a single statement.
+	// Of the buckets sharing an id, the last one is saved, as if they had been saved one after the other.
+	// An error is returned if the operation fails.
+	MultiSaveBuckets(ctx context.Context, buckets []*models.Bucket) error
+
 	// UpdateBucket will be called to save each bucket contained inside a block.
+	// When columns are given, exactly those columns are written, even if the bucket holds their zero value;
+	// otherwise only the non-zero fields of the bucket are written.
+	// An error is returned if the operation fails.
+	UpdateBucket(ctx context.Context, bucket *models.Bucket, columns ...string) error
+
+	// UpdateBucketAt behaves like UpdateBucket, but leaves the bucket untouched if it has been updated at a height
+	// greater than the given one, so that an older event handled late can't overwrite a newer state.
+	// The number of updated rows is returned, zero telling that the update was stale or that the bucket is missing;
+	// on MySQL rows that the update leaves unchanged are not counted either.
+	// An error is returned if the operation fails.
+	UpdateBucketAt(ctx context.Context, height int64, bucket *models.Bucket, columns ...string) (int64, error)
+
+	// SaveBucketQuotaHistory records the given change of the charged read quota or of the payment address of a
+	// bucket, made at its height. Its old values are replaced by the new ones of the change recorded before it, or by
+	// the old ones of the change recorded after it, whose old values become the new ones of the given change: the
+	// changes handled out of order are recorded as they were made. Nothing is recorded when the change leaves the
+	// values untouched, or when a change is recorded at the same height already.
 	// An error is returned if the operation fails.
-	UpdateBucket(ctx context.Context, bucket *models.Bucket) error
+	SaveBucketQuotaHistory(ctx context.Context, history *models.BucketQuotaHistory) error
+
+	// ListBucketQuotaHistory returns a page of the changes of the charged read quota and of the payment address of
+	// the given bucket, most recent first.
+	// An error is returned if the operation fails.
+	ListBucketQuotaHistory(ctx context.Context, bucketID common.Hash, limit, offset int) ([]*models.BucketQuotaHistory, error)
+
+	// SaveBucketDeletionIssue records the given bucket deleted while objects not removed were still stored inside
+	// it, unless recorded already.
+	// An error is returned if the operation fails.
+	SaveBucketDeletionIssue(ctx context.Context, issue *models.BucketDeletionIssue) error
+
+	// GetBucketByName returns the bucket having the given name, ignoring removed buckets.
+	// ErrBucketNotFound is returned if no such bucket exists.
+	GetBucketByName(ctx context.Context, name string) (*models.Bucket, error)
+
+	// GetBucketByID returns the bucket having the given id, ignoring removed buckets.
+	// ErrBucketNotFound is returned if no such bucket exists.
+	GetBucketByID(ctx context.Context, id common.Hash) (*models.Bucket, error)
+
+	// GetBucketByNameWithRemoved behaves like GetBucketByName, but returns removed buckets as well.
+	GetBucketByNameWithRemoved(ctx context.Context, name string) (*models.Bucket, error)
+
+	// GetBucketByIDWithRemoved behaves like GetBucketByID, but returns removed buckets as well.
+	GetBucketByIDWithRemoved(ctx context.Context, id common.Hash) (*models.Bucket, error)
 
 	// SaveObject will be called to save each object contained inside a block.
 	// An error is returned if the operation fails.
 	SaveObject(ctx context.Context, object *models.Object) error
 
+	// CreateObject saves the given object if no object with its id exists yet, and tells whether it has been saved.
+	// An error is returned if the operation fails.
+	CreateObject(ctx context.Context, object *models.Object) (bool, error)
+
+	// MultiSaveObjects saves the given objects as SaveObject does, writing many of them with a single statement.
+	// Of the objects sharing an id, the last one is saved, as if they had been saved one after the other.
+	// An error is returned if the operation fails.
+	MultiSaveObjects(ctx context.Context, objects []*models.Object) error
+
 	// UpdateObject will be called to update each object contained inside a block.
+	// The columns are handled as in UpdateBucket.
+	// An error is returned if the operation fails.
+	UpdateObject(ctx context.Context, object *models.Object, columns ...string) error
+
+	// UpdateObjectAt behaves like UpdateObject, skipping stale updates as UpdateBucketAt does.
+	UpdateObjectAt(ctx context.Context, height int64, object *models.Object, columns ...string) (int64, error)
+
+	// AddBucketStats adds the given number of objects and payload size, negative to subtract them, to the stats of
+	// the given bucket.
+	// An error is returned if the operation fails.
+	AddBucketStats(ctx context.Context, bucketID common.Hash, objects, size int64) error
+
+	// GetBucketStats returns the stats of the given bucket, zero if none of its objects has been counted.
+	// An error is returned if the operation fails.
+	GetBucketStats(ctx context.Context, bucketID common.Hash) (*models.BucketStats, error)
+
+	// ResetBucketStats zeroes the stats of the given bucket, marking all of its objects as not counted.
+	// An error is returned if the operation fails.
+	ResetBucketStats(ctx context.Context, bucketID common.Hash) error
+
+	// RecalculateBucketStats recounts the stats of the given bucket out of its sealed and discontinued objects not
+	// removed, marking those objects as counted and the others as not, and returns them. It repairs the stats after
+	// a rollback, which leaves them untouched.
 	// An error is returned if the operation fails.
-	UpdateObject(ctx context.Context, object *models.Object) error
+	RecalculateBucketStats(ctx context.Context, bucketID common.Hash) (*models.BucketStats, error)
+
+	// BackfillBucketStats recalculates the stats of every bucket, as RecalculateBucketStats does, so that the
+	// buckets stored before their stats were tracked report them. It returns the number of buckets recalculated,
+	// and must not run while blocks are being parsed.
+	BackfillBucketStats(ctx context.Context) (int64, error)
 
-	// GetObject returns an object model with given objectId.
-	// It should return only one record
+	// GetObject returns the object, not removed, having the given objectId.
+	// ErrObjectNotFound is returned if no such object exists.
 	GetObject(ctx context.Context, objectId common.Hash) (*models.Object, error)
 
+	// GetObjectWithRemoved behaves like GetObject, but returns removed objects as well.
+	GetObjectWithRemoved(ctx context.Context, objectId common.Hash) (*models.Object, error)
+
+	// GetObjectChecksums returns the checksums, one per redundancy piece in piece order, of the object, not removed,
+	// having the given id, decoded back to raw bytes. They are stored as a Postgres bytea array literal of their hex
+	// encodings, like {"\\x0102","\\x0304"}, whatever the database.
+	// ErrObjectNotFound is returned if no such object exists.
+	GetObjectChecksums(ctx context.Context, objectId common.Hash) ([][]byte, error)
+
+	// GetObjectByBucketAndName returns the object, not removed, having the given name inside the given bucket.
+	// ErrObjectNotFound is returned if no such object exists.
+	GetObjectByBucketAndName(ctx context.Context, bucketName, objectName string) (*models.Object, error)
+
+	// ListObjectsByBucket returns a page of the objects stored inside the bucket having the given name,
+	// ordered by object name.
+	// An error is returned if the operation fails.
+	ListObjectsByBucket(ctx context.Context, bucketName string, opts ObjectListOptions) ([]*models.Object, error)
+
+	// CountActiveObjectsByBucket returns the number of the objects, not removed, stored inside the given bucket.
+	// An error is returned if the operation fails.
+	CountActiveObjectsByBucket(ctx context.Context, bucketID common.Hash) (int64, error)
+
+	// ListObjectsToGCBefore returns at most limit discontinued objects, not removed yet, whose deletion is due at
+	// the given unix time, earliest deletion first.
+	// An error is returned if the operation fails.
+	ListObjectsToGCBefore(ctx context.Context, unixTime int64, limit int) ([]*models.Object, error)
+
+	// ListObjectCopies returns a page of the objects, not removed, copied from the given object, which may have been
+	// removed, in copy order. The copies of these copies are not returned.
+	// An error is returned if the operation fails.
+	ListObjectCopies(ctx context.Context, sourceObjectID common.Hash, limit, offset int) ([]*models.Object, error)
+
+	// ListObjectsByCreator returns a page of the objects, not removed, created by the given address
+	// across all buckets, ordered by object id.
+	// Only the objects whose id sorts after startAfterObjectID are returned, so the zero hash starts from the first one.
+	// An error is returned if the operation fails.
+	ListObjectsByCreator(ctx context.Context, creator common.Address, limit int, startAfterObjectID common.Hash) ([]*models.Object, error)
+
 	SaveEpoch(ctx context.Context, epoch *models.Epoch) error
 
+	// GetEpoch returns the stored epoch, or the zero epoch if none has been stored yet.
 	GetEpoch(ctx context.Context) (*models.Epoch, error)
 
 	// SavePaymentAccount will be called to save PaymentAccount.
 	// An error is returned if the operation fails.
 	SavePaymentAccount(ctx context.Context, paymentAccount *models.PaymentAccount) error
 
+	// CountPaymentAccountsByOwner returns the number of payment accounts owned by the given owner.
+	// An error is returned if the operation fails.
+	CountPaymentAccountsByOwner(ctx context.Context, owner common.Address) (uint64, error)
+
+	// SavePaymentAccountRefundable records the refundable flag of a payment account updated at a height, doing
+	// nothing if it is recorded already.
+	// An error is returned if the operation fails.
+	SavePaymentAccountRefundable(ctx context.Context, refundable *models.PaymentAccountRefundable) error
+
+	// ListPaymentAccountRefundable returns the refundable flags the given payment account has been created with and
+	// flipped to, oldest first, the updates leaving the flag unchanged being skipped.
+	// An error is returned if the operation fails.
+	ListPaymentAccountRefundable(ctx context.Context, addr common.Address) ([]*models.PaymentAccountRefundable, error)
+
+	// ListPaymentAccountsByOwner returns a page of the payment accounts owned by the given address,
+	// most recently updated first, together with the total number of accounts it owns.
+	// An error is returned if the operation fails.
+	ListPaymentAccountsByOwner(ctx context.Context, owner common.Address, limit, offset int) ([]*models.PaymentAccount, int64, error)
+
+	// GetPaymentAccountByAddr returns the payment account having the given address.
+	// ErrPaymentAccountNotFound is returned if no such account exists.
+	GetPaymentAccountByAddr(ctx context.Context, addr common.Address) (*models.PaymentAccount, error)
+
+	// SavePaymentLedger records the given deposit or withdrawal, doing nothing if it is recorded already.
+	// An error is returned if the operation fails.
+	SavePaymentLedger(ctx context.Context, entry *models.PaymentLedger) error
+
+	// ListPaymentLedger returns a page of the deposits and withdrawals of the given stream account between the given
+	// heights, both included, oldest first, together with their total number.
+	// An error is returned if the operation fails.
+	ListPaymentLedger(ctx context.Context, account common.Address, fromHeight, toHeight int64, limit, offset int) ([]*models.PaymentLedger, int64, error)
+
 	// SaveStreamRecord will be called to save SaveStreamRecord.
 	// An error is returned if the operation fails.
 	SaveStreamRecord(ctx context.Context, streamRecord *models.StreamRecord) error
 
+	// RepairStreamRecord overwrites the stream record of the account of the given one with it, unless the stored one
+	// has been updated after it, according to their crud timestamps.
+	// An error is returned if the operation fails.
+	RepairStreamRecord(ctx context.Context, streamRecord *models.StreamRecord) error
+
+	// ListStreamRecords returns at most limit stream records whose id is above the given one, ordered by id.
+	// An error is returned if the operation fails.
+	ListStreamRecords(ctx context.Context, afterID uint64, limit int) ([]*models.StreamRecord, error)
+
+	// SaveReconciliationIssues records the given divergences of the stream records from the chain.
+	// An error is returned if the operation fails.
+	SaveReconciliationIssues(ctx context.Context, issues []*models.ReconciliationIssue) error
+
+	// ListReconciliationIssues returns at most limit divergences of the stream record of the given account from the
+	// chain, most recent first.
+	// An error is returned if the operation fails.
+	ListReconciliationIssues(ctx context.Context, account common.Address, limit int) ([]*models.ReconciliationIssue, error)
+
+	// SaveStreamRecordHistory appends the given update of a stream record to the stream record history, unless it was
+	// recorded already when the block is handled again.
+	// An error is returned if the operation fails.
+	SaveStreamRecordHistory(ctx context.Context, history *models.StreamRecordHistory) error
+
+	// ListStreamRecordHistory returns at most limit updates of the stream record of the given account whose crud
+	// timestamp is between the given unix times, both included, ordered by crud timestamp.
+	// An error is returned if the operation fails.
+	ListStreamRecordHistory(ctx context.Context, account common.Address, fromTime, toTime int64, limit int) ([]*models.StreamRecordHistory, error)
+
+	// DeleteStreamRecordHistoryBefore deletes the updates of the stream records whose crud timestamp is before the
+	// given unix time, and returns the number of updates deleted.
+	// An error is returned if the operation fails.
+	DeleteStreamRecordHistoryBefore(ctx context.Context, unixTime int64) (int64, error)
+
+	// ReplaceOutFlows replaces the out flows of the given account by the given ones, read at the given height,
+	// clearing them when none is given. The out flows read at a later height are kept, even when that read found
+	// none, and false is returned.
+	// An error is returned if the operation fails.
+	ReplaceOutFlows(ctx context.Context, account common.Address, height int64, outFlows []*models.StreamRecordOutFlow) (bool, error)
+
+	// ListOutFlowsByAccount returns the out flows of the given account, ordered by receiver.
+	// An error is returned if the operation fails.
+	ListOutFlowsByAccount(ctx context.Context, account common.Address) ([]*models.StreamRecordOutFlow, error)
+
+	// SumInflowBySP returns the sum of the rates of the active out flows of every account to the given storage
+	// provider.
+	// An error is returned if the operation fails.
+	SumInflowBySP(ctx context.Context, spAddress common.Address) (*big.Int, error)
+
 	// SavePermission will be called to save each policy contained inside a event.
 	// An error is returned if the operation fails.
 	SavePermission(ctx context.Context, permission *models.Permission) error
 
 	// UpdatePermission will be called to update each policy
+	// The columns are handled as in UpdateBucket.
 	// An error is returned if the operation fails.
-	UpdatePermission(ctx context.Context, permission *models.Permission) error
+	UpdatePermission(ctx context.Context, permission *models.Permission, columns ...string) error
+
+	// GetPermissionsByResource returns all the policies, not removed, attached to the given resource
+	// ordered by creation time.
+	// An error is returned if the operation fails.
+	GetPermissionsByResource(ctx context.Context, resourceType string, resourceID common.Hash) ([]*models.Permission, error)
+
+	// GetPermissionByPolicyID returns the policy, not removed, having the given id.
+	// ErrPermissionNotFound is returned if no such policy exists.
+	GetPermissionByPolicyID(ctx context.Context, policyID common.Hash) (*models.Permission, error)
+
+	// ExpirePermissionsBefore marks as removed at the given height at most limit policies, not removed nor
+	// updated at a greater height, whose expiration time is before the given unix time, the one of the block at
+	// that height. The policies without an expiration time never expire.
+	// It returns the number of policies marked, or an error if the operation fails.
+	ExpirePermissionsBefore(ctx context.Context, height, unixTime int64, limit int) (int64, error)
 
 	// CreateGroup will be called to save each group contained inside an event.
 	// An error is returned if the operation fails.
 	CreateGroup(ctx context.Context, groupMembers []*models.Group) error
 
 	// UpdateGroup will be called to update each group
+	// The columns are handled as in UpdateBucket.
 	// An error is returned if the operation fails.
-	UpdateGroup(ctx context.Context, group *models.Group) error
+	UpdateGroup(ctx context.Context, group *models.Group, columns ...string) error
 
-	// DeleteGroup will be called to delete each group
+	// UpdateGroupAt behaves like UpdateGroup, skipping stale updates as UpdateBucketAt does.
+	UpdateGroupAt(ctx context.Context, height int64, group *models.Group, columns ...string) (int64, error)
+
+	// DeleteGroup marks the given group, together with all of its members, as removed at the given height.
+	// An error is returned if the operation fails.
+	DeleteGroup(ctx context.Context, groupID common.Hash, updateAt, updateTime int64) error
+
+	// DeleteGroupMember marks the given member of the given group as removed at the given height.
+	// An error is returned if the operation fails.
+	DeleteGroupMember(ctx context.Context, groupID common.Hash, accountID common.Address, updateAt, updateTime int64) error
+
+	// GetGroupMembers returns a page of the members, not removed, of the given group ordered by account id.
+	// Only the members whose account sorts after startAfterAccount are returned, so the zero address
+	// starts from the first member.
+	// An error is returned if the operation fails.
+	GetGroupMembers(ctx context.Context, groupID common.Hash, limit int, startAfterAccount common.Address) ([]*models.Group, error)
+
+	// ListGroupsByAccount returns the groups, not removed, the given account is a member of,
+	// together with the group rows of the groups it owns.
 	// An error is returned if the operation fails.
-	DeleteGroup(ctx context.Context, group *models.Group) error
+	ListGroupsByAccount(ctx context.Context, account common.Address) ([]*models.Group, error)
 
 	// CreateStorageProvider will be called to save each sp contained inside an event.
 	// An error is returned if the operation fails.
 	CreateStorageProvider(ctx context.Context, storageProvider *models.StorageProvider) error
 
 	// UpdateStorageProvider will be called to update each sp
+	// The columns are handled as in UpdateBucket.
+	// An error is returned if the operation fails.
+	UpdateStorageProvider(ctx context.Context, storageProvider *models.StorageProvider, columns ...string) error
+
+	// UpdateStorageProviderAt behaves like UpdateStorageProvider, skipping stale updates as UpdateBucketAt does.
+	UpdateStorageProviderAt(ctx context.Context, height int64, storageProvider *models.StorageProvider, columns ...string) (int64, error)
+
+	// GetStorageProviderByID returns the storage provider having the given id.
+	// ErrStorageProviderNotFound is returned if no such sp exists.
+	GetStorageProviderByID(ctx context.Context, spID uint32) (*models.StorageProvider, error)
+
+	// GetStorageProviderByOperatorAddress returns the storage provider operated by the given hex address,
+	// regardless of its case.
+	// ErrStorageProviderNotFound is returned if no such sp exists.
+	GetStorageProviderByOperatorAddress(ctx context.Context, operatorAddress string) (*models.StorageProvider, error)
+
+	// ListStorageProviders returns all the storage providers ordered by id.
+	// Removed storage providers are returned only when includeRemoved is true.
 	// An error is returned if the operation fails.
-	UpdateStorageProvider(ctx context.Context, storageProvider *models.StorageProvider) error
+	ListStorageProviders(ctx context.Context, includeRemoved bool) ([]*models.StorageProvider, error)
 
 	// MultiSaveStatement will be called to save each statement contained inside a policy.
 	// An error is returned if the operation fails.
 	MultiSaveStatement(ctx context.Context, statements []*models.Statements) error
 
-	RemoveStatements(ctx context.Context, policyID common.Hash) error
+	// RemoveStatements marks as removed all the statements of the given policy, deleted at the given height by
+	// the given tx.
+	// An error is returned if the operation fails.
+	RemoveStatements(ctx context.Context, policyID common.Hash, updateAt int64, updateTxHash common.Hash) error
+
+	// DeleteStatements permanently deletes all the statements of the given policy, so that they can be saved again.
+	// An error is returned if the operation fails.
+	DeleteStatements(ctx context.Context, policyID common.Hash) error
+
+	// GetStatementsByPolicyID returns the statements of the given policy in insertion order.
+	// Removed statements are returned only when includeRemoved is true.
+	// An error is returned if the operation fails.
+	GetStatementsByPolicyID(ctx context.Context, policyID common.Hash, includeRemoved bool) ([]*models.Statements, error)
+
+	// ExpireStatementsBefore marks as removed at most limit statements as in ExpirePermissionsBefore.
+	// It returns the number of statements marked, or an error if the operation fails.
+	ExpireStatementsBefore(ctx context.Context, height, unixTime int64, limit int) (int64, error)
+
+	// ConsumeStatementQuota adds the given size to the size consumed out of the limit of the given statement.
+	// An error is returned if the operation fails.
+	ConsumeStatementQuota(ctx context.Context, statementID uint64, size uint64) error
+
+	// ReleaseStatementQuota subtracts the given size from the size consumed out of the limit of the given
+	// statement, down to zero.
+	// An error is returned if the operation fails.
+	ReleaseStatementQuota(ctx context.Context, statementID uint64, size uint64) error
+
+	// MoveStatementQuota moves the objects whose size has been consumed out of the limit of the statement fromID
+	// to the statement toID, whose consumed size becomes the given one. With a toID of 0 the objects are left
+	// consuming no limit.
+	// An error is returned if the operation fails.
+	MoveStatementQuota(ctx context.Context, fromID, toID uint64, consumedSize uint64) error
+
+	// GetStatementQuotaUsage returns the statements, not removed, of the given policy having a size limit, along
+	// with the size consumed out of it, in insertion order.
+	// An error is returned if the operation fails.
+	GetStatementQuotaUsage(ctx context.Context, policyID common.Hash) ([]*models.Statements, error)
+
+	// SavePermissionHistory appends the given policy put or deleted to the permission history, unless the same
+	// operation of the same tx on the policy is there already.
+	// An error is returned if the operation fails.
+	SavePermissionHistory(ctx context.Context, history *models.PermissionHistory) error
+
+	// ListPermissionHistory returns the policies put or deleted on the given resource between the given
+	// heights, both included, ordered by height.
+	// An error is returned if the operation fails.
+	ListPermissionHistory(ctx context.Context, resourceID common.Hash, fromHeight, toHeight uint64) ([]*models.PermissionHistory, error)
+
+	// ListPermissionsByPrincipal returns all the policies, not removed, put on the given principal, ordered by id.
+	// An error is returned if the operation fails.
+	ListPermissionsByPrincipal(ctx context.Context, principalType int32, principalValue string) ([]*models.Permission, error)
+
+	// SaveEffectivePermissions saves the given materialized policies, replacing the ones of the same policy for
+	// the same account.
+	// An error is returned if the operation fails.
+	SaveEffectivePermissions(ctx context.Context, permissions []*models.EffectivePermission) error
+
+	// DeleteEffectivePermissions deletes the materialized policies of the given policy.
+	// An error is returned if the operation fails.
+	DeleteEffectivePermissions(ctx context.Context, policyID common.Hash) error
+
+	// DeleteGroupEffectivePermissions deletes the materialized policies of the given group, for all of its
+	// members.
+	// An error is returned if the operation fails.
+	DeleteGroupEffectivePermissions(ctx context.Context, groupID common.Hash) error
+
+	// DeleteMemberEffectivePermissions deletes the materialized policies of the given group for the given member.
+	// An error is returned if the operation fails.
+	DeleteMemberEffectivePermissions(ctx context.Context, groupID common.Hash, account common.Address) error
+
+	// ListEffectivePermissionsByAccount returns the policies applying to the given account on the given resource
+	// through the groups it is a member of, ordered by id.
+	// An error is returned if the operation fails.
+	ListEffectivePermissionsByAccount(ctx context.Context, account common.Address, resourceID common.Hash) ([]*models.EffectivePermission, error)
 
 	SaveGVG(ctx context.Context, gvg *models.GlobalVirtualGroup) error
 
-	UpdateGVG(ctx context.Context, gvg *models.GlobalVirtualGroup) error
+	// UpdateGVG updates the given global virtual group, handling the columns as in UpdateBucket.
+	UpdateGVG(ctx context.Context, gvg *models.GlobalVirtualGroup, columns ...string) error
+
+	// UpdateGVGAt behaves like UpdateGVG, skipping stale updates as UpdateBucketAt does.
+	UpdateGVGAt(ctx context.Context, height int64, gvg *models.GlobalVirtualGroup, columns ...string) (int64, error)
+
+	// AddGVGStoredSizeAt behaves like AddLVGStoredSizeAt for the given global virtual group.
+	AddGVGStoredSizeAt(ctx context.Context, height int64, gvgID uint32, size int64) (int64, error)
+
+	// GetGVGByID returns the global virtual group having the given id.
+	// ErrGVGNotFound is returned if no such group exists.
+	GetGVGByID(ctx context.Context, gvgID uint32) (*models.GlobalVirtualGroup, error)
+
+	// ListGVGsByFamilyID returns the global virtual groups, not removed, of the given family ordered by id.
+	// An error is returned if the operation fails.
+	ListGVGsByFamilyID(ctx context.Context, familyID uint32) ([]*models.GlobalVirtualGroup, error)
+
+	// ListGVGsBySPID returns the global virtual groups, not removed, in which the given sp is either
+	// the primary sp or one of the secondary sps, ordered by id.
+	// An error is returned if the operation fails.
+	ListGVGsBySPID(ctx context.Context, spID uint32) ([]*models.GlobalVirtualGroup, error)
 
 	SaveLVG(ctx context.Context, lvg *models.LocalVirtualGroup) error
 
-	UpdateLVG(ctx context.Context, lvg *models.LocalVirtualGroup) error
+	// UpdateLVG updates the given local virtual group, handling the columns as in UpdateBucket.
+	UpdateLVG(ctx context.Context, lvg *models.LocalVirtualGroup, columns ...string) error
+
+	// UpdateLVGAt behaves like UpdateLVG, skipping stale updates as UpdateBucketAt does.
+	UpdateLVGAt(ctx context.Context, height int64, lvg *models.LocalVirtualGroup, columns ...string) (int64, error)
+
+	// AddLVGStoredSizeAt adds the given size to the stored size of the given local virtual group, and sets its
+	// update_at to the given height, unless it has been updated at that height or a greater one already.
+	// The number of updated rows is returned.
+	// An error is returned if the operation fails.
+	AddLVGStoredSizeAt(ctx context.Context, height int64, bucketID common.Hash, lvgID uint32, size int64) (int64, error)
+
+	// RemoveLVGsByBucket marks as removed all the local virtual groups of the given bucket, deleted at the given
+	// height by the given transaction, leaving untouched the ones updated at a greater height.
+	// An error is returned if the operation fails.
+	RemoveLVGsByBucket(ctx context.Context, bucketID common.Hash, updateAt int64, updateTxHash common.Hash, updateTime int64) error
+
+	// ListLVGsByBucket returns the local virtual groups, not removed, of the given bucket ordered by id.
+	// An error is returned if the operation fails.
+	ListLVGsByBucket(ctx context.Context, bucketID common.Hash) ([]*models.LocalVirtualGroup, error)
+
+	// GetLVG returns the local virtual group having the given id inside the given bucket.
+	// ErrLVGNotFound is returned if no such group exists.
+	GetLVG(ctx context.Context, bucketID common.Hash, lvgID uint32) (*models.LocalVirtualGroup, error)
 
 	SaveVGF(ctx context.Context, vgf *models.GlobalVirtualGroupFamily) error
 
-	UpdateVGF(ctx context.Context, vgf *models.GlobalVirtualGroupFamily) error
+	// UpdateVGF updates the given global virtual group family, handling the columns as in UpdateBucket.
+	UpdateVGF(ctx context.Context, vgf *models.GlobalVirtualGroupFamily, columns ...string) error
+
+	// UpdateVGFAt behaves like UpdateVGF, skipping stale updates as UpdateBucketAt does.
+	UpdateVGFAt(ctx context.Context, height int64, vgf *models.GlobalVirtualGroupFamily, columns ...string) (int64, error)
+
+	// GetVGFByID returns the global virtual group family, not removed, having the given id.
+	// ErrInvalidVGFID is returned for the id 0 and ErrVGFNotFound if no such family exists.
+	GetVGFByID(ctx context.Context, familyID uint32) (*models.GlobalVirtualGroupFamily, error)
+
+	// GetVGFByIDWithRemoved behaves like GetVGFByID, but returns removed families as well.
+	GetVGFByIDWithRemoved(ctx context.Context, familyID uint32) (*models.GlobalVirtualGroupFamily, error)
+
+	// ListVGFsByPrimarySP returns a page of the global virtual group families, not removed,
+	// whose primary sp is the given one, ordered by id.
+	// An error is returned if the operation fails.
+	ListVGFsByPrimarySP(ctx context.Context, spID uint32, limit, offset int) ([]*models.GlobalVirtualGroupFamily, error)
+
+	// ListVGFsByPrimarySPWithRemoved behaves like ListVGFsByPrimarySP, but returns removed families as well.
+	ListVGFsByPrimarySPWithRemoved(ctx context.Context, spID uint32, limit, offset int) ([]*models.GlobalVirtualGroupFamily, error)
+
+	// CountObjects returns the number of objects stored, along with the number of the sealed ones not removed
+	// and the number of the removed ones.
+	// An error is returned if the operation fails.
+	CountObjects(ctx context.Context) (total, sealed, removed int64, err error)
 
+	// SaveDBStatistics stores the given statistics, replacing the previous ones.
+	// An error is returned if the operation fails.
 	SaveDBStatistics(ctx context.Context, ds *models.DataStat) error
 
-	// Begin begins a transaction with any transaction options opts
-	Begin(ctx context.Context) *Impl
+	// GetDataStat returns the statistics stored last, which are all zero before the first ones get saved.
+	// An error is returned if the operation fails.
+	GetDataStat(ctx context.Context) (*models.DataStat, error)
+
+	// SaveFailedBlock records that the block at the given height failed to be processed. When the height has
+	// failed already, its retry count is incremented and its module and error replaced, its first failure kept.
+	// An error is returned if the operation fails.
+	SaveFailedBlock(ctx context.Context, failed *models.FailedBlock) error
+
+	// ListFailedBlocks returns a page of the failed blocks, ordered by height.
+	// An error is returned if the operation fails.
+	ListFailedBlocks(ctx context.Context, limit, offset int) ([]*models.FailedBlock, error)
 
-	// Rollback rollbacks the changes in a transaction
-	Rollback()
+	// DeleteFailedBlock deletes the failed block at the given height, doing nothing if there is none.
+	// An error is returned if the operation fails.
+	DeleteFailedBlock(ctx context.Context, height uint64) error
+
+	// SaveBlockProgress records how far the txs and events of the given height have been handled, replacing the
+	// progress recorded for it before.
+	// An error is returned if the operation fails.
+	SaveBlockProgress(ctx context.Context, progress *models.BlockProgress) error
+
+	// GetBlockProgress returns the progress recorded for the given height.
+	// ErrBlockProgressNotFound is returned if there is none, another error if the operation fails.
+	GetBlockProgress(ctx context.Context, height uint64) (*models.BlockProgress, error)
 
-	// Commit commits the changes in a transaction
+	// DeleteBlockProgress deletes the progress recorded for the given height, doing nothing if there is none.
 	// An error is returned if the operation fails.
+	DeleteBlockProgress(ctx context.Context, height uint64) error
+
+	// ListBlockProgressHeights returns in ascending order the heights having a progress recorded, which have been
+	// left unfinished.
+	// An error is returned if the operation fails.
+	ListBlockProgressHeights(ctx context.Context) ([]uint64, error)
+
+	// Begin begins a transaction with the default options of the database.
+	// Writes made through the transaction are not retried on deadlocks: they fail with ErrTxRetryable instead,
+	// and the whole transaction has to be rolled back and run again.
+	// When called on a transaction, or when ctx carries one (see ContextWithTx), a savepoint of it is returned
+	// instead: its Rollback only undoes the writes made since, and its Commit leaves them to be committed
+	// along with the outer transaction.
+	// In read-only mode the writes made through the transaction fail with ErrReadOnly, like any other.
+	Begin(ctx context.Context) *Impl
+
+	// BeginWithOptions behaves like Begin, beginning the transaction with the given options.
+	// MySQL and PostgreSQL honor the read committed, read uncommitted, repeatable read and serializable
+	// isolation levels, PostgreSQL taking snapshot as repeatable read; any other level makes the transaction
+	// fail to begin. SQLite ignores the isolation level, its transactions being serializable.
+	// The writes made through a read-only transaction fail with ErrReadOnlyTx, whatever the database.
+	// A savepoint keeps the isolation level of its transaction, and is read-only when either is.
+	BeginWithOptions(ctx context.Context, opts *sql.TxOptions) *Impl
+
+	// Rollback rollbacks the changes in a transaction.
+	// ErrTxFinished is returned if the transaction has already been committed or rolled back,
+	// and any other error if the operation fails.
+	Rollback() error
+
+	// Commit commits the changes in a transaction, rolling them back if that fails.
+	// ErrTxFinished is returned if the transaction has already been committed or rolled back,
+	// and any other error if the operation fails.
 	Commit() error
 
+	// DeleteDataAfterHeight deletes everything stored for the heights above the given one, so that they can be
+	// parsed again after a chain reorganization: the blocks, txs, messages, events, commit signatures and block
+	// results, along with the rows of the module tables registered through RegisterRollbackTable. The epoch is
+	// moved back to the given height if it is past it.
+	// A module row updated above the height would be deleted rather than restored to its previous state, so
+	// ErrRollbackLosesState is returned, nothing being deleted, if such a row was created at or below the height:
+	// the database has to be indexed again from scratch then.
+	DeleteDataAfterHeight(ctx context.Context, height uint64) error
+
+	// Ping checks that the database can be reached and answers queries.
+	// An error is returned if it can't.
+	Ping(ctx context.Context) error
+
 	// Close closes the connection to the database
 	Close()
 }
@@ -153,19 +714,25 @@ type Database interface {
 // PruningDb represents a database that supports pruning properly
 type PruningDb interface {
 	// Prune prunes the data for the given height, returning any error
-	Prune(height int64) error
+	Prune(ctx context.Context, height int64) error
 
 	// StoreLastPruned saves the last height at which the database was pruned
-	StoreLastPruned(height int64) error
+	StoreLastPruned(ctx context.Context, height int64) error
 
 	// GetLastPruned returns the last height at which the database was pruned
-	GetLastPruned() (int64, error)
+	GetLastPruned(ctx context.Context) (int64, error)
 }
 
 // Context contains the data that might be used to build a Database instance
 type Context struct {
 	Cfg            databaseconfig.Config
 	EncodingConfig *params.EncodingConfig
+
+	// AddressesParser extracts the addresses involved in each stored message, which default to its signers when nil
+	AddressesParser models.MessageAddressesParser
+
+	// ChainID is the id of the chain being indexed, which scopes the rows of the database
+	ChainID string
 }
 
 // NewContext allows to build a new Context instance
@@ -179,43 +746,148 @@ func NewContext(cfg databaseconfig.Config, encodingConfig *params.EncodingConfig
 // Builder represents a method that allows to build any database from a given Marshaler and configuration
 type Builder func(ctx *Context) (Database, error)
 
-type Impl struct {
-	Db             *gorm.DB
-	EncodingConfig *params.EncodingConfig
+// NewImpl opens the database of the given context, along with its read replicas, and returns an Impl running its
+// queries through it, the builders of the database types wrapping it.
+// An error is returned if the database fails to be opened.
+func NewImpl(ctx *Context) (*Impl, error) {
+	db, err := sqlclient.New(&ctx.Cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := RegisterErrorTranslation(db); err != nil {
+		return nil, err
+	}
+	if err := RegisterQueryTimeout(db, time.Duration(ctx.Cfg.QueryTimeout)); err != nil {
+		return nil, err
+	}
+	var metrics Metrics = NoopMetrics{}
+	if ctx.Cfg.EnableMetrics {
+		metrics = PrometheusMetrics{}
+	}
+	if err := RegisterMetrics(db, metrics); err != nil {
+		return nil, err
+	}
+	replicas, err := sqlclient.NewReplicas(&ctx.Cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := RegisterReplicas(db, sqlclient.ReplicaDialectors(&ctx.Cfg, replicas)); err != nil {
+		return nil, err
+	}
+	return &Impl{
+		Db:                  db,
+		EncodingConfig:      ctx.EncodingConfig,
+		MaxPageSize:         ctx.Cfg.MaxPageSize,
+		MaxMissingHeights:   ctx.Cfg.MaxMissingHeights,
+		MaxRetries:          ctx.Cfg.MaxRetries,
+		TxBatchSize:         ctx.Cfg.TxBatchSize,
+		UpsertBatchSize:     ctx.Cfg.UpsertBatchSize,
+		Partitions:          NewPartitionManager(db, ctx.Cfg.EnablePartitioning, ctx.Cfg.PartitionSize),
+		Replicas:            replicas,
+		AddressesParser:     ctx.AddressesParser,
+		ArchiveEvents:       ctx.Cfg.ArchiveEvents,
+		GzipThreshold:       ctx.Cfg.BlockResultCompressionThreshold,
+		TxCompressThreshold: ctx.Cfg.TxCompressionThreshold,
+		ChainID:             ctx.ChainID,
+		ReadOnly:            ctx.Cfg.ReadOnly,
+	}, nil
 }
 
-// createPartitionIfNotExists creates a new partition having the given partition id if not existing
-func (db *Impl) createPartitionIfNotExists(table string, partitionID int64) error {
-	partitionTable := fmt.Sprintf("%s_%d", table, partitionID)
+const (
+	// DefaultMaxPageSize is the max number of rows returned by paginated queries when no other limit is configured
+	DefaultMaxPageSize = 100
 
-	stmt := fmt.Sprintf(
-		"CREATE TABLE IF NOT EXISTS %s PARTITION OF %s FOR VALUES IN (%d)",
-		partitionTable,
-		table,
-		partitionID,
-	)
-	err := db.Db.Exec(stmt).Error
+	// DefaultMaxMissingHeights is the max number of heights returned by GetMissingHeights when no other limit is configured
+	DefaultMaxMissingHeights = 10000
 
-	if err != nil {
-		return err
+	// DefaultBlockResultCompressionThreshold is the size above which block results are compressed
+	// when no other threshold is configured
+	DefaultBlockResultCompressionThreshold = 1 << 16
+
+	// DefaultTxCompressionThreshold is the size above which the messages and the logs of a tx are compressed
+	// when no other threshold is configured
+	DefaultTxCompressionThreshold = 1 << 20
+
+	// DefaultTxBatchSize is the max number of tx rows inserted by a single statement when no other limit is configured
+	DefaultTxBatchSize = 200
+
+	// DefaultUpsertBatchSize is the max number of rows written by a single statement of the MultiSave methods
+	// when no other limit is configured. An object with a 1KB name and its checksums takes a few KB, which keeps
+	// a statement well below the 4MB max_allowed_packet of older MySQL servers.
+	DefaultUpsertBatchSize = 500
+)
+
+type Impl struct {
+	Db                  *gorm.DB
+	EncodingConfig      *params.EncodingConfig
+	MaxPageSize         int
+	MaxMissingHeights   int
+	MaxRetries          int
+	TxBatchSize         int
+	UpsertBatchSize     int
+	Partitions          PartitionManager
+	Replicas            []*sql.DB
+	AddressesParser     models.MessageAddressesParser
+	ArchiveEvents       bool
+	GzipThreshold       int
+	TxCompressThreshold int
+
+	// ChainID is the chain the blocks, txs, epoch, buckets and objects are written for and read from,
+	// so that several chains can be indexed into the same database
+	ChainID string
+
+	// ReadOnly makes every write fail with ErrReadOnly, while the reads keep working
+	ReadOnly bool
+
+	// inTx tells whether Db is a transaction opened by Begin, in which case failed writes are never retried
+	inTx bool
+	// readOnly tells whether Db is a read-only transaction, through which every write fails
+	readOnly bool
+	// finished tells whether the transaction has been committed or rolled back already
+	finished bool
+	// txDepth is the number of transactions this one is nested into, each nested transaction being
+	// a savepoint named savePointName
+	txDepth       int
+	savePointName string
+}
+
+// pageLimit caps the given limit to the max page size, using the max page size itself when no limit is given
+func (db *Impl) pageLimit(limit int) int {
+	maxPageSize := db.MaxPageSize
+	if maxPageSize <= 0 {
+		maxPageSize = DefaultMaxPageSize
 	}
 
-	return nil
+	if limit <= 0 || limit > maxPageSize {
+		return maxPageSize
+	}
+	return limit
 }
 
 // -------------------------------------------------------------------------------------------------------------------
 
 func (db *Impl) PrepareTables(ctx context.Context, tables []schema.Tabler) error {
-	q := db.Db.WithContext(ctx)
-	m := db.Db.Migrator()
+	if db.ReadOnly {
+		return ErrReadOnly
+	}
+
+	q := onPrimary(db.Db.WithContext(ctx))
+	m := q.Migrator()
 
 	for _, t := range tables {
-		if m.HasTable(t.TableName()) {
-			continue
+		if !m.HasTable(t.TableName()) {
+			if err := q.Table(t.TableName()).AutoMigrate(t); err != nil {
+				log.Errorw("migrate table failed", "table", t.TableName(), "err", err)
+				return err
+			}
 		}
 
-		if err := q.Table(t.TableName()).AutoMigrate(t); err != nil {
-			log.Errorw("migrate table failed", "table", t.TableName(), "err", err)
+		if err := db.ensureIndexes(ctx, t); err != nil {
+			log.Errorw("create missing indexes failed", "table", t.TableName(), "err", err)
+			return err
+		}
+
+		if err := db.registerUpdateAtTable(t); err != nil {
 			return err
 		}
 	}
@@ -223,363 +895,2911 @@ func (db *Impl) PrepareTables(ctx context.Context, tables []schema.Tabler) error
 	return nil
 }
 
+// ensureIndexes creates the indexes declared by the given table model that do not exist yet, so that indexes
+// added to a model after its table has been created are still applied, and then checks that all of them exist.
+// The queries of the read paths depend on these indexes, so a table missing any of them is reported as an error.
+func (db *Impl) ensureIndexes(ctx context.Context, t schema.Tabler) error {
+	stmt := &gorm.Statement{DB: db.Db}
+	if err := stmt.Parse(t); err != nil {
+		return err
+	}
+
+	m := onPrimary(db.Db.WithContext(ctx)).Migrator()
+	for name := range stmt.Schema.ParseIndexes() {
+		if m.HasIndex(t, name) {
+			continue
+		}
+		if err := m.CreateIndex(t, name); err != nil {
+			return err
+		}
+		if !m.HasIndex(t, name) {
+			return fmt.Errorf("index %s of table %s could not be created", name, t.TableName())
+		}
+	}
+	return nil
+}
+
 func (db *Impl) AutoMigrate(ctx context.Context, tables []schema.Tabler) error {
-	m := db.Db.Migrator()
+	if db.ReadOnly {
+		return ErrReadOnly
+	}
+
+	m := onPrimary(db.Db.WithContext(ctx)).Migrator()
 	for _, t := range tables {
 		if err := m.AutoMigrate(t); err != nil {
 			log.Errorw("migrate table failed", "table", t.TableName(), "err", err)
 			return err
 		}
+		for _, index := range replacedIndexes[t.TableName()] {
+			if !m.HasIndex(t, index) {
+				continue
+			}
+			if err := m.DropIndex(t, index); err != nil {
+				return fmt.Errorf("failed to drop index %s of table %s: %w", index, t.TableName(), err)
+			}
+		}
 	}
 	return nil
 }
 
-// HasBlock implements database.Database
-func (db *Impl) HasBlock(ctx context.Context, height uint64) (bool, error) {
-	var res bool
-	err := db.Db.Raw(`SELECT EXISTS(SELECT 1 FROM blocks WHERE height = ?);`, height).Scan(&res).Error
-	return res, err
+// replacedIndexes are the indexes the tables had before being replaced by indexes of other columns, or unique
+// ones, under another name since AutoMigrate leaves the existing indexes as they are
+var replacedIndexes = map[string][]string{
+	(&models.PaymentLedger{}).TableName():     {"idx_ledger_tx"},
+	(&models.Tx{}).TableName():                {"idx_hash"},
+	(&models.StorageProvider{}).TableName():   {"idx_sp_id"},
+	(&models.LocalVirtualGroup{}).TableName(): {"idx_lvg_bucket"},
 }
 
-// GetLastBlockHeight returns the last block height stored inside the database
-func (db *Impl) GetLastBlockHeight(ctx context.Context) (uint64, error) {
-	var height uint64
-
-	err := db.Db.Table((&models.Block{}).TableName()).Select("height").Order("height DESC").Take(&height).Error
-	if errIsNotFound(err) {
-		return 0, nil
-	}
-
-	return height, err
+// chainScopedTables are the models whose rows are scoped by chain id
+var chainScopedTables = []schema.Tabler{
+	&models.Block{}, &models.Tx{}, &models.Epoch{}, &models.Bucket{}, &models.Object{},
+	&models.Event{}, &models.CommitSig{}, &models.BlockResult{},
 }
 
-// SaveBlock implements database.Database
-func (db *Impl) SaveBlock(ctx context.Context, block *models.Block) error {
-	err := db.Db.Table((&models.Block{}).TableName()).Clauses(clause.OnConflict{
-		Columns:   []clause.Column{{Name: "hash"}},
-		UpdateAll: true,
-	}, clause.OnConflict{
-		Columns:   []clause.Column{{Name: "height"}},
-		UpdateAll: true,
-	}).Create(block).Error
-	return err
+// legacyUniqueIndexes are the unique indexes the chain scoped tables had before the chain id was part of them
+var legacyUniqueIndexes = map[string][]string{
+	(&models.Block{}).TableName():     {"idx_height"},
+	(&models.Tx{}).TableName():        {"idx_height_tx_index"},
+	(&models.Bucket{}).TableName():    {"idx_bucket_id", "idx_bucket_name"},
+	(&models.Object{}).TableName():    {"idx_object_id"},
+	(&models.CommitSig{}).TableName(): {"idx_validator_address_height"},
+}
+
+// CheckChainID implements database.Database
+func (db *Impl) CheckChainID(ctx context.Context) error {
+	m := onPrimary(db.Db.WithContext(ctx)).Migrator()
+	for _, t := range chainScopedTables {
+		if !m.HasTable(t.TableName()) {
+			continue
+		}
+		if !m.HasColumn(t, "chain_id") {
+			return fmt.Errorf("table %s %w", t.TableName(), ErrChainIDNotBackfilled)
+		}
+		if db.ChainID == "" {
+			continue
+		}
+
+		var unscoped bool
+		err := db.withContext(ctx).Raw(fmt.Sprintf(`SELECT EXISTS(SELECT 1 FROM %s WHERE chain_id = ?);`, t.TableName()), "").
+			Scan(&unscoped).Error
+		if err != nil {
+			return err
+		}
+		if unscoped {
+			return fmt.Errorf("table %s %w", t.TableName(), ErrChainIDNotBackfilled)
+		}
+	}
+	return nil
+}
+
+// BackfillChainID implements database.Database
+func (db *Impl) BackfillChainID(ctx context.Context) (int64, error) {
+	if db.ReadOnly {
+		return 0, ErrReadOnly
+	}
+	if db.ChainID == "" {
+		return 0, errors.New("no chain id to backfill")
+	}
+
+	m := onPrimary(db.Db.WithContext(ctx)).Migrator()
+	for _, t := range chainScopedTables {
+		if !m.HasTable(t.TableName()) {
+			continue
+		}
+
+		// The chain id is part of the primary key of the epoch and of the block results, which can't be altered
+		// in place
+		if _, ok := t.(*models.Epoch); ok && !m.HasColumn(t, "chain_id") {
+			if err := db.recreateEpochTable(ctx); err != nil {
+				return 0, fmt.Errorf("failed to migrate table %s: %w", t.TableName(), err)
+			}
+			continue
+		}
+		if _, ok := t.(*models.BlockResult); ok && !m.HasColumn(t, "chain_id") {
+			if err := db.recreateBlockResultTable(ctx); err != nil {
+				return 0, fmt.Errorf("failed to migrate table %s: %w", t.TableName(), err)
+			}
+			continue
+		}
+
+		// The indexes including the chain id get created by AutoMigrate
+		if err := m.AutoMigrate(t); err != nil {
+			return 0, fmt.Errorf("failed to migrate table %s: %w", t.TableName(), err)
+		}
+		for _, index := range legacyUniqueIndexes[t.TableName()] {
+			if !m.HasIndex(t, index) {
+				continue
+			}
+			if err := m.DropIndex(t, index); err != nil {
+				return 0, fmt.Errorf("failed to drop index %s of table %s: %w", index, t.TableName(), err)
+			}
+		}
+	}
+
+	var backfilled int64
+	err := db.retry(ctx, func() error {
+		backfilled = 0
+		return db.withContext(ctx).Transaction(func(gormTx *gorm.DB) error {
+			for _, t := range chainScopedTables {
+				if !gormTx.Migrator().HasTable(t.TableName()) {
+					continue
+				}
+				res := gormTx.Table(t.TableName()).Where("chain_id = ?", "").Update("chain_id", db.ChainID)
+				if res.Error != nil {
+					return res.Error
+				}
+				backfilled += res.RowsAffected
+			}
+			return nil
+		})
+	})
+	return backfilled, err
+}
+
+// recreateEpochTable creates the epoch table again with the chain id inside its primary key,
+// keeping the stored epoch
+func (db *Impl) recreateEpochTable(ctx context.Context) error {
+	var epochs []*models.Epoch
+	err := db.withContext(ctx).Table((&models.Epoch{}).TableName()).
+		Select("one_row_id", "block_height", "block_hash", "update_time").
+		Find(&epochs).Error
+	if err != nil {
+		return err
+	}
+
+	return db.withContext(ctx).Transaction(func(gormTx *gorm.DB) error {
+		m := gormTx.Migrator()
+		if err := m.DropTable(&models.Epoch{}); err != nil {
+			return err
+		}
+		if err := m.CreateTable(&models.Epoch{}); err != nil {
+			return err
+		}
+		if len(epochs) == 0 {
+			return nil
+		}
+		return gormTx.Table((&models.Epoch{}).TableName()).Create(epochs).Error
+	})
+}
+
+// recreateBlockResultTable creates the block result table again with the chain id inside its primary key,
+// copying the stored block results into it
+func (db *Impl) recreateBlockResultTable(ctx context.Context) error {
+	legacyTable := (&models.BlockResult{}).TableName() + "_legacy"
+	return db.withContext(ctx).Transaction(func(gormTx *gorm.DB) error {
+		m := gormTx.Migrator()
+		// The oldest tables predate the encoding of the results
+		if !m.HasColumn(&models.BlockResult{}, "encoding") {
+			if err := m.AddColumn(&models.BlockResult{}, "Encoding"); err != nil {
+				return err
+			}
+		}
+		if err := m.RenameTable(&models.BlockResult{}, legacyTable); err != nil {
+			return err
+		}
+		if err := m.CreateTable(&models.BlockResult{}); err != nil {
+			return err
+		}
+		err := gormTx.Exec(fmt.Sprintf(`INSERT INTO %s (chain_id, block_height, result, encoding) SELECT '', block_height, result, encoding FROM %s;`,
+			(&models.BlockResult{}).TableName(), legacyTable)).Error
+		if err != nil {
+			return err
+		}
+		return m.DropTable(legacyTable)
+	})
+}
+
+// HasBlock implements database.Database
+func (db *Impl) HasBlock(ctx context.Context, height uint64) (bool, error) {
+	var res bool
+	err := db.withContext(ctx).Raw(`SELECT EXISTS(SELECT 1 FROM blocks WHERE chain_id = ? AND height = ?);`, db.ChainID, height).Scan(&res).Error
+	return res, err
+}
+
+// GetBlockByHeight implements database.Database
+func (db *Impl) GetBlockByHeight(ctx context.Context, height uint64) (*models.Block, error) {
+	var block models.Block
+
+	err := db.chainTable(ctx, &models.Block{}).Where("height = ?", height).Take(&block).Error
+	if err != nil {
+		if errIsNotFound(err) {
+			return nil, ErrBlockNotFound
+		}
+		return nil, err
+	}
+	return &block, nil
+}
+
+// GetLastBlockHeight returns the last block height stored inside the database
+func (db *Impl) GetLastBlockHeight(ctx context.Context) (uint64, error) {
+	var height uint64
+
+	err := db.chainTable(ctx, &models.Block{}).Select("height").Order("height DESC").Take(&height).Error
+	if errIsNotFound(err) {
+		return 0, nil
+	}
+
+	return height, err
+}
+
+// GetMissingHeights implements database.Database.
+// Rather than loading every stored height, the gaps are detected inside the database by looking for the
+// stored heights whose successor is missing; both lookups are served by the unique index on (chain_id, height) and
+// the scan stops as soon as enough gaps have been found.
+func (db *Impl) GetMissingHeights(ctx context.Context, startHeight, endHeight uint64) ([]uint64, error) {
+	if startHeight > endHeight {
+		return nil, nil
+	}
+
+	maxHeights := db.MaxMissingHeights
+	if maxHeights <= 0 {
+		maxHeights = DefaultMaxMissingHeights
+	}
+
+	var missing []uint64
+	appendRange := func(from, to uint64) {
+		for height := from; height <= to && len(missing) < maxHeights; height++ {
+			missing = append(missing, height)
+		}
+	}
+
+	var bounds struct {
+		MinHeight *uint64
+		MaxHeight *uint64
+	}
+	err := db.chainTable(ctx, &models.Block{}).
+		Select("MIN(height) AS min_height, MAX(height) AS max_height").
+		Where("height BETWEEN ? AND ?", startHeight, endHeight).
+		Scan(&bounds).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// No block stored inside the range at all
+	if bounds.MinHeight == nil || bounds.MaxHeight == nil {
+		appendRange(startHeight, endHeight)
+		return missing, nil
+	}
+
+	if *bounds.MinHeight > startHeight {
+		appendRange(startHeight, *bounds.MinHeight-1)
+	}
+
+	var gaps []struct {
+		GapStart uint64
+		GapEnd   uint64
+	}
+	err = db.withContext(ctx).Raw(`
+SELECT b.height + 1 AS gap_start,
+	(SELECT MIN(n.height) FROM blocks n WHERE n.chain_id = b.chain_id AND n.height > b.height) - 1 AS gap_end
+FROM blocks b
+WHERE b.chain_id = ? AND b.height >= ? AND b.height < ?
+	AND NOT EXISTS (SELECT 1 FROM blocks n WHERE n.chain_id = b.chain_id AND n.height = b.height + 1)
+ORDER BY b.height
+LIMIT ?`, db.ChainID, *bounds.MinHeight, *bounds.MaxHeight, maxHeights).Scan(&gaps).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, gap := range gaps {
+		appendRange(gap.GapStart, gap.GapEnd)
+	}
+
+	if *bounds.MaxHeight < endHeight {
+		appendRange(*bounds.MaxHeight+1, endHeight)
+	}
+
+	return missing, nil
+}
+
+// SaveBlock implements database.Database
+func (db *Impl) SaveBlock(ctx context.Context, block *models.Block) error {
+	if err := db.checkWritable(ctx); err != nil {
+		return err
+	}
+
+	// The partition of the txs is created as soon as their block is saved, before a transaction spanning
+	// the whole block gets to lock the txs table, since adding partitions requires locking it
+	if err := db.partitions().EnsurePartition(ctx, (&models.Tx{}).TableName(), block.Height); err != nil {
+		return err
+	}
+
+	block.ChainID = db.ChainID
+	return db.retry(ctx, func() error {
+		return db.withContext(ctx).Table(block.TableName()).Clauses(clause.OnConflict{
+			// A block re-stored after a reorg keeps its height but gets a new hash, so height is the key
+			Columns:   []clause.Column{{Name: "chain_id"}, {Name: "height"}},
+			UpdateAll: true,
+		}).Create(block).Error
+	})
+}
+
+// GetBlockNearTime implements database.Database
+func (db *Impl) GetBlockNearTime(ctx context.Context, t time.Time) (*models.Block, error) {
+	var block models.Block
+
+	// Block timestamps are stored in whole seconds, so truncating t keeps the "not after" semantic
+	err := db.chainTable(ctx, &models.Block{}).
+		Where("timestamp <= ?", t.Unix()).
+		Order("timestamp DESC, height DESC").
+		Take(&block).Error
+	if err != nil {
+		if errIsNotFound(err) {
+			return nil, ErrBlockNotFound
+		}
+		return nil, err
+	}
+	return &block, nil
+}
+
+// ListBlocksByTimeRange implements database.Database
+func (db *Impl) ListBlocksByTimeRange(ctx context.Context, from, to time.Time, limit, offset int) ([]*models.Block, error) {
+	fromTimestamp := from.Unix()
+	if from.Nanosecond() > 0 {
+		// A block at the truncated second would be before from
+		fromTimestamp++
+	}
+
+	if offset < 0 {
+		offset = 0
+	}
+
+	blocks := make([]*models.Block, 0)
+	err := db.chainTable(ctx, &models.Block{}).
+		Where("timestamp BETWEEN ? AND ?", fromTimestamp, to.Unix()).
+		Order("height ASC").
+		Limit(db.pageLimit(limit)).
+		Offset(offset).
+		Find(&blocks).Error
+	if err != nil {
+		return nil, err
+	}
+	return blocks, nil
 }
 
 // GetTotalBlocks implements database.Database
 func (db *Impl) GetTotalBlocks(ctx context.Context) int64 {
 	var blockCount int64
-	err := db.Db.Table((&models.Block{}).TableName()).Count(&blockCount).Error
+	err := db.chainTable(ctx, &models.Block{}).Count(&blockCount).Error
+	if err != nil {
+		return 0
+	}
+
+	return blockCount
+}
+
+// SaveBlockResult implements database.Database
+func (db *Impl) SaveBlockResult(ctx context.Context, height uint64, result []byte) error {
+	row := &models.BlockResult{ChainID: db.ChainID, BlockHeight: height, Result: string(result), Encoding: models.BlockResultEncodingRaw}
+
+	threshold := db.GzipThreshold
+	if threshold == 0 {
+		threshold = DefaultBlockResultCompressionThreshold
+	}
+	if threshold > 0 && len(result) > threshold {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(result); err != nil {
+			return fmt.Errorf("failed to compress block result: %s", err)
+		}
+		if err := zw.Close(); err != nil {
+			return fmt.Errorf("failed to compress block result: %s", err)
+		}
+		row.Result = base64.StdEncoding.EncodeToString(buf.Bytes())
+		row.Encoding = models.BlockResultEncodingGzip
+	}
+
+	// UpdateAll would skip the encoding, which has a default value
+	return db.retry(ctx, func() error {
+		return db.withContext(ctx).Table((&models.BlockResult{}).TableName()).Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "chain_id"}, {Name: "block_height"}},
+			DoUpdates: clause.AssignmentColumns([]string{"result", "encoding"}),
+		}).Create(row).Error
+	})
+}
+
+// GetBlockResult implements database.Database
+func (db *Impl) GetBlockResult(ctx context.Context, height uint64) ([]byte, error) {
+	var row models.BlockResult
+	err := db.chainTable(ctx, &models.BlockResult{}).
+		Where("block_height = ?", height).
+		Take(&row).Error
+	if err != nil {
+		if errIsNotFound(err) {
+			return nil, ErrBlockResultNotFound
+		}
+		return nil, err
+	}
+
+	switch row.Encoding {
+	case models.BlockResultEncodingRaw:
+		return []byte(row.Result), nil
+	case models.BlockResultEncodingGzip:
+		compressed, err := base64.StdEncoding.DecodeString(row.Result)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode block result %d: %s", height, err)
+		}
+		zr, err := gzip.NewReader(bytes.NewReader(compressed))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress block result %d: %s", height, err)
+		}
+		defer zr.Close()
+		result, err := io.ReadAll(zr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress block result %d: %s", height, err)
+		}
+		return result, nil
+	default:
+		return nil, fmt.Errorf("unknown encoding %q of block result %d", row.Encoding, height)
+	}
+}
+
+// SaveTx implements database.Database
+func (db *Impl) SaveTx(ctx context.Context, blockTimestamp uint64, index int, tx *types.Tx) error {
+	dbTx, dbMsgs, err := db.txRows(blockTimestamp, index, tx)
+	if err != nil {
+		return err
+	}
+	return db.saveTxRows(ctx, []*models.Tx{dbTx}, dbMsgs)
+}
+
+// SaveTxs implements database.Database
+func (db *Impl) SaveTxs(ctx context.Context, blockTimestamp uint64, txs []*types.Tx) error {
+	return db.SaveTxsFrom(ctx, blockTimestamp, 0, txs)
+}
+
+// SaveTxsFrom implements database.Database
+func (db *Impl) SaveTxsFrom(ctx context.Context, blockTimestamp uint64, first int, txs []*types.Tx) error {
+	if len(txs) == 0 {
+		return nil
+	}
+
+	dbTxs := make([]*models.Tx, len(txs))
+	var dbMsgs []*models.Message
+	for index, tx := range txs {
+		dbTx, msgs, err := db.txRows(blockTimestamp, first+index, tx)
+		if err != nil {
+			return fmt.Errorf("failed to encode tx %s: %s", tx.TxHash, err)
+		}
+		dbTxs[index] = dbTx
+		dbMsgs = append(dbMsgs, msgs...)
+	}
+	return db.saveTxRows(ctx, dbTxs, dbMsgs)
+}
+
+// txRows builds the tx row and the message rows of the given tx, found at the given index inside its block
+func (db *Impl) txRows(blockTimestamp uint64, index int, tx *types.Tx) (*models.Tx, []*models.Message, error) {
+	var sigs = make([]string, len(tx.Signatures))
+	for index, sig := range tx.Signatures {
+		sigs[index] = base64.StdEncoding.EncodeToString(sig)
+	}
+	sigsBz, err := json.Marshal(sigs)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to JSON encode tx signatures: %s", err)
+	}
+
+	var msgs = make([]string, len(tx.Body.Messages))
+	for index, msg := range tx.Body.Messages {
+		bz, err := db.EncodingConfig.Codec.MarshalJSON(msg)
+		if err != nil {
+			return nil, nil, err
+		}
+		msgs[index] = string(bz)
+	}
+	msgsBz, err := jsonArray(msgs)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to JSON encode tx messages: %s", err)
+	}
+
+	feeBz, err := db.EncodingConfig.Codec.MarshalJSON(tx.AuthInfo.Fee)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to JSON encode tx fee: %s", err)
+	}
+
+	var sigInfos = make([]string, len(tx.AuthInfo.SignerInfos))
+	for index, info := range tx.AuthInfo.SignerInfos {
+		bz, err := db.EncodingConfig.Codec.MarshalJSON(info)
+		if err != nil {
+			return nil, nil, err
+		}
+		sigInfos[index] = string(bz)
+	}
+	sigInfoBz, err := jsonArray(sigInfos)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to JSON encode tx signer infos: %s", err)
+	}
+
+	// Amino encodes a tx without logs as null, while the column holds an array
+	logsBz := []byte("[]")
+	if len(tx.Logs) > 0 {
+		logsBz, err = db.EncodingConfig.Amino.MarshalJSON(tx.Logs)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// CometBFT returns uppercase hashes, which get stored as raw bytes like every other hash
+	txHash, err := common.ParseHash(tx.TxHash)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	dbTx := &models.Tx{
+		ChainID:     db.ChainID,
+		Hash:        txHash,
+		Height:      uint64(tx.Height),
+		TxIndex:     uint32(index),
+		Success:     tx.Successful(),
+		Code:        tx.Code,
+		Codespace:   tx.Codespace,
+		MsgCount:    uint32(len(tx.Body.Messages)),
+		Messages:    string(msgsBz),
+		Memo:        tx.Body.Memo,
+		Signatures:  string(sigsBz),
+		SignerInfos: string(sigInfoBz),
+		Fee:         string(feeBz),
+		GasWanted:   uint64(tx.GasWanted),
+		GasUsed:     uint64(tx.GasUsed),
+		RawLog:      tx.RawLog,
+		Logs:        string(logsBz),
+		Timestamp:   blockTimestamp,
+	}
+	db.compressTx(dbTx)
+
+	dbMsgs := make([]*models.Message, len(tx.Body.Messages))
+	for index, msg := range tx.Body.Messages {
+		sdkMsg, _ := msg.GetCachedValue().(sdk.Msg)
+		dbMsgs[index] = db.messageRow(dbTx, index, msg.TypeUrl, sdkMsg, msgs[index])
+	}
+
+	return dbTx, dbMsgs, nil
+}
+
+// messageRow builds the row of the message found at the given index of the given tx, value being its JSON encoding.
+// msg is nil when the message could not be decoded, in which case its addresses are left empty.
+func (db *Impl) messageRow(dbTx *models.Tx, index int, typeURL string, msg sdk.Msg, value string) *models.Message {
+	signers := msgSigners(msg)
+	involved := signers
+	if db.AddressesParser != nil && msg != nil {
+		if addresses, err := db.AddressesParser(db.EncodingConfig.Codec, msg); err == nil {
+			involved = addresses
+		}
+	}
+
+	row := &models.Message{
+		TxHash:            dbTx.Hash,
+		MsgIndex:          uint32(index),
+		Height:            dbTx.Height,
+		TypeURL:           typeURL,
+		Value:             value,
+		Signers:           jsonStrings(normalizeAddresses(signers)),
+		InvolvedAddresses: jsonStrings(normalizeAddresses(involved)),
+	}
+	db.compressMessage(row)
+	return row
+}
+
+// msgSigners returns the signers of the given message, or nil if they can't be told
+func msgSigners(msg sdk.Msg) (signers []string) {
+	if msg == nil {
+		return nil
+	}
+
+	// GetSigners panics when the message holds malformed addresses
+	defer func() {
+		if r := recover(); r != nil {
+			signers = nil
+		}
+	}()
+
+	for _, signer := range msg.GetSigners() {
+		// Addresses that can't be parsed are returned as empty ones
+		if !signer.Empty() {
+			signers = append(signers, signer.String())
+		}
+	}
+	return signers
+}
+
+// jsonStrings returns the JSON array holding the given values
+func jsonStrings(values []string) string {
+	if values == nil {
+		values = []string{}
+	}
+	bz, _ := json.Marshal(values)
+	return string(bz)
+}
+
+// txCompressThreshold returns the size above which the messages and the logs of a tx, or the value of a message,
+// are compressed, which is negative when nothing is
+func (db *Impl) txCompressThreshold() int {
+	if db.TxCompressThreshold == 0 {
+		return DefaultTxCompressionThreshold
+	}
+	return db.TxCompressThreshold
+}
+
+// compressTx compresses the messages and the logs of the given tx row when their size exceeds the
+// configured threshold, so that large txs like the ones uploading wasm code never get truncated.
+// The compressed bytes are stored as they are, the JSON columns being left with empty arrays.
+func (db *Impl) compressTx(dbTx *models.Tx) {
+	threshold := db.txCompressThreshold()
+	if threshold < 0 || len(dbTx.Messages)+len(dbTx.Logs) <= threshold {
+		return
+	}
+
+	dbTx.CompressedMessages = zstdEncoder.EncodeAll([]byte(dbTx.Messages), nil)
+	dbTx.CompressedLogs = zstdEncoder.EncodeAll([]byte(dbTx.Logs), nil)
+	dbTx.Messages, dbTx.Logs = "[]", "[]"
+	dbTx.Compressed = true
+}
+
+// decompressTxs restores the messages and the logs of the given tx rows which got compressed by compressTx
+func decompressTxs(txs ...*models.Tx) error {
+	for _, tx := range txs {
+		if !tx.Compressed {
+			continue
+		}
+
+		messages, err := zstdDecoder.DecodeAll(tx.CompressedMessages, nil)
+		if err != nil {
+			return fmt.Errorf("failed to decompress tx %s: %s", tx.Hash.Hex(), err)
+		}
+		logs, err := zstdDecoder.DecodeAll(tx.CompressedLogs, nil)
+		if err != nil {
+			return fmt.Errorf("failed to decompress tx %s: %s", tx.Hash.Hex(), err)
+		}
+		tx.Messages, tx.Logs = string(messages), string(logs)
+		tx.CompressedMessages, tx.CompressedLogs = nil, nil
+		tx.Compressed = false
+	}
+	return nil
+}
+
+// compressMessage compresses the value of the given message row when its size exceeds the configured threshold,
+// like compressTx does for the tx holding it
+func (db *Impl) compressMessage(msg *models.Message) {
+	threshold := db.txCompressThreshold()
+	if threshold < 0 || len(msg.Value) <= threshold {
+		return
+	}
+
+	msg.CompressedValue = zstdEncoder.EncodeAll([]byte(msg.Value), nil)
+	msg.Value = "{}"
+	msg.Compressed = true
+}
+
+// The zstd encoder and decoder are safe for concurrent use through EncodeAll and DecodeAll
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil)
+)
+
+// normalizeAddresses turns the hex addresses among the given ones into their canonical lowercase
+// representation, so that the JSON address columns can be matched regardless of the input case.
+// Bech32 addresses are lowercased, which is their canonical form as well.
+func normalizeAddresses(addresses []string) []string {
+	normalized := make([]string, len(addresses))
+	for index, address := range addresses {
+		if hexAddr, err := common.NormalizeAddress(address); err == nil {
+			normalized[index] = hexAddr
+		} else {
+			normalized[index] = strings.ToLower(address)
+		}
+	}
+	return normalized
+}
+
+// jsonArray builds a JSON array out of the given JSON encoded elements, storing the empty ones as null
+func jsonArray(elements []string) ([]byte, error) {
+	raws := make([]json.RawMessage, len(elements))
+	for index, element := range elements {
+		if strings.TrimSpace(element) == "" {
+			element = "null"
+		}
+		raws[index] = json.RawMessage(element)
+	}
+	return json.Marshal(raws)
+}
+
+// saveTxRows upserts the given tx rows along with their message rows inside a single transaction
+func (db *Impl) saveTxRows(ctx context.Context, dbTxs []*models.Tx, dbMsgs []*models.Message) error {
+	if err := db.checkWritable(ctx); err != nil {
+		return err
+	}
+	for _, dbTx := range dbTxs {
+		if err := db.partitions().EnsurePartition(ctx, dbTx.TableName(), dbTx.Height); err != nil {
+			return err
+		}
+	}
+
+	return db.retry(ctx, func() error {
+		return db.withContext(ctx).Transaction(func(gormTx *gorm.DB) error {
+			// UpdateAll would skip the JSON columns, as they have a default value
+			err := gormTx.Table((&models.Tx{}).TableName()).Clauses(clause.OnConflict{
+				Columns: []clause.Column{{Name: "chain_id"}, {Name: "height"}, {Name: "tx_index"}},
+				DoUpdates: clause.AssignmentColumns([]string{
+					"hash", "success", "code", "codespace", "msg_count", "compressed", "compressed_messages",
+					"compressed_logs", "messages", "memo", "signatures", "signer_infos", "fee", "gas_wanted", "gas_used",
+					"raw_log", "logs", "timestamp",
+				}),
+			}).CreateInBatches(dbTxs, db.txBatchSize()).Error
+			if err != nil {
+				return err
+			}
+
+			return saveMessages(gormTx, dbMsgs)
+		})
+	})
+}
+
+// txBatchSize returns the max number of tx rows inserted by a single statement
+func (db *Impl) txBatchSize() int {
+	if db.TxBatchSize <= 0 {
+		return DefaultTxBatchSize
+	}
+	return db.TxBatchSize
+}
+
+// upsertBatchSize returns the max number of rows written by a single statement of the MultiSave methods
+func (db *Impl) upsertBatchSize() int {
+	if db.UpsertBatchSize <= 0 {
+		return DefaultUpsertBatchSize
+	}
+	return db.UpsertBatchSize
+}
+
+// lastByKey returns the given rows without the ones followed by another row having the same key, keeping their order.
+// Postgres rejects an upsert updating the same row twice, which a batch holding a key twice would.
+func lastByKey[T any, K comparable](rows []T, key func(T) K) []T {
+	last := make(map[K]int, len(rows))
+	for index, row := range rows {
+		last[key(row)] = index
+	}
+	if len(last) == len(rows) {
+		return rows
+	}
+
+	unique := make([]T, 0, len(last))
+	for index, row := range rows {
+		if last[key(row)] == index {
+			unique = append(unique, row)
+		}
+	}
+	return unique
+}
+
+// messagesBatchSize is the max number of message rows inserted by a single statement
+const messagesBatchSize = 500
+
+// saveMessages upserts the given message rows
+func saveMessages(gormDb *gorm.DB, msgs []*models.Message) error {
+	if len(msgs) == 0 {
+		return nil
+	}
+
+	// UpdateAll would skip the columns having a default value, so the updated ones are listed instead
+	return gormDb.Table((&models.Message{}).TableName()).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "tx_hash"}, {Name: "msg_index"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"height", "type_url", "value", "compressed", "compressed_value", "signers", "involved_addresses",
+		}),
+	}).CreateInBatches(msgs, messagesBatchSize).Error
+}
+
+// ListTxsByMessageType implements database.Database
+func (db *Impl) ListTxsByMessageType(ctx context.Context, typeURL string, fromHeight, toHeight uint64, limit, offset int) ([]*models.Tx, error) {
+	// The sub query is served by the (type_url, height) index of the messages table
+	txHashes := db.withContext(ctx).Table((&models.Message{}).TableName()).
+		Select("tx_hash").
+		Where("type_url = ? AND height BETWEEN ? AND ?", typeURL, fromHeight, toHeight)
+
+	if offset < 0 {
+		offset = 0
+	}
+
+	txs := make([]*models.Tx, 0)
+	err := db.chainTable(ctx, &models.Tx{}).
+		Where("height BETWEEN ? AND ? AND hash IN (?)", fromHeight, toHeight, txHashes).
+		Order("height ASC, tx_index ASC").
+		Limit(db.pageLimit(limit)).
+		Offset(offset).
+		Find(&txs).Error
+	if err != nil {
+		return nil, err
+	}
+	if err := decompressTxs(txs...); err != nil {
+		return nil, err
+	}
+	return txs, nil
+}
+
+// BackfillMessages implements database.Database.
+// The messages are decoded back from the JSON encoded ones stored inside each tx, keeping their type URL
+// from the "@type" field even when their type is not known to the codec.
+func (db *Impl) BackfillMessages(ctx context.Context, fromHeight, toHeight uint64) (int64, error) {
+	var txs []*models.Tx
+	err := db.chainTable(ctx, &models.Tx{}).
+		Select("hash", "height", "compressed", "compressed_messages", "compressed_logs", "messages", "logs").
+		Where("height BETWEEN ? AND ?", fromHeight, toHeight).
+		Find(&txs).Error
+	if err != nil {
+		return 0, err
+	}
+	if err := decompressTxs(txs...); err != nil {
+		return 0, err
+	}
+
+	var msgs []*models.Message
+	for _, tx := range txs {
+		var rawMsgs []json.RawMessage
+		if err := json.Unmarshal([]byte(tx.Messages), &rawMsgs); err != nil {
+			return 0, fmt.Errorf("failed to decode messages of tx %s: %s", tx.Hash.Hex(), err)
+		}
+
+		for index, raw := range rawMsgs {
+			var typed struct {
+				Type string `json:"@type"`
+			}
+			if err := json.Unmarshal(raw, &typed); err != nil {
+				return 0, fmt.Errorf("failed to decode message %d of tx %s: %s", index, tx.Hash.Hex(), err)
+			}
+
+			var msg sdk.Msg
+			if err := db.EncodingConfig.Codec.UnmarshalInterfaceJSON(raw, &msg); err != nil {
+				msg = nil
+			}
+			msgs = append(msgs, db.messageRow(tx, index, typed.Type, msg, string(raw)))
+		}
+	}
+
+	err = db.retry(ctx, func() error {
+		return saveMessages(db.withContext(ctx), msgs)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(msgs)), nil
+}
+
+// GetTxByHash implements database.Database.
+// The hash is matched on its raw bytes, so callers can build it with common.HexToHash
+// from either a 0x-prefixed or a bare hex string.
+func (db *Impl) GetTxByHash(ctx context.Context, hash common.Hash) (*models.Tx, error) {
+	var tx models.Tx
+
+	err := db.chainTable(ctx, &models.Tx{}).Where("hash = ?", hash).Take(&tx).Error
+	if err != nil {
+		if errIsNotFound(err) {
+			return nil, ErrTxNotFound
+		}
+		return nil, err
+	}
+	if err := decompressTxs(&tx); err != nil {
+		return nil, err
+	}
+	return &tx, nil
+}
+
+// GetTxsByHeight implements database.Database
+func (db *Impl) GetTxsByHeight(ctx context.Context, height uint64, limit, offset int) ([]*models.Tx, int64, error) {
+	q := db.chainTable(ctx, &models.Tx{}).Where("height = ?", height).Session(&gorm.Session{})
+
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	txs := make([]*models.Tx, 0)
+	if total == 0 {
+		return txs, 0, nil
+	}
+
+	if offset < 0 {
+		offset = 0
+	}
+	err := q.Order("tx_index ASC").Limit(db.pageLimit(limit)).Offset(offset).Find(&txs).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := decompressTxs(txs...); err != nil {
+		return nil, 0, err
+	}
+	return txs, total, nil
+}
+
+// eventsBatchSize is the max number of event rows inserted by a single statement
+const eventsBatchSize = 500
+
+// SaveEvents implements database.Database
+func (db *Impl) SaveEvents(ctx context.Context, height uint64, txIndex uint32, txHash common.Hash, events []sdk.Event) error {
+	return db.saveEvents(ctx, height, models.EventOriginTx, txIndex, &txHash, events)
+}
+
+// SaveBlockEvents implements database.Database
+func (db *Impl) SaveBlockEvents(ctx context.Context, height uint64, origin models.EventOrigin, events []sdk.Event) error {
+	if origin == models.EventOriginTx {
+		return fmt.Errorf("events of txs must be saved with SaveEvents")
+	}
+	return db.saveEvents(ctx, height, origin, 0, nil, events)
+}
+
+// saveEvents replaces the events archived for the given height, origin and tx, which is nil for the block events
+func (db *Impl) saveEvents(ctx context.Context, height uint64, origin models.EventOrigin, txIndex uint32, txHash *common.Hash, events []sdk.Event) error {
+	if !db.ArchiveEvents || len(events) == 0 {
+		return nil
+	}
+
+	rows := make([]*models.Event, len(events))
+	for index, event := range events {
+		attributes, err := models.EncodeEventAttributes(event.Attributes)
+		if err != nil {
+			return fmt.Errorf("failed to encode attributes of event %s: %s", event.Type, err)
+		}
+		rows[index] = &models.Event{
+			ChainID:    db.ChainID,
+			Height:     height,
+			Origin:     origin,
+			TxHash:     txHash,
+			TxIndex:    txIndex,
+			EventIndex: uint32(index),
+			Type:       event.Type,
+			Attributes: attributes,
+		}
+	}
+
+	return db.retry(ctx, func() error {
+		return db.withContext(ctx).Transaction(func(gormTx *gorm.DB) error {
+			q := gormTx.Table((&models.Event{}).TableName()).Where("chain_id = ? AND height = ? AND origin = ?", db.ChainID, height, origin)
+			if txHash != nil {
+				q = q.Where("tx_hash = ?", *txHash)
+			} else {
+				q = q.Where("tx_hash IS NULL")
+			}
+			if err := q.Delete(&models.Event{}).Error; err != nil {
+				return err
+			}
+
+			return gormTx.Table((&models.Event{}).TableName()).CreateInBatches(rows, eventsBatchSize).Error
+		})
+	})
+}
+
+// eventsBlockOrder sorts the events of a block in the order they were emitted, whatever the order they were saved in
+const eventsBlockOrder = "CASE origin WHEN 'begin_block' THEN 0 WHEN 'tx' THEN 1 ELSE 2 END, tx_index ASC, event_index ASC"
+
+// ListEventsByHeight implements database.Database
+func (db *Impl) ListEventsByHeight(ctx context.Context, height uint64) ([]*models.Event, error) {
+	events := make([]*models.Event, 0)
+	err := db.chainTable(ctx, &models.Event{}).
+		Where("height = ?", height).
+		Order(eventsBlockOrder).
+		Find(&events).Error
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// commitSigsBatchSize is the max number of commit signature rows inserted by a single statement,
+// keeping large validator sets below the max_allowed_packet limit of MySQL
+const commitSigsBatchSize = 500
+
+// SaveCommitSignatures implements database.Database
+func (db *Impl) SaveCommitSignatures(ctx context.Context, signatures []*types.CommitSig) error {
+	if len(signatures) == 0 {
+		return nil
+	}
+
+	commitSigs := make([]*models.CommitSig, len(signatures))
+	for i, sig := range signatures {
+		commitSigs[i] = &models.CommitSig{
+			ChainID:          db.ChainID,
+			ValidatorAddress: sig.ValidatorAddress,
+			Height:           uint64(sig.Height),
+			Timestamp:        sig.Timestamp,
+			VotingPower:      sig.VotingPower,
+			ProposerPriority: sig.ProposerPriority,
+		}
+	}
+
+	return db.retry(ctx, func() error {
+		return db.withContext(ctx).Table((&models.CommitSig{}).TableName()).Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "chain_id"}, {Name: "validator_address"}, {Name: "height"}},
+			DoNothing: true,
+		}).CreateInBatches(commitSigs, commitSigsBatchSize).Error
+	})
+}
+
+// SaveValidators implements database.Database
+func (db *Impl) SaveValidators(ctx context.Context, validators []*models.Validator) error {
+	if len(validators) == 0 {
+		return nil
+	}
+
+	return db.retry(ctx, func() error {
+		return db.withContext(ctx).Table((&models.Validator{}).TableName()).Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "consensus_address"}},
+			DoNothing: true,
+		}).CreateInBatches(validators, db.upsertBatchSize()).Error
+	})
+}
+
+// GetValidatorByConsAddr implements database.Database
+func (db *Impl) GetValidatorByConsAddr(ctx context.Context, addr string) (*models.Validator, error) {
+	// Consensus addresses are accepted either hex encoded or as bech32 strings, in any case
+	consAddr, err := common.ParseAddress(addr)
+	if err != nil {
+		bz, err := sdk.ConsAddressFromBech32(strings.ToLower(addr))
+		if err != nil {
+			return nil, fmt.Errorf("invalid consensus address %s: %s", addr, err)
+		}
+		consAddr = common.BytesToAddress(bz)
+	}
+
+	var validator models.Validator
+	err = db.withContext(ctx).Table((&models.Validator{}).TableName()).
+		Where("consensus_address = ?", consAddr).
+		Take(&validator).Error
+	if err != nil {
+		if errIsNotFound(err) {
+			return nil, ErrValidatorNotFound
+		}
+		return nil, err
+	}
+	return &validator, nil
+}
+
+func (db *Impl) SaveBucket(ctx context.Context, bucket *models.Bucket) error {
+	bucket.ChainID = db.ChainID
+	return db.retry(ctx, func() error {
+		return db.withContext(ctx).Table(bucket.TableName()).Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "chain_id"}, {Name: "bucket_id"}},
+			UpdateAll: true,
+		}).Create(bucket).Error
+	})
+}
+
+// MultiSaveBuckets implements database.Database
+func (db *Impl) MultiSaveBuckets(ctx context.Context, buckets []*models.Bucket) error {
+	if len(buckets) == 0 {
+		return nil
+	}
+
+	buckets = lastByKey(buckets, func(bucket *models.Bucket) common.Hash { return bucket.BucketID })
+	for _, bucket := range buckets {
+		bucket.ChainID = db.ChainID
+	}
+	return db.retry(ctx, func() error {
+		return db.withContext(ctx).Table((&models.Bucket{}).TableName()).Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "chain_id"}, {Name: "bucket_id"}},
+			UpdateAll: true,
+		}).CreateInBatches(buckets, db.upsertBatchSize()).Error
+	})
+}
+
+func (db *Impl) UpdateBucket(ctx context.Context, bucket *models.Bucket, columns ...string) error {
+	return db.retry(ctx, func() error {
+		q := db.chainTable(ctx, &models.Bucket{}).Where("bucket_id = ?", bucket.BucketID)
+		return updates(q, bucket, columns)
+	})
+}
+
+// UpdateBucketAt implements database.Database
+func (db *Impl) UpdateBucketAt(ctx context.Context, height int64, bucket *models.Bucket, columns ...string) (int64, error) {
+	return updateAt(ctx, db, height, bucket, columns, "chain_id = ? AND bucket_id = ?", db.ChainID, bucket.BucketID)
+}
+
+// SaveBucketQuotaHistory implements database.Database
+func (db *Impl) SaveBucketQuotaHistory(ctx context.Context, history *models.BucketQuotaHistory) error {
+	return db.retry(ctx, func() error {
+		return db.withContext(ctx).Transaction(func(gormTx *gorm.DB) error {
+			changes := func() *gorm.DB {
+				return gormTx.Session(&gorm.Session{NewDB: true}).Table(history.TableName()).
+					Where("bucket_id = ?", history.BucketID)
+			}
+
+			var count int64
+			if err := changes().Where("height = ?", history.Height).Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				return nil
+			}
+
+			var previous, next models.BucketQuotaHistory
+			err := changes().Where("height < ?", history.Height).Order("height DESC, id DESC").Take(&previous).Error
+			if err != nil && !errIsNotFound(err) {
+				return err
+			}
+			hasPrevious := err == nil
+			err = changes().Where("height > ?", history.Height).Order("height ASC, id ASC").Take(&next).Error
+			if err != nil && !errIsNotFound(err) {
+				return err
+			}
+			hasNext := err == nil
+
+			switch {
+			case hasPrevious:
+				history.OldChargedReadQuota, history.OldPaymentAddress = previous.NewChargedReadQuota, previous.NewPaymentAddress
+			case hasNext:
+				history.OldChargedReadQuota, history.OldPaymentAddress = next.OldChargedReadQuota, next.OldPaymentAddress
+			}
+			if history.OldChargedReadQuota == history.NewChargedReadQuota && history.OldPaymentAddress == history.NewPaymentAddress {
+				return nil
+			}
+			if err := gormTx.Table(history.TableName()).Create(history).Error; err != nil {
+				return err
+			}
+			if !hasNext {
+				return nil
+			}
+
+			// The change following this one was made from its new values, and changes nothing anymore if it set them
+			if next.NewChargedReadQuota == history.NewChargedReadQuota && next.NewPaymentAddress == history.NewPaymentAddress {
+				return changes().Where("id = ?", next.ID).Delete(&models.BucketQuotaHistory{}).Error
+			}
+			return changes().Where("id = ?", next.ID).Updates(map[string]interface{}{
+				"old_charged_read_quota": history.NewChargedReadQuota,
+				"old_payment_address":    history.NewPaymentAddress,
+			}).Error
+		})
+	})
+}
+
+// ListBucketQuotaHistory implements database.Database
+func (db *Impl) ListBucketQuotaHistory(ctx context.Context, bucketID common.Hash, limit, offset int) ([]*models.BucketQuotaHistory, error) {
+	if offset < 0 {
+		offset = 0
+	}
+
+	history := make([]*models.BucketQuotaHistory, 0)
+	err := db.withContext(ctx).Table((&models.BucketQuotaHistory{}).TableName()).
+		Where("bucket_id = ?", bucketID).
+		Order("height DESC, id DESC").
+		Limit(db.pageLimit(limit)).
+		Offset(offset).
+		Find(&history).Error
+	if err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// SaveBucketDeletionIssue implements database.Database
+func (db *Impl) SaveBucketDeletionIssue(ctx context.Context, issue *models.BucketDeletionIssue) error {
+	return db.retry(ctx, func() error {
+		return db.withContext(ctx).Table(issue.TableName()).Clauses(clause.OnConflict{DoNothing: true}).Create(issue).Error
+	})
+}
+
+// GetBucketByName implements database.Database
+func (db *Impl) GetBucketByName(ctx context.Context, name string) (*models.Bucket, error) {
+	return db.getBucket(ctx, "bucket_name = ?", name, false)
+}
+
+// GetBucketByID implements database.Database
+func (db *Impl) GetBucketByID(ctx context.Context, id common.Hash) (*models.Bucket, error) {
+	return db.getBucket(ctx, "bucket_id = ?", id, false)
+}
+
+// GetBucketByNameWithRemoved implements database.Database
+func (db *Impl) GetBucketByNameWithRemoved(ctx context.Context, name string) (*models.Bucket, error) {
+	return db.getBucket(ctx, "bucket_name = ?", name, true)
+}
+
+// GetBucketByIDWithRemoved implements database.Database
+func (db *Impl) GetBucketByIDWithRemoved(ctx context.Context, id common.Hash) (*models.Bucket, error) {
+	return db.getBucket(ctx, "bucket_id = ?", id, true)
+}
+
+func (db *Impl) getBucket(ctx context.Context, query string, arg interface{}, includeRemoved bool) (*models.Bucket, error) {
+	var bucket models.Bucket
+
+	q := db.chainTable(ctx, &models.Bucket{}).Where(query, arg)
+	if !includeRemoved {
+		q = q.Where("removed IS NOT TRUE")
+	}
+
+	err := q.Take(&bucket).Error
+	if err != nil {
+		if errIsNotFound(err) {
+			return nil, ErrBucketNotFound
+		}
+		return nil, err
+	}
+	return &bucket, nil
+}
+
+func (db *Impl) SaveObject(ctx context.Context, object *models.Object) error {
+	object.ChainID = db.ChainID
+	return db.retry(ctx, func() error {
+		return db.withContext(ctx).Table(object.TableName()).Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "chain_id"}, {Name: "object_id"}},
+			UpdateAll: true,
+		}).Create(object).Error
+	})
+}
+
+// CreateObject implements database.Database
+func (db *Impl) CreateObject(ctx context.Context, object *models.Object) (bool, error) {
+	object.ChainID = db.ChainID
+	var created bool
+	err := db.retry(ctx, func() error {
+		res := db.withContext(ctx).Table(object.TableName()).
+			Clauses(clause.OnConflict{DoNothing: true}).
+			Create(object)
+		created = res.RowsAffected > 0
+		return res.Error
+	})
+	return created, err
+}
+
+// MultiSaveObjects implements database.Database
+func (db *Impl) MultiSaveObjects(ctx context.Context, objects []*models.Object) error {
+	if len(objects) == 0 {
+		return nil
+	}
+
+	objects = lastByKey(objects, func(object *models.Object) common.Hash { return object.ObjectID })
+	for _, object := range objects {
+		object.ChainID = db.ChainID
+	}
+	return db.retry(ctx, func() error {
+		return db.withContext(ctx).Table((&models.Object{}).TableName()).Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "chain_id"}, {Name: "object_id"}},
+			UpdateAll: true,
+		}).CreateInBatches(objects, db.upsertBatchSize()).Error
+	})
+}
+
+func (db *Impl) UpdateObject(ctx context.Context, object *models.Object, columns ...string) error {
+	return db.retry(ctx, func() error {
+		q := db.chainTable(ctx, &models.Object{}).Where("object_id = ?", object.ObjectID)
+		return updates(q, object, columns)
+	})
+}
+
+// UpdateObjectAt implements database.Database
+func (db *Impl) UpdateObjectAt(ctx context.Context, height int64, object *models.Object, columns ...string) (int64, error) {
+	return updateAt(ctx, db, height, object, columns, "chain_id = ? AND object_id = ?", db.ChainID, object.ObjectID)
+}
+
+// AddBucketStats implements database.Database
+func (db *Impl) AddBucketStats(ctx context.Context, bucketID common.Hash, objects, size int64) error {
+	stats := &models.BucketStats{ChainID: db.ChainID, BucketID: bucketID, ObjectCount: objects, StoredSize: size}
+	return db.retry(ctx, func() error {
+		return db.withContext(ctx).Table(stats.TableName()).Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "chain_id"}, {Name: "bucket_id"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"object_count": gorm.Expr("object_count + ?", objects),
+				"stored_size":  gorm.Expr("stored_size + ?", size),
+			}),
+		}).Create(stats).Error
+	})
+}
+
+// GetBucketStats implements database.Database
+func (db *Impl) GetBucketStats(ctx context.Context, bucketID common.Hash) (*models.BucketStats, error) {
+	var stats models.BucketStats
+	err := db.chainTable(ctx, &models.BucketStats{}).Where("bucket_id = ?", bucketID).Take(&stats).Error
+	if errIsNotFound(err) {
+		return &models.BucketStats{ChainID: db.ChainID, BucketID: bucketID}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// ResetBucketStats implements database.Database
+func (db *Impl) ResetBucketStats(ctx context.Context, bucketID common.Hash) error {
+	stats := &models.BucketStats{ChainID: db.ChainID, BucketID: bucketID}
+	return db.retry(ctx, func() error {
+		return db.withContext(ctx).Transaction(func(gormTx *gorm.DB) error {
+			err := gormTx.Table((&models.Object{}).TableName()).
+				Where("chain_id = ? AND bucket_id = ? AND stats_counted IS TRUE", db.ChainID, bucketID).
+				Update("stats_counted", false).Error
+			if err != nil {
+				return err
+			}
+			return gormTx.Table(stats.TableName()).Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "chain_id"}, {Name: "bucket_id"}},
+				DoUpdates: clause.AssignmentColumns([]string{"object_count", "stored_size"}),
+			}).Create(stats).Error
+		})
+	})
+}
+
+// RecalculateBucketStats implements database.Database
+func (db *Impl) RecalculateBucketStats(ctx context.Context, bucketID common.Hash) (*models.BucketStats, error) {
+	stats := &models.BucketStats{ChainID: db.ChainID, BucketID: bucketID}
+	stored := []string{storagetypes.OBJECT_STATUS_SEALED.String(), storagetypes.OBJECT_STATUS_DISCONTINUED.String()}
+	err := db.retry(ctx, func() error {
+		return db.withContext(ctx).Transaction(func(gormTx *gorm.DB) error {
+			objects := func() *gorm.DB {
+				return gormTx.Session(&gorm.Session{NewDB: true}).Table((&models.Object{}).TableName()).
+					Where("chain_id = ? AND bucket_id = ?", db.ChainID, bucketID)
+			}
+			err := objects().
+				Update("stats_counted", gorm.Expr("CASE WHEN removed IS NOT TRUE AND status IN ? THEN TRUE ELSE FALSE END", stored)).Error
+			if err != nil {
+				return err
+			}
+
+			var counts struct {
+				ObjectCount int64
+				StoredSize  int64
+			}
+			// SUM is NULL when there are no objects
+			err = objects().
+				Select("COUNT(*) AS object_count, COALESCE(SUM(payload_size), 0) AS stored_size").
+				Where("stats_counted IS TRUE").
+				Scan(&counts).Error
+			if err != nil {
+				return err
+			}
+			stats.ObjectCount, stats.StoredSize = counts.ObjectCount, counts.StoredSize
+
+			return gormTx.Session(&gorm.Session{NewDB: true}).Table(stats.TableName()).Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "chain_id"}, {Name: "bucket_id"}},
+				DoUpdates: clause.AssignmentColumns([]string{"object_count", "stored_size"}),
+			}).Create(stats).Error
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// BackfillBucketStats implements database.Database
+func (db *Impl) BackfillBucketStats(ctx context.Context) (int64, error) {
+	if db.ReadOnly {
+		return 0, ErrReadOnly
+	}
+
+	// The buckets are paged through while their objects get updated
+	ctx = ReadFromPrimary(ctx)
+	var count int64
+	var afterID uint64
+	for {
+		var buckets []*models.Bucket
+		err := db.chainTable(ctx, &models.Bucket{}).
+			Select("id", "bucket_id").
+			Where("id > ?", afterID).
+			Order("id ASC").
+			Limit(db.pageLimit(0)).
+			Find(&buckets).Error
+		if err != nil {
+			return count, err
+		}
+		if len(buckets) == 0 {
+			return count, nil
+		}
+
+		for _, bucket := range buckets {
+			if _, err := db.RecalculateBucketStats(ctx, bucket.BucketID); err != nil {
+				return count, fmt.Errorf("failed to recalculate the stats of bucket %s: %w", bucket.BucketID.Hex(), err)
+			}
+			count++
+		}
+		afterID = buckets[len(buckets)-1].ID
+	}
+}
+
+func (db *Impl) GetObject(ctx context.Context, objectId common.Hash) (*models.Object, error) {
+	return db.getObject(ctx, objectId, false)
+}
+
+// GetObjectWithRemoved implements database.Database
+func (db *Impl) GetObjectWithRemoved(ctx context.Context, objectId common.Hash) (*models.Object, error) {
+	return db.getObject(ctx, objectId, true)
+}
+
+func (db *Impl) getObject(ctx context.Context, objectId common.Hash, withRemoved bool) (*models.Object, error) {
+	var object models.Object
+
+	q := db.chainTable(ctx, &models.Object{}).Where("object_id = ?", objectId)
+	if !withRemoved {
+		q = q.Where("removed IS NOT TRUE")
+	}
+
+	err := q.Take(&object).Error
+	if err != nil {
+		if errIsNotFound(err) {
+			return nil, ErrObjectNotFound
+		}
+		return nil, err
+	}
+	return &object, nil
+}
+
+// GetObjectChecksums implements database.Database
+func (db *Impl) GetObjectChecksums(ctx context.Context, objectId common.Hash) ([][]byte, error) {
+	var object models.Object
+
+	err := db.chainTable(ctx, &models.Object{}).
+		Select("checksums").
+		Where("object_id = ? AND removed IS NOT TRUE", objectId).
+		Take(&object).Error
+	if err != nil {
+		if errIsNotFound(err) {
+			return nil, ErrObjectNotFound
+		}
+		return nil, err
+	}
+	return object.CheckSums, nil
+}
+
+// CountActiveObjectsByBucket implements database.Database
+func (db *Impl) CountActiveObjectsByBucket(ctx context.Context, bucketID common.Hash) (int64, error) {
+	var count int64
+	err := db.chainTable(ctx, &models.Object{}).
+		Where("bucket_id = ? AND removed IS NOT TRUE", bucketID).
+		Count(&count).Error
+	return count, err
+}
+
+// ListObjectsToGCBefore implements database.Database.
+// The chain deletes the discontinued objects whose delete_at is up to the block time, so the given time is included.
+func (db *Impl) ListObjectsToGCBefore(ctx context.Context, unixTime int64, limit int) ([]*models.Object, error) {
+	objects := make([]*models.Object, 0)
+	err := db.chainTable(ctx, &models.Object{}).
+		Where("status = ? AND removed IS NOT TRUE AND delete_at <= ?", storagetypes.OBJECT_STATUS_DISCONTINUED.String(), unixTime).
+		Order("delete_at ASC, id ASC").
+		Limit(db.pageLimit(limit)).
+		Find(&objects).Error
+	if err != nil {
+		return nil, err
+	}
+	return objects, nil
+}
+
+// ListObjectCopies implements database.Database
+func (db *Impl) ListObjectCopies(ctx context.Context, sourceObjectID common.Hash, limit, offset int) ([]*models.Object, error) {
+	if offset < 0 {
+		offset = 0
+	}
+
+	objects := make([]*models.Object, 0)
+	err := db.chainTable(ctx, &models.Object{}).
+		Where("source_object_id = ? AND removed IS NOT TRUE", sourceObjectID).
+		Order("id ASC").
+		Limit(db.pageLimit(limit)).
+		Offset(offset).
+		Find(&objects).Error
+	if err != nil {
+		return nil, err
+	}
+	return objects, nil
+}
+
+// GetObjectByBucketAndName implements database.Database.
+// The (bucket_name, object_name) pair is unique among live objects on chain, yet the index can't enforce it
+// because removed rows are kept; should duplicates show up anyway, the most recently stored one is returned.
+func (db *Impl) GetObjectByBucketAndName(ctx context.Context, bucketName, objectName string) (*models.Object, error) {
+	var objects []*models.Object
+
+	err := db.chainTable(ctx, &models.Object{}).
+		Where("bucket_name = ? AND object_name = ? AND removed IS NOT TRUE", bucketName, objectName).
+		Order("id DESC").
+		Limit(2).
+		Find(&objects).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if len(objects) == 0 {
+		return nil, ErrObjectNotFound
+	}
+	if len(objects) > 1 {
+		log.Warnw("found duplicated live objects", "bucket_name", bucketName, "object_name", objectName,
+			"object_id", objects[0].ObjectID.Hex(), "duplicated_object_id", objects[1].ObjectID.Hex())
+	}
+	return objects[0], nil
+}
+
+// ListObjectsByBucket implements database.Database
+func (db *Impl) ListObjectsByBucket(ctx context.Context, bucketName string, opts ObjectListOptions) ([]*models.Object, error) {
+	// bucket_name + object_name lets the query walk the idx_bucket_name_object_name index in order
+	q := db.chainTable(ctx, &models.Object{}).Where("bucket_name = ?", bucketName)
+
+	if opts.StartAfter != "" {
+		q = q.Where("object_name > ?", opts.StartAfter)
+	}
+	if opts.Prefix != "" {
+		q = q.Where("object_name LIKE ? ESCAPE '!'", escapeLike(opts.Prefix)+"%")
+	}
+	if !opts.IncludeRemoved {
+		q = q.Where("removed IS NOT TRUE")
+	}
+	if opts.Status != "" {
+		q = q.Where("status = ?", opts.Status)
+	}
+
+	objects := make([]*models.Object, 0)
+	err := q.Order("object_name ASC").Limit(db.pageLimit(opts.Limit)).Find(&objects).Error
+	if err != nil {
+		return nil, err
+	}
+	return objects, nil
+}
+
+// ListObjectsByCreator implements database.Database
+func (db *Impl) ListObjectsByCreator(ctx context.Context, creator common.Address, limit int, startAfterObjectID common.Hash) ([]*models.Object, error) {
+	objects := make([]*models.Object, 0)
+
+	// creator + object_id lets the query walk the idx_creator_object_id index in order
+	err := db.chainTable(ctx, &models.Object{}).
+		Where("creator = ? AND object_id > ? AND removed IS NOT TRUE", creator, startAfterObjectID).
+		Order("object_id ASC").
+		Limit(db.pageLimit(limit)).
+		Find(&objects).Error
+	if err != nil {
+		return nil, err
+	}
+	return objects, nil
+}
+
+func (db *Impl) SaveStreamRecord(ctx context.Context, streamRecord *models.StreamRecord) error {
+	return db.retry(ctx, func() error {
+		return db.withContext(ctx).Table((&models.StreamRecord{}).TableName()).Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "account"}},
+			UpdateAll: true,
+		}).Omit("out_flows_height").Create(streamRecord).Error
+	})
+}
+
+// RepairStreamRecord implements database.Database
+func (db *Impl) RepairStreamRecord(ctx context.Context, streamRecord *models.StreamRecord) error {
+	return db.retry(ctx, func() error {
+		res := db.withContext(ctx).Table(streamRecord.TableName()).
+			Where("account = ? AND crud_timestamp <= ?", streamRecord.Account, streamRecord.CrudTimestamp).
+			Select("*").Omit("id", "out_flows_height").
+			Updates(streamRecord)
+		if res.Error != nil || res.RowsAffected > 0 {
+			return res.Error
+		}
+		return db.withContext(ctx).Table(streamRecord.TableName()).Clauses(clause.OnConflict{DoNothing: true}).
+			Omit("out_flows_height").
+			Create(streamRecord).Error
+	})
+}
+
+// ListStreamRecords implements database.Database
+func (db *Impl) ListStreamRecords(ctx context.Context, afterID uint64, limit int) ([]*models.StreamRecord, error) {
+	streamRecords := make([]*models.StreamRecord, 0)
+	err := db.withContext(ctx).Table((&models.StreamRecord{}).TableName()).
+		Where("id > ?", afterID).
+		Order("id ASC").
+		Limit(db.pageLimit(limit)).
+		Find(&streamRecords).Error
+	if err != nil {
+		return nil, err
+	}
+	return streamRecords, nil
+}
+
+// SaveReconciliationIssues implements database.Database
+func (db *Impl) SaveReconciliationIssues(ctx context.Context, issues []*models.ReconciliationIssue) error {
+	if len(issues) == 0 {
+		return nil
+	}
+	return db.retry(ctx, func() error {
+		return db.withContext(ctx).Table((&models.ReconciliationIssue{}).TableName()).Create(issues).Error
+	})
+}
+
+// ListReconciliationIssues implements database.Database
+func (db *Impl) ListReconciliationIssues(ctx context.Context, account common.Address, limit int) ([]*models.ReconciliationIssue, error) {
+	issues := make([]*models.ReconciliationIssue, 0)
+	err := db.withContext(ctx).Table((&models.ReconciliationIssue{}).TableName()).
+		Where("account = ?", account).
+		Order("id DESC").
+		Limit(db.pageLimit(limit)).
+		Find(&issues).Error
+	if err != nil {
+		return nil, err
+	}
+	return issues, nil
+}
+
+// SaveStreamRecordHistory implements database.Database
+func (db *Impl) SaveStreamRecordHistory(ctx context.Context, history *models.StreamRecordHistory) error {
+	return db.retry(ctx, func() error {
+		return db.withContext(ctx).Table((&models.StreamRecordHistory{}).TableName()).
+			Clauses(clause.OnConflict{DoNothing: true}).
+			Create(history).Error
+	})
+}
+
+// ListStreamRecordHistory implements database.Database
+func (db *Impl) ListStreamRecordHistory(ctx context.Context, account common.Address, fromTime, toTime int64, limit int) ([]*models.StreamRecordHistory, error) {
+	history := make([]*models.StreamRecordHistory, 0)
+	err := db.withContext(ctx).Table((&models.StreamRecordHistory{}).TableName()).
+		Where("account = ? AND crud_timestamp >= ? AND crud_timestamp <= ?", account, fromTime, toTime).
+		Order("crud_timestamp ASC, id ASC").
+		Limit(db.pageLimit(limit)).
+		Find(&history).Error
+	if err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// DeleteStreamRecordHistoryBefore implements database.Database
+func (db *Impl) DeleteStreamRecordHistoryBefore(ctx context.Context, unixTime int64) (int64, error) {
+	var deleted int64
+	err := db.retry(ctx, func() error {
+		res := db.withContext(ctx).Table((&models.StreamRecordHistory{}).TableName()).
+			Where("crud_timestamp < ?", unixTime).
+			Delete(&models.StreamRecordHistory{})
+		deleted = res.RowsAffected
+		return res.Error
+	})
+	return deleted, err
+}
+
+// ReplaceOutFlows implements database.Database
+func (db *Impl) ReplaceOutFlows(ctx context.Context, account common.Address, height int64, outFlows []*models.StreamRecordOutFlow) (bool, error) {
+	var replaced bool
+	err := db.retry(ctx, func() error {
+		replaced = false
+		return db.withContext(ctx).Transaction(func(gormTx *gorm.DB) error {
+			// The stream record remembers the height of the last read, which found no out flow to keep it
+			var stale bool
+			err := gormTx.Raw(fmt.Sprintf(`SELECT EXISTS(SELECT 1 FROM %s WHERE account = ? AND height > ?) OR EXISTS(SELECT 1 FROM %s WHERE account = ? AND out_flows_height > ?);`,
+				(&models.StreamRecordOutFlow{}).TableName(), (&models.StreamRecord{}).TableName()), account, height, account, height).
+				Scan(&stale).Error
+			if err != nil || stale {
+				return err
+			}
+
+			err = gormTx.Table((&models.StreamRecordOutFlow{}).TableName()).
+				Where("account = ?", account).
+				Delete(&models.StreamRecordOutFlow{}).Error
+			if err != nil {
+				return err
+			}
+			if len(outFlows) > 0 {
+				if err := gormTx.Table((&models.StreamRecordOutFlow{}).TableName()).Create(outFlows).Error; err != nil {
+					return err
+				}
+			}
+			err = gormTx.Table((&models.StreamRecord{}).TableName()).
+				Where("account = ?", account).
+				Update("out_flows_height", height).Error
+			replaced = err == nil
+			return err
+		})
+	})
+	return replaced, err
+}
+
+// ListOutFlowsByAccount implements database.Database
+func (db *Impl) ListOutFlowsByAccount(ctx context.Context, account common.Address) ([]*models.StreamRecordOutFlow, error) {
+	outFlows := make([]*models.StreamRecordOutFlow, 0)
+	err := db.withContext(ctx).Table((&models.StreamRecordOutFlow{}).TableName()).
+		Where("account = ?", account).
+		Order("to_address ASC, status ASC").
+		Find(&outFlows).Error
+	if err != nil {
+		return nil, err
+	}
+	return outFlows, nil
+}
+
+// SumInflowBySP implements database.Database
+func (db *Impl) SumInflowBySP(ctx context.Context, spAddress common.Address) (*big.Int, error) {
+	// The rates are stored encoded, so they get summed here rather than by the database
+	var rates []*common.Big
+	err := db.withContext(ctx).Table((&models.StreamRecordOutFlow{}).TableName()).
+		Where("to_address = ? AND status = ?", spAddress, paymenttypes.OUT_FLOW_STATUS_ACTIVE.String()).
+		Pluck("rate", &rates).Error
+	if err != nil {
+		return nil, err
+	}
+
+	sum := new(big.Int)
+	for _, rate := range rates {
+		if rate != nil {
+			sum.Add(sum, rate.Raw())
+		}
+	}
+	return sum, nil
+}
+
+func (db *Impl) SavePaymentAccount(ctx context.Context, paymentAccount *models.PaymentAccount) error {
+	return db.retry(ctx, func() error {
+		return db.withContext(ctx).Table((&models.PaymentAccount{}).TableName()).Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "addr"}},
+			UpdateAll: true,
+		}).Create(paymentAccount).Error
+	})
+}
+
+// CountPaymentAccountsByOwner implements database.Database
+func (db *Impl) CountPaymentAccountsByOwner(ctx context.Context, owner common.Address) (uint64, error) {
+	// Counted from the accounts themselves, the count can't drift from them on reprocessing or rollbacks
+	var count int64
+	err := db.withContext(ctx).Table((&models.PaymentAccount{}).TableName()).
+		Where("owner = ?", owner).
+		Count(&count).Error
+	return uint64(count), err
+}
+
+// SavePaymentAccountRefundable implements database.Database
+func (db *Impl) SavePaymentAccountRefundable(ctx context.Context, refundable *models.PaymentAccountRefundable) error {
+	return db.retry(ctx, func() error {
+		return db.withContext(ctx).Table((&models.PaymentAccountRefundable{}).TableName()).
+			Clauses(clause.OnConflict{DoNothing: true}).
+			Create(refundable).Error
+	})
+}
+
+// ListPaymentAccountRefundable implements database.Database
+func (db *Impl) ListPaymentAccountRefundable(ctx context.Context, addr common.Address) ([]*models.PaymentAccountRefundable, error) {
+	var updates []*models.PaymentAccountRefundable
+	err := db.withContext(ctx).Table((&models.PaymentAccountRefundable{}).TableName()).
+		Where("addr = ?", addr).
+		Order("height ASC").
+		Find(&updates).Error
+	if err != nil {
+		return nil, err
+	}
+
+	history := make([]*models.PaymentAccountRefundable, 0)
+	for _, update := range updates {
+		if len(history) > 0 && history[len(history)-1].Refundable == update.Refundable {
+			continue
+		}
+		history = append(history, update)
+	}
+	return history, nil
+}
+
+// ListPaymentAccountsByOwner implements database.Database
+func (db *Impl) ListPaymentAccountsByOwner(ctx context.Context, owner common.Address, limit, offset int) ([]*models.PaymentAccount, int64, error) {
+	q := db.withContext(ctx).Table((&models.PaymentAccount{}).TableName()).Where("owner = ?", owner).Session(&gorm.Session{})
+
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	accounts := make([]*models.PaymentAccount, 0)
+	if total == 0 {
+		return accounts, 0, nil
+	}
+
+	if offset < 0 {
+		offset = 0
+	}
+	err := q.Order("update_at DESC, id DESC").Limit(db.pageLimit(limit)).Offset(offset).Find(&accounts).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return accounts, total, nil
+}
+
+// SavePaymentLedger implements database.Database
+func (db *Impl) SavePaymentLedger(ctx context.Context, entry *models.PaymentLedger) error {
+	return db.retry(ctx, func() error {
+		return db.withContext(ctx).Table((&models.PaymentLedger{}).TableName()).
+			Clauses(clause.OnConflict{DoNothing: true}).
+			Create(entry).Error
+	})
+}
+
+// ListPaymentLedger implements database.Database
+func (db *Impl) ListPaymentLedger(ctx context.Context, account common.Address, fromHeight, toHeight int64, limit, offset int) ([]*models.PaymentLedger, int64, error) {
+	q := db.withContext(ctx).Table((&models.PaymentLedger{}).TableName()).
+		Where("account = ? AND height >= ? AND height <= ?", account, fromHeight, toHeight).
+		Session(&gorm.Session{})
+
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	entries := make([]*models.PaymentLedger, 0)
+	if total == 0 {
+		return entries, 0, nil
+	}
+
+	if offset < 0 {
+		offset = 0
+	}
+	err := q.Order("height ASC, id ASC").Limit(db.pageLimit(limit)).Offset(offset).Find(&entries).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return entries, total, nil
+}
+
+// GetPaymentAccountByAddr implements database.Database
+func (db *Impl) GetPaymentAccountByAddr(ctx context.Context, addr common.Address) (*models.PaymentAccount, error) {
+	var account models.PaymentAccount
+
+	err := db.withContext(ctx).Table((&models.PaymentAccount{}).TableName()).Where("addr = ?", addr).Take(&account).Error
+	if err != nil {
+		if errIsNotFound(err) {
+			return nil, ErrPaymentAccountNotFound
+		}
+		return nil, err
+	}
+	return &account, nil
+}
+
+// SaveEpoch implements database.Database.
+// The epoch only moves forward: the workers finishing their blocks out of order, an epoch not past the stored one
+// leaves it untouched.
+func (db *Impl) SaveEpoch(ctx context.Context, epoch *models.Epoch) error {
+	epoch.ChainID = db.ChainID
+	return db.retry(ctx, func() error {
+		res := db.chainTable(ctx, epoch).Where("block_height < ?", epoch.BlockHeight).Updates(map[string]interface{}{
+			"block_height": epoch.BlockHeight,
+			"block_hash":   epoch.BlockHash,
+			"update_time":  epoch.UpdateTime,
+		})
+		if res.Error != nil || res.RowsAffected > 0 {
+			return res.Error
+		}
+		return db.withContext(ctx).Table(epoch.TableName()).Clauses(clause.OnConflict{DoNothing: true}).Create(epoch).Error
+	})
+}
+
+func (db *Impl) GetEpoch(ctx context.Context) (*models.Epoch, error) {
+	var epoch models.Epoch
+
+	// No epoch is stored before the first block gets processed, which is reported as the zero epoch
+	err := db.chainTable(ctx, &models.Epoch{}).Take(&epoch).Error
+	if err != nil && !errIsNotFound(err) {
+		return nil, err
+	}
+	return &epoch, nil
+}
+
+func (db *Impl) SavePermission(ctx context.Context, permission *models.Permission) error {
+	// A policy put again for the same principal and resource replaces the previous, possibly deleted, one;
+	// its columns are listed explicitly so that the row is always brought back to life
+	return db.retry(ctx, func() error {
+		return db.withContext(ctx).Table((&models.Permission{}).TableName()).Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "principal_type"}, {Name: "principal_value"}, {Name: "resource_type"}, {Name: "resource_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{
+				"policy_id", "create_timestamp", "update_timestamp", "expiration_time", "removed",
+				"create_at", "create_tx_hash", "update_at", "update_tx_hash",
+			}),
+		}).Create(permission).Error
+	})
+}
+
+func (db *Impl) UpdatePermission(ctx context.Context, permission *models.Permission, columns ...string) error {
+	return db.retry(ctx, func() error {
+		q := db.withContext(ctx).Table((&models.Permission{}).TableName()).Where("policy_id = ?", permission.PolicyID)
+		return updates(q, permission, columns)
+	})
+}
+
+// GetPermissionsByResource implements database.Database
+func (db *Impl) GetPermissionsByResource(ctx context.Context, resourceType string, resourceID common.Hash) ([]*models.Permission, error) {
+	permissions := make([]*models.Permission, 0)
+
+	err := db.withContext(ctx).Table((&models.Permission{}).TableName()).
+		Where("resource_type = ? AND resource_id = ? AND removed IS NOT TRUE", resourceType, resourceID).
+		Order("create_timestamp ASC, id ASC").
+		Find(&permissions).Error
+	if err != nil {
+		return nil, err
+	}
+	return permissions, nil
+}
+
+// GetPermissionByPolicyID implements database.Database
+func (db *Impl) GetPermissionByPolicyID(ctx context.Context, policyID common.Hash) (*models.Permission, error) {
+	var permission models.Permission
+
+	err := db.withContext(ctx).Table((&models.Permission{}).TableName()).
+		Where("policy_id = ? AND removed IS NOT TRUE", policyID).
+		Take(&permission).Error
+	if err != nil {
+		if errIsNotFound(err) {
+			return nil, ErrPermissionNotFound
+		}
+		return nil, err
+	}
+	return &permission, nil
+}
+
+// ExpirePermissionsBefore implements database.Database
+func (db *Impl) ExpirePermissionsBefore(ctx context.Context, height, unixTime int64, limit int) (int64, error) {
+	return db.expireBefore(ctx, (&models.Permission{}).TableName(), height, unixTime, limit)
+}
+
+func (db *Impl) CreateGroup(ctx context.Context, groupMembers []*models.Group) error {
+	return db.retry(ctx, func() error {
+		return db.withContext(ctx).Table((&models.Group{}).TableName()).Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "group_id"}, {Name: "account_id"}},
+			UpdateAll: true,
+		}).Create(groupMembers).Error
+	})
+}
+
+func (db *Impl) UpdateGroup(ctx context.Context, group *models.Group, columns ...string) error {
+	return db.retry(ctx, func() error {
+		q := db.withContext(ctx).Table((&models.Group{}).TableName()).Where("group_id = ? AND account_id = ?", group.GroupID, group.AccountID)
+		return updates(q, group, columns)
+	})
+}
+
+// UpdateGroupAt implements database.Database
+func (db *Impl) UpdateGroupAt(ctx context.Context, height int64, group *models.Group, columns ...string) (int64, error) {
+	return updateAt(ctx, db, height, group, columns, "group_id = ? AND account_id = ?", group.GroupID, group.AccountID)
+}
+
+// DeleteGroup implements database.Database
+func (db *Impl) DeleteGroup(ctx context.Context, groupID common.Hash, updateAt, updateTime int64) error {
+	// Only the removal columns are touched, leaving the member specific ones of every row as they are
+	return db.retry(ctx, func() error {
+		return db.withContext(ctx).Table((&models.Group{}).TableName()).
+			Where("group_id = ?", groupID).
+			Updates(map[string]interface{}{
+				"removed":     true,
+				"update_at":   updateAt,
+				"update_time": updateTime,
+			}).Error
+	})
+}
+
+// DeleteGroupMember implements database.Database
+func (db *Impl) DeleteGroupMember(ctx context.Context, groupID common.Hash, accountID common.Address, updateAt, updateTime int64) error {
+	return db.retry(ctx, func() error {
+		return db.withContext(ctx).Table((&models.Group{}).TableName()).
+			Where("group_id = ? AND account_id = ?", groupID, accountID).
+			Updates(map[string]interface{}{
+				"removed":     true,
+				"update_at":   updateAt,
+				"update_time": updateTime,
+			}).Error
+	})
+}
+
+// GetGroupMembers implements database.Database
+func (db *Impl) GetGroupMembers(ctx context.Context, groupID common.Hash, limit int, startAfterAccount common.Address) ([]*models.Group, error) {
+	members := make([]*models.Group, 0)
+
+	// The group row itself is stored with the zero account id, hence it is always skipped here
+	err := db.withContext(ctx).Table((&models.Group{}).TableName()).
+		Where("group_id = ? AND account_id > ? AND removed IS NOT TRUE", groupID, startAfterAccount).
+		Order("account_id ASC").
+		Limit(db.pageLimit(limit)).
+		Find(&members).Error
+	if err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+// ListGroupsByAccount implements database.Database
+func (db *Impl) ListGroupsByAccount(ctx context.Context, account common.Address) ([]*models.Group, error) {
+	groups := make([]*models.Group, 0)
+
+	err := db.withContext(ctx).Table((&models.Group{}).TableName()).
+		Where("(account_id = ? OR (owner = ? AND account_id = ?)) AND removed IS NOT TRUE", account, account, common.Address{}).
+		Order("id ASC").
+		Find(&groups).Error
+	if err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+func (db *Impl) CreateStorageProvider(ctx context.Context, storageProvider *models.StorageProvider) error {
+	return db.retry(ctx, func() error {
+		return db.withContext(ctx).Table((&models.StorageProvider{}).TableName()).Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "sp_id"}},
+			UpdateAll: true,
+		}).Create(storageProvider).Error
+	})
+}
+
+func (db *Impl) UpdateStorageProvider(ctx context.Context, storageProvider *models.StorageProvider, columns ...string) error {
+	return db.retry(ctx, func() error {
+		q := db.withContext(ctx).Table((&models.StorageProvider{}).TableName()).Where("sp_id = ? ", storageProvider.SpId)
+		return updates(q, storageProvider, columns)
+	})
+}
+
+// UpdateStorageProviderAt implements database.Database
+func (db *Impl) UpdateStorageProviderAt(ctx context.Context, height int64, storageProvider *models.StorageProvider, columns ...string) (int64, error) {
+	return updateAt(ctx, db, height, storageProvider, columns, "sp_id = ?", storageProvider.SpId)
+}
+
+// GetStorageProviderByID implements database.Database
+func (db *Impl) GetStorageProviderByID(ctx context.Context, spID uint32) (*models.StorageProvider, error) {
+	return db.getStorageProvider(ctx, "sp_id = ?", spID)
+}
+
+// GetStorageProviderByOperatorAddress implements database.Database
+func (db *Impl) GetStorageProviderByOperatorAddress(ctx context.Context, operatorAddress string) (*models.StorageProvider, error) {
+	// Addresses are stored as raw bytes, so decoding the hex string makes the lookup case-insensitive
+	addr, err := common.ParseAddress(operatorAddress)
+	if err != nil {
+		return nil, fmt.Errorf("invalid operator address: %w", err)
+	}
+	return db.getStorageProvider(ctx, "operator_address = ?", addr)
+}
+
+func (db *Impl) getStorageProvider(ctx context.Context, query string, arg interface{}) (*models.StorageProvider, error) {
+	var storageProvider models.StorageProvider
+
+	err := db.withContext(ctx).Table((&models.StorageProvider{}).TableName()).Where(query, arg).Take(&storageProvider).Error
+	if err != nil {
+		if errIsNotFound(err) {
+			return nil, ErrStorageProviderNotFound
+		}
+		return nil, err
+	}
+	return &storageProvider, nil
+}
+
+// ListStorageProviders implements database.Database
+func (db *Impl) ListStorageProviders(ctx context.Context, includeRemoved bool) ([]*models.StorageProvider, error) {
+	q := db.withContext(ctx).Table((&models.StorageProvider{}).TableName())
+	if !includeRemoved {
+		q = q.Where("removed IS NOT TRUE")
+	}
+
+	storageProviders := make([]*models.StorageProvider, 0)
+	if err := q.Order("sp_id ASC").Find(&storageProviders).Error; err != nil {
+		return nil, err
+	}
+	return storageProviders, nil
+}
+
+func (db *Impl) MultiSaveStatement(ctx context.Context, statements []*models.Statements) error {
+	return db.retry(ctx, func() error {
+		return db.withContext(ctx).Table((&models.Statements{}).TableName()).Create(statements).Error
+	})
+}
+
+func (db *Impl) RemoveStatements(ctx context.Context, policyID common.Hash, updateAt int64, updateTxHash common.Hash) error {
+	return db.retry(ctx, func() error {
+		return db.withContext(ctx).Table((&models.Statements{}).TableName()).Where("policy_id = ?", policyID).
+			Updates(map[string]interface{}{"removed": true, "update_at": updateAt, "update_tx_hash": updateTxHash}).Error
+	})
+}
+
+// DeleteStatements implements database.Database
+func (db *Impl) DeleteStatements(ctx context.Context, policyID common.Hash) error {
+	return db.retry(ctx, func() error {
+		return db.withContext(ctx).Table((&models.Statements{}).TableName()).Where("policy_id = ?", policyID).Delete(&models.Statements{}).Error
+	})
+}
+
+// GetStatementsByPolicyID implements database.Database
+func (db *Impl) GetStatementsByPolicyID(ctx context.Context, policyID common.Hash, includeRemoved bool) ([]*models.Statements, error) {
+	q := db.withContext(ctx).Table((&models.Statements{}).TableName()).Where("policy_id = ?", policyID)
+	if !includeRemoved {
+		q = q.Where("removed IS NOT TRUE")
+	}
+
+	statements := make([]*models.Statements, 0)
+	if err := q.Order("id ASC").Find(&statements).Error; err != nil {
+		return nil, err
+	}
+	return statements, nil
+}
+
+// ExpireStatementsBefore implements database.Database
+func (db *Impl) ExpireStatementsBefore(ctx context.Context, height, unixTime int64, limit int) (int64, error) {
+	return db.expireBefore(ctx, (&models.Statements{}).TableName(), height, unixTime, limit)
+}
+
+// ConsumeStatementQuota implements database.Database
+func (db *Impl) ConsumeStatementQuota(ctx context.Context, statementID uint64, size uint64) error {
+	return db.retry(ctx, func() error {
+		return db.withContext(ctx).Table((&models.Statements{}).TableName()).Where("id = ?", statementID).
+			Update("consumed_size", gorm.Expr("consumed_size + ?", size)).Error
+	})
+}
+
+// ReleaseStatementQuota implements database.Database
+func (db *Impl) ReleaseStatementQuota(ctx context.Context, statementID uint64, size uint64) error {
+	return db.retry(ctx, func() error {
+		return db.withContext(ctx).Table((&models.Statements{}).TableName()).Where("id = ?", statementID).
+			Update("consumed_size", gorm.Expr("CASE WHEN consumed_size > ? THEN consumed_size - ? ELSE 0 END", size, size)).Error
+	})
+}
+
+// MoveStatementQuota implements database.Database
+func (db *Impl) MoveStatementQuota(ctx context.Context, fromID, toID uint64, consumedSize uint64) error {
+	return db.retry(ctx, func() error {
+		return db.withContext(ctx).Transaction(func(gormTx *gorm.DB) error {
+			if toID != 0 {
+				err := gormTx.Table((&models.Statements{}).TableName()).Where("id = ?", toID).
+					Update("consumed_size", consumedSize).Error
+				if err != nil {
+					return err
+				}
+			}
+			return gormTx.Table((&models.Object{}).TableName()).
+				Where("chain_id = ? AND quota_statement_id = ?", db.ChainID, fromID).
+				Update("quota_statement_id", toID).Error
+		})
+	})
+}
+
+// GetStatementQuotaUsage implements database.Database
+func (db *Impl) GetStatementQuotaUsage(ctx context.Context, policyID common.Hash) ([]*models.Statements, error) {
+	statements := make([]*models.Statements, 0)
+	err := db.withContext(ctx).Table((&models.Statements{}).TableName()).
+		Where("policy_id = ? AND limit_size > 0 AND removed IS NOT TRUE", policyID).
+		Order("id ASC").
+		Find(&statements).Error
 	if err != nil {
-		return 0
+		return nil, err
 	}
+	return statements, nil
+}
 
-	return blockCount
+// SavePermissionHistory implements database.Database
+func (db *Impl) SavePermissionHistory(ctx context.Context, history *models.PermissionHistory) error {
+	return db.retry(ctx, func() error {
+		return db.withContext(ctx).Table((&models.PermissionHistory{}).TableName()).
+			Clauses(clause.OnConflict{DoNothing: true}).
+			Create(history).Error
+	})
 }
 
-// SaveTx implements database.Database
-func (db *Impl) SaveTx(ctx context.Context, blockTimestamp uint64, index int, tx *types.Tx) error {
-	var sigs = make([]string, len(tx.Signatures))
-	for index, sig := range tx.Signatures {
-		sigs[index] = base64.StdEncoding.EncodeToString(sig)
+// ListPermissionHistory implements database.Database
+func (db *Impl) ListPermissionHistory(ctx context.Context, resourceID common.Hash, fromHeight, toHeight uint64) ([]*models.PermissionHistory, error) {
+	history := make([]*models.PermissionHistory, 0)
+	err := db.withContext(ctx).Table((&models.PermissionHistory{}).TableName()).
+		Where("resource_id = ? AND height >= ? AND height <= ?", resourceID, fromHeight, toHeight).
+		Order("height ASC, id ASC").
+		Find(&history).Error
+	if err != nil {
+		return nil, err
 	}
+	return history, nil
+}
 
-	var msgs = make([]string, len(tx.Body.Messages))
-	for index, msg := range tx.Body.Messages {
-		bz, err := db.EncodingConfig.Codec.MarshalJSON(msg)
-		if err != nil {
-			return err
-		}
-		msgs[index] = string(bz)
+// ListPermissionsByPrincipal implements database.Database
+func (db *Impl) ListPermissionsByPrincipal(ctx context.Context, principalType int32, principalValue string) ([]*models.Permission, error) {
+	permissions := make([]*models.Permission, 0)
+	err := db.withContext(ctx).Table((&models.Permission{}).TableName()).
+		Where("principal_type = ? AND principal_value = ? AND removed IS NOT TRUE", principalType, principalValue).
+		Order("id ASC").
+		Find(&permissions).Error
+	if err != nil {
+		return nil, err
 	}
-	msgsBz := fmt.Sprintf("[%s]", strings.Join(msgs, ","))
+	return permissions, nil
+}
 
-	feeBz, err := db.EncodingConfig.Codec.MarshalJSON(tx.AuthInfo.Fee)
+// SaveEffectivePermissions implements database.Database
+func (db *Impl) SaveEffectivePermissions(ctx context.Context, permissions []*models.EffectivePermission) error {
+	if len(permissions) == 0 {
+		return nil
+	}
+	return db.retry(ctx, func() error {
+		return db.withContext(ctx).Table((&models.EffectivePermission{}).TableName()).Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "account"}, {Name: "policy_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{
+				"resource_type", "resource_id", "group_id", "action_value", "deny_action_value", "expiration_time",
+			}),
+		}).Create(permissions).Error
+	})
+}
+
+// DeleteEffectivePermissions implements database.Database
+func (db *Impl) DeleteEffectivePermissions(ctx context.Context, policyID common.Hash) error {
+	return db.retry(ctx, func() error {
+		return db.withContext(ctx).Table((&models.EffectivePermission{}).TableName()).
+			Where("policy_id = ?", policyID).
+			Delete(&models.EffectivePermission{}).Error
+	})
+}
+
+// DeleteGroupEffectivePermissions implements database.Database
+func (db *Impl) DeleteGroupEffectivePermissions(ctx context.Context, groupID common.Hash) error {
+	return db.retry(ctx, func() error {
+		return db.withContext(ctx).Table((&models.EffectivePermission{}).TableName()).
+			Where("group_id = ?", groupID).
+			Delete(&models.EffectivePermission{}).Error
+	})
+}
+
+// DeleteMemberEffectivePermissions implements database.Database
+func (db *Impl) DeleteMemberEffectivePermissions(ctx context.Context, groupID common.Hash, account common.Address) error {
+	return db.retry(ctx, func() error {
+		return db.withContext(ctx).Table((&models.EffectivePermission{}).TableName()).
+			Where("group_id = ? AND account = ?", groupID, account).
+			Delete(&models.EffectivePermission{}).Error
+	})
+}
+
+// ListEffectivePermissionsByAccount implements database.Database
+func (db *Impl) ListEffectivePermissionsByAccount(ctx context.Context, account common.Address, resourceID common.Hash) ([]*models.EffectivePermission, error) {
+	permissions := make([]*models.EffectivePermission, 0)
+	err := db.withContext(ctx).Table((&models.EffectivePermission{}).TableName()).
+		Where("account = ? AND resource_id = ?", account, resourceID).
+		Order("id ASC").
+		Find(&permissions).Error
 	if err != nil {
-		return fmt.Errorf("failed to JSON encode tx fee: %s", err)
+		return nil, err
 	}
+	return permissions, nil
+}
 
-	var sigInfos = make([]string, len(tx.AuthInfo.SignerInfos))
-	for index, info := range tx.AuthInfo.SignerInfos {
-		bz, err := db.EncodingConfig.Codec.MarshalJSON(info)
-		if err != nil {
+// expireBefore marks as removed at the given height at most limit rows of the given table, not removed nor
+// updated at a greater height, whose expiration time is set and before unixTime, and returns the number of
+// rows marked. No tx removes them, hence their update tx hash is cleared. The rows are picked by id first, so
+// that the update only locks them rather than every row scanned.
+func (db *Impl) expireBefore(ctx context.Context, table string, height, unixTime int64, limit int) (int64, error) {
+	var expired int64
+	err := db.retry(ctx, func() error {
+		var ids []uint64
+		err := db.withContext(ctx).Table(table).
+			Where("removed IS NOT TRUE AND expiration_time > 0 AND expiration_time < ? AND update_at <= ?", unixTime, height).
+			Order("id ASC").Limit(limit).
+			Pluck("id", &ids).Error
+		if err != nil || len(ids) == 0 {
+			expired = 0
 			return err
 		}
-		sigInfos[index] = string(bz)
+
+		res := db.withContext(ctx).Table(table).Where("id IN ?", ids).Updates(map[string]interface{}{
+			"removed":        true,
+			"update_at":      height,
+			"update_tx_hash": common.Hash{},
+		})
+		expired = res.RowsAffected
+		return res.Error
+	})
+	return expired, err
+}
+
+func (db *Impl) SaveGVG(ctx context.Context, gvg *models.GlobalVirtualGroup) error {
+	return db.retry(ctx, func() error {
+		return db.withContext(ctx).Table((&models.GlobalVirtualGroup{}).TableName()).Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "global_virtual_group_id"}},
+			UpdateAll: true,
+		}).Create(gvg).Error
+	})
+}
+
+func (db *Impl) UpdateGVG(ctx context.Context, gvg *models.GlobalVirtualGroup, columns ...string) error {
+	return db.retry(ctx, func() error {
+		q := db.withContext(ctx).Table((&models.GlobalVirtualGroup{}).TableName()).Where("global_virtual_group_id = ?", gvg.GlobalVirtualGroupId)
+		return updates(q, gvg, columns)
+	})
+}
+
+// UpdateGVGAt implements database.Database
+func (db *Impl) UpdateGVGAt(ctx context.Context, height int64, gvg *models.GlobalVirtualGroup, columns ...string) (int64, error) {
+	return updateAt(ctx, db, height, gvg, columns, "global_virtual_group_id = ?", gvg.GlobalVirtualGroupId)
+}
+
+// AddGVGStoredSizeAt implements database.Database
+func (db *Impl) AddGVGStoredSizeAt(ctx context.Context, height int64, gvgID uint32, size int64) (int64, error) {
+	var updated int64
+	err := db.retry(ctx, func() error {
+		res := db.withContext(ctx).Table((&models.GlobalVirtualGroup{}).TableName()).
+			Where("global_virtual_group_id = ? AND update_at < ?", gvgID, height).
+			Updates(map[string]interface{}{"stored_size": gorm.Expr("stored_size + ?", size), "update_at": height})
+		updated = res.RowsAffected
+		return res.Error
+	})
+	return updated, err
+}
+
+// GetGVGByID implements database.Database
+func (db *Impl) GetGVGByID(ctx context.Context, gvgID uint32) (*models.GlobalVirtualGroup, error) {
+	var gvg models.GlobalVirtualGroup
+
+	err := db.withContext(ctx).Table((&models.GlobalVirtualGroup{}).TableName()).Where("global_virtual_group_id = ?", gvgID).Take(&gvg).Error
+	if err != nil {
+		if errIsNotFound(err) {
+			return nil, ErrGVGNotFound
+		}
+		return nil, err
 	}
-	sigInfoBz := fmt.Sprintf("[%s]", strings.Join(sigInfos, ","))
+	return &gvg, nil
+}
+
+// ListGVGsByFamilyID implements database.Database
+func (db *Impl) ListGVGsByFamilyID(ctx context.Context, familyID uint32) ([]*models.GlobalVirtualGroup, error) {
+	gvgs := make([]*models.GlobalVirtualGroup, 0)
 
-	logsBz, err := db.EncodingConfig.Amino.MarshalJSON(tx.Logs)
+	err := db.withContext(ctx).Table((&models.GlobalVirtualGroup{}).TableName()).
+		Where("family_id = ? AND removed IS NOT TRUE", familyID).
+		Order("global_virtual_group_id ASC").
+		Find(&gvgs).Error
 	if err != nil {
-		return err
+		return nil, err
 	}
+	return gvgs, nil
+}
 
-	dbTx := &models.Tx{
-		Hash:        common.HexToHash(tx.TxHash),
-		Height:      uint64(tx.Height),
-		TxIndex:     uint32(index),
-		Success:     tx.Successful(),
-		Messages:    msgsBz,
-		Memo:        tx.Body.Memo,
-		Signatures:  strings.Join(sigs, ","),
-		SignerInfos: sigInfoBz,
-		Fee:         string(feeBz),
-		GasWanted:   uint64(tx.GasWanted),
-		GasUsed:     uint64(tx.GasUsed),
-		RawLog:      tx.RawLog,
-		Logs:        string(logsBz),
-		Timestamp:   blockTimestamp,
+// ListGVGsBySPID implements database.Database.
+// The secondary sp ids are stored as a comma separated list, so the query only narrows the candidates
+// down with a LIKE on the textual id and the exact membership is then checked on the decoded list.
+// This keeps the column portable across MySQL and PostgreSQL, and the number of GVGs is small enough
+// for the extra filtering to be cheap.
+func (db *Impl) ListGVGsBySPID(ctx context.Context, spID uint32) ([]*models.GlobalVirtualGroup, error) {
+	var candidates []*models.GlobalVirtualGroup
+
+	err := db.withContext(ctx).Table((&models.GlobalVirtualGroup{}).TableName()).
+		Where("(primary_sp_id = ? OR secondary_sp_ids LIKE ?) AND removed IS NOT TRUE", spID, fmt.Sprintf("%%%d%%", spID)).
+		Order("global_virtual_group_id ASC").
+		Find(&candidates).Error
+	if err != nil {
+		return nil, err
 	}
 
-	err = db.Db.Table((&models.Tx{}).TableName()).Clauses(clause.OnConflict{
-		Columns:   []clause.Column{{Name: "hash"}},
-		UpdateAll: true,
-	}, clause.OnConflict{
-		Columns:   []clause.Column{{Name: "height"}, {Name: "tx_index"}},
-		UpdateAll: true,
-	}).Create(dbTx).Error
-	return err
+	gvgs := make([]*models.GlobalVirtualGroup, 0, len(candidates))
+	for _, gvg := range candidates {
+		if gvg.PrimarySpId == spID || containsUint32(gvg.SecondarySpIds, spID) {
+			gvgs = append(gvgs, gvg)
+		}
+	}
+	return gvgs, nil
 }
 
-// SaveCommitSignatures implements database.Database
-func (db *Impl) SaveCommitSignatures(ctx context.Context, signatures []*types.CommitSig) error {
-	if len(signatures) == 0 {
-		return nil
-	}
+func (db *Impl) SaveLVG(ctx context.Context, lvg *models.LocalVirtualGroup) error {
+	return db.retry(ctx, func() error {
+		return db.withContext(ctx).Table((&models.LocalVirtualGroup{}).TableName()).Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "local_virtual_group_id"}, {Name: "bucket_id"}},
+			UpdateAll: true,
+		}).Create(lvg).Error
+	})
+}
 
-	stmt := `INSERT INTO pre_commit (validator_address, height, timestamp, voting_power, proposer_priority) VALUES `
+func (db *Impl) UpdateLVG(ctx context.Context, lvg *models.LocalVirtualGroup, columns ...string) error {
+	return db.retry(ctx, func() error {
+		q := db.withContext(ctx).Table((&models.LocalVirtualGroup{}).TableName()).Where("local_virtual_group_id = ? and bucket_id = ?", lvg.LocalVirtualGroupId, lvg.BucketID)
+		return updates(q, lvg, columns)
+	})
+}
 
-	var sparams []interface{}
-	for i, sig := range signatures {
-		si := i * 5
+// UpdateLVGAt implements database.Database
+func (db *Impl) UpdateLVGAt(ctx context.Context, height int64, lvg *models.LocalVirtualGroup, columns ...string) (int64, error) {
+	return updateAt(ctx, db, height, lvg, columns, "local_virtual_group_id = ? AND bucket_id = ?", lvg.LocalVirtualGroupId, lvg.BucketID)
+}
+
+// AddLVGStoredSizeAt implements database.Database
+func (db *Impl) AddLVGStoredSizeAt(ctx context.Context, height int64, bucketID common.Hash, lvgID uint32, size int64) (int64, error) {
+	var updated int64
+	err := db.retry(ctx, func() error {
+		res := db.withContext(ctx).Table((&models.LocalVirtualGroup{}).TableName()).
+			Where("local_virtual_group_id = ? AND bucket_id = ? AND update_at < ?", lvgID, bucketID, height).
+			Updates(map[string]interface{}{"stored_size": gorm.Expr("stored_size + ?", size), "update_at": height})
+		updated = res.RowsAffected
+		return res.Error
+	})
+	return updated, err
+}
+
+// RemoveLVGsByBucket implements database.Database
+func (db *Impl) RemoveLVGsByBucket(ctx context.Context, bucketID common.Hash, updateAt int64, updateTxHash common.Hash, updateTime int64) error {
+	return db.retry(ctx, func() error {
+		return db.withContext(ctx).Table((&models.LocalVirtualGroup{}).TableName()).
+			Where("bucket_id = ? AND update_at <= ?", bucketID, updateAt).
+			Updates(map[string]interface{}{"removed": true, "update_at": updateAt, "update_tx_hash": updateTxHash, "update_time": updateTime}).Error
+	})
+}
+
+// ListLVGsByBucket implements database.Database
+func (db *Impl) ListLVGsByBucket(ctx context.Context, bucketID common.Hash) ([]*models.LocalVirtualGroup, error) {
+	lvgs := make([]*models.LocalVirtualGroup, 0)
 
-		stmt += fmt.Sprintf("($%d, $%d, $%d, $%d, $%d),", si+1, si+2, si+3, si+4, si+5)
-		sparams = append(sparams, sig.ValidatorAddress, sig.Height, sig.Timestamp, sig.VotingPower, sig.ProposerPriority)
+	err := db.withContext(ctx).Table((&models.LocalVirtualGroup{}).TableName()).
+		Where("bucket_id = ? AND removed IS NOT TRUE", bucketID).
+		Order("local_virtual_group_id ASC").
+		Find(&lvgs).Error
+	if err != nil {
+		return nil, err
 	}
+	return lvgs, nil
+}
 
-	stmt = stmt[:len(stmt)-1]
-	stmt += " ON CONFLICT (validator_address, timestamp) DO NOTHING"
-	err := db.Db.WithContext(ctx).Exec(stmt, sparams...).Error
-	return err
+// GetLVG implements database.Database
+func (db *Impl) GetLVG(ctx context.Context, bucketID common.Hash, lvgID uint32) (*models.LocalVirtualGroup, error) {
+	var lvg models.LocalVirtualGroup
+
+	err := db.withContext(ctx).Table((&models.LocalVirtualGroup{}).TableName()).
+		Where("local_virtual_group_id = ? AND bucket_id = ?", lvgID, bucketID).
+		Take(&lvg).Error
+	if err != nil {
+		if errIsNotFound(err) {
+			return nil, ErrLVGNotFound
+		}
+		return nil, err
+	}
+	return &lvg, nil
 }
 
-func (db *Impl) SaveBucket(ctx context.Context, bucket *models.Bucket) error {
-	err := db.Db.WithContext(ctx).Table((&models.Bucket{}).TableName()).Clauses(clause.OnConflict{
-		Columns:   []clause.Column{{Name: "bucket_id"}},
-		UpdateAll: true,
-	}).Create(bucket).Error
-	return err
+func (db *Impl) SaveVGF(ctx context.Context, vgf *models.GlobalVirtualGroupFamily) error {
+	return db.retry(ctx, func() error {
+		return db.withContext(ctx).Table((&models.GlobalVirtualGroupFamily{}).TableName()).Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "global_virtual_group_family_id"}},
+			UpdateAll: true,
+		}).Create(vgf).Error
+	})
 }
 
-func (db *Impl) UpdateBucket(ctx context.Context, bucket *models.Bucket) error {
-	err := db.Db.WithContext(ctx).Table((&models.Bucket{}).TableName()).Where("bucket_id = ?", bucket.BucketID).Updates(bucket).Error
-	return err
+func (db *Impl) UpdateVGF(ctx context.Context, vgf *models.GlobalVirtualGroupFamily, columns ...string) error {
+	return db.retry(ctx, func() error {
+		q := db.withContext(ctx).Table((&models.GlobalVirtualGroupFamily{}).TableName()).Where("global_virtual_group_family_id = ?", vgf.GlobalVirtualGroupFamilyId)
+		return updates(q, vgf, columns)
+	})
 }
 
-func (db *Impl) SaveObject(ctx context.Context, object *models.Object) error {
-	err := db.Db.WithContext(ctx).Table((&models.Object{}).TableName()).Clauses(clause.OnConflict{
-		Columns:   []clause.Column{{Name: "object_id"}},
-		UpdateAll: true,
-	}).Create(object).Error
-	return err
+// UpdateVGFAt implements database.Database
+func (db *Impl) UpdateVGFAt(ctx context.Context, height int64, vgf *models.GlobalVirtualGroupFamily, columns ...string) (int64, error) {
+	return updateAt(ctx, db, height, vgf, columns, "global_virtual_group_family_id = ?", vgf.GlobalVirtualGroupFamilyId)
 }
 
-func (db *Impl) UpdateObject(ctx context.Context, object *models.Object) error {
-	err := db.Db.WithContext(ctx).Table((&models.Object{}).TableName()).Where("object_id = ?", object.ObjectID).Updates(object).Error
-	return err
+// GetVGFByID implements database.Database
+func (db *Impl) GetVGFByID(ctx context.Context, familyID uint32) (*models.GlobalVirtualGroupFamily, error) {
+	return db.getVGF(ctx, familyID, false)
 }
 
-func (db *Impl) GetObject(ctx context.Context, objectId common.Hash) (*models.Object, error) {
-	var object models.Object
+// GetVGFByIDWithRemoved implements database.Database
+func (db *Impl) GetVGFByIDWithRemoved(ctx context.Context, familyID uint32) (*models.GlobalVirtualGroupFamily, error) {
+	return db.getVGF(ctx, familyID, true)
+}
 
-	err := db.Db.WithContext(ctx).Where(
-		"object_id = ? AND removed IS NOT TRUE", objectId).Find(&object).Error
-	if err != nil {
+func (db *Impl) getVGF(ctx context.Context, familyID uint32, includeRemoved bool) (*models.GlobalVirtualGroupFamily, error) {
+	// 0 is never assigned to a family and would otherwise match the rows having no family at all
+	if familyID == 0 {
+		return nil, ErrInvalidVGFID
+	}
+
+	q := db.withContext(ctx).Table((&models.GlobalVirtualGroupFamily{}).TableName()).Where("global_virtual_group_family_id = ?", familyID)
+	if !includeRemoved {
+		q = q.Where("removed IS NOT TRUE")
+	}
+
+	var vgf models.GlobalVirtualGroupFamily
+	if err := q.Take(&vgf).Error; err != nil {
+		if errIsNotFound(err) {
+			return nil, ErrVGFNotFound
+		}
 		return nil, err
 	}
-	return &object, nil
+	return &vgf, nil
 }
 
-func (db *Impl) SaveStreamRecord(ctx context.Context, streamRecord *models.StreamRecord) error {
-	err := db.Db.WithContext(ctx).Table((&models.StreamRecord{}).TableName()).Clauses(clause.OnConflict{
-		Columns:   []clause.Column{{Name: "account"}},
-		UpdateAll: true,
-	}).Create(streamRecord).Error
-	return err
+// ListVGFsByPrimarySP implements database.Database
+func (db *Impl) ListVGFsByPrimarySP(ctx context.Context, spID uint32, limit, offset int) ([]*models.GlobalVirtualGroupFamily, error) {
+	return db.listVGFsByPrimarySP(ctx, spID, limit, offset, false)
 }
 
-func (db *Impl) SavePaymentAccount(ctx context.Context, paymentAccount *models.PaymentAccount) error {
-	err := db.Db.WithContext(ctx).Table((&models.PaymentAccount{}).TableName()).Clauses(clause.OnConflict{
-		Columns:   []clause.Column{{Name: "addr"}},
-		UpdateAll: true,
-	}).Create(paymentAccount).Error
-	return err
+// ListVGFsByPrimarySPWithRemoved implements database.Database
+func (db *Impl) ListVGFsByPrimarySPWithRemoved(ctx context.Context, spID uint32, limit, offset int) ([]*models.GlobalVirtualGroupFamily, error) {
+	return db.listVGFsByPrimarySP(ctx, spID, limit, offset, true)
 }
 
-func (db *Impl) SaveEpoch(ctx context.Context, epoch *models.Epoch) error {
-	err := db.Db.Table((&models.Epoch{}).TableName()).Clauses(clause.OnConflict{
-		Columns:   []clause.Column{{Name: "one_row_id"}},
-		DoUpdates: clause.AssignmentColumns([]string{"block_height", "block_hash", "update_time"}),
-	}).Create(epoch).Error
-	return err
+func (db *Impl) listVGFsByPrimarySP(ctx context.Context, spID uint32, limit, offset int, includeRemoved bool) ([]*models.GlobalVirtualGroupFamily, error) {
+	q := db.withContext(ctx).Table((&models.GlobalVirtualGroupFamily{}).TableName()).Where("primary_sp_id = ?", spID)
+	if !includeRemoved {
+		q = q.Where("removed IS NOT TRUE")
+	}
+
+	if offset < 0 {
+		offset = 0
+	}
+
+	vgfs := make([]*models.GlobalVirtualGroupFamily, 0)
+	err := q.Order("global_virtual_group_family_id ASC").Limit(db.pageLimit(limit)).Offset(offset).Find(&vgfs).Error
+	if err != nil {
+		return nil, err
+	}
+	return vgfs, nil
 }
 
-func (db *Impl) GetEpoch(ctx context.Context) (*models.Epoch, error) {
-	var epoch models.Epoch
+// CountObjects implements database.Database
+func (db *Impl) CountObjects(ctx context.Context) (total, sealed, removed int64, err error) {
+	var counts struct {
+		Total   int64
+		Sealed  int64
+		Removed int64
+	}
+
+	// A single scan of the table computes every count; SUM is NULL when there are no objects
+	err = db.chainTable(ctx, &models.Object{}).
+		Select(
+			"COUNT(*) AS total, "+
+				"COALESCE(SUM(CASE WHEN status = ? AND removed IS NOT TRUE THEN 1 ELSE 0 END), 0) AS sealed, "+
+				"COALESCE(SUM(CASE WHEN removed IS TRUE THEN 1 ELSE 0 END), 0) AS removed",
+			storagetypes.OBJECT_STATUS_SEALED.String(),
+		).
+		Scan(&counts).Error
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	return counts.Total, counts.Sealed, counts.Removed, nil
+}
 
-	err := db.Db.Find(&epoch).Error
+// SaveDBStatistics implements database.Database
+func (db *Impl) SaveDBStatistics(ctx context.Context, ds *models.DataStat) error {
+	ds.OneRowId = true
+	return db.retry(ctx, func() error {
+		return db.withContext(ctx).Table((&models.DataStat{}).TableName()).Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "one_row_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{
+				"block_height", "object_total_count", "object_seal_count", "object_del_count", "update_time",
+			}),
+		}).Create(ds).Error
+	})
+}
+
+// GetDataStat implements database.Database
+func (db *Impl) GetDataStat(ctx context.Context) (*models.DataStat, error) {
+	var stat models.DataStat
+
+	err := db.withContext(ctx).Table((&models.DataStat{}).TableName()).Take(&stat).Error
 	if err != nil && !errIsNotFound(err) {
 		return nil, err
 	}
-	return &epoch, nil
+	return &stat, nil
 }
 
-func (db *Impl) SavePermission(ctx context.Context, permission *models.Permission) error {
-	return db.Db.WithContext(ctx).Table((&models.Permission{}).TableName()).Clauses(clause.OnConflict{
-		Columns:   []clause.Column{{Name: "principal_type"}, {Name: "principal_value"}, {Name: "resource_type"}, {Name: "resource_id"}},
-		UpdateAll: true,
-	}).Create(permission).Error
+// SaveFailedBlock implements database.Database
+func (db *Impl) SaveFailedBlock(ctx context.Context, failed *models.FailedBlock) error {
+	failed.ChainID = db.ChainID
+	return db.retry(ctx, func() error {
+		return db.withContext(ctx).Table(failed.TableName()).Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "chain_id"}, {Name: "height"}},
+			DoUpdates: clause.Set{
+				{Column: clause.Column{Name: "module"}, Value: failed.Module},
+				{Column: clause.Column{Name: "error"}, Value: failed.Error},
+				{Column: clause.Column{Name: "retry_count"}, Value: gorm.Expr(failed.TableName() + ".retry_count + 1")},
+			},
+		}).Create(failed).Error
+	})
 }
 
-func (db *Impl) UpdatePermission(ctx context.Context, permission *models.Permission) error {
-	return db.Db.WithContext(ctx).Table((&models.Permission{}).TableName()).Where("policy_id = ?", permission.PolicyID).Updates(permission).Error
+// ListFailedBlocks implements database.Database
+func (db *Impl) ListFailedBlocks(ctx context.Context, limit, offset int) ([]*models.FailedBlock, error) {
+	var failed []*models.FailedBlock
+
+	err := db.chainTable(ctx, &models.FailedBlock{}).
+		Order("height ASC").
+		Limit(db.pageLimit(limit)).
+		Offset(offset).
+		Find(&failed).Error
+	if err != nil {
+		return nil, err
+	}
+	return failed, nil
 }
 
-func (db *Impl) CreateGroup(ctx context.Context, groupMembers []*models.Group) error {
-	err := db.Db.WithContext(ctx).Table((&models.Group{}).TableName()).Clauses(clause.OnConflict{
-		Columns:   []clause.Column{{Name: "group_id"}, {Name: "account_id"}},
-		UpdateAll: true,
-	}).Create(groupMembers).Error
-	return err
+// DeleteFailedBlock implements database.Database
+func (db *Impl) DeleteFailedBlock(ctx context.Context, height uint64) error {
+	return db.retry(ctx, func() error {
+		return db.chainTable(ctx, &models.FailedBlock{}).Where("height = ?", height).Delete(&models.FailedBlock{}).Error
+	})
 }
 
-func (db *Impl) UpdateGroup(ctx context.Context, group *models.Group) error {
-	return db.Db.WithContext(ctx).Table((&models.Group{}).TableName()).Where("group_id = ? AND account_id = ?", group.GroupID, group.AccountID).Updates(group).Error
+// SaveBlockProgress implements database.Database
+func (db *Impl) SaveBlockProgress(ctx context.Context, progress *models.BlockProgress) error {
+	progress.ChainID = db.ChainID
+	return db.retry(ctx, func() error {
+		return db.withContext(ctx).Table(progress.TableName()).Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "chain_id"}, {Name: "height"}},
+			DoUpdates: clause.AssignmentColumns([]string{"block_hash", "tx_index", "event_index", "update_time"}),
+		}).Create(progress).Error
+	})
 }
 
-func (db *Impl) DeleteGroup(ctx context.Context, group *models.Group) error {
-	return db.Db.WithContext(ctx).Table((&models.Group{}).TableName()).Where("group_id = ?", group.GroupID).Updates(group).Error
+// GetBlockProgress implements database.Database
+func (db *Impl) GetBlockProgress(ctx context.Context, height uint64) (*models.BlockProgress, error) {
+	var progress models.BlockProgress
+	err := db.chainTable(ctx, &models.BlockProgress{}).Where("height = ?", height).Take(&progress).Error
+	if err != nil {
+		if errIsNotFound(err) {
+			return nil, ErrBlockProgressNotFound
+		}
+		return nil, err
+	}
+	return &progress, nil
 }
 
-func (db *Impl) CreateStorageProvider(ctx context.Context, storageProvider *models.StorageProvider) error {
-	err := db.Db.WithContext(ctx).Table((&models.StorageProvider{}).TableName()).Clauses(clause.OnConflict{
-		Columns:   []clause.Column{{Name: "sp_id"}},
-		UpdateAll: true,
-	}).Create(storageProvider).Error
-	return err
+// DeleteBlockProgress implements database.Database
+func (db *Impl) DeleteBlockProgress(ctx context.Context, height uint64) error {
+	return db.retry(ctx, func() error {
+		return db.chainTable(ctx, &models.BlockProgress{}).Where("height = ?", height).Delete(&models.BlockProgress{}).Error
+	})
 }
 
-func (db *Impl) UpdateStorageProvider(ctx context.Context, storageProvider *models.StorageProvider) error {
-	return db.Db.WithContext(ctx).Table((&models.StorageProvider{}).TableName()).Where("sp_id = ? ", storageProvider.SpId).Updates(storageProvider).Error
+// ListBlockProgressHeights implements database.Database
+func (db *Impl) ListBlockProgressHeights(ctx context.Context) ([]uint64, error) {
+	var heights []uint64
+	err := db.chainTable(ctx, &models.BlockProgress{}).Order("height ASC").Pluck("height", &heights).Error
+	if err != nil {
+		return nil, err
+	}
+	return heights, nil
 }
 
-func (db *Impl) MultiSaveStatement(ctx context.Context, statements []*models.Statements) error {
-	return db.Db.WithContext(ctx).Table((&models.Statements{}).TableName()).Create(statements).Error
+func (db *Impl) Begin(ctx context.Context) *Impl {
+	return db.BeginWithOptions(ctx, nil)
 }
 
-func (db *Impl) RemoveStatements(ctx context.Context, policyID common.Hash) error {
-	return db.Db.WithContext(ctx).Table((&models.Statements{}).TableName()).Where("policy_id = ?", policyID).Update("removed", true).Error
+// BeginWithOptions implements database.Database
+func (db *Impl) BeginWithOptions(ctx context.Context, opts *sql.TxOptions) *Impl {
+	readOnly := opts != nil && opts.ReadOnly
+
+	// A transaction can't be opened inside another one, so a savepoint of the latter stands for it
+	if tx := db.active(ctx); tx.inTx {
+		return tx.savePoint(ctx, readOnly)
+	}
+
+	tx := db.withDb(db.Db.WithContext(ctx).Begin(opts))
+	tx.inTx = true
+	tx.readOnly = readOnly
+	return tx
 }
 
-func (db *Impl) SaveGVG(ctx context.Context, gvg *models.GlobalVirtualGroup) error {
-	err := db.Db.WithContext(ctx).Table((&models.GlobalVirtualGroup{}).TableName()).Clauses(clause.OnConflict{
-		Columns:   []clause.Column{{Name: "global_virtual_group_id"}},
-		UpdateAll: true,
-	}).Create(gvg).Error
-	return err
+// savePoint returns a copy of this transaction whose Commit and Rollback only apply to the writes made
+// after a new savepoint
+func (db *Impl) savePoint(ctx context.Context, readOnly bool) *Impl {
+	sp := db.withDb(db.Db.WithContext(ctx))
+	sp.readOnly = db.readOnly || readOnly
+	sp.txDepth = db.txDepth + 1
+	sp.savePointName = fmt.Sprintf("juno_sp_%d", savePointSeq.Add(1))
+	sp.Db.SavePoint(sp.savePointName)
+	return sp
 }
 
-func (db *Impl) UpdateGVG(ctx context.Context, gvg *models.GlobalVirtualGroup) error {
-	err := db.Db.WithContext(ctx).Table((&models.GlobalVirtualGroup{}).TableName()).Where("global_virtual_group_id = ?", gvg.GlobalVirtualGroupId).Updates(gvg).Error
-	return err
+// active returns the Impl the queries made with ctx run through, which is the transaction carried by ctx
+// unless this Impl is a transaction itself
+func (db *Impl) active(ctx context.Context) *Impl {
+	if !db.inTx {
+		if tx, ok := TxFromContext(ctx); ok {
+			return tx
+		}
+	}
+	return db
 }
 
-func (db *Impl) SaveLVG(ctx context.Context, lvg *models.LocalVirtualGroup) error {
-	err := db.Db.WithContext(ctx).Table((&models.LocalVirtualGroup{}).TableName()).Clauses(clause.OnConflict{
-		Columns:   []clause.Column{{Name: "local_virtual_group_id"}},
-		UpdateAll: true,
-	}).Create(lvg).Error
-	return err
+// chainTable returns a query on the table of the given model restricted to the rows of ChainID
+func (db *Impl) chainTable(ctx context.Context, model schema.Tabler) *gorm.DB {
+	return db.withContext(ctx).Table(model.TableName()).Where("chain_id = ?", db.ChainID)
 }
 
-func (db *Impl) UpdateLVG(ctx context.Context, lvg *models.LocalVirtualGroup) error {
-	err := db.Db.WithContext(ctx).Table((&models.LocalVirtualGroup{}).TableName()).Where("local_virtual_group_id = ? and bucket_id = ?", lvg.LocalVirtualGroupId, lvg.BucketID).Updates(lvg).Error
-	return err
+// withContext returns the gorm handle the queries made with ctx run through
+func (db *Impl) withContext(ctx context.Context) *gorm.DB {
+	q := db.active(ctx).Db.WithContext(ctx)
+	if readsFromPrimary(ctx) {
+		return onPrimary(q)
+	}
+	return q
 }
 
-func (db *Impl) SaveVGF(ctx context.Context, vgf *models.GlobalVirtualGroupFamily) error {
-	err := db.Db.WithContext(ctx).Table((&models.GlobalVirtualGroupFamily{}).TableName()).Clauses(clause.OnConflict{
-		Columns:   []clause.Column{{Name: "global_virtual_group_family_id"}},
-		UpdateAll: true,
-	}).Create(vgf).Error
-	return err
+// withDb returns a copy of this Impl that runs its queries through the given gorm handle,
+// keeping every other field so that nothing gets lost when new ones are added
+func (db *Impl) withDb(gormDb *gorm.DB) *Impl {
+	clone := *db
+	clone.Db = gormDb
+	return &clone
 }
 
-func (db *Impl) UpdateVGF(ctx context.Context, vgf *models.GlobalVirtualGroupFamily) error {
-	err := db.Db.WithContext(ctx).Table((&models.GlobalVirtualGroupFamily{}).TableName()).Where("global_virtual_group_family_id = ?", vgf.GlobalVirtualGroupFamilyId).Updates(vgf).Error
-	return err
+// Rollback implements database.Database
+func (db *Impl) Rollback() error {
+	if db.finished {
+		return ErrTxFinished
+	}
+	db.finished = true
+	return db.rollback()
 }
 
-func (db *Impl) SaveDBStatistics(ctx context.Context, ds *models.DataStat) error {
-	return nil
+// rollback undoes the writes of this transaction, or the ones made since its savepoint
+func (db *Impl) rollback() error {
+	if db.txDepth == 0 {
+		return db.Db.Rollback().Error
+	}
+
+	// Rolling back keeps the savepoint, which is released so that it can't be used anymore, like a transaction
+	if err := db.Db.RollbackTo(db.savePointName).Error; err != nil {
+		return err
+	}
+	return db.releaseSavePoint()
 }
 
-func (db *Impl) Begin(ctx context.Context) *Impl {
-	return &Impl{
-		Db: db.Db.WithContext(ctx).Begin(),
+// Commit implements database.Database
+func (db *Impl) Commit() error {
+	if db.finished {
+		return ErrTxFinished
 	}
+	db.finished = true
+
+	// The writes made since a savepoint get committed along with the transaction it belongs to
+	var err error
+	if db.txDepth > 0 {
+		err = db.releaseSavePoint()
+	} else {
+		err = db.Db.Commit().Error
+	}
+	if err == nil {
+		return nil
+	}
+
+	// Depending on the driver a failed commit may or may not have ended the transaction already
+	if rbErr := db.rollback(); rbErr != nil && !errors.Is(rbErr, sql.ErrTxDone) {
+		return errors.Join(err, fmt.Errorf("failed to rollback transaction: %w", rbErr))
+	}
+	return err
 }
 
-func (db *Impl) Rollback() {
-	db.Db.Rollback()
+func (db *Impl) releaseSavePoint() error {
+	return db.Db.Exec("RELEASE SAVEPOINT " + db.savePointName).Error
 }
 
-func (db *Impl) Commit() error {
-	return db.Db.Commit().Error
+// Ping implements database.Database
+func (db *Impl) Ping(ctx context.Context) error {
+	sqlDB, err := db.Db.DB()
+	if err != nil {
+		return err
+	}
+	if err := sqlDB.PingContext(ctx); err != nil {
+		return err
+	}
+
+	// A reachable server may still refuse to run queries, e.g. when the configured schema doesn't exist
+	var one int
+	return onPrimary(db.Db.WithContext(ctx)).Raw("SELECT 1").Scan(&one).Error
 }
 
-// Close implements database.Database
+// Close implements database.Database.
+// Closing an already closed database is a no-op.
 func (db *Impl) Close() {
-	var err error
+	if db.Db == nil {
+		return
+	}
+
+	sqlDB, err := db.Db.DB()
 	if err != nil {
+		log.Errorw("error while getting connection to close", "err", err)
+		return
+	}
+
+	// sql.DB.Close is idempotent, so there's no need to track whether it has already been called
+	if err := sqlDB.Close(); err != nil {
 		log.Errorw("error while closing connection", "err", err)
 	}
+	for _, replica := range db.Replicas {
+		if err := replica.Close(); err != nil {
+			log.Errorw("error while closing read replica connection", "err", err)
+		}
+	}
 }
 
 // -------------------------------------------------------------------------------------------------------------------
 
 // GetLastPruned implements database.PruningDb
-func (db *Impl) GetLastPruned() (int64, error) {
-	var lastPrunedHeight int64
-	err := db.Db.Raw(`SELECT coalesce(MAX(last_pruned_height),0) FROM pruning LIMIT 1;`).Scan(&lastPrunedHeight).Error
-	return lastPrunedHeight, err
+func (db *Impl) GetLastPruned(ctx context.Context) (int64, error) {
+	var pruning models.Pruning
+	err := db.withContext(ctx).Table((&models.Pruning{}).TableName()).Take(&pruning).Error
+	if errIsNotFound(err) {
+		return 0, nil
+	}
+	return pruning.LastPrunedHeight, err
 }
 
 // StoreLastPruned implements database.PruningDb
-func (db *Impl) StoreLastPruned(height int64) error {
-	err := db.Db.Exec(`DELETE FROM pruning`).Error
-	if err != nil {
-		return err
+func (db *Impl) StoreLastPruned(ctx context.Context, height int64) error {
+	return db.retry(ctx, func() error {
+		return db.withContext(ctx).Table((&models.Pruning{}).TableName()).Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "one_row_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"last_pruned_height"}),
+		}).Create(&models.Pruning{OneRowId: true, LastPrunedHeight: height}).Error
+	})
+}
+
+// Prune implements database.PruningDb.
+// The data of other chains is kept.
+func (db *Impl) Prune(ctx context.Context, height int64) error {
+	return db.retry(ctx, func() error {
+		return db.withContext(ctx).Transaction(func(gormTx *gorm.DB) error {
+			err := gormTx.Table((&models.CommitSig{}).TableName()).
+				Where("chain_id = ? AND height = ?", db.ChainID, height).
+				Delete(&models.CommitSig{}).Error
+			if err != nil {
+				return err
+			}
+
+			txHashes := gormTx.Session(&gorm.Session{NewDB: true}).Table((&models.Tx{}).TableName()).
+				Select("hash").
+				Where("chain_id = ? AND height = ?", db.ChainID, height)
+			err = gormTx.Table((&models.Message{}).TableName()).
+				Where("height = ? AND tx_hash IN (?)", height, txHashes).
+				Delete(&models.Message{}).Error
+			if err != nil {
+				return err
+			}
+
+			err = gormTx.Table((&models.Tx{}).TableName()).
+				Where("chain_id = ? AND height = ?", db.ChainID, height).
+				Delete(&models.Tx{}).Error
+			if err != nil {
+				return err
+			}
+
+			return gormTx.Table((&models.BlockResult{}).TableName()).
+				Where("chain_id = ? AND block_height = ?", db.ChainID, height).
+				Delete(&models.BlockResult{}).Error
+		})
+	})
+}
+
+// updates writes the given model into the rows matched by q. GORM skips zero-valued fields when updating
+// from a struct, so the columns that have to be written regardless of their value must be selected explicitly
+func updates(q *gorm.DB, model interface{}, columns []string) error {
+	if len(columns) > 0 {
+		q = q.Select(columns)
 	}
+	return q.Updates(model).Error
+}
 
-	err = db.Db.Exec(`INSERT INTO pruning (last_pruned_height) VALUES ($1)`, height).Error
-	return err
+// updateAt behaves like updates on the rows of the table of model matching the given conditions, leaving untouched
+// the ones updated at a height greater than the given one, and returns the number of rows written
+func updateAt[T schema.Tabler](ctx context.Context, db *Impl, height int64, model T, columns []string, query string, args ...interface{}) (int64, error) {
+	var updated int64
+	err := db.retry(ctx, func() error {
+		q := db.withContext(ctx).Table(model.TableName()).Where(query, args...).Where("update_at <= ?", height)
+		if len(columns) > 0 {
+			q = q.Select(columns)
+		}
+		res := q.Updates(model)
+		updated = res.RowsAffected
+		return res.Error
+	})
+	return updated, err
 }
 
-// Prune implements database.PruningDb
-func (db *Impl) Prune(height int64) error {
-	err := db.Db.Exec(`DELETE FROM pre_commit WHERE height = $1`, height).Error
-	if err != nil {
-		return err
+func containsUint32(values []uint32, value uint32) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
 	}
+	return false
+}
 
-	err = db.Db.Exec(`
-DELETE FROM message 
-USING transaction 
-WHERE message.transaction_hash = transaction.hash AND transaction.height = $1
-`, height).Error
-	return err
+// escapeLike escapes the LIKE wildcards inside the given value, using '!' as the escape character
+func escapeLike(value string) string {
+	return likeEscaper.Replace(value)
 }
 
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
 func errIsNotFound(err error) bool {
 	return errors.Is(err, sql.ErrNoRows) || errors.Is(err, gorm.ErrRecordNotFound)
 }
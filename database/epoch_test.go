@@ -0,0 +1,41 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/forbole/juno/v4/common"
+	"github.com/forbole/juno/v4/models"
+)
+
+func TestGetEpoch(t *testing.T) {
+	ctx := context.Background()
+	db := newTestImpl(t, &models.Epoch{})
+
+	epoch, err := db.GetEpoch(ctx)
+	require.NoError(t, err)
+	require.Zero(t, epoch.BlockHeight)
+
+	for _, height := range []int64{10, 11} {
+		require.NoError(t, db.SaveEpoch(ctx, &models.Epoch{
+			OneRowId:    true,
+			BlockHeight: height,
+			BlockHash:   common.HexToHash("0x01"),
+			UpdateTime:  height,
+		}))
+	}
+
+	epoch, err = db.GetEpoch(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(11), epoch.BlockHeight)
+	require.Equal(t, common.HexToHash("0x01"), epoch.BlockHash)
+
+	// A worker finishing an earlier block late doesn't move the epoch back
+	require.NoError(t, db.SaveEpoch(ctx, &models.Epoch{OneRowId: true, BlockHeight: 9, BlockHash: common.HexToHash("0x02")}))
+	epoch, err = db.GetEpoch(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(11), epoch.BlockHeight)
+	require.Equal(t, common.HexToHash("0x01"), epoch.BlockHash)
+}
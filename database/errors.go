@@ -0,0 +1,169 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrNotFound is returned when the requested record is not stored in the database.
+	// Every more specific not found error below wraps it.
+	ErrNotFound = fmt.Errorf("not found: %w", gorm.ErrRecordNotFound)
+
+	// ErrDuplicateKey is returned when a write violates a unique constraint
+	ErrDuplicateKey = errors.New("duplicate key")
+
+	// ErrSerialization is returned when a write fails because of a deadlock, a lock wait timeout or a
+	// serialization failure; the same operation is expected to succeed when retried
+	ErrSerialization = errors.New("serialization failure")
+
+	// ErrTxRetryable is returned when a write inside a transaction fails with ErrSerialization.
+	// The transaction can't be used anymore: it must be rolled back and retried from the start.
+	ErrTxRetryable = errors.New("transaction must be retried")
+
+	// ErrReadOnly is returned when a write is made through a database opened in read-only mode
+	ErrReadOnly = errors.New("write in read-only mode")
+
+	// ErrReadOnlyTx is returned when a write is made through a read-only transaction
+	ErrReadOnlyTx = errors.New("write in a read-only transaction")
+
+	// ErrTxFinished is returned when committing or rolling back a transaction which has already been
+	// committed or rolled back
+	ErrTxFinished = errors.New("transaction already finished")
+
+	// ErrChainIDNotBackfilled is returned when rows were stored before the chain id of each of them was recorded
+	ErrChainIDNotBackfilled = errors.New("has rows without chain id, run the chain-id migration first")
+
+	// ErrRollbackLosesState is returned when rolling back would delete module rows written before the height,
+	// whose previous state isn't kept: the database has to be indexed again from scratch instead
+	ErrRollbackLosesState = errors.New("rollback would lose the state of rows written before the height")
+
+	// ErrBlockNotFound is returned when the requested block is not stored in the database
+	ErrBlockNotFound = fmt.Errorf("block %w", ErrNotFound)
+
+	// ErrTxNotFound is returned when the requested transaction is not stored in the database
+	ErrTxNotFound = fmt.Errorf("tx %w", ErrNotFound)
+
+	// ErrValidatorNotFound is returned when the requested validator is not stored in the database
+	ErrValidatorNotFound = fmt.Errorf("validator %w", ErrNotFound)
+
+	// ErrBlockResultNotFound is returned when the results of the requested block are not stored in the database
+	ErrBlockResultNotFound = fmt.Errorf("block result %w", ErrNotFound)
+
+	// ErrBlockProgressNotFound is returned when no progress is recorded for the requested height
+	ErrBlockProgressNotFound = fmt.Errorf("block progress %w", ErrNotFound)
+
+	// ErrBucketNotFound is returned when the requested bucket does not exist or has been removed
+	ErrBucketNotFound = fmt.Errorf("bucket %w", ErrNotFound)
+
+	// ErrObjectNotFound is returned when the requested object does not exist or has been removed
+	ErrObjectNotFound = fmt.Errorf("object %w", ErrNotFound)
+
+	// ErrPermissionNotFound is returned when the requested policy does not exist or has been deleted
+	ErrPermissionNotFound = fmt.Errorf("permission %w", ErrNotFound)
+
+	// ErrPaymentAccountNotFound is returned when the requested payment account is not stored in the database
+	ErrPaymentAccountNotFound = fmt.Errorf("payment account %w", ErrNotFound)
+
+	// ErrStorageProviderNotFound is returned when the requested storage provider is not stored in the database
+	ErrStorageProviderNotFound = fmt.Errorf("storage provider %w", ErrNotFound)
+
+	// ErrGVGNotFound is returned when the requested global virtual group is not stored in the database
+	ErrGVGNotFound = fmt.Errorf("global virtual group %w", ErrNotFound)
+
+	// ErrLVGNotFound is returned when the requested local virtual group is not stored in the database
+	ErrLVGNotFound = fmt.Errorf("local virtual group %w", ErrNotFound)
+
+	// ErrVGFNotFound is returned when the requested global virtual group family does not exist or has been removed
+	ErrVGFNotFound = fmt.Errorf("global virtual group family %w", ErrNotFound)
+
+	// ErrInvalidVGFID is returned when looking up the global virtual group family having id 0
+	ErrInvalidVGFID = errors.New("invalid global virtual group family id 0")
+)
+
+// MySQL error numbers and Postgres SQLSTATE codes translated by translateError
+const (
+	mysqlErrDuplicateEntry   = 1062
+	mysqlErrLockWaitTimeout  = 1205
+	mysqlErrLockDeadlock     = 1213
+	pgErrUniqueViolation     = "23505"
+	pgErrSerializationFailed = "40001"
+	pgErrDeadlockDetected    = "40P01"
+)
+
+// translateError wraps the given driver error into the matching sentinel error of this package,
+// keeping the original one in the chain. Errors having no matching sentinel are returned as they are.
+func translateError(err error) error {
+	if err == nil || errors.Is(err, ErrNotFound) || errors.Is(err, ErrDuplicateKey) || errors.Is(err, ErrSerialization) {
+		return err
+	}
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		switch mysqlErr.Number {
+		case mysqlErrDuplicateEntry:
+			return fmt.Errorf("%w: %w", ErrDuplicateKey, err)
+		case mysqlErrLockWaitTimeout, mysqlErrLockDeadlock:
+			return fmt.Errorf("%w: %w", ErrSerialization, err)
+		}
+		return err
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case pgErrUniqueViolation:
+			return fmt.Errorf("%w: %w", ErrDuplicateKey, err)
+		case pgErrSerializationFailed, pgErrDeadlockDetected:
+			return fmt.Errorf("%w: %w", ErrSerialization, err)
+		}
+		return err
+	}
+
+	// The sqlite driver is always linked in, but it only defines its Error type when built with cgo, being a stub
+	// otherwise, so the messages of the sqlite errors are matched instead of their codes whichever way it is built
+	msg := err.Error()
+	switch {
+	case strings.HasPrefix(msg, "UNIQUE constraint failed"):
+		return fmt.Errorf("%w: %w", ErrDuplicateKey, err)
+	case strings.HasPrefix(msg, "database is locked"):
+		return fmt.Errorf("%w: %w", ErrSerialization, err)
+	}
+	return err
+}
+
+// RegisterErrorTranslation makes every statement run through the given gorm handle report its failure
+// as one of the sentinel errors of this package whenever possible
+func RegisterErrorTranslation(db *gorm.DB) error {
+	translate := func(tx *gorm.DB) {
+		if tx.Error != nil {
+			tx.Error = translateError(tx.Error)
+		}
+	}
+
+	callbacks := db.Callback()
+	for _, processor := range []interface {
+		Register(name string, fn func(*gorm.DB)) error
+	}{
+		callbacks.Create(),
+		callbacks.Query(),
+		callbacks.Update(),
+		callbacks.Delete(),
+		callbacks.Row(),
+		callbacks.Raw(),
+	} {
+		if err := processor.Register("juno:translate_error", translate); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/forbole/juno/v4/common"
+	"github.com/forbole/juno/v4/models"
+)
+
+func TestTranslateError(t *testing.T) {
+	otherErr := errors.New("other")
+
+	for _, tc := range []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"mysql duplicate entry", &mysql.MySQLError{Number: 1062}, ErrDuplicateKey},
+		{"mysql lock wait timeout", &mysql.MySQLError{Number: 1205}, ErrSerialization},
+		{"mysql deadlock", &mysql.MySQLError{Number: 1213}, ErrSerialization},
+		{"postgres unique violation", &pgconn.PgError{Code: "23505"}, ErrDuplicateKey},
+		{"postgres serialization failure", &pgconn.PgError{Code: "40001"}, ErrSerialization},
+		{"postgres deadlock", &pgconn.PgError{Code: "40P01"}, ErrSerialization},
+		{"record not found", gorm.ErrRecordNotFound, ErrNotFound},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := translateError(tc.err)
+			require.ErrorIs(t, err, tc.want)
+			require.ErrorIs(t, err, tc.err)
+		})
+	}
+
+	require.Nil(t, translateError(nil))
+	require.Equal(t, otherErr, translateError(otherErr))
+	mysqlErr := &mysql.MySQLError{Number: 1146}
+	require.Equal(t, error(mysqlErr), translateError(mysqlErr))
+	require.Equal(t, ErrBucketNotFound, translateError(ErrBucketNotFound))
+}
+
+func TestSentinelErrors(t *testing.T) {
+	ctx := context.Background()
+	db := newTestImpl(t, &models.Bucket{})
+
+	// A bucket created again with the same name but a new id, while the removed one is still stored
+	require.NoError(t, db.SaveBucket(ctx, &models.Bucket{BucketID: common.HexToHash("0x01"), BucketName: "bucket", Removed: true}))
+	err := db.SaveBucket(ctx, &models.Bucket{BucketID: common.HexToHash("0x02"), BucketName: "bucket"})
+	require.ErrorIs(t, err, ErrDuplicateKey)
+
+	// Buckets and payment accounts both declare an idx_owner index, which sqlite won't allow in one database
+	db = newTestImpl(t, &models.PaymentAccount{})
+	_, err = db.GetPaymentAccountByAddr(ctx, common.HexToAddress("0x01"))
+	require.ErrorIs(t, err, ErrPaymentAccountNotFound)
+	require.ErrorIs(t, err, ErrNotFound)
+
+	// Errors of statements run directly through the gorm handle are translated as well
+	var account models.PaymentAccount
+	err = db.Db.Table((&models.PaymentAccount{}).TableName()).Take(&account).Error
+	require.ErrorIs(t, err, ErrNotFound)
+
+	require.NoError(t, db.Db.Create(&models.PaymentAccount{Addr: common.HexToAddress("0x01")}).Error)
+	err = db.Db.Create(&models.PaymentAccount{Addr: common.HexToAddress("0x01")}).Error
+	require.ErrorIs(t, err, ErrDuplicateKey)
+}
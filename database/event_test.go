@@ -0,0 +1,112 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	abci "github.com/cometbft/cometbft/abci/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/stretchr/testify/require"
+
+	"github.com/forbole/juno/v4/common"
+	"github.com/forbole/juno/v4/models"
+)
+
+func newTestEvent(eventType string, attributes ...string) sdk.Event {
+	event := sdk.Event{Type: eventType}
+	for i := 0; i+1 < len(attributes); i += 2 {
+		event.Attributes = append(event.Attributes, abci.EventAttribute{Key: attributes[i], Value: attributes[i+1], Index: true})
+	}
+	return event
+}
+
+func TestSaveEvents(t *testing.T) {
+	ctx := context.Background()
+	db := newTestImpl(t, &models.Event{})
+	txHash := common.HexToHash("0x01")
+
+	// Nothing is archived unless enabled
+	require.NoError(t, db.SaveEvents(ctx, 1, 1, txHash, []sdk.Event{newTestEvent("transfer", "amount", "1azkme")}))
+	events, err := db.ListEventsByHeight(ctx, 1)
+	require.NoError(t, err)
+	require.Empty(t, events)
+
+	db.ArchiveEvents = true
+	require.NoError(t, db.SaveBlockEvents(ctx, 1, models.EventOriginBeginBlock, []sdk.Event{newTestEvent("mint", "amount", "10azkme")}))
+	require.NoError(t, db.SaveEvents(ctx, 1, 1, txHash, []sdk.Event{
+		newTestEvent("message", "action", "/cosmos.bank.v1beta1.MsgSend"),
+		newTestEvent("transfer", "recipient", "0x02", "amount", "1azkme"),
+	}))
+	require.NoError(t, db.SaveBlockEvents(ctx, 1, models.EventOriginEndBlock, []sdk.Event{newTestEvent("complete_unbonding")}))
+	require.NoError(t, db.SaveEvents(ctx, 2, 0, common.HexToHash("0x02"), []sdk.Event{newTestEvent("message")}))
+	require.Error(t, db.SaveBlockEvents(ctx, 1, models.EventOriginTx, []sdk.Event{newTestEvent("message")}))
+
+	// Saving the events of a tx again replaces them, which are still listed in the order of the block
+	require.NoError(t, db.SaveEvents(ctx, 1, 1, txHash, []sdk.Event{
+		newTestEvent("message", "action", "/cosmos.bank.v1beta1.MsgSend"),
+		newTestEvent("transfer", "recipient", "0x02", "amount", "1azkme"),
+	}))
+	firstTxHash := common.HexToHash("0x03")
+	require.NoError(t, db.SaveEvents(ctx, 1, 0, firstTxHash, []sdk.Event{newTestEvent("message")}))
+
+	events, err = db.ListEventsByHeight(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, events, 5)
+	require.Equal(t, models.EventOriginBeginBlock, events[0].Origin)
+	require.Nil(t, events[0].TxHash)
+	require.Equal(t, firstTxHash, *events[1].TxHash)
+	for i, event := range events[2:4] {
+		require.Equal(t, models.EventOriginTx, event.Origin)
+		require.Equal(t, txHash, *event.TxHash)
+		require.Equal(t, uint32(1), event.TxIndex)
+		require.Equal(t, uint32(i), event.EventIndex)
+	}
+	require.Equal(t, models.EventOriginEndBlock, events[4].Origin)
+
+	transfer, err := events[3].ToABCIEvent()
+	require.NoError(t, err)
+	require.Equal(t, abci.Event(newTestEvent("transfer", "recipient", "0x02", "amount", "1azkme")), transfer)
+}
+
+func TestSaveEventsInBatches(t *testing.T) {
+	ctx := context.Background()
+	db := newTestImpl(t, &models.Event{})
+	db.ArchiveEvents = true
+
+	events := make([]sdk.Event, eventsBatchSize*2+1)
+	for i := range events {
+		events[i] = newTestEvent("transfer", "index", fmt.Sprint(i))
+	}
+	require.NoError(t, db.SaveEvents(ctx, 1, 0, common.HexToHash("0x01"), events))
+
+	stored, err := db.ListEventsByHeight(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, stored, len(events))
+	require.Equal(t, uint32(len(events)-1), stored[len(events)-1].EventIndex)
+}
+
+func TestEventAttributesEncoding(t *testing.T) {
+	ctx := context.Background()
+	db := newTestImpl(t, &models.Event{})
+	db.ArchiveEvents = true
+
+	event := newTestEvent("binary",
+		"utf8", "héllo",
+		"raw", string([]byte{0xff, 0xfe, 0x00, 0x01}),
+		string([]byte{0xc3, 0x28}), "invalid key",
+		"empty", "",
+	)
+	event.Attributes[0].Index = false
+	require.NoError(t, db.SaveEvents(ctx, 1, 0, common.HexToHash("0x01"), []sdk.Event{event}))
+
+	stored, err := db.ListEventsByHeight(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, stored, 1)
+	// Valid values are kept readable
+	require.Contains(t, stored[0].Attributes, `"value":"héllo"`)
+
+	decoded, err := stored[0].ToABCIEvent()
+	require.NoError(t, err)
+	require.Equal(t, abci.Event(event), decoded)
+}
@@ -0,0 +1,50 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/forbole/juno/v4/models"
+)
+
+func TestFailedBlock(t *testing.T) {
+	ctx := context.Background()
+	mainnet, testnet := newTestChains(t, &models.FailedBlock{})
+
+	for _, failed := range []*models.FailedBlock{
+		{Height: 7, Module: "bucket", Error: "first", FirstFailedAt: 100},
+		{Height: 3, Error: "node unavailable", FirstFailedAt: 100},
+		{Height: 7, Module: "object", Error: "second", FirstFailedAt: 200},
+	} {
+		require.NoError(t, mainnet.SaveFailedBlock(ctx, failed))
+	}
+	require.NoError(t, testnet.SaveFailedBlock(ctx, &models.FailedBlock{Height: 7, Error: "testnet"}))
+
+	// Failing again counts a retry, keeping the first failure time
+	failedBlocks, err := mainnet.ListFailedBlocks(ctx, 10, 0)
+	require.NoError(t, err)
+	require.Len(t, failedBlocks, 2)
+	require.Equal(t, uint64(3), failedBlocks[0].Height)
+	require.Zero(t, failedBlocks[0].RetryCount)
+	require.Equal(t, uint64(7), failedBlocks[1].Height)
+	require.Equal(t, "object", failedBlocks[1].Module)
+	require.Equal(t, "second", failedBlocks[1].Error)
+	require.Equal(t, int64(100), failedBlocks[1].FirstFailedAt)
+	require.Equal(t, 1, failedBlocks[1].RetryCount)
+
+	require.NoError(t, mainnet.DeleteFailedBlock(ctx, 7))
+	require.NoError(t, mainnet.DeleteFailedBlock(ctx, 8))
+	failedBlocks, err = mainnet.ListFailedBlocks(ctx, 10, 0)
+	require.NoError(t, err)
+	require.Len(t, failedBlocks, 1)
+	require.Equal(t, uint64(3), failedBlocks[0].Height)
+
+	// The other chain is left untouched
+	failedBlocks, err = testnet.ListFailedBlocks(ctx, 10, 0)
+	require.NoError(t, err)
+	require.Len(t, failedBlocks, 1)
+	require.Equal(t, "testnet", failedBlocks[0].Error)
+	require.Zero(t, failedBlocks[0].RetryCount)
+}
@@ -0,0 +1,102 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/forbole/juno/v4/common"
+	"github.com/forbole/juno/v4/models"
+)
+
+func TestGroupMembership(t *testing.T) {
+	ctx := context.Background()
+	db := newTestImpl(t, &models.Group{})
+
+	owner := common.HexToAddress("0x0f")
+	alice, bob, carol := common.HexToAddress("0x01"), common.HexToAddress("0x02"), common.HexToAddress("0x03")
+	groupID, otherGroupID := common.HexToHash("0x0a"), common.HexToHash("0x0b")
+
+	require.NoError(t, db.CreateGroup(ctx, []*models.Group{
+		{Owner: owner, GroupID: groupID, GroupName: "group", AccountID: common.Address{}},
+		{Owner: owner, GroupID: groupID, GroupName: "group", AccountID: carol},
+		{Owner: owner, GroupID: groupID, GroupName: "group", AccountID: alice},
+		{Owner: owner, GroupID: groupID, GroupName: "group", AccountID: bob},
+		{Owner: alice, GroupID: otherGroupID, GroupName: "other", AccountID: common.Address{}},
+		{Owner: alice, GroupID: otherGroupID, GroupName: "other", AccountID: bob},
+	}))
+
+	// bob leaves the first group
+	require.NoError(t, db.UpdateGroup(ctx, &models.Group{GroupID: groupID, AccountID: bob, Removed: true}))
+
+	members, err := db.GetGroupMembers(ctx, groupID, 10, common.Address{})
+	require.NoError(t, err)
+	require.Len(t, members, 2)
+	require.Equal(t, alice, members[0].AccountID)
+	require.Equal(t, carol, members[1].AccountID)
+
+	members, err = db.GetGroupMembers(ctx, groupID, 1, alice)
+	require.NoError(t, err)
+	require.Len(t, members, 1)
+	require.Equal(t, carol, members[0].AccountID)
+
+	// alice is a member of the first group and owns the second one
+	groups, err := db.ListGroupsByAccount(ctx, alice)
+	require.NoError(t, err)
+	require.Len(t, groups, 2)
+	require.Equal(t, groupID, groups[0].GroupID)
+	require.Equal(t, otherGroupID, groups[1].GroupID)
+	require.Equal(t, common.Address{}, groups[1].AccountID)
+
+	// bob has been removed from the first group
+	groups, err = db.ListGroupsByAccount(ctx, bob)
+	require.NoError(t, err)
+	require.Len(t, groups, 1)
+	require.Equal(t, otherGroupID, groups[0].GroupID)
+}
+
+func TestDeleteGroup(t *testing.T) {
+	ctx := context.Background()
+	db := newTestImpl(t, &models.Group{})
+
+	owner, alice, bob := common.HexToAddress("0x0f"), common.HexToAddress("0x01"), common.HexToAddress("0x02")
+	groupID, otherGroupID := common.HexToHash("0x0a"), common.HexToHash("0x0b")
+
+	require.NoError(t, db.CreateGroup(ctx, []*models.Group{
+		{Owner: owner, GroupID: groupID, AccountID: common.Address{}, UpdateAt: 1},
+		{Owner: owner, GroupID: groupID, AccountID: alice, Operator: owner, ExpirationTime: 100, UpdateAt: 1},
+		{Owner: owner, GroupID: groupID, AccountID: bob, Operator: alice, ExpirationTime: 200, UpdateAt: 1},
+		{Owner: owner, GroupID: otherGroupID, AccountID: alice, UpdateAt: 1},
+	}))
+
+	require.NoError(t, db.DeleteGroupMember(ctx, groupID, bob, 5, 500))
+
+	members, err := db.GetGroupMembers(ctx, groupID, 10, common.Address{})
+	require.NoError(t, err)
+	require.Len(t, members, 1)
+	require.Equal(t, alice, members[0].AccountID)
+
+	require.NoError(t, db.DeleteGroup(ctx, groupID, 10, 1000))
+
+	var rows []*models.Group
+	require.NoError(t, db.Db.Table((&models.Group{}).TableName()).Order("id").Find(&rows).Error)
+	require.Len(t, rows, 4)
+
+	for _, row := range rows[:3] {
+		require.True(t, row.Removed)
+		require.Equal(t, int64(10), row.UpdateAt)
+		require.Equal(t, int64(1000), row.UpdateTime)
+		require.Equal(t, owner, row.Owner)
+	}
+
+	// Member specific columns are left untouched
+	require.Equal(t, owner, rows[1].Operator)
+	require.Equal(t, int64(100), rows[1].ExpirationTime)
+	require.Equal(t, alice, rows[2].Operator)
+	require.Equal(t, int64(200), rows[2].ExpirationTime)
+
+	// Other groups are not affected
+	require.False(t, rows[3].Removed)
+	require.Equal(t, int64(1), rows[3].UpdateAt)
+}
@@ -0,0 +1,90 @@
+package database
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/forbole/juno/v4/log"
+)
+
+// Metrics records the statements run by the database layer.
+// The table and operation labels are bounded by the models and the gorm callbacks, never by the SQL text.
+type Metrics interface {
+	// ObserveStatement records a statement of the given operation run on the given table, which took
+	// the given duration and failed with err unless nil
+	ObserveStatement(table, operation string, duration time.Duration, err error)
+}
+
+// NoopMetrics is the Metrics implementation that records nothing
+type NoopMetrics struct{}
+
+// ObserveStatement implements Metrics
+func (NoopMetrics) ObserveStatement(string, string, time.Duration, error) {}
+
+// PrometheusMetrics is the Metrics implementation exposing the statements through the Prometheus
+// collectors of the log package
+type PrometheusMetrics struct{}
+
+// ObserveStatement implements Metrics
+func (PrometheusMetrics) ObserveStatement(table, operation string, duration time.Duration, err error) {
+	log.DBStatementCount.WithLabelValues(table, operation).Inc()
+	log.DBStatementLatencyHist.WithLabelValues(table, operation).Observe(duration.Seconds())
+	if err != nil {
+		log.DBStatementErrorCount.WithLabelValues(table, operation).Inc()
+	}
+}
+
+const (
+	statementStartKey = "juno:statement_start"
+
+	// noTable labels the raw statements, which aren't bound to the table of a model
+	noTable = "none"
+)
+
+// RegisterMetrics makes every statement run through the given gorm handle get recorded by metrics.
+// Not found errors are part of the normal flow of reads, so they are not recorded as failures.
+func RegisterMetrics(db *gorm.DB, metrics Metrics) error {
+	if _, ok := metrics.(NoopMetrics); ok || metrics == nil {
+		return nil
+	}
+
+	start := func(tx *gorm.DB) {
+		tx.InstanceSet(statementStartKey, time.Now())
+	}
+	observe := func(operation string) func(tx *gorm.DB) {
+		return func(tx *gorm.DB) {
+			begin, ok := tx.InstanceGet(statementStartKey)
+			if !ok {
+				return
+			}
+
+			table := tx.Statement.Table
+			if table == "" {
+				table = noTable
+			}
+			err := tx.Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				err = nil
+			}
+			metrics.ObserveStatement(table, operation, time.Since(begin.(time.Time)), err)
+		}
+	}
+
+	callbacks := db.Callback()
+	return errors.Join(
+		callbacks.Create().Before("*").Register("juno:metrics_start", start),
+		callbacks.Create().After("*").Register("juno:metrics_observe", observe("create")),
+		callbacks.Query().Before("*").Register("juno:metrics_start", start),
+		callbacks.Query().After("*").Register("juno:metrics_observe", observe("query")),
+		callbacks.Update().Before("*").Register("juno:metrics_start", start),
+		callbacks.Update().After("*").Register("juno:metrics_observe", observe("update")),
+		callbacks.Delete().Before("*").Register("juno:metrics_start", start),
+		callbacks.Delete().After("*").Register("juno:metrics_observe", observe("delete")),
+		callbacks.Row().Before("*").Register("juno:metrics_start", start),
+		callbacks.Row().After("*").Register("juno:metrics_observe", observe("row")),
+		callbacks.Raw().Before("*").Register("juno:metrics_start", start),
+		callbacks.Raw().After("*").Register("juno:metrics_observe", observe("raw")),
+	)
+}
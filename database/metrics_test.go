@@ -0,0 +1,87 @@
+package database
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/forbole/juno/v4/common"
+	"github.com/forbole/juno/v4/log"
+	"github.com/forbole/juno/v4/models"
+)
+
+type statementKey struct {
+	table, operation string
+}
+
+// recordingMetrics counts the statements and the failures it observes
+type recordingMetrics struct {
+	mu     sync.Mutex
+	calls  map[statementKey]int
+	errors map[statementKey]int
+}
+
+func newRecordingMetrics() *recordingMetrics {
+	return &recordingMetrics{calls: map[statementKey]int{}, errors: map[statementKey]int{}}
+}
+
+func (m *recordingMetrics) ObserveStatement(table, operation string, _ time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := statementKey{table, operation}
+	m.calls[key]++
+	if err != nil {
+		m.errors[key]++
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	ctx := context.Background()
+	db := newTestImpl(t, &models.Block{}, &models.Object{})
+	metrics := newRecordingMetrics()
+	require.NoError(t, RegisterMetrics(db.Db, metrics))
+
+	require.NoError(t, db.SaveBlock(ctx, &models.Block{
+		BlockID: models.BlockID{Hash: common.HexToHash("0x01")},
+		Header:  models.Header{Height: 1},
+	}))
+	require.Equal(t, 1, metrics.calls[statementKey{"blocks", "create"}])
+
+	require.NoError(t, db.SaveObject(ctx, &models.Object{ObjectID: common.HexToHash("0x01"), ObjectName: "file"}))
+	_, err := db.GetObject(ctx, common.HexToHash("0x01"))
+	require.NoError(t, err)
+	require.Equal(t, 1, metrics.calls[statementKey{"objects", "query"}])
+
+	// A missing row is not a failure of the statement
+	_, err = db.GetObject(ctx, common.HexToHash("0x02"))
+	require.ErrorIs(t, err, ErrNotFound)
+	require.Equal(t, 2, metrics.calls[statementKey{"objects", "query"}])
+	require.Zero(t, metrics.errors[statementKey{"objects", "query"}])
+
+	// The permissions table was never created
+	_, err = db.GetPermissionByPolicyID(ctx, common.HexToHash("0x01"))
+	require.Error(t, err)
+	require.Equal(t, 1, metrics.errors[statementKey{(&models.Permission{}).TableName(), "query"}])
+
+	require.NoError(t, db.Db.Exec("SELECT 1").Error)
+	require.Equal(t, 1, metrics.calls[statementKey{noTable, "raw"}])
+}
+
+func TestPrometheusMetrics(t *testing.T) {
+	ctx := context.Background()
+	db := newTestImpl(t, &models.Object{})
+	require.NoError(t, RegisterMetrics(db.Db, PrometheusMetrics{}))
+
+	calls := testutil.ToFloat64(log.DBStatementCount.WithLabelValues("objects", "query"))
+	errors := testutil.ToFloat64(log.DBStatementErrorCount.WithLabelValues("objects", "query"))
+
+	_, err := db.GetObject(ctx, common.HexToHash("0x01"))
+	require.ErrorIs(t, err, ErrNotFound)
+	require.Equal(t, calls+1, testutil.ToFloat64(log.DBStatementCount.WithLabelValues("objects", "query")))
+	require.Equal(t, errors, testutil.ToFloat64(log.DBStatementErrorCount.WithLabelValues("objects", "query")))
+}
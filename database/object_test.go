@@ -0,0 +1,284 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+
+	"github.com/forbole/juno/v4/common"
+	"github.com/forbole/juno/v4/models"
+)
+
+func saveTestObjects(t *testing.T, db *Impl, objects ...*models.Object) {
+	t.Helper()
+
+	for i, object := range objects {
+		object.ObjectID = common.BigToHash(big.NewInt(int64(i + 1)))
+		require.NoError(t, db.SaveObject(context.Background(), object))
+	}
+}
+
+func objectNames(objects []*models.Object) []string {
+	names := make([]string, len(objects))
+	for i, object := range objects {
+		names[i] = object.ObjectName
+	}
+	return names
+}
+
+func TestListObjectsByBucket(t *testing.T) {
+	ctx := context.Background()
+	db := newTestImpl(t, &models.Object{})
+
+	saveTestObjects(t, db,
+		&models.Object{BucketName: "bucket", ObjectName: "photos/b.png", Status: "OBJECT_STATUS_SEALED"},
+		&models.Object{BucketName: "bucket", ObjectName: "photos/a.png", Status: "OBJECT_STATUS_CREATED"},
+		&models.Object{BucketName: "bucket", ObjectName: "docs/a.txt", Status: "OBJECT_STATUS_SEALED"},
+		&models.Object{BucketName: "bucket", ObjectName: "photos/c.png", Status: "OBJECT_STATUS_SEALED", Removed: true},
+		&models.Object{BucketName: "bucket", ObjectName: "photos_old/d.png", Status: "OBJECT_STATUS_SEALED"},
+		&models.Object{BucketName: "other", ObjectName: "photos/e.png", Status: "OBJECT_STATUS_SEALED"},
+	)
+
+	objects, err := db.ListObjectsByBucket(ctx, "bucket", ObjectListOptions{})
+	require.NoError(t, err)
+	require.Equal(t, []string{"docs/a.txt", "photos/a.png", "photos/b.png", "photos_old/d.png"}, objectNames(objects))
+
+	// Paginate using the last object name as continuation token
+	objects, err = db.ListObjectsByBucket(ctx, "bucket", ObjectListOptions{Limit: 2})
+	require.NoError(t, err)
+	require.Equal(t, []string{"docs/a.txt", "photos/a.png"}, objectNames(objects))
+
+	objects, err = db.ListObjectsByBucket(ctx, "bucket", ObjectListOptions{Limit: 2, StartAfter: objects[1].ObjectName})
+	require.NoError(t, err)
+	require.Equal(t, []string{"photos/b.png", "photos_old/d.png"}, objectNames(objects))
+
+	// The '_' inside the prefix must not be handled as a wildcard
+	objects, err = db.ListObjectsByBucket(ctx, "bucket", ObjectListOptions{Prefix: "photos_"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"photos_old/d.png"}, objectNames(objects))
+
+	objects, err = db.ListObjectsByBucket(ctx, "bucket", ObjectListOptions{Prefix: "photos/", IncludeRemoved: true})
+	require.NoError(t, err)
+	require.Equal(t, []string{"photos/a.png", "photos/b.png", "photos/c.png"}, objectNames(objects))
+
+	objects, err = db.ListObjectsByBucket(ctx, "bucket", ObjectListOptions{Status: "OBJECT_STATUS_CREATED"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"photos/a.png"}, objectNames(objects))
+
+	objects, err = db.ListObjectsByBucket(ctx, "missing", ObjectListOptions{})
+	require.NoError(t, err)
+	require.Empty(t, objects)
+}
+
+func TestPrepareTablesCreatesMissingIndexes(t *testing.T) {
+	db := newTestImpl(t, &models.Object{})
+
+	m := db.Db.Migrator()
+	require.NoError(t, m.DropIndex(&models.Object{}, "idx_bucket_name_object_name"))
+	require.False(t, m.HasIndex(&models.Object{}, "idx_bucket_name_object_name"))
+
+	require.NoError(t, db.PrepareTables(context.Background(), []schema.Tabler{&models.Object{}}))
+	require.True(t, m.HasIndex(&models.Object{}, "idx_bucket_name_object_name"))
+}
+
+func TestListObjectsByCreator(t *testing.T) {
+	ctx := context.Background()
+	db := newTestImpl(t, &models.Object{})
+
+	creator, other := common.HexToAddress("0x01"), common.HexToAddress("0x02")
+	saveTestObjects(t, db,
+		&models.Object{BucketName: "first", ObjectName: "a", Creator: creator, Status: "OBJECT_STATUS_SEALED"},
+		&models.Object{BucketName: "second", ObjectName: "b", Creator: creator, Status: "OBJECT_STATUS_CREATED"},
+		&models.Object{BucketName: "second", ObjectName: "c", Creator: other, Status: "OBJECT_STATUS_SEALED"},
+		&models.Object{BucketName: "third", ObjectName: "d", Creator: creator, Status: "OBJECT_STATUS_SEALED", Removed: true},
+		&models.Object{BucketName: "third", ObjectName: "e", Creator: creator, Status: "OBJECT_STATUS_CREATED"},
+	)
+
+	objects, err := db.ListObjectsByCreator(ctx, creator, 10, common.Hash{})
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "b", "e"}, objectNames(objects))
+	require.Equal(t, "OBJECT_STATUS_SEALED", objects[0].Status)
+	require.Equal(t, "OBJECT_STATUS_CREATED", objects[1].Status)
+	require.Equal(t, "third", objects[2].BucketName)
+
+	objects, err = db.ListObjectsByCreator(ctx, creator, 2, common.Hash{})
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "b"}, objectNames(objects))
+
+	objects, err = db.ListObjectsByCreator(ctx, creator, 2, objects[1].ObjectID)
+	require.NoError(t, err)
+	require.Equal(t, []string{"e"}, objectNames(objects))
+}
+
+func TestGetObjectByBucketAndName(t *testing.T) {
+	ctx := context.Background()
+	db := newTestImpl(t, &models.Object{})
+
+	saveTestObjects(t, db,
+		&models.Object{BucketName: "bucket", ObjectName: "file", Status: "OBJECT_STATUS_SEALED"},
+		&models.Object{BucketName: "bucket", ObjectName: "deleted", Status: "OBJECT_STATUS_SEALED"},
+	)
+	original, err := db.GetObjectByBucketAndName(ctx, "bucket", "file")
+	require.NoError(t, err)
+
+	// The object is deleted and then created again with the same name
+	require.NoError(t, db.UpdateObject(ctx, &models.Object{ObjectID: original.ObjectID, Removed: true}))
+	_, err = db.GetObjectByBucketAndName(ctx, "bucket", "file")
+	require.True(t, errors.Is(err, ErrObjectNotFound))
+
+	recreatedID := common.HexToHash("0xff")
+	require.NoError(t, db.SaveObject(ctx, &models.Object{
+		BucketName: "bucket", ObjectName: "file", ObjectID: recreatedID, Status: "OBJECT_STATUS_CREATED",
+	}))
+
+	object, err := db.GetObjectByBucketAndName(ctx, "bucket", "file")
+	require.NoError(t, err)
+	require.Equal(t, recreatedID, object.ObjectID)
+	require.Equal(t, "OBJECT_STATUS_CREATED", object.Status)
+
+	// Duplicated live rows resolve to the latest one
+	duplicatedID := common.HexToHash("0xfe")
+	require.NoError(t, db.SaveObject(ctx, &models.Object{BucketName: "bucket", ObjectName: "file", ObjectID: duplicatedID}))
+	object, err = db.GetObjectByBucketAndName(ctx, "bucket", "file")
+	require.NoError(t, err)
+	require.Equal(t, duplicatedID, object.ObjectID)
+
+	_, err = db.GetObjectByBucketAndName(ctx, "other", "file")
+	require.True(t, errors.Is(err, ErrObjectNotFound))
+}
+
+func TestGetObject(t *testing.T) {
+	ctx := context.Background()
+	db := newTestImpl(t, &models.Object{})
+
+	saveTestObjects(t, db, &models.Object{BucketName: "bucket", ObjectName: "file"})
+	objectID := common.BigToHash(big.NewInt(1))
+
+	object, err := db.GetObject(ctx, objectID)
+	require.NoError(t, err)
+	require.Equal(t, "file", object.ObjectName)
+
+	_, err = db.GetObject(ctx, common.HexToHash("0xff"))
+	require.True(t, errors.Is(err, ErrObjectNotFound))
+	require.True(t, errors.Is(err, gorm.ErrRecordNotFound))
+
+	require.NoError(t, db.UpdateObject(ctx, &models.Object{ObjectID: objectID, Removed: true}))
+	_, err = db.GetObject(ctx, objectID)
+	require.True(t, errors.Is(err, ErrObjectNotFound))
+}
+
+func TestMultiSaveObjects(t *testing.T) {
+	ctx := context.Background()
+	db := newTestImpl(t, &models.Object{})
+
+	objects := make([]*models.Object, 10000)
+	for i := range objects {
+		objects[i] = &models.Object{
+			ObjectID:   common.BigToHash(big.NewInt(int64(i + 1))),
+			BucketName: "bucket",
+			ObjectName: fmt.Sprintf("%s-%d", strings.Repeat("a", 1000), i),
+			CheckSums:  pq.ByteaArray{bytes.Repeat([]byte{0x01}, 32), bytes.Repeat([]byte{0x02}, 32)},
+			UpdateAt:   1,
+		}
+	}
+	require.NoError(t, db.MultiSaveObjects(ctx, objects))
+	require.NoError(t, db.MultiSaveObjects(ctx, nil))
+
+	count := func() int64 {
+		var count int64
+		require.NoError(t, db.Db.Table((&models.Object{}).TableName()).Count(&count).Error)
+		return count
+	}
+	require.Equal(t, int64(10000), count())
+
+	// Saving them again, as rows freshly decoded from the chain, updates the existing rows instead of adding new ones
+	for _, object := range objects {
+		object.ID = 0
+		object.UpdateAt = 2
+	}
+	require.NoError(t, db.MultiSaveObjects(ctx, objects))
+	require.Equal(t, int64(10000), count())
+
+	object, err := db.GetObject(ctx, objects[9999].ObjectID)
+	require.NoError(t, err)
+	require.Equal(t, objects[9999].ObjectName, object.ObjectName)
+	require.Equal(t, objects[9999].CheckSums, object.CheckSums)
+	require.Equal(t, int64(2), object.UpdateAt)
+}
+
+func TestGetObjectChecksums(t *testing.T) {
+	ctx := context.Background()
+	db := newTestImpl(t, &models.Object{})
+
+	// A primary piece and 6 erasure coded ones, with bytes needing escaping in the stored literal
+	checksums := make([][]byte, 7)
+	for i := range checksums {
+		checksums[i] = bytes.Repeat([]byte{byte(i)}, 32)
+	}
+	checksums[1] = append([]byte(`"\{},`), checksums[1][5:]...)
+	checksums[6] = bytes.Repeat([]byte{0xff}, 32)
+
+	saveTestObjects(t, db,
+		&models.Object{BucketName: "bucket", ObjectName: "sealed", CheckSums: checksums},
+		&models.Object{BucketName: "bucket", ObjectName: "created"},
+	)
+
+	stored, err := db.GetObjectChecksums(ctx, common.BigToHash(big.NewInt(1)))
+	require.NoError(t, err)
+	require.Equal(t, checksums, stored)
+
+	// The stored encoding is the same whatever the database
+	var raw string
+	require.NoError(t, db.Db.Table((&models.Object{}).TableName()).Select("checksums").Where("object_id = ?", common.BigToHash(big.NewInt(1))).Scan(&raw).Error)
+	require.True(t, strings.HasPrefix(raw, `{"\\x0000000000`), raw)
+
+	stored, err = db.GetObjectChecksums(ctx, common.BigToHash(big.NewInt(2)))
+	require.NoError(t, err)
+	require.Empty(t, stored)
+
+	_, err = db.GetObjectChecksums(ctx, common.HexToHash("0xff"))
+	require.ErrorIs(t, err, ErrObjectNotFound)
+
+	require.NoError(t, db.UpdateObject(ctx, &models.Object{ObjectID: common.BigToHash(big.NewInt(1)), Removed: true}, "removed"))
+	_, err = db.GetObjectChecksums(ctx, common.BigToHash(big.NewInt(1)))
+	require.ErrorIs(t, err, ErrObjectNotFound)
+}
+
+func TestBackfillBucketStats(t *testing.T) {
+	ctx := context.Background()
+	db := newTestImpl(t, &models.Bucket{}, &models.Object{}, &models.BucketStats{})
+	db.MaxPageSize = 2
+
+	// The buckets and objects stored before the stats were tracked
+	bucketIDs := make([]common.Hash, 3)
+	for i := range bucketIDs {
+		bucketIDs[i] = common.BigToHash(big.NewInt(int64(i + 1)))
+		require.NoError(t, db.SaveBucket(ctx, &models.Bucket{BucketID: bucketIDs[i], BucketName: fmt.Sprintf("bucket-%d", i)}))
+	}
+	require.NoError(t, db.MultiSaveObjects(ctx, []*models.Object{
+		{ObjectID: common.HexToHash("0x01"), BucketID: bucketIDs[0], PayloadSize: 10, Status: "OBJECT_STATUS_SEALED"},
+		{ObjectID: common.HexToHash("0x02"), BucketID: bucketIDs[0], PayloadSize: 20, Status: "OBJECT_STATUS_CREATED"},
+		{ObjectID: common.HexToHash("0x03"), BucketID: bucketIDs[2], PayloadSize: 30, Status: "OBJECT_STATUS_SEALED"},
+	}))
+
+	count, err := db.BackfillBucketStats(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(3), count)
+	for i, expected := range [][2]int64{{1, 10}, {0, 0}, {1, 30}} {
+		stats, err := db.GetBucketStats(ctx, bucketIDs[i])
+		require.NoError(t, err)
+		require.Equal(t, expected, [2]int64{stats.ObjectCount, stats.StoredSize})
+	}
+	object, err := db.GetObject(ctx, common.HexToHash("0x01"))
+	require.NoError(t, err)
+	require.True(t, object.StatsCounted)
+}
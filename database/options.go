@@ -0,0 +1,20 @@
+package database
+
+// ObjectListOptions contains the options used to list the objects of a bucket
+type ObjectListOptions struct {
+	// Limit is the max number of objects to return, capped to the configured max page size
+	Limit int
+
+	// StartAfter is the continuation token: only objects whose name sorts after it are returned.
+	// It should be set to the name of the last object of the previous page.
+	StartAfter string
+
+	// Prefix, when set, only returns the objects whose name starts with it
+	Prefix string
+
+	// IncludeRemoved tells whether removed objects should be returned as well
+	IncludeRemoved bool
+
+	// Status, when set, only returns the objects having the given status (e.g. OBJECT_STATUS_SEALED)
+	Status string
+}
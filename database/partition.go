@@ -0,0 +1,189 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+)
+
+// PartitionManager creates the partitions of the tables partitioned by RANGE on their height column.
+// The tables must have been created partitioned already: the manager only adds the partitions new heights need,
+// along with a default partition catching the heights no other partition holds.
+type PartitionManager interface {
+	// EnsurePartition makes sure table has a partition where the rows at the given height can be stored
+	EnsurePartition(ctx context.Context, table string, height uint64) error
+}
+
+// NewPartitionManager returns the PartitionManager matching the dialect of the given gorm handle,
+// creating one partition every size heights. The returned manager does nothing when partitioning is
+// not enabled, size is not positive or the dialect doesn't support partitions.
+func NewPartitionManager(db *gorm.DB, enabled bool, size int64) PartitionManager {
+	if !enabled || size <= 0 {
+		return noopPartitionManager{}
+	}
+
+	switch db.Dialector.Name() {
+	case "postgres":
+		return &postgresPartitionManager{db: db, size: uint64(size), created: make(map[string]uint64)}
+	case "mysql":
+		return &mysqlPartitionManager{db: db, size: uint64(size), upperBounds: make(map[string]uint64)}
+	default:
+		return noopPartitionManager{}
+	}
+}
+
+// partitions returns the partition manager of this Impl, which does nothing when none has been set
+func (db *Impl) partitions() PartitionManager {
+	if db.Partitions == nil {
+		return noopPartitionManager{}
+	}
+	return db.Partitions
+}
+
+type noopPartitionManager struct{}
+
+// EnsurePartition implements PartitionManager
+func (noopPartitionManager) EnsurePartition(context.Context, string, uint64) error {
+	return nil
+}
+
+// postgresPartitionManager adds a partition of the range [id*size, (id+1)*size) for every partition id, the
+// default partition being added first. A range partition can't be added while the default one holds some of its
+// rows, which only happens if rows got stored without their partition being ensured.
+type postgresPartitionManager struct {
+	db   *gorm.DB
+	size uint64
+
+	mu sync.Mutex
+	// created holds the partition id of every partition known to exist, keyed by partition name
+	created map[string]uint64
+}
+
+// EnsurePartition implements PartitionManager
+func (m *postgresPartitionManager) EnsurePartition(ctx context.Context, table string, height uint64) error {
+	partitionID := height / m.size
+	partition := fmt.Sprintf("%s_%d", table, partitionID)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.created[partition]; ok {
+		return nil
+	}
+
+	defaultPartition := table + "_default"
+	if _, ok := m.created[defaultPartition]; !ok {
+		err := m.db.WithContext(ctx).Exec(fmt.Sprintf(
+			"CREATE TABLE IF NOT EXISTS %s PARTITION OF %s DEFAULT", defaultPartition, table,
+		)).Error
+		if err != nil {
+			return fmt.Errorf("failed to create partition %s: %w", defaultPartition, err)
+		}
+		m.created[defaultPartition] = 0
+	}
+
+	err := m.db.WithContext(ctx).Exec(fmt.Sprintf(
+		"CREATE TABLE IF NOT EXISTS %s PARTITION OF %s FOR VALUES FROM (%d) TO (%d)",
+		partition, table, partitionID*m.size, (partitionID+1)*m.size,
+	)).Error
+	if err != nil {
+		return fmt.Errorf("failed to create partition %s: %w", partition, err)
+	}
+
+	m.created[partition] = partitionID
+	return nil
+}
+
+// mysqlPartitionManager adds a partition of the heights lower than (id+1)*size for every partition id.
+// MySQL RANGE partitions have to be added in increasing order, and each of them holds all the heights
+// below its bound not held by the previous ones: a height is covered as soon as a higher bound exists.
+// The last partition, pmax, holds the heights above every bound, the new partitions being split out of it.
+type mysqlPartitionManager struct {
+	db   *gorm.DB
+	size uint64
+
+	mu sync.Mutex
+	// upperBounds holds the highest partition bound, below pmax, of every table, once it has been read and pmax
+	// has been made sure to exist
+	upperBounds map[string]uint64
+}
+
+// mysqlPartitions is the state of the partitions of a table
+type mysqlPartitions struct {
+	UpperBound uint64
+	HasMax     bool
+}
+
+const (
+	mysqlErrDuplicatePartition = 1517
+	mysqlErrRangeNotIncreasing = 1493
+	mysqlMaxPartition          = "pmax"
+	mysqlPartitionsQuery       = `SELECT
+COALESCE(MAX(CASE WHEN PARTITION_DESCRIPTION <> 'MAXVALUE' THEN CAST(PARTITION_DESCRIPTION AS UNSIGNED) END), 0) AS upper_bound,
+COUNT(CASE WHEN PARTITION_DESCRIPTION = 'MAXVALUE' THEN 1 END) > 0 AS has_max
+FROM information_schema.PARTITIONS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ?`
+)
+
+// EnsurePartition implements PartitionManager
+func (m *mysqlPartitionManager) EnsurePartition(ctx context.Context, table string, height uint64) error {
+	partitionID := height / m.size
+	upperBound := (partitionID + 1) * m.size
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current, ok := m.upperBounds[table]
+	if !ok {
+		var err error
+		if current, err = m.readPartitions(ctx, table); err != nil {
+			return err
+		}
+		m.upperBounds[table] = current
+	}
+	if height < current {
+		return nil
+	}
+
+	partition := fmt.Sprintf("p%d", partitionID)
+	err := m.db.WithContext(ctx).Exec(fmt.Sprintf(
+		"ALTER TABLE %s REORGANIZE PARTITION %s INTO (PARTITION %s VALUES LESS THAN (%d), PARTITION %s VALUES LESS THAN MAXVALUE)",
+		table, mysqlMaxPartition, partition, upperBound, mysqlMaxPartition,
+	)).Error
+
+	// Another process may have added the same partition, or a higher one, in the meantime
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && (mysqlErr.Number == mysqlErrDuplicatePartition || mysqlErr.Number == mysqlErrRangeNotIncreasing) {
+		delete(m.upperBounds, table)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create partition %s of %s: %w", partition, table, err)
+	}
+
+	m.upperBounds[table] = upperBound
+	return nil
+}
+
+// readPartitions returns the highest partition bound of the given table, below pmax, adding pmax if missing
+func (m *mysqlPartitionManager) readPartitions(ctx context.Context, table string) (uint64, error) {
+	var partitions mysqlPartitions
+	if err := m.db.WithContext(ctx).Raw(mysqlPartitionsQuery, table).Scan(&partitions).Error; err != nil {
+		return 0, fmt.Errorf("failed to read the partitions of %s: %w", table, err)
+	}
+	if partitions.HasMax {
+		return partitions.UpperBound, nil
+	}
+
+	err := m.db.WithContext(ctx).Exec(fmt.Sprintf(
+		"ALTER TABLE %s ADD PARTITION (PARTITION %s VALUES LESS THAN MAXVALUE)", table, mysqlMaxPartition,
+	)).Error
+	var mysqlErr *mysql.MySQLError
+	if err != nil && !(errors.As(err, &mysqlErr) && mysqlErr.Number == mysqlErrDuplicatePartition) {
+		return 0, fmt.Errorf("failed to create partition %s of %s: %w", mysqlMaxPartition, table, err)
+	}
+	return partitions.UpperBound, nil
+}
@@ -0,0 +1,97 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	gormmysql "gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/forbole/juno/v4/common"
+	"github.com/forbole/juno/v4/models"
+)
+
+// openDryRun opens a gorm handle that never reaches a server, returning the statements it is asked to execute
+func openDryRun(t *testing.T, dialector gorm.Dialector) (*gorm.DB, *[]string) {
+	t.Helper()
+
+	db, err := gorm.Open(dialector, &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	require.NoError(t, err)
+
+	var statements []string
+	err = db.Callback().Raw().Register("test:capture", func(tx *gorm.DB) {
+		statements = append(statements, tx.Statement.SQL.String())
+	})
+	require.NoError(t, err)
+	return db, &statements
+}
+
+func TestNewPartitionManager(t *testing.T) {
+	pgDb, _ := openDryRun(t, postgres.New(postgres.Config{DSN: "host=localhost"}))
+	require.IsType(t, &postgresPartitionManager{}, NewPartitionManager(pgDb, true, 1000))
+	require.IsType(t, noopPartitionManager{}, NewPartitionManager(pgDb, false, 1000))
+	require.IsType(t, noopPartitionManager{}, NewPartitionManager(pgDb, true, 0))
+
+	sqliteDb, _ := openDryRun(t, sqlite.Open("file::memory:"))
+	require.IsType(t, noopPartitionManager{}, NewPartitionManager(sqliteDb, true, 1000))
+}
+
+func TestPostgresPartitionManager(t *testing.T) {
+	ctx := context.Background()
+	db, statements := openDryRun(t, postgres.New(postgres.Config{DSN: "host=localhost"}))
+	manager := NewPartitionManager(db, true, 1000000)
+
+	for _, height := range []uint64{5, 999999, 1000000, 7} {
+		require.NoError(t, manager.EnsurePartition(ctx, "txs", height))
+	}
+	require.Equal(t, []string{
+		"CREATE TABLE IF NOT EXISTS txs_default PARTITION OF txs DEFAULT",
+		"CREATE TABLE IF NOT EXISTS txs_0 PARTITION OF txs FOR VALUES FROM (0) TO (1000000)",
+		"CREATE TABLE IF NOT EXISTS txs_1 PARTITION OF txs FOR VALUES FROM (1000000) TO (2000000)",
+	}, *statements)
+}
+
+func TestMySQLPartitionManager(t *testing.T) {
+	ctx := context.Background()
+	db, statements := openDryRun(t, gormmysql.New(gormmysql.Config{DSN: "user@tcp(localhost)/db", SkipInitializeWithVersion: true}))
+	manager := NewPartitionManager(db, true, 1000000).(*mysqlPartitionManager)
+	// The dry run can't read the existing partitions, so start as if the first one had been created with the table,
+	// along with pmax
+	manager.upperBounds["txs"] = 1000000
+
+	for _, height := range []uint64{5, 1000000, 1500000, 3200000, 12} {
+		require.NoError(t, manager.EnsurePartition(ctx, "txs", height))
+	}
+	require.Equal(t, []string{
+		"ALTER TABLE txs REORGANIZE PARTITION pmax INTO (PARTITION p1 VALUES LESS THAN (2000000), PARTITION pmax VALUES LESS THAN MAXVALUE)",
+		"ALTER TABLE txs REORGANIZE PARTITION pmax INTO (PARTITION p3 VALUES LESS THAN (4000000), PARTITION pmax VALUES LESS THAN MAXVALUE)",
+	}, *statements)
+}
+
+// recordingPartitionManager records the heights it is asked for
+type recordingPartitionManager struct {
+	heights map[string][]uint64
+}
+
+func (m *recordingPartitionManager) EnsurePartition(_ context.Context, table string, height uint64) error {
+	m.heights[table] = append(m.heights[table], height)
+	return nil
+}
+
+func TestSaveEnsuresPartitions(t *testing.T) {
+	ctx := context.Background()
+	manager := &recordingPartitionManager{heights: make(map[string][]uint64)}
+
+	db := newTestImpl(t, &models.Block{})
+	db.Partitions = manager
+	require.NoError(t, db.SaveBlock(ctx, &models.Block{BlockID: models.BlockID{Hash: common.HexToHash("0x01")}, Header: models.Header{Height: 10}}))
+
+	txDb := newTestImpl(t, &models.Tx{}, &models.Message{})
+	txDb.Partitions = manager
+	require.NoError(t, txDb.SaveTxs(ctx, 1700000000, newTestTxs(t, 11, 2)))
+
+	require.Equal(t, map[string][]uint64{"txs": {10, 11, 11}}, manager.heights)
+}
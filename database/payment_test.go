@@ -0,0 +1,127 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/forbole/juno/v4/common"
+	"github.com/forbole/juno/v4/models"
+)
+
+func TestPaymentAccounts(t *testing.T) {
+	ctx := context.Background()
+	db := newTestImpl(t, &models.PaymentAccount{})
+
+	owner, other := common.HexToAddress("0x0f"), common.HexToAddress("0x0e")
+	first, second, third := common.HexToAddress("0x01"), common.HexToAddress("0x02"), common.HexToAddress("0x03")
+
+	require.NoError(t, db.SavePaymentAccount(ctx, &models.PaymentAccount{Addr: first, Owner: owner, Refundable: true, UpdateAt: 10, UpdateTime: 1000}))
+	require.NoError(t, db.SavePaymentAccount(ctx, &models.PaymentAccount{Addr: second, Owner: owner, Refundable: true, UpdateAt: 11, UpdateTime: 1100}))
+	require.NoError(t, db.SavePaymentAccount(ctx, &models.PaymentAccount{Addr: third, Owner: other, Refundable: true, UpdateAt: 12, UpdateTime: 1200}))
+
+	// The first account is later made non refundable
+	require.NoError(t, db.SavePaymentAccount(ctx, &models.PaymentAccount{Addr: first, Owner: owner, Refundable: false, UpdateAt: 20, UpdateTime: 2000}))
+
+	accounts, total, err := db.ListPaymentAccountsByOwner(ctx, owner, 10, 0)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), total)
+	require.Len(t, accounts, 2)
+	require.Equal(t, first, accounts[0].Addr)
+	require.False(t, accounts[0].Refundable)
+	require.Equal(t, int64(20), accounts[0].UpdateAt)
+	require.Equal(t, int64(2000), accounts[0].UpdateTime)
+	require.Equal(t, second, accounts[1].Addr)
+	require.True(t, accounts[1].Refundable)
+
+	accounts, total, err = db.ListPaymentAccountsByOwner(ctx, owner, 1, 1)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), total)
+	require.Len(t, accounts, 1)
+	require.Equal(t, second, accounts[0].Addr)
+
+	account, err := db.GetPaymentAccountByAddr(ctx, third)
+	require.NoError(t, err)
+	require.Equal(t, other, account.Owner)
+
+	_, err = db.GetPaymentAccountByAddr(ctx, common.HexToAddress("0x04"))
+	require.True(t, errors.Is(err, ErrPaymentAccountNotFound))
+}
+
+func TestOutFlows(t *testing.T) {
+	ctx := context.Background()
+	db := newTestImpl(t, &models.StreamRecordOutFlow{}, &models.StreamRecord{})
+
+	alice, bob := common.HexToAddress("0x01"), common.HexToAddress("0x02")
+	sp1, sp2 := common.HexToAddress("0x0a"), common.HexToAddress("0x0b")
+	// Rates beyond 64 bits
+	large, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	rate := func(i *big.Int) *common.Big { return (*common.Big)(new(big.Int).Set(i)) }
+
+	replace := func(account common.Address, height int64, outFlows []*models.StreamRecordOutFlow) bool {
+		t.Helper()
+		replaced, err := db.ReplaceOutFlows(ctx, account, height, outFlows)
+		require.NoError(t, err)
+		return replaced
+	}
+	require.NoError(t, db.SaveStreamRecord(ctx, &models.StreamRecord{Account: alice}))
+
+	require.True(t, replace(alice, 10, []*models.StreamRecordOutFlow{
+		{Account: alice, ToAddress: sp1, Rate: rate(large), Status: "OUT_FLOW_STATUS_ACTIVE", Height: 10},
+		{Account: alice, ToAddress: sp2, Rate: rate(big.NewInt(5)), Status: "OUT_FLOW_STATUS_ACTIVE", Height: 10},
+	}))
+	require.True(t, replace(bob, 11, []*models.StreamRecordOutFlow{
+		{Account: bob, ToAddress: sp1, Rate: rate(big.NewInt(7)), Status: "OUT_FLOW_STATUS_ACTIVE", Height: 11},
+		{Account: bob, ToAddress: sp1, Rate: rate(big.NewInt(100)), Status: "OUT_FLOW_STATUS_FROZEN", Height: 11},
+	}))
+
+	outFlows, err := db.ListOutFlowsByAccount(ctx, alice)
+	require.NoError(t, err)
+	require.Len(t, outFlows, 2)
+	require.Equal(t, sp1, outFlows[0].ToAddress)
+	require.Zero(t, large.Cmp(outFlows[0].Rate.Raw()))
+	require.Equal(t, sp2, outFlows[1].ToAddress)
+	require.Zero(t, big.NewInt(5).Cmp(outFlows[1].Rate.Raw()))
+
+	// The frozen flows don't count
+	sum, err := db.SumInflowBySP(ctx, sp1)
+	require.NoError(t, err)
+	require.Zero(t, new(big.Int).Add(large, big.NewInt(7)).Cmp(sum))
+
+	// The receivers no longer present are dropped
+	require.True(t, replace(alice, 20, []*models.StreamRecordOutFlow{
+		{Account: alice, ToAddress: sp2, Rate: rate(big.NewInt(9)), Status: "OUT_FLOW_STATUS_ACTIVE", Height: 20},
+	}))
+	outFlows, err = db.ListOutFlowsByAccount(ctx, alice)
+	require.NoError(t, err)
+	require.Len(t, outFlows, 1)
+	require.Equal(t, int64(20), outFlows[0].Height)
+	sum, err = db.SumInflowBySP(ctx, sp1)
+	require.NoError(t, err)
+	require.Zero(t, big.NewInt(7).Cmp(sum))
+
+	// No out flow clears the previous ones
+	require.True(t, replace(alice, 30, nil))
+	outFlows, err = db.ListOutFlowsByAccount(ctx, alice)
+	require.NoError(t, err)
+	require.Empty(t, outFlows)
+
+	// Out flows read before the cleared ones are stale, while an update of the stream record keeps the height
+	require.NoError(t, db.SaveStreamRecord(ctx, &models.StreamRecord{Account: alice, CrudTimestamp: 1}))
+	require.False(t, replace(alice, 25, []*models.StreamRecordOutFlow{
+		{Account: alice, ToAddress: sp2, Rate: rate(big.NewInt(9)), Status: "OUT_FLOW_STATUS_ACTIVE", Height: 25},
+	}))
+	outFlows, err = db.ListOutFlowsByAccount(ctx, alice)
+	require.NoError(t, err)
+	require.Empty(t, outFlows)
+	outFlows, err = db.ListOutFlowsByAccount(ctx, bob)
+	require.NoError(t, err)
+	require.Len(t, outFlows, 2)
+
+	sum, err = db.SumInflowBySP(ctx, common.HexToAddress("0x0c"))
+	require.NoError(t, err)
+	require.Zero(t, sum.Sign())
+}
@@ -0,0 +1,111 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/forbole/juno/v4/common"
+	"github.com/forbole/juno/v4/models"
+)
+
+func TestGetPermissionsByResource(t *testing.T) {
+	ctx := context.Background()
+	db := newTestImpl(t, &models.Permission{})
+
+	resourceID := common.HexToHash("0x0a")
+	require.NoError(t, db.SavePermission(ctx, &models.Permission{
+		PrincipalType: 1, PrincipalValue: "0x02", ResourceType: "RESOURCE_TYPE_BUCKET", ResourceID: resourceID,
+		PolicyID: common.HexToHash("0x02"), CreateTimestamp: 200,
+	}))
+	require.NoError(t, db.SavePermission(ctx, &models.Permission{
+		PrincipalType: 1, PrincipalValue: "0x01", ResourceType: "RESOURCE_TYPE_BUCKET", ResourceID: resourceID,
+		PolicyID: common.HexToHash("0x01"), CreateTimestamp: 100,
+	}))
+	require.NoError(t, db.SavePermission(ctx, &models.Permission{
+		PrincipalType: 1, PrincipalValue: "0x03", ResourceType: "RESOURCE_TYPE_BUCKET", ResourceID: resourceID,
+		PolicyID: common.HexToHash("0x03"), CreateTimestamp: 300,
+	}))
+	require.NoError(t, db.SavePermission(ctx, &models.Permission{
+		PrincipalType: 1, PrincipalValue: "0x01", ResourceType: "RESOURCE_TYPE_OBJECT", ResourceID: resourceID,
+		PolicyID: common.HexToHash("0x04"), CreateTimestamp: 50,
+	}))
+	require.NoError(t, db.UpdatePermission(ctx, &models.Permission{PolicyID: common.HexToHash("0x03"), Removed: true}))
+
+	permissions, err := db.GetPermissionsByResource(ctx, "RESOURCE_TYPE_BUCKET", resourceID)
+	require.NoError(t, err)
+	require.Len(t, permissions, 2)
+	require.Equal(t, common.HexToHash("0x01"), permissions[0].PolicyID)
+	require.Equal(t, common.HexToHash("0x02"), permissions[1].PolicyID)
+
+	permission, err := db.GetPermissionByPolicyID(ctx, common.HexToHash("0x04"))
+	require.NoError(t, err)
+	require.Equal(t, "RESOURCE_TYPE_OBJECT", permission.ResourceType)
+
+	_, err = db.GetPermissionByPolicyID(ctx, common.HexToHash("0x03"))
+	require.True(t, errors.Is(err, ErrPermissionNotFound))
+}
+
+func TestExpirePermissionsBefore(t *testing.T) {
+	ctx := context.Background()
+	db := newTestImpl(t, &models.Permission{})
+
+	// expired, expired, not expired yet, never expiring, expired but already removed, and expired but updated
+	// after the height of the sweep
+	for i, expiration := range []int64{100, 200, 500, 0, 150, 120} {
+		require.NoError(t, db.SavePermission(ctx, &models.Permission{
+			PrincipalType: 1, PrincipalValue: "0x01", ResourceType: "RESOURCE_TYPE_BUCKET",
+			ResourceID: common.BigToHash(big.NewInt(int64(i + 1))), PolicyID: common.BigToHash(big.NewInt(int64(i + 1))),
+			ExpirationTime: expiration, Removed: i == 4, UpdateAt: int64(i), UpdateTxHash: common.HexToHash("0x01"),
+		}))
+	}
+
+	// The batches are bounded by the limit
+	expired, err := db.ExpirePermissionsBefore(ctx, 4, 300, 1)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), expired)
+	expired, err = db.ExpirePermissionsBefore(ctx, 4, 300, 10)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), expired)
+	expired, err = db.ExpirePermissionsBefore(ctx, 4, 300, 10)
+	require.NoError(t, err)
+	require.Zero(t, expired)
+
+	for i, removed := range []bool{true, true, false, false, true, false} {
+		var permission models.Permission
+		require.NoError(t, db.Db.Where("policy_id = ?", common.BigToHash(big.NewInt(int64(i+1)))).Take(&permission).Error)
+		require.Equal(t, removed, permission.Removed, "policy %d", i+1)
+	}
+
+	// The expired policies are marked at the height of the sweep, without any tx
+	var permission models.Permission
+	require.NoError(t, db.Db.Where("policy_id = ?", common.BigToHash(big.NewInt(2))).Take(&permission).Error)
+	require.Equal(t, int64(4), permission.UpdateAt)
+	require.Equal(t, common.Hash{}, permission.UpdateTxHash)
+}
+
+func TestExpireStatementsBefore(t *testing.T) {
+	ctx := context.Background()
+	db := newTestImpl(t, &models.Statements{})
+
+	policyID := common.HexToHash("0x01")
+	require.NoError(t, db.MultiSaveStatement(ctx, []*models.Statements{
+		{PolicyID: policyID, ExpirationTime: 100},
+		{PolicyID: policyID, ExpirationTime: 300},
+		{PolicyID: policyID, ExpirationTime: 0},
+		{PolicyID: policyID, ExpirationTime: 200},
+	}))
+
+	expired, err := db.ExpireStatementsBefore(ctx, 10, 300, 10)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), expired)
+
+	statements, err := db.GetStatementsByPolicyID(ctx, policyID, false)
+	require.NoError(t, err)
+	require.Len(t, statements, 2)
+	require.Equal(t, int64(300), statements[0].ExpirationTime)
+	require.Zero(t, statements[1].ExpirationTime)
+}
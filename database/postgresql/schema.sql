@@ -24,7 +24,7 @@ CREATE TABLE pre_commit
     timestamp         TIMESTAMP WITHOUT TIME ZONE NOT NULL,
     voting_power      BIGINT                      NOT NULL,
     proposer_priority BIGINT                      NOT NULL,
-    UNIQUE (validator_address, timestamp)
+    UNIQUE (validator_address, height)
 );
 CREATE INDEX pre_commit_validator_address_index ON pre_commit (validator_address);
 CREATE INDEX pre_commit_height_index ON pre_commit (height);
@@ -96,5 +96,6 @@ $$ LANGUAGE sql STABLE;
 
 CREATE TABLE pruning
 (
-    last_pruned_height BIGINT NOT NULL
+    one_row_id         BOOLEAN NOT NULL DEFAULT TRUE PRIMARY KEY,
+    last_pruned_height BIGINT  NOT NULL
 )
\ No newline at end of file
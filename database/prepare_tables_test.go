@@ -0,0 +1,64 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm/schema"
+
+	"github.com/forbole/juno/v4/common"
+	"github.com/forbole/juno/v4/models"
+)
+
+func TestPrepareTablesCreatesHotPathIndexes(t *testing.T) {
+	skipUnlessSQLite(t)
+
+	for _, tc := range []struct {
+		table schema.Tabler
+		index string
+		// columns lists the leading columns of the index, in order
+		columns []string
+	}{
+		{&models.Object{}, "idx_bucket_name_object_name", []string{"bucket_name", "object_name"}},
+		{&models.Permission{}, "idx_resource", []string{"resource_id", "resource_type"}},
+		{&models.Statements{}, "idx_policy_id", []string{"policy_id"}},
+		{&models.Group{}, "idx_account_group", []string{"account_id"}},
+		{&models.Tx{}, "idx_tx_hash", []string{"hash", "height"}},
+	} {
+		t.Run(tc.table.TableName(), func(t *testing.T) {
+			// Some index names are shared by several tables, which sqlite doesn't allow inside a single database
+			db := newTestImpl(t, tc.table)
+
+			m := db.Db.Migrator()
+			require.True(t, m.HasIndex(tc.table, tc.index))
+
+			// The sqlite migrator can't list indexes, so their columns are read from sqlite itself
+			var columns []string
+			err := db.Db.Raw("SELECT name FROM pragma_index_info(?) ORDER BY seqno", tc.index).Scan(&columns).Error
+			require.NoError(t, err)
+			require.Equal(t, tc.columns, columns[:len(tc.columns)])
+		})
+	}
+}
+
+// legacyStorageProvider is the storage providers table as it was before its sp id index became unique
+type legacyStorageProvider struct {
+	ID   uint64 `gorm:"column:id;primaryKey"`
+	SpId uint32 `gorm:"column:sp_id;index:idx_sp_id"`
+}
+
+func (*legacyStorageProvider) TableName() string { return "storage_providers" }
+
+func TestAutoMigrateReplacesIndexes(t *testing.T) {
+	ctx := context.Background()
+	db := newTestImpl(t, &legacyStorageProvider{})
+	require.NoError(t, db.AutoMigrate(ctx, []schema.Tabler{&models.StorageProvider{}}))
+
+	m := db.Db.Migrator()
+	require.False(t, m.HasIndex(&models.StorageProvider{}, "idx_sp_id"))
+	require.True(t, m.HasIndex(&models.StorageProvider{}, "idx_unique_sp_id"))
+
+	require.NoError(t, db.Db.Create(newTestStorageProvider(1, common.HexToAddress("0x01"))).Error)
+	require.Error(t, db.Db.Create(newTestStorageProvider(1, common.HexToAddress("0x02"))).Error)
+}
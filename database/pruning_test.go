@@ -0,0 +1,56 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm/schema"
+
+	"github.com/forbole/juno/v4/models"
+	"github.com/forbole/juno/v4/types"
+)
+
+func TestPrune(t *testing.T) {
+	ctx := context.Background()
+	db := newTestImpl(t, &models.Tx{}, &models.Message{}, &models.CommitSig{}, &models.BlockResult{})
+
+	for height := int64(1); height <= 3; height++ {
+		require.NoError(t, db.SaveBlockResult(ctx, uint64(height), []byte("{}")))
+		require.NoError(t, db.SaveTx(ctx, 0, 0, newTestTx(t, fmt.Sprintf("0x%02x", height), height)))
+		require.NoError(t, db.SaveCommitSignatures(ctx, []*types.CommitSig{
+			types.NewCommitSig("mevalcons1", 10, 0, height, time.Unix(height, 0)),
+		}))
+	}
+
+	require.NoError(t, db.Prune(ctx, 2))
+
+	for _, table := range []schema.Tabler{&models.Tx{}, &models.Message{}, &models.CommitSig{}} {
+		var heights []uint64
+		require.NoError(t, db.Db.Table(table.TableName()).Order("height").Pluck("height", &heights).Error)
+		require.Equal(t, []uint64{1, 3}, heights, table.TableName())
+	}
+
+	_, err := db.GetBlockResult(ctx, 2)
+	require.ErrorIs(t, err, ErrBlockResultNotFound)
+	_, err = db.GetBlockResult(ctx, 3)
+	require.NoError(t, err)
+}
+
+func TestLastPruned(t *testing.T) {
+	ctx := context.Background()
+	db := newTestImpl(t, &models.Pruning{})
+
+	height, err := db.GetLastPruned(ctx)
+	require.NoError(t, err)
+	require.Zero(t, height)
+
+	require.NoError(t, db.StoreLastPruned(ctx, 10))
+	require.NoError(t, db.StoreLastPruned(ctx, 20))
+
+	height, err = db.GetLastPruned(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(20), height)
+}
@@ -0,0 +1,60 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm/schema"
+
+	"github.com/forbole/juno/v4/common"
+	"github.com/forbole/juno/v4/models"
+)
+
+// recordingPartitionManager records the heights it is asked to create the partitions of
+type recordingPartitionManager struct {
+	heights []uint64
+}
+
+// EnsurePartition implements PartitionManager
+func (m *recordingPartitionManager) EnsurePartition(_ context.Context, _ string, height uint64) error {
+	m.heights = append(m.heights, height)
+	return nil
+}
+
+func TestReadOnly(t *testing.T) {
+	ctx := context.Background()
+	db := newTestImpl(t, &models.Block{}, &models.Epoch{}, &models.Permission{})
+	require.NoError(t, db.SaveEpoch(ctx, &models.Epoch{OneRowId: true, BlockHeight: 10}))
+
+	db.ReadOnly = true
+	partitions := &recordingPartitionManager{}
+	db.Partitions = partitions
+	block := &models.Block{BlockID: models.BlockID{Hash: common.HexToHash("0x01")}, Header: models.Header{Height: 11}}
+	require.ErrorIs(t, db.SaveBlock(ctx, block), ErrReadOnly)
+	require.ErrorIs(t, db.SaveTxs(ctx, 1700000000, newTestTxs(t, 11, 1)), ErrReadOnly)
+	// Not even the partitions get created
+	require.Empty(t, partitions.heights)
+	require.ErrorIs(t, db.SavePermission(ctx, &models.Permission{PolicyID: common.HexToHash("0x01")}), ErrReadOnly)
+	require.ErrorIs(t, db.PrepareTables(ctx, []schema.Tabler{&models.Bucket{}}), ErrReadOnly)
+
+	// Nothing has been written
+	has, err := db.HasBlock(ctx, 11)
+	require.NoError(t, err)
+	require.False(t, has)
+
+	require.NoError(t, db.Ping(ctx))
+	epoch, err := db.GetEpoch(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(10), epoch.BlockHeight)
+
+	// The transactions reject the writes as well
+	tx := db.Begin(ctx)
+	require.NoError(t, tx.Db.Error)
+	defer tx.Rollback()
+	require.ErrorIs(t, tx.SaveBlock(ctx, block), ErrReadOnly)
+	require.ErrorIs(t, db.SaveEpoch(ContextWithTx(ctx, tx), &models.Epoch{OneRowId: true, BlockHeight: 11}), ErrReadOnly)
+	epoch, err = tx.GetEpoch(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(10), epoch.BlockHeight)
+}
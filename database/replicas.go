@@ -0,0 +1,49 @@
+package database
+
+import (
+	"context"
+	"sync/atomic"
+
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+type readFromPrimaryKey struct{}
+
+// ReadFromPrimary returns a copy of ctx making the reads run with it go to the primary database even when
+// read replicas are configured. Callers which can't tolerate the replication lag, like the ones deciding
+// where the parser resumes from and the handlers writing what they read, must use it.
+func ReadFromPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, readFromPrimaryKey{}, true)
+}
+
+// readsFromPrimary tells whether the given context has been built with ReadFromPrimary
+func readsFromPrimary(ctx context.Context) bool {
+	primary, _ := ctx.Value(readFromPrimaryKey{}).(bool)
+	return primary
+}
+
+// onPrimary returns the given gorm handle with its queries pinned to the primary database
+func onPrimary(db *gorm.DB) *gorm.DB {
+	return db.Clauses(dbresolver.Write)
+}
+
+// RegisterReplicas makes the reads run through the given gorm handle, raw or not, go to the given replicas in
+// turn through the dbresolver plugin. Everything else stays on the primary database: writes, anything run inside
+// a transaction, and the queries whose context has been built with ReadFromPrimary.
+func RegisterReplicas(db *gorm.DB, replicas []gorm.Dialector) error {
+	if len(replicas) == 0 {
+		return nil
+	}
+	return db.Use(dbresolver.Register(dbresolver.Config{Replicas: replicas, Policy: &roundRobinPolicy{}}))
+}
+
+// roundRobinPolicy sends the reads to the replicas one after the other
+type roundRobinPolicy struct {
+	next atomic.Uint64
+}
+
+// Resolve implements dbresolver.Policy
+func (p *roundRobinPolicy) Resolve(connPools []gorm.ConnPool) gorm.ConnPool {
+	return connPools[(p.next.Add(1)-1)%uint64(len(connPools))]
+}
@@ -0,0 +1,80 @@
+package database
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/plugin/dbresolver"
+
+	"github.com/forbole/juno/v4/common"
+	"github.com/forbole/juno/v4/models"
+)
+
+// openFileDb opens the sqlite database stored inside the given file, creating the buckets table
+func openFileDb(t *testing.T, path string) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(path), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = sqlDB.Close() })
+
+	require.NoError(t, db.AutoMigrate(&models.Bucket{}))
+	return db
+}
+
+func TestReplicas(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	primary := openFileDb(t, filepath.Join(dir, "primary.db"))
+	replica := openFileDb(t, filepath.Join(dir, "replica.db"))
+	require.NoError(t, RegisterErrorTranslation(primary))
+	require.NoError(t, RegisterReplicas(primary, []gorm.Dialector{sqlite.Open(filepath.Join(dir, "replica.db"))}))
+	db := &Impl{Db: primary}
+
+	countBuckets := func(gormDb *gorm.DB) int64 {
+		var count int64
+		require.NoError(t, gormDb.Raw("SELECT COUNT(*) FROM buckets").Scan(&count).Error)
+		return count
+	}
+
+	// Writes go to the primary only
+	primaryID := common.HexToHash("0x01")
+	require.NoError(t, db.SaveBucket(ctx, &models.Bucket{BucketID: primaryID, BucketName: "primary"}))
+	require.Equal(t, int64(0), countBuckets(replica))
+
+	// Reads go to the replica, which hasn't received the bucket
+	_, err := db.GetBucketByID(ctx, primaryID)
+	require.ErrorIs(t, err, ErrBucketNotFound)
+
+	replicaID := common.HexToHash("0x02")
+	require.NoError(t, replica.Create(&models.Bucket{BucketID: replicaID, BucketName: "replica"}).Error)
+	bucket, err := db.GetBucketByID(ctx, replicaID)
+	require.NoError(t, err)
+	require.Equal(t, "replica", bucket.BucketName)
+
+	// Unless the caller asks for the primary
+	bucket, err = db.GetBucketByID(ReadFromPrimary(ctx), primaryID)
+	require.NoError(t, err)
+	require.Equal(t, "primary", bucket.BucketName)
+
+	// Raw reads go to the replica too, unless pinned to the primary like the ping checking the one written to
+	require.NoError(t, db.SaveBucket(ctx, &models.Bucket{BucketID: common.HexToHash("0x03"), BucketName: "other"}))
+	require.Equal(t, int64(1), countBuckets(primary))
+	require.Equal(t, int64(2), countBuckets(primary.Clauses(dbresolver.Write)))
+	require.NoError(t, db.Ping(ctx))
+
+	// Transactions run entirely on the primary
+	tx := db.Begin(ctx)
+	bucket, err = tx.GetBucketByID(ctx, primaryID)
+	require.NoError(t, err)
+	require.Equal(t, "primary", bucket.BucketName)
+	tx.Rollback()
+}
@@ -0,0 +1,93 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/forbole/juno/v4/log"
+)
+
+// DefaultMaxRetries is the number of times a write failing with ErrSerialization is retried when no other
+// limit is configured
+const DefaultMaxRetries = 3
+
+var (
+	// retryBaseDelay is the wait before the first retry, doubled on every following attempt
+	retryBaseDelay = 50 * time.Millisecond
+
+	// retryMaxDelay caps the wait between two attempts
+	retryMaxDelay = 2 * time.Second
+)
+
+// maxRetries returns the configured number of retries; a negative value disables them
+func (db *Impl) maxRetries() int {
+	switch {
+	case db.MaxRetries < 0:
+		return 0
+	case db.MaxRetries == 0:
+		return DefaultMaxRetries
+	default:
+		return db.MaxRetries
+	}
+}
+
+// retry runs the given write, running it again with a jittered exponential backoff while it fails with
+// ErrSerialization. Inside a transaction opened with Begin nothing is retried, since the failed statement
+// has already invalidated the transaction: ErrTxRetryable is returned so that the caller retries it as a whole.
+func (db *Impl) retry(ctx context.Context, write func() error) error {
+	// Every write goes through here, so this is where the ones made in read-only mode or inside
+	// a read-only transaction get rejected
+	if err := db.checkWritable(ctx); err != nil {
+		return err
+	}
+	tx := db.active(ctx)
+
+	err := write()
+	if !errors.Is(err, ErrSerialization) {
+		return err
+	}
+	if tx.inTx {
+		return fmt.Errorf("%w: %w", ErrTxRetryable, err)
+	}
+
+	for attempt := 0; attempt < db.maxRetries(); attempt++ {
+		select {
+		case <-ctx.Done():
+			return errors.Join(err, ctx.Err())
+		case <-time.After(retryDelay(attempt)):
+		}
+
+		log.DBRetryCount.Inc()
+		if err = write(); !errors.Is(err, ErrSerialization) {
+			return err
+		}
+	}
+	return err
+}
+
+// retryDelay returns the wait before the given retry, picked at random between half and the whole
+// of its exponential backoff so that concurrent writers don't collide again
+func retryDelay(attempt int) time.Duration {
+	delay := retryMaxDelay
+	if attempt < 32 && retryBaseDelay<<attempt < retryMaxDelay {
+		delay = retryBaseDelay << attempt
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// checkWritable returns ErrReadOnly in read-only mode, or ErrReadOnlyTx inside a read-only transaction, for the
+// writes made with ctx. The writes made before going through retry, like the creation of the partitions, must
+// check it first.
+func (db *Impl) checkWritable(ctx context.Context) error {
+	tx := db.active(ctx)
+	if tx.ReadOnly {
+		return ErrReadOnly
+	}
+	if tx.readOnly {
+		return ErrReadOnlyTx
+	}
+	return nil
+}
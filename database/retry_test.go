@@ -0,0 +1,106 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/forbole/juno/v4/common"
+	"github.com/forbole/juno/v4/log"
+	"github.com/forbole/juno/v4/models"
+)
+
+// failCreates makes the next n inserts run through db fail with a MySQL deadlock
+func failCreates(t *testing.T, db *Impl, n int) {
+	t.Helper()
+
+	err := db.Db.Callback().Create().Before("gorm:create").Register("test:deadlock", func(tx *gorm.DB) {
+		if n > 0 {
+			n--
+			_ = tx.AddError(&mysql.MySQLError{Number: 1213, Message: "Deadlock found when trying to get lock"})
+		}
+	})
+	require.NoError(t, err)
+}
+
+func TestRetry(t *testing.T) {
+	ctx := context.Background()
+
+	baseDelay := retryBaseDelay
+	retryBaseDelay = time.Millisecond
+	t.Cleanup(func() { retryBaseDelay = baseDelay })
+
+	bucket := func() *models.Bucket {
+		return &models.Bucket{BucketID: common.HexToHash("0x01"), BucketName: "bucket"}
+	}
+
+	t.Run("succeeds after transient failures", func(t *testing.T) {
+		db := newTestImpl(t, &models.Bucket{})
+		failCreates(t, db, 2)
+		retries := testutil.ToFloat64(log.DBRetryCount)
+
+		require.NoError(t, db.SaveBucket(ctx, bucket()))
+		require.Equal(t, float64(2), testutil.ToFloat64(log.DBRetryCount)-retries)
+
+		_, err := db.GetBucketByID(ctx, common.HexToHash("0x01"))
+		require.NoError(t, err)
+	})
+
+	t.Run("gives up after max retries", func(t *testing.T) {
+		db := newTestImpl(t, &models.Bucket{})
+		db.MaxRetries = 2
+		failCreates(t, db, 3)
+
+		err := db.SaveBucket(ctx, bucket())
+		require.ErrorIs(t, err, ErrSerialization)
+		require.NotErrorIs(t, err, ErrTxRetryable)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		db := newTestImpl(t, &models.Bucket{})
+		db.MaxRetries = -1
+		failCreates(t, db, 1)
+		retries := testutil.ToFloat64(log.DBRetryCount)
+
+		require.ErrorIs(t, db.SaveBucket(ctx, bucket()), ErrSerialization)
+		require.Equal(t, retries, testutil.ToFloat64(log.DBRetryCount))
+	})
+
+	t.Run("not retried inside a transaction", func(t *testing.T) {
+		db := newTestImpl(t, &models.Bucket{})
+		failCreates(t, db, 1)
+		retries := testutil.ToFloat64(log.DBRetryCount)
+
+		tx := db.Begin(ctx)
+		err := tx.SaveBucket(ctx, bucket())
+		tx.Rollback()
+		require.ErrorIs(t, err, ErrTxRetryable)
+		require.ErrorIs(t, err, ErrSerialization)
+		require.Equal(t, retries, testutil.ToFloat64(log.DBRetryCount))
+
+		// The caller retries the whole transaction, which now goes through
+		require.NoError(t, WithTx(ctx, db, func(tx Database) error {
+			return tx.SaveBucket(ctx, bucket())
+		}))
+	})
+
+	t.Run("stops when the context is done", func(t *testing.T) {
+		db := newTestImpl(t, &models.Bucket{})
+		failCreates(t, db, 1)
+		retryBaseDelay = time.Hour
+
+		timeoutCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		err := db.SaveBucket(timeoutCtx, bucket())
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.ErrorIs(t, err, ErrSerialization)
+		require.Less(t, time.Since(start), retryMaxDelay/2)
+	})
+}
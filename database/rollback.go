@@ -0,0 +1,206 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+
+	"github.com/forbole/juno/v4/models"
+)
+
+// rollbackTable is a module table whose rows written above a height get deleted by DeleteDataAfterHeight
+type rollbackTable struct {
+	table schema.Tabler
+	// column holds the height at which each row was written
+	column string
+}
+
+var (
+	rollbackTablesMu sync.Mutex
+	// rollbackTables are the module tables registered for rollbacks, keyed by table name
+	rollbackTables = make(map[string]rollbackTable)
+)
+
+// RegisterRollbackTable makes DeleteDataAfterHeight delete the rows of the given table whose heightColumn is
+// above the height, so that a module can contribute its own data to the rollbacks. The tables prepared through
+// PrepareTables having an update_at column are registered already.
+func RegisterRollbackTable(table schema.Tabler, heightColumn string) {
+	rollbackTablesMu.Lock()
+	defer rollbackTablesMu.Unlock()
+	rollbackTables[table.TableName()] = rollbackTable{table: table, column: heightColumn}
+}
+
+// registerUpdateAtTable registers the given table for rollbacks if it has an update_at column
+func (db *Impl) registerUpdateAtTable(t schema.Tabler) error {
+	stmt := &gorm.Statement{DB: db.Db}
+	if err := stmt.Parse(t); err != nil {
+		return err
+	}
+	if _, ok := stmt.Schema.FieldsByDBName["update_at"]; ok {
+		RegisterRollbackTable(t, "update_at")
+	}
+	return nil
+}
+
+// DeleteDataAfterHeight implements database.Database
+func (db *Impl) DeleteDataAfterHeight(ctx context.Context, height uint64) error {
+	return db.retry(ctx, func() error {
+		return db.withContext(ctx).Transaction(func(gormTx *gorm.DB) error {
+			for _, t := range registeredRollbackTables() {
+				if err := db.checkRollbackKeepsState(gormTx, t, height); err != nil {
+					return err
+				}
+			}
+
+			txHashes := gormTx.Session(&gorm.Session{NewDB: true}).Table((&models.Tx{}).TableName()).
+				Select("hash").
+				Where("chain_id = ? AND height > ?", db.ChainID, height)
+			err := db.deleteAfter(gormTx, &models.Message{}, "height", height, false, func(q *gorm.DB) *gorm.DB {
+				return q.Where("tx_hash IN (?)", txHashes)
+			})
+			if err != nil {
+				return err
+			}
+
+			for _, t := range []struct {
+				model       schema.Tabler
+				column      string
+				chainScoped bool
+			}{
+				{&models.Tx{}, "height", true},
+				{&models.Event{}, "height", true},
+				{&models.CommitSig{}, "height", true},
+				{&models.BlockResult{}, "block_height", true},
+				{&models.BlockProgress{}, "height", true},
+			} {
+				if err = db.deleteAfter(gormTx, t.model, t.column, height, t.chainScoped, nil); err != nil {
+					return err
+				}
+			}
+
+			for _, t := range registeredRollbackTables() {
+				if err = db.deleteAfter(gormTx, t.table, t.column, height, db.hasChainID(t.table), nil); err != nil {
+					return err
+				}
+			}
+
+			if err = db.deleteAfter(gormTx, &models.Block{}, "height", height, true, nil); err != nil {
+				return err
+			}
+			return db.rewindEpoch(gormTx, height)
+		})
+	})
+}
+
+// checkRollbackKeepsState returns ErrRollbackLosesState if the given table has rows updated above the height,
+// that were created at or below it. Those rows would be deleted rather than brought back to their state at the
+// height, which isn't kept. The rows of a table without creation height are all deemed created at or below it.
+func (db *Impl) checkRollbackKeepsState(gormTx *gorm.DB, t rollbackTable, height uint64) error {
+	if t.column != "update_at" || !gormTx.Migrator().HasTable(t.table.TableName()) {
+		return nil
+	}
+
+	q := gormTx.Table(t.table.TableName()).Where("update_at > ?", height)
+	if db.hasChainID(t.table) {
+		q = q.Where("chain_id = ?", db.ChainID)
+	}
+	if db.hasColumn(t.table, "create_at") {
+		q = q.Where("create_at <= ?", height)
+	}
+
+	var count int64
+	if err := q.Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to check %s after height %d: %s", t.table.TableName(), height, err)
+	}
+	if count > 0 {
+		return fmt.Errorf("%d rows of %s: %w", count, t.table.TableName(), ErrRollbackLosesState)
+	}
+	return nil
+}
+
+// registeredRollbackTables returns the tables registered for rollbacks, sorted by name so that they are always
+// cleaned in the same order
+func registeredRollbackTables() []rollbackTable {
+	rollbackTablesMu.Lock()
+	defer rollbackTablesMu.Unlock()
+
+	tables := make([]rollbackTable, 0, len(rollbackTables))
+	for _, t := range rollbackTables {
+		tables = append(tables, t)
+	}
+	sort.Slice(tables, func(i, j int) bool { return tables[i].table.TableName() < tables[j].table.TableName() })
+	return tables
+}
+
+// deleteAfter deletes the rows of the given table whose column is above height, restricted to the chain of
+// this Impl when chainScoped. A table that doesn't exist, like the one of a disabled feature, is skipped.
+func (db *Impl) deleteAfter(
+	gormTx *gorm.DB, model schema.Tabler, column string, height uint64, chainScoped bool, scope func(*gorm.DB) *gorm.DB,
+) error {
+	if !gormTx.Migrator().HasTable(model.TableName()) {
+		return nil
+	}
+
+	q := gormTx.Table(model.TableName()).Where(fmt.Sprintf("%s > ?", column), height)
+	if chainScoped {
+		q = q.Where("chain_id = ?", db.ChainID)
+	}
+	if scope != nil {
+		q = scope(q)
+	}
+	if err := q.Delete(model).Error; err != nil {
+		return fmt.Errorf("failed to delete %s after height %d: %s", model.TableName(), height, err)
+	}
+	return nil
+}
+
+// hasChainID tells whether the rows of the given table carry the id of their chain
+func (db *Impl) hasChainID(t schema.Tabler) bool {
+	return db.hasColumn(t, "chain_id")
+}
+
+// hasColumn tells whether the model of the given table has the given column
+func (db *Impl) hasColumn(t schema.Tabler, column string) bool {
+	stmt := &gorm.Statement{DB: db.Db}
+	if err := stmt.Parse(t); err != nil {
+		return false
+	}
+	_, ok := stmt.Schema.FieldsByDBName[column]
+	return ok
+}
+
+// rewindEpoch moves the epoch back to the block stored at the given height if it is past it,
+// removing it when no such block is stored
+func (db *Impl) rewindEpoch(gormTx *gorm.DB, height uint64) error {
+	var epoch models.Epoch
+	if !gormTx.Migrator().HasTable(epoch.TableName()) {
+		return nil
+	}
+
+	err := gormTx.Table(epoch.TableName()).Where("chain_id = ?", db.ChainID).Take(&epoch).Error
+	if errIsNotFound(err) || (err == nil && uint64(epoch.BlockHeight) <= height) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var block models.Block
+	err = gormTx.Table(block.TableName()).Where("chain_id = ? AND height = ?", db.ChainID, height).Take(&block).Error
+	if errIsNotFound(err) {
+		return gormTx.Table(epoch.TableName()).Where("chain_id = ?", db.ChainID).Delete(&models.Epoch{}).Error
+	}
+	if err != nil {
+		return err
+	}
+
+	return gormTx.Table(epoch.TableName()).Where("chain_id = ?", db.ChainID).Updates(map[string]interface{}{
+		"block_height": block.Height,
+		"block_hash":   block.Hash,
+		"update_time":  block.Timestamp,
+	}).Error
+}
@@ -0,0 +1,112 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/forbole/juno/v4/common"
+	"github.com/forbole/juno/v4/models"
+)
+
+func TestDeleteDataAfterHeight(t *testing.T) {
+	ctx := context.Background()
+	mainnet, testnet := newTestChains(t, &models.Block{}, &models.Epoch{}, &models.Bucket{}, &models.Event{},
+		&models.CommitSig{}, &models.BlockResult{})
+
+	saveChainBlocks(t, mainnet, 1, 2, 3, 4, 5)
+	saveChainBlocks(t, testnet, 1, 2, 3, 4, 5)
+	for _, db := range []*Impl{mainnet, testnet} {
+		require.NoError(t, db.SaveEpoch(ctx, &models.Epoch{OneRowId: true, BlockHeight: 5}))
+		for _, updateAt := range []int64{2, 5} {
+			name := fmt.Sprintf("%s%d", db.ChainID, updateAt)
+			require.NoError(t, db.SaveBucket(ctx, &models.Bucket{
+				BucketID:   common.HexToHash(fmt.Sprintf("%x", name)),
+				BucketName: name,
+				CreateAt:   updateAt,
+				UpdateAt:   updateAt,
+			}))
+		}
+	}
+	for _, height := range []uint64{3, 4} {
+		for _, db := range []*Impl{mainnet, testnet} {
+			require.NoError(t, db.Db.Create(&models.Event{
+				ChainID: db.ChainID, Height: height, Origin: models.EventOriginBeginBlock, Type: "test", Attributes: "[]",
+			}).Error)
+			require.NoError(t, db.SaveBlockResult(ctx, height, []byte("{}")))
+		}
+	}
+
+	require.NoError(t, mainnet.DeleteDataAfterHeight(ctx, 3))
+
+	// The data of mainnet after the height is gone
+	last, err := mainnet.GetLastBlockHeight(ctx)
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), last)
+	_, err = mainnet.GetBucketByName(ctx, "mainnet2")
+	require.NoError(t, err)
+	_, err = mainnet.GetBucketByName(ctx, "mainnet5")
+	require.ErrorIs(t, err, ErrBucketNotFound)
+	events, err := mainnet.ListEventsByHeight(ctx, 3)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	events, err = mainnet.ListEventsByHeight(ctx, 4)
+	require.NoError(t, err)
+	require.Empty(t, events)
+	_, err = mainnet.GetBlockResult(ctx, 4)
+	require.ErrorIs(t, err, ErrBlockResultNotFound)
+
+	// while its epoch moved back to the block at the height
+	epoch, err := mainnet.GetEpoch(ctx)
+	require.NoError(t, err)
+	block, err := mainnet.GetBlockByHeight(ctx, 3)
+	require.NoError(t, err)
+	require.Equal(t, int64(3), epoch.BlockHeight)
+	require.Equal(t, block.Hash, epoch.BlockHash)
+
+	// The other chain is left untouched
+	last, err = testnet.GetLastBlockHeight(ctx)
+	require.NoError(t, err)
+	require.Equal(t, uint64(5), last)
+	_, err = testnet.GetBucketByName(ctx, "testnet5")
+	require.NoError(t, err)
+	epoch, err = testnet.GetEpoch(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(5), epoch.BlockHeight)
+	events, err = testnet.ListEventsByHeight(ctx, 4)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	_, err = testnet.GetBlockResult(ctx, 4)
+	require.NoError(t, err)
+
+	// An epoch already behind the height stays where it is
+	require.NoError(t, mainnet.DeleteDataAfterHeight(ctx, 4))
+	epoch, err = mainnet.GetEpoch(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(3), epoch.BlockHeight)
+}
+
+func TestDeleteDataAfterHeightLosingState(t *testing.T) {
+	ctx := context.Background()
+	db := newTestImpl(t, &models.Block{}, &models.Bucket{})
+	saveChainBlocks(t, db, 1, 2, 3)
+
+	// A bucket created before the height and updated after it
+	require.NoError(t, db.SaveBucket(ctx, &models.Bucket{BucketID: common.HexToHash("0x01"), BucketName: "bucket", CreateAt: 1, UpdateAt: 3}))
+	require.ErrorIs(t, db.DeleteDataAfterHeight(ctx, 2), ErrRollbackLosesState)
+
+	// Nothing has been deleted
+	last, err := db.GetLastBlockHeight(ctx)
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), last)
+	_, err = db.GetBucketByName(ctx, "bucket")
+	require.NoError(t, err)
+}
+
+func TestDeleteDataAfterHeightReadOnly(t *testing.T) {
+	db := newTestImpl(t, &models.Block{})
+	db.ReadOnly = true
+	require.ErrorIs(t, db.DeleteDataAfterHeight(context.Background(), 1), ErrReadOnly)
+}
@@ -1,20 +1,21 @@
 package sqlclient
 
 import (
-	"context"
 	"database/sql"
-	"errors"
+	"fmt"
 	"time"
 
 	"gorm.io/driver/mysql"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
-	"gorm.io/gorm/logger"
 
 	databaseconfig "github.com/forbole/juno/v4/database/config"
 	"github.com/forbole/juno/v4/log"
 )
 
+// SQLiteMemoryDSN is the DSN of an in-memory sqlite database, used when no DSN is configured
+const SQLiteMemoryDSN = "file::memory:"
+
 func New(cfg *databaseconfig.Config) (*gorm.DB, error) {
 	if cfg.Secrets != nil {
 		secret, err := databaseconfig.GetString(cfg.Secrets)
@@ -29,20 +30,20 @@ func New(cfg *databaseconfig.Config) (*gorm.DB, error) {
 	var err error
 	switch cfg.Type {
 	case databaseconfig.MySQL:
-		db, err = gorm.Open(mysql.Open(cfg.DSN),
-			&gorm.Config{
-				Logger:                                   &loggerAdaptor{slowThreshold: time.Duration(cfg.SlowThreshold)},
-				DisableForeignKeyConstraintWhenMigrating: true,
-			},
-		)
+		db, err = gorm.Open(mysql.Open(cfg.DSN), gormConfig(cfg))
 	case databaseconfig.PostgreSQL:
-		db, err = gorm.Open(postgres.Open(cfg.DSN),
-			&gorm.Config{
-				Logger:                                   &loggerAdaptor{slowThreshold: time.Duration(cfg.SlowThreshold)},
-				DisableForeignKeyConstraintWhenMigrating: true,
-				SkipDefaultTransaction:                   true,
-			},
-		)
+		gormCfg := gormConfig(cfg)
+		gormCfg.SkipDefaultTransaction = true
+		db, err = gorm.Open(postgres.Open(cfg.DSN), gormCfg)
+	case databaseconfig.SQLite:
+		if cfg.DSN == "" {
+			cfg.DSN = SQLiteMemoryDSN
+		}
+		// sqlite allows a single writer, and every connection to an in-memory DSN opens a brand-new database
+		cfg.MaxOpenConnections = 1
+		db, err = gorm.Open(openSQLite(cfg.DSN), gormConfig(cfg))
+	default:
+		return nil, fmt.Errorf("unsupported database type %s", cfg.Type)
 	}
 
 	if err != nil {
@@ -56,16 +57,88 @@ func New(cfg *databaseconfig.Config) (*gorm.DB, error) {
 		return nil, err
 	}
 
+	configurePool(sqlDB, cfg)
+	return db, nil
+}
+
+// NewReplicas opens a connection pool to each of the read replicas of the given config
+func NewReplicas(cfg *databaseconfig.Config) ([]*sql.DB, error) {
+	replicas := make([]*sql.DB, 0, len(cfg.ReadReplicas))
+	closeAll := func() {
+		for _, replica := range replicas {
+			_ = replica.Close()
+		}
+	}
+
+	for _, dsn := range cfg.ReadReplicas {
+		var dialector gorm.Dialector
+		switch cfg.Type {
+		case databaseconfig.MySQL:
+			dialector = mysql.Open(dsn)
+		case databaseconfig.PostgreSQL:
+			dialector = postgres.Open(dsn)
+		default:
+			closeAll()
+			return nil, fmt.Errorf("read replicas are not supported by database type %s", cfg.Type)
+		}
+
+		db, err := gorm.Open(dialector, gormConfig(cfg))
+		if err != nil {
+			log.Errorw("failed to open read replica", "err", err)
+			closeAll()
+			return nil, err
+		}
+		sqlDB, err := db.DB()
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+
+		configurePool(sqlDB, cfg)
+		replicas = append(replicas, sqlDB)
+	}
+	return replicas, nil
+}
+
+// ReplicaDialectors returns the dialectors running the statements of the given database type through the given
+// read replicas, opened by NewReplicas
+func ReplicaDialectors(cfg *databaseconfig.Config, replicas []*sql.DB) []gorm.Dialector {
+	dialectors := make([]gorm.Dialector, 0, len(replicas))
+	for _, replica := range replicas {
+		switch cfg.Type {
+		case databaseconfig.MySQL:
+			dialectors = append(dialectors, mysql.New(mysql.Config{Conn: replica}))
+		case databaseconfig.PostgreSQL:
+			dialectors = append(dialectors, postgres.New(postgres.Config{Conn: replica}))
+		}
+	}
+	return dialectors
+}
+
+// gormConfig returns the gorm settings shared by every database type
+func gormConfig(cfg *databaseconfig.Config) *gorm.Config {
+	return &gorm.Config{
+		Logger:                                   newLoggerAdaptor(cfg),
+		DisableForeignKeyConstraintWhenMigrating: true,
+		// Statements are prepared once per connection and then reused. Transactions opened with Begin
+		// share the same cache, since gorm wraps them with the prepared statements of their connection.
+		PrepareStmt: !cfg.DisablePreparedStatements,
+	}
+}
+
+// configurePool applies the connection pool settings of the given config to sqlDB,
+// filling in the defaults of the ones that are not set
+func configurePool(sqlDB *sql.DB, cfg *databaseconfig.Config) {
 	if cfg.MaxOpenConnections <= 0 {
 		cfg.MaxOpenConnections = 256
 	}
 	if cfg.MaxIdleConnections <= 0 {
 		cfg.MaxIdleConnections = cfg.MaxOpenConnections
 	}
-	if cfg.ConnMaxIdleTime <= databaseconfig.Duration(time.Minute) {
+	if cfg.ConnMaxIdleTime == 0 {
 		cfg.ConnMaxIdleTime = databaseconfig.Duration(5 * time.Minute)
 	}
-	if cfg.ConnMaxLifetime <= databaseconfig.Duration(time.Minute) {
+	if cfg.ConnMaxLifetime == 0 {
 		cfg.ConnMaxLifetime = databaseconfig.Duration(time.Hour)
 	}
 
@@ -73,41 +146,4 @@ func New(cfg *databaseconfig.Config) (*gorm.DB, error) {
 	sqlDB.SetMaxOpenConns(cfg.MaxOpenConnections)
 	sqlDB.SetConnMaxIdleTime(time.Duration(cfg.ConnMaxIdleTime))
 	sqlDB.SetConnMaxLifetime(time.Duration(cfg.ConnMaxLifetime))
-
-	return db, nil
-}
-
-type loggerAdaptor struct {
-	slowThreshold time.Duration
-}
-
-func (la *loggerAdaptor) LogMode(logger.LogLevel) logger.Interface {
-	return &loggerAdaptor{slowThreshold: la.slowThreshold}
-}
-
-func (*loggerAdaptor) Info(ctx context.Context, fmt string, args ...interface{}) {
-	log.With("module", "gorm").AddCallerSkip(1).CtxInfof(ctx, fmt, args...)
-}
-
-func (*loggerAdaptor) Warn(ctx context.Context, fmt string, args ...interface{}) {
-	log.With("module", "gorm").AddCallerSkip(1).CtxWarnf(ctx, fmt, args...)
-}
-
-func (*loggerAdaptor) Error(ctx context.Context, fmt string, args ...interface{}) {
-	log.With("module", "gorm").AddCallerSkip(1).CtxErrorf(ctx, fmt, args...)
-}
-func (la *loggerAdaptor) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
-	elapsed := time.Since(begin)
-	switch {
-	case err != nil:
-		// ignore not found
-		if errors.Is(err, sql.ErrNoRows) || errors.Is(err, gorm.ErrRecordNotFound) {
-			return
-		}
-		strSql, rows := fc()
-		log.CtxErrorw(ctx, "error sql", "err", err, "elapsed", elapsed, "sql", strSql, "rows", rows)
-	case elapsed > la.slowThreshold && la.slowThreshold != 0:
-		strSql, rows := fc()
-		log.CtxWarnw(ctx, "slow sql", "elapsed", elapsed, "sql", strSql, "rows", rows)
-	}
 }
@@ -0,0 +1,111 @@
+package sqlclient
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	databaseconfig "github.com/forbole/juno/v4/database/config"
+)
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = sqlDB.Close() })
+	return sqlDB
+}
+
+// openConns checks out n connections at once and gives them back to the pool
+func openConns(t *testing.T, sqlDB *sql.DB, n int) {
+	t.Helper()
+
+	conns := make([]*sql.Conn, n)
+	for i := range conns {
+		conn, err := sqlDB.Conn(context.Background())
+		require.NoError(t, err)
+		conns[i] = conn
+	}
+	for _, conn := range conns {
+		require.NoError(t, conn.Close())
+	}
+}
+
+func TestConfigurePool(t *testing.T) {
+	t.Run("configured", func(t *testing.T) {
+		sqlDB := openTestDB(t)
+		cfg := &databaseconfig.Config{
+			MaxOpenConnections: 8,
+			MaxIdleConnections: 2,
+			ConnMaxIdleTime:    databaseconfig.Duration(10 * time.Minute),
+			ConnMaxLifetime:    databaseconfig.Duration(30 * time.Minute),
+		}
+		configurePool(sqlDB, cfg)
+
+		openConns(t, sqlDB, 5)
+		stats := sqlDB.Stats()
+		require.Equal(t, 8, stats.MaxOpenConnections)
+		require.Equal(t, 2, stats.Idle)
+		require.Equal(t, int64(3), stats.MaxIdleClosed)
+
+		require.Equal(t, databaseconfig.Duration(10*time.Minute), cfg.ConnMaxIdleTime)
+		require.Equal(t, databaseconfig.Duration(30*time.Minute), cfg.ConnMaxLifetime)
+	})
+
+	t.Run("defaults", func(t *testing.T) {
+		sqlDB := openTestDB(t)
+		cfg := &databaseconfig.Config{}
+		configurePool(sqlDB, cfg)
+
+		openConns(t, sqlDB, 5)
+		stats := sqlDB.Stats()
+		require.Equal(t, 256, stats.MaxOpenConnections)
+		// The idle connections default to the max open ones, so none got closed
+		require.Equal(t, 5, stats.Idle)
+		require.Zero(t, stats.MaxIdleClosed)
+
+		require.Equal(t, databaseconfig.Duration(5*time.Minute), cfg.ConnMaxIdleTime)
+		require.Equal(t, databaseconfig.Duration(time.Hour), cfg.ConnMaxLifetime)
+	})
+
+	t.Run("short durations", func(t *testing.T) {
+		sqlDB := openTestDB(t)
+		cfg := &databaseconfig.Config{
+			ConnMaxIdleTime: databaseconfig.Duration(time.Second),
+			ConnMaxLifetime: databaseconfig.Duration(30 * time.Second),
+		}
+		configurePool(sqlDB, cfg)
+
+		require.Equal(t, databaseconfig.Duration(time.Second), cfg.ConnMaxIdleTime)
+		require.Equal(t, databaseconfig.Duration(30*time.Second), cfg.ConnMaxLifetime)
+	})
+}
+
+func TestGormConfig(t *testing.T) {
+	cfg := &databaseconfig.Config{}
+	require.True(t, gormConfig(cfg).PrepareStmt)
+
+	cfg.DisablePreparedStatements = true
+	require.False(t, gormConfig(cfg).PrepareStmt)
+}
+
+func TestReplicaDialectors(t *testing.T) {
+	replicas := []*sql.DB{openTestDB(t), openTestDB(t)}
+
+	dialectors := ReplicaDialectors(&databaseconfig.Config{Type: databaseconfig.MySQL}, replicas)
+	require.Len(t, dialectors, 2)
+	require.Equal(t, "mysql", dialectors[0].Name())
+
+	dialectors = ReplicaDialectors(&databaseconfig.Config{Type: databaseconfig.PostgreSQL}, replicas)
+	require.Len(t, dialectors, 2)
+	require.Equal(t, "postgres", dialectors[1].Name())
+}
@@ -0,0 +1,72 @@
+package sqlclient
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	databaseconfig "github.com/forbole/juno/v4/database/config"
+	"github.com/forbole/juno/v4/log"
+)
+
+// DefaultSlowThreshold is the duration above which a statement is logged as slow when no other one is configured
+const DefaultSlowThreshold = 200 * time.Millisecond
+
+// loggerAdaptor makes gorm log through the log package: failed statements are logged as errors and slow ones
+// as warnings, while all the others are logged at debug level only when tracing is enabled
+type loggerAdaptor struct {
+	slowThreshold time.Duration
+	traceSQL      bool
+}
+
+// newLoggerAdaptor returns the loggerAdaptor configured by the given config.
+// A negative slow threshold turns off the logging of slow statements.
+func newLoggerAdaptor(cfg *databaseconfig.Config) *loggerAdaptor {
+	slowThreshold := time.Duration(cfg.SlowThreshold)
+	switch {
+	case slowThreshold == 0:
+		slowThreshold = DefaultSlowThreshold
+	case slowThreshold < 0:
+		slowThreshold = 0
+	}
+	return &loggerAdaptor{slowThreshold: slowThreshold, traceSQL: cfg.TraceSQL}
+}
+
+func (la *loggerAdaptor) LogMode(logger.LogLevel) logger.Interface {
+	return &loggerAdaptor{slowThreshold: la.slowThreshold, traceSQL: la.traceSQL}
+}
+
+func (*loggerAdaptor) Info(ctx context.Context, fmt string, args ...interface{}) {
+	log.With("module", "gorm").AddCallerSkip(1).CtxInfof(ctx, fmt, args...)
+}
+
+func (*loggerAdaptor) Warn(ctx context.Context, fmt string, args ...interface{}) {
+	log.With("module", "gorm").AddCallerSkip(1).CtxWarnf(ctx, fmt, args...)
+}
+
+func (*loggerAdaptor) Error(ctx context.Context, fmt string, args ...interface{}) {
+	log.With("module", "gorm").AddCallerSkip(1).CtxErrorf(ctx, fmt, args...)
+}
+
+func (la *loggerAdaptor) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
+	elapsed := time.Since(begin)
+	switch {
+	case err != nil:
+		// ignore not found
+		if errors.Is(err, sql.ErrNoRows) || errors.Is(err, gorm.ErrRecordNotFound) {
+			return
+		}
+		strSql, rows := fc()
+		log.CtxErrorw(ctx, "error sql", "err", err, "elapsed", elapsed, "sql", strSql, "rows", rows)
+	case elapsed > la.slowThreshold && la.slowThreshold != 0:
+		strSql, rows := fc()
+		log.CtxWarnw(ctx, "slow sql", "elapsed", elapsed, "sql", strSql, "rows", rows)
+	case la.traceSQL:
+		strSql, rows := fc()
+		log.CtxDebugw(ctx, "sql", "elapsed", elapsed, "sql", strSql, "rows", rows)
+	}
+}
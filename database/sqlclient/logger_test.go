@@ -0,0 +1,150 @@
+package sqlclient
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	databaseconfig "github.com/forbole/juno/v4/database/config"
+	"github.com/forbole/juno/v4/log"
+)
+
+// sqliteWithSleep is a sqlite driver providing a sleep(ms) function, used to run deliberately slow statements
+const sqliteWithSleep = "sqlite3_with_sleep"
+
+func init() {
+	sql.Register(sqliteWithSleep, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			return conn.RegisterFunc("sleep", func(ms int) int {
+				time.Sleep(time.Duration(ms) * time.Millisecond)
+				return ms
+			}, false)
+		},
+	})
+}
+
+// logBuffer collects the log entries written by the log package
+type logBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (*logBuffer) Sync() error { return nil }
+
+func (*logBuffer) Stop() error { return nil }
+
+// entries returns the log entries written so far having the given message
+func (b *logBuffer) entries(t *testing.T, msg string) []map[string]interface{} {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var entries []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(b.buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var entry map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &entry))
+		if entry["msg"] == msg {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// captureLogs redirects the output of the log package to the returned buffer until the end of the test
+func captureLogs(t *testing.T, lvl log.Level) *logBuffer {
+	buf := &logBuffer{}
+	log.SetWriter(buf)
+	log.SetLevel(lvl)
+	t.Cleanup(func() {
+		log.SetWriter(stderrWriter{})
+		log.SetLevel(log.InfoLevel)
+	})
+	return buf
+}
+
+type stderrWriter struct{}
+
+func (stderrWriter) Write(p []byte) (int, error) { return os.Stderr.Write(p) }
+
+func (stderrWriter) Sync() error { return nil }
+
+func (stderrWriter) Stop() error { return nil }
+
+func openLoggedDB(t *testing.T, cfg *databaseconfig.Config) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(&sqlite.Dialector{DriverName: sqliteWithSleep, DSN: "file::memory:"}, &gorm.Config{
+		Logger: newLoggerAdaptor(cfg),
+	})
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = sqlDB.Close() })
+	return db
+}
+
+func TestLoggerAdaptor(t *testing.T) {
+	t.Run("slow statements", func(t *testing.T) {
+		logs := captureLogs(t, log.InfoLevel)
+		db := openLoggedDB(t, &databaseconfig.Config{SlowThreshold: databaseconfig.Duration(20 * time.Millisecond)})
+
+		require.NoError(t, db.Exec("SELECT 1").Error)
+		require.NoError(t, db.Exec("SELECT sleep(50)").Error)
+
+		entries := logs.entries(t, "slow sql")
+		require.Len(t, entries, 1)
+		require.Equal(t, "warn", entries[0]["l"])
+		require.Equal(t, "SELECT sleep(50)", entries[0]["sql"])
+		require.Contains(t, entries[0], "rows")
+		require.GreaterOrEqual(t, entries[0]["elapsed"], 0.05)
+	})
+
+	t.Run("failed statements", func(t *testing.T) {
+		logs := captureLogs(t, log.InfoLevel)
+		db := openLoggedDB(t, &databaseconfig.Config{})
+
+		require.Error(t, db.Exec("SELECT * FROM missing_table").Error)
+
+		entries := logs.entries(t, "error sql")
+		require.Len(t, entries, 1)
+		require.Equal(t, "error", entries[0]["l"])
+		require.Equal(t, "SELECT * FROM missing_table", entries[0]["sql"])
+		require.Contains(t, entries[0]["err"], "no such table")
+	})
+
+	t.Run("trace", func(t *testing.T) {
+		logs := captureLogs(t, log.DebugLevel)
+		db := openLoggedDB(t, &databaseconfig.Config{TraceSQL: true})
+
+		require.NoError(t, db.Exec("SELECT 1").Error)
+
+		entries := logs.entries(t, "sql")
+		require.Len(t, entries, 1)
+		require.Equal(t, "debug", entries[0]["l"])
+		require.Equal(t, "SELECT 1", entries[0]["sql"])
+	})
+
+	t.Run("defaults", func(t *testing.T) {
+		require.Equal(t, DefaultSlowThreshold, newLoggerAdaptor(&databaseconfig.Config{}).slowThreshold)
+		require.Zero(t, newLoggerAdaptor(&databaseconfig.Config{SlowThreshold: -1}).slowThreshold)
+		require.False(t, newLoggerAdaptor(&databaseconfig.Config{}).traceSQL)
+	})
+}
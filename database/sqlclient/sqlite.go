@@ -0,0 +1,87 @@
+package sqlclient
+
+import (
+	"fmt"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// sqliteDialector is the sqlite dialector whose migrator prefixes the names of the indexes with the ones of their
+// tables. The names of the indexes are global to a sqlite database, while the models reuse some of them across
+// tables, like idx_owner.
+type sqliteDialector struct {
+	sqlite.Dialector
+}
+
+// openSQLite returns the dialector of the sqlite database of the given DSN
+func openSQLite(dsn string) gorm.Dialector {
+	return sqliteDialector{Dialector: sqlite.Dialector{DSN: dsn}}
+}
+
+// Migrator implements gorm.Dialector
+func (dialector sqliteDialector) Migrator(db *gorm.DB) gorm.Migrator {
+	return sqliteMigrator{Migrator: dialector.Dialector.Migrator(db).(sqlite.Migrator)}
+}
+
+// sqliteMigrator is the sqlite migrator creating, and looking for, the indexes of a table under the name of the
+// model prefixed with the one of the table
+type sqliteMigrator struct {
+	sqlite.Migrator
+}
+
+// SQLiteIndexName returns the name the index of the given table and name gets in a sqlite database
+func SQLiteIndexName(table, name string) string {
+	return table + "_" + name
+}
+
+// indexName returns the name of the given index of the table of value in the database
+func (m sqliteMigrator) indexName(value interface{}, name string) string {
+	_ = m.RunWithValue(value, func(stmt *gorm.Statement) error {
+		if idx := stmt.Schema.LookIndex(name); idx != nil {
+			name = idx.Name
+		}
+		name = SQLiteIndexName(stmt.Table, name)
+		return nil
+	})
+	return name
+}
+
+// CreateIndex implements gorm.Migrator
+func (m sqliteMigrator) CreateIndex(value interface{}, name string) error {
+	return m.RunWithValue(value, func(stmt *gorm.Statement) error {
+		idx := stmt.Schema.LookIndex(name)
+		if idx == nil {
+			return fmt.Errorf("failed to create index with name %v", name)
+		}
+
+		opts := m.BuildIndexOptions(idx.Fields, stmt)
+		values := []interface{}{clause.Column{Name: SQLiteIndexName(stmt.Table, idx.Name)}, clause.Table{Name: stmt.Table}, opts}
+
+		createIndexSQL := "CREATE "
+		if idx.Class != "" {
+			createIndexSQL += idx.Class + " "
+		}
+		createIndexSQL += "INDEX ? ON ??"
+		if idx.Where != "" {
+			createIndexSQL += " WHERE " + idx.Where
+		}
+		return m.DB.Exec(createIndexSQL, values...).Error
+	})
+}
+
+// HasIndex implements gorm.Migrator
+func (m sqliteMigrator) HasIndex(value interface{}, name string) bool {
+	return m.Migrator.HasIndex(value, m.indexName(value, name))
+}
+
+// DropIndex implements gorm.Migrator
+func (m sqliteMigrator) DropIndex(value interface{}, name string) error {
+	return m.Migrator.DropIndex(value, m.indexName(value, name))
+}
+
+// RenameIndex implements gorm.Migrator
+func (m sqliteMigrator) RenameIndex(value interface{}, oldName, newName string) error {
+	return m.Migrator.RenameIndex(value, m.indexName(value, oldName), m.indexName(value, newName))
+}
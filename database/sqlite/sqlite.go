@@ -0,0 +1,25 @@
+package sqlite
+
+import (
+	"github.com/forbole/juno/v4/database"
+)
+
+// Builder creates a sqlite database with the given database connection info from config,
+// which lives in memory unless the DSN points to a file. It is meant for tests and local
+// development: read replicas are not supported and partitioning is ignored.
+func Builder(ctx *database.Context) (database.Database, error) {
+	impl, err := database.NewImpl(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &Database{Impl: *impl}, nil
+}
+
+// type check to ensure interface is properly implemented
+var _ database.Database = &Database{}
+
+// Database defines a wrapper around a SQL database and implements functionality
+// for data aggregation and exporting.
+type Database struct {
+	database.Impl
+}
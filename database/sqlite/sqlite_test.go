@@ -0,0 +1,71 @@
+package sqlite_test
+
+import (
+	"context"
+	"testing"
+
+	"cosmossdk.io/simapp/params"
+	"github.com/cosmos/cosmos-sdk/types/module/testutil"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm/schema"
+
+	"github.com/forbole/juno/v4/database"
+	databaseconfig "github.com/forbole/juno/v4/database/config"
+	"github.com/forbole/juno/v4/database/sqlite"
+	"github.com/forbole/juno/v4/models"
+)
+
+// newTestDatabase returns a database built by the sqlite builder, living in memory
+func newTestDatabase(t *testing.T) database.Database {
+	t.Helper()
+
+	codec := testutil.MakeTestEncodingConfig()
+	cfg := databaseconfig.DefaultDatabaseConfig()
+	cfg.Type = databaseconfig.SQLite
+	cfg.DSN = ""
+
+	db, err := sqlite.Builder(database.NewContext(cfg, &params.EncodingConfig{
+		InterfaceRegistry: codec.InterfaceRegistry,
+		Codec:             codec.Codec,
+		TxConfig:          codec.TxConfig,
+		Amino:             codec.Amino,
+	}))
+	require.NoError(t, err)
+	t.Cleanup(db.Close)
+	return db
+}
+
+func TestBuilder(t *testing.T) {
+	db := newTestDatabase(t)
+
+	ctx := context.Background()
+	require.NoError(t, db.Ping(ctx))
+	require.NoError(t, db.PrepareTables(ctx, []schema.Tabler{&models.Block{}}))
+	require.NoError(t, db.SaveBlock(ctx, &models.Block{Header: models.Header{Height: 1}}))
+
+	// Every statement must have reached the same in-memory database
+	has, err := db.HasBlock(ctx, 1)
+	require.NoError(t, err)
+	require.True(t, has)
+}
+
+func TestBuilderIndexNames(t *testing.T) {
+	db := newTestDatabase(t)
+
+	// The buckets, objects and payment accounts all declare an idx_owner index
+	ctx := context.Background()
+	tables := []schema.Tabler{&models.Bucket{}, &models.Object{}, &models.PaymentAccount{}}
+	require.NoError(t, db.PrepareTables(ctx, tables))
+
+	m := db.(*sqlite.Database).Db.Migrator()
+	for _, table := range tables {
+		require.True(t, m.HasIndex(table, "idx_owner"))
+	}
+	require.NoError(t, m.DropIndex(&models.Object{}, "idx_owner"))
+	require.False(t, m.HasIndex(&models.Object{}, "idx_owner"))
+	require.True(t, m.HasIndex(&models.Bucket{}, "idx_owner"))
+
+	// Preparing the tables again creates the missing index
+	require.NoError(t, db.PrepareTables(ctx, tables))
+	require.True(t, m.HasIndex(&models.Object{}, "idx_owner"))
+}
@@ -0,0 +1,45 @@
+// Package sqlitetest provides the in-memory sqlite databases the tests of the modules run against
+package sqlitetest
+
+import (
+	"context"
+	"testing"
+
+	"cosmossdk.io/simapp/params"
+	"github.com/cosmos/cosmos-sdk/types/module/testutil"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm/schema"
+
+	"github.com/forbole/juno/v4/database"
+	databaseconfig "github.com/forbole/juno/v4/database/config"
+	"github.com/forbole/juno/v4/database/sqlite"
+)
+
+// NewDatabase returns a fresh in-memory sqlite database built by the sqlite builder, having the given tables
+// prepared. The database gets closed once the test ends.
+func NewDatabase(t testing.TB, tables ...schema.Tabler) *database.Impl {
+	t.Helper()
+
+	cfg := databaseconfig.DefaultDatabaseConfig()
+	cfg.Type = databaseconfig.SQLite
+	cfg.DSN = ""
+	cfg.DisablePreparedStatements = true
+	// The statements of the tests are never slow enough to be worth logging
+	cfg.SlowThreshold = -1
+
+	codec := testutil.MakeTestEncodingConfig()
+	built, err := sqlite.Builder(database.NewContext(cfg, &params.EncodingConfig{
+		InterfaceRegistry: codec.InterfaceRegistry,
+		Codec:             codec.Codec,
+		TxConfig:          codec.TxConfig,
+		Amino:             codec.Amino,
+	}))
+	require.NoError(t, err)
+	t.Cleanup(built.Close)
+
+	db := &built.(*sqlite.Database).Impl
+	if len(tables) > 0 {
+		require.NoError(t, db.PrepareTables(context.Background(), tables))
+	}
+	return db
+}
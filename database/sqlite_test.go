@@ -0,0 +1,98 @@
+package database
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"cosmossdk.io/simapp/params"
+	"github.com/cosmos/cosmos-sdk/types/module/testutil"
+	banktypes "github.com/cosmos/cosmos-sdk/x/bank/types"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/schema"
+)
+
+// mysqlDSNEnv names the environment variable holding the DSN of the MySQL database to run the tests against,
+// in place of sqlite. The DSN must enable parseTime, and the tables of every test get dropped when it starts.
+const mysqlDSNEnv = "JUNO_TEST_MYSQL_DSN"
+
+// newTestImpl returns an Impl backed by a fresh in-memory sqlite database having the given tables created
+func newTestImpl(t testing.TB, tables ...schema.Tabler) *Impl {
+	t.Helper()
+	return newTestImplWithConfig(t, &gorm.Config{}, tables...)
+}
+
+// newTestImplWithConfig behaves like newTestImpl, opening the database with the given gorm settings
+func newTestImplWithConfig(t testing.TB, gormCfg *gorm.Config, tables ...schema.Tabler) *Impl {
+	t.Helper()
+
+	gormCfg.Logger = logger.Default.LogMode(logger.Silent)
+	dsn := os.Getenv(mysqlDSNEnv)
+	dialector := sqlite.Open("file::memory:")
+	if dsn != "" {
+		dialector = mysql.Open(dsn)
+	}
+	db, err := gorm.Open(dialector, gormCfg)
+	require.NoError(t, err)
+
+	// Every new connection to file::memory: opens a brand-new database, so stick to a single one
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = sqlDB.Close() })
+	if dsn != "" {
+		for _, table := range tables {
+			require.NoError(t, db.Migrator().DropTable(table.TableName()))
+		}
+	}
+	require.NoError(t, RegisterErrorTranslation(db))
+
+	codec := testutil.MakeTestEncodingConfig()
+	banktypes.RegisterInterfaces(codec.InterfaceRegistry)
+
+	impl := &Impl{
+		Db: db,
+		EncodingConfig: &params.EncodingConfig{
+			InterfaceRegistry: codec.InterfaceRegistry,
+			Codec:             codec.Codec,
+			TxConfig:          codec.TxConfig,
+			Amino:             codec.Amino,
+		},
+	}
+	require.NoError(t, impl.PrepareTables(context.Background(), tables))
+
+	return impl
+}
+
+// skipUnlessSQLite skips the tests relying on the behaviour of sqlite itself
+func skipUnlessSQLite(t testing.TB) {
+	t.Helper()
+	if os.Getenv(mysqlDSNEnv) != "" {
+		t.Skip("relies on sqlite")
+	}
+}
+
+func TestClose(t *testing.T) {
+	db := newTestImpl(t)
+
+	db.Close()
+	require.NotPanics(t, db.Close)
+
+	err := db.Db.Exec("SELECT 1").Error
+	require.EqualError(t, err, "sql: database is closed")
+}
+
+func TestPing(t *testing.T) {
+	db := newTestImpl(t)
+	require.NoError(t, db.Ping(context.Background()))
+
+	db.Close()
+	start := time.Now()
+	require.EqualError(t, db.Ping(context.Background()), "sql: database is closed")
+	require.Less(t, time.Since(start), time.Second)
+}
@@ -0,0 +1,73 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/forbole/juno/v4/common"
+	"github.com/forbole/juno/v4/models"
+)
+
+func newTestStorageProvider(spID uint32, operator common.Address) *models.StorageProvider {
+	return &models.StorageProvider{
+		SpId:            spID,
+		OperatorAddress: operator,
+		TotalDeposit:    (*common.Big)(big.NewInt(1000)),
+		ReadPrice:       (*common.Big)(big.NewInt(1)),
+		StorePrice:      (*common.Big)(big.NewInt(2)),
+		Status:          "STATUS_IN_SERVICE",
+		Endpoint:        "https://sp.example.com",
+	}
+}
+
+func TestStorageProviderLookups(t *testing.T) {
+	ctx := context.Background()
+	db := newTestImpl(t, &models.StorageProvider{})
+
+	operator := common.HexToAddress("0xAbCdEf0123456789aBcDeF0123456789AbCdEf01")
+	require.NoError(t, db.CreateStorageProvider(ctx, newTestStorageProvider(1, operator)))
+	require.NoError(t, db.CreateStorageProvider(ctx, newTestStorageProvider(2, common.HexToAddress("0x02"))))
+	require.NoError(t, db.CreateStorageProvider(ctx, newTestStorageProvider(3, common.HexToAddress("0x03"))))
+
+	require.NoError(t, db.UpdateStorageProvider(ctx, &models.StorageProvider{
+		SpId:     1,
+		Endpoint: "https://new-sp.example.com",
+		Status:   "STATUS_IN_MAINTENANCE",
+	}))
+	require.NoError(t, db.UpdateStorageProvider(ctx, &models.StorageProvider{SpId: 3, Removed: true}))
+
+	sp, err := db.GetStorageProviderByID(ctx, 1)
+	require.NoError(t, err)
+	require.Equal(t, "https://new-sp.example.com", sp.Endpoint)
+	require.Equal(t, "STATUS_IN_MAINTENANCE", sp.Status)
+	require.Equal(t, int64(1000), sp.TotalDeposit.Raw().Int64())
+
+	for _, address := range []string{operator.Hex(), strings.ToLower(operator.Hex()), strings.ToUpper(operator.Hex()[2:])} {
+		sp, err = db.GetStorageProviderByOperatorAddress(ctx, address)
+		require.NoError(t, err)
+		require.Equal(t, uint32(1), sp.SpId)
+		require.Equal(t, "https://new-sp.example.com", sp.Endpoint)
+	}
+
+	_, err = db.GetStorageProviderByOperatorAddress(ctx, "not an address")
+	require.Error(t, err)
+
+	_, err = db.GetStorageProviderByID(ctx, 4)
+	require.True(t, errors.Is(err, ErrStorageProviderNotFound))
+
+	sps, err := db.ListStorageProviders(ctx, false)
+	require.NoError(t, err)
+	require.Len(t, sps, 2)
+	require.Equal(t, uint32(1), sps[0].SpId)
+	require.Equal(t, uint32(2), sps[1].SpId)
+
+	sps, err = db.ListStorageProviders(ctx, true)
+	require.NoError(t, err)
+	require.Len(t, sps, 3)
+	require.True(t, sps[2].Removed)
+}
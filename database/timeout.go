@@ -0,0 +1,62 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	queryCancelKey = "juno:query_cancel"
+	queryCtxKey    = "juno:query_ctx"
+)
+
+// RegisterQueryTimeout bounds every statement run through the given gorm handle whose context has no deadline
+// to the given timeout. A non-positive timeout leaves statements unbounded.
+//
+// Statements run through Row and Rows are left out, since their result is still being read once the
+// statement itself has returned; this includes Raw(...).Scan(...).
+func RegisterQueryTimeout(db *gorm.DB, timeout time.Duration) error {
+	if timeout <= 0 {
+		return nil
+	}
+
+	start := func(tx *gorm.DB) {
+		if _, ok := tx.Statement.Context.Deadline(); ok {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(tx.Statement.Context, timeout)
+		tx.InstanceSet(queryCtxKey, tx.Statement.Context)
+		tx.InstanceSet(queryCancelKey, cancel)
+		tx.Statement.Context = ctx
+	}
+	end := func(tx *gorm.DB) {
+		cancel, ok := tx.InstanceGet(queryCancelKey)
+		if !ok {
+			return
+		}
+		cancel.(context.CancelFunc)()
+
+		// Put back the caller's context, so that a statement built on top of this one doesn't inherit the expired one
+		if ctx, ok := tx.InstanceGet(queryCtxKey); ok {
+			tx.Statement.Context = ctx.(context.Context)
+		}
+	}
+
+	callbacks := db.Callback()
+	return errors.Join(
+		callbacks.Create().Before("*").Register("juno:query_timeout_start", start),
+		callbacks.Create().After("*").Register("juno:query_timeout_end", end),
+		callbacks.Query().Before("*").Register("juno:query_timeout_start", start),
+		callbacks.Query().After("*").Register("juno:query_timeout_end", end),
+		callbacks.Update().Before("*").Register("juno:query_timeout_start", start),
+		callbacks.Update().After("*").Register("juno:query_timeout_end", end),
+		callbacks.Delete().Before("*").Register("juno:query_timeout_start", start),
+		callbacks.Delete().After("*").Register("juno:query_timeout_end", end),
+		callbacks.Raw().Before("*").Register("juno:query_timeout_start", start),
+		callbacks.Raw().After("*").Register("juno:query_timeout_end", end),
+	)
+}
@@ -0,0 +1,41 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/forbole/juno/v4/common"
+	"github.com/forbole/juno/v4/models"
+)
+
+// slowQuery takes well over a second to run on sqlite
+const slowQuery = `WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < 100000000) SELECT count(*) FROM n`
+
+func TestQueryTimeout(t *testing.T) {
+	skipUnlessSQLite(t)
+
+	db := newTestImpl(t, &models.Bucket{})
+	require.NoError(t, RegisterQueryTimeout(db.Db, 50*time.Millisecond))
+	ctx := context.Background()
+
+	start := time.Now()
+	err := db.Db.WithContext(ctx).Exec(slowQuery).Error
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Less(t, time.Since(start), time.Second)
+
+	// A deadline set by the caller takes precedence over the default timeout
+	deadlineCtx, cancel := context.WithTimeout(ctx, time.Hour)
+	defer cancel()
+	require.NoError(t, db.Db.WithContext(deadlineCtx).Exec("SELECT 1").Error)
+
+	// Fast statements are not affected, and neither are the ones built on top of them
+	bucketID := common.HexToHash("0x01")
+	require.NoError(t, db.SaveBucket(ctx, &models.Bucket{BucketID: bucketID, BucketName: "bucket"}))
+	require.NoError(t, db.UpdateBucket(ctx, &models.Bucket{BucketID: bucketID, UpdateAt: 2}))
+	bucket, err := db.GetBucketByID(ctx, bucketID)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), bucket.UpdateAt)
+}
@@ -0,0 +1,55 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+type txKey struct{}
+
+// savePointSeq numbers the savepoints, so that nested ones never share a name
+var savePointSeq atomic.Uint64
+
+// ContextWithTx returns a copy of ctx carrying the given transaction, which is then used by every
+// query made with the returned context, whatever the Database it is run through.
+// Begin called with such a context opens a savepoint of the transaction rather than a new one.
+func ContextWithTx(ctx context.Context, tx *Impl) context.Context {
+	return context.WithValue(ctx, txKey{}, tx)
+}
+
+// TxFromContext returns the transaction carried by ctx, if any
+func TxFromContext(ctx context.Context) (*Impl, bool) {
+	tx, ok := ctx.Value(txKey{}).(*Impl)
+	return tx, ok
+}
+
+// WithTx runs fn inside a database transaction, passing it a Database bound to that transaction.
+// The transaction is committed if fn succeeds, and rolled back if fn returns an error or panics;
+// in the latter case the panic is propagated once the rollback is done.
+func WithTx(ctx context.Context, db Database, fn func(tx Database) error) (err error) {
+	tx := db.Begin(ctx)
+	if tx.Db.Error != nil {
+		return fmt.Errorf("failed to begin transaction: %w", tx.Db.Error)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			_ = tx.Rollback()
+			panic(r)
+		}
+	}()
+
+	if err = fn(tx); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return errors.Join(err, fmt.Errorf("failed to rollback transaction: %w", rbErr))
+		}
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
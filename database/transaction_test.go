@@ -0,0 +1,269 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/forbole/juno/v4/common"
+	"github.com/forbole/juno/v4/models"
+)
+
+func TestWithTx(t *testing.T) {
+	ctx := context.Background()
+
+	saveBucket := func(tx Database, id byte) error {
+		return tx.SaveBucket(ctx, &models.Bucket{BucketID: common.BytesToHash([]byte{id}), BucketName: string('a' + rune(id))})
+	}
+	countBuckets := func(db *Impl) int64 {
+		var count int64
+		require.NoError(t, db.Db.Table((&models.Bucket{}).TableName()).Count(&count).Error)
+		return count
+	}
+
+	t.Run("commit", func(t *testing.T) {
+		db := newTestImpl(t, &models.Bucket{})
+		require.NoError(t, WithTx(ctx, db, func(tx Database) error {
+			return saveBucket(tx, 1)
+		}))
+		require.Equal(t, int64(1), countBuckets(db))
+	})
+
+	t.Run("error", func(t *testing.T) {
+		db := newTestImpl(t, &models.Bucket{})
+		errFn := errors.New("fn failed")
+		err := WithTx(ctx, db, func(tx Database) error {
+			require.NoError(t, saveBucket(tx, 1))
+			return errFn
+		})
+		require.ErrorIs(t, err, errFn)
+		require.Zero(t, countBuckets(db))
+	})
+
+	t.Run("panic", func(t *testing.T) {
+		db := newTestImpl(t, &models.Bucket{})
+		require.PanicsWithValue(t, "fn panicked", func() {
+			_ = WithTx(ctx, db, func(tx Database) error {
+				require.NoError(t, saveBucket(tx, 1))
+				panic("fn panicked")
+			})
+		})
+		require.Zero(t, countBuckets(db))
+	})
+
+	t.Run("commit failure", func(t *testing.T) {
+		db := newTestImpl(t, &models.Bucket{})
+		err := WithTx(ctx, db, func(tx Database) error {
+			require.NoError(t, saveBucket(tx, 1))
+			// Ending the transaction here makes the final commit fail
+			tx.Rollback()
+			return nil
+		})
+		require.ErrorContains(t, err, "failed to commit transaction")
+		require.Zero(t, countBuckets(db))
+	})
+}
+
+func TestContextWithTx(t *testing.T) {
+	ctx := context.Background()
+
+	saveBucket := func(ctx context.Context, db Database, id byte) error {
+		return db.SaveBucket(ctx, &models.Bucket{BucketID: common.BytesToHash([]byte{id}), BucketName: string('a' + rune(id))})
+	}
+	bucketIDs := func(db *Impl) []common.Hash {
+		var ids []common.Hash
+		require.NoError(t, db.Db.Table((&models.Bucket{}).TableName()).Order("bucket_name").Pluck("bucket_id", &ids).Error)
+		return ids
+	}
+
+	t.Run("rollback", func(t *testing.T) {
+		db := newTestImpl(t, &models.Bucket{})
+		tx := db.Begin(ctx)
+		txCtx := ContextWithTx(ctx, tx)
+
+		// Writes made through the Database itself join the transaction carried by the context
+		require.NoError(t, saveBucket(txCtx, db, 1))
+		_, err := db.GetBucketByID(txCtx, common.BytesToHash([]byte{1}))
+		require.NoError(t, err)
+
+		tx.Rollback()
+		require.Empty(t, bucketIDs(db))
+	})
+
+	t.Run("nested", func(t *testing.T) {
+		db := newTestImpl(t, &models.Bucket{})
+		tx := db.Begin(ctx)
+		txCtx := ContextWithTx(ctx, tx)
+		require.NoError(t, saveBucket(txCtx, db, 1))
+
+		// A nested transaction is a savepoint, so only its own writes are undone when it fails
+		errFn := errors.New("fn failed")
+		err := WithTx(txCtx, db, func(nested Database) error {
+			require.NoError(t, saveBucket(txCtx, nested, 2))
+			return errFn
+		})
+		require.ErrorIs(t, err, errFn)
+
+		require.NoError(t, WithTx(txCtx, db, func(nested Database) error {
+			return saveBucket(txCtx, nested, 3)
+		}))
+
+		require.NoError(t, tx.Commit())
+		require.Equal(t, []common.Hash{common.BytesToHash([]byte{1}), common.BytesToHash([]byte{3})}, bucketIDs(db))
+	})
+}
+
+func TestNestedBegin(t *testing.T) {
+	ctx := context.Background()
+
+	saveBucket := func(db Database, id byte) {
+		require.NoError(t, db.SaveBucket(ctx, &models.Bucket{BucketID: common.BytesToHash([]byte{id}), BucketName: string('a' + rune(id))}))
+	}
+	bucketIDs := func(db *Impl) []common.Hash {
+		var ids []common.Hash
+		require.NoError(t, db.Db.Table((&models.Bucket{}).TableName()).Order("bucket_name").Pluck("bucket_id", &ids).Error)
+		return ids
+	}
+
+	t.Run("inner rollback", func(t *testing.T) {
+		db := newTestImpl(t, &models.Bucket{})
+		outer := db.Begin(ctx)
+		saveBucket(outer, 1)
+
+		inner := outer.Begin(ctx)
+		require.NoError(t, inner.Db.Error)
+		saveBucket(inner, 2)
+		innermost := inner.Begin(ctx)
+		saveBucket(innermost, 3)
+		require.NoError(t, innermost.Commit())
+		inner.Rollback()
+
+		// The outer transaction is still usable after the inner one has been rolled back
+		saveBucket(outer, 4)
+		require.NoError(t, outer.Commit())
+		require.Equal(t, []common.Hash{common.BytesToHash([]byte{1}), common.BytesToHash([]byte{4})}, bucketIDs(db))
+	})
+
+	t.Run("outer rollback", func(t *testing.T) {
+		db := newTestImpl(t, &models.Bucket{})
+		outer := db.Begin(ctx)
+		saveBucket(outer, 1)
+
+		inner := outer.Begin(ctx)
+		saveBucket(inner, 2)
+		require.NoError(t, inner.Commit())
+
+		// Committing the inner transaction left its writes to the outer one
+		outer.Rollback()
+		require.Empty(t, bucketIDs(db))
+	})
+
+	t.Run("with tx", func(t *testing.T) {
+		db := newTestImpl(t, &models.Bucket{})
+		errFn := errors.New("fn failed")
+		err := WithTx(ctx, db, func(outer Database) error {
+			saveBucket(outer, 1)
+			require.ErrorIs(t, WithTx(ctx, outer, func(inner Database) error {
+				saveBucket(inner, 2)
+				return errFn
+			}), errFn)
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, []common.Hash{common.BytesToHash([]byte{1})}, bucketIDs(db))
+	})
+}
+
+func TestBeginWithOptions(t *testing.T) {
+	ctx := context.Background()
+	bucket := &models.Bucket{BucketID: common.HexToHash("0x01"), BucketName: "bucket"}
+
+	t.Run("read only", func(t *testing.T) {
+		db := newTestImpl(t, &models.Bucket{})
+		require.NoError(t, db.SaveBucket(ctx, bucket))
+
+		tx := db.BeginWithOptions(ctx, &sql.TxOptions{ReadOnly: true})
+		require.NoError(t, tx.Db.Error)
+		defer tx.Rollback()
+
+		require.ErrorIs(t, tx.SaveBucket(ctx, &models.Bucket{BucketID: common.HexToHash("0x02"), BucketName: "other"}), ErrReadOnlyTx)
+		// The same goes for the writes joining the transaction through the context, and for its savepoints
+		require.ErrorIs(t, db.SaveBucket(ContextWithTx(ctx, tx), bucket), ErrReadOnlyTx)
+		require.ErrorIs(t, tx.Begin(ctx).SaveBucket(ctx, bucket), ErrReadOnlyTx)
+
+		// Reads are still allowed
+		stored, err := tx.GetBucketByID(ctx, bucket.BucketID)
+		require.NoError(t, err)
+		require.Equal(t, "bucket", stored.BucketName)
+	})
+
+	t.Run("read only savepoint", func(t *testing.T) {
+		db := newTestImpl(t, &models.Bucket{})
+		tx := db.Begin(ctx)
+		sp := tx.BeginWithOptions(ctx, &sql.TxOptions{ReadOnly: true})
+		require.ErrorIs(t, sp.SaveBucket(ctx, bucket), ErrReadOnlyTx)
+		require.NoError(t, sp.Commit())
+
+		require.NoError(t, tx.SaveBucket(ctx, bucket))
+		require.NoError(t, tx.Commit())
+	})
+
+	t.Run("isolation level", func(t *testing.T) {
+		db := newTestImpl(t, &models.Bucket{})
+		tx := db.BeginWithOptions(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+		require.NoError(t, tx.Db.Error)
+		require.NoError(t, tx.SaveBucket(ctx, bucket))
+		require.NoError(t, tx.Commit())
+
+		_, err := db.GetBucketByID(ctx, bucket.BucketID)
+		require.NoError(t, err)
+	})
+}
+
+func TestFinishTx(t *testing.T) {
+	ctx := context.Background()
+	bucket := &models.Bucket{BucketID: common.HexToHash("0x01"), BucketName: "bucket"}
+
+	t.Run("commit twice", func(t *testing.T) {
+		db := newTestImpl(t, &models.Bucket{})
+		tx := db.Begin(ctx)
+		require.NoError(t, tx.SaveBucket(ctx, bucket))
+		require.NoError(t, tx.Commit())
+
+		require.ErrorIs(t, tx.Commit(), ErrTxFinished)
+		require.ErrorIs(t, tx.Rollback(), ErrTxFinished)
+		_, err := db.GetBucketByID(ctx, bucket.BucketID)
+		require.NoError(t, err)
+	})
+
+	t.Run("rollback twice", func(t *testing.T) {
+		db := newTestImpl(t, &models.Bucket{})
+		tx := db.Begin(ctx)
+		sp := tx.Begin(ctx)
+		require.NoError(t, sp.Rollback())
+		require.ErrorIs(t, sp.Rollback(), ErrTxFinished)
+		require.ErrorIs(t, sp.Commit(), ErrTxFinished)
+
+		require.NoError(t, tx.Rollback())
+		require.ErrorIs(t, tx.Rollback(), ErrTxFinished)
+	})
+
+	t.Run("commit failure", func(t *testing.T) {
+		db := newTestImpl(t, &models.Bucket{})
+		txCtx, cancel := context.WithCancel(ctx)
+		tx := db.Begin(txCtx)
+		require.NoError(t, tx.SaveBucket(txCtx, bucket))
+
+		// Canceling the context makes database/sql close the connection of the transaction behind our back
+		cancel()
+		err := tx.Commit()
+		require.True(t, errors.Is(err, context.Canceled) || errors.Is(err, sql.ErrTxDone), err)
+
+		// The failed commit already finished the transaction, so rolling it back doesn't reach the database
+		require.ErrorIs(t, tx.Rollback(), ErrTxFinished)
+		require.ErrorIs(t, tx.Commit(), ErrTxFinished)
+	})
+}
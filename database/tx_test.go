@@ -0,0 +1,642 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/cosmos/cosmos-sdk/codec"
+	codectypes "github.com/cosmos/cosmos-sdk/codec/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	sdktx "github.com/cosmos/cosmos-sdk/types/tx"
+	banktypes "github.com/cosmos/cosmos-sdk/x/bank/types"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+
+	"github.com/forbole/juno/v4/common"
+	"github.com/forbole/juno/v4/models"
+	"github.com/forbole/juno/v4/modules/messages"
+	"github.com/forbole/juno/v4/types"
+)
+
+// newTestTx builds a tx containing a single bank send message
+func newTestTx(t testing.TB, hash string, height int64) *types.Tx {
+	t.Helper()
+
+	coins := sdk.NewCoins(sdk.NewInt64Coin("azkme", 100))
+	msg, err := codectypes.NewAnyWithValue(&banktypes.MsgSend{
+		FromAddress: "0x0000000000000000000000000000000000000001",
+		ToAddress:   "0x0000000000000000000000000000000000000002",
+		Amount:      coins,
+	})
+	require.NoError(t, err)
+
+	return &types.Tx{
+		Tx: &sdktx.Tx{
+			Body: &sdktx.TxBody{
+				Messages: []*codectypes.Any{msg},
+				Memo:     "memo",
+			},
+			AuthInfo: &sdktx.AuthInfo{
+				Fee: &sdktx.Fee{Amount: coins, GasLimit: 200000},
+			},
+			Signatures: [][]byte{{0x01, 0x02, 0x03}},
+		},
+		TxResponse: &sdk.TxResponse{
+			TxHash:    hash,
+			Height:    height,
+			GasWanted: 200000,
+			GasUsed:   150000,
+			RawLog:    "raw log",
+			Logs: sdk.ABCIMessageLogs{{
+				MsgIndex: 0,
+				Events: sdk.StringEvents{{
+					Type:       "message",
+					Attributes: []sdk.Attribute{{Key: "action", Value: "/cosmos.bank.v1beta1.MsgSend"}},
+				}},
+			}},
+		},
+	}
+}
+
+func TestGetTxByHash(t *testing.T) {
+	ctx := context.Background()
+	db := newTestImpl(t, &models.Tx{}, &models.Message{})
+
+	hash := "0xb1d2c3e4f5a6978812345678901234567890abcdefabcdefabcdefabcdefabcd"
+	tx := newTestTx(t, hash, 10)
+	require.NoError(t, db.SaveTx(ctx, 1700000000, 0, tx))
+
+	// Saving the same tx again must upsert rather than fail
+	require.NoError(t, db.SaveTx(ctx, 1700000000, 0, tx))
+
+	msgBz, err := db.EncodingConfig.Codec.MarshalJSON(tx.Body.Messages[0])
+	require.NoError(t, err)
+	feeBz, err := db.EncodingConfig.Codec.MarshalJSON(tx.AuthInfo.Fee)
+	require.NoError(t, err)
+	logsBz, err := db.EncodingConfig.Amino.MarshalJSON(tx.Logs)
+	require.NoError(t, err)
+
+	for _, input := range []string{hash, strings.TrimPrefix(hash, "0x")} {
+		stored, err := db.GetTxByHash(ctx, common.HexToHash(input))
+		require.NoError(t, err)
+
+		require.Equal(t, common.HexToHash(hash), stored.Hash)
+		require.Equal(t, uint64(10), stored.Height)
+		require.Equal(t, fmt.Sprintf("[%s]", msgBz), stored.Messages)
+		require.Equal(t, string(feeBz), stored.Fee)
+		require.Equal(t, string(logsBz), stored.Logs)
+		require.Equal(t, "memo", stored.Memo)
+		require.True(t, stored.Success)
+	}
+}
+
+func TestGetTxByHashNotFound(t *testing.T) {
+	db := newTestImpl(t, &models.Tx{}, &models.Message{})
+
+	_, err := db.GetTxByHash(context.Background(), common.HexToHash("0x01"))
+	require.True(t, errors.Is(err, ErrTxNotFound))
+	require.True(t, errors.Is(err, gorm.ErrRecordNotFound))
+}
+
+func TestGetTxsByHeight(t *testing.T) {
+	ctx := context.Background()
+	db := newTestImpl(t, &models.Tx{}, &models.Message{})
+	db.MaxPageSize = 2
+
+	// Save them out of order to make sure the result is sorted by index
+	for _, index := range []int{2, 0, 1} {
+		hash := fmt.Sprintf("0x%064x", index+1)
+		require.NoError(t, db.SaveTx(ctx, 1700000000, index, newTestTx(t, hash, 20)))
+	}
+	require.NoError(t, db.SaveTx(ctx, 1700000000, 0, newTestTx(t, fmt.Sprintf("0x%064x", 99), 21)))
+
+	txs, total, err := db.GetTxsByHeight(ctx, 20, 10, 0)
+	require.NoError(t, err)
+	require.Equal(t, int64(3), total)
+	require.Len(t, txs, 2, "limit must be capped to the max page size")
+	require.Equal(t, uint32(0), txs[0].TxIndex)
+	require.Equal(t, uint32(1), txs[1].TxIndex)
+
+	txs, total, err = db.GetTxsByHeight(ctx, 20, 2, 2)
+	require.NoError(t, err)
+	require.Equal(t, int64(3), total)
+	require.Len(t, txs, 1)
+	require.Equal(t, uint32(2), txs[0].TxIndex)
+	require.Equal(t, common.HexToHash(fmt.Sprintf("0x%064x", 3)), txs[0].Hash)
+
+	txs, total, err = db.GetTxsByHeight(ctx, 30, 10, 0)
+	require.NoError(t, err)
+	require.Zero(t, total)
+	require.NotNil(t, txs)
+	require.Empty(t, txs)
+}
+
+func TestListTxsByMessageType(t *testing.T) {
+	ctx := context.Background()
+	db := newTestImpl(t, &models.Tx{}, &models.Message{})
+
+	for height := int64(1); height <= 3; height++ {
+		require.NoError(t, db.SaveTx(ctx, 1700000000, 0, newTestTx(t, fmt.Sprintf("0x%064x", height), height)))
+	}
+
+	// A tx without any bank message must not be returned
+	other := newTestTx(t, fmt.Sprintf("0x%064x", 4), 2)
+	other.Body.Messages[0].TypeUrl = "/cosmos.bank.v1beta1.MsgMultiSend"
+	require.NoError(t, db.SaveTx(ctx, 1700000000, 1, other))
+
+	txs, err := db.ListTxsByMessageType(ctx, "/cosmos.bank.v1beta1.MsgSend", 2, 3, 10, 0)
+	require.NoError(t, err)
+	require.Len(t, txs, 2)
+	require.Equal(t, uint64(2), txs[0].Height)
+	require.Equal(t, uint64(3), txs[1].Height)
+
+	txs, err = db.ListTxsByMessageType(ctx, "/cosmos.bank.v1beta1.MsgMultiSend", 0, 10, 10, 0)
+	require.NoError(t, err)
+	require.Len(t, txs, 1)
+	require.Equal(t, common.HexToHash(fmt.Sprintf("0x%064x", 4)), txs[0].Hash)
+
+	// Type URLs must match exactly
+	txs, err = db.ListTxsByMessageType(ctx, "/cosmos.bank.v1beta1.Msg", 0, 10, 10, 0)
+	require.NoError(t, err)
+	require.Empty(t, txs)
+}
+
+// getTestMessage returns the message row stored at the given index of the tx having the given hash
+func getTestMessage(t *testing.T, db *Impl, hash string, index uint32) *models.Message {
+	t.Helper()
+
+	var msg models.Message
+	err := db.Db.Table((&models.Message{}).TableName()).
+		Where("tx_hash = ? AND msg_index = ?", common.HexToHash(hash), index).
+		Take(&msg).Error
+	require.NoError(t, err)
+	return &msg
+}
+
+func TestSaveTxMessages(t *testing.T) {
+	ctx := context.Background()
+	db := newTestImpl(t, &models.Tx{}, &models.Message{})
+	hash := fmt.Sprintf("0x%064x", 1)
+	from, to := sdk.AccAddress(bytes.Repeat([]byte{1}, 20)).String(), sdk.AccAddress(bytes.Repeat([]byte{2}, 20)).String()
+	tx := newTestTx(t, hash, 5)
+	send, err := codectypes.NewAnyWithValue(&banktypes.MsgSend{
+		FromAddress: from,
+		ToAddress:   to,
+		Amount:      sdk.NewCoins(sdk.NewInt64Coin("azkme", 100)),
+	})
+	require.NoError(t, err)
+	tx.Body.Messages[0] = send
+
+	require.NoError(t, db.SaveTx(ctx, 1700000000, 0, tx))
+	msg := getTestMessage(t, db, hash, 0)
+	require.Equal(t, "/cosmos.bank.v1beta1.MsgSend", msg.TypeURL)
+	require.Equal(t, uint64(5), msg.Height)
+	require.JSONEq(t, fmt.Sprintf(`[%q]`, from), msg.Signers)
+	// Without a parser the involved addresses are the signers
+	require.JSONEq(t, msg.Signers, msg.InvolvedAddresses)
+
+	var value sdk.Msg
+	require.NoError(t, db.EncodingConfig.Codec.UnmarshalInterfaceJSON([]byte(msg.Value), &value))
+	require.Equal(t, to, value.(*banktypes.MsgSend).ToAddress)
+	require.Equal(t, int64(100), value.(*banktypes.MsgSend).Amount.AmountOf("azkme").Int64())
+
+	db.AddressesParser = messages.BankMessagesParser
+	require.NoError(t, db.SaveTx(ctx, 1700000000, 0, tx))
+	msg = getTestMessage(t, db, hash, 0)
+	require.JSONEq(t, fmt.Sprintf(`[%q, %q]`, to, from), msg.InvolvedAddresses)
+}
+
+func TestBackfillMessages(t *testing.T) {
+	ctx := context.Background()
+	db := newTestImpl(t, &models.Tx{}, &models.Message{})
+
+	require.NoError(t, db.SaveTx(ctx, 1700000000, 0, newTestTx(t, fmt.Sprintf("0x%064x", 1), 5)))
+
+	// Simulate a tx stored before the messages were tracked
+	require.NoError(t, db.Db.Exec("DELETE FROM messages").Error)
+	txs, err := db.ListTxsByMessageType(ctx, "/cosmos.bank.v1beta1.MsgSend", 0, 10, 10, 0)
+	require.NoError(t, err)
+	require.Empty(t, txs)
+
+	count, err := db.BackfillMessages(ctx, 0, 10)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), count)
+
+	txs, err = db.ListTxsByMessageType(ctx, "/cosmos.bank.v1beta1.MsgSend", 0, 10, 10, 0)
+	require.NoError(t, err)
+	require.Len(t, txs, 1)
+	msg := getTestMessage(t, db, fmt.Sprintf("0x%064x", 1), 0)
+	require.Equal(t, "/cosmos.bank.v1beta1.MsgSend", msg.TypeURL)
+	require.Contains(t, msg.Value, `"to_address":"0x0000000000000000000000000000000000000002"`)
+
+	// Backfilling again must be idempotent
+	_, err = db.BackfillMessages(ctx, 0, 10)
+	require.NoError(t, err)
+	var total int64
+	require.NoError(t, db.Db.Table("messages").Count(&total).Error)
+	require.Equal(t, int64(1), total)
+}
+
+func TestSaveTxInsideTransaction(t *testing.T) {
+	ctx := context.Background()
+	db := newTestImpl(t, &models.Tx{}, &models.Message{})
+
+	hash := fmt.Sprintf("0x%064x", 1)
+	tx := db.Begin(ctx)
+	require.NotNil(t, tx.EncodingConfig)
+	require.NoError(t, tx.SaveTx(ctx, 1700000000, 0, newTestTx(t, hash, 1)))
+	require.NoError(t, tx.Commit())
+
+	stored, err := db.GetTxByHash(ctx, common.HexToHash(hash))
+	require.NoError(t, err)
+	require.NotEmpty(t, stored.Fee)
+	require.Contains(t, stored.Messages, "/cosmos.bank.v1beta1.MsgSend")
+}
+
+func TestSaveTxReplacesHashAtPosition(t *testing.T) {
+	ctx := context.Background()
+	db := newTestImpl(t, &models.Tx{}, &models.Message{})
+
+	require.NoError(t, db.SaveTx(ctx, 1700000000, 0, newTestTx(t, "0xaa", 10)))
+	require.NoError(t, db.SaveTx(ctx, 1700000000, 0, newTestTx(t, "0xbb", 10)))
+
+	txs, total, err := db.GetTxsByHeight(ctx, 10, 10, 0)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), total)
+	require.Len(t, txs, 1)
+	require.Equal(t, common.HexToHash("0xbb"), txs[0].Hash)
+
+	_, err = db.GetTxByHash(ctx, common.HexToHash("0xaa"))
+	require.ErrorIs(t, err, ErrTxNotFound)
+}
+
+func TestSaveTxAtAnotherPosition(t *testing.T) {
+	ctx := context.Background()
+	db := newTestImpl(t, &models.Tx{}, &models.Message{})
+
+	// The same tx saved again at another index of its block is upserted at that index
+	require.NoError(t, db.SaveTx(ctx, 1700000000, 0, newTestTx(t, "0xaa", 10)))
+	require.NoError(t, db.SaveTx(ctx, 1700000000, 1, newTestTx(t, "0xaa", 10)))
+	require.NoError(t, db.SaveTx(ctx, 1700000000, 0, newTestTx(t, "0xbb", 10)))
+
+	txs, total, err := db.GetTxsByHeight(ctx, 10, 10, 0)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), total)
+	require.Equal(t, []common.Hash{common.HexToHash("0xbb"), common.HexToHash("0xaa")}, []common.Hash{txs[0].Hash, txs[1].Hash})
+
+	tx, err := db.GetTxByHash(ctx, common.HexToHash("0xaa"))
+	require.NoError(t, err)
+	require.Equal(t, uint32(1), tx.TxIndex)
+}
+
+// newTestTxs builds count txs included at the given height
+func newTestTxs(t testing.TB, height int64, count int) []*types.Tx {
+	txs := make([]*types.Tx, count)
+	for i := range txs {
+		txs[i] = newTestTx(t, fmt.Sprintf("0x%032x%032x", height, i+1), height)
+	}
+	return txs
+}
+
+func TestSaveTxs(t *testing.T) {
+	ctx := context.Background()
+	db := newTestImpl(t, &models.Tx{}, &models.Message{})
+	// Use a batch size not dividing the number of txs, so that the last batch is a partial one
+	db.TxBatchSize = 2
+
+	txs := newTestTxs(t, 10, 5)
+	require.NoError(t, db.SaveTxs(ctx, 1700000000, txs))
+	require.NoError(t, db.SaveTxs(ctx, 1700000000, nil))
+
+	stored, total, err := db.GetTxsByHeight(ctx, 10, 10, 0)
+	require.NoError(t, err)
+	require.Equal(t, int64(5), total)
+	for i, tx := range stored {
+		require.Equal(t, uint32(i), tx.TxIndex)
+		require.Equal(t, common.HexToHash(txs[i].TxHash), tx.Hash)
+		require.Equal(t, uint64(1700000000), tx.Timestamp)
+	}
+
+	var msgs int64
+	require.NoError(t, db.Db.Table((&models.Message{}).TableName()).Where("height = ?", 10).Count(&msgs).Error)
+	require.Equal(t, int64(5), msgs)
+
+	// Saving the block again upserts every row, and the rows match the ones stored one by one
+	require.NoError(t, db.SaveTxs(ctx, 1700000000, txs))
+	_, total, err = db.GetTxsByHeight(ctx, 10, 10, 0)
+	require.NoError(t, err)
+	require.Equal(t, int64(5), total)
+
+	// Saving the block a few txs at a time keeps their index inside it
+	split := newTestImpl(t, &models.Tx{}, &models.Message{})
+	require.NoError(t, split.SaveTxsFrom(ctx, 1700000000, 0, txs[:3]))
+	require.NoError(t, split.SaveTxsFrom(ctx, 1700000000, 3, txs[3:]))
+	stored, total, err = split.GetTxsByHeight(ctx, 10, 10, 0)
+	require.NoError(t, err)
+	require.Equal(t, int64(5), total)
+	for i, tx := range stored {
+		require.Equal(t, uint32(i), tx.TxIndex)
+		require.Equal(t, common.HexToHash(txs[i].TxHash), tx.Hash)
+	}
+
+	single := newTestImpl(t, &models.Tx{}, &models.Message{})
+	require.NoError(t, single.SaveTx(ctx, 1700000000, 3, txs[3]))
+	expected, err := single.GetTxByHash(ctx, common.HexToHash(txs[3].TxHash))
+	require.NoError(t, err)
+	actual, err := db.GetTxByHash(ctx, common.HexToHash(txs[3].TxHash))
+	require.NoError(t, err)
+	expected.ID, actual.ID = 0, 0
+	require.Equal(t, expected, actual)
+}
+
+func BenchmarkSaveTx(b *testing.B) {
+	ctx := context.Background()
+	db := newTestImpl(b, &models.Tx{}, &models.Message{})
+	txs := newTestTxs(b, 10, 500)
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		for i, tx := range txs {
+			if err := db.SaveTx(ctx, 1700000000, i, tx); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkSaveTxs(b *testing.B) {
+	ctx := context.Background()
+	db := newTestImpl(b, &models.Tx{}, &models.Message{})
+	txs := newTestTxs(b, 10, 500)
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		if err := db.SaveTxs(ctx, 1700000000, txs); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestSaveFailedTx(t *testing.T) {
+	ctx := context.Background()
+	db := newTestImpl(t, &models.Tx{}, &models.Message{})
+
+	tx := newTestTx(t, fmt.Sprintf("0x%064x", 1), 10)
+	require.NoError(t, db.SaveTx(ctx, 1700000000, 0, tx))
+
+	// The tx gets saved again as failed, as it would be when re-indexing with the right results
+	tx.Code = 13
+	tx.Codespace = "sdk"
+	tx.Body.Messages = append(tx.Body.Messages, tx.Body.Messages[0])
+	require.NoError(t, db.SaveTx(ctx, 1700000000, 0, tx))
+	require.NoError(t, db.SaveTx(ctx, 1700000000, 1, newTestTx(t, fmt.Sprintf("0x%064x", 2), 10)))
+
+	var failed []*models.Tx
+	require.NoError(t, db.Db.Table((&models.Tx{}).TableName()).Where("codespace = ? AND code = ?", "sdk", 13).Find(&failed).Error)
+	require.Len(t, failed, 1)
+	require.False(t, failed[0].Success)
+	require.Equal(t, common.HexToHash(tx.TxHash), failed[0].Hash)
+
+	var msgs []json.RawMessage
+	require.NoError(t, json.Unmarshal([]byte(failed[0].Messages), &msgs))
+	require.Equal(t, uint32(2), failed[0].MsgCount)
+	require.Len(t, msgs, int(failed[0].MsgCount))
+
+	result := failed[0].ToTmTx().TxResult
+	require.Equal(t, uint32(13), result.Code)
+	require.Equal(t, "sdk", result.Codespace)
+}
+
+func TestMigrateTxCode(t *testing.T) {
+	skipUnlessSQLite(t)
+
+	ctx := context.Background()
+	db := newTestImpl(t, &legacyTx{})
+	require.NoError(t, db.Db.Create(&legacyTx{Hash: common.HexToHash("0x01"), Height: 10}).Error)
+
+	require.NoError(t, db.AutoMigrate(ctx, []schema.Tabler{&models.Tx{}}))
+
+	stored, err := db.GetTxByHash(ctx, common.HexToHash("0x01"))
+	require.NoError(t, err)
+	require.Zero(t, stored.Code)
+	require.Empty(t, stored.Codespace)
+	require.Zero(t, stored.MsgCount)
+	// Failed txs stored before the migration still report a non zero code
+	require.Equal(t, uint32(1), stored.ToTmTx().TxResult.Code)
+}
+
+// legacyTx is the txs table as it was before the error code got stored
+type legacyTx struct {
+	ID uint64 `gorm:"column:id;primaryKey"`
+
+	Hash    common.Hash `gorm:"column:hash;type:BINARY(32);not null"`
+	Height  uint64      `gorm:"column:height;not null"`
+	TxIndex uint32      `gorm:"column:tx_index;not null"`
+
+	Success     bool   `gorm:"column:success"`
+	Messages    string `gorm:"column:messages;type:json;not null;default:(JSON_ARRAY())"`
+	Memo        string `gorm:"column:memo"`
+	Signatures  string `gorm:"column:signatures"`
+	SignerInfos string `gorm:"column:signer_infos;type:json;not null;default:(JSON_ARRAY())"`
+	Fee         string `gorm:"column:fee;type:json;not null;default:(JSON_ARRAY())"`
+
+	GasWanted uint64 `gorm:"column:gas_wanted"`
+	GasUsed   uint64 `gorm:"column:gas_used"`
+	RawLog    string `gorm:"column:raw_log"`
+	Logs      string `gorm:"column:logs;type:json;not null;default:(JSON_ARRAY())"`
+
+	Timestamp uint64 `gorm:"timestamp"`
+}
+
+func (*legacyTx) TableName() string {
+	return (&models.Tx{}).TableName()
+}
+
+func TestSaveTxJSONColumns(t *testing.T) {
+	ctx := context.Background()
+	db := newTestImpl(t, &models.Tx{}, &models.Message{})
+
+	multiSig := newTestTx(t, fmt.Sprintf("0x%064x", 1), 10)
+	multiSig.Signatures = [][]byte{{0x01, 0x02, 0x03}, {0x04, 0x05}, {}}
+	multiSig.AuthInfo.SignerInfos = []*sdktx.SignerInfo{{Sequence: 1}, {Sequence: 2}, {Sequence: 3}}
+
+	noMsgs := newTestTx(t, fmt.Sprintf("0x%064x", 2), 10)
+	noMsgs.Body.Messages = nil
+	noMsgs.Signatures = nil
+	noMsgs.Logs = nil
+
+	require.NoError(t, db.SaveTxs(ctx, 1700000000, []*types.Tx{multiSig, noMsgs}))
+
+	stored, err := db.GetTxByHash(ctx, common.HexToHash(multiSig.TxHash))
+	require.NoError(t, err)
+	require.True(t, json.Valid([]byte(stored.Signatures)))
+	sigs, err := stored.DecodeSignatures()
+	require.NoError(t, err)
+	require.Equal(t, []string{"AQID", "BAU=", ""}, sigs)
+	infos, err := stored.DecodeSignerInfos()
+	require.NoError(t, err)
+	require.Len(t, infos, 3)
+	msgs, err := stored.DecodeMessages()
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+
+	stored, err = db.GetTxByHash(ctx, common.HexToHash(noMsgs.TxHash))
+	require.NoError(t, err)
+	for _, column := range []string{stored.Signatures, stored.Messages, stored.SignerInfos, stored.Logs} {
+		require.JSONEq(t, "[]", column)
+	}
+	require.Zero(t, stored.MsgCount)
+	msgs, err = stored.DecodeMessages()
+	require.NoError(t, err)
+	require.Empty(t, msgs)
+}
+
+func TestDecodeLegacyTxColumns(t *testing.T) {
+	legacy := &models.Tx{
+		Signatures:  "AQID,BAU=",
+		Messages:    `[{"@type":"/cosmos.bank.v1beta1.MsgSend"},,{"@type":"/cosmos.bank.v1beta1.MsgSend"}]`,
+		SignerInfos: "[]",
+		Logs:        "null",
+	}
+
+	sigs, err := legacy.DecodeSignatures()
+	require.NoError(t, err)
+	require.Equal(t, []string{"AQID", "BAU="}, sigs)
+
+	msgs, err := legacy.DecodeMessages()
+	require.NoError(t, err)
+	require.Len(t, msgs, 3)
+	require.JSONEq(t, "null", string(msgs[1]))
+	require.JSONEq(t, `{"@type":"/cosmos.bank.v1beta1.MsgSend"}`, string(msgs[2]))
+
+	infos, err := legacy.DecodeSignerInfos()
+	require.NoError(t, err)
+	require.Empty(t, infos)
+	logs, err := legacy.DecodeLogs()
+	require.NoError(t, err)
+	require.Empty(t, logs)
+
+	// Only empty messages could break the old arrays
+	legacy.Messages = "[,]"
+	msgs, err = legacy.DecodeMessages()
+	require.NoError(t, err)
+	require.Len(t, msgs, 2)
+
+	legacy.Signatures = ""
+	sigs, err = legacy.DecodeSignatures()
+	require.NoError(t, err)
+	require.Empty(t, sigs)
+
+	legacy.Messages = `{"@type":"/cosmos.bank.v1beta1.MsgSend"}`
+	_, err = legacy.DecodeMessages()
+	require.Error(t, err)
+}
+
+func TestSaveTxNormalizesHashAndAddresses(t *testing.T) {
+	ctx := context.Background()
+	db := newTestImpl(t, &models.Tx{}, &models.Message{})
+
+	// CometBFT returns bare uppercase hashes, while addresses may come with any case
+	hash := strings.Repeat("AB", 32)
+	hexAddr := common.BytesToAddress(bytes.Repeat([]byte{0xab}, 20)).Hex()
+	bech32Addr := sdk.AccAddress(bytes.Repeat([]byte{0xab}, 20)).String()
+	db.AddressesParser = func(codec.Codec, sdk.Msg) ([]string, error) {
+		return []string{hexAddr, strings.ToUpper(bech32Addr)}, nil
+	}
+	require.NoError(t, db.SaveTx(ctx, 1700000000, 0, newTestTx(t, hash, 5)))
+
+	for _, query := range []string{"0x" + strings.ToLower(hash), strings.ToLower(hash), "0X" + hash} {
+		txHash, err := common.ParseHash(query)
+		require.NoError(t, err)
+		stored, err := db.GetTxByHash(ctx, txHash)
+		require.NoError(t, err)
+		require.Equal(t, "0x"+strings.ToLower(hash), stored.Hash.Hex())
+	}
+
+	msg := getTestMessage(t, db, hash, 0)
+	require.JSONEq(t, fmt.Sprintf(`[%q, %q]`, strings.ToLower(hexAddr), bech32Addr), msg.InvolvedAddresses)
+
+	invalid := newTestTx(t, "0x"+strings.Repeat("zz", 32), 5)
+	require.ErrorIs(t, db.SaveTx(ctx, 1700000000, 1, invalid), common.ErrInvalidHash)
+}
+
+func TestSaveLargeTx(t *testing.T) {
+	ctx := context.Background()
+	db := newTestImpl(t, &models.Tx{}, &models.Message{})
+
+	// A 20MB message, as large as the ones carrying wasm bytecode
+	large := newTestTx(t, fmt.Sprintf("0x%064x", 1), 10)
+	msg, err := codectypes.NewAnyWithValue(&banktypes.MsgSetSendEnabled{
+		Authority:   sdk.AccAddress(bytes.Repeat([]byte{1}, 20)).String(),
+		SendEnabled: []*banktypes.SendEnabled{{Denom: strings.Repeat("wasm", 5<<20), Enabled: true}},
+	})
+	require.NoError(t, err)
+	large.Body.Messages = []*codectypes.Any{msg}
+	small := newTestTx(t, fmt.Sprintf("0x%064x", 2), 10)
+	require.NoError(t, db.SaveTxs(ctx, 1700000000, []*types.Tx{large, small}))
+
+	var rows []*models.Tx
+	require.NoError(t, db.Db.Table((&models.Tx{}).TableName()).Order("tx_index").Find(&rows).Error)
+	require.Len(t, rows, 2)
+	require.True(t, rows[0].Compressed)
+	require.Equal(t, "[]", rows[0].Messages)
+	require.Equal(t, "[]", rows[0].Logs)
+	require.Less(t, len(rows[0].CompressedMessages), DefaultTxCompressionThreshold)
+	require.False(t, rows[1].Compressed)
+	require.Empty(t, rows[1].CompressedMessages)
+
+	// The same tx saved without compression tells what must be read back
+	plain := newTestImpl(t, &models.Tx{}, &models.Message{})
+	plain.TxCompressThreshold = -1
+	require.NoError(t, plain.SaveTx(ctx, 1700000000, 0, large))
+	expected, err := plain.GetTxByHash(ctx, common.HexToHash(large.TxHash))
+	require.NoError(t, err)
+	require.False(t, expected.Compressed)
+	require.Greater(t, len(expected.Messages), 20<<20)
+
+	stored, err := db.GetTxByHash(ctx, common.HexToHash(large.TxHash))
+	require.NoError(t, err)
+	require.False(t, stored.Compressed)
+	require.Equal(t, expected.Messages, stored.Messages)
+	require.Equal(t, expected.Logs, stored.Logs)
+	msgs, err := stored.DecodeMessages()
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+
+	byHeight, _, err := db.GetTxsByHeight(ctx, 10, 10, 0)
+	require.NoError(t, err)
+	require.Equal(t, expected.Messages, byHeight[0].Messages)
+
+	// The value of the large message is compressed too, unlike the one of the small tx
+	expectedMsg := getTestMessage(t, plain, large.TxHash, 0)
+	require.False(t, expectedMsg.Compressed)
+	storedMsg := getTestMessage(t, db, large.TxHash, 0)
+	require.True(t, storedMsg.Compressed)
+	require.Equal(t, "{}", storedMsg.Value)
+	value, err := zstdDecoder.DecodeAll(storedMsg.CompressedValue, nil)
+	require.NoError(t, err)
+	require.Equal(t, expectedMsg.Value, string(value))
+	require.False(t, getTestMessage(t, db, small.TxHash, 0).Compressed)
+
+	// Compressing is deterministic, so saving the tx again leaves the same row
+	require.NoError(t, db.SaveTx(ctx, 1700000000, 0, large))
+	var again models.Tx
+	require.NoError(t, db.Db.Table((&models.Tx{}).TableName()).Where("tx_index = ?", 0).Take(&again).Error)
+	require.Equal(t, rows[0].CompressedMessages, again.CompressedMessages)
+
+	// The messages can be backfilled out of compressed txs as well
+	require.NoError(t, db.Db.Exec("DELETE FROM messages").Error)
+	count, err := db.BackfillMessages(ctx, 10, 10)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), count)
+	txs, err := db.ListTxsByMessageType(ctx, "/cosmos.bank.v1beta1.MsgSetSendEnabled", 0, 10, 10, 0)
+	require.NoError(t, err)
+	require.Len(t, txs, 1)
+	require.Equal(t, expected.Messages, txs[0].Messages)
+}
@@ -0,0 +1,250 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/forbole/juno/v4/common"
+	"github.com/forbole/juno/v4/models"
+)
+
+func TestUpdatesWriteZeroValues(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("bucket", func(t *testing.T) {
+		db := newTestImpl(t, &models.Bucket{})
+		bucketID := common.HexToHash("0x01")
+		require.NoError(t, db.SaveBucket(ctx, &models.Bucket{
+			BucketID: bucketID, BucketName: "bucket", ChargedReadQuota: 100, Visibility: "VISIBILITY_TYPE_PUBLIC_READ", UpdateAt: 1,
+		}))
+
+		// Without columns zero values are skipped
+		require.NoError(t, db.UpdateBucket(ctx, &models.Bucket{BucketID: bucketID, UpdateAt: 2}))
+		bucket, err := db.GetBucketByID(ctx, bucketID)
+		require.NoError(t, err)
+		require.Equal(t, uint64(100), bucket.ChargedReadQuota)
+		require.Equal(t, int64(2), bucket.UpdateAt)
+
+		require.NoError(t, db.UpdateBucket(ctx, &models.Bucket{BucketID: bucketID, UpdateAt: 3}, "charged_read_quota", "update_at"))
+		bucket, err = db.GetBucketByID(ctx, bucketID)
+		require.NoError(t, err)
+		require.Zero(t, bucket.ChargedReadQuota)
+		require.Equal(t, int64(3), bucket.UpdateAt)
+		// Columns which are not listed are left untouched
+		require.Equal(t, "bucket", bucket.BucketName)
+		require.Equal(t, "VISIBILITY_TYPE_PUBLIC_READ", bucket.Visibility)
+	})
+
+	t.Run("object", func(t *testing.T) {
+		db := newTestImpl(t, &models.Object{})
+		objectID := common.HexToHash("0x01")
+		require.NoError(t, db.SaveObject(ctx, &models.Object{ObjectID: objectID, ObjectName: "file", Removed: true}))
+
+		require.NoError(t, db.UpdateObject(ctx, &models.Object{ObjectID: objectID}, "removed"))
+		object, err := db.GetObject(ctx, objectID)
+		require.NoError(t, err)
+		require.False(t, object.Removed)
+		require.Equal(t, "file", object.ObjectName)
+	})
+
+	t.Run("permission", func(t *testing.T) {
+		db := newTestImpl(t, &models.Permission{})
+		policyID := common.HexToHash("0x01")
+		require.NoError(t, db.SavePermission(ctx, &models.Permission{PolicyID: policyID, ExpirationTime: 100, Removed: true}))
+
+		require.NoError(t, db.UpdatePermission(ctx, &models.Permission{PolicyID: policyID}, "removed", "expiration_time"))
+		permission, err := db.GetPermissionByPolicyID(ctx, policyID)
+		require.NoError(t, err)
+		require.False(t, permission.Removed)
+		require.Zero(t, permission.ExpirationTime)
+	})
+
+	t.Run("group", func(t *testing.T) {
+		db := newTestImpl(t, &models.Group{})
+		groupID, account := common.HexToHash("0x01"), common.HexToAddress("0x02")
+		require.NoError(t, db.CreateGroup(ctx, []*models.Group{
+			{GroupID: groupID, AccountID: account, ExpirationTime: 100, Extra: "extra"},
+		}))
+
+		require.NoError(t, db.UpdateGroup(ctx, &models.Group{GroupID: groupID, AccountID: account}, "expiration_time"))
+		members, err := db.GetGroupMembers(ctx, groupID, 10, common.Address{})
+		require.NoError(t, err)
+		require.Len(t, members, 1)
+		require.Zero(t, members[0].ExpirationTime)
+		require.Equal(t, "extra", members[0].Extra)
+	})
+
+	t.Run("storage provider", func(t *testing.T) {
+		db := newTestImpl(t, &models.StorageProvider{})
+		sp := newTestStorageProvider(1, common.HexToAddress("0x01"))
+		sp.FreeReadQuota = 100
+		sp.Moniker = "moniker"
+		require.NoError(t, db.CreateStorageProvider(ctx, sp))
+
+		require.NoError(t, db.UpdateStorageProvider(ctx, &models.StorageProvider{SpId: 1}, "free_read_quota", "moniker"))
+		stored, err := db.GetStorageProviderByID(ctx, 1)
+		require.NoError(t, err)
+		require.Zero(t, stored.FreeReadQuota)
+		require.Empty(t, stored.Moniker)
+		require.Equal(t, "https://sp.example.com", stored.Endpoint)
+	})
+
+	t.Run("gvg", func(t *testing.T) {
+		db := newTestImpl(t, &models.GlobalVirtualGroup{})
+		gvg := newTestGVG(1, 1, 1, 2, 3)
+		gvg.StoredSize = 100
+		require.NoError(t, db.SaveGVG(ctx, gvg))
+
+		require.NoError(t, db.UpdateGVG(ctx, newTestGVG(1, 0, 0), "stored_size", "secondary_sp_ids"))
+		stored, err := db.GetGVGByID(ctx, 1)
+		require.NoError(t, err)
+		require.Zero(t, stored.StoredSize)
+		require.Empty(t, stored.SecondarySpIds)
+		require.Equal(t, uint32(1), stored.PrimarySpId)
+	})
+
+	t.Run("lvg", func(t *testing.T) {
+		db := newTestImpl(t, &models.LocalVirtualGroup{})
+		bucketID := common.HexToHash("0x01")
+		require.NoError(t, db.SaveLVG(ctx, &models.LocalVirtualGroup{
+			LocalVirtualGroupId: 1, GlobalVirtualGroupId: 2, BucketID: bucketID, StoredSize: 100,
+		}))
+
+		require.NoError(t, db.UpdateLVG(ctx, &models.LocalVirtualGroup{LocalVirtualGroupId: 1, BucketID: bucketID}, "stored_size"))
+		stored, err := db.GetLVG(ctx, bucketID, 1)
+		require.NoError(t, err)
+		require.Zero(t, stored.StoredSize)
+		require.Equal(t, uint32(2), stored.GlobalVirtualGroupId)
+	})
+
+	t.Run("vgf", func(t *testing.T) {
+		db := newTestImpl(t, &models.GlobalVirtualGroupFamily{})
+		require.NoError(t, db.SaveVGF(ctx, &models.GlobalVirtualGroupFamily{
+			GlobalVirtualGroupFamilyId: 1, PrimarySpId: 1, GlobalVirtualGroupIds: []uint32{1, 2},
+		}))
+
+		require.NoError(t, db.UpdateVGF(ctx, &models.GlobalVirtualGroupFamily{GlobalVirtualGroupFamilyId: 1}, "global_virtual_group_ids"))
+		stored, err := db.GetVGFByID(ctx, 1)
+		require.NoError(t, err)
+		require.Empty(t, stored.GlobalVirtualGroupIds)
+		require.Equal(t, uint32(1), stored.PrimarySpId)
+	})
+}
+
+func TestUpdatesAtSkipStaleWrites(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("bucket", func(t *testing.T) {
+		db := newTestImpl(t, &models.Bucket{})
+		bucketID := common.HexToHash("0x01")
+		require.NoError(t, db.SaveBucket(ctx, &models.Bucket{BucketID: bucketID, BucketName: "bucket", UpdateAt: 1}))
+
+		// The update of block 20 is handled before the one of block 10
+		updated, err := db.UpdateBucketAt(ctx, 20, &models.Bucket{BucketID: bucketID, Visibility: "VISIBILITY_TYPE_PRIVATE", UpdateAt: 20}, "visibility", "update_at")
+		require.NoError(t, err)
+		require.Equal(t, int64(1), updated)
+		updated, err = db.UpdateBucketAt(ctx, 10, &models.Bucket{BucketID: bucketID, Visibility: "VISIBILITY_TYPE_PUBLIC_READ", UpdateAt: 10}, "visibility", "update_at")
+		require.NoError(t, err)
+		require.Zero(t, updated)
+
+		bucket, err := db.GetBucketByID(ctx, bucketID)
+		require.NoError(t, err)
+		require.Equal(t, "VISIBILITY_TYPE_PRIVATE", bucket.Visibility)
+		require.Equal(t, int64(20), bucket.UpdateAt)
+
+		// Another update of the same block is still applied
+		updated, err = db.UpdateBucketAt(ctx, 20, &models.Bucket{BucketID: bucketID, ChargedReadQuota: 100, UpdateAt: 20}, "charged_read_quota", "update_at")
+		require.NoError(t, err)
+		require.Equal(t, int64(1), updated)
+	})
+
+	t.Run("object", func(t *testing.T) {
+		db := newTestImpl(t, &models.Object{})
+		objectID := common.HexToHash("0x01")
+		require.NoError(t, db.SaveObject(ctx, &models.Object{ObjectID: objectID, ObjectName: "file", UpdateAt: 1}))
+
+		_, err := db.UpdateObjectAt(ctx, 20, &models.Object{ObjectID: objectID, Removed: true, UpdateAt: 20}, "removed", "update_at")
+		require.NoError(t, err)
+		updated, err := db.UpdateObjectAt(ctx, 10, &models.Object{ObjectID: objectID, Status: "OBJECT_STATUS_SEALED", UpdateAt: 10}, "status", "update_at")
+		require.NoError(t, err)
+		require.Zero(t, updated)
+
+		var object models.Object
+		require.NoError(t, db.Db.Table(object.TableName()).Where("object_id = ?", objectID).Take(&object).Error)
+		require.True(t, object.Removed)
+		require.Empty(t, object.Status)
+		require.Equal(t, int64(20), object.UpdateAt)
+	})
+
+	t.Run("group", func(t *testing.T) {
+		db := newTestImpl(t, &models.Group{})
+		groupID, account := common.HexToHash("0x01"), common.HexToAddress("0x02")
+		require.NoError(t, db.CreateGroup(ctx, []*models.Group{{GroupID: groupID, AccountID: account, UpdateAt: 1}}))
+
+		_, err := db.UpdateGroupAt(ctx, 20, &models.Group{GroupID: groupID, AccountID: account, ExpirationTime: 200, UpdateAt: 20}, "expiration_time", "update_at")
+		require.NoError(t, err)
+		updated, err := db.UpdateGroupAt(ctx, 10, &models.Group{GroupID: groupID, AccountID: account, ExpirationTime: 100, UpdateAt: 10}, "expiration_time", "update_at")
+		require.NoError(t, err)
+		require.Zero(t, updated)
+
+		members, err := db.GetGroupMembers(ctx, groupID, 10, common.Address{})
+		require.NoError(t, err)
+		require.Len(t, members, 1)
+		require.Equal(t, int64(200), members[0].ExpirationTime)
+	})
+
+	t.Run("storage provider", func(t *testing.T) {
+		db := newTestImpl(t, &models.StorageProvider{})
+		require.NoError(t, db.CreateStorageProvider(ctx, newTestStorageProvider(1, common.HexToAddress("0x01"))))
+
+		_, err := db.UpdateStorageProviderAt(ctx, 20, &models.StorageProvider{SpId: 1, Moniker: "newer", UpdateAt: 20}, "moniker", "update_at")
+		require.NoError(t, err)
+		updated, err := db.UpdateStorageProviderAt(ctx, 10, &models.StorageProvider{SpId: 1, Moniker: "older", UpdateAt: 10}, "moniker", "update_at")
+		require.NoError(t, err)
+		require.Zero(t, updated)
+
+		stored, err := db.GetStorageProviderByID(ctx, 1)
+		require.NoError(t, err)
+		require.Equal(t, "newer", stored.Moniker)
+	})
+
+	t.Run("virtual groups", func(t *testing.T) {
+		// the virtual group tables share index names, which sqlite won't allow in one database
+		db := newTestImpl(t, &models.GlobalVirtualGroup{})
+		lvgDb := newTestImpl(t, &models.LocalVirtualGroup{})
+		vgfDb := newTestImpl(t, &models.GlobalVirtualGroupFamily{})
+		bucketID := common.HexToHash("0x01")
+		require.NoError(t, db.SaveGVG(ctx, newTestGVG(1, 1, 1, 2)))
+		require.NoError(t, lvgDb.SaveLVG(ctx, &models.LocalVirtualGroup{LocalVirtualGroupId: 1, BucketID: bucketID}))
+		require.NoError(t, vgfDb.SaveVGF(ctx, &models.GlobalVirtualGroupFamily{GlobalVirtualGroupFamilyId: 1, PrimarySpId: 1}))
+
+		_, err := db.UpdateGVGAt(ctx, 20, &models.GlobalVirtualGroup{GlobalVirtualGroupId: 1, StoredSize: 200, UpdateAt: 20}, "stored_size", "update_at")
+		require.NoError(t, err)
+		updated, err := db.UpdateGVGAt(ctx, 10, &models.GlobalVirtualGroup{GlobalVirtualGroupId: 1, StoredSize: 100, UpdateAt: 10}, "stored_size", "update_at")
+		require.NoError(t, err)
+		require.Zero(t, updated)
+		gvg, err := db.GetGVGByID(ctx, 1)
+		require.NoError(t, err)
+		require.Equal(t, uint64(200), gvg.StoredSize)
+
+		_, err = lvgDb.UpdateLVGAt(ctx, 20, &models.LocalVirtualGroup{LocalVirtualGroupId: 1, BucketID: bucketID, StoredSize: 200, UpdateAt: 20}, "stored_size", "update_at")
+		require.NoError(t, err)
+		updated, err = lvgDb.UpdateLVGAt(ctx, 10, &models.LocalVirtualGroup{LocalVirtualGroupId: 1, BucketID: bucketID, StoredSize: 100, UpdateAt: 10}, "stored_size", "update_at")
+		require.NoError(t, err)
+		require.Zero(t, updated)
+		lvg, err := lvgDb.GetLVG(ctx, bucketID, 1)
+		require.NoError(t, err)
+		require.Equal(t, uint64(200), lvg.StoredSize)
+
+		_, err = vgfDb.UpdateVGFAt(ctx, 20, &models.GlobalVirtualGroupFamily{GlobalVirtualGroupFamilyId: 1, PrimarySpId: 3, UpdateAt: 20}, "primary_sp_id", "update_at")
+		require.NoError(t, err)
+		updated, err = vgfDb.UpdateVGFAt(ctx, 10, &models.GlobalVirtualGroupFamily{GlobalVirtualGroupFamilyId: 1, PrimarySpId: 2, UpdateAt: 10}, "primary_sp_id", "update_at")
+		require.NoError(t, err)
+		require.Zero(t, updated)
+		vgf, err := vgfDb.GetVGFByID(ctx, 1)
+		require.NoError(t, err)
+		require.Equal(t, uint32(3), vgf.PrimarySpId)
+	})
+}
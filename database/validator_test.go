@@ -0,0 +1,59 @@
+package database
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/stretchr/testify/require"
+
+	"github.com/forbole/juno/v4/common"
+	"github.com/forbole/juno/v4/models"
+)
+
+func TestSaveValidators(t *testing.T) {
+	ctx := context.Background()
+	db := newTestImpl(t, &models.Validator{})
+
+	first := models.NewValidator(common.HexToAddress("0x01"), models.BytesToPubkey([]byte{0x01}), 5)
+	second := models.NewValidator(common.HexToAddress("0x02"), models.BytesToPubkey([]byte{0x02}), 5)
+	require.NoError(t, db.SaveValidators(ctx, []*models.Validator{first, second}))
+	require.NoError(t, db.SaveValidators(ctx, nil))
+
+	// Saving a validator again keeps the height it was first seen at
+	again := models.NewValidator(common.HexToAddress("0x01"), models.BytesToPubkey([]byte{0x01}), 10)
+	third := models.NewValidator(common.HexToAddress("0x03"), models.BytesToPubkey([]byte{0x03}), 10)
+	require.NoError(t, db.SaveValidators(ctx, []*models.Validator{again, third}))
+
+	var count int64
+	require.NoError(t, db.Db.Table((&models.Validator{}).TableName()).Count(&count).Error)
+	require.Equal(t, int64(3), count)
+
+	stored, err := db.GetValidatorByConsAddr(ctx, common.HexToAddress("0x01").Hex())
+	require.NoError(t, err)
+	require.Equal(t, uint64(5), stored.FirstSeenHeight)
+	require.Equal(t, first.ConsensusPubkey, stored.ConsensusPubkey)
+
+	// Commit signatures refer to validators by their bech32 address
+	stored, err = db.GetValidatorByConsAddr(ctx, sdk.ConsAddress(common.HexToAddress("0x03").Bytes()).String())
+	require.NoError(t, err)
+	require.Equal(t, uint64(10), stored.FirstSeenHeight)
+
+	// Both encodings are matched regardless of their case and of the 0x prefix
+	for _, addr := range []string{
+		strings.ToUpper(common.HexToAddress("0x03").Hex()[2:]),
+		strings.ToUpper(sdk.ConsAddress(common.HexToAddress("0x03").Bytes()).String()),
+	} {
+		stored, err = db.GetValidatorByConsAddr(ctx, addr)
+		require.NoError(t, err)
+		require.Equal(t, uint64(10), stored.FirstSeenHeight)
+	}
+
+	_, err = db.GetValidatorByConsAddr(ctx, common.HexToAddress("0x04").Hex())
+	require.ErrorIs(t, err, ErrValidatorNotFound)
+
+	_, err = db.GetValidatorByConsAddr(ctx, "invalid")
+	require.Error(t, err)
+	require.NotErrorIs(t, err, ErrNotFound)
+}
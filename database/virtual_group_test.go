@@ -0,0 +1,154 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/forbole/juno/v4/common"
+	"github.com/forbole/juno/v4/models"
+)
+
+func newTestGVG(id, familyID, primarySpID uint32, secondarySpIDs ...uint32) *models.GlobalVirtualGroup {
+	return &models.GlobalVirtualGroup{
+		GlobalVirtualGroupId: id,
+		FamilyId:             familyID,
+		PrimarySpId:          primarySpID,
+		SecondarySpIds:       secondarySpIDs,
+		TotalDeposit:         (*common.Big)(big.NewInt(0)),
+	}
+}
+
+func gvgIDs(gvgs []*models.GlobalVirtualGroup) []uint32 {
+	ids := make([]uint32, len(gvgs))
+	for i, gvg := range gvgs {
+		ids[i] = gvg.GlobalVirtualGroupId
+	}
+	return ids
+}
+
+func TestGVGLookups(t *testing.T) {
+	ctx := context.Background()
+	db := newTestImpl(t, &models.GlobalVirtualGroup{})
+
+	require.NoError(t, db.SaveGVG(ctx, newTestGVG(1, 10, 1, 2, 3, 4)))
+	require.NoError(t, db.SaveGVG(ctx, newTestGVG(2, 10, 2, 1, 3, 4)))
+	require.NoError(t, db.SaveGVG(ctx, newTestGVG(3, 11, 3, 12, 21, 4)))
+	require.NoError(t, db.SaveGVG(ctx, newTestGVG(4, 11, 2, 5, 6, 7)))
+	require.NoError(t, db.UpdateGVG(ctx, &models.GlobalVirtualGroup{GlobalVirtualGroupId: 4, Removed: true}))
+
+	gvg, err := db.GetGVGByID(ctx, 3)
+	require.NoError(t, err)
+	require.Equal(t, uint32(11), gvg.FamilyId)
+	require.Equal(t, common.Uint32Array{12, 21, 4}, gvg.SecondarySpIds)
+
+	_, err = db.GetGVGByID(ctx, 5)
+	require.True(t, errors.Is(err, ErrGVGNotFound))
+
+	gvgs, err := db.ListGVGsByFamilyID(ctx, 11)
+	require.NoError(t, err)
+	require.Equal(t, []uint32{3}, gvgIDs(gvgs))
+
+	// sp 2 is secondary in gvg 1, primary in gvg 2 and primary in the removed gvg 4
+	gvgs, err = db.ListGVGsBySPID(ctx, 2)
+	require.NoError(t, err)
+	require.Equal(t, []uint32{1, 2}, gvgIDs(gvgs))
+
+	// sp 1 must not match the secondary ids 12 and 21 of gvg 3
+	gvgs, err = db.ListGVGsBySPID(ctx, 1)
+	require.NoError(t, err)
+	require.Equal(t, []uint32{1, 2}, gvgIDs(gvgs))
+
+	gvgs, err = db.ListGVGsBySPID(ctx, 99)
+	require.NoError(t, err)
+	require.Empty(t, gvgs)
+}
+
+func TestLVGLookups(t *testing.T) {
+	ctx := context.Background()
+	db := newTestImpl(t, &models.LocalVirtualGroup{})
+
+	bucketA, bucketB := common.HexToHash("0x0a"), common.HexToHash("0x0b")
+	require.NoError(t, db.SaveLVG(ctx, &models.LocalVirtualGroup{LocalVirtualGroupId: 2, GlobalVirtualGroupId: 20, BucketID: bucketA}))
+	require.NoError(t, db.SaveLVG(ctx, &models.LocalVirtualGroup{LocalVirtualGroupId: 1, GlobalVirtualGroupId: 10, BucketID: bucketA}))
+	require.NoError(t, db.SaveLVG(ctx, &models.LocalVirtualGroup{LocalVirtualGroupId: 1, GlobalVirtualGroupId: 30, BucketID: bucketB}))
+	require.NoError(t, db.SaveLVG(ctx, &models.LocalVirtualGroup{LocalVirtualGroupId: 3, GlobalVirtualGroupId: 40, BucketID: bucketA}))
+	require.NoError(t, db.UpdateLVG(ctx, &models.LocalVirtualGroup{LocalVirtualGroupId: 3, BucketID: bucketA, Removed: true}))
+
+	// Updating the lvg 1 of bucket B must leave the one of bucket A untouched
+	require.NoError(t, db.UpdateLVG(ctx, &models.LocalVirtualGroup{LocalVirtualGroupId: 1, BucketID: bucketB, StoredSize: 100}))
+
+	lvgs, err := db.ListLVGsByBucket(ctx, bucketA)
+	require.NoError(t, err)
+	require.Len(t, lvgs, 2)
+	require.Equal(t, uint32(1), lvgs[0].LocalVirtualGroupId)
+	require.Equal(t, uint32(10), lvgs[0].GlobalVirtualGroupId)
+	require.Zero(t, lvgs[0].StoredSize)
+	require.Equal(t, uint32(2), lvgs[1].LocalVirtualGroupId)
+
+	lvg, err := db.GetLVG(ctx, bucketB, 1)
+	require.NoError(t, err)
+	require.Equal(t, uint32(30), lvg.GlobalVirtualGroupId)
+	require.Equal(t, uint64(100), lvg.StoredSize)
+
+	_, err = db.GetLVG(ctx, bucketB, 2)
+	require.True(t, errors.Is(err, ErrLVGNotFound))
+}
+
+func TestVGFLookups(t *testing.T) {
+	ctx := context.Background()
+	db := newTestImpl(t, &models.GlobalVirtualGroupFamily{})
+
+	for id := uint32(1); id <= 5; id++ {
+		require.NoError(t, db.SaveVGF(ctx, &models.GlobalVirtualGroupFamily{
+			GlobalVirtualGroupFamilyId: id,
+			PrimarySpId:                1,
+			GlobalVirtualGroupIds:      common.Uint32Array{id * 10},
+			VirtualPaymentAddress:      common.BigToAddress(big.NewInt(int64(id))),
+		}))
+	}
+	require.NoError(t, db.SaveVGF(ctx, &models.GlobalVirtualGroupFamily{GlobalVirtualGroupFamilyId: 6, PrimarySpId: 2}))
+	require.NoError(t, db.UpdateVGF(ctx, &models.GlobalVirtualGroupFamily{GlobalVirtualGroupFamilyId: 3, Removed: true}))
+
+	_, err := db.GetVGFByID(ctx, 0)
+	require.True(t, errors.Is(err, ErrInvalidVGFID))
+
+	vgf, err := db.GetVGFByID(ctx, 2)
+	require.NoError(t, err)
+	require.Equal(t, common.BigToAddress(big.NewInt(2)), vgf.VirtualPaymentAddress)
+	require.Equal(t, common.Uint32Array{20}, vgf.GlobalVirtualGroupIds)
+
+	_, err = db.GetVGFByID(ctx, 3)
+	require.True(t, errors.Is(err, ErrVGFNotFound))
+
+	vgf, err = db.GetVGFByIDWithRemoved(ctx, 3)
+	require.NoError(t, err)
+	require.True(t, vgf.Removed)
+
+	vgfIDs := func(vgfs []*models.GlobalVirtualGroupFamily) []uint32 {
+		ids := make([]uint32, len(vgfs))
+		for i, vgf := range vgfs {
+			ids[i] = vgf.GlobalVirtualGroupFamilyId
+		}
+		return ids
+	}
+
+	vgfs, err := db.ListVGFsByPrimarySP(ctx, 1, 2, 0)
+	require.NoError(t, err)
+	require.Equal(t, []uint32{1, 2}, vgfIDs(vgfs))
+
+	vgfs, err = db.ListVGFsByPrimarySP(ctx, 1, 2, 2)
+	require.NoError(t, err)
+	require.Equal(t, []uint32{4, 5}, vgfIDs(vgfs))
+
+	vgfs, err = db.ListVGFsByPrimarySP(ctx, 1, 2, 4)
+	require.NoError(t, err)
+	require.Empty(t, vgfs)
+
+	vgfs, err = db.ListVGFsByPrimarySPWithRemoved(ctx, 1, 10, 0)
+	require.NoError(t, err)
+	require.Equal(t, []uint32{1, 2, 3, 4, 5}, vgfIDs(vgfs))
+}
@@ -65,6 +65,82 @@ var DBLatencyHist = promauto.NewHistogram(
 	},
 )
 
+// DBRetryCount represents the Telemetry counter used to track the writes retried after a transient failure
+var DBRetryCount = promauto.NewCounter(
+	prometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: "db",
+		Name:      "retries",
+		Help:      "Count of database writes retried after a deadlock or serialization failure.",
+	},
+)
+
+// DBStatementCount represents the Telemetry counter used to track the statements run on each table
+var DBStatementCount = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: "db",
+		Name:      "statements",
+		Help:      "Count of database statements by table and operation.",
+	},
+	[]string{"table", "operation"},
+)
+
+// DBStatementErrorCount represents the Telemetry counter used to track the statements failed on each table
+var DBStatementErrorCount = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: "db",
+		Name:      "statement_errors",
+		Help:      "Count of failed database statements by table and operation.",
+	},
+	[]string{"table", "operation"},
+)
+
+// DBStatementLatencyHist represents the Telemetry histogram used to track the duration of the statements run on each table
+var DBStatementLatencyHist = promauto.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Subsystem: "db",
+		Name:      "statement_latency",
+		Help:      "Duration in seconds of database statements by table and operation.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 3, 12),
+	},
+	[]string{"table", "operation"},
+)
+
+// BackfillGapsFound represents the Telemetry counter used to track the missing heights found by the backfiller
+var BackfillGapsFound = promauto.NewCounter(
+	prometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: "backfill",
+		Name:      "gaps_found",
+		Help:      "Count of missing heights found below the epoch.",
+	},
+)
+
+// BackfillGapsFilled represents the Telemetry counter used to track the missing heights parsed by the backfiller
+var BackfillGapsFilled = promauto.NewCounter(
+	prometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: "backfill",
+		Name:      "gaps_filled",
+		Help:      "Count of missing heights found below the epoch and parsed since.",
+	},
+)
+
+// QueueDepth represents the Telemetry gauge used to track the number of heights waiting for the workers,
+// by priority
+var QueueDepth = promauto.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Subsystem: "queue",
+		Name:      "depth",
+		Help:      "Number of heights waiting to be processed, high for the new heights and low for the missing ones.",
+	},
+	[]string{"priority"},
+)
+
 var IndexerLatencyHist = promauto.NewHistogramVec(
 	prometheus.HistogramOpts{
 		Namespace: Namespace,
@@ -74,3 +150,48 @@ var IndexerLatencyHist = promauto.NewHistogramVec(
 	},
 	[]string{"procedure"},
 )
+
+// ModuleErrors represents the Telemetry counter used to track the errors and panics of the handlers of each module
+var ModuleErrors = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: "module",
+		Name:      "errors",
+		Help:      "Count of the errors returned, or panics raised, by the handlers of each module.",
+	},
+	[]string{"module", "kind"},
+)
+
+// AverageBlockTime represents the Telemetry gauge used to track the average time between two blocks, which the
+// adaptive polling waits for
+var AverageBlockTime = promauto.NewGauge(
+	prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Subsystem: "chain",
+		Name:      "average_block_time_seconds",
+		Help:      "Exponential moving average of the time between two parsed blocks, in seconds.",
+	},
+)
+
+// ExpiredRows represents the Telemetry counter used to track the rows marked as removed once expired, by table
+var ExpiredRows = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: "expiration",
+		Name:      "expired_rows",
+		Help:      "Count of the rows marked as removed by the expiration sweeps, by table.",
+	},
+	[]string{"table"},
+)
+
+// ReconciliationIssues represents the Telemetry counter used to track the fields of the stream records found
+// diverging from the chain, by field
+var ReconciliationIssues = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: "reconciliation",
+		Name:      "issues",
+		Help:      "Count of the fields of the stream records found diverging from the chain, by field.",
+	},
+	[]string{"field"},
+)
@@ -23,7 +23,7 @@ type BlockID struct {
 }
 
 type Header struct {
-	Height             uint64      `gorm:"column:height;not null;uniqueIndex:idx_height"`
+	Height             uint64      `gorm:"column:height;not null;uniqueIndex:idx_chain_height,priority:2"`
 	LastCommitHash     common.Hash `gorm:"last_commit_hash;type:BINARY(32)"`     // commit from validators from the last block
 	DataHash           common.Hash `gorm:"data_hash;type:BINARY(32)"`            // transactions
 	ValidatorsHash     common.Hash `gorm:"validators_hash;type:BINARY(32)"`      // validators for the current block
@@ -37,12 +37,15 @@ type Header struct {
 	EvidenceHash    common.Hash    `gorm:"evidence_hash;type:BINARY(32)"` // evidence included in the block
 	ProposerAddress common.Address `gorm:"column:proposer_address;type:BINARY(20);index:idx_proposer_address"`
 
-	Timestamp uint64 `gorm:"column:timestamp"`
+	Timestamp uint64 `gorm:"column:timestamp;index:idx_timestamp"`
 }
 
 type Block struct {
 	ID uint64 `gorm:"column:id;primaryKey" json:"-"`
 
+	// ChainID tells which chain the block belongs to, so that several chains can share the same database
+	ChainID string `gorm:"column:chain_id;type:varchar(64);not null;default:'';uniqueIndex:idx_chain_height,priority:1"`
+
 	BlockID
 	Header
 
@@ -0,0 +1,20 @@
+package models
+
+import "github.com/forbole/juno/v4/common"
+
+// BlockProgress records how far the txs and events of a height being processed have been handled, so that
+// processing it again after a crash resumes after them instead of handling them twice.
+// TxIndex is the index of the last tx whose tx and message handlers have been called, EventIndex the index of
+// the last event handled among the ones of every tx of the block, both being -1 when none has been.
+type BlockProgress struct {
+	ChainID    string      `gorm:"column:chain_id;type:varchar(64);not null;default:'';primaryKey"`
+	Height     uint64      `gorm:"column:height;primaryKey;autoIncrement:false"`
+	BlockHash  common.Hash `gorm:"column:block_hash;type:BINARY(32)"`
+	TxIndex    int         `gorm:"column:tx_index;not null;default:-1"`
+	EventIndex int         `gorm:"column:event_index;not null;default:-1"`
+	UpdateTime int64       `gorm:"column:update_time;type:bigint(64)"`
+}
+
+func (*BlockProgress) TableName() string {
+	return "block_progress"
+}
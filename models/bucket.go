@@ -9,8 +9,9 @@ import (
 type Bucket struct {
 	ID uint64 `gorm:"column:id;primaryKey"`
 
-	BucketID                   common.Hash    `gorm:"column:bucket_id;type:BINARY(32);uniqueIndex:idx_bucket_id"`
-	BucketName                 string         `gorm:"column:bucket_name;type:varchar(64);uniqueIndex:idx_bucket_name"` // BucketName length between 3 and 63
+	ChainID                    string         `gorm:"column:chain_id;type:varchar(64);not null;default:'';uniqueIndex:idx_chain_bucket_id,priority:1;uniqueIndex:idx_chain_bucket_name,priority:1"`
+	BucketID                   common.Hash    `gorm:"column:bucket_id;type:BINARY(32);uniqueIndex:idx_chain_bucket_id,priority:2"`
+	BucketName                 string         `gorm:"column:bucket_name;type:varchar(64);uniqueIndex:idx_chain_bucket_name,priority:2"` // BucketName length between 3 and 63
 	Owner                      common.Address `gorm:"column:owner;type:BINARY(20);index:idx_owner"`
 	PaymentAddress             common.Address `gorm:"column:payment_address;type:BINARY(20)"`
 	GlobalVirtualGroupFamilyId uint32         `gorm:"column:global_virtual_group_family_id;index:idx_vgf_id"`
@@ -22,6 +23,10 @@ type Bucket struct {
 	DeleteAt                   int64          `gorm:"column:delete_at"`
 	DeleteReason               string         `gorm:"column:delete_reason;type:varchar(256);"`
 
+	// MirrorStatus is the status of the mirroring of the bucket to MirrorDestChainID, empty if never mirrored
+	MirrorStatus      string `gorm:"column:mirror_status;type:varchar(32);not null;default:''"`
+	MirrorDestChainID uint32 `gorm:"column:mirror_dest_chain_id"`
+
 	StorageSize decimal.Decimal `gorm:"column:storage_size;type:DECIMAL(65, 0);not null"`
 	ChargeSize  decimal.Decimal `gorm:"column:charge_size;type:DECIMAL(65, 0);not null"`
 
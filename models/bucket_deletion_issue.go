@@ -0,0 +1,19 @@
+package models
+
+import "github.com/forbole/juno/v4/common"
+
+// BucketDeletionIssue records a bucket deleted by the chain while objects not removed were still stored inside it,
+// telling that the events removing them have been missed
+type BucketDeletionIssue struct {
+	ID uint64 `gorm:"column:id;primaryKey" json:"-"`
+
+	BucketID      common.Hash `gorm:"column:bucket_id;type:BINARY(32);not null;uniqueIndex:idx_deletion_issue_bucket_height,priority:1"`
+	BucketName    string      `gorm:"column:bucket_name;type:varchar(64)"`
+	ActiveObjects int64       `gorm:"column:active_objects"`
+	Height        int64       `gorm:"column:height;type:bigint(64);uniqueIndex:idx_deletion_issue_bucket_height,priority:2"`
+	TxHash        common.Hash `gorm:"column:tx_hash;type:BINARY(32)"`
+}
+
+func (*BucketDeletionIssue) TableName() string {
+	return "bucket_deletion_issues"
+}
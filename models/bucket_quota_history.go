@@ -0,0 +1,20 @@
+package models
+
+import "github.com/forbole/juno/v4/common"
+
+// BucketQuotaHistory records a change of the charged read quota or of the payment address of a bucket
+type BucketQuotaHistory struct {
+	ID uint64 `gorm:"column:id;primaryKey" json:"-"`
+
+	BucketID            common.Hash    `gorm:"column:bucket_id;type:BINARY(32);not null;index:idx_quota_history_bucket_height,priority:1"`
+	OldChargedReadQuota uint64         `gorm:"column:old_charged_read_quota"`
+	NewChargedReadQuota uint64         `gorm:"column:new_charged_read_quota"`
+	OldPaymentAddress   common.Address `gorm:"column:old_payment_address;type:BINARY(20)"`
+	NewPaymentAddress   common.Address `gorm:"column:new_payment_address;type:BINARY(20)"`
+	Height              int64          `gorm:"column:height;type:bigint(64);index:idx_quota_history_bucket_height,priority:2"`
+	TxHash              common.Hash    `gorm:"column:tx_hash;type:BINARY(32)"`
+}
+
+func (*BucketQuotaHistory) TableName() string {
+	return "bucket_quota_history"
+}
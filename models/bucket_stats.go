@@ -0,0 +1,18 @@
+package models
+
+import "github.com/forbole/juno/v4/common"
+
+// BucketStats holds the number of the objects stored inside a bucket and their total payload size, maintained as
+// the objects get sealed, updated and deleted
+type BucketStats struct {
+	ID uint64 `gorm:"column:id;primaryKey" json:"-"`
+
+	ChainID     string      `gorm:"column:chain_id;type:varchar(64);not null;default:'';uniqueIndex:idx_chain_bucket_stats,priority:1"`
+	BucketID    common.Hash `gorm:"column:bucket_id;type:BINARY(32);not null;uniqueIndex:idx_chain_bucket_stats,priority:2"`
+	ObjectCount int64       `gorm:"column:object_count;not null;default:0"`
+	StoredSize  int64       `gorm:"column:stored_size;not null;default:0"`
+}
+
+func (*BucketStats) TableName() string {
+	return "bucket_stats"
+}
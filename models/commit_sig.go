@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+)
+
+// CommitSig contains the data of a single validator commit signature
+type CommitSig struct {
+	ID uint64 `gorm:"column:id;primaryKey" json:"-"`
+
+	// ChainID tells which chain the signature belongs to, so that several chains can share the same database
+	ChainID string `gorm:"column:chain_id;type:varchar(64);not null;default:'';uniqueIndex:idx_chain_validator_address_height,priority:1"`
+
+	ValidatorAddress string    `gorm:"column:validator_address;type:varchar(128);not null;uniqueIndex:idx_chain_validator_address_height,priority:2"`
+	Height           uint64    `gorm:"column:height;not null;uniqueIndex:idx_chain_validator_address_height,priority:3;index:idx_pre_commit_height"`
+	Timestamp        time.Time `gorm:"column:timestamp;not null"`
+	VotingPower      int64     `gorm:"column:voting_power;not null"`
+	ProposerPriority int64     `gorm:"column:proposer_priority;not null"`
+}
+
+func (*CommitSig) TableName() string {
+	return "pre_commit"
+}
@@ -1,23 +1,36 @@
 package models
 
+// DataStat contains the statistics of the stored objects, computed at BlockHeight
 type DataStat struct {
-	OneRowId         bool   `gorm:"one_row_id;not null;default:true;primaryKey"`
-	BlockHeight      int64  `gorm:"column:block_height;type:bigint(64)"`
-	ObjectTotalCount string `gorm:"column:object_total_count;type:VARCHAR(2048)"`
-	ObjectSealCount  string `gorm:"column:object_seal_count;type:VARCHAR(2048)"`
-	ObjectDelCount   string `gorm:"column:object_del_count;type:VARCHAR(2048)"`
-	UpdateTime       int64  `gorm:"update_time;type:bigint(64)"`
+	OneRowId         bool  `gorm:"one_row_id;not null;default:true;primaryKey"`
+	BlockHeight      int64 `gorm:"column:block_height;type:bigint(64)"`
+	ObjectTotalCount int64 `gorm:"column:object_total_count;type:bigint(64);not null;default:0"`
+	ObjectSealCount  int64 `gorm:"column:object_seal_count;type:bigint(64);not null;default:0"`
+	ObjectDelCount   int64 `gorm:"column:object_del_count;type:bigint(64);not null;default:0"`
+	UpdateTime       int64 `gorm:"update_time;type:bigint(64)"`
 }
 
 func (*DataStat) TableName() string {
 	return "data_stat"
 }
 
+// BlockResult contains the results of a block as returned by the node, Encoding telling how Result is encoded
 type BlockResult struct {
+	// ChainID tells which chain the block belongs to, so that several chains can share the same database
+	ChainID     string `gorm:"column:chain_id;type:varchar(64);not null;default:'';primaryKey"`
 	BlockHeight uint64 `gorm:"primaryKey"`
 	Result      string `gorm:"column:result;type:mediumtext"`
+	Encoding    string `gorm:"column:encoding;type:varchar(16);not null;default:''"`
 }
 
+const (
+	// BlockResultEncodingRaw is the encoding of the results stored as they are
+	BlockResultEncodingRaw = ""
+
+	// BlockResultEncodingGzip is the encoding of the results compressed with gzip, then base64 encoded
+	BlockResultEncodingGzip = "gzip+base64"
+)
+
 func (*BlockResult) TableName() string {
 	return "block_result"
 }
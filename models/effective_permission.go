@@ -0,0 +1,24 @@
+package models
+
+import "github.com/forbole/juno/v4/common"
+
+// EffectivePermission materializes a policy put on a group for a member of the group, so that the policies
+// applying to an account through its groups can be read without going through the group membership.
+// ActionValue holds the actions allowed by the statements of the policy and DenyActionValue the denied ones,
+// recorded as in Statements. ExpirationTime is the earliest of the expiration times of the policy and of the
+// membership, zero when neither expires.
+type EffectivePermission struct {
+	ID              uint64         `gorm:"column:id;primaryKey"`
+	Account         common.Address `gorm:"column:account;type:BINARY(20);uniqueIndex:idx_effective_account_policy,priority:1;index:idx_effective_account_resource,priority:1"`
+	ResourceType    string         `gorm:"column:resource_type;type:varchar(64)"`
+	ResourceID      common.Hash    `gorm:"column:resource_id;type:BINARY(32);index:idx_effective_account_resource,priority:2"`
+	PolicyID        common.Hash    `gorm:"column:policy_id;type:BINARY(32);uniqueIndex:idx_effective_account_policy,priority:2;index:idx_effective_policy_id"`
+	GroupID         common.Hash    `gorm:"column:group_id;type:BINARY(32);index:idx_effective_group_id"`
+	ActionValue     int            `gorm:"column:action_value;type:int"`
+	DenyActionValue int            `gorm:"column:deny_action_value;type:int"`
+	ExpirationTime  int64          `gorm:"column:expiration_time"` // seconds
+}
+
+func (*EffectivePermission) TableName() string {
+	return "effective_permission"
+}
@@ -0,0 +1,105 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"unicode/utf8"
+
+	abci "github.com/cometbft/cometbft/abci/types"
+
+	"github.com/forbole/juno/v4/common"
+)
+
+// EventOrigin tells which part of a block emitted an event
+type EventOrigin string
+
+const (
+	EventOriginTx         EventOrigin = "tx"
+	EventOriginBeginBlock EventOrigin = "begin_block"
+	EventOriginEndBlock   EventOrigin = "end_block"
+)
+
+// Event contains a raw event emitted by the chain, archived so that it can be replayed later on
+type Event struct {
+	ID uint64 `gorm:"column:id;primaryKey" json:"-"`
+
+	// ChainID tells which chain emitted the event, so that several chains can share the same database
+	ChainID string `gorm:"column:chain_id;type:varchar(64);not null;default:'';index:idx_event_chain_height_origin,priority:1"`
+
+	Height     uint64       `gorm:"column:height;not null;index:idx_event_chain_height_origin,priority:2"`
+	Origin     EventOrigin  `gorm:"column:origin;type:varchar(16);not null;index:idx_event_chain_height_origin,priority:3"`
+	TxHash     *common.Hash `gorm:"column:tx_hash;type:BINARY(32);index:idx_event_tx_hash"` // nil for the events of begin and end block
+	TxIndex    uint32       `gorm:"column:tx_index;not null;default:0"`                     // 0 for the events of begin and end block
+	EventIndex uint32       `gorm:"column:event_index;not null"`
+	Type       string       `gorm:"column:type;type:varchar(256);not null;index:idx_event_type"`
+	Attributes string       `gorm:"column:attributes;type:json;not null"`
+}
+
+func (*Event) TableName() string {
+	return "events"
+}
+
+// eventAttribute is the JSON form of an event attribute.
+// Keys and values are not guaranteed to be valid UTF-8, in which case they are base64 encoded.
+type eventAttribute struct {
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+	Index  bool   `json:"index,omitempty"`
+	Base64 bool   `json:"base64,omitempty"`
+}
+
+// EncodeEventAttributes returns the JSON encoding of the given attributes, which DecodeEventAttributes reverts
+func EncodeEventAttributes(attributes []abci.EventAttribute) (string, error) {
+	encoded := make([]eventAttribute, len(attributes))
+	for i, attr := range attributes {
+		encoded[i] = eventAttribute{Key: attr.Key, Value: attr.Value, Index: attr.Index}
+		if !utf8.ValidString(attr.Key) || !utf8.ValidString(attr.Value) {
+			encoded[i].Key = base64.StdEncoding.EncodeToString([]byte(attr.Key))
+			encoded[i].Value = base64.StdEncoding.EncodeToString([]byte(attr.Value))
+			encoded[i].Base64 = true
+		}
+	}
+
+	bz, err := json.Marshal(encoded)
+	if err != nil {
+		return "", err
+	}
+	return string(bz), nil
+}
+
+// DecodeEventAttributes returns the attributes encoded by EncodeEventAttributes
+func DecodeEventAttributes(data string) ([]abci.EventAttribute, error) {
+	var encoded []eventAttribute
+	if err := json.Unmarshal([]byte(data), &encoded); err != nil {
+		return nil, err
+	}
+
+	attributes := make([]abci.EventAttribute, len(encoded))
+	for i, attr := range encoded {
+		attributes[i] = abci.EventAttribute{Key: attr.Key, Value: attr.Value, Index: attr.Index}
+		if !attr.Base64 {
+			continue
+		}
+
+		key, err := base64.StdEncoding.DecodeString(attr.Key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key of attribute %d: %s", i, err)
+		}
+		value, err := base64.StdEncoding.DecodeString(attr.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value of attribute %d: %s", i, err)
+		}
+		attributes[i].Key, attributes[i].Value = string(key), string(value)
+	}
+	return attributes, nil
+}
+
+// ToABCIEvent returns the event as it was emitted by the chain
+func (e *Event) ToABCIEvent() (abci.Event, error) {
+	attributes, err := DecodeEventAttributes(e.Attributes)
+	if err != nil {
+		return abci.Event{}, err
+	}
+	return abci.Event{Type: e.Type, Attributes: attributes}, nil
+}
@@ -0,0 +1,16 @@
+package models
+
+// FailedBlock records a height the parser failed to process, so that it can be retried later on.
+// Module is the module whose handler failed, empty when none is at fault, like when the node fails.
+type FailedBlock struct {
+	ChainID       string `gorm:"column:chain_id;type:varchar(64);not null;default:'';primaryKey"`
+	Height        uint64 `gorm:"column:height;primaryKey;autoIncrement:false"`
+	Module        string `gorm:"column:module;type:varchar(64);not null;default:''"`
+	Error         string `gorm:"column:error;type:text"`
+	FirstFailedAt int64  `gorm:"column:first_failed_at;type:bigint(64)"`
+	RetryCount    int    `gorm:"column:retry_count;not null;default:0"`
+}
+
+func (*FailedBlock) TableName() string {
+	return "failed_block"
+}
@@ -6,9 +6,9 @@ import (
 
 type LocalVirtualGroup struct {
 	ID                   uint64      `gorm:"column:id;primaryKey"`
-	LocalVirtualGroupId  uint32      `gorm:"column:local_virtual_group_id;index:idx_lvg_id;index:idx_lvg_bucket,priority:1"`
+	LocalVirtualGroupId  uint32      `gorm:"column:local_virtual_group_id;index:idx_lvg_id;uniqueIndex:idx_unique_lvg_bucket,priority:1"`
 	GlobalVirtualGroupId uint32      `gorm:"column:global_virtual_group_id;index:idx_gvg_id"`
-	BucketID             common.Hash `gorm:"column:bucket_id;type:BINARY(32);index:idx_bucket_id;index:idx_lvg_bucket,priority:2"`
+	BucketID             common.Hash `gorm:"column:bucket_id;type:BINARY(32);index:idx_bucket_id;uniqueIndex:idx_unique_lvg_bucket,priority:2"`
 	StoredSize           uint64      `gorm:"column:stored_size"`
 
 	CreateAt     int64       `gorm:"column:create_at"`
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"github.com/cosmos/cosmos-sdk/codec"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+
+	"github.com/forbole/juno/v4/common"
+)
+
+// MessageAddressesParser represents a function that extracts all the
+// involved addresses from a provided message (both accounts and validators)
+type MessageAddressesParser = func(cdc codec.Codec, msg sdk.Msg) ([]string, error)
+
+// Message contains the data of a single message contained inside a transaction
+type Message struct {
+	ID uint64 `gorm:"column:id;primaryKey" json:"-"`
+
+	TxHash   common.Hash `gorm:"column:tx_hash;type:BINARY(32);not null;uniqueIndex:idx_tx_hash_msg_index,priority:1"`
+	MsgIndex uint32      `gorm:"column:msg_index;not null;uniqueIndex:idx_tx_hash_msg_index,priority:2"`
+	Height   uint64      `gorm:"column:height;not null;index:idx_msg_height;index:idx_type_url_height,priority:2"`
+	TypeURL  string      `gorm:"column:type_url;type:varchar(256);not null;index:idx_type_url_height,priority:1"`
+
+	// The columns added after the table was first created are left nullable, so that they can be added to the
+	// rows stored already whatever the database type, while every row written fills them
+	Value             string `gorm:"column:value;type:json"`
+	Signers           string `gorm:"column:signers;type:json"`
+	InvolvedAddresses string `gorm:"column:involved_addresses;type:json"`
+
+	// Compressed tells that Value was too large to be stored as it is: it then holds an empty JSON object, the
+	// zstd compressed JSON value being held by CompressedValue
+	Compressed      bool   `gorm:"column:compressed;not null;default:false"`
+	CompressedValue []byte `gorm:"column:compressed_value" json:"-"`
+}
+
+func (*Message) TableName() string {
+	return "messages"
+}
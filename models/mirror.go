@@ -0,0 +1,11 @@
+package models
+
+const (
+	// MirrorStatusPending is the mirror status of the resources whose mirroring has been requested, and not
+	// acknowledged yet by the destination chain
+	MirrorStatusPending = "MIRROR_STATUS_PENDING"
+	// MirrorStatusSuccess is the mirror status of the resources mirrored to the destination chain
+	MirrorStatusSuccess = "MIRROR_STATUS_SUCCESS"
+	// MirrorStatusFailed is the mirror status of the resources the destination chain failed to mirror
+	MirrorStatusFailed = "MIRROR_STATUS_FAILED"
+)
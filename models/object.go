@@ -7,15 +7,25 @@ import (
 	"github.com/forbole/juno/v4/common"
 )
 
+const (
+	// ObjectDeleteReasonCanceled is the delete reason of the objects whose creation has been canceled before they got
+	// sealed, which the chain deletes without giving them a status of their own: they keep the one they had
+	ObjectDeleteReasonCanceled = "creation canceled"
+	// ObjectDeleteReasonSealRejected is the delete reason of the objects whose seal has been rejected by their storage
+	// provider, which the chain deletes without giving them a status of their own: they keep the one they had
+	ObjectDeleteReasonSealRejected = "seal rejected"
+)
+
 type Object struct {
 	ID uint64 `gorm:"column:id;primaryKey"`
 
+	ChainID    string      `gorm:"column:chain_id;type:varchar(64);not null;default:'';uniqueIndex:idx_chain_object_id,priority:1"`
 	BucketID   common.Hash `gorm:"column:bucket_id;type:BINARY(32);index:idx_bucket_id"`
 	BucketName string      `gorm:"column:bucket_name;type:varchar(64);index:idx_bucket_name_object_name,priority:1"`
-	ObjectID   common.Hash `gorm:"column:object_id;type:BINARY(32);uniqueIndex:idx_object_id"`
+	ObjectID   common.Hash `gorm:"column:object_id;type:BINARY(32);uniqueIndex:idx_chain_object_id,priority:2;index:idx_creator_object_id,priority:2"`
 	ObjectName string      `gorm:"column:object_name;type:varchar(1024);index:idx_bucket_name_object_name,length:512,priority:2"`
 
-	Creator             common.Address `gorm:"column:creator;type:BINARY(20)"`
+	Creator             common.Address `gorm:"column:creator;type:BINARY(20);index:idx_creator_object_id,priority:1"`
 	Owner               common.Address `gorm:"column:owner;type:BINARY(20);index:idx_owner"`
 	LocalVirtualGroupId uint32         `gorm:"column:local_virtual_group_id;index:idx_lvg_id"`
 	Operator            common.Address `gorm:"column:operator;type:BINARY(20)"`
@@ -25,16 +35,22 @@ type Object struct {
 	Status              string         `gorm:"column:status;type:VARCHAR(50)"`
 	RedundancyType      string         `gorm:"column:redundancy_type;type:VARCHAR(50)"`
 	SourceType          string         `gorm:"column:source_type;type:VARCHAR(50)"`
-	CheckSums           pq.ByteaArray  `gorm:"column:checksums;type:text"`
-	DeleteAt            int64          `gorm:"column:delete_at"`
+	CheckSums           pq.ByteaArray  `gorm:"column:checksums;type:text"` // one per redundancy piece, stored as a Postgres bytea array literal
+	DeleteAt            int64          `gorm:"column:delete_at;index:idx_object_delete_at"`
 	DeleteReason        string         `gorm:"column:delete_reason;type:varchar(256);"`
 
+	// MirrorStatus is the status of the mirroring of the object to MirrorDestChainID, empty if never mirrored
+	MirrorStatus      string `gorm:"column:mirror_status;type:varchar(32);not null;default:''"`
+	MirrorDestChainID uint32 `gorm:"column:mirror_dest_chain_id"`
+
 	CreateAt     int64       `gorm:"column:create_at"`
 	CreateTxHash common.Hash `gorm:"column:create_tx_hash;type:BINARY(32);not null"`
 	CreateTime   int64       `gorm:"column:create_time"` // seconds
 	UpdateAt     int64       `gorm:"column:update_at;index:idx_update_at"`
 	UpdateTxHash common.Hash `gorm:"column:update_tx_hash;type:BINARY(32);not null"`
 	SealedTxHash common.Hash `gorm:"column:sealed_tx_hash;type:BINARY(32)"`
+	SealedAt     int64       `gorm:"column:sealed_at"`
+	SealedTime   int64       `gorm:"column:sealed_time"` // seconds
 	UpdateTime   int64       `gorm:"column:update_time"` // seconds
 	Removed      bool        `gorm:"column:removed;default:false"`
 
@@ -44,6 +60,16 @@ type Object struct {
 	ContentUpdatedTime int64          `gorm:"content_updated_time"` // ContentUpdatedTime defines the content updated time, it is related to updated_at in ObjectInfo
 	Updater            common.Address `gorm:"column:updater;type:BINARY(20)"`
 	Version            int64          `gorm:"version"`
+
+	// SourceObjectID is the id of the object this object is a copy of, nil if it isn't a copy
+	SourceObjectID *common.Hash `gorm:"column:source_object_id;type:BINARY(32);index:idx_source_object_id"`
+	// SourceBucketName is the name of the bucket of the object this object is a copy of
+	SourceBucketName string `gorm:"column:source_bucket_name;type:varchar(64)"`
+
+	// QuotaStatementID is the id of the statement whose LimitSize the payload of the object is consumed from
+	QuotaStatementID uint64 `gorm:"column:quota_statement_id"`
+	// StatsCounted tells whether the object is counted in the BucketStats of its bucket
+	StatsCounted bool `gorm:"column:stats_counted;not null;default:false"`
 }
 
 func (*Object) TableName() string {
@@ -16,3 +16,16 @@ type PaymentAccount struct {
 func (*PaymentAccount) TableName() string {
 	return "payment_accounts"
 }
+
+// PaymentAccountRefundable records the refundable flag of a payment account updated at a height
+type PaymentAccountRefundable struct {
+	ID uint64 `gorm:"column:id;primaryKey" json:"-"`
+
+	Addr       common.Address `gorm:"column:addr;type:BINARY(20);not null;uniqueIndex:idx_refundable_addr_height,priority:1"`
+	Refundable bool           `gorm:"column:refundable"`
+	Height     int64          `gorm:"column:height;type:bigint(64);uniqueIndex:idx_refundable_addr_height,priority:2"`
+}
+
+func (*PaymentAccountRefundable) TableName() string {
+	return "payment_account_refundable_history"
+}
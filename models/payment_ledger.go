@@ -0,0 +1,32 @@
+package models
+
+import "github.com/forbole/juno/v4/common"
+
+const (
+	// PaymentLedgerDeposit is the kind of the entries recording a deposit into a stream account
+	PaymentLedgerDeposit = "deposit"
+	// PaymentLedgerWithdraw is the kind of the entries recording a withdrawal out of a stream account
+	PaymentLedgerWithdraw = "withdraw"
+)
+
+// PaymentLedger records the money moved into and out of a stream account by the deposits and the withdrawals.
+// An entry is identified by its event, the entries recorded before the event index was known having it at -1.
+type PaymentLedger struct {
+	ID uint64 `gorm:"column:id;primaryKey" json:"-"`
+
+	// Account is the stream account the money is moved into or out of
+	Account     common.Address `gorm:"column:account;type:BINARY(20);not null;index:idx_ledger_account_height,priority:1"`
+	FromAddress common.Address `gorm:"column:from_address;type:BINARY(20);not null;uniqueIndex:idx_ledger_event,priority:5"`
+	ToAddress   common.Address `gorm:"column:to_address;type:BINARY(20);not null;uniqueIndex:idx_ledger_event,priority:6"`
+	Amount      *common.Big    `gorm:"column:amount"`
+	Kind        string         `gorm:"column:kind;type:varchar(16);not null;uniqueIndex:idx_ledger_event,priority:4"`
+
+	Height     int64       `gorm:"column:height;type:bigint(64);index:idx_ledger_account_height,priority:2;uniqueIndex:idx_ledger_event,priority:2"`
+	TxHash     common.Hash `gorm:"column:tx_hash;type:BINARY(32);uniqueIndex:idx_ledger_event,priority:1"`
+	EventIndex int         `gorm:"column:event_index;not null;default:-1;uniqueIndex:idx_ledger_event,priority:3"`
+	Timestamp  int64       `gorm:"column:timestamp;type:bigint(64)"`
+}
+
+func (*PaymentLedger) TableName() string {
+	return "payment_ledger"
+}
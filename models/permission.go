@@ -10,11 +10,15 @@ type Permission struct {
 	ID              uint64      `gorm:"id;type:bigint(64);primaryKey"`
 	PrincipalType   int32       `gorm:"principal_type;type:int;uniqueIndex:idx_policy,priority:1"`
 	PrincipalValue  string      `gorm:"principal_value;type:varchar(128);uniqueIndex:idx_policy,priority:2"`
-	ResourceType    string      `gorm:"resource_type;type:varchar(64);uniqueIndex:idx_policy,priority:3"`
-	ResourceID      common.Hash `gorm:"resource_id;type:BINARY(32);uniqueIndex:idx_policy,priority:4"`
+	ResourceType    string      `gorm:"resource_type;type:varchar(64);uniqueIndex:idx_policy,priority:3;index:idx_resource,priority:2"`
+	ResourceID      common.Hash `gorm:"resource_id;type:BINARY(32);uniqueIndex:idx_policy,priority:4;index:idx_resource,priority:1"`
 	PolicyID        common.Hash `gorm:"policy_id;type:BINARY(32);index:idx_policy_id"`
 	CreateTimestamp int64       `gorm:"create_timestamp;type:bigint(64)"`
 	UpdateTimestamp int64       `gorm:"update_timestamp;type:bigint(64)"`
+	CreateAt        int64       `gorm:"column:create_at"`
+	CreateTxHash    common.Hash `gorm:"column:create_tx_hash;type:BINARY(32)"`
+	UpdateAt        int64       `gorm:"column:update_at"`
+	UpdateTxHash    common.Hash `gorm:"column:update_tx_hash;type:BINARY(32)"`
 	ExpirationTime  int64       `gorm:"expiration_time;type:bigint(64)"` // seconds
 	Removed         bool        `gorm:"removed;"`
 }
@@ -28,9 +32,15 @@ type Statements struct {
 	PolicyID       common.Hash    `gorm:"policy_id;type:BINARY(32);index:idx_policy_id"`
 	Effect         string         `gorm:"effect;type:varchar(32)"`
 	ActionValue    int            `gorm:"action_value;type:int"`
+	UnknownActions pq.Int32Array  `gorm:"unknown_actions;type:text"` // actions not recorded into ActionValue
 	Resources      pq.StringArray `gorm:"resources;type:text"`
 	ExpirationTime int64          `gorm:"expiration_time;type:bigint(64)"`
 	LimitSize      uint64         `gorm:"limit_size;type:bigint(64)"`
+	ConsumedSize   uint64         `gorm:"consumed_size;type:bigint(64);not null;default:0"` // payload size of the sealed objects created under LimitSize
+	CreateAt       int64          `gorm:"column:create_at"`
+	CreateTxHash   common.Hash    `gorm:"column:create_tx_hash;type:BINARY(32)"`
+	UpdateAt       int64          `gorm:"column:update_at"`
+	UpdateTxHash   common.Hash    `gorm:"column:update_tx_hash;type:BINARY(32)"`
 	Removed        bool           `gorm:"removed;"`
 }
 
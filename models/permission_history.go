@@ -0,0 +1,35 @@
+package models
+
+import (
+	"gorm.io/datatypes"
+
+	"github.com/forbole/juno/v4/common"
+)
+
+const (
+	// PermissionHistoryPut is the operation of the history rows recording a policy put
+	PermissionHistoryPut = "put"
+	// PermissionHistoryDelete is the operation of the history rows recording a policy deleted
+	PermissionHistoryDelete = "delete"
+)
+
+// PermissionHistory records a policy put or deleted, along with its statements at that time. Unlike the
+// permission and statements tables, its rows are never updated, so that the policies of a resource at any
+// height can be told.
+type PermissionHistory struct {
+	ID             uint64         `gorm:"column:id;primaryKey"`
+	Operation      string         `gorm:"column:operation;type:varchar(16);uniqueIndex:idx_history_policy_tx,priority:2"`
+	PolicyID       common.Hash    `gorm:"column:policy_id;type:BINARY(32);uniqueIndex:idx_history_policy_tx,priority:1"`
+	PrincipalType  int32          `gorm:"column:principal_type;type:int"`
+	PrincipalValue string         `gorm:"column:principal_value;type:varchar(128)"`
+	ResourceType   string         `gorm:"column:resource_type;type:varchar(64)"`
+	ResourceID     common.Hash    `gorm:"column:resource_id;type:BINARY(32);index:idx_history_resource_height,priority:1"`
+	Statements     datatypes.JSON `gorm:"column:statements;type:json"`
+	Height         int64          `gorm:"column:height;index:idx_history_resource_height,priority:2"`
+	TxHash         common.Hash    `gorm:"column:tx_hash;type:BINARY(32);uniqueIndex:idx_history_policy_tx,priority:3"`
+	Timestamp      int64          `gorm:"column:timestamp"` // seconds
+}
+
+func (*PermissionHistory) TableName() string {
+	return "permission_history"
+}
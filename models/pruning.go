@@ -0,0 +1,11 @@
+package models
+
+// Pruning keeps track of the last height pruned by the pruning module
+type Pruning struct {
+	OneRowId         bool  `gorm:"one_row_id;not null;default:true;primaryKey"`
+	LastPrunedHeight int64 `gorm:"column:last_pruned_height;type:bigint(64);not null"`
+}
+
+func (*Pruning) TableName() string {
+	return "pruning"
+}
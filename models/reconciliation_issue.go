@@ -0,0 +1,22 @@
+package models
+
+import "github.com/forbole/juno/v4/common"
+
+// ReconciliationIssue records a field of a stream record found diverging from the chain state by a reconciliation
+type ReconciliationIssue struct {
+	ID uint64 `gorm:"column:id;primaryKey" json:"-"`
+
+	Account    common.Address `gorm:"column:account;type:BINARY(20);not null;index:idx_reconciliation_account"`
+	Field      string         `gorm:"column:field;type:varchar(32);not null"`
+	DBValue    string         `gorm:"column:db_value"`
+	ChainValue string         `gorm:"column:chain_value"`
+	// Height is the height the chain state has been queried at
+	Height int64 `gorm:"column:height;type:bigint(64)"`
+	// Repaired tells whether the stream record has been overwritten by the chain state
+	Repaired   bool  `gorm:"column:repaired"`
+	CreateTime int64 `gorm:"column:create_time;type:bigint(64);index:idx_reconciliation_time"`
+}
+
+func (*ReconciliationIssue) TableName() string {
+	return "reconciliation_issues"
+}
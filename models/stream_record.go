@@ -17,6 +17,10 @@ type StreamRecord struct {
 	SettleTimestamp   int64          `gorm:"column:settle_timestamp"`
 	OutFlowCount      uint64         `gorm:"column:out_flow_count"`
 	FrozenNetflowRate *common.Big    `gorm:"column:frozen_netflow_rate"`
+
+	// OutFlowsHeight is the height the out flows of the account were last read at, which the updates of the stream
+	// record leave as it is
+	OutFlowsHeight int64 `gorm:"column:out_flows_height;not null;default:0"`
 }
 
 func (*StreamRecord) TableName() string {
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"github.com/forbole/juno/v4/common"
+)
+
+// StreamRecordHistory records every update of a stream record, which StreamRecord only holds the latest state of
+type StreamRecordHistory struct {
+	ID uint64 `gorm:"column:id;primaryKey" json:"-"`
+
+	Account           common.Address `gorm:"column:account;type:BINARY(20);index:idx_stream_history_account_time,priority:1;uniqueIndex:idx_stream_history_update,priority:1"`
+	CrudTimestamp     int64          `gorm:"column:crud_timestamp;index:idx_stream_history_account_time,priority:2;index:idx_stream_history_time;uniqueIndex:idx_stream_history_update,priority:4"`
+	NetflowRate       *common.Big    `gorm:"column:netflow_rate"`
+	FrozenNetflowRate *common.Big    `gorm:"column:frozen_netflow_rate"`
+	StaticBalance     *common.Big    `gorm:"column:static_balance"`
+	BufferBalance     *common.Big    `gorm:"column:buffer_balance"`
+	LockBalance       *common.Big    `gorm:"column:lock_balance"`
+	Status            string         `gorm:"column:status"`
+	SettleTimestamp   int64          `gorm:"column:settle_timestamp"`
+	Height            int64          `gorm:"column:height;uniqueIndex:idx_stream_history_update,priority:2"`
+	TxHash            common.Hash    `gorm:"column:tx_hash;type:BINARY(32);uniqueIndex:idx_stream_history_update,priority:3"`
+}
+
+func (*StreamRecordHistory) TableName() string {
+	return "stream_record_history"
+}
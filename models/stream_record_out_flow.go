@@ -0,0 +1,21 @@
+package models
+
+import (
+	"github.com/forbole/juno/v4/common"
+)
+
+// StreamRecordOutFlow is a flow going out of the stream record of an account to a receiver, usually a storage
+// provider. The out flows of an account are replaced as a whole on every update.
+type StreamRecordOutFlow struct {
+	ID uint64 `gorm:"column:id;primaryKey" json:"-"`
+
+	Account   common.Address `gorm:"column:account;type:BINARY(20);uniqueIndex:idx_out_flow_account_to,priority:1"`
+	ToAddress common.Address `gorm:"column:to_address;type:BINARY(20);uniqueIndex:idx_out_flow_account_to,priority:2;index:idx_out_flow_to"`
+	Rate      *common.Big    `gorm:"column:rate"`
+	Status    string         `gorm:"column:status;type:varchar(32);uniqueIndex:idx_out_flow_account_to,priority:3"`
+	Height    int64          `gorm:"column:height"`
+}
+
+func (*StreamRecordOutFlow) TableName() string {
+	return "stream_record_out_flows"
+}
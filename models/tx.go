@@ -2,6 +2,9 @@ package models
 
 import (
 	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
 	"time"
 
 	abci "github.com/cometbft/cometbft/abci/types"
@@ -14,11 +17,25 @@ import (
 type Tx struct {
 	ID uint64 `gorm:"column:id;primaryKey" json:"-"`
 
-	Hash    common.Hash `gorm:"column:hash;type:BINARY(32);not null;uniqueIndex:idx_hash"`
-	Height  uint64      `gorm:"column:height;not null;uniqueIndex:idx_height_tx_index,priority:1"`
-	TxIndex uint32      `gorm:"column:tx_index;not null;uniqueIndex:idx_height_tx_index,priority:2"`
+	// The rows are keyed by their position, Hash not being unique: a tx saved again at another position is
+	// replaced by the one there once the whole block gets saved again
+	ChainID string      `gorm:"column:chain_id;type:varchar(64);not null;default:'';uniqueIndex:idx_chain_height_tx_index,priority:1"`
+	Hash    common.Hash `gorm:"column:hash;type:BINARY(32);not null;index:idx_tx_hash,priority:1"`
+	Height  uint64      `gorm:"column:height;not null;uniqueIndex:idx_chain_height_tx_index,priority:2;index:idx_tx_hash,priority:2"`
+	TxIndex uint32      `gorm:"column:tx_index;not null;uniqueIndex:idx_chain_height_tx_index,priority:3"`
+
+	Success   bool   `gorm:"column:success"`
+	Code      uint32 `gorm:"column:code;not null;default:0"`
+	Codespace string `gorm:"column:codespace;type:VARCHAR(256);not null;default:''"`
+	MsgCount  uint32 `gorm:"column:msg_count;not null;default:0"`
+
+	// Compressed tells that Messages and Logs were too large to be stored as they are: each of them then
+	// holds an empty JSON array, their zstd compressed JSON arrays being held by CompressedMessages and
+	// CompressedLogs
+	Compressed         bool   `gorm:"column:compressed;not null;default:false"`
+	CompressedMessages []byte `gorm:"column:compressed_messages" json:"-"`
+	CompressedLogs     []byte `gorm:"column:compressed_logs" json:"-"`
 
-	Success     bool   `gorm:"column:success"`
 	Messages    string `gorm:"column:messages;type:json;not null;default:(JSON_ARRAY())"`
 	Memo        string `gorm:"column:memo"`
 	Signatures  string `gorm:"column:signatures"`
@@ -43,11 +60,12 @@ func (t *Tx) ToTmTx() *ResultTx {
 		GasWanted: int64(t.GasWanted),
 		GasUsed:   int64(t.GasUsed),
 		Messages:  t.Messages,
+		Code:      t.Code,
+		Codespace: t.Codespace,
 	}
 
-	if t.Success {
-		txResult.Code = 0
-	} else {
+	// Rows stored before the code was recorded only tell whether the tx failed
+	if !t.Success && txResult.Code == 0 {
 		txResult.Code = 1
 	}
 
@@ -62,6 +80,93 @@ func (t *Tx) ToTmTx() *ResultTx {
 	}
 }
 
+// DecodeSignatures returns the base64 encoded signatures of the tx.
+// Rows written before the column held a JSON array store them joined with commas.
+func (t *Tx) DecodeSignatures() ([]string, error) {
+	value := strings.TrimSpace(t.Signatures)
+	if value == "" {
+		return []string{}, nil
+	}
+	if !strings.HasPrefix(value, "[") {
+		return strings.Split(value, ","), nil
+	}
+
+	var sigs []string
+	if err := json.Unmarshal([]byte(value), &sigs); err != nil {
+		return nil, fmt.Errorf("invalid signatures of tx %s: %w", t.Hash.Hex(), err)
+	}
+	return sigs, nil
+}
+
+// DecodeMessages returns the JSON encoded messages of the tx
+func (t *Tx) DecodeMessages() ([]json.RawMessage, error) {
+	msgs, err := decodeJSONArray(t.Messages)
+	if err != nil {
+		return nil, fmt.Errorf("invalid messages of tx %s: %w", t.Hash.Hex(), err)
+	}
+	return msgs, nil
+}
+
+// DecodeSignerInfos returns the JSON encoded signer infos of the tx
+func (t *Tx) DecodeSignerInfos() ([]json.RawMessage, error) {
+	infos, err := decodeJSONArray(t.SignerInfos)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signer infos of tx %s: %w", t.Hash.Hex(), err)
+	}
+	return infos, nil
+}
+
+// DecodeLogs returns the JSON encoded message logs of the tx
+func (t *Tx) DecodeLogs() ([]json.RawMessage, error) {
+	logs, err := decodeJSONArray(t.Logs)
+	if err != nil {
+		return nil, fmt.Errorf("invalid logs of tx %s: %w", t.Hash.Hex(), err)
+	}
+	return logs, nil
+}
+
+// decodeJSONArray decodes a JSON array column. Older rows were built by joining the elements
+// with commas, so an element that encoded to nothing left an empty slot, which is returned as null.
+func decodeJSONArray(value string) ([]json.RawMessage, error) {
+	value = strings.TrimSpace(value)
+	if value == "" || value == "null" {
+		return []json.RawMessage{}, nil
+	}
+
+	var elements []json.RawMessage
+	if err := json.Unmarshal([]byte(value), &elements); err == nil {
+		return elements, nil
+	}
+
+	if !strings.HasPrefix(value, "[") || !strings.HasSuffix(value, "]") {
+		return nil, fmt.Errorf("not a JSON array: %.32q", value)
+	}
+	rest := strings.TrimSpace(value[1 : len(value)-1])
+	elements = make([]json.RawMessage, 0)
+	for {
+		if rest == "" || strings.HasPrefix(rest, ",") {
+			elements = append(elements, json.RawMessage("null"))
+		} else {
+			decoder := json.NewDecoder(strings.NewReader(rest))
+			var element json.RawMessage
+			if err := decoder.Decode(&element); err != nil {
+				return nil, err
+			}
+			elements = append(elements, element)
+			rest = strings.TrimSpace(rest[decoder.InputOffset():])
+		}
+
+		if rest == "" {
+			break
+		}
+		if !strings.HasPrefix(rest, ",") {
+			return nil, fmt.Errorf("unexpected %.32q after array element", rest)
+		}
+		rest = strings.TrimSpace(rest[1:])
+	}
+	return elements, nil
+}
+
 type ResponseDeliverTx struct {
 	Code      uint32       `protobuf:"varint,1,opt,name=code,proto3" json:"code"`
 	Data      []byte       `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
@@ -49,6 +49,7 @@ type Validator struct {
 
 	ConsensusAddress common.Address `gorm:"column:consensus_address;type:binary(20);not null;uniqueIndex:idx_address"`
 	ConsensusPubkey  Pubkey         `gorm:"column:consensus_pubkey;type:binary(64);not null;uniqueIndex:idx_pubkey"`
+	FirstSeenHeight  uint64         `gorm:"column:first_seen_height;not null"`
 }
 
 func (*Validator) TableName() string {
@@ -147,9 +148,10 @@ func (*ValidatorSigningInfo) TableName() string {
 	return "validator_signing_infos"
 }
 
-func NewValidator(ConsensusAddress common.Address, ConsensusPubkey Pubkey) *Validator {
+func NewValidator(ConsensusAddress common.Address, ConsensusPubkey Pubkey, FirstSeenHeight uint64) *Validator {
 	return &Validator{
 		ConsensusAddress: ConsensusAddress,
 		ConsensusPubkey:  ConsensusPubkey,
+		FirstSeenHeight:  FirstSeenHeight,
 	}
 }
@@ -43,11 +43,19 @@ func (m *Module) PrepareTables() error {
 		&models.AverageBlockTimePerMinute{},
 
 		&models.Epoch{},
+		&models.CommitSig{},
 
 		&models.Tx{},
+		&models.Message{},
+		&models.Event{},
+		&models.BlockResult{},
+
+		&models.FailedBlock{},
+		&models.BlockProgress{},
 	})
 }
 
+// AutoMigrate implements modules.PrepareTablesModule
 func (m *Module) AutoMigrate() error {
-	return nil
+	return m.db.AutoMigrate(context.TODO(), []schema.Tabler{&models.Tx{}})
 }
@@ -3,6 +3,7 @@ package bucket
 import (
 	"context"
 	"errors"
+	"fmt"
 
 	abci "github.com/cometbft/cometbft/abci/types"
 	tmctypes "github.com/cometbft/cometbft/rpc/core/types"
@@ -11,6 +12,7 @@ import (
 	storagetypes "github.com/evmos/evmos/v12/x/storage/types"
 
 	"github.com/forbole/juno/v4/common"
+	"github.com/forbole/juno/v4/database"
 	"github.com/forbole/juno/v4/log"
 	"github.com/forbole/juno/v4/models"
 )
@@ -21,6 +23,8 @@ var (
 	EventUpdateBucketInfo        = proto.MessageName(&storagetypes.EventUpdateBucketInfo{})
 	EventDiscontinueBucket       = proto.MessageName(&storagetypes.EventDiscontinueBucket{})
 	EventCompleteMigrationBucket = proto.MessageName(&storagetypes.EventCompleteMigrationBucket{})
+	EventMirrorBucket            = proto.MessageName(&storagetypes.EventMirrorBucket{})
+	EventMirrorBucketResult      = proto.MessageName(&storagetypes.EventMirrorBucketResult{})
 )
 
 var BucketEvents = map[string]bool{
@@ -29,6 +33,8 @@ var BucketEvents = map[string]bool{
 	EventUpdateBucketInfo:        true,
 	EventDiscontinueBucket:       true,
 	EventCompleteMigrationBucket: true,
+	EventMirrorBucket:            true,
+	EventMirrorBucketResult:      true,
 }
 
 func (m *Module) ExtractEventStatements(ctx context.Context, block *tmctypes.ResultBlock, txHash common.Hash, event sdk.Event) (map[string][]interface{}, error) {
@@ -82,19 +88,42 @@ func (m *Module) HandleEvent(ctx context.Context, block *tmctypes.ResultBlock, t
 			return errors.New("complete migrate bucket event assert error")
 		}
 		return m.handleCompleteMigrationBucket(ctx, block, txHash, completeMigrationBucket)
+	case EventMirrorBucket:
+		mirrorBucket, ok := typedEvent.(*storagetypes.EventMirrorBucket)
+		if !ok {
+			log.Errorw("type assert error", "type", "EventMirrorBucket", "event", typedEvent)
+			return errors.New("mirror bucket event assert error")
+		}
+		return m.handleMirrorBucket(ctx, block, txHash, mirrorBucket)
+	case EventMirrorBucketResult:
+		mirrorBucketResult, ok := typedEvent.(*storagetypes.EventMirrorBucketResult)
+		if !ok {
+			log.Errorw("type assert error", "type", "EventMirrorBucketResult", "event", typedEvent)
+			return errors.New("mirror bucket result event assert error")
+		}
+		return m.handleMirrorBucketResult(ctx, block, txHash, mirrorBucketResult)
 	}
 
 	return nil
 }
 
 func (m *Module) handleCreateBucket(ctx context.Context, block *tmctypes.ResultBlock, txHash common.Hash, createBucket *storagetypes.EventCreateBucket) error {
+	owner, err := common.ParseAddressOrZero(createBucket.Owner)
+	if err != nil {
+		return fmt.Errorf("bucket owner: %w", err)
+	}
+	paymentAddress, err := common.ParseAddressOrZero(createBucket.PaymentAddress)
+	if err != nil {
+		return fmt.Errorf("bucket payment address: %w", err)
+	}
+
 	bucket := &models.Bucket{
 		BucketID:                   common.BigToHash(createBucket.BucketId.BigInt()),
 		BucketName:                 createBucket.BucketName,
-		Owner:                      common.HexToAddress(createBucket.Owner),
-		PaymentAddress:             common.HexToAddress(createBucket.PaymentAddress),
+		Owner:                      owner,
+		PaymentAddress:             paymentAddress,
 		GlobalVirtualGroupFamilyId: createBucket.GlobalVirtualGroupFamilyId,
-		Operator:                   common.HexToAddress(createBucket.Owner),
+		Operator:                   owner,
 		SourceType:                 createBucket.SourceType.String(),
 		ChargedReadQuota:           createBucket.ChargedReadQuota,
 		Visibility:                 createBucket.Visibility.String(),
@@ -125,7 +154,8 @@ func (m *Module) handleDeleteBucket(ctx context.Context, block *tmctypes.ResultB
 		UpdateTime:   block.Block.Time.UTC().Unix(),
 	}
 
-	return m.db.UpdateBucket(ctx, bucket)
+	// The objects and the local virtual groups of the bucket are handled by their own modules
+	return m.updateBucket(ctx, block, bucket, "removed", "update_at", "update_tx_hash", "update_time")
 }
 
 func (m *Module) handleDiscontinueBucket(ctx context.Context, block *tmctypes.ResultBlock, txHash common.Hash, discontinueBucket *storagetypes.EventDiscontinueBucket) error {
@@ -141,7 +171,7 @@ func (m *Module) handleDiscontinueBucket(ctx context.Context, block *tmctypes.Re
 		UpdateTime:   block.Block.Time.UTC().Unix(),
 	}
 
-	return m.db.UpdateBucket(ctx, bucket)
+	return m.updateBucket(ctx, block, bucket, "delete_reason", "delete_at", "status", "update_at", "update_tx_hash", "update_time")
 }
 
 func (m *Module) handleUpdateBucketInfo(ctx context.Context, block *tmctypes.ResultBlock, txHash common.Hash, updateBucket *storagetypes.EventUpdateBucketInfo) error {
@@ -158,7 +188,30 @@ func (m *Module) handleUpdateBucketInfo(ctx context.Context, block *tmctypes.Res
 		UpdateTime:   block.Block.Time.UTC().Unix(),
 	}
 
-	return m.db.UpdateBucket(ctx, bucket)
+	// The previous quota and payment address are recorded along with the update, when they change. The stored ones
+	// are the previous ones unless other changes got recorded, the history then taking them from those, so that the
+	// updates handled out of order, stale ones included, are recorded as they were made.
+	return database.WithTx(ctx, m.db, func(tx database.Database) error {
+		stored, err := tx.GetBucketByIDWithRemoved(ctx, bucket.BucketID)
+		if err != nil && !errors.Is(err, database.ErrBucketNotFound) {
+			return err
+		}
+
+		_, err = updateBucketIn(ctx, tx, block, bucket, "charged_read_quota", "payment_address", "visibility", "global_virtual_group_family_id", "update_at", "update_tx_hash", "update_time")
+		if err != nil || stored == nil {
+			return err
+		}
+
+		return tx.SaveBucketQuotaHistory(ctx, &models.BucketQuotaHistory{
+			BucketID:            bucket.BucketID,
+			OldChargedReadQuota: stored.ChargedReadQuota,
+			NewChargedReadQuota: bucket.ChargedReadQuota,
+			OldPaymentAddress:   stored.PaymentAddress,
+			NewPaymentAddress:   bucket.PaymentAddress,
+			Height:              block.Block.Height,
+			TxHash:              txHash,
+		})
+	})
 }
 
 func (m *Module) handleCompleteMigrationBucket(ctx context.Context, block *tmctypes.ResultBlock, txHash common.Hash, completeMigrationBucket *storagetypes.EventCompleteMigrationBucket) error {
@@ -172,5 +225,70 @@ func (m *Module) handleCompleteMigrationBucket(ctx context.Context, block *tmcty
 		UpdateTime:   block.Block.Time.UTC().Unix(),
 	}
 
-	return m.db.UpdateBucket(ctx, bucket)
+	return m.updateBucket(ctx, block, bucket, "global_virtual_group_family_id", "update_at", "update_tx_hash", "update_time")
+}
+
+func (m *Module) handleMirrorBucket(ctx context.Context, block *tmctypes.ResultBlock, txHash common.Hash, mirrorBucket *storagetypes.EventMirrorBucket) error {
+	bucket := &models.Bucket{
+		BucketID:          common.BigToHash(mirrorBucket.BucketId.BigInt()),
+		BucketName:        mirrorBucket.BucketName,
+		MirrorStatus:      models.MirrorStatusPending,
+		MirrorDestChainID: mirrorBucket.DestChainId,
+
+		UpdateAt:     block.Block.Height,
+		UpdateTxHash: txHash,
+		UpdateTime:   block.Block.Time.UTC().Unix(),
+	}
+
+	return m.updateBucket(ctx, block, bucket, "mirror_status", "mirror_dest_chain_id", "update_at", "update_tx_hash", "update_time")
+}
+
+// handleMirrorBucketResult records the acknowledgement of the mirroring of a bucket by the destination chain, which
+// may come many blocks after the request, once the bucket has been deleted already
+func (m *Module) handleMirrorBucketResult(ctx context.Context, block *tmctypes.ResultBlock, txHash common.Hash, mirrorBucketResult *storagetypes.EventMirrorBucketResult) error {
+	bucketID := common.BigToHash(mirrorBucketResult.BucketId.BigInt())
+	if _, err := m.db.GetBucketByID(ctx, bucketID); err != nil {
+		if errors.Is(err, database.ErrBucketNotFound) {
+			log.Infow("skipping mirror result of deleted bucket", "bucket_id", bucketID, "height", block.Block.Height)
+			return nil
+		}
+		return err
+	}
+
+	mirrorStatus := models.MirrorStatusFailed
+	if mirrorBucketResult.Status == storagetypes.StatusSuccess {
+		mirrorStatus = models.MirrorStatusSuccess
+	}
+
+	bucket := &models.Bucket{
+		BucketID:          bucketID,
+		BucketName:        mirrorBucketResult.BucketName,
+		MirrorStatus:      mirrorStatus,
+		MirrorDestChainID: mirrorBucketResult.DestChainId,
+
+		UpdateAt:     block.Block.Height,
+		UpdateTxHash: txHash,
+		UpdateTime:   block.Block.Time.UTC().Unix(),
+	}
+
+	return m.updateBucket(ctx, block, bucket, "mirror_status", "mirror_dest_chain_id", "update_at", "update_tx_hash", "update_time")
+}
+
+// updateBucket writes the given columns of the bucket, unless a later block has updated it already
+func (m *Module) updateBucket(ctx context.Context, block *tmctypes.ResultBlock, bucket *models.Bucket, columns ...string) error {
+	_, err := updateBucketIn(ctx, m.db, block, bucket, columns...)
+	return err
+}
+
+// updateBucketIn behaves like updateBucket, writing into the given database, and tells whether the bucket
+// has been written
+func updateBucketIn(ctx context.Context, db database.Database, block *tmctypes.ResultBlock, bucket *models.Bucket, columns ...string) (bool, error) {
+	updated, err := db.UpdateBucketAt(ctx, block.Block.Height, bucket, columns...)
+	if err != nil {
+		return false, err
+	}
+	if updated == 0 {
+		log.Debugw("skipping stale bucket update", "bucket_id", bucket.BucketID, "height", block.Block.Height)
+	}
+	return updated > 0, nil
 }
@@ -0,0 +1,192 @@
+package bucket
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	sdkmath "cosmossdk.io/math"
+	tmctypes "github.com/cometbft/cometbft/rpc/core/types"
+	tmtypes "github.com/cometbft/cometbft/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/gogoproto/proto"
+	storagetypes "github.com/evmos/evmos/v12/x/storage/types"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm/schema"
+
+	"github.com/forbole/juno/v4/common"
+	"github.com/forbole/juno/v4/database"
+	"github.com/forbole/juno/v4/database/sqlite/sqlitetest"
+	"github.com/forbole/juno/v4/models"
+)
+
+func newTestModule(t *testing.T) (*Module, *database.Impl) {
+	t.Helper()
+
+	db := sqlitetest.NewDatabase(t, &models.Bucket{})
+	return NewModule(db), db
+}
+
+func newTestBlock(height int64) *tmctypes.ResultBlock {
+	return &tmctypes.ResultBlock{
+		Block: &tmtypes.Block{Header: tmtypes.Header{Height: height, Time: time.Unix(1700000000+height, 0)}},
+	}
+}
+
+// handleEvent makes m handle the given event, emitted at height by the tx whose hash is the height
+func handleEvent(t *testing.T, m *Module, height int64, event proto.Message) {
+	t.Helper()
+
+	sdkEvent, err := sdk.TypedEventToEvent(event)
+	require.NoError(t, err)
+	require.NoError(t, m.HandleEvent(context.Background(), newTestBlock(height), common.BigToHash(sdkmath.NewInt(height).BigInt()), sdkEvent))
+}
+
+func TestMirrorBucket(t *testing.T) {
+	ctx := context.Background()
+	m, db := newTestModule(t)
+
+	bucketID := func(id uint64) common.Hash { return common.BigToHash(sdkmath.NewUint(id).BigInt()) }
+
+	for _, id := range []uint64{1, 2, 3} {
+		require.NoError(t, db.SaveBucket(ctx, &models.Bucket{BucketID: bucketID(id), BucketName: "bucket-" + sdkmath.NewUint(id).String(), UpdateAt: 10}))
+		handleEvent(t, m, 11, &storagetypes.EventMirrorBucket{BucketId: sdkmath.NewUint(id), DestChainId: 97})
+		bucket, err := db.GetBucketByID(ctx, bucketID(id))
+		require.NoError(t, err)
+		require.Equal(t, models.MirrorStatusPending, bucket.MirrorStatus)
+		require.Equal(t, uint32(97), bucket.MirrorDestChainID)
+	}
+
+	// Request then success
+	handleEvent(t, m, 20, &storagetypes.EventMirrorBucketResult{BucketId: sdkmath.NewUint(1), Status: storagetypes.StatusSuccess, DestChainId: 97})
+	bucket, err := db.GetBucketByID(ctx, bucketID(1))
+	require.NoError(t, err)
+	require.Equal(t, models.MirrorStatusSuccess, bucket.MirrorStatus)
+	require.Equal(t, int64(20), bucket.UpdateAt)
+
+	// Request then failure
+	handleEvent(t, m, 20, &storagetypes.EventMirrorBucketResult{BucketId: sdkmath.NewUint(2), Status: storagetypes.StatusFail, DestChainId: 97})
+	bucket, err = db.GetBucketByID(ctx, bucketID(2))
+	require.NoError(t, err)
+	require.Equal(t, models.MirrorStatusFailed, bucket.MirrorStatus)
+
+	// The result for a bucket deleted in between is skipped
+	handleEvent(t, m, 15, &storagetypes.EventDeleteBucket{BucketId: sdkmath.NewUint(3), BucketName: "bucket-3"})
+	handleEvent(t, m, 20, &storagetypes.EventMirrorBucketResult{BucketId: sdkmath.NewUint(3), Status: storagetypes.StatusSuccess, DestChainId: 97})
+	bucket, err = db.GetBucketByIDWithRemoved(ctx, bucketID(3))
+	require.NoError(t, err)
+	require.True(t, bucket.Removed)
+	require.Equal(t, models.MirrorStatusPending, bucket.MirrorStatus)
+	require.Equal(t, int64(15), bucket.UpdateAt)
+}
+
+func TestDiscontinueBucket(t *testing.T) {
+	ctx := context.Background()
+	m, db := newTestModule(t)
+
+	bucketID := common.BigToHash(sdkmath.NewUint(1).BigInt())
+	require.NoError(t, db.SaveBucket(ctx, &models.Bucket{
+		BucketID: bucketID, BucketName: "bucket", Status: storagetypes.BUCKET_STATUS_CREATED.String(), UpdateAt: 10,
+	}))
+
+	handleEvent(t, m, 11, &storagetypes.EventDiscontinueBucket{BucketId: sdkmath.NewUint(1), BucketName: "bucket", Reason: "illegal", DeleteAt: 2000})
+
+	bucket, err := db.GetBucketByID(ctx, bucketID)
+	require.NoError(t, err)
+	require.Equal(t, storagetypes.BUCKET_STATUS_DISCONTINUED.String(), bucket.Status)
+	require.Equal(t, int64(2000), bucket.DeleteAt)
+	require.Equal(t, "illegal", bucket.DeleteReason)
+	require.Equal(t, int64(11), bucket.UpdateAt)
+}
+
+func TestBucketQuotaHistory(t *testing.T) {
+	ctx := context.Background()
+	m, db := newTestModule(t)
+	require.NoError(t, db.PrepareTables(ctx, []schema.Tabler{&models.BucketQuotaHistory{}}))
+
+	bucketID := common.BigToHash(sdkmath.NewUint(1).BigInt())
+	payer, other := common.HexToAddress("0x01"), common.HexToAddress("0x02")
+	require.NoError(t, db.SaveBucket(ctx, &models.Bucket{BucketID: bucketID, BucketName: "bucket", PaymentAddress: payer, ChargedReadQuota: 100, UpdateAt: 10}))
+
+	update := func(height int64, quota uint64, paymentAddress common.Address) {
+		handleEvent(t, m, height, &storagetypes.EventUpdateBucketInfo{
+			BucketId: sdkmath.NewUint(1), BucketName: "bucket", ChargedReadQuota: quota, PaymentAddress: paymentAddress.String(),
+		})
+	}
+
+	update(11, 200, payer)
+	// Reprocessing the update doesn't record it twice
+	update(11, 200, payer)
+	// Neither does an update leaving the quota and the payment address untouched
+	update(12, 200, payer)
+	update(13, 200, other)
+
+	history, err := db.ListBucketQuotaHistory(ctx, bucketID, 10, 0)
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	require.Equal(t, int64(13), history[0].Height)
+	require.Equal(t, uint64(200), history[0].OldChargedReadQuota)
+	require.Equal(t, uint64(200), history[0].NewChargedReadQuota)
+	require.Equal(t, payer, history[0].OldPaymentAddress)
+	require.Equal(t, other, history[0].NewPaymentAddress)
+	require.Equal(t, int64(11), history[1].Height)
+	require.Equal(t, uint64(100), history[1].OldChargedReadQuota)
+	require.Equal(t, uint64(200), history[1].NewChargedReadQuota)
+	require.Equal(t, common.BigToHash(sdkmath.NewInt(11).BigInt()), history[1].TxHash)
+
+	history, err = db.ListBucketQuotaHistory(ctx, bucketID, 1, 1)
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	require.Equal(t, int64(11), history[0].Height)
+
+	bucket, err := db.GetBucketByID(ctx, bucketID)
+	require.NoError(t, err)
+	require.Equal(t, uint64(200), bucket.ChargedReadQuota)
+	require.Equal(t, other, bucket.PaymentAddress)
+
+	// The updates handled out of order are recorded as they were made
+	update(15, 400, other)
+	update(14, 300, other)
+	// Including the one setting the values the following one had been recorded setting
+	update(17, 500, payer)
+	update(16, 500, payer)
+	history, err = db.ListBucketQuotaHistory(ctx, bucketID, 10, 0)
+	require.NoError(t, err)
+	changes := make([][3]uint64, len(history))
+	for i, change := range history {
+		changes[i] = [3]uint64{uint64(change.Height), change.OldChargedReadQuota, change.NewChargedReadQuota}
+	}
+	require.Equal(t, [][3]uint64{{16, 400, 500}, {15, 300, 400}, {14, 200, 300}, {13, 200, 200}, {11, 100, 200}}, changes)
+	require.Equal(t, other, history[0].OldPaymentAddress)
+	require.Equal(t, payer, history[0].NewPaymentAddress)
+
+	bucket, err = db.GetBucketByID(ctx, bucketID)
+	require.NoError(t, err)
+	require.Equal(t, uint64(500), bucket.ChargedReadQuota)
+}
+
+func TestDeleteBucket(t *testing.T) {
+	ctx := context.Background()
+	m, db := newTestModule(t)
+
+	bucketID := func(id uint64) common.Hash { return common.BigToHash(sdkmath.NewUint(id).BigInt()) }
+	deleteBucket := func(height int64, id uint64) {
+		handleEvent(t, m, height, &storagetypes.EventDeleteBucket{BucketId: sdkmath.NewUint(id), BucketName: "bucket"})
+	}
+
+	require.NoError(t, db.SaveBucket(ctx, &models.Bucket{BucketID: bucketID(1), BucketName: "1", UpdateAt: 10}))
+	deleteBucket(20, 1)
+	_, err := db.GetBucketByID(ctx, bucketID(1))
+	require.ErrorIs(t, err, database.ErrBucketNotFound)
+
+	// Handling the deletion again
+	deleteBucket(20, 1)
+	_, err = db.GetBucketByID(ctx, bucketID(1))
+	require.ErrorIs(t, err, database.ErrBucketNotFound)
+
+	// A stale deletion leaves the bucket untouched
+	require.NoError(t, db.SaveBucket(ctx, &models.Bucket{BucketID: bucketID(3), BucketName: "3", UpdateAt: 50}))
+	deleteBucket(40, 3)
+	_, err = db.GetBucketByID(ctx, bucketID(3))
+	require.NoError(t, err)
+}
@@ -2,11 +2,14 @@ package epoch
 
 import (
 	"context"
+
+	"github.com/forbole/juno/v4/database"
 	"github.com/forbole/juno/v4/log"
 )
 
 func (m *Module) IsProcessed(height uint64) (bool, error) {
-	ep, err := m.db.GetEpoch(context.Background())
+	// The replicas may lag behind, making an already processed height look new
+	ep, err := m.db.GetEpoch(database.ReadFromPrimary(context.Background()))
 	if err != nil {
 		return false, err
 	}
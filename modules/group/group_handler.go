@@ -20,6 +20,7 @@ var (
 	EventDeleteGroup       = proto.MessageName(&storagetypes.EventDeleteGroup{})
 	EventLeaveGroup        = proto.MessageName(&storagetypes.EventLeaveGroup{})
 	EventUpdateGroupMember = proto.MessageName(&storagetypes.EventUpdateGroupMember{})
+	EventRenewGroupMember  = proto.MessageName(&storagetypes.EventRenewGroupMember{})
 )
 
 var GroupEvents = map[string]bool{
@@ -27,6 +28,7 @@ var GroupEvents = map[string]bool{
 	EventDeleteGroup:       true,
 	EventLeaveGroup:        true,
 	EventUpdateGroupMember: true,
+	EventRenewGroupMember:  true,
 }
 
 func (m *Module) ExtractEventStatements(ctx context.Context, block *tmctypes.ResultBlock, txHash common.Hash, event sdk.Event) (map[string][]interface{}, error) {
@@ -59,6 +61,13 @@ func (m *Module) HandleEvent(ctx context.Context, block *tmctypes.ResultBlock, _
 			return errors.New("update group member event assert error")
 		}
 		return m.handleUpdateGroupMember(ctx, block, updateGroupMember)
+	case EventRenewGroupMember:
+		renewGroupMember, ok := typedEvent.(*storagetypes.EventRenewGroupMember)
+		if !ok {
+			log.Errorw("type assert error", "type", "EventRenewGroupMember", "event", typedEvent)
+			return errors.New("renew group member event assert error")
+		}
+		return m.handleRenewGroupMember(ctx, block, renewGroupMember)
 
 	case EventDeleteGroup:
 		deleteGroup, ok := typedEvent.(*storagetypes.EventDeleteGroup)
@@ -103,42 +112,10 @@ func (m *Module) handleCreateGroup(ctx context.Context, block *tmctypes.ResultBl
 }
 
 func (m *Module) handleDeleteGroup(ctx context.Context, block *tmctypes.ResultBlock, deleteGroup *storagetypes.EventDeleteGroup) error {
-	group := &models.Group{
-		Owner:     common.HexToAddress(deleteGroup.Owner),
-		GroupID:   common.BigToHash(deleteGroup.GroupId.BigInt()),
-		GroupName: deleteGroup.GroupName,
-
-		UpdateAt:   block.Block.Height,
-		UpdateTime: block.Block.Time.UTC().Unix(),
-		Removed:    true,
-	}
-
-	//update group item
-	groupItem := &models.Group{
-		GroupID:   common.BigToHash(deleteGroup.GroupId.BigInt()),
-		AccountID: common.HexToAddress("0"),
-
-		UpdateAt:   block.Block.Height,
-		UpdateTime: block.Block.Time.UTC().Unix(),
-		Removed:    true,
-	}
-	m.db.UpdateGroup(ctx, groupItem)
-
-	return m.db.DeleteGroup(ctx, group)
+	return m.db.DeleteGroup(ctx, common.BigToHash(deleteGroup.GroupId.BigInt()), block.Block.Height, block.Block.Time.UTC().Unix())
 }
 
 func (m *Module) handleLeaveGroup(ctx context.Context, block *tmctypes.ResultBlock, leaveGroup *storagetypes.EventLeaveGroup) error {
-	group := &models.Group{
-		Owner:     common.HexToAddress(leaveGroup.Owner),
-		GroupID:   common.BigToHash(leaveGroup.GroupId.BigInt()),
-		GroupName: leaveGroup.GroupName,
-		AccountID: common.HexToAddress(leaveGroup.MemberAddress),
-
-		UpdateAt:   block.Block.Height,
-		UpdateTime: block.Block.Time.UTC().Unix(),
-		Removed:    true,
-	}
-
 	//update group item
 	groupItem := &models.Group{
 		GroupID:   common.BigToHash(leaveGroup.GroupId.BigInt()),
@@ -148,9 +125,17 @@ func (m *Module) handleLeaveGroup(ctx context.Context, block *tmctypes.ResultBlo
 		UpdateTime: block.Block.Time.UTC().Unix(),
 		Removed:    false,
 	}
-	m.db.UpdateGroup(ctx, groupItem)
+	if err := m.updateGroup(ctx, block, groupItem, "update_at", "update_time"); err != nil {
+		return err
+	}
 
-	return m.db.UpdateGroup(ctx, group)
+	return m.db.DeleteGroupMember(
+		ctx,
+		common.BigToHash(leaveGroup.GroupId.BigInt()),
+		common.HexToAddress(leaveGroup.MemberAddress),
+		block.Block.Height,
+		block.Block.Time.UTC().Unix(),
+	)
 }
 
 func (m *Module) handleUpdateGroupMember(ctx context.Context, block *tmctypes.ResultBlock, updateGroupMember *storagetypes.EventUpdateGroupMember) error {
@@ -182,18 +167,16 @@ func (m *Module) handleUpdateGroupMember(ctx context.Context, block *tmctypes.Re
 	}
 
 	for _, memberToDelete := range membersToDelete {
-		groupItem := &models.Group{
-			Owner:     common.HexToAddress(updateGroupMember.Owner),
-			GroupID:   common.BigToHash(updateGroupMember.GroupId.BigInt()),
-			GroupName: updateGroupMember.GroupName,
-			AccountID: common.HexToAddress(memberToDelete),
-			Operator:  common.HexToAddress(updateGroupMember.Operator),
-
-			UpdateAt:   block.Block.Height,
-			UpdateTime: block.Block.Time.UTC().Unix(),
-			Removed:    true,
+		err := m.db.DeleteGroupMember(
+			ctx,
+			common.BigToHash(updateGroupMember.GroupId.BigInt()),
+			common.HexToAddress(memberToDelete),
+			block.Block.Height,
+			block.Block.Time.UTC().Unix(),
+		)
+		if err != nil {
+			return err
 		}
-		m.db.UpdateGroup(ctx, groupItem)
 	}
 
 	//update group item
@@ -205,7 +188,38 @@ func (m *Module) handleUpdateGroupMember(ctx context.Context, block *tmctypes.Re
 		UpdateTime: block.Block.Time.UTC().Unix(),
 		Removed:    false,
 	}
-	m.db.UpdateGroup(ctx, groupItem)
+	return m.updateGroup(ctx, block, groupItem, "update_at", "update_time")
+}
 
+func (m *Module) handleRenewGroupMember(ctx context.Context, block *tmctypes.ResultBlock, renewGroupMember *storagetypes.EventRenewGroupMember) error {
+	for _, member := range renewGroupMember.Members {
+		var expirationTime int64
+		if member.ExpirationTime != nil {
+			expirationTime = member.ExpirationTime.Unix()
+		}
+		groupItem := &models.Group{
+			GroupID:        common.BigToHash(renewGroupMember.GroupId.BigInt()),
+			AccountID:      common.HexToAddress(member.Member),
+			ExpirationTime: expirationTime,
+
+			UpdateAt:   block.Block.Height,
+			UpdateTime: block.Block.Time.UTC().Unix(),
+		}
+		if err := m.updateGroup(ctx, block, groupItem, "expiration_time", "update_at", "update_time"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updateGroup writes the given columns of the group row, unless a later block has updated it already
+func (m *Module) updateGroup(ctx context.Context, block *tmctypes.ResultBlock, group *models.Group, columns ...string) error {
+	updated, err := m.db.UpdateGroupAt(ctx, block.Block.Height, group, columns...)
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		log.Debugw("skipping stale group update", "group_id", group.GroupID, "account_id", group.AccountID, "height", block.Block.Height)
+	}
 	return nil
 }
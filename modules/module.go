@@ -39,6 +39,25 @@ func (m Modules) FindByName(name string) (module Module, found bool) {
 
 // --------------------------------------------------------------------------------------------------------------------
 
+type ActivationHeightModule interface {
+	// ActivationHeight returns the height from which the module gets the chain data, the heights below it, produced
+	// before the module feature existed on chain, being skipped for it.
+	ActivationHeight() uint64
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+type DependentModule interface {
+	// Dependencies returns the names of the modules whose data this module relies on, which are expected to run
+	// along with it.
+	Dependencies() []string
+	// SetMissingDependencies is given the names of the dependencies not running along with the module, whose
+	// tables may not exist. The module skips the part of its handling relying on them.
+	SetMissingDependencies(names []string)
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
 type PrepareTablesModule interface {
 	// PrepareTables creates tables required by the module.
 	PrepareTables() error
@@ -61,6 +80,14 @@ type AsyncOperationsModule interface {
 	RunAsyncOperations()
 }
 
+type StoppableAsyncOperationsModule interface {
+	// RunAsyncOperationsUntil runs all the async operations associated with a module, like RunAsyncOperations,
+	// until the given context is done. The process shuts down once this method has returned, or once the
+	// drain timeout has passed.
+	// NOTE. When a module implements both, this method is called instead of RunAsyncOperations.
+	RunAsyncOperationsUntil(ctx context.Context)
+}
+
 type PeriodicOperationsModule interface {
 	// RegisterPeriodicOperations allows to register all the operations that will be run on a periodic basis.
 	// The given scheduler can be used to define the periodicity of each task.
@@ -86,11 +113,26 @@ type GenesisModule interface {
 
 type BlockModule interface {
 	// HandleBlock allows to handle a single block.
-	// For convenience of use, all the transactions present inside the given block will be passed as well.
+	// The transactions present inside the given block are only passed to the modules implementing
+	// BlockTxsModule, the other ones getting none of them.
 	// For each transaction present inside the block, HandleTx will be called as well.
 	// NOTE. The returned error will be logged using the BlockError method. All other modules' handlers
 	// will still be called.
-	HandleBlock(block *tmctypes.ResultBlock, results *tmctypes.ResultBlockResults, txs []*types.Tx, getTmcValidators GetTmcValidators) error
+	// The given context carries the transaction of the block, if any, which the writes of the module must go through.
+	HandleBlock(ctx context.Context, block *tmctypes.ResultBlock, results *tmctypes.ResultBlockResults, txs []*types.Tx, getTmcValidators GetTmcValidators) error
+}
+
+type BlockTxsModule interface {
+	// RequiresBlockTxs tells whether HandleBlock needs all the transactions of the block, which are otherwise
+	// decoded and handled a few at a time instead of being held in memory all at once.
+	RequiresBlockTxs() bool
+}
+
+type OrderSensitiveModule interface {
+	// OrderSensitive tells whether the module relies on the transactions of a block being handled one after the
+	// other in their order, even the ones touching different resources, which disables the parallel handling of
+	// the transactions.
+	OrderSensitive() bool
 }
 
 type TransactionModule interface {
@@ -128,6 +170,23 @@ type EventModule interface {
 	ClearCtx()
 }
 
+// eventIndexKey is the context key of the index of the handled event among the events of its tx
+type eventIndexKey struct{}
+
+// WithEventIndex returns a copy of ctx telling the event handlers the index of the handled event among the events
+// of its tx
+func WithEventIndex(ctx context.Context, index int) context.Context {
+	return context.WithValue(ctx, eventIndexKey{}, index)
+}
+
+// EventIndex returns the index of the handled event among the events of its tx, or -1 if ctx doesn't tell it
+func EventIndex(ctx context.Context) int {
+	if index, ok := ctx.Value(eventIndexKey{}).(int); ok {
+		return index
+	}
+	return -1
+}
+
 type EpochModule interface {
 	IsProcessed(height uint64) (bool, error)
 }
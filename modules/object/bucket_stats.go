@@ -0,0 +1,84 @@
+package object
+
+import (
+	"context"
+	"errors"
+
+	tmctypes "github.com/cometbft/cometbft/rpc/core/types"
+	storagetypes "github.com/evmos/evmos/v12/x/storage/types"
+
+	"github.com/forbole/juno/v4/common"
+	"github.com/forbole/juno/v4/database"
+	"github.com/forbole/juno/v4/log"
+	"github.com/forbole/juno/v4/models"
+)
+
+// handleDeleteBucket resets the stats of the deleted bucket. The chain only deletes empty buckets, so the objects
+// left tell that their deletion has been missed, which gets recorded as an issue of the bucket.
+func (m *Module) handleDeleteBucket(ctx context.Context, block *tmctypes.ResultBlock, txHash common.Hash, deleteBucket *storagetypes.EventDeleteBucket) error {
+	bucketID := common.BigToHash(deleteBucket.BucketId.BigInt())
+
+	return database.WithTx(ctx, m.db, func(tx database.Database) error {
+		activeObjects, err := tx.CountActiveObjectsByBucket(ctx, bucketID)
+		if err != nil {
+			return err
+		}
+		if activeObjects > 0 {
+			log.Warnw("deleted bucket still has objects", "bucket_id", bucketID, "bucket_name", deleteBucket.BucketName,
+				"objects", activeObjects, "height", block.Block.Height)
+			err := tx.SaveBucketDeletionIssue(ctx, &models.BucketDeletionIssue{
+				BucketID:      bucketID,
+				BucketName:    deleteBucket.BucketName,
+				ActiveObjects: activeObjects,
+				Height:        block.Block.Height,
+				TxHash:        txHash,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return tx.ResetBucketStats(ctx, bucketID)
+	})
+}
+
+// countObject adds the given sealed object to the stats of its bucket. An object counted already is left
+// untouched, so that handling its seal again doesn't count it twice.
+// An error is returned if the operation fails.
+func countObject(ctx context.Context, db database.Database, objectID common.Hash) error {
+	object, err := db.GetObject(ctx, objectID)
+	if errors.Is(err, database.ErrObjectNotFound) {
+		return nil
+	}
+	if err != nil || object.StatsCounted {
+		return err
+	}
+
+	if err := db.AddBucketStats(ctx, object.BucketID, 1, int64(object.PayloadSize)); err != nil {
+		return err
+	}
+	return db.UpdateObject(ctx, &models.Object{ObjectID: object.ObjectID, StatsCounted: true}, "stats_counted")
+}
+
+// uncountObject subtracts the given object from the stats of its bucket once it is deleted, if it has been
+// counted.
+// An error is returned if the operation fails.
+func uncountObject(ctx context.Context, db database.Database, object *models.Object) error {
+	if !object.StatsCounted {
+		return nil
+	}
+
+	if err := db.AddBucketStats(ctx, object.BucketID, -1, -int64(object.PayloadSize)); err != nil {
+		return err
+	}
+	return db.UpdateObject(ctx, &models.Object{ObjectID: object.ObjectID}, "stats_counted")
+}
+
+// resizeObject adjusts the stats of the bucket of the given object, as stored before its content got updated,
+// to the new payload size, if it has been counted.
+// An error is returned if the operation fails.
+func resizeObject(ctx context.Context, db database.Database, object *models.Object, payloadSize uint64) error {
+	if !object.StatsCounted || object.PayloadSize == payloadSize {
+		return nil
+	}
+	return db.AddBucketStats(ctx, object.BucketID, 0, int64(payloadSize)-int64(object.PayloadSize))
+}
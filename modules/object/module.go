@@ -8,6 +8,9 @@ import (
 	"github.com/forbole/juno/v4/database"
 	"github.com/forbole/juno/v4/models"
 	"github.com/forbole/juno/v4/modules"
+	"github.com/forbole/juno/v4/modules/bucket"
+	"github.com/forbole/juno/v4/modules/permission"
+	virtualgroup "github.com/forbole/juno/v4/modules/virtual_group"
 )
 
 const (
@@ -17,11 +20,15 @@ const (
 var (
 	_ modules.Module              = &Module{}
 	_ modules.PrepareTablesModule = &Module{}
+	_ modules.DependentModule     = &Module{}
 )
 
 // Module represents the object module
 type Module struct {
 	db database.Database
+
+	// missing holds the names of the modules the object module relies on which are not running
+	missing map[string]bool
 }
 
 // NewModule builds a new Module instance
@@ -36,12 +43,33 @@ func (m *Module) Name() string {
 	return ModuleName
 }
 
+// Dependencies implements modules.DependentModule.
+// The objects live in their buckets, which their creators may have been granted through a policy, and are stored
+// in the virtual groups.
+func (m *Module) Dependencies() []string {
+	return []string{bucket.ModuleName, permission.ModuleName, virtualgroup.ModuleName}
+}
+
+// SetMissingDependencies implements modules.DependentModule
+func (m *Module) SetMissingDependencies(names []string) {
+	m.missing = make(map[string]bool, len(names))
+	for _, name := range names {
+		m.missing[name] = true
+	}
+}
+
+// running tells whether the module having the given name, among the dependencies, runs along with this one
+func (m *Module) running(name string) bool {
+	return !m.missing[name]
+}
+
 // PrepareTables implements
 func (m *Module) PrepareTables() error {
-	return m.db.PrepareTables(context.TODO(), []schema.Tabler{&models.Object{}})
+	database.RegisterRollbackTable(&models.BucketDeletionIssue{}, "height")
+	return m.db.PrepareTables(context.TODO(), []schema.Tabler{&models.Object{}, &models.BucketStats{}, &models.BucketDeletionIssue{}})
 }
 
 // AutoMigrate implements
 func (m *Module) AutoMigrate() error {
-	return m.db.AutoMigrate(context.TODO(), []schema.Tabler{&models.Object{}})
+	return m.db.AutoMigrate(context.TODO(), []schema.Tabler{&models.Object{}, &models.BucketStats{}, &models.BucketDeletionIssue{}})
 }
@@ -3,6 +3,7 @@ package object
 import (
 	"context"
 	"errors"
+	"fmt"
 
 	abci "github.com/cometbft/cometbft/abci/types"
 	tmctypes "github.com/cometbft/cometbft/rpc/core/types"
@@ -11,8 +12,12 @@ import (
 	storagetypes "github.com/evmos/evmos/v12/x/storage/types"
 
 	"github.com/forbole/juno/v4/common"
+	"github.com/forbole/juno/v4/database"
 	"github.com/forbole/juno/v4/log"
 	"github.com/forbole/juno/v4/models"
+	"github.com/forbole/juno/v4/modules/bucket"
+	"github.com/forbole/juno/v4/modules/permission"
+	virtualgroup "github.com/forbole/juno/v4/modules/virtual_group"
 )
 
 var (
@@ -24,6 +29,10 @@ var (
 	EventRejectSealObject   = proto.MessageName(&storagetypes.EventRejectSealObject{})
 	EventDiscontinueObject  = proto.MessageName(&storagetypes.EventDiscontinueObject{})
 	EventUpdateObjectInfo   = proto.MessageName(&storagetypes.EventUpdateObjectInfo{})
+	EventMirrorObject       = proto.MessageName(&storagetypes.EventMirrorObject{})
+	EventMirrorObjectResult = proto.MessageName(&storagetypes.EventMirrorObjectResult{})
+
+	EventUpdateObjectContentSuccess = proto.MessageName(&storagetypes.EventUpdateObjectContentSuccess{})
 )
 
 var ObjectEvents = map[string]bool{
@@ -35,6 +44,13 @@ var ObjectEvents = map[string]bool{
 	EventRejectSealObject:   true,
 	EventDiscontinueObject:  true,
 	EventUpdateObjectInfo:   true,
+	EventMirrorObject:       true,
+	EventMirrorObjectResult: true,
+
+	EventUpdateObjectContentSuccess: true,
+
+	// The objects and the stats of a bucket go along with it
+	bucket.EventDeleteBucket: true,
 }
 
 func (m *Module) ExtractEventStatements(ctx context.Context, block *tmctypes.ResultBlock, txHash common.Hash, event sdk.Event) (map[string][]interface{}, error) {
@@ -109,19 +125,56 @@ func (m *Module) HandleEvent(ctx context.Context, block *tmctypes.ResultBlock, t
 			return errors.New("update object event assert error")
 		}
 		return m.handleUpdateObjectInfo(ctx, block, txHash, updateObjectInfo)
+	case EventMirrorObject:
+		mirrorObject, ok := typedEvent.(*storagetypes.EventMirrorObject)
+		if !ok {
+			log.Errorw("type assert error", "type", "EventMirrorObject", "event", typedEvent)
+			return errors.New("mirror object event assert error")
+		}
+		return m.handleMirrorObject(ctx, block, txHash, mirrorObject)
+	case EventMirrorObjectResult:
+		mirrorObjectResult, ok := typedEvent.(*storagetypes.EventMirrorObjectResult)
+		if !ok {
+			log.Errorw("type assert error", "type", "EventMirrorObjectResult", "event", typedEvent)
+			return errors.New("mirror object result event assert error")
+		}
+		return m.handleMirrorObjectResult(ctx, block, txHash, mirrorObjectResult)
+	case EventUpdateObjectContentSuccess:
+		updateObjectContent, ok := typedEvent.(*storagetypes.EventUpdateObjectContentSuccess)
+		if !ok {
+			log.Errorw("type assert error", "type", "EventUpdateObjectContentSuccess", "event", typedEvent)
+			return errors.New("update object content success event assert error")
+		}
+		return m.handleUpdateObjectContentSuccess(ctx, block, txHash, updateObjectContent)
+	case bucket.EventDeleteBucket:
+		deleteBucket, ok := typedEvent.(*storagetypes.EventDeleteBucket)
+		if !ok {
+			log.Errorw("type assert error", "type", "EventDeleteBucket", "event", typedEvent)
+			return errors.New("delete bucket event assert error")
+		}
+		return m.handleDeleteBucket(ctx, block, txHash, deleteBucket)
 	}
 
 	return nil
 }
 
 func (m *Module) handleCreateObject(ctx context.Context, block *tmctypes.ResultBlock, txHash common.Hash, createObject *storagetypes.EventCreateObject) error {
+	creator, err := common.ParseAddressOrZero(createObject.Creator)
+	if err != nil {
+		return fmt.Errorf("object creator: %w", err)
+	}
+	owner, err := common.ParseAddressOrZero(createObject.Owner)
+	if err != nil {
+		return fmt.Errorf("object owner: %w", err)
+	}
+
 	object := &models.Object{
 		BucketID:       common.BigToHash(createObject.BucketId.BigInt()),
 		BucketName:     createObject.BucketName,
 		ObjectID:       common.BigToHash(createObject.ObjectId.BigInt()),
 		ObjectName:     createObject.ObjectName,
-		Creator:        common.HexToAddress(createObject.Creator),
-		Owner:          common.HexToAddress(createObject.Owner),
+		Creator:        creator,
+		Owner:          owner,
 		PayloadSize:    createObject.PayloadSize,
 		Visibility:     createObject.Visibility.String(),
 		ContentType:    createObject.ContentType,
@@ -139,9 +192,60 @@ func (m *Module) handleCreateObject(ctx context.Context, block *tmctypes.ResultB
 		Removed:      false,
 	}
 
-	return m.db.SaveObject(ctx, object)
+	return database.WithTx(ctx, m.db, func(tx database.Database) error {
+		created, err := tx.CreateObject(ctx, object)
+		if err != nil {
+			return err
+		}
+		if created {
+			return countCreatedSealed(ctx, tx, object)
+		}
+
+		stored, err := tx.GetObjectWithRemoved(ctx, object.ObjectID)
+		if err != nil {
+			return err
+		}
+		if stored.CreateAt != 0 {
+			if stored.UpdateAt > object.UpdateAt {
+				log.Debugw("skipping stale object creation", "object_id", object.ObjectID, "height", block.Block.Height)
+				return nil
+			}
+			// The creation handled again must not count the object twice
+			object.StatsCounted, object.QuotaStatementID = stored.StatsCounted, stored.QuotaStatementID
+			if err := tx.SaveObject(ctx, object); err != nil {
+				return err
+			}
+			return countCreatedSealed(ctx, tx, object)
+		}
+
+		// The object has been sealed or rejected already, by events handled before this one, leaving a stub this
+		// creation fills in
+		columns := []string{"bucket_id", "creator", "owner", "payload_size", "visibility", "content_type",
+			"redundancy_type", "source_type", "create_tx_hash", "create_at", "create_time"}
+		// The checksums of an object created by its storage provider are only known once sealed
+		if len(object.CheckSums) > 0 {
+			columns = append(columns, "checksums")
+		}
+		err = tx.UpdateObject(ctx, object, columns...)
+		if err != nil || stored.Status != storagetypes.OBJECT_STATUS_SEALED.String() {
+			return err
+		}
+		object.LocalVirtualGroupId = stored.LocalVirtualGroupId
+		return m.sealBookkeeping(ctx, tx, stored.SealedAt, object)
+	})
 }
 
+// countCreatedSealed counts the given object in the stats of its bucket if it has been created sealed, which an
+// empty object is, without any seal following its creation
+func countCreatedSealed(ctx context.Context, tx database.Database, object *models.Object) error {
+	if object.Status != storagetypes.OBJECT_STATUS_SEALED.String() {
+		return nil
+	}
+	return countObject(ctx, tx, object.ObjectID)
+}
+
+// handleSealObject marks the object as sealed and assigns it to its local virtual group. An object whose creation
+// hasn't been handled yet is saved as a stub that the creation fills in.
 func (m *Module) handleSealObject(ctx context.Context, block *tmctypes.ResultBlock, txHash common.Hash, sealObject *storagetypes.EventSealObject) error {
 	object := &models.Object{
 		BucketName:          sealObject.BucketName,
@@ -151,6 +255,9 @@ func (m *Module) handleSealObject(ctx context.Context, block *tmctypes.ResultBlo
 		LocalVirtualGroupId: sealObject.LocalVirtualGroupId,
 		Status:              sealObject.Status.String(),
 		SealedTxHash:        txHash,
+		SealedAt:            block.Block.Height,
+		SealedTime:          block.Block.Time.UTC().Unix(),
+		CheckSums:           sealObject.Checksums,
 
 		UpdateAt:     block.Block.Height,
 		UpdateTxHash: txHash,
@@ -158,43 +265,142 @@ func (m *Module) handleSealObject(ctx context.Context, block *tmctypes.ResultBlo
 		Removed:      false,
 	}
 
-	return m.db.UpdateObject(ctx, object)
+	columns := []string{"operator", "local_virtual_group_id", "status", "sealed_tx_hash", "sealed_at", "sealed_time", "update_at", "update_tx_hash", "update_time", "removed"}
+	// The checksums given along with the creation are left as they are when the seal carries none
+	if len(object.CheckSums) > 0 {
+		columns = append(columns, "checksums")
+	}
+
+	return database.WithTx(ctx, m.db, func(tx database.Database) error {
+		stored, updated, err := updateOrStubObject(ctx, tx, block, object, columns...)
+		if err != nil || !updated {
+			return err
+		}
+
+		stored.LocalVirtualGroupId = object.LocalVirtualGroupId
+		return m.sealBookkeeping(ctx, tx, block.Block.Height, stored)
+	})
 }
 
+// sealBookkeeping consumes the payload of the given object, sealed at the given height, out of the size limit it was
+// created under, counts it in the stats of its bucket and adds it to the stored size of its virtual groups. The
+// limits and the virtual groups are left aside when the permission or the virtual group module isn't running.
+func (m *Module) sealBookkeeping(ctx context.Context, tx database.Database, height int64, object *models.Object) error {
+	if m.running(permission.ModuleName) {
+		if err := permission.ConsumeQuota(ctx, tx, object.ObjectID); err != nil {
+			return err
+		}
+	}
+	if err := countObject(ctx, tx, object.ObjectID); err != nil {
+		return err
+	}
+	if !m.running(virtualgroup.ModuleName) {
+		return nil
+	}
+	return addVirtualGroupsStoredSize(ctx, tx, height, object)
+}
+
+// addVirtualGroupsStoredSize adds the payload of the given object, sealed at the given height, to the stored size
+// of its local virtual group and of the global virtual group of the latter.
+// The chain emits the stored sizes of the groups along with the seal, which are written as they are: the groups
+// updated at the height of the seal already are left untouched, and the stored sizes added here get overwritten
+// by the ones of the chain when handled after the seal.
+func addVirtualGroupsStoredSize(ctx context.Context, tx database.Database, height int64, object *models.Object) error {
+	lvg, err := tx.GetLVG(ctx, object.BucketID, object.LocalVirtualGroupId)
+	if errors.Is(err, database.ErrLVGNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	size := int64(object.PayloadSize)
+	if _, err := tx.AddLVGStoredSizeAt(ctx, height, lvg.BucketID, lvg.LocalVirtualGroupId, size); err != nil {
+		return err
+	}
+	_, err = tx.AddGVGStoredSizeAt(ctx, height, lvg.GlobalVirtualGroupId, size)
+	return err
+}
+
+// handleCancelCreateObject removes the object whose creation has been canceled, the chain deleting it. Its name can
+// be given to a new object right away, which gets a new id.
 func (m *Module) handleCancelCreateObject(ctx context.Context, block *tmctypes.ResultBlock, txHash common.Hash, cancelCreateObject *storagetypes.EventCancelCreateObject) error {
 	object := &models.Object{
 		BucketName:   cancelCreateObject.BucketName,
 		ObjectName:   cancelCreateObject.ObjectName,
 		ObjectID:     common.BigToHash(cancelCreateObject.ObjectId.BigInt()),
 		Operator:     common.HexToAddress(cancelCreateObject.Operator),
+		DeleteReason: models.ObjectDeleteReasonCanceled,
+
 		UpdateAt:     block.Block.Height,
 		UpdateTxHash: txHash,
 		UpdateTime:   block.Block.Time.UTC().Unix(),
 		Removed:      true,
 	}
 
-	return m.db.UpdateObject(ctx, object)
+	return m.updateObject(ctx, block, object, "operator", "delete_reason", "update_at", "update_tx_hash", "update_time", "removed")
 }
 
+// handleCopyObject stores the copy of the source object as a new object remembering its source, so that the
+// lineage of the copies can be followed. The copy needs to be sealed unless it is empty.
 func (m *Module) handleCopyObject(ctx context.Context, block *tmctypes.ResultBlock, txHash common.Hash, copyObject *storagetypes.EventCopyObject) error {
-	destObject, err := m.db.GetObject(ctx, common.BigToHash(copyObject.SrcObjectId.BigInt()))
-	if err != nil {
-		return err
-	}
+	sourceObjectID := common.BigToHash(copyObject.SrcObjectId.BigInt())
+	destObjectID := common.BigToHash(copyObject.DstObjectId.BigInt())
+
+	return database.WithTx(ctx, m.db, func(tx database.Database) error {
+		// Handling the copy again leaves the copy as it has been sealed, updated or deleted since
+		if _, err := tx.GetObjectWithRemoved(ctx, destObjectID); !errors.Is(err, database.ErrObjectNotFound) {
+			return err
+		}
+
+		// The source may have been deleted after the copy
+		destObject, err := tx.GetObjectWithRemoved(ctx, sourceObjectID)
+		if err != nil {
+			return err
+		}
+
+		// The copy belongs to the destination bucket, whose stats get credited. Without the bucket module the
+		// destination bucket is unknown, and the copy is left without one.
+		destBucketID := common.Hash{}
+		if m.running(bucket.ModuleName) {
+			destBucket, err := tx.GetBucketByNameWithRemoved(ctx, copyObject.DstBucketName)
+			if err != nil {
+				return err
+			}
+			destBucketID = destBucket.BucketID
+		}
 
-	destObject.ObjectID = common.BigToHash(copyObject.DstObjectId.BigInt())
-	destObject.ObjectName = copyObject.DstObjectName
-	destObject.BucketName = copyObject.DstBucketName
-	destObject.Operator = common.HexToAddress(copyObject.Operator)
-	destObject.CreateAt = block.Block.Height
-	destObject.CreateTxHash = txHash
-	destObject.CreateTime = block.Block.Time.UTC().Unix()
-	destObject.UpdateAt = block.Block.Height
-	destObject.UpdateTxHash = txHash
-	destObject.UpdateTime = block.Block.Time.UTC().Unix()
-	destObject.Removed = false
-
-	return m.db.UpdateObject(ctx, destObject)
+		destObject.ID = 0
+		destObject.ObjectID = destObjectID
+		destObject.BucketID = destBucketID
+		destObject.ObjectName = copyObject.DstObjectName
+		destObject.BucketName = copyObject.DstBucketName
+		destObject.Operator = common.HexToAddress(copyObject.Operator)
+		destObject.LocalVirtualGroupId = copyObject.LocalVirtualGroupId
+		destObject.Status = storagetypes.OBJECT_STATUS_CREATED.String()
+		if destObject.PayloadSize == 0 {
+			destObject.Status = storagetypes.OBJECT_STATUS_SEALED.String()
+		}
+		destObject.SourceObjectID = &sourceObjectID
+		destObject.SourceBucketName = copyObject.SrcBucketName
+		destObject.QuotaStatementID = 0
+		destObject.StatsCounted = false
+		destObject.CreateAt = block.Block.Height
+		destObject.CreateTxHash = txHash
+		destObject.CreateTime = block.Block.Time.UTC().Unix()
+		destObject.UpdateAt = block.Block.Height
+		destObject.UpdateTxHash = txHash
+		destObject.UpdateTime = block.Block.Time.UTC().Unix()
+		destObject.Removed = false
+
+		if err := tx.SaveObject(ctx, destObject); err != nil {
+			return err
+		}
+		if destObject.Status != storagetypes.OBJECT_STATUS_SEALED.String() {
+			return nil
+		}
+		return countObject(ctx, tx, destObjectID)
+	})
 }
 
 func (m *Module) handleDeleteObject(ctx context.Context, block *tmctypes.ResultBlock, txHash common.Hash, deleteObject *storagetypes.EventDeleteObject) error {
@@ -210,17 +416,37 @@ func (m *Module) handleDeleteObject(ctx context.Context, block *tmctypes.ResultB
 		Removed:      true,
 	}
 
-	return m.db.UpdateObject(ctx, object)
+	// The payload of the object is given back to the size limit it was created under, and subtracted from the stats
+	// of its bucket, along with the deletion
+	return database.WithTx(ctx, m.db, func(tx database.Database) error {
+		// The object can't be read anymore once removed
+		stored, err := tx.GetObject(ctx, object.ObjectID)
+		if err != nil && !errors.Is(err, database.ErrObjectNotFound) {
+			return err
+		}
+
+		updated, err := updateObjectIn(ctx, tx, block, object, "update_at", "update_tx_hash", "update_time", "removed")
+		if err != nil || !updated || stored == nil {
+			return err
+		}
+		if m.running(permission.ModuleName) {
+			if err := permission.ReleaseQuota(ctx, tx, stored); err != nil {
+				return err
+			}
+		}
+		return uncountObject(ctx, tx, stored)
+	})
 }
 
 // RejectSeal event won't emit a delete event, need to be deleted manually here in metadata service
-// handle logic is set as removed, no need to set status
+// handle logic is set as removed, recording the rejection in the delete reason
 func (m *Module) handleRejectSealObject(ctx context.Context, block *tmctypes.ResultBlock, txHash common.Hash, rejectSealObject *storagetypes.EventRejectSealObject) error {
 	object := &models.Object{
-		BucketName: rejectSealObject.BucketName,
-		ObjectName: rejectSealObject.ObjectName,
-		ObjectID:   common.BigToHash(rejectSealObject.ObjectId.BigInt()),
-		Operator:   common.HexToAddress(rejectSealObject.Operator),
+		BucketName:   rejectSealObject.BucketName,
+		ObjectName:   rejectSealObject.ObjectName,
+		ObjectID:     common.BigToHash(rejectSealObject.ObjectId.BigInt()),
+		Operator:     common.HexToAddress(rejectSealObject.Operator),
+		DeleteReason: models.ObjectDeleteReasonSealRejected,
 
 		UpdateAt:     block.Block.Height,
 		UpdateTxHash: txHash,
@@ -228,7 +454,10 @@ func (m *Module) handleRejectSealObject(ctx context.Context, block *tmctypes.Res
 		Removed:      true,
 	}
 
-	return m.db.UpdateObject(ctx, object)
+	return database.WithTx(ctx, m.db, func(tx database.Database) error {
+		_, _, err := updateOrStubObject(ctx, tx, block, object, "operator", "delete_reason", "update_at", "update_tx_hash", "update_time", "removed")
+		return err
+	})
 }
 
 func (m *Module) handleEventDiscontinueObject(ctx context.Context, block *tmctypes.ResultBlock, txHash common.Hash, discontinueObject *storagetypes.EventDiscontinueObject) error {
@@ -245,7 +474,7 @@ func (m *Module) handleEventDiscontinueObject(ctx context.Context, block *tmctyp
 		Removed:      false,
 	}
 
-	return m.db.UpdateObject(ctx, object)
+	return m.updateObject(ctx, block, object, "delete_reason", "delete_at", "status", "update_at", "update_tx_hash", "update_time", "removed")
 }
 
 func (m *Module) handleUpdateObjectInfo(ctx context.Context, block *tmctypes.ResultBlock, txHash common.Hash, updateObject *storagetypes.EventUpdateObjectInfo) error {
@@ -261,5 +490,128 @@ func (m *Module) handleUpdateObjectInfo(ctx context.Context, block *tmctypes.Res
 		UpdateTime:   block.Block.Time.UTC().Unix(),
 	}
 
-	return m.db.UpdateObject(ctx, object)
+	return m.updateObject(ctx, block, object, "operator", "visibility", "update_at", "update_tx_hash", "update_time")
+}
+
+// handleUpdateObjectContentSuccess records the new payload of the object once its update has been sealed,
+// adjusting the stored size of its bucket
+func (m *Module) handleUpdateObjectContentSuccess(ctx context.Context, block *tmctypes.ResultBlock, txHash common.Hash, updateObjectContent *storagetypes.EventUpdateObjectContentSuccess) error {
+	object := &models.Object{
+		BucketName:         updateObjectContent.BucketName,
+		ObjectName:         updateObjectContent.ObjectName,
+		ObjectID:           common.BigToHash(updateObjectContent.ObjectId.BigInt()),
+		PayloadSize:        updateObjectContent.NewPayloadSize,
+		ContentType:        updateObjectContent.ContentType,
+		CheckSums:          updateObjectContent.NewChecksums,
+		IsUpdating:         false,
+		ContentUpdatedTime: updateObjectContent.UpdatedAt,
+		Updater:            common.HexToAddress(updateObjectContent.Operator),
+		Version:            updateObjectContent.Version,
+
+		UpdateAt:     block.Block.Height,
+		UpdateTxHash: txHash,
+		UpdateTime:   block.Block.Time.UTC().Unix(),
+	}
+
+	return database.WithTx(ctx, m.db, func(tx database.Database) error {
+		// Handling the update again finds the new size stored already, leaving the stats untouched
+		stored, err := tx.GetObject(ctx, object.ObjectID)
+		if err != nil && !errors.Is(err, database.ErrObjectNotFound) {
+			return err
+		}
+
+		updated, err := updateObjectIn(ctx, tx, block, object, "payload_size", "content_type", "checksums", "is_updating", "content_updated_time", "updater", "version", "update_at", "update_tx_hash", "update_time")
+		if err != nil || !updated || stored == nil {
+			return err
+		}
+		return resizeObject(ctx, tx, stored, object.PayloadSize)
+	})
+}
+
+func (m *Module) handleMirrorObject(ctx context.Context, block *tmctypes.ResultBlock, txHash common.Hash, mirrorObject *storagetypes.EventMirrorObject) error {
+	object := &models.Object{
+		ObjectID:          common.BigToHash(mirrorObject.ObjectId.BigInt()),
+		BucketName:        mirrorObject.BucketName,
+		ObjectName:        mirrorObject.ObjectName,
+		MirrorStatus:      models.MirrorStatusPending,
+		MirrorDestChainID: mirrorObject.DestChainId,
+
+		UpdateAt:     block.Block.Height,
+		UpdateTxHash: txHash,
+		UpdateTime:   block.Block.Time.UTC().Unix(),
+	}
+
+	return m.updateObject(ctx, block, object, "mirror_status", "mirror_dest_chain_id", "update_at", "update_tx_hash", "update_time")
+}
+
+// handleMirrorObjectResult records the acknowledgement of the mirroring of an object by the destination chain, which
+// may come many blocks after the request, once the object has been deleted already
+func (m *Module) handleMirrorObjectResult(ctx context.Context, block *tmctypes.ResultBlock, txHash common.Hash, mirrorObjectResult *storagetypes.EventMirrorObjectResult) error {
+	objectID := common.BigToHash(mirrorObjectResult.ObjectId.BigInt())
+	if _, err := m.db.GetObject(ctx, objectID); err != nil {
+		if errors.Is(err, database.ErrObjectNotFound) {
+			log.Infow("skipping mirror result of deleted object", "object_id", objectID, "height", block.Block.Height)
+			return nil
+		}
+		return err
+	}
+
+	mirrorStatus := models.MirrorStatusFailed
+	if mirrorObjectResult.Status == storagetypes.StatusSuccess {
+		mirrorStatus = models.MirrorStatusSuccess
+	}
+
+	object := &models.Object{
+		ObjectID:          objectID,
+		BucketName:        mirrorObjectResult.BucketName,
+		ObjectName:        mirrorObjectResult.ObjectName,
+		MirrorStatus:      mirrorStatus,
+		MirrorDestChainID: mirrorObjectResult.DestChainId,
+
+		UpdateAt:     block.Block.Height,
+		UpdateTxHash: txHash,
+		UpdateTime:   block.Block.Time.UTC().Unix(),
+	}
+
+	return m.updateObject(ctx, block, object, "mirror_status", "mirror_dest_chain_id", "update_at", "update_tx_hash", "update_time")
+}
+
+// updateObject writes the given columns of the object, unless a later block has updated it already
+func (m *Module) updateObject(ctx context.Context, block *tmctypes.ResultBlock, object *models.Object, columns ...string) error {
+	_, err := updateObjectIn(ctx, m.db, block, object, columns...)
+	return err
+}
+
+// updateOrStubObject writes the given columns of the object as updateObjectIn does, saving the object as a stub
+// if it doesn't exist yet. The object as stored before the update is returned, along with whether it has been
+// updated; a stub is not.
+func updateOrStubObject(ctx context.Context, tx database.Database, block *tmctypes.ResultBlock, object *models.Object, columns ...string) (*models.Object, bool, error) {
+	stored, err := tx.GetObjectWithRemoved(ctx, object.ObjectID)
+	if errors.Is(err, database.ErrObjectNotFound) {
+		// Another worker may create the object meanwhile, in which case it gets updated
+		created, createErr := tx.CreateObject(ctx, object)
+		if createErr != nil || created {
+			return nil, false, createErr
+		}
+		stored, err = tx.GetObjectWithRemoved(ctx, object.ObjectID)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	updated, err := updateObjectIn(ctx, tx, block, object, columns...)
+	return stored, updated, err
+}
+
+// updateObjectIn behaves like updateObject, writing into the given database, and tells whether the object
+// has been written
+func updateObjectIn(ctx context.Context, db database.Database, block *tmctypes.ResultBlock, object *models.Object, columns ...string) (bool, error) {
+	updated, err := db.UpdateObjectAt(ctx, block.Block.Height, object, columns...)
+	if err != nil {
+		return false, err
+	}
+	if updated == 0 {
+		log.Debugw("skipping stale object update", "object_id", object.ObjectID, "height", block.Block.Height)
+	}
+	return updated > 0, nil
 }
@@ -0,0 +1,671 @@
+package object
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	sdkmath "cosmossdk.io/math"
+	tmctypes "github.com/cometbft/cometbft/rpc/core/types"
+	tmtypes "github.com/cometbft/cometbft/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/gogoproto/proto"
+	storagetypes "github.com/evmos/evmos/v12/x/storage/types"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+
+	"github.com/forbole/juno/v4/common"
+	"github.com/forbole/juno/v4/database"
+	"github.com/forbole/juno/v4/database/sqlite/sqlitetest"
+	"github.com/forbole/juno/v4/models"
+	"github.com/forbole/juno/v4/modules/permission"
+	virtualgroup "github.com/forbole/juno/v4/modules/virtual_group"
+)
+
+func newTestModule(t *testing.T) (*Module, *database.Impl) {
+	t.Helper()
+
+	db := sqlitetest.NewDatabase(t, &models.Object{}, &models.BucketStats{}, &models.BucketDeletionIssue{},
+		&models.Bucket{}, &models.Permission{}, &models.LocalVirtualGroup{}, &models.GlobalVirtualGroup{})
+	return NewModule(db), db
+}
+
+func newTestBlock(height int64) *tmctypes.ResultBlock {
+	return &tmctypes.ResultBlock{
+		Block: &tmtypes.Block{Header: tmtypes.Header{Height: height, Time: time.Unix(1700000000+height, 0)}},
+	}
+}
+
+// testCreator is the creator of the objects of the tests
+var testCreator = common.HexToAddress("0x01")
+
+// objectID returns the hash of the given id, as the objects and the buckets are identified with
+func objectID(id uint64) common.Hash {
+	return common.BigToHash(sdkmath.NewUint(id).BigInt())
+}
+
+// handleEvent makes m handle the given event, emitted at height by the tx whose hash is the height
+func handleEvent(t *testing.T, m *Module, height int64, event proto.Message) {
+	t.Helper()
+
+	sdkEvent, err := sdk.TypedEventToEvent(event)
+	require.NoError(t, err)
+	require.NoError(t, m.HandleEvent(context.Background(), newTestBlock(height), common.BigToHash(sdkmath.NewInt(height).BigInt()), sdkEvent))
+}
+
+func TestMirrorObject(t *testing.T) {
+	ctx := context.Background()
+	m, db := newTestModule(t)
+
+	mirrorStatus := func(objectID uint64) (string, uint32) {
+		object, err := db.GetObject(ctx, common.BigToHash(sdkmath.NewUint(objectID).BigInt()))
+		require.NoError(t, err)
+		return object.MirrorStatus, object.MirrorDestChainID
+	}
+
+	for _, objectID := range []uint64{1, 2, 3} {
+		require.NoError(t, db.SaveObject(ctx, &models.Object{
+			ObjectID: common.BigToHash(sdkmath.NewUint(objectID).BigInt()), BucketName: "bucket", UpdateAt: 10,
+		}))
+		status, _ := mirrorStatus(objectID)
+		require.Empty(t, status)
+		handleEvent(t, m, 11, &storagetypes.EventMirrorObject{ObjectId: sdkmath.NewUint(objectID), BucketName: "bucket", DestChainId: 56})
+		status, destChainID := mirrorStatus(objectID)
+		require.Equal(t, models.MirrorStatusPending, status)
+		require.Equal(t, uint32(56), destChainID)
+	}
+
+	// Request then success
+	handleEvent(t, m, 20, &storagetypes.EventMirrorObjectResult{ObjectId: sdkmath.NewUint(1), Status: storagetypes.StatusSuccess, DestChainId: 56})
+	status, destChainID := mirrorStatus(1)
+	require.Equal(t, models.MirrorStatusSuccess, status)
+	require.Equal(t, uint32(56), destChainID)
+
+	// Request then failure
+	handleEvent(t, m, 20, &storagetypes.EventMirrorObjectResult{ObjectId: sdkmath.NewUint(2), Status: storagetypes.StatusFail, DestChainId: 56})
+	status, _ = mirrorStatus(2)
+	require.Equal(t, models.MirrorStatusFailed, status)
+
+	// The result for an object deleted in between is skipped
+	require.NoError(t, db.UpdateObject(ctx, &models.Object{ObjectID: common.BigToHash(sdkmath.NewUint(3).BigInt()), Removed: true}, "removed"))
+	handleEvent(t, m, 20, &storagetypes.EventMirrorObjectResult{ObjectId: sdkmath.NewUint(3), Status: storagetypes.StatusSuccess, DestChainId: 56})
+	var object models.Object
+	require.NoError(t, db.Db.Table(object.TableName()).Where("object_id = ?", common.BigToHash(sdkmath.NewUint(3).BigInt())).Take(&object).Error)
+	require.Equal(t, models.MirrorStatusPending, object.MirrorStatus)
+	require.Equal(t, int64(11), object.UpdateAt)
+
+	// As is the result for an object never indexed
+	handleEvent(t, m, 20, &storagetypes.EventMirrorObjectResult{ObjectId: sdkmath.NewUint(4), Status: storagetypes.StatusSuccess, DestChainId: 56})
+}
+
+func TestDiscontinueObject(t *testing.T) {
+	ctx := context.Background()
+	m, db := newTestModule(t)
+
+	for _, id := range []uint64{1, 2, 3} {
+		require.NoError(t, db.SaveObject(ctx, &models.Object{
+			ObjectID: objectID(id), BucketName: "bucket", Status: storagetypes.OBJECT_STATUS_SEALED.String(), UpdateAt: 10,
+		}))
+	}
+	handleEvent(t, m, 11, &storagetypes.EventDiscontinueObject{BucketName: "bucket", ObjectId: sdkmath.NewUint(2), Reason: "illegal", DeleteAt: 2000})
+	handleEvent(t, m, 12, &storagetypes.EventDiscontinueObject{BucketName: "bucket", ObjectId: sdkmath.NewUint(1), Reason: "spam", DeleteAt: 1000})
+
+	object, err := db.GetObject(ctx, objectID(2))
+	require.NoError(t, err)
+	require.Equal(t, storagetypes.OBJECT_STATUS_DISCONTINUED.String(), object.Status)
+	require.Equal(t, int64(2000), object.DeleteAt)
+	require.Equal(t, "illegal", object.DeleteReason)
+
+	objects, err := db.ListObjectsToGCBefore(ctx, 999, 10)
+	require.NoError(t, err)
+	require.Empty(t, objects)
+
+	objects, err = db.ListObjectsToGCBefore(ctx, 2000, 10)
+	require.NoError(t, err)
+	require.Len(t, objects, 2)
+	require.Equal(t, objectID(1), objects[0].ObjectID)
+	require.Equal(t, objectID(2), objects[1].ObjectID)
+
+	objects, err = db.ListObjectsToGCBefore(ctx, 2000, 1)
+	require.NoError(t, err)
+	require.Len(t, objects, 1)
+	require.Equal(t, objectID(1), objects[0].ObjectID)
+
+	// The chain never turns a discontinued object back to sealed, it deletes it once due
+	handleEvent(t, m, 20, &storagetypes.EventDeleteObject{BucketName: "bucket", ObjectId: sdkmath.NewUint(1)})
+	objects, err = db.ListObjectsToGCBefore(ctx, 2000, 10)
+	require.NoError(t, err)
+	require.Len(t, objects, 1)
+	require.Equal(t, objectID(2), objects[0].ObjectID)
+	_, err = db.GetObject(ctx, objectID(1))
+	require.ErrorIs(t, err, database.ErrObjectNotFound)
+}
+
+func TestCancelCreateObject(t *testing.T) {
+	ctx := context.Background()
+	m, db := newTestModule(t)
+
+	createObject := func(height int64, objectID uint64) {
+		handleEvent(t, m, height, &storagetypes.EventCreateObject{
+			Creator: testCreator.String(), Owner: testCreator.String(), BucketName: "bucket", ObjectName: "a",
+			BucketId: sdkmath.NewUint(1), ObjectId: sdkmath.NewUint(objectID), CreateAt: 1700000000 + height,
+			Status: storagetypes.OBJECT_STATUS_CREATED,
+		})
+	}
+
+	createObject(10, 1)
+	handleEvent(t, m, 11, &storagetypes.EventCancelCreateObject{Operator: testCreator.String(), BucketName: "bucket", ObjectName: "a", ObjectId: sdkmath.NewUint(1)})
+	_, err := db.GetObjectByBucketAndName(ctx, "bucket", "a")
+	require.ErrorIs(t, err, database.ErrObjectNotFound)
+
+	var canceled models.Object
+	require.NoError(t, db.Db.Table(canceled.TableName()).Where("object_id = ?", common.BigToHash(sdkmath.NewUint(1).BigInt())).Take(&canceled).Error)
+	require.True(t, canceled.Removed)
+	require.Equal(t, storagetypes.OBJECT_STATUS_CREATED.String(), canceled.Status)
+	require.Equal(t, models.ObjectDeleteReasonCanceled, canceled.DeleteReason)
+	require.Equal(t, int64(11), canceled.UpdateAt)
+	require.Equal(t, common.BigToHash(sdkmath.NewInt(11).BigInt()), canceled.UpdateTxHash)
+	require.Equal(t, int64(1700000011), canceled.UpdateTime)
+
+	// The name is given to a new object
+	createObject(12, 2)
+	var active int64
+	require.NoError(t, db.Db.Table(canceled.TableName()).Where("bucket_name = ? AND object_name = ? AND removed IS NOT TRUE", "bucket", "a").Count(&active).Error)
+	require.Equal(t, int64(1), active)
+
+	object, err := db.GetObjectByBucketAndName(ctx, "bucket", "a")
+	require.NoError(t, err)
+	require.Equal(t, common.BigToHash(sdkmath.NewUint(2).BigInt()), object.ObjectID)
+	require.Equal(t, storagetypes.OBJECT_STATUS_CREATED.String(), object.Status)
+}
+
+func TestBucketStats(t *testing.T) {
+	ctx := context.Background()
+	m, db := newTestModule(t)
+
+	createAndSeal := func(height int64, bucketID, objectID, payloadSize uint64) {
+		handleEvent(t, m, height, &storagetypes.EventCreateObject{
+			Creator: testCreator.String(), Owner: testCreator.String(), BucketName: "bucket", ObjectName: sdkmath.NewUint(objectID).String(),
+			BucketId: sdkmath.NewUint(bucketID), ObjectId: sdkmath.NewUint(objectID), PayloadSize: payloadSize,
+			CreateAt: 1700000000 + height, Status: storagetypes.OBJECT_STATUS_CREATED,
+		})
+		handleEvent(t, m, height+1, &storagetypes.EventSealObject{BucketName: "bucket", ObjectId: sdkmath.NewUint(objectID), Status: storagetypes.OBJECT_STATUS_SEALED})
+	}
+	requireStats := func(bucketID uint64, objectCount, storedSize int64) {
+		t.Helper()
+		stats, err := db.GetBucketStats(ctx, common.BigToHash(sdkmath.NewUint(bucketID).BigInt()))
+		require.NoError(t, err)
+		require.Equal(t, objectCount, stats.ObjectCount)
+		require.Equal(t, storedSize, stats.StoredSize)
+
+		// The maintained stats match a full recount
+		recount, err := db.RecalculateBucketStats(ctx, stats.BucketID)
+		require.NoError(t, err)
+		require.Equal(t, stats.ObjectCount, recount.ObjectCount)
+		require.Equal(t, stats.StoredSize, recount.StoredSize)
+	}
+
+	requireStats(1, 0, 0)
+	createAndSeal(10, 1, 1, 100)
+	createAndSeal(20, 1, 2, 200)
+	createAndSeal(30, 2, 3, 50)
+	// Handling a seal again doesn't count the object twice
+	handleEvent(t, m, 21, &storagetypes.EventSealObject{BucketName: "bucket", ObjectId: sdkmath.NewUint(2), Status: storagetypes.OBJECT_STATUS_SEALED})
+	requireStats(1, 2, 300)
+	requireStats(2, 1, 50)
+
+	// A created object isn't counted until sealed
+	handleEvent(t, m, 40, &storagetypes.EventCreateObject{
+		Creator: testCreator.String(), Owner: testCreator.String(), BucketName: "bucket", ObjectName: "4",
+		BucketId: sdkmath.NewUint(1), ObjectId: sdkmath.NewUint(4), PayloadSize: 400, Status: storagetypes.OBJECT_STATUS_CREATED,
+	})
+	requireStats(1, 2, 300)
+
+	handleEvent(t, m, 50, &storagetypes.EventDeleteObject{BucketName: "bucket", ObjectId: sdkmath.NewUint(1)})
+	handleEvent(t, m, 50, &storagetypes.EventDeleteObject{BucketName: "bucket", ObjectId: sdkmath.NewUint(1)})
+	requireStats(1, 1, 200)
+
+	updateContent := &storagetypes.EventUpdateObjectContentSuccess{
+		BucketName: "bucket", ObjectId: sdkmath.NewUint(2), PrevPayloadSize: 200, NewPayloadSize: 500, Version: 1,
+	}
+	handleEvent(t, m, 60, updateContent)
+	handleEvent(t, m, 60, updateContent)
+	requireStats(1, 1, 500)
+
+	// A discontinued object stays counted until the chain deletes it
+	handleEvent(t, m, 70, &storagetypes.EventDiscontinueObject{BucketName: "bucket", ObjectId: sdkmath.NewUint(2), Reason: "spam", DeleteAt: 1000})
+	requireStats(1, 1, 500)
+	handleEvent(t, m, 80, &storagetypes.EventDeleteObject{BucketName: "bucket", ObjectId: sdkmath.NewUint(2)})
+	requireStats(1, 0, 0)
+	requireStats(2, 1, 50)
+
+	// An empty object is created sealed, and counted once even if its creation is handled again
+	createEmpty := &storagetypes.EventCreateObject{
+		Creator: testCreator.String(), Owner: testCreator.String(), BucketName: "bucket", ObjectName: "5",
+		BucketId: sdkmath.NewUint(1), ObjectId: sdkmath.NewUint(5), Status: storagetypes.OBJECT_STATUS_SEALED,
+	}
+	handleEvent(t, m, 90, createEmpty)
+	handleEvent(t, m, 90, createEmpty)
+	requireStats(1, 1, 0)
+	handleEvent(t, m, 100, &storagetypes.EventDeleteObject{BucketName: "bucket", ObjectId: sdkmath.NewUint(5)})
+	requireStats(1, 0, 0)
+
+	// Drifted stats get repaired
+	bucketID := common.BigToHash(sdkmath.NewUint(2).BigInt())
+	require.NoError(t, db.AddBucketStats(ctx, bucketID, 3, 30))
+	stats, err := db.RecalculateBucketStats(ctx, bucketID)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), stats.ObjectCount)
+	require.Equal(t, int64(50), stats.StoredSize)
+	stats, err = db.GetBucketStats(ctx, bucketID)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), stats.ObjectCount)
+	require.Equal(t, int64(50), stats.StoredSize)
+}
+
+func TestDeleteBucket(t *testing.T) {
+	ctx := context.Background()
+	m, db := newTestModule(t)
+
+	bucketID := func(id uint64) common.Hash { return common.BigToHash(sdkmath.NewUint(id).BigInt()) }
+	deleteBucket := func(height int64, id uint64) {
+		handleEvent(t, m, height, &storagetypes.EventDeleteBucket{BucketId: sdkmath.NewUint(id), BucketName: "bucket"})
+	}
+	requireReset := func(id uint64) {
+		t.Helper()
+		stats, err := db.GetBucketStats(ctx, bucketID(id))
+		require.NoError(t, err)
+		require.Equal(t, int64(0), stats.ObjectCount)
+		require.Equal(t, int64(0), stats.StoredSize)
+	}
+	issues := func() []*models.BucketDeletionIssue {
+		var issues []*models.BucketDeletionIssue
+		require.NoError(t, db.Db.Table((&models.BucketDeletionIssue{}).TableName()).Order("id").Find(&issues).Error)
+		return issues
+	}
+
+	// An empty bucket
+	deleteBucket(20, 1)
+	requireReset(1)
+	require.Empty(t, issues())
+
+	// A bucket whose objects have been missed
+	for i, removed := range []bool{false, false, true} {
+		objectID := common.BigToHash(sdkmath.NewUint(uint64(i) + 1).BigInt())
+		require.NoError(t, db.SaveObject(ctx, &models.Object{
+			BucketID: bucketID(2), ObjectID: objectID, PayloadSize: 100, Removed: removed, StatsCounted: !removed,
+		}))
+	}
+	require.NoError(t, db.AddBucketStats(ctx, bucketID(2), 2, 200))
+	deleteBucket(30, 2)
+	requireReset(2)
+	require.Len(t, issues(), 1)
+	require.Equal(t, bucketID(2), issues()[0].BucketID)
+	require.Equal(t, int64(2), issues()[0].ActiveObjects)
+	require.Equal(t, int64(30), issues()[0].Height)
+
+	var counted int64
+	require.NoError(t, db.Db.Table("objects").Where("stats_counted IS TRUE").Count(&counted).Error)
+	require.Zero(t, counted)
+
+	// Handling the deletion again
+	deleteBucket(30, 2)
+	requireReset(2)
+	require.Len(t, issues(), 1)
+}
+
+func TestCopyObject(t *testing.T) {
+	ctx := context.Background()
+	m, db := newTestModule(t)
+
+	copyObject := func(height int64, srcBucket string, src uint64, dstBucket string, dst uint64) {
+		handleEvent(t, m, height, &storagetypes.EventCopyObject{
+			SrcBucketName: srcBucket, SrcObjectName: "object", SrcObjectId: sdkmath.NewUint(src),
+			DstBucketName: dstBucket, DstObjectName: "object", DstObjectId: sdkmath.NewUint(dst),
+		})
+		handleEvent(t, m, height+1, &storagetypes.EventSealObject{BucketName: dstBucket, ObjectId: sdkmath.NewUint(dst), Status: storagetypes.OBJECT_STATUS_SEALED})
+	}
+	copies := func(source uint64, limit, offset int) []common.Hash {
+		objects, err := db.ListObjectCopies(ctx, objectID(source), limit, offset)
+		require.NoError(t, err)
+		ids := make([]common.Hash, len(objects))
+		for i, object := range objects {
+			ids[i] = object.ObjectID
+		}
+		return ids
+	}
+
+	bucketID := func(id uint64) common.Hash { return common.BigToHash(sdkmath.NewUint(id).BigInt()) }
+	for id, name := range []string{"a", "b", "c"} {
+		require.NoError(t, db.SaveBucket(ctx, &models.Bucket{BucketID: bucketID(uint64(id) + 1), BucketName: name, UpdateAt: 1}))
+	}
+
+	handleEvent(t, m, 10, &storagetypes.EventCreateObject{
+		Creator: testCreator.String(), Owner: testCreator.String(), BucketName: "a", ObjectName: "object", BucketId: sdkmath.NewUint(1), ObjectId: sdkmath.NewUint(1), PayloadSize: 100,
+		Status: storagetypes.OBJECT_STATUS_CREATED,
+	})
+	handleEvent(t, m, 11, &storagetypes.EventSealObject{BucketName: "a", ObjectId: sdkmath.NewUint(1), Status: storagetypes.OBJECT_STATUS_SEALED})
+	copyObject(20, "a", 1, "b", 2)
+	copyObject(30, "b", 2, "c", 3)
+	copyObject(40, "a", 1, "c", 4)
+	// Handling a copy again leaves it untouched
+	handleEvent(t, m, 20, &storagetypes.EventCopyObject{
+		SrcBucketName: "a", SrcObjectName: "object", SrcObjectId: sdkmath.NewUint(1),
+		DstBucketName: "b", DstObjectName: "object", DstObjectId: sdkmath.NewUint(2),
+	})
+
+	copied, err := db.GetObject(ctx, objectID(3))
+	require.NoError(t, err)
+	require.NotNil(t, copied.SourceObjectID)
+	require.Equal(t, objectID(2), *copied.SourceObjectID)
+	require.Equal(t, "b", copied.SourceBucketName)
+	require.Equal(t, "c", copied.BucketName)
+	require.Equal(t, bucketID(3), copied.BucketID)
+	require.Equal(t, uint64(100), copied.PayloadSize)
+	require.Equal(t, int64(30), copied.CreateAt)
+
+	copied, err = db.GetObject(ctx, objectID(2))
+	require.NoError(t, err)
+	require.Equal(t, storagetypes.OBJECT_STATUS_SEALED.String(), copied.Status)
+	require.Equal(t, int64(21), copied.UpdateAt)
+
+	require.Equal(t, bucketID(2), copied.BucketID)
+
+	source, err := db.GetObject(ctx, objectID(1))
+	require.NoError(t, err)
+	require.Nil(t, source.SourceObjectID)
+
+	// The copies are credited to their destination bucket
+	for id, count := range map[uint64]int64{1: 1, 2: 1, 3: 2} {
+		stats, err := db.GetBucketStats(ctx, bucketID(id))
+		require.NoError(t, err)
+		require.Equal(t, count, stats.ObjectCount)
+		require.Equal(t, 100*count, stats.StoredSize)
+	}
+
+	require.Equal(t, []common.Hash{objectID(2), objectID(4)}, copies(1, 10, 0))
+	require.Equal(t, []common.Hash{objectID(4)}, copies(1, 1, 1))
+	require.Equal(t, []common.Hash{objectID(3)}, copies(2, 10, 0))
+	require.Empty(t, copies(3, 10, 0))
+
+	// Deleting the source leaves its copies
+	handleEvent(t, m, 50, &storagetypes.EventDeleteObject{BucketName: "a", ObjectId: sdkmath.NewUint(1)})
+	_, err = db.GetObject(ctx, objectID(1))
+	require.ErrorIs(t, err, database.ErrObjectNotFound)
+	require.Equal(t, []common.Hash{objectID(2), objectID(4)}, copies(1, 10, 0))
+
+	// Deleted copies are not listed
+	handleEvent(t, m, 60, &storagetypes.EventDeleteObject{BucketName: "c", ObjectId: sdkmath.NewUint(4)})
+	require.Equal(t, []common.Hash{objectID(2)}, copies(1, 10, 0))
+
+	// Handling a copy again once deleted leaves it deleted
+	handleEvent(t, m, 40, &storagetypes.EventCopyObject{
+		SrcBucketName: "a", SrcObjectName: "object", SrcObjectId: sdkmath.NewUint(1),
+		DstBucketName: "c", DstObjectName: "object", DstObjectId: sdkmath.NewUint(4),
+	})
+	_, err = db.GetObject(ctx, objectID(4))
+	require.ErrorIs(t, err, database.ErrObjectNotFound)
+
+	// A copy handled after its source got deleted
+	copyObject(70, "a", 1, "b", 5)
+	copied, err = db.GetObject(ctx, objectID(5))
+	require.NoError(t, err)
+	require.Equal(t, bucketID(2), copied.BucketID)
+	require.Equal(t, uint64(100), copied.PayloadSize)
+}
+
+func TestSealObject(t *testing.T) {
+	ctx := context.Background()
+	m, db := newTestModule(t)
+
+	createObject := func(height int64, id, payloadSize uint64) {
+		handleEvent(t, m, height, &storagetypes.EventCreateObject{
+			Creator: testCreator.String(), Owner: testCreator.String(), BucketName: "bucket", ObjectName: sdkmath.NewUint(id).String(),
+			BucketId: sdkmath.NewUint(1), ObjectId: sdkmath.NewUint(id), PayloadSize: payloadSize,
+			CreateAt: 1700000000 + height, Status: storagetypes.OBJECT_STATUS_CREATED,
+		})
+	}
+	sealObject := func(height int64, id uint64) {
+		handleEvent(t, m, height, &storagetypes.EventSealObject{
+			BucketName: "bucket", ObjectName: sdkmath.NewUint(id).String(), ObjectId: sdkmath.NewUint(id),
+			Status: storagetypes.OBJECT_STATUS_SEALED, LocalVirtualGroupId: 1,
+		})
+	}
+	requireStoredSize := func(lvgSize, gvgSize uint64) {
+		t.Helper()
+		lvg, err := db.GetLVG(ctx, objectID(1), 1)
+		require.NoError(t, err)
+		require.Equal(t, lvgSize, lvg.StoredSize)
+		gvg, err := db.GetGVGByID(ctx, 7)
+		require.NoError(t, err)
+		require.Equal(t, gvgSize, gvg.StoredSize)
+	}
+
+	require.NoError(t, db.SaveLVG(ctx, &models.LocalVirtualGroup{LocalVirtualGroupId: 1, GlobalVirtualGroupId: 7, BucketID: objectID(1), UpdateAt: 5}))
+	require.NoError(t, db.SaveGVG(ctx, &models.GlobalVirtualGroup{GlobalVirtualGroupId: 7, TotalDeposit: (*common.Big)(big.NewInt(0)), UpdateAt: 5}))
+
+	// Created then sealed
+	createObject(10, 1, 100)
+	sealObject(11, 1)
+	sealObject(11, 1)
+	object, err := db.GetObject(ctx, objectID(1))
+	require.NoError(t, err)
+	require.Equal(t, storagetypes.OBJECT_STATUS_SEALED.String(), object.Status)
+	require.Equal(t, uint32(1), object.LocalVirtualGroupId)
+	require.Equal(t, int64(11), object.SealedAt)
+	require.Equal(t, int64(1700000011), object.SealedTime)
+	require.Equal(t, common.BigToHash(sdkmath.NewInt(11).BigInt()), object.SealedTxHash)
+	requireStoredSize(100, 100)
+
+	// Sealed before the creation is handled
+	sealObject(21, 2)
+	object, err = db.GetObject(ctx, objectID(2))
+	require.NoError(t, err)
+	require.Equal(t, storagetypes.OBJECT_STATUS_SEALED.String(), object.Status)
+	require.Equal(t, int64(0), object.CreateAt)
+	requireStoredSize(100, 100)
+
+	createObject(20, 2, 50)
+	object, err = db.GetObject(ctx, objectID(2))
+	require.NoError(t, err)
+	require.Equal(t, storagetypes.OBJECT_STATUS_SEALED.String(), object.Status)
+	require.Equal(t, testCreator, object.Creator)
+	require.Equal(t, objectID(1), object.BucketID)
+	require.Equal(t, uint64(50), object.PayloadSize)
+	require.Equal(t, int64(20), object.CreateAt)
+	require.Equal(t, int64(21), object.SealedAt)
+	require.Equal(t, int64(21), object.UpdateAt)
+	requireStoredSize(150, 150)
+	// Handling the creation again doesn't count the object twice
+	createObject(20, 2, 50)
+	requireStoredSize(150, 150)
+
+	stats, err := db.GetBucketStats(ctx, objectID(1))
+	require.NoError(t, err)
+	require.Equal(t, int64(2), stats.ObjectCount)
+	require.Equal(t, int64(150), stats.StoredSize)
+
+	// The stored sizes emitted by the chain along with the seal are kept
+	_, err = db.UpdateLVGAt(ctx, 31, &models.LocalVirtualGroup{LocalVirtualGroupId: 1, BucketID: objectID(1), StoredSize: 400, UpdateAt: 31}, "stored_size", "update_at")
+	require.NoError(t, err)
+	createObject(30, 3, 250)
+	sealObject(31, 3)
+	lvg, err := db.GetLVG(ctx, objectID(1), 1)
+	require.NoError(t, err)
+	require.Equal(t, uint64(400), lvg.StoredSize)
+
+	// Rejected after the creation
+	createObject(40, 4, 10)
+	handleEvent(t, m, 41, &storagetypes.EventRejectSealObject{BucketName: "bucket", ObjectName: "4", ObjectId: sdkmath.NewUint(4)})
+	_, err = db.GetObject(ctx, objectID(4))
+	require.ErrorIs(t, err, database.ErrObjectNotFound)
+	object, err = db.GetObjectWithRemoved(ctx, objectID(4))
+	require.NoError(t, err)
+	require.True(t, object.Removed)
+	require.Equal(t, storagetypes.OBJECT_STATUS_CREATED.String(), object.Status)
+	require.Equal(t, models.ObjectDeleteReasonSealRejected, object.DeleteReason)
+	require.Equal(t, uint64(10), object.PayloadSize)
+	require.Equal(t, int64(41), object.UpdateAt)
+}
+
+func TestSealObjectMissingDependencies(t *testing.T) {
+	ctx := context.Background()
+	// The permission and virtual group tables don't exist without their modules
+	db := sqlitetest.NewDatabase(t, &models.Object{}, &models.BucketStats{})
+	m := NewModule(db)
+	m.SetMissingDependencies([]string{permission.ModuleName, virtualgroup.ModuleName})
+
+	for i, event := range []proto.Message{
+		&storagetypes.EventCreateObject{
+			Creator: testCreator.String(), Owner: testCreator.String(), BucketName: "bucket",
+			ObjectName: "1", BucketId: sdkmath.NewUint(1), ObjectId: sdkmath.NewUint(1), PayloadSize: 100,
+			Status: storagetypes.OBJECT_STATUS_CREATED,
+		},
+		&storagetypes.EventSealObject{
+			BucketName: "bucket", ObjectName: "1", ObjectId: sdkmath.NewUint(1), Status: storagetypes.OBJECT_STATUS_SEALED,
+			LocalVirtualGroupId: 1,
+		},
+		&storagetypes.EventCreateObject{
+			Creator: testCreator.String(), Owner: testCreator.String(), BucketName: "bucket",
+			ObjectName: "2", BucketId: sdkmath.NewUint(1), ObjectId: sdkmath.NewUint(2),
+			Status: storagetypes.OBJECT_STATUS_SEALED,
+		},
+		&storagetypes.EventDeleteObject{BucketName: "bucket", ObjectName: "2", ObjectId: sdkmath.NewUint(2)},
+	} {
+		handleEvent(t, m, int64(10+i), event)
+	}
+
+	stats, err := db.GetBucketStats(ctx, common.BigToHash(sdkmath.NewUint(1).BigInt()))
+	require.NoError(t, err)
+	require.Equal(t, int64(1), stats.ObjectCount)
+	require.Equal(t, int64(100), stats.StoredSize)
+}
+
+func TestEffectiveObjectVisibility(t *testing.T) {
+	ctx := context.Background()
+	m, db := newTestModule(t)
+	require.NoError(t, db.PrepareTables(ctx, []schema.Tabler{&models.Bucket{}}))
+
+	setBucketVisibility := func(visibility storagetypes.VisibilityType) {
+		require.NoError(t, db.Db.Exec("UPDATE buckets SET visibility = ?", visibility.String()).Error)
+	}
+	requireVisibility := func(id uint64, visibility storagetypes.VisibilityType) {
+		t.Helper()
+		effective, err := m.GetEffectiveObjectVisibility(ctx, objectID(id))
+		require.NoError(t, err)
+		require.Equal(t, visibility.String(), effective)
+	}
+
+	require.NoError(t, db.SaveBucket(ctx, &models.Bucket{
+		BucketID: objectID(1), BucketName: "bucket", Visibility: storagetypes.VISIBILITY_TYPE_PUBLIC_READ.String(),
+	}))
+	for id, visibility := range map[uint64]storagetypes.VisibilityType{
+		1: storagetypes.VISIBILITY_TYPE_PRIVATE, 2: storagetypes.VISIBILITY_TYPE_INHERIT, 3: storagetypes.VISIBILITY_TYPE_INHERIT,
+	} {
+		handleEvent(t, m, 10, &storagetypes.EventCreateObject{
+			Creator: testCreator.String(), Owner: testCreator.String(), BucketName: "bucket", ObjectName: sdkmath.NewUint(id).String(),
+			BucketId: sdkmath.NewUint(1), ObjectId: sdkmath.NewUint(id), Visibility: visibility, Status: storagetypes.OBJECT_STATUS_CREATED,
+		})
+	}
+
+	requireVisibility(1, storagetypes.VISIBILITY_TYPE_PRIVATE)
+	requireVisibility(2, storagetypes.VISIBILITY_TYPE_PUBLIC_READ)
+	requireVisibility(3, storagetypes.VISIBILITY_TYPE_PUBLIC_READ)
+
+	// The bucket changing is seen right away
+	setBucketVisibility(storagetypes.VISIBILITY_TYPE_PRIVATE)
+	requireVisibility(2, storagetypes.VISIBILITY_TYPE_PRIVATE)
+	requireVisibility(3, storagetypes.VISIBILITY_TYPE_PRIVATE)
+
+	// A batch reads the bucket once for all its objects
+	bucketReads := 0
+	require.NoError(t, db.Db.Callback().Query().Before("gorm:query").Register("test:bucket_reads", func(tx *gorm.DB) {
+		if tx.Statement.Table == (&models.Bucket{}).TableName() {
+			bucketReads++
+		}
+	}))
+	visibilities, err := m.GetEffectiveObjectVisibilities(ctx, []common.Hash{objectID(1), objectID(2), objectID(3)})
+	require.NoError(t, err)
+	require.Equal(t, map[common.Hash]string{
+		objectID(1): storagetypes.VISIBILITY_TYPE_PRIVATE.String(),
+		objectID(2): storagetypes.VISIBILITY_TYPE_PRIVATE.String(),
+		objectID(3): storagetypes.VISIBILITY_TYPE_PRIVATE.String(),
+	}, visibilities)
+	require.Equal(t, 1, bucketReads)
+
+	// and never keeps it past the call
+	setBucketVisibility(storagetypes.VISIBILITY_TYPE_PUBLIC_READ)
+	visibilities, err = m.GetEffectiveObjectVisibilities(ctx, []common.Hash{objectID(2), objectID(3)})
+	require.NoError(t, err)
+	require.Equal(t, storagetypes.VISIBILITY_TYPE_PUBLIC_READ.String(), visibilities[objectID(2)])
+	require.Equal(t, storagetypes.VISIBILITY_TYPE_PUBLIC_READ.String(), visibilities[objectID(3)])
+	require.Equal(t, 2, bucketReads)
+
+	// The object inherits the visibility of its bucket again
+	handleEvent(t, m, 11, &storagetypes.EventUpdateObjectInfo{BucketName: "bucket", ObjectName: "1", ObjectId: sdkmath.NewUint(1), Visibility: storagetypes.VISIBILITY_TYPE_INHERIT})
+	requireVisibility(1, storagetypes.VISIBILITY_TYPE_PUBLIC_READ)
+	requireVisibility(2, storagetypes.VISIBILITY_TYPE_PUBLIC_READ)
+
+	object, err := db.GetObject(ctx, objectID(1))
+	require.NoError(t, err)
+	require.Equal(t, storagetypes.VISIBILITY_TYPE_INHERIT.String(), object.Visibility)
+	require.Equal(t, int64(11), object.UpdateAt)
+	require.Equal(t, common.BigToHash(sdkmath.NewInt(11).BigInt()), object.UpdateTxHash)
+
+	// Back to private
+	handleEvent(t, m, 12, &storagetypes.EventUpdateObjectInfo{BucketName: "bucket", ObjectName: "1", ObjectId: sdkmath.NewUint(1), Visibility: storagetypes.VISIBILITY_TYPE_PRIVATE})
+	requireVisibility(1, storagetypes.VISIBILITY_TYPE_PRIVATE)
+
+	_, err = m.GetEffectiveObjectVisibility(ctx, objectID(4))
+	require.ErrorIs(t, err, database.ErrObjectNotFound)
+}
+
+func TestSealObjectChecksums(t *testing.T) {
+	ctx := context.Background()
+	m, db := newTestModule(t)
+
+	createObject := func(height int64, id uint64, checksums [][]byte) {
+		handleEvent(t, m, height, &storagetypes.EventCreateObject{
+			Creator: testCreator.String(), Owner: testCreator.String(), BucketName: "bucket", ObjectName: sdkmath.NewUint(id).String(),
+			BucketId: sdkmath.NewUint(1), ObjectId: sdkmath.NewUint(id), PayloadSize: 1, Checksums: checksums,
+			Status: storagetypes.OBJECT_STATUS_CREATED,
+		})
+	}
+	sealObject := func(height int64, id uint64, checksums [][]byte) {
+		handleEvent(t, m, height, &storagetypes.EventSealObject{
+			BucketName: "bucket", ObjectName: sdkmath.NewUint(id).String(), ObjectId: sdkmath.NewUint(id),
+			Status: storagetypes.OBJECT_STATUS_SEALED, Checksums: checksums,
+		})
+	}
+	newChecksums := func(seed byte) [][]byte {
+		checksums := make([][]byte, 7)
+		for i := range checksums {
+			checksums[i] = bytes.Repeat([]byte{seed + byte(i)}, 32)
+		}
+		return checksums
+	}
+	requireChecksums := func(id uint64, checksums [][]byte) {
+		t.Helper()
+		stored, err := db.GetObjectChecksums(ctx, objectID(id))
+		require.NoError(t, err)
+		require.Equal(t, checksums, stored)
+	}
+
+	// Created by its storage provider, the checksums come along with the seal
+	createObject(10, 1, nil)
+	sealObject(11, 1, newChecksums(0x10))
+	requireChecksums(1, newChecksums(0x10))
+
+	// The seal carrying none leaves the ones of the creation
+	createObject(20, 2, newChecksums(0x20))
+	sealObject(21, 2, nil)
+	requireChecksums(2, newChecksums(0x20))
+
+	// Sealed before the creation is handled
+	sealObject(31, 3, newChecksums(0x30))
+	createObject(30, 3, nil)
+	requireChecksums(3, newChecksums(0x30))
+}
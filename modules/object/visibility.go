@@ -0,0 +1,57 @@
+package object
+
+import (
+	"context"
+
+	storagetypes "github.com/evmos/evmos/v12/x/storage/types"
+
+	"github.com/forbole/juno/v4/common"
+)
+
+// GetEffectiveObjectVisibility returns the visibility of the object, not removed, having the given id, which is
+// the one of its bucket when the object inherits it.
+// database.ErrObjectNotFound or database.ErrBucketNotFound is returned if the object or its bucket doesn't exist.
+func (m *Module) GetEffectiveObjectVisibility(ctx context.Context, objectID common.Hash) (string, error) {
+	return m.effectiveObjectVisibility(ctx, objectID, nil)
+}
+
+// GetEffectiveObjectVisibilities returns the effective visibilities of the objects, not removed, having the given
+// ids, keyed by object id. The visibility of a bucket is read once for all its objects inheriting it, and never
+// outlives the call.
+// database.ErrObjectNotFound or database.ErrBucketNotFound is returned if an object or its bucket doesn't exist.
+func (m *Module) GetEffectiveObjectVisibilities(ctx context.Context, objectIDs []common.Hash) (map[common.Hash]string, error) {
+	buckets := make(map[common.Hash]string)
+	visibilities := make(map[common.Hash]string, len(objectIDs))
+	for _, objectID := range objectIDs {
+		visibility, err := m.effectiveObjectVisibility(ctx, objectID, buckets)
+		if err != nil {
+			return nil, err
+		}
+		visibilities[objectID] = visibility
+	}
+	return visibilities, nil
+}
+
+// effectiveObjectVisibility returns the effective visibility of the given object, looking for the visibility of its
+// bucket in buckets first when not nil, and adding it there once read
+func (m *Module) effectiveObjectVisibility(ctx context.Context, objectID common.Hash, buckets map[common.Hash]string) (string, error) {
+	object, err := m.db.GetObject(ctx, objectID)
+	if err != nil {
+		return "", err
+	}
+	if object.Visibility != storagetypes.VISIBILITY_TYPE_INHERIT.String() {
+		return object.Visibility, nil
+	}
+
+	if visibility, ok := buckets[object.BucketID]; ok {
+		return visibility, nil
+	}
+	bucket, err := m.db.GetBucketByID(ctx, object.BucketID)
+	if err != nil {
+		return "", err
+	}
+	if buckets != nil {
+		buckets[object.BucketID] = bucket.Visibility
+	}
+	return bucket.Visibility, nil
+}
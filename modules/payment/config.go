@@ -0,0 +1,60 @@
+package payment
+
+import (
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultReconcileBatchSize is the number of stream records compared against the chain by each reconciliation
+// when no other one is configured
+const DefaultReconcileBatchSize = 100
+
+type Config struct {
+	// History tells whether every update of the stream records is recorded into the stream record history
+	History bool `yaml:"stream_record_history"`
+
+	// HistoryRetention is how long the stream record history is kept, the older updates getting pruned
+	// periodically. It is kept forever when zero.
+	HistoryRetention time.Duration `yaml:"stream_record_history_retention"`
+
+	// ReconcileInterval is the number of minutes between two reconciliations of the stream records against the
+	// chain. They are not reconciled when zero.
+	ReconcileInterval int `yaml:"reconcile_interval"`
+
+	// ReconcileBatchSize is the number of stream records compared against the chain by each reconciliation
+	ReconcileBatchSize int `yaml:"reconcile_batch_size"`
+
+	// ReconcileRepair tells whether the stream records diverging from the chain are overwritten by the chain state
+	ReconcileRepair bool `yaml:"reconcile_repair"`
+}
+
+// NewConfig allows to build a new Config instance
+func NewConfig(history bool, historyRetention time.Duration) *Config {
+	return &Config{
+		History:          history,
+		HistoryRetention: historyRetention,
+
+		ReconcileBatchSize: DefaultReconcileBatchSize,
+	}
+}
+
+// ParseConfig reads the payment config, returning the default one when not set
+func ParseConfig(bz []byte) (*Config, error) {
+	type T struct {
+		Config *Config `yaml:"payment"`
+	}
+	var cfg T
+	err := yaml.Unmarshal(bz, &cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Config == nil {
+		cfg.Config = NewConfig(false, 0)
+	}
+	if cfg.Config.ReconcileBatchSize <= 0 {
+		cfg.Config.ReconcileBatchSize = DefaultReconcileBatchSize
+	}
+	return cfg.Config, nil
+}
@@ -0,0 +1,33 @@
+package payment_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/forbole/juno/v4/modules/payment"
+)
+
+func TestParseConfig(t *testing.T) {
+	cfg, err := payment.ParseConfig([]byte(`
+payment:
+  stream_record_history: true
+  stream_record_history_retention: 720h
+  reconcile_interval: 60
+  reconcile_repair: true
+`))
+	require.NoError(t, err)
+	require.True(t, cfg.History)
+	require.Equal(t, 720*time.Hour, cfg.HistoryRetention)
+	require.Equal(t, 60, cfg.ReconcileInterval)
+	require.Equal(t, payment.DefaultReconcileBatchSize, cfg.ReconcileBatchSize)
+	require.True(t, cfg.ReconcileRepair)
+
+	cfg, err = payment.ParseConfig([]byte(`invalid_field: yes`))
+	require.NoError(t, err)
+	require.False(t, cfg.History)
+	require.Zero(t, cfg.HistoryRetention)
+	require.Zero(t, cfg.ReconcileInterval)
+	require.False(t, cfg.ReconcileRepair)
+}
@@ -0,0 +1,191 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	paymenttypes "github.com/evmos/evmos/v12/x/payment/types"
+	"github.com/go-co-op/gocron"
+
+	"github.com/forbole/juno/v4/common"
+	"github.com/forbole/juno/v4/database"
+	"github.com/forbole/juno/v4/log"
+	"github.com/forbole/juno/v4/models"
+	"github.com/forbole/juno/v4/node/remote"
+)
+
+// HistoryPruneInterval is the interval, in minutes, between two prunings of the stream record history
+const HistoryPruneInterval = 60
+
+// RegisterPeriodicOperations implements modules.PeriodicOperationsModule
+func (m *Module) RegisterPeriodicOperations(scheduler *gocron.Scheduler) error {
+	log.Debugw("setting up periodic tasks", "module", ModuleName)
+
+	if m.cfg.History && m.cfg.HistoryRetention > 0 {
+		_, err := scheduler.Every(HistoryPruneInterval).Minutes().Do(func() {
+			if err := m.pruneHistory(context.Background(), time.Now()); err != nil {
+				log.Errorw("failed to prune the stream record history", "module", ModuleName, "err", err)
+			}
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if m.cfg.ReconcileInterval > 0 {
+		if m.client == nil {
+			log.Warnw("the node exposes no gRPC connection, the stream records won't be reconciled", "module", ModuleName)
+			return nil
+		}
+
+		_, err := scheduler.Every(m.cfg.ReconcileInterval).Minutes().Do(func() {
+			if err := m.reconcile(database.ReadFromPrimary(context.Background()), time.Now()); err != nil {
+				log.Errorw("failed to reconcile the stream records", "module", ModuleName, "err", err)
+			}
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pruneHistory deletes the updates of the stream records older than the retention of the history at the given time
+func (m *Module) pruneHistory(ctx context.Context, now time.Time) error {
+	deleted, err := m.db.DeleteStreamRecordHistoryBefore(ctx, now.Add(-m.cfg.HistoryRetention).Unix())
+	if err != nil {
+		return fmt.Errorf("failed to delete the stream record history: %s", err)
+	}
+
+	log.Debugw("pruned the stream record history", "module", ModuleName, "deleted", deleted)
+	return nil
+}
+
+// reconcile compares the next batch of stream records, going round-robin over all of them, against the chain state
+// at the epoch, and records the fields diverging. When repairing, the diverging stream records are overwritten by
+// the chain state.
+func (m *Module) reconcile(ctx context.Context, now time.Time) error {
+	m.reconcileMu.Lock()
+	defer m.reconcileMu.Unlock()
+
+	// The last stored height may be past heights still being processed, unlike the epoch
+	epoch, err := m.db.GetEpoch(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get the epoch: %s", err)
+	}
+	height := epoch.BlockHeight
+	// Without an epoch stored, the last stored height stands for it
+	if height == 0 {
+		lastHeight, err := m.db.GetLastBlockHeight(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get the last block height: %s", err)
+		}
+		height = int64(lastHeight)
+	}
+	if height == 0 {
+		log.Debugw("no block stored yet, skipping the reconciliation", "module", ModuleName)
+		return nil
+	}
+
+	streamRecords, err := m.db.ListStreamRecords(ctx, m.reconcileAfter, m.cfg.ReconcileBatchSize)
+	if err == nil && len(streamRecords) == 0 && m.reconcileAfter > 0 {
+		// Every stream record has been reconciled, start over
+		streamRecords, err = m.db.ListStreamRecords(ctx, 0, m.cfg.ReconcileBatchSize)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to list the stream records: %s", err)
+	}
+	if len(streamRecords) < m.cfg.ReconcileBatchSize {
+		m.reconcileAfter = 0
+	} else {
+		m.reconcileAfter = streamRecords[len(streamRecords)-1].ID
+	}
+
+	// The chain is queried at the epoch, so that the updates not indexed yet aren't reported
+	queryCtx := remote.GetHeightRequestContext(ctx, height)
+
+	var issues []*models.ReconciliationIssue
+	for _, streamRecord := range streamRecords {
+		res, err := m.client.StreamRecord(queryCtx, &paymenttypes.QueryGetStreamRecordRequest{Account: streamRecord.Account.String()})
+		if err != nil {
+			log.Warnw("failed to query the stream record", "module", ModuleName, "account", streamRecord.Account, "err", err)
+			continue
+		}
+
+		// The stream record may have been updated past the height queried, by a height processed out of order
+		if streamRecord.CrudTimestamp > res.StreamRecord.CrudTimestamp {
+			continue
+		}
+
+		recordIssues := compareStreamRecord(streamRecord, &res.StreamRecord)
+		if len(recordIssues) == 0 {
+			continue
+		}
+
+		repaired := false
+		if m.cfg.ReconcileRepair {
+			if err := m.db.RepairStreamRecord(ctx, chainStreamRecord(streamRecord.Account, &res.StreamRecord)); err != nil {
+				log.Errorw("failed to repair the stream record", "module", ModuleName, "account", streamRecord.Account, "err", err)
+			} else {
+				repaired = true
+			}
+		}
+
+		for _, issue := range recordIssues {
+			issue.Height = height
+			issue.Repaired = repaired
+			issue.CreateTime = now.Unix()
+			log.ReconciliationIssues.WithLabelValues(issue.Field).Inc()
+			log.Warnw("stream record diverging from the chain", "module", ModuleName, "account", issue.Account,
+				"field", issue.Field, "db", issue.DBValue, "chain", issue.ChainValue)
+		}
+		issues = append(issues, recordIssues...)
+	}
+
+	return m.db.SaveReconciliationIssues(ctx, issues)
+}
+
+// compareStreamRecord returns the issues of the fields of the given stream record diverging from the chain one
+func compareStreamRecord(streamRecord *models.StreamRecord, chain *paymenttypes.StreamRecord) []*models.ReconciliationIssue {
+	var issues []*models.ReconciliationIssue
+	compare := func(field, dbValue, chainValue string) {
+		if dbValue != chainValue {
+			issues = append(issues, &models.ReconciliationIssue{
+				Account:    streamRecord.Account,
+				Field:      field,
+				DBValue:    dbValue,
+				ChainValue: chainValue,
+			})
+		}
+	}
+
+	compare("static_balance", bigString(streamRecord.StaticBalance), chain.StaticBalance.String())
+	compare("netflow_rate", bigString(streamRecord.NetflowRate), chain.NetflowRate.String())
+	compare("status", streamRecord.Status, chain.Status.String())
+	return issues
+}
+
+// chainStreamRecord returns the stream record of the given account holding the given chain state
+func chainStreamRecord(account common.Address, chain *paymenttypes.StreamRecord) *models.StreamRecord {
+	return &models.StreamRecord{
+		Account:           account,
+		CrudTimestamp:     chain.CrudTimestamp,
+		NetflowRate:       (*common.Big)(chain.NetflowRate.BigInt()),
+		FrozenNetflowRate: (*common.Big)(chain.FrozenNetflowRate.BigInt()),
+		StaticBalance:     (*common.Big)(chain.StaticBalance.BigInt()),
+		BufferBalance:     (*common.Big)(chain.BufferBalance.BigInt()),
+		LockBalance:       (*common.Big)(chain.LockBalance.BigInt()),
+		Status:            chain.Status.String(),
+		SettleTimestamp:   chain.SettleTimestamp,
+		OutFlowCount:      chain.OutFlowCount,
+	}
+}
+
+// bigString returns the decimal representation of the given value, a missing one being zero
+func bigString(value *common.Big) string {
+	if value == nil {
+		return "0"
+	}
+	return value.Raw().String()
+}
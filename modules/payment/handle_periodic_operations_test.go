@@ -0,0 +1,192 @@
+package payment
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	paymenttypes "github.com/evmos/evmos/v12/x/payment/types"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"gorm.io/gorm/schema"
+
+	"github.com/forbole/juno/v4/common"
+	"github.com/forbole/juno/v4/models"
+)
+
+// mockQueryClient serves the stream records and the out flows it holds, by account
+type mockQueryClient struct {
+	paymenttypes.QueryClient
+
+	streamRecords map[string]paymenttypes.StreamRecord
+	outFlows      map[string][]paymenttypes.OutFlow
+	outFlowsErr   error
+}
+
+func (c *mockQueryClient) StreamRecord(_ context.Context, req *paymenttypes.QueryGetStreamRecordRequest, _ ...grpc.CallOption) (*paymenttypes.QueryGetStreamRecordResponse, error) {
+	return &paymenttypes.QueryGetStreamRecordResponse{StreamRecord: c.streamRecords[req.Account]}, nil
+}
+
+func (c *mockQueryClient) OutFlows(_ context.Context, req *paymenttypes.QueryOutFlowsRequest, _ ...grpc.CallOption) (*paymenttypes.QueryOutFlowsResponse, error) {
+	if c.outFlowsErr != nil {
+		return nil, c.outFlowsErr
+	}
+	return &paymenttypes.QueryOutFlowsResponse{OutFlows: c.outFlows[req.Account]}, nil
+}
+
+func TestReconcile(t *testing.T) {
+	ctx := context.Background()
+	m, db := newTestModule(t)
+	require.NoError(t, db.PrepareTables(ctx, []schema.Tabler{&models.Epoch{}}))
+	require.NoError(t, db.SaveEpoch(ctx, &models.Epoch{OneRowId: true, BlockHeight: 15}))
+
+	alice, bob := common.HexToAddress("0x01"), common.HexToAddress("0x02")
+	for _, account := range []common.Address{alice, bob} {
+		require.NoError(t, m.handleEventStreamRecordUpdate(ctx, newTestBlock(10), common.Hash{}, &paymenttypes.EventStreamRecordUpdate{
+			Account:           account.String(),
+			CrudTimestamp:     1700000000,
+			NetflowRate:       sdk.NewInt(-20),
+			FrozenNetflowRate: sdk.ZeroInt(),
+			StaticBalance:     sdk.NewInt(100),
+			BufferBalance:     sdk.ZeroInt(),
+			LockBalance:       sdk.ZeroInt(),
+			Status:            paymenttypes.STREAM_ACCOUNT_STATUS_ACTIVE,
+		}))
+	}
+
+	chainRecord := func(account common.Address, staticBalance int64) paymenttypes.StreamRecord {
+		return paymenttypes.StreamRecord{
+			Account:           account.String(),
+			CrudTimestamp:     1700000100,
+			NetflowRate:       sdk.NewInt(-20),
+			FrozenNetflowRate: sdk.ZeroInt(),
+			StaticBalance:     sdk.NewInt(staticBalance),
+			BufferBalance:     sdk.ZeroInt(),
+			LockBalance:       sdk.ZeroInt(),
+			Status:            paymenttypes.STREAM_ACCOUNT_STATUS_ACTIVE,
+		}
+	}
+	// The balance of bob missed an update
+	m.client = &mockQueryClient{streamRecords: map[string]paymenttypes.StreamRecord{
+		alice.String(): chainRecord(alice, 100),
+		bob.String():   chainRecord(bob, 40),
+	}}
+	m.cfg.ReconcileBatchSize = 1
+
+	// One stream record is reconciled at a time
+	require.NoError(t, m.reconcile(ctx, time.Unix(1700000200, 0)))
+	issues, err := db.ListReconciliationIssues(ctx, bob, 10)
+	require.NoError(t, err)
+	require.Empty(t, issues)
+
+	require.NoError(t, m.reconcile(ctx, time.Unix(1700000300, 0)))
+	issues, err = db.ListReconciliationIssues(ctx, bob, 10)
+	require.NoError(t, err)
+	require.Len(t, issues, 1)
+	require.Equal(t, "static_balance", issues[0].Field)
+	require.Equal(t, "100", issues[0].DBValue)
+	require.Equal(t, "40", issues[0].ChainValue)
+	require.False(t, issues[0].Repaired)
+	require.Equal(t, int64(1700000300), issues[0].CreateTime)
+	require.Equal(t, int64(15), issues[0].Height)
+
+	issues, err = db.ListReconciliationIssues(ctx, alice, 10)
+	require.NoError(t, err)
+	require.Empty(t, issues)
+
+	// Once repaired, the stream record no longer diverges
+	m.cfg.ReconcileBatchSize = 10
+	m.cfg.ReconcileRepair = true
+	require.NoError(t, m.reconcile(ctx, time.Unix(1700000400, 0)))
+	issues, err = db.ListReconciliationIssues(ctx, bob, 10)
+	require.NoError(t, err)
+	require.Len(t, issues, 2)
+	require.True(t, issues[0].Repaired)
+
+	streamRecords, err := db.ListStreamRecords(ctx, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, streamRecords, 2)
+	require.Equal(t, bob, streamRecords[1].Account)
+	require.Equal(t, int64(40), streamRecords[1].StaticBalance.Raw().Int64())
+	require.Equal(t, int64(1700000100), streamRecords[1].CrudTimestamp)
+
+	require.NoError(t, m.reconcile(ctx, time.Unix(1700000500, 0)))
+	issues, err = db.ListReconciliationIssues(ctx, bob, 10)
+	require.NoError(t, err)
+	require.Len(t, issues, 2)
+
+	// A stream record updated past the height queried is neither reported nor repaired
+	require.NoError(t, m.handleEventStreamRecordUpdate(ctx, newTestBlock(20), common.Hash{}, &paymenttypes.EventStreamRecordUpdate{
+		Account:           alice.String(),
+		CrudTimestamp:     1700000200,
+		NetflowRate:       sdk.NewInt(-20),
+		FrozenNetflowRate: sdk.ZeroInt(),
+		StaticBalance:     sdk.NewInt(50),
+		BufferBalance:     sdk.ZeroInt(),
+		LockBalance:       sdk.ZeroInt(),
+		Status:            paymenttypes.STREAM_ACCOUNT_STATUS_ACTIVE,
+	}))
+	require.NoError(t, m.reconcile(ctx, time.Unix(1700000600, 0)))
+	issues, err = db.ListReconciliationIssues(ctx, alice, 10)
+	require.NoError(t, err)
+	require.Empty(t, issues)
+
+	// Nor overwritten by an older state
+	require.NoError(t, db.RepairStreamRecord(ctx, chainStreamRecord(alice, &paymenttypes.StreamRecord{
+		CrudTimestamp:     1700000100,
+		NetflowRate:       sdk.NewInt(-20),
+		FrozenNetflowRate: sdk.ZeroInt(),
+		StaticBalance:     sdk.NewInt(100),
+		BufferBalance:     sdk.ZeroInt(),
+		LockBalance:       sdk.ZeroInt(),
+	})))
+	streamRecords, err = db.ListStreamRecords(ctx, 0, 10)
+	require.NoError(t, err)
+	require.Equal(t, alice, streamRecords[0].Account)
+	require.Equal(t, int64(50), streamRecords[0].StaticBalance.Raw().Int64())
+}
+
+func TestReconcileWithoutEpoch(t *testing.T) {
+	ctx := context.Background()
+	m, db := newTestModule(t)
+	require.NoError(t, db.PrepareTables(ctx, []schema.Tabler{&models.Epoch{}, &models.Block{}}))
+
+	alice := common.HexToAddress("0x01")
+	require.NoError(t, m.handleEventStreamRecordUpdate(ctx, newTestBlock(10), common.Hash{}, &paymenttypes.EventStreamRecordUpdate{
+		Account:           alice.String(),
+		CrudTimestamp:     1700000000,
+		NetflowRate:       sdk.NewInt(-20),
+		FrozenNetflowRate: sdk.ZeroInt(),
+		StaticBalance:     sdk.NewInt(100),
+		BufferBalance:     sdk.ZeroInt(),
+		LockBalance:       sdk.ZeroInt(),
+		Status:            paymenttypes.STREAM_ACCOUNT_STATUS_ACTIVE,
+	}))
+	m.client = &mockQueryClient{streamRecords: map[string]paymenttypes.StreamRecord{
+		alice.String(): {
+			Account:           alice.String(),
+			CrudTimestamp:     1700000000,
+			NetflowRate:       sdk.NewInt(-20),
+			FrozenNetflowRate: sdk.ZeroInt(),
+			StaticBalance:     sdk.NewInt(40),
+			BufferBalance:     sdk.ZeroInt(),
+			LockBalance:       sdk.ZeroInt(),
+			Status:            paymenttypes.STREAM_ACCOUNT_STATUS_ACTIVE,
+		},
+	}}
+
+	// Nothing is stored yet
+	require.NoError(t, m.reconcile(ctx, time.Unix(1700000100, 0)))
+	issues, err := db.ListReconciliationIssues(ctx, alice, 10)
+	require.NoError(t, err)
+	require.Empty(t, issues)
+
+	// The last stored height stands for the epoch
+	require.NoError(t, db.SaveBlock(ctx, &models.Block{Header: models.Header{Height: 12}}))
+	require.NoError(t, m.reconcile(ctx, time.Unix(1700000200, 0)))
+	issues, err = db.ListReconciliationIssues(ctx, alice, 10)
+	require.NoError(t, err)
+	require.Len(t, issues, 1)
+	require.Equal(t, int64(12), issues[0].Height)
+}
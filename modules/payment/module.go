@@ -2,12 +2,16 @@ package payment
 
 import (
 	"context"
+	"sync"
 
+	paymenttypes "github.com/evmos/evmos/v12/x/payment/types"
 	"gorm.io/gorm/schema"
 
 	"github.com/forbole/juno/v4/database"
 	"github.com/forbole/juno/v4/models"
 	"github.com/forbole/juno/v4/modules"
+	"github.com/forbole/juno/v4/node"
+	"github.com/forbole/juno/v4/types/config"
 )
 
 const (
@@ -15,19 +19,45 @@ const (
 )
 
 var (
-	_ modules.Module              = &Module{}
-	_ modules.PrepareTablesModule = &Module{}
+	_ modules.Module                   = &Module{}
+	_ modules.PrepareTablesModule      = &Module{}
+	_ modules.PeriodicOperationsModule = &Module{}
 )
 
 // Module represents the payment module
 type Module struct {
-	db database.Database
+	cfg *Config
+	db  database.Database
+
+	// client queries the payment module of the chain, nil when the node exposes no gRPC connection
+	client paymenttypes.QueryClient
+
+	// reconcileMu guards reconcileAfter, the id of the last stream record reconciled
+	reconcileMu    sync.Mutex
+	reconcileAfter uint64
 }
 
 // NewModule builds a new Module instance
-func NewModule(db database.Database) *Module {
+func NewModule(cfg config.Config, db database.Database, proxy node.Node) *Module {
+	bz, err := cfg.GetBytes()
+	if err != nil {
+		panic(err)
+	}
+
+	paymentCfg, err := ParseConfig(bz)
+	if err != nil {
+		panic(err)
+	}
+
+	var client paymenttypes.QueryClient
+	if grpcNode, ok := proxy.(node.GrpcNode); ok && grpcNode.GrpcConnection() != nil {
+		client = paymenttypes.NewQueryClient(grpcNode.GrpcConnection())
+	}
+
 	return &Module{
-		db: db,
+		cfg:    paymentCfg,
+		db:     db,
+		client: client,
 	}
 }
 
@@ -38,10 +68,29 @@ func (m *Module) Name() string {
 
 // PrepareTables implements
 func (m *Module) PrepareTables() error {
-	return m.db.PrepareTables(context.TODO(), []schema.Tabler{&models.StreamRecord{}, &models.PaymentAccount{}})
+	tables := m.tables()
+	database.RegisterRollbackTable(&models.PaymentLedger{}, "height")
+	database.RegisterRollbackTable(&models.PaymentAccountRefundable{}, "height")
+	if m.cfg.History {
+		tables = append(tables, &models.StreamRecordHistory{})
+		database.RegisterRollbackTable(&models.StreamRecordHistory{}, "height")
+	}
+	return m.db.PrepareTables(context.TODO(), tables)
 }
 
 // AutoMigrate implements
 func (m *Module) AutoMigrate() error {
-	return m.db.AutoMigrate(context.TODO(), []schema.Tabler{&models.StreamRecord{}, &models.PaymentAccount{}})
+	return m.db.AutoMigrate(context.TODO(), m.tables())
+}
+
+// tables returns the tables of the module, the stream record history aside
+func (m *Module) tables() []schema.Tabler {
+	return []schema.Tabler{
+		&models.StreamRecord{},
+		&models.PaymentAccount{},
+		&models.PaymentAccountRefundable{},
+		&models.PaymentLedger{},
+		&models.StreamRecordOutFlow{},
+		&models.ReconciliationIssue{},
+	}
 }
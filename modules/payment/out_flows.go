@@ -0,0 +1,71 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+
+	tmctypes "github.com/cometbft/cometbft/rpc/core/types"
+	paymenttypes "github.com/evmos/evmos/v12/x/payment/types"
+
+	"github.com/forbole/juno/v4/common"
+	"github.com/forbole/juno/v4/database"
+	"github.com/forbole/juno/v4/log"
+	"github.com/forbole/juno/v4/models"
+	"github.com/forbole/juno/v4/node/remote"
+)
+
+// queryOutFlows reads the out flows of the given account at the given block through the OutFlows query of the
+// payment module.
+// An error is returned if the query fails, like one at a height the node has pruned, so that the update of the
+// account is handled again rather than leaving its out flows behind.
+func (m *Module) queryOutFlows(ctx context.Context, block *tmctypes.ResultBlock, account common.Address) ([]paymenttypes.OutFlow, error) {
+	queryCtx := remote.GetHeightRequestContext(ctx, block.Block.Height)
+	res, err := m.client.OutFlows(queryCtx, &paymenttypes.QueryOutFlowsRequest{Account: account.String()})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query the out flows of %s at height %d: %w", account, block.Block.Height, err)
+	}
+	return res.OutFlows, nil
+}
+
+// SaveOutFlows replaces the out flows of the given account by the given ones, read at the given block, so that
+// the spending of the account can be attributed to the storage providers it flows to. An empty list clears the
+// out flows of the account. The out flows read at a later block are kept, when reprocessing old blocks.
+// EventStreamRecordUpdate doesn't carry the out flows of the account in the supported chain version, so they
+// are read through the OutFlows query of the payment module by the handler of the stream record updates.
+// An error is returned if the operation fails.
+func (m *Module) SaveOutFlows(ctx context.Context, block *tmctypes.ResultBlock, account common.Address, outFlows []paymenttypes.OutFlow) error {
+	return saveOutFlows(ctx, m.db, block, account, outFlows)
+}
+
+// saveOutFlows is SaveOutFlows writing through the given database, which may be a transaction
+func saveOutFlows(ctx context.Context, db database.Database, block *tmctypes.ResultBlock, account common.Address, outFlows []paymenttypes.OutFlow) error {
+	flows := make([]*models.StreamRecordOutFlow, 0, len(outFlows))
+	for _, outFlow := range outFlows {
+		toAddress, err := common.ParseAddress(outFlow.ToAddress)
+		if err != nil {
+			return fmt.Errorf("out flow receiver: %w", err)
+		}
+		flows = append(flows, &models.StreamRecordOutFlow{
+			Account:   account,
+			ToAddress: toAddress,
+			Rate:      (*common.Big)(outFlow.Rate.BigInt()),
+			Status:    outFlow.Status.String(),
+			Height:    block.Block.Height,
+		})
+	}
+
+	replaced, err := db.ReplaceOutFlows(ctx, account, block.Block.Height, flows)
+	if err != nil {
+		return err
+	}
+	if !replaced {
+		log.Debugw("skipping stale out flows", "account", account, "height", block.Block.Height)
+	}
+	return nil
+}
+
+// ListOutFlowsByAccount returns the out flows of the given account.
+// An error is returned if the operation fails.
+func (m *Module) ListOutFlowsByAccount(ctx context.Context, account common.Address) ([]*models.StreamRecordOutFlow, error) {
+	return m.db.ListOutFlowsByAccount(ctx, account)
+}
@@ -3,6 +3,7 @@ package payment
 import (
 	"context"
 	"errors"
+	"fmt"
 
 	abci "github.com/cometbft/cometbft/abci/types"
 	tmctypes "github.com/cometbft/cometbft/rpc/core/types"
@@ -11,25 +12,31 @@ import (
 	paymenttypes "github.com/evmos/evmos/v12/x/payment/types"
 
 	"github.com/forbole/juno/v4/common"
+	"github.com/forbole/juno/v4/database"
 	"github.com/forbole/juno/v4/log"
 	"github.com/forbole/juno/v4/models"
+	"github.com/forbole/juno/v4/modules"
 )
 
 var (
 	EventPaymentAccountUpdate = proto.MessageName(&paymenttypes.EventPaymentAccountUpdate{})
 	EventStreamRecordUpdate   = proto.MessageName(&paymenttypes.EventStreamRecordUpdate{})
+	EventDeposit              = proto.MessageName(&paymenttypes.EventDeposit{})
+	EventWithdraw             = proto.MessageName(&paymenttypes.EventWithdraw{})
 )
 
 var PaymentEvents = map[string]bool{
 	EventPaymentAccountUpdate: true,
 	EventStreamRecordUpdate:   true,
+	EventDeposit:              true,
+	EventWithdraw:             true,
 }
 
 func (m *Module) ExtractEventStatements(ctx context.Context, block *tmctypes.ResultBlock, txHash common.Hash, event sdk.Event) (map[string][]interface{}, error) {
 	return nil, nil
 }
 
-func (m *Module) HandleEvent(ctx context.Context, block *tmctypes.ResultBlock, _ common.Hash, event sdk.Event) error {
+func (m *Module) HandleEvent(ctx context.Context, block *tmctypes.ResultBlock, txHash common.Hash, event sdk.Event) error {
 	if !PaymentEvents[event.Type] {
 		return nil
 	}
@@ -54,27 +61,78 @@ func (m *Module) HandleEvent(ctx context.Context, block *tmctypes.ResultBlock, _
 			log.Errorw("type assert error", "type", "EventStreamRecordUpdate", "event", typedEvent)
 			return errors.New("update stream record event assert error")
 		}
-		return m.handleEventStreamRecordUpdate(ctx, streamRecordUpdate)
+		return m.handleEventStreamRecordUpdate(ctx, block, txHash, streamRecordUpdate)
+	case EventDeposit:
+		deposit, ok := typedEvent.(*paymenttypes.EventDeposit)
+		if !ok {
+			log.Errorw("type assert error", "type", "EventDeposit", "event", typedEvent)
+			return errors.New("deposit event assert error")
+		}
+		return m.handleLedgerEvent(ctx, block, txHash, models.PaymentLedgerDeposit, deposit.From, deposit.To, deposit.Amount)
+	case EventWithdraw:
+		withdraw, ok := typedEvent.(*paymenttypes.EventWithdraw)
+		if !ok {
+			log.Errorw("type assert error", "type", "EventWithdraw", "event", typedEvent)
+			return errors.New("withdraw event assert error")
+		}
+		return m.handleLedgerEvent(ctx, block, txHash, models.PaymentLedgerWithdraw, withdraw.From, withdraw.To, withdraw.Amount)
 	}
 
 	return nil
 }
 
 func (m *Module) handlePaymentAccountUpdate(ctx context.Context, block *tmctypes.ResultBlock, paymentAccountUpdate *paymenttypes.EventPaymentAccountUpdate) error {
+	addr, err := common.ParseAddressOrZero(paymentAccountUpdate.Addr)
+	if err != nil {
+		return fmt.Errorf("payment account address: %w", err)
+	}
+	owner, err := common.ParseAddressOrZero(paymentAccountUpdate.Owner)
+	if err != nil {
+		return fmt.Errorf("payment account owner: %w", err)
+	}
+
 	paymentAccount := &models.PaymentAccount{
-		Addr:       common.HexToAddress(paymentAccountUpdate.Addr),
-		Owner:      common.HexToAddress(paymentAccountUpdate.Owner),
+		Addr:       addr,
+		Owner:      owner,
 		Refundable: paymentAccountUpdate.Refundable,
 		UpdateAt:   block.Block.Height,
 		UpdateTime: block.Block.Time.UTC().Unix(),
 	}
 
-	return m.db.SavePaymentAccount(ctx, paymentAccount)
+	return database.WithTx(ctx, m.db, func(tx database.Database) error {
+		// The flag is recorded at every height it is updated at, whatever the order the heights are processed in,
+		// so that the flips are told apart once listed
+		err := tx.SavePaymentAccountRefundable(ctx, &models.PaymentAccountRefundable{
+			Addr:       addr,
+			Refundable: paymentAccount.Refundable,
+			Height:     block.Block.Height,
+		})
+		if err != nil {
+			return err
+		}
+
+		// When reprocessing old blocks the account may already hold the state of a later one, which must be kept
+		stored, err := tx.GetPaymentAccountByAddr(ctx, addr)
+		switch {
+		case errors.Is(err, database.ErrNotFound):
+		case err != nil:
+			return err
+		case stored.UpdateAt > block.Block.Height:
+			log.Debugw("skipping stale payment account update", "addr", addr, "height", block.Block.Height, "update_at", stored.UpdateAt)
+			return nil
+		}
+		return tx.SavePaymentAccount(ctx, paymentAccount)
+	})
 }
 
-func (m *Module) handleEventStreamRecordUpdate(ctx context.Context, streamRecordUpdate *paymenttypes.EventStreamRecordUpdate) error {
+func (m *Module) handleEventStreamRecordUpdate(ctx context.Context, block *tmctypes.ResultBlock, txHash common.Hash, streamRecordUpdate *paymenttypes.EventStreamRecordUpdate) error {
+	account, err := common.ParseAddressOrZero(streamRecordUpdate.Account)
+	if err != nil {
+		return fmt.Errorf("stream record account: %w", err)
+	}
+
 	streamRecord := &models.StreamRecord{
-		Account:           common.HexToAddress(streamRecordUpdate.Account),
+		Account:           account,
 		CrudTimestamp:     streamRecordUpdate.CrudTimestamp,
 		NetflowRate:       (*common.Big)(streamRecordUpdate.NetflowRate.BigInt()),
 		FrozenNetflowRate: (*common.Big)(streamRecordUpdate.FrozenNetflowRate.BigInt()),
@@ -85,5 +143,75 @@ func (m *Module) handleEventStreamRecordUpdate(ctx context.Context, streamRecord
 		SettleTimestamp:   streamRecordUpdate.SettleTimestamp,
 	}
 
-	return m.db.SaveStreamRecord(ctx, streamRecord)
+	// The out flows are read before the transaction, which doesn't wait on the node. Nothing is read when the
+	// node exposes no gRPC connection.
+	var outFlows []paymenttypes.OutFlow
+	if m.client != nil {
+		if outFlows, err = m.queryOutFlows(ctx, block, account); err != nil {
+			return err
+		}
+	}
+
+	return database.WithTx(ctx, m.db, func(tx database.Database) error {
+		if err := tx.SaveStreamRecord(ctx, streamRecord); err != nil {
+			return err
+		}
+		if m.cfg.History {
+			if err := tx.SaveStreamRecordHistory(ctx, streamRecordHistory(block, txHash, streamRecord)); err != nil {
+				return err
+			}
+		}
+		if m.client == nil {
+			return nil
+		}
+		return saveOutFlows(ctx, tx, block, account, outFlows)
+	})
+}
+
+// streamRecordHistory returns the update of the stream record history made by the given stream record, updated
+// at the given block
+func streamRecordHistory(block *tmctypes.ResultBlock, txHash common.Hash, streamRecord *models.StreamRecord) *models.StreamRecordHistory {
+	return &models.StreamRecordHistory{
+		Account:           streamRecord.Account,
+		CrudTimestamp:     streamRecord.CrudTimestamp,
+		NetflowRate:       streamRecord.NetflowRate,
+		FrozenNetflowRate: streamRecord.FrozenNetflowRate,
+		StaticBalance:     streamRecord.StaticBalance,
+		BufferBalance:     streamRecord.BufferBalance,
+		LockBalance:       streamRecord.LockBalance,
+		Status:            streamRecord.Status,
+		SettleTimestamp:   streamRecord.SettleTimestamp,
+		Height:            block.Block.Height,
+		TxHash:            txHash,
+	}
+}
+
+// handleLedgerEvent records a deposit into the stream account to, or a withdrawal out of the stream account from,
+// depending on the given kind. The balances are left to the stream record updates.
+func (m *Module) handleLedgerEvent(ctx context.Context, block *tmctypes.ResultBlock, txHash common.Hash, kind, from, to string, amount sdk.Int) error {
+	fromAddress, err := common.ParseAddressOrZero(from)
+	if err != nil {
+		return fmt.Errorf("%s from: %w", kind, err)
+	}
+	toAddress, err := common.ParseAddressOrZero(to)
+	if err != nil {
+		return fmt.Errorf("%s to: %w", kind, err)
+	}
+
+	account := toAddress
+	if kind == models.PaymentLedgerWithdraw {
+		account = fromAddress
+	}
+
+	return m.db.SavePaymentLedger(ctx, &models.PaymentLedger{
+		Account:     account,
+		FromAddress: fromAddress,
+		ToAddress:   toAddress,
+		Amount:      (*common.Big)(amount.BigInt()),
+		Kind:        kind,
+		Height:      block.Block.Height,
+		TxHash:      txHash,
+		EventIndex:  modules.EventIndex(ctx),
+		Timestamp:   block.Block.Time.UTC().Unix(),
+	})
 }
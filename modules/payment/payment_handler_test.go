@@ -0,0 +1,380 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	tmctypes "github.com/cometbft/cometbft/rpc/core/types"
+	tmtypes "github.com/cometbft/cometbft/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/gogoproto/proto"
+	paymenttypes "github.com/evmos/evmos/v12/x/payment/types"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm/schema"
+
+	"github.com/forbole/juno/v4/common"
+	"github.com/forbole/juno/v4/database"
+	"github.com/forbole/juno/v4/database/sqlite/sqlitetest"
+	"github.com/forbole/juno/v4/models"
+	"github.com/forbole/juno/v4/modules"
+)
+
+func newTestModule(t *testing.T) (*Module, *database.Impl) {
+	t.Helper()
+
+	db := sqlitetest.NewDatabase(t)
+	m := &Module{cfg: NewConfig(false, 0), db: db}
+	require.NoError(t, m.PrepareTables())
+	return m, db
+}
+
+func newTestBlock(height int64) *tmctypes.ResultBlock {
+	return &tmctypes.ResultBlock{
+		Block: &tmtypes.Block{Header: tmtypes.Header{Height: height, Time: time.Unix(1700000000+height, 0)}},
+	}
+}
+
+// handleEvent makes m handle the given event, emitted at height by the tx whose hash is the height
+func handleEvent(t *testing.T, m *Module, height int64, event proto.Message) {
+	t.Helper()
+
+	sdkEvent, err := sdk.TypedEventToEvent(event)
+	require.NoError(t, err)
+	require.NoError(t, m.HandleEvent(context.Background(), newTestBlock(height), common.BigToHash(big.NewInt(height)), sdkEvent))
+}
+
+func TestHandlePaymentAccountUpdate(t *testing.T) {
+	ctx := context.Background()
+	m, db := newTestModule(t)
+
+	addr := "0x00000000000000000000000000000000000000a1"
+	owner := "0x00000000000000000000000000000000000000b1"
+
+	// The account is not stored yet
+	require.NoError(t, m.handlePaymentAccountUpdate(ctx, newTestBlock(10), &paymenttypes.EventPaymentAccountUpdate{
+		Addr: addr, Owner: owner, Refundable: true,
+	}))
+	account, err := db.GetPaymentAccountByAddr(ctx, common.HexToAddress(addr))
+	require.NoError(t, err)
+	require.True(t, account.Refundable)
+	require.Equal(t, int64(10), account.UpdateAt)
+
+	// A later update is applied
+	require.NoError(t, m.handlePaymentAccountUpdate(ctx, newTestBlock(20), &paymenttypes.EventPaymentAccountUpdate{
+		Addr: addr, Owner: owner, Refundable: false,
+	}))
+	account, err = db.GetPaymentAccountByAddr(ctx, common.HexToAddress(addr))
+	require.NoError(t, err)
+	require.False(t, account.Refundable)
+	require.Equal(t, int64(20), account.UpdateAt)
+
+	// Reprocessing the first block must not bring the stale state back
+	require.NoError(t, m.handlePaymentAccountUpdate(ctx, newTestBlock(10), &paymenttypes.EventPaymentAccountUpdate{
+		Addr: addr, Owner: owner, Refundable: true,
+	}))
+	account, err = db.GetPaymentAccountByAddr(ctx, common.HexToAddress(addr))
+	require.NoError(t, err)
+	require.False(t, account.Refundable)
+	require.Equal(t, int64(20), account.UpdateAt)
+}
+
+func TestPaymentAccountCount(t *testing.T) {
+	ctx := context.Background()
+	m, db := newTestModule(t)
+
+	owner := common.HexToAddress("0x0f")
+	first, second := common.HexToAddress("0x01"), common.HexToAddress("0x02")
+	update := func(height int64, addr common.Address, refundable bool) {
+		require.NoError(t, m.handlePaymentAccountUpdate(ctx, newTestBlock(height), &paymenttypes.EventPaymentAccountUpdate{
+			Addr: addr.String(), Owner: owner.String(), Refundable: refundable,
+		}))
+	}
+
+	// Reprocessing the creation doesn't count the account twice
+	update(10, first, true)
+	update(10, first, true)
+	count, err := db.CountPaymentAccountsByOwner(ctx, owner)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), count)
+
+	// Neither does updating it
+	update(20, first, false)
+	update(25, first, false)
+	update(30, first, true)
+	update(30, second, true)
+	count, err = db.CountPaymentAccountsByOwner(ctx, owner)
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), count)
+
+	count, err = db.CountPaymentAccountsByOwner(ctx, common.HexToAddress("0x0e"))
+	require.NoError(t, err)
+	require.Zero(t, count)
+
+	// The creation and the flips are listed, once
+	update(20, first, false)
+	history, err := db.ListPaymentAccountRefundable(ctx, first)
+	require.NoError(t, err)
+	require.Len(t, history, 3)
+	for i, expected := range []models.PaymentAccountRefundable{
+		{Refundable: true, Height: 10}, {Refundable: false, Height: 20}, {Refundable: true, Height: 30},
+	} {
+		require.Equal(t, expected.Refundable, history[i].Refundable)
+		require.Equal(t, expected.Height, history[i].Height)
+	}
+
+	// Processed out of order, the flips are the ones that happened
+	third := common.HexToAddress("0x03")
+	update(30, third, true)
+	update(10, third, true)
+	update(20, third, false)
+	history, err = db.ListPaymentAccountRefundable(ctx, third)
+	require.NoError(t, err)
+	require.Len(t, history, 3)
+	for i, expected := range []models.PaymentAccountRefundable{
+		{Refundable: true, Height: 10}, {Refundable: false, Height: 20}, {Refundable: true, Height: 30},
+	} {
+		require.Equal(t, expected.Refundable, history[i].Refundable)
+		require.Equal(t, expected.Height, history[i].Height)
+	}
+	count, err = db.CountPaymentAccountsByOwner(ctx, owner)
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), count)
+
+	// The count follows the accounts rolled back
+	require.NoError(t, db.Db.Table((&models.PaymentAccount{}).TableName()).Where("addr = ?", third).Delete(&models.PaymentAccount{}).Error)
+	count, err = db.CountPaymentAccountsByOwner(ctx, owner)
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), count)
+}
+
+func TestHandlePaymentAccountUpdateMixedCase(t *testing.T) {
+	ctx := context.Background()
+	m, db := newTestModule(t)
+
+	// Addresses are stored as bytes, whatever the case and the prefix they came with
+	require.NoError(t, m.handlePaymentAccountUpdate(ctx, newTestBlock(10), &paymenttypes.EventPaymentAccountUpdate{
+		Addr: "0X00000000000000000000000000000000000000AB", Owner: "00000000000000000000000000000000000000CD", Refundable: true,
+	}))
+	addr, err := common.ParseAddress("0x00000000000000000000000000000000000000ab")
+	require.NoError(t, err)
+	account, err := db.GetPaymentAccountByAddr(ctx, addr)
+	require.NoError(t, err)
+	require.Equal(t, common.HexToAddress("0xcd"), account.Owner)
+
+	err = m.handlePaymentAccountUpdate(ctx, newTestBlock(11), &paymenttypes.EventPaymentAccountUpdate{Addr: "not an address"})
+	require.ErrorIs(t, err, common.ErrInvalidAddress)
+
+	// An empty owner is stored as the zero address
+	require.NoError(t, m.handlePaymentAccountUpdate(ctx, newTestBlock(12), &paymenttypes.EventPaymentAccountUpdate{Addr: addr.String()}))
+	account, err = db.GetPaymentAccountByAddr(ctx, addr)
+	require.NoError(t, err)
+	require.Equal(t, common.Address{}, account.Owner)
+}
+
+func TestStreamRecordHistory(t *testing.T) {
+	ctx := context.Background()
+	m, db := newTestModule(t)
+	m.cfg.History = true
+	m.cfg.HistoryRetention = time.Hour
+	require.NoError(t, db.PrepareTables(ctx, []schema.Tabler{&models.StreamRecordHistory{}}))
+
+	account := "0x00000000000000000000000000000000000000a1"
+	handleUpdate := func(i int, balance int64) {
+		height := int64(10 + i)
+		require.NoError(t, m.handleEventStreamRecordUpdate(ctx, newTestBlock(height), common.BigToHash(big.NewInt(height)), &paymenttypes.EventStreamRecordUpdate{
+			Account:           account,
+			CrudTimestamp:     1700000000 + int64(i)*3600,
+			NetflowRate:       sdk.NewInt(-20),
+			FrozenNetflowRate: sdk.ZeroInt(),
+			StaticBalance:     sdk.NewInt(balance),
+			BufferBalance:     sdk.NewInt(10),
+			LockBalance:       sdk.ZeroInt(),
+			Status:            paymenttypes.STREAM_ACCOUNT_STATUS_ACTIVE,
+		}))
+	}
+	for i, balance := range []int64{100, 80, 60} {
+		handleUpdate(i, balance)
+	}
+
+	// Handling a block again does not record its updates twice
+	handleUpdate(1, 80)
+
+	var current int64
+	require.NoError(t, db.Db.Table((&models.StreamRecord{}).TableName()).Count(&current).Error)
+	require.Equal(t, int64(1), current)
+
+	history, err := db.ListStreamRecordHistory(ctx, common.HexToAddress(account), 0, 1800000000, 10)
+	require.NoError(t, err)
+	require.Len(t, history, 3)
+	for i, balance := range []int64{100, 80, 60} {
+		require.Equal(t, int64(10+i), history[i].Height)
+		require.Equal(t, common.BigToHash(big.NewInt(int64(10+i))), history[i].TxHash)
+		require.Equal(t, balance, history[i].StaticBalance.Raw().Int64())
+	}
+
+	history, err = db.ListStreamRecordHistory(ctx, common.HexToAddress(account), 1700000000+3600, 1800000000, 1)
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	require.Equal(t, int64(11), history[0].Height)
+
+	// Only the updates within the retention are kept
+	require.NoError(t, m.pruneHistory(ctx, time.Unix(1700000000+2*3600+1800, 0)))
+	history, err = db.ListStreamRecordHistory(ctx, common.HexToAddress(account), 0, 1800000000, 10)
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	require.Equal(t, int64(12), history[0].Height)
+}
+
+func TestSaveOutFlows(t *testing.T) {
+	ctx := context.Background()
+	m, _ := newTestModule(t)
+
+	account := common.HexToAddress("0x01")
+	rate, _ := sdk.NewIntFromString("98765432109876543210")
+	require.NoError(t, m.SaveOutFlows(ctx, newTestBlock(10), account, []paymenttypes.OutFlow{
+		{ToAddress: "0x000000000000000000000000000000000000000A", Rate: rate, Status: paymenttypes.OUT_FLOW_STATUS_ACTIVE},
+	}))
+
+	outFlows, err := m.ListOutFlowsByAccount(ctx, account)
+	require.NoError(t, err)
+	require.Len(t, outFlows, 1)
+	require.Equal(t, common.HexToAddress("0x0a"), outFlows[0].ToAddress)
+	require.Zero(t, rate.BigInt().Cmp(outFlows[0].Rate.Raw()))
+	require.Equal(t, "OUT_FLOW_STATUS_ACTIVE", outFlows[0].Status)
+	require.Equal(t, int64(10), outFlows[0].Height)
+
+	require.Error(t, m.SaveOutFlows(ctx, newTestBlock(11), account, []paymenttypes.OutFlow{{ToAddress: "sp", Rate: rate}}))
+
+	require.NoError(t, m.SaveOutFlows(ctx, newTestBlock(12), account, nil))
+	outFlows, err = m.ListOutFlowsByAccount(ctx, account)
+	require.NoError(t, err)
+	require.Empty(t, outFlows)
+}
+
+func TestStreamRecordUpdateOutFlows(t *testing.T) {
+	ctx := context.Background()
+	m, _ := newTestModule(t)
+
+	account := common.HexToAddress("0x01")
+	outFlow := func(rate int64) paymenttypes.OutFlow {
+		return paymenttypes.OutFlow{
+			ToAddress: "0x000000000000000000000000000000000000000A", Rate: sdk.NewInt(rate), Status: paymenttypes.OUT_FLOW_STATUS_ACTIVE,
+		}
+	}
+	client := &mockQueryClient{outFlows: map[string][]paymenttypes.OutFlow{account.String(): {outFlow(20)}}}
+	m.client = client
+	update := func(height int64) {
+		require.NoError(t, m.handleEventStreamRecordUpdate(ctx, newTestBlock(height), common.Hash{}, &paymenttypes.EventStreamRecordUpdate{
+			Account:           account.String(),
+			NetflowRate:       sdk.NewInt(-20),
+			FrozenNetflowRate: sdk.ZeroInt(),
+			StaticBalance:     sdk.NewInt(100),
+			BufferBalance:     sdk.ZeroInt(),
+			LockBalance:       sdk.ZeroInt(),
+			Status:            paymenttypes.STREAM_ACCOUNT_STATUS_ACTIVE,
+		}))
+	}
+	requireRate := func(rate, height int64) {
+		t.Helper()
+		outFlows, err := m.ListOutFlowsByAccount(ctx, account)
+		require.NoError(t, err)
+		require.Len(t, outFlows, 1)
+		require.Equal(t, rate, outFlows[0].Rate.Raw().Int64())
+		require.Equal(t, height, outFlows[0].Height)
+	}
+
+	// The out flows are read along with the update of the stream record
+	update(10)
+	requireRate(20, 10)
+
+	client.outFlows[account.String()] = []paymenttypes.OutFlow{outFlow(30)}
+	update(20)
+	requireRate(30, 20)
+
+	// Reprocessing an old block keeps the out flows read at a later one
+	client.outFlows[account.String()] = []paymenttypes.OutFlow{outFlow(20)}
+	update(10)
+	requireRate(30, 20)
+
+	// Even when the later read found none
+	client.outFlows[account.String()] = nil
+	update(30)
+	client.outFlows[account.String()] = []paymenttypes.OutFlow{outFlow(20)}
+	update(25)
+	outFlows, err := m.ListOutFlowsByAccount(ctx, account)
+	require.NoError(t, err)
+	require.Empty(t, outFlows)
+
+	// A failing read fails the update, which is left to be handled again
+	client.outFlowsErr = errors.New("height pruned")
+	require.Error(t, m.handleEventStreamRecordUpdate(ctx, newTestBlock(40), common.Hash{}, &paymenttypes.EventStreamRecordUpdate{
+		Account:           account.String(),
+		NetflowRate:       sdk.ZeroInt(),
+		FrozenNetflowRate: sdk.ZeroInt(),
+		StaticBalance:     sdk.ZeroInt(),
+		BufferBalance:     sdk.ZeroInt(),
+		LockBalance:       sdk.ZeroInt(),
+	}))
+}
+
+func TestPaymentLedger(t *testing.T) {
+	ctx := context.Background()
+	m, db := newTestModule(t)
+
+	owner, account := common.HexToAddress("0x01"), common.HexToAddress("0x0a")
+	large, ok := sdk.NewIntFromString("1000000000000000000000")
+	require.True(t, ok)
+
+	handleEvent(t, m, 10, &paymenttypes.EventDeposit{From: owner.String(), To: account.String(), Amount: large})
+	handleEvent(t, m, 11, &paymenttypes.EventDeposit{From: owner.String(), To: account.String(), Amount: sdk.NewInt(5)})
+	handleEvent(t, m, 12, &paymenttypes.EventWithdraw{From: account.String(), To: owner.String(), Amount: sdk.NewInt(3)})
+	// Handling an event again doesn't record it twice
+	handleEvent(t, m, 12, &paymenttypes.EventWithdraw{From: account.String(), To: owner.String(), Amount: sdk.NewInt(3)})
+
+	entries, total, err := db.ListPaymentLedger(ctx, account, 0, 100, 10, 0)
+	require.NoError(t, err)
+	require.Equal(t, int64(3), total)
+	require.Len(t, entries, 3)
+	require.Equal(t, models.PaymentLedgerDeposit, entries[0].Kind)
+	require.Equal(t, owner, entries[0].FromAddress)
+	require.Equal(t, account, entries[0].ToAddress)
+	require.Zero(t, large.BigInt().Cmp(entries[0].Amount.Raw()))
+	require.Equal(t, int64(10), entries[0].Height)
+	require.Equal(t, models.PaymentLedgerWithdraw, entries[2].Kind)
+	require.Equal(t, account, entries[2].FromAddress)
+	require.Equal(t, owner, entries[2].ToAddress)
+	require.Equal(t, int64(3), entries[2].Amount.Raw().Int64())
+	require.Equal(t, common.BigToHash(big.NewInt(12)), entries[2].TxHash)
+
+	entries, total, err = db.ListPaymentLedger(ctx, account, 0, 100, 2, 2)
+	require.NoError(t, err)
+	require.Equal(t, int64(3), total)
+	require.Len(t, entries, 1)
+	require.Equal(t, int64(12), entries[0].Height)
+
+	entries, total, err = db.ListPaymentLedger(ctx, account, 11, 11, 10, 0)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), total)
+	require.Equal(t, int64(5), entries[0].Amount.Raw().Int64())
+
+	// The ledger of the owner isn't the one of its stream account
+	_, total, err = db.ListPaymentLedger(ctx, owner, 0, 100, 10, 0)
+	require.NoError(t, err)
+	require.Zero(t, total)
+
+	// The same deposit made twice by a tx is recorded once per event
+	other := common.HexToAddress("0x0b")
+	sdkEvent, err := sdk.TypedEventToEvent(&paymenttypes.EventDeposit{From: owner.String(), To: other.String(), Amount: sdk.NewInt(7)})
+	require.NoError(t, err)
+	for _, index := range []int{0, 1, 1} {
+		require.NoError(t, m.HandleEvent(modules.WithEventIndex(ctx, index), newTestBlock(13), common.BigToHash(big.NewInt(13)), sdkEvent))
+	}
+	entries, total, err = db.ListPaymentLedger(ctx, other, 0, 100, 10, 0)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), total)
+	require.Equal(t, 0, entries[0].EventIndex)
+	require.Equal(t, 1, entries[1].EventIndex)
+}
@@ -0,0 +1,39 @@
+package permission
+
+import (
+	"gopkg.in/yaml.v3"
+)
+
+type Config struct {
+	// History tells whether every policy put or deleted is recorded into the permission history, which grows
+	// without bounds
+	History bool `yaml:"history"`
+
+	// GroupPermissions tells whether the policies put on groups are materialized for each member of the groups
+	GroupPermissions bool `yaml:"group_permissions"`
+}
+
+// NewConfig allows to build a new Config instance
+func NewConfig(history, groupPermissions bool) *Config {
+	return &Config{
+		History:          history,
+		GroupPermissions: groupPermissions,
+	}
+}
+
+// ParseConfig reads the permission config, returning the default one when not set
+func ParseConfig(bz []byte) (*Config, error) {
+	type T struct {
+		Config *Config `yaml:"permission"`
+	}
+	var cfg T
+	err := yaml.Unmarshal(bz, &cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Config == nil {
+		cfg.Config = NewConfig(false, false)
+	}
+	return cfg.Config, nil
+}
@@ -0,0 +1,25 @@
+package permission_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/forbole/juno/v4/modules/permission"
+)
+
+func TestParseConfig(t *testing.T) {
+	cfg, err := permission.ParseConfig([]byte(`
+permission:
+  history: true
+  group_permissions: true
+`))
+	require.NoError(t, err)
+	require.True(t, cfg.History)
+	require.True(t, cfg.GroupPermissions)
+
+	cfg, err = permission.ParseConfig([]byte(`invalid_field: yes`))
+	require.NoError(t, err)
+	require.False(t, cfg.History)
+	require.False(t, cfg.GroupPermissions)
+}
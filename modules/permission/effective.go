@@ -0,0 +1,203 @@
+package permission
+
+import (
+	"context"
+	"errors"
+	"math/big"
+
+	abci "github.com/cometbft/cometbft/abci/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/gogoproto/proto"
+	permissiontypes "github.com/evmos/evmos/v12/x/permission/types"
+	storagetypes "github.com/evmos/evmos/v12/x/storage/types"
+
+	"github.com/forbole/juno/v4/common"
+	"github.com/forbole/juno/v4/database"
+	"github.com/forbole/juno/v4/log"
+	"github.com/forbole/juno/v4/models"
+	"github.com/forbole/juno/v4/modules/group"
+)
+
+// memberPageSize is the number of members of a group read at a time when materializing its policies
+const memberPageSize = 100
+
+var (
+	EventDeleteGroup       = proto.MessageName(&storagetypes.EventDeleteGroup{})
+	EventLeaveGroup        = proto.MessageName(&storagetypes.EventLeaveGroup{})
+	EventUpdateGroupMember = proto.MessageName(&storagetypes.EventUpdateGroupMember{})
+	EventRenewGroupMember  = proto.MessageName(&storagetypes.EventRenewGroupMember{})
+)
+
+// GroupEvents are the events changing the members of a group, which the materialized policies of the group
+// follow
+var GroupEvents = map[string]bool{
+	EventDeleteGroup:       true,
+	EventLeaveGroup:        true,
+	EventUpdateGroupMember: true,
+	EventRenewGroupMember:  true,
+}
+
+// ListEffectivePermissionsByAccount returns the policies applying to the given account on the given resource
+// through the groups it is a member of, out of the materialized ones. It is only available when the group
+// permissions are enabled.
+// An error is returned if the operation fails.
+func (m *Module) ListEffectivePermissionsByAccount(ctx context.Context, account common.Address, resourceID common.Hash) ([]*models.EffectivePermission, error) {
+	if !m.cfg.GroupPermissions {
+		return nil, errors.New("group permissions are not enabled")
+	}
+	return m.db.ListEffectivePermissionsByAccount(ctx, account, resourceID)
+}
+
+// handleGroupEvent updates the materialized policies of the group whose members the given event changes
+func (m *Module) handleGroupEvent(ctx context.Context, event sdk.Event) error {
+	typedEvent, err := sdk.ParseTypedEvent(abci.Event(event))
+	if err != nil {
+		log.Errorw("parse typed events error", "module", m.Name(), "event", event, "err", err)
+		return err
+	}
+
+	switch typedEvent := typedEvent.(type) {
+	case *storagetypes.EventUpdateGroupMember:
+		return m.handleUpdateGroupMember(ctx, typedEvent)
+	case *storagetypes.EventRenewGroupMember:
+		return m.handleRenewGroupMember(ctx, typedEvent)
+	case *storagetypes.EventLeaveGroup:
+		return m.db.DeleteMemberEffectivePermissions(ctx, common.BigToHash(typedEvent.GroupId.BigInt()),
+			common.HexToAddress(typedEvent.MemberAddress))
+	case *storagetypes.EventDeleteGroup:
+		return m.db.DeleteGroupEffectivePermissions(ctx, common.BigToHash(typedEvent.GroupId.BigInt()))
+	}
+	return nil
+}
+
+// handleUpdateGroupMember materializes the policies of the group for the members added, and deletes the ones
+// of the members removed
+func (m *Module) handleUpdateGroupMember(ctx context.Context, event *storagetypes.EventUpdateGroupMember) error {
+	groupID := common.BigToHash(event.GroupId.BigInt())
+	return database.WithTx(ctx, m.db, func(tx database.Database) error {
+		for _, member := range event.MembersToDelete {
+			if err := tx.DeleteMemberEffectivePermissions(ctx, groupID, common.HexToAddress(member)); err != nil {
+				return err
+			}
+		}
+		return saveMembersEffectivePermissions(ctx, tx, event.GroupId.String(), groupMembers(groupID, event.MembersToAdd))
+	})
+}
+
+// handleRenewGroupMember moves the materialized policies of the renewed members to their new expiration time
+func (m *Module) handleRenewGroupMember(ctx context.Context, event *storagetypes.EventRenewGroupMember) error {
+	members := groupMembers(common.BigToHash(event.GroupId.BigInt()), event.Members)
+	return database.WithTx(ctx, m.db, func(tx database.Database) error {
+		return saveMembersEffectivePermissions(ctx, tx, event.GroupId.String(), members)
+	})
+}
+
+// groupMembers returns the given members of the given group, along with their expiration time
+func groupMembers(groupID common.Hash, details []*storagetypes.EventGroupMemberDetail) []*models.Group {
+	members := make([]*models.Group, 0, len(details))
+	for _, member := range details {
+		var expirationTime int64
+		if member.ExpirationTime != nil {
+			expirationTime = member.ExpirationTime.Unix()
+		}
+		members = append(members, &models.Group{GroupID: groupID, AccountID: common.HexToAddress(member.Member), ExpirationTime: expirationTime})
+	}
+	return members
+}
+
+// saveMembersEffectivePermissions materializes the policies put on the given group for the given members of it
+func saveMembersEffectivePermissions(ctx context.Context, tx database.Database, groupID string, members []*models.Group) error {
+	if len(members) == 0 {
+		return nil
+	}
+
+	permissions, err := tx.ListPermissionsByPrincipal(ctx, int32(permissiontypes.PRINCIPAL_TYPE_GNFD_GROUP), groupID)
+	if err != nil {
+		return err
+	}
+	for _, permission := range permissions {
+		statements, err := tx.GetStatementsByPolicyID(ctx, permission.PolicyID, false)
+		if err != nil {
+			return err
+		}
+		if err := tx.SaveEffectivePermissions(ctx, effectivePermissions(permission, statements, members)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// materializePolicy replaces the materialized policies of the given policy, put along with the given statements,
+// by the ones of the current members of its group, if it is put on a group
+func (m *Module) materializePolicy(ctx context.Context, tx database.Database, permission *models.Permission, statements []*models.Statements) error {
+	if !m.cfg.GroupPermissions {
+		return nil
+	}
+	if err := tx.DeleteEffectivePermissions(ctx, permission.PolicyID); err != nil {
+		return err
+	}
+	if permissiontypes.PrincipalType(permission.PrincipalType) != permissiontypes.PRINCIPAL_TYPE_GNFD_GROUP {
+		return nil
+	}
+
+	groupID, ok := new(big.Int).SetString(permission.PrincipalValue, 10)
+	if !ok {
+		log.Warnw("invalid group id of policy", "module", ModuleName, "policy id", permission.PolicyID, "group id", permission.PrincipalValue)
+		return nil
+	}
+
+	// Without the group module the members are unknown, the ones added later being materialized out of the
+	// group events
+	if !m.running(group.ModuleName) {
+		return nil
+	}
+
+	// The pages may be smaller than asked for, capped by the max page size of the database
+	var startAfter common.Address
+	for {
+		members, err := tx.GetGroupMembers(ctx, common.BigToHash(groupID), memberPageSize, startAfter)
+		if err != nil || len(members) == 0 {
+			return err
+		}
+		if err := tx.SaveEffectivePermissions(ctx, effectivePermissions(permission, statements, members)); err != nil {
+			return err
+		}
+		startAfter = members[len(members)-1].AccountID
+	}
+}
+
+// effectivePermissions returns the materialized policies of the given policy for the given members of its group
+func effectivePermissions(permission *models.Permission, statements []*models.Statements, members []*models.Group) []*models.EffectivePermission {
+	var allowed, denied int
+	for _, statement := range statements {
+		switch statement.Effect {
+		case permissiontypes.EFFECT_ALLOW.String():
+			allowed |= statement.ActionValue
+		case permissiontypes.EFFECT_DENY.String():
+			denied |= statement.ActionValue
+		}
+	}
+
+	permissions := make([]*models.EffectivePermission, 0, len(members))
+	for _, member := range members {
+		permissions = append(permissions, &models.EffectivePermission{
+			Account:         member.AccountID,
+			ResourceType:    permission.ResourceType,
+			ResourceID:      permission.ResourceID,
+			PolicyID:        permission.PolicyID,
+			GroupID:         member.GroupID,
+			ActionValue:     allowed,
+			DenyActionValue: denied,
+			ExpirationTime:  earliestExpiration(permission.ExpirationTime, member.ExpirationTime),
+		})
+	}
+	return permissions
+}
+
+// earliestExpiration returns the earliest of the given expiration times, a zero one never expiring
+func earliestExpiration(a, b int64) int64 {
+	if a == 0 || (b != 0 && b < a) {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,149 @@
+package permission
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	sdkmath "cosmossdk.io/math"
+	permissiontypes "github.com/evmos/evmos/v12/x/permission/types"
+	storagetypes "github.com/evmos/evmos/v12/x/storage/types"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm/schema"
+
+	"github.com/forbole/juno/v4/common"
+	"github.com/forbole/juno/v4/models"
+	"github.com/forbole/juno/v4/modules/group"
+)
+
+func TestEffectivePermissions(t *testing.T) {
+	ctx := context.Background()
+	m, db := newTestModule(t)
+	m.cfg.GroupPermissions = true
+	require.NoError(t, db.PrepareTables(ctx, []schema.Tabler{&models.Group{}, &models.EffectivePermission{}}))
+
+	bob, carol := common.HexToAddress("0x02"), common.HexToAddress("0x03")
+	groupID := common.BigToHash(sdkmath.NewUint(5).BigInt())
+	resourceID := common.BigToHash(sdkmath.NewUint(42).BigInt())
+	require.NoError(t, db.CreateGroup(ctx, []*models.Group{{GroupID: groupID, AccountID: bob}}))
+
+	blockTime := time.Unix(1700000000, 0)
+	handleEvent(t, m, blockTime, &permissiontypes.EventPutPolicy{
+		PolicyId:     sdkmath.NewUint(7),
+		Principal:    &permissiontypes.Principal{Type: permissiontypes.PRINCIPAL_TYPE_GNFD_GROUP, Value: "5"},
+		ResourceType: 1,
+		ResourceId:   sdkmath.NewUint(42),
+		Statements: []*permissiontypes.Statement{
+			{Effect: permissiontypes.EFFECT_ALLOW, Actions: []permissiontypes.ActionType{permissiontypes.ACTION_GET_OBJECT}},
+			{Effect: permissiontypes.EFFECT_DENY, Actions: []permissiontypes.ActionType{permissiontypes.ACTION_DELETE_OBJECT}},
+		},
+	})
+
+	permissions, err := m.ListEffectivePermissionsByAccount(ctx, bob, resourceID)
+	require.NoError(t, err)
+	require.Len(t, permissions, 1)
+	require.Equal(t, 1<<6, permissions[0].ActionValue)
+	require.Equal(t, 1<<4, permissions[0].DenyActionValue)
+	require.Equal(t, groupID, permissions[0].GroupID)
+
+	// A member added after the policy is put gets it too
+	expiration := time.Unix(1800000000, 0)
+	handleEvent(t, m, blockTime, &storagetypes.EventUpdateGroupMember{
+		GroupId:      sdkmath.NewUint(5),
+		MembersToAdd: []*storagetypes.EventGroupMemberDetail{{Member: carol.String(), ExpirationTime: &expiration}},
+	})
+
+	permissions, err = m.ListEffectivePermissionsByAccount(ctx, carol, resourceID)
+	require.NoError(t, err)
+	require.Len(t, permissions, 1)
+	require.Equal(t, common.BigToHash(sdkmath.NewUint(7).BigInt()), permissions[0].PolicyID)
+	require.Equal(t, expiration.Unix(), permissions[0].ExpirationTime)
+
+	// Renewing a member moves its expiration
+	renewed := time.Unix(1900000000, 0)
+	handleEvent(t, m, blockTime, &storagetypes.EventRenewGroupMember{
+		GroupId: sdkmath.NewUint(5),
+		Members: []*storagetypes.EventGroupMemberDetail{{Member: carol.String(), ExpirationTime: &renewed}},
+	})
+	permissions, err = m.ListEffectivePermissionsByAccount(ctx, carol, resourceID)
+	require.NoError(t, err)
+	require.Len(t, permissions, 1)
+	require.Equal(t, renewed.Unix(), permissions[0].ExpirationTime)
+
+	// A member removed loses it
+	handleEvent(t, m, blockTime, &storagetypes.EventUpdateGroupMember{GroupId: sdkmath.NewUint(5), MembersToDelete: []string{carol.String()}})
+	permissions, err = m.ListEffectivePermissionsByAccount(ctx, carol, resourceID)
+	require.NoError(t, err)
+	require.Empty(t, permissions)
+
+	// Deleting the policy cleans up the materialized ones
+	handleEvent(t, m, blockTime, &permissiontypes.EventDeletePolicy{PolicyId: sdkmath.NewUint(7)})
+	permissions, err = m.ListEffectivePermissionsByAccount(ctx, bob, resourceID)
+	require.NoError(t, err)
+	require.Empty(t, permissions)
+}
+
+func TestEffectivePermissionsPages(t *testing.T) {
+	ctx := context.Background()
+	m, db := newTestModule(t)
+	m.cfg.GroupPermissions = true
+	require.NoError(t, db.PrepareTables(ctx, []schema.Tabler{&models.Group{}, &models.EffectivePermission{}}))
+
+	// The pages of members are capped below the page size asked for
+	db.MaxPageSize = 2
+	groupID := common.BigToHash(sdkmath.NewUint(5).BigInt())
+	members := []common.Address{common.HexToAddress("0x02"), common.HexToAddress("0x03"), common.HexToAddress("0x04")}
+	for _, member := range members {
+		require.NoError(t, db.CreateGroup(ctx, []*models.Group{{GroupID: groupID, AccountID: member}}))
+	}
+
+	handleEvent(t, m, time.Unix(1700000000, 0), &permissiontypes.EventPutPolicy{
+		PolicyId:     sdkmath.NewUint(7),
+		Principal:    &permissiontypes.Principal{Type: permissiontypes.PRINCIPAL_TYPE_GNFD_GROUP, Value: "5"},
+		ResourceType: 1,
+		ResourceId:   sdkmath.NewUint(42),
+		Statements: []*permissiontypes.Statement{
+			{Effect: permissiontypes.EFFECT_ALLOW, Actions: []permissiontypes.ActionType{permissiontypes.ACTION_GET_OBJECT}},
+		},
+	})
+
+	for _, member := range members {
+		permissions, err := m.ListEffectivePermissionsByAccount(ctx, member, common.BigToHash(sdkmath.NewUint(42).BigInt()))
+		require.NoError(t, err)
+		require.Len(t, permissions, 1, member.String())
+	}
+}
+
+func TestEffectivePermissionsWithoutGroupModule(t *testing.T) {
+	ctx := context.Background()
+	m, db := newTestModule(t)
+	m.cfg.GroupPermissions = true
+	m.SetMissingDependencies([]string{group.ModuleName})
+	// The table of the groups doesn't exist without the group module
+	require.NoError(t, db.PrepareTables(ctx, []schema.Tabler{&models.EffectivePermission{}}))
+
+	blockTime := time.Unix(1700000000, 0)
+	handleEvent(t, m, blockTime, &permissiontypes.EventPutPolicy{
+		PolicyId:     sdkmath.NewUint(7),
+		Principal:    &permissiontypes.Principal{Type: permissiontypes.PRINCIPAL_TYPE_GNFD_GROUP, Value: "5"},
+		ResourceType: 1,
+		ResourceId:   sdkmath.NewUint(42),
+		Statements: []*permissiontypes.Statement{
+			{Effect: permissiontypes.EFFECT_ALLOW, Actions: []permissiontypes.ActionType{permissiontypes.ACTION_GET_OBJECT}},
+		},
+	})
+
+	// The members added afterwards are still materialized out of the group events
+	bob := common.HexToAddress("0x02")
+	handleEvent(t, m, blockTime, &storagetypes.EventUpdateGroupMember{
+		GroupId:      sdkmath.NewUint(5),
+		MembersToAdd: []*storagetypes.EventGroupMemberDetail{{Member: bob.String()}},
+	})
+	permissions, err := m.ListEffectivePermissionsByAccount(ctx, bob, common.BigToHash(sdkmath.NewUint(42).BigInt()))
+	require.NoError(t, err)
+	require.Len(t, permissions, 1)
+
+	// The policies of the groups can't be verified
+	_, err = m.memberGroups(ctx, bob, blockTime)
+	require.Error(t, err)
+}
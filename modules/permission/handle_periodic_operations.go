@@ -0,0 +1,95 @@
+package permission
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-co-op/gocron"
+
+	"github.com/forbole/juno/v4/database"
+	"github.com/forbole/juno/v4/log"
+	"github.com/forbole/juno/v4/models"
+)
+
+const (
+	// SweepInterval is the interval, in minutes, between two sweeps of the expired policies and statements
+	SweepInterval = 10
+
+	// SweepBatchSize is the maximum number of rows marked as removed by a single statement of a sweep,
+	// bounding the time the rows stay locked
+	SweepBatchSize = 1000
+)
+
+// RegisterPeriodicOperations implements modules.PeriodicOperationsModule
+func (m *Module) RegisterPeriodicOperations(scheduler *gocron.Scheduler) error {
+	log.Debugw("setting up periodic tasks", "module", ModuleName)
+
+	_, err := scheduler.Every(SweepInterval).Minutes().Do(func() {
+		ctx := database.ReadFromPrimary(context.Background())
+		block, err := m.lastParsedBlock(ctx)
+		if err != nil {
+			log.Errorw("failed to get the last parsed block", "module", ModuleName, "err", err)
+			return
+		}
+		if block == nil {
+			return
+		}
+		if err := m.sweepExpired(ctx, int64(block.Height), int64(block.Timestamp)); err != nil {
+			log.Errorw("failed to sweep the expired policies", "module", ModuleName, "err", err)
+		}
+	})
+	return err
+}
+
+// lastParsedBlock returns the block of the epoch, every block up to which has been parsed, or the last stored
+// block when no epoch is stored. Nil is returned when no block is stored yet.
+func (m *Module) lastParsedBlock(ctx context.Context) (*models.Block, error) {
+	epoch, err := m.db.GetEpoch(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	height := uint64(epoch.BlockHeight)
+	if height == 0 {
+		if height, err = m.db.GetLastBlockHeight(ctx); err != nil || height == 0 {
+			return nil, err
+		}
+	}
+	return m.db.GetBlockByHeight(ctx, height)
+}
+
+// sweepExpired marks as removed at the given height the policies and statements expired before the given unix
+// time, the one of the block at that height, so that the sweep doesn't depend on when it runs and gets rolled
+// back along with the block
+func (m *Module) sweepExpired(ctx context.Context, height, blockTime int64) error {
+	permissions, err := sweep(ctx, (&models.Permission{}).TableName(), height, blockTime, m.db.ExpirePermissionsBefore)
+	if err != nil {
+		return fmt.Errorf("failed to expire the policies: %s", err)
+	}
+
+	statements, err := sweep(ctx, (&models.Statements{}).TableName(), height, blockTime, m.db.ExpireStatementsBefore)
+	if err != nil {
+		return fmt.Errorf("failed to expire the statements: %s", err)
+	}
+
+	log.Debugw("swept the expired policies", "module", ModuleName, "policies", permissions, "statements", statements)
+	return nil
+}
+
+// sweep calls expire with batches of SweepBatchSize rows until fewer rows than that are left expired in the
+// given table, and returns the number of rows marked as removed
+func sweep(ctx context.Context, table string, height, blockTime int64, expire func(context.Context, int64, int64, int) (int64, error)) (int64, error) {
+	var total int64
+	for {
+		expired, err := expire(ctx, height, blockTime, SweepBatchSize)
+		if err != nil {
+			return total, err
+		}
+
+		total += expired
+		log.ExpiredRows.WithLabelValues(table).Add(float64(expired))
+		if expired < SweepBatchSize {
+			return total, nil
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package permission
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm/schema"
+
+	"github.com/forbole/juno/v4/common"
+	"github.com/forbole/juno/v4/database"
+	"github.com/forbole/juno/v4/models"
+)
+
+func TestSweepExpired(t *testing.T) {
+	ctx := context.Background()
+	m, db := newTestModule(t)
+
+	// Expired, not expired yet and never expiring policies, each with a statement expiring alike
+	expirations := []int64{1000, 3000, 0}
+	for i, expiration := range expirations {
+		policyID := common.BigToHash(big.NewInt(int64(i + 1)))
+		require.NoError(t, db.SavePermission(ctx, &models.Permission{
+			PrincipalType: 1, PrincipalValue: "0x01", ResourceType: "RESOURCE_TYPE_BUCKET",
+			ResourceID: policyID, PolicyID: policyID, ExpirationTime: expiration,
+		}))
+		require.NoError(t, db.MultiSaveStatement(ctx, []*models.Statements{{PolicyID: policyID, ExpirationTime: expiration}}))
+	}
+
+	require.NoError(t, m.sweepExpired(ctx, 20, 2000))
+
+	for i, expiration := range expirations {
+		policyID := common.BigToHash(big.NewInt(int64(i + 1)))
+		expired := expiration == 1000
+
+		_, err := db.GetPermissionByPolicyID(ctx, policyID)
+		if expired {
+			require.ErrorIs(t, err, database.ErrPermissionNotFound)
+		} else {
+			require.NoError(t, err)
+		}
+
+		statements, err := db.GetStatementsByPolicyID(ctx, policyID, false)
+		require.NoError(t, err)
+		require.Equal(t, expired, len(statements) == 0, "policy %d", i+1)
+	}
+
+	// The expired statements are marked at the height of the sweep
+	statements, err := db.GetStatementsByPolicyID(ctx, common.BigToHash(big.NewInt(1)), true)
+	require.NoError(t, err)
+	require.Len(t, statements, 1)
+	require.Equal(t, int64(20), statements[0].UpdateAt)
+
+	// Sweeping again doesn't touch anything more
+	require.NoError(t, m.sweepExpired(ctx, 20, 2000))
+	_, err = db.GetPermissionByPolicyID(ctx, common.BigToHash(big.NewInt(2)))
+	require.NoError(t, err)
+}
+
+func TestLastParsedBlock(t *testing.T) {
+	ctx := context.Background()
+	m, db := newTestModule(t)
+	require.NoError(t, db.PrepareTables(ctx, []schema.Tabler{&models.Block{}, &models.Epoch{}}))
+
+	// Nothing is stored yet
+	block, err := m.lastParsedBlock(ctx)
+	require.NoError(t, err)
+	require.Nil(t, block)
+
+	// The last stored block stands for the epoch
+	for _, height := range []uint64{10, 12} {
+		require.NoError(t, db.SaveBlock(ctx, &models.Block{BlockID: models.BlockID{Hash: common.BigToHash(big.NewInt(int64(height)))},
+			Header: models.Header{Height: height, Timestamp: 1700000000 + height}}))
+	}
+	block, err = m.lastParsedBlock(ctx)
+	require.NoError(t, err)
+	require.Equal(t, uint64(12), block.Height)
+
+	require.NoError(t, db.SaveEpoch(ctx, &models.Epoch{OneRowId: true, BlockHeight: 10}))
+	block, err = m.lastParsedBlock(ctx)
+	require.NoError(t, err)
+	require.Equal(t, uint64(10), block.Height)
+	require.Equal(t, uint64(1700000010), block.Timestamp)
+}
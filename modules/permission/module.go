@@ -8,6 +8,8 @@ import (
 	"github.com/forbole/juno/v4/database"
 	"github.com/forbole/juno/v4/models"
 	"github.com/forbole/juno/v4/modules"
+	"github.com/forbole/juno/v4/modules/group"
+	"github.com/forbole/juno/v4/types/config"
 )
 
 const (
@@ -17,17 +19,35 @@ const (
 var (
 	_ modules.Module              = &Module{}
 	_ modules.PrepareTablesModule = &Module{}
+	_ modules.DependentModule     = &Module{}
+
+	_ modules.PeriodicOperationsModule = &Module{}
 )
 
 // Module represents the payment module
 type Module struct {
-	db database.Database
+	cfg *Config
+	db  database.Database
+
+	// missing holds the names of the modules the permission module relies on which are not running
+	missing map[string]bool
 }
 
 // NewModule builds a new Module instance
-func NewModule(db database.Database) *Module {
+func NewModule(cfg config.Config, db database.Database) *Module {
+	bz, err := cfg.GetBytes()
+	if err != nil {
+		panic(err)
+	}
+
+	permissionCfg, err := ParseConfig(bz)
+	if err != nil {
+		panic(err)
+	}
+
 	return &Module{
-		db: db,
+		cfg: permissionCfg,
+		db:  db,
 	}
 }
 
@@ -36,12 +56,39 @@ func (m *Module) Name() string {
 	return ModuleName
 }
 
+// Dependencies implements modules.DependentModule.
+// The policies put on a group apply to its members, which are stored by the group module.
+func (m *Module) Dependencies() []string {
+	return []string{group.ModuleName}
+}
+
+// SetMissingDependencies implements modules.DependentModule
+func (m *Module) SetMissingDependencies(names []string) {
+	m.missing = make(map[string]bool, len(names))
+	for _, name := range names {
+		m.missing[name] = true
+	}
+}
+
+// running tells whether the module having the given name, among the dependencies, runs along with this one
+func (m *Module) running(name string) bool {
+	return !m.missing[name]
+}
+
 // PrepareTables implements
 func (m *Module) PrepareTables() error {
-	return m.db.PrepareTables(context.TODO(), []schema.Tabler{&models.Permission{}, &models.Statements{}})
+	tables := []schema.Tabler{&models.Permission{}, &models.Statements{}}
+	if m.cfg.History {
+		tables = append(tables, &models.PermissionHistory{})
+		database.RegisterRollbackTable(&models.PermissionHistory{}, "height")
+	}
+	if m.cfg.GroupPermissions {
+		tables = append(tables, &models.EffectivePermission{})
+	}
+	return m.db.PrepareTables(context.TODO(), tables)
 }
 
 // AutoMigrate implements
 func (m *Module) AutoMigrate() error {
-	return m.db.AutoMigrate(context.TODO(), []schema.Tabler{&models.Statements{}})
+	return m.db.AutoMigrate(context.TODO(), []schema.Tabler{&models.Permission{}, &models.Statements{}})
 }
@@ -2,7 +2,9 @@ package permission
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 
 	abci "github.com/cometbft/cometbft/abci/types"
 	tmctypes "github.com/cometbft/cometbft/rpc/core/types"
@@ -11,6 +13,7 @@ import (
 	permissiontypes "github.com/evmos/evmos/v12/x/permission/types"
 
 	"github.com/forbole/juno/v4/common"
+	"github.com/forbole/juno/v4/database"
 	"github.com/forbole/juno/v4/log"
 	"github.com/forbole/juno/v4/models"
 )
@@ -25,26 +28,41 @@ var PolicyEvents = map[string]bool{
 	EventDeletePolicy: true,
 }
 
-var actionTypeMap = map[permissiontypes.ActionType]int{
-	permissiontypes.ACTION_TYPE_ALL:            0,
-	permissiontypes.ACTION_UPDATE_BUCKET_INFO:  1,
-	permissiontypes.ACTION_DELETE_BUCKET:       2,
-	permissiontypes.ACTION_CREATE_OBJECT:       3,
-	permissiontypes.ACTION_DELETE_OBJECT:       4,
-	permissiontypes.ACTION_COPY_OBJECT:         5,
-	permissiontypes.ACTION_GET_OBJECT:          6,
-	permissiontypes.ACTION_EXECUTE_OBJECT:      7,
-	permissiontypes.ACTION_LIST_OBJECT:         8,
-	permissiontypes.ACTION_UPDATE_GROUP_MEMBER: 9,
-	permissiontypes.ACTION_DELETE_GROUP:        10,
-	permissiontypes.ACTION_UPDATE_OBJECT_INFO:  11,
+// maxActionBit is the highest bit of the action value of a statement an action can be recorded into, the
+// action_value column being a signed 32 bits integer
+const maxActionBit = 30
+
+// actionTypeMap maps the action types to the bit recording them in the action value of a statement. It is built
+// out of the action types compiled in, so that the ones added by a chain upgrade are picked up along with it.
+var actionTypeMap = newActionTypeMap()
+
+// newActionTypeMap returns the bit of every action type: ACTION_TYPE_ALL is recorded into the bit 0, and the
+// other ones into the bit of their value, as long as it fits into the action value
+func newActionTypeMap() map[permissiontypes.ActionType]int {
+	actions := make(map[permissiontypes.ActionType]int, len(permissiontypes.ActionType_name))
+	for value := range permissiontypes.ActionType_name {
+		action := permissiontypes.ActionType(value)
+		switch {
+		case action == permissiontypes.ACTION_TYPE_ALL:
+			actions[action] = 0
+		case action == permissiontypes.ACTION_UNSPECIFIED:
+		case value <= maxActionBit:
+			actions[action] = int(value)
+		default:
+			log.Warnw("action type not fitting into the action value", "module", ModuleName, "action", action)
+		}
+	}
+	return actions
 }
 
 func (m *Module) ExtractEventStatements(ctx context.Context, block *tmctypes.ResultBlock, txHash common.Hash, event sdk.Event) (map[string][]interface{}, error) {
 	return nil, nil
 }
 
-func (m *Module) HandleEvent(ctx context.Context, block *tmctypes.ResultBlock, _ common.Hash, event sdk.Event) error {
+func (m *Module) HandleEvent(ctx context.Context, block *tmctypes.ResultBlock, txHash common.Hash, event sdk.Event) error {
+	if m.cfg.GroupPermissions && GroupEvents[event.Type] {
+		return m.handleGroupEvent(ctx, event)
+	}
 	if !PolicyEvents[event.Type] {
 		return nil
 	}
@@ -61,20 +79,20 @@ func (m *Module) HandleEvent(ctx context.Context, block *tmctypes.ResultBlock, _
 			log.Errorw("type assert error", "type", "EventCreateObject", "event", typedEvent)
 			return errors.New("put policy event assert error")
 		}
-		return m.handlePutPolicy(ctx, block, putPolicy)
+		return m.handlePutPolicy(ctx, block, txHash, putPolicy)
 	case EventDeletePolicy:
 		deletePolicy, ok := typedEvent.(*permissiontypes.EventDeletePolicy)
 		if !ok {
 			log.Errorw("type assert error", "type", "EventCancelCreateObject", "event", typedEvent)
 			return errors.New("cancel delete policy event assert error")
 		}
-		return m.handleDeletePolicy(ctx, block, deletePolicy)
+		return m.handleDeletePolicy(ctx, block, txHash, deletePolicy)
 	}
 
 	return nil
 }
 
-func (m *Module) handlePutPolicy(ctx context.Context, block *tmctypes.ResultBlock, policy *permissiontypes.EventPutPolicy) error {
+func (m *Module) handlePutPolicy(ctx context.Context, block *tmctypes.ResultBlock, txHash common.Hash, policy *permissiontypes.EventPutPolicy) error {
 	var expireTime int64
 	if policy.ExpirationTime == nil {
 		expireTime = 0
@@ -88,23 +106,38 @@ func (m *Module) handlePutPolicy(ctx context.Context, block *tmctypes.ResultBloc
 		ResourceID:      common.BigToHash(policy.ResourceId.BigInt()),
 		PolicyID:        common.BigToHash(policy.PolicyId.BigInt()),
 		CreateTimestamp: block.Block.Time.Unix(),
+		UpdateTimestamp: block.Block.Time.Unix(),
+		CreateAt:        block.Block.Height,
+		CreateTxHash:    txHash,
+		UpdateAt:        block.Block.Height,
+		UpdateTxHash:    txHash,
 		ExpirationTime:  expireTime,
+		Removed:         false,
 	}
 
 	statements := make([]*models.Statements, 0, 0)
 	for _, statement := range policy.Statements {
 		actionValue := 0
+		var unknownActions []int32
 		for _, action := range statement.Actions {
 			value, ok := actionTypeMap[action]
 			if !ok {
-				return errors.New("unknown action type action")
+				// Failing would stall the indexing on every policy using an action added by a chain upgrade
+				log.Warnw("unknown action type", "module", ModuleName, "policy id", policy.PolicyId, "action", action)
+				unknownActions = append(unknownActions, int32(action))
+				continue
 			}
 			actionValue |= 1 << value
 		}
 		s := &models.Statements{
-			PolicyID:    common.BigToHash(policy.PolicyId.BigInt()),
-			Effect:      statement.Effect.String(),
-			ActionValue: actionValue,
+			PolicyID:       common.BigToHash(policy.PolicyId.BigInt()),
+			Effect:         statement.Effect.String(),
+			ActionValue:    actionValue,
+			UnknownActions: unknownActions,
+			CreateAt:       block.Block.Height,
+			CreateTxHash:   txHash,
+			UpdateAt:       block.Block.Height,
+			UpdateTxHash:   txHash,
 		}
 		if statement.ExpirationTime != nil {
 			s.ExpirationTime = statement.ExpirationTime.UTC().Unix()
@@ -118,34 +151,104 @@ func (m *Module) handlePutPolicy(ctx context.Context, block *tmctypes.ResultBloc
 		statements = append(statements, s)
 	}
 
-	// begin transaction
-	tx := m.db.Begin(ctx)
-	err1 := tx.SavePermission(ctx, p)
-	err2 := tx.MultiSaveStatement(ctx, statements)
-	err3 := tx.Commit()
-	if err1 != nil || err2 != nil || err3 != nil {
-		tx.Rollback()
-		log.Errorw("failed to save policy", "permission err", err1, "statement err", err2, "commit err", err3)
-		return errors.New("save policy transaction failed")
+	err := database.WithTx(ctx, m.db, func(tx database.Database) error {
+		if err := tx.SavePermission(ctx, p); err != nil {
+			return err
+		}
+		previous, err := tx.GetStatementsByPolicyID(ctx, p.PolicyID, true)
+		if err != nil {
+			return err
+		}
+		// statements have no natural key, so the ones stored when this block was processed before are replaced
+		if err := tx.DeleteStatements(ctx, p.PolicyID); err != nil {
+			return err
+		}
+		if err := tx.MultiSaveStatement(ctx, statements); err != nil {
+			return err
+		}
+		if err := carryQuotas(ctx, tx, previous, statements); err != nil {
+			return err
+		}
+		if err := m.materializePolicy(ctx, tx, p, statements); err != nil {
+			return err
+		}
+		return m.saveHistory(ctx, tx, block, txHash, models.PermissionHistoryPut, p, statements)
+	})
+	if err != nil {
+		log.Errorw("failed to save policy", "policy id", p.PolicyID, "err", err)
+		return fmt.Errorf("save policy transaction failed: %w", err)
 	}
 	return nil
 }
 
-func (m *Module) handleDeletePolicy(ctx context.Context, block *tmctypes.ResultBlock, event *permissiontypes.EventDeletePolicy) error {
-	// begin transaction
-	tx := m.db.Begin(ctx)
+func (m *Module) handleDeletePolicy(ctx context.Context, block *tmctypes.ResultBlock, txHash common.Hash, event *permissiontypes.EventDeletePolicy) error {
 	policyIDHash := common.BigToHash(event.PolicyId.BigInt())
-	err1 := tx.UpdatePermission(ctx, &models.Permission{
-		PolicyID:        policyIDHash,
-		Removed:         true,
-		UpdateTimestamp: block.Block.Time.Unix(),
+
+	// Policies put before the indexed range, or already deleted by a previous run, have nothing to delete
+	permission, err := m.db.GetPermissionByPolicyID(ctx, policyIDHash)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			log.Warnw("policy to delete not found, skipping", "policy id", policyIDHash)
+			return nil
+		}
+		return err
+	}
+
+	err = database.WithTx(ctx, m.db, func(tx database.Database) error {
+		statements, err := tx.GetStatementsByPolicyID(ctx, policyIDHash, false)
+		if err != nil {
+			return err
+		}
+
+		err = tx.UpdatePermission(ctx, &models.Permission{
+			PolicyID:        policyIDHash,
+			Removed:         true,
+			UpdateTimestamp: block.Block.Time.Unix(),
+			UpdateAt:        block.Block.Height,
+			UpdateTxHash:    txHash,
+		}, "removed", "update_timestamp", "update_at", "update_tx_hash")
+		if err != nil {
+			return err
+		}
+		if err := tx.RemoveStatements(ctx, policyIDHash, block.Block.Height, txHash); err != nil {
+			return err
+		}
+		if m.cfg.GroupPermissions {
+			if err := tx.DeleteEffectivePermissions(ctx, policyIDHash); err != nil {
+				return err
+			}
+		}
+		return m.saveHistory(ctx, tx, block, txHash, models.PermissionHistoryDelete, permission, statements)
 	})
-	err2 := tx.RemoveStatements(ctx, policyIDHash)
-	err3 := tx.Commit()
-	if err1 != nil || err2 != nil || err3 != nil {
-		tx.Rollback()
-		log.Errorw("failed to delete policy", "permission err", err1, "statement err", err2, "commit err", err3)
-		return errors.New("delete policy transaction failed")
+	if err != nil {
+		log.Errorw("failed to delete policy", "policy id", policyIDHash, "err", err)
+		return fmt.Errorf("delete policy transaction failed: %w", err)
 	}
 	return nil
 }
+
+// saveHistory records the given operation on the policy, along with its statements, into the permission history
+// when enabled
+func (m *Module) saveHistory(ctx context.Context, tx database.Database, block *tmctypes.ResultBlock, txHash common.Hash,
+	operation string, permission *models.Permission, statements []*models.Statements) error {
+	if !m.cfg.History {
+		return nil
+	}
+
+	snapshot, err := json.Marshal(statements)
+	if err != nil {
+		return fmt.Errorf("failed to marshal statements: %w", err)
+	}
+	return tx.SavePermissionHistory(ctx, &models.PermissionHistory{
+		Operation:      operation,
+		PolicyID:       permission.PolicyID,
+		PrincipalType:  permission.PrincipalType,
+		PrincipalValue: permission.PrincipalValue,
+		ResourceType:   permission.ResourceType,
+		ResourceID:     permission.ResourceID,
+		Statements:     snapshot,
+		Height:         block.Block.Height,
+		TxHash:         txHash,
+		Timestamp:      block.Block.Time.Unix(),
+	})
+}
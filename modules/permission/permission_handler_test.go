@@ -0,0 +1,296 @@
+package permission
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	sdkmath "cosmossdk.io/math"
+	tmctypes "github.com/cometbft/cometbft/rpc/core/types"
+	tmtypes "github.com/cometbft/cometbft/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/gogoproto/proto"
+	mechaincommon "github.com/evmos/evmos/v12/types/common"
+	permissiontypes "github.com/evmos/evmos/v12/x/permission/types"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm/schema"
+
+	"github.com/forbole/juno/v4/common"
+	"github.com/forbole/juno/v4/database"
+	"github.com/forbole/juno/v4/database/sqlite/sqlitetest"
+	"github.com/forbole/juno/v4/models"
+)
+
+func newTestModule(t *testing.T) (*Module, *database.Impl) {
+	t.Helper()
+
+	db := sqlitetest.NewDatabase(t, &models.Permission{}, &models.Statements{})
+	return &Module{cfg: NewConfig(false, false), db: db}, db
+}
+
+func newTestBlock(timestamp time.Time) *tmctypes.ResultBlock {
+	return &tmctypes.ResultBlock{
+		Block: &tmtypes.Block{Header: tmtypes.Header{Height: 1, Time: timestamp}},
+	}
+}
+
+// handleEvent makes m handle the given event, emitted by a block made at timestamp
+func handleEvent(t *testing.T, m *Module, timestamp time.Time, event proto.Message) {
+	t.Helper()
+
+	sdkEvent, err := sdk.TypedEventToEvent(event)
+	require.NoError(t, err)
+	require.NoError(t, m.HandleEvent(context.Background(), newTestBlock(timestamp), common.Hash{}, sdkEvent))
+}
+
+func TestHandlePutPolicyStatements(t *testing.T) {
+	ctx := context.Background()
+	m, db := newTestModule(t)
+
+	expiration := time.Unix(1800000000, 0).UTC()
+	event := &permissiontypes.EventPutPolicy{
+		PolicyId:     sdkmath.NewUint(7),
+		Principal:    &permissiontypes.Principal{Type: permissiontypes.PRINCIPAL_TYPE_GNFD_ACCOUNT, Value: "0x01"},
+		ResourceType: 1,
+		ResourceId:   sdkmath.NewUint(42),
+		Statements: []*permissiontypes.Statement{
+			{
+				Effect:         permissiontypes.EFFECT_ALLOW,
+				Actions:        []permissiontypes.ActionType{permissiontypes.ACTION_GET_OBJECT, permissiontypes.ACTION_LIST_OBJECT},
+				Resources:      []string{"grn:o::bucket/*"},
+				ExpirationTime: &expiration,
+				LimitSize:      &mechaincommon.UInt64Value{Value: 1024},
+			},
+			{
+				// no resources, no limit size and no expiration time
+				Effect:  permissiontypes.EFFECT_DENY,
+				Actions: []permissiontypes.ActionType{permissiontypes.ACTION_DELETE_OBJECT},
+			},
+		},
+	}
+	require.NoError(t, m.handlePutPolicy(ctx, newTestBlock(time.Unix(1700000000, 0)), common.Hash{}, event))
+
+	// Processing the same block again must not duplicate the statements
+	require.NoError(t, m.handlePutPolicy(ctx, newTestBlock(time.Unix(1700000000, 0)), common.Hash{}, event))
+
+	policyID := common.BigToHash(event.PolicyId.BigInt())
+	statements, err := db.GetStatementsByPolicyID(ctx, policyID, false)
+	require.NoError(t, err)
+	require.Len(t, statements, 2)
+
+	require.Equal(t, permissiontypes.EFFECT_ALLOW.String(), statements[0].Effect)
+	require.Equal(t, 1<<6|1<<8, statements[0].ActionValue)
+	require.Equal(t, []string{"grn:o::bucket/*"}, []string(statements[0].Resources))
+	require.Equal(t, expiration.Unix(), statements[0].ExpirationTime)
+	require.Equal(t, uint64(1024), statements[0].LimitSize)
+
+	require.Equal(t, permissiontypes.EFFECT_DENY.String(), statements[1].Effect)
+	require.Equal(t, 1<<4, statements[1].ActionValue)
+	require.Empty(t, statements[1].Resources)
+	require.Zero(t, statements[1].ExpirationTime)
+	require.Zero(t, statements[1].LimitSize)
+
+	require.NoError(t, m.handleDeletePolicy(ctx, newTestBlock(time.Unix(1700000100, 0)), common.Hash{}, &permissiontypes.EventDeletePolicy{PolicyId: event.PolicyId}))
+
+	statements, err = db.GetStatementsByPolicyID(ctx, policyID, false)
+	require.NoError(t, err)
+	require.Empty(t, statements)
+
+	statements, err = db.GetStatementsByPolicyID(ctx, policyID, true)
+	require.NoError(t, err)
+	require.Len(t, statements, 2)
+	require.True(t, statements[0].Removed)
+}
+
+func TestHandlePutPolicyAfterDelete(t *testing.T) {
+	ctx := context.Background()
+	m, db := newTestModule(t)
+
+	newEvent := func(policyID uint64) *permissiontypes.EventPutPolicy {
+		return &permissiontypes.EventPutPolicy{
+			PolicyId:     sdkmath.NewUint(policyID),
+			Principal:    &permissiontypes.Principal{Type: permissiontypes.PRINCIPAL_TYPE_GNFD_ACCOUNT, Value: "0x01"},
+			ResourceType: 1,
+			ResourceId:   sdkmath.NewUint(42),
+			Statements: []*permissiontypes.Statement{
+				{Effect: permissiontypes.EFFECT_ALLOW, Actions: []permissiontypes.ActionType{permissiontypes.ACTION_GET_OBJECT}},
+			},
+		}
+	}
+
+	require.NoError(t, m.handlePutPolicy(ctx, newTestBlock(time.Unix(1700000000, 0)), common.Hash{}, newEvent(7)))
+	require.NoError(t, m.handleDeletePolicy(ctx, newTestBlock(time.Unix(1700000100, 0)), common.Hash{}, &permissiontypes.EventDeletePolicy{
+		PolicyId: sdkmath.NewUint(7),
+	}))
+	require.NoError(t, m.handlePutPolicy(ctx, newTestBlock(time.Unix(1700000200, 0)), common.Hash{}, newEvent(8)))
+
+	oldPolicyID := common.BigToHash(sdkmath.NewUint(7).BigInt())
+	newPolicyID := common.BigToHash(sdkmath.NewUint(8).BigInt())
+
+	_, err := db.GetPermissionByPolicyID(ctx, oldPolicyID)
+	require.ErrorIs(t, err, database.ErrPermissionNotFound)
+
+	permission, err := db.GetPermissionByPolicyID(ctx, newPolicyID)
+	require.NoError(t, err)
+	require.False(t, permission.Removed)
+	require.Equal(t, int64(1700000200), permission.CreateTimestamp)
+	require.Equal(t, int64(1700000200), permission.UpdateTimestamp)
+
+	permissions, err := db.GetPermissionsByResource(ctx, permission.ResourceType, permission.ResourceID)
+	require.NoError(t, err)
+	require.Len(t, permissions, 1)
+	require.Equal(t, newPolicyID, permissions[0].PolicyID)
+
+	statements, err := db.GetStatementsByPolicyID(ctx, newPolicyID, false)
+	require.NoError(t, err)
+	require.Len(t, statements, 1)
+}
+
+func TestHandleDeleteUnknownPolicy(t *testing.T) {
+	ctx := context.Background()
+	m, db := newTestModule(t)
+
+	policyID := sdkmath.NewUint(9)
+	require.NoError(t, m.handleDeletePolicy(ctx, newTestBlock(time.Unix(1700000000, 0)), common.Hash{}, &permissiontypes.EventDeletePolicy{PolicyId: policyID}))
+
+	_, err := db.GetPermissionByPolicyID(ctx, common.BigToHash(policyID.BigInt()))
+	require.ErrorIs(t, err, database.ErrNotFound)
+}
+
+func TestHandlePutPolicyUnknownActions(t *testing.T) {
+	ctx := context.Background()
+	m, db := newTestModule(t)
+
+	event := &permissiontypes.EventPutPolicy{
+		PolicyId:     sdkmath.NewUint(7),
+		Principal:    &permissiontypes.Principal{Type: permissiontypes.PRINCIPAL_TYPE_GNFD_ACCOUNT, Value: "0x01"},
+		ResourceType: 1,
+		ResourceId:   sdkmath.NewUint(42),
+		Statements: []*permissiontypes.Statement{
+			{
+				Effect: permissiontypes.EFFECT_ALLOW,
+				Actions: []permissiontypes.ActionType{
+					permissiontypes.ACTION_UPDATE_OBJECT_CONTENT, permissiontypes.ActionType(42), permissiontypes.ACTION_TYPE_ALL,
+				},
+			},
+		},
+	}
+	require.NoError(t, m.handlePutPolicy(ctx, newTestBlock(time.Unix(1700000000, 0)), common.Hash{}, event))
+
+	policyID := common.BigToHash(event.PolicyId.BigInt())
+	_, err := db.GetPermissionByPolicyID(ctx, policyID)
+	require.NoError(t, err)
+
+	statements, err := db.GetStatementsByPolicyID(ctx, policyID, false)
+	require.NoError(t, err)
+	require.Len(t, statements, 1)
+	require.Equal(t, 1<<14|1<<0, statements[0].ActionValue)
+	require.Equal(t, []int32{42}, []int32(statements[0].UnknownActions))
+}
+
+func TestPolicyProvenance(t *testing.T) {
+	ctx := context.Background()
+	m, db := newTestModule(t)
+
+	event := &permissiontypes.EventPutPolicy{
+		PolicyId:     sdkmath.NewUint(7),
+		Principal:    &permissiontypes.Principal{Type: permissiontypes.PRINCIPAL_TYPE_GNFD_ACCOUNT, Value: "0x01"},
+		ResourceType: 1,
+		ResourceId:   sdkmath.NewUint(42),
+		Statements: []*permissiontypes.Statement{
+			{Effect: permissiontypes.EFFECT_ALLOW, Actions: []permissiontypes.ActionType{permissiontypes.ACTION_GET_OBJECT}},
+		},
+	}
+	putBlock := newTestBlock(time.Unix(1700000000, 0))
+	putBlock.Block.Height = 10
+	putTxHash := common.HexToHash("0x0a")
+	require.NoError(t, m.handlePutPolicy(ctx, putBlock, putTxHash, event))
+
+	deleteBlock := newTestBlock(time.Unix(1700000100, 0))
+	deleteBlock.Block.Height = 20
+	deleteTxHash := common.HexToHash("0x14")
+	require.NoError(t, m.handleDeletePolicy(ctx, deleteBlock, deleteTxHash, &permissiontypes.EventDeletePolicy{PolicyId: event.PolicyId}))
+
+	policyID := common.BigToHash(event.PolicyId.BigInt())
+	var permission models.Permission
+	require.NoError(t, db.Db.Where("policy_id = ?", policyID).Take(&permission).Error)
+	require.True(t, permission.Removed)
+	require.Equal(t, int64(10), permission.CreateAt)
+	require.Equal(t, putTxHash, permission.CreateTxHash)
+	require.Equal(t, int64(20), permission.UpdateAt)
+	require.Equal(t, deleteTxHash, permission.UpdateTxHash)
+
+	statements, err := db.GetStatementsByPolicyID(ctx, policyID, true)
+	require.NoError(t, err)
+	require.Len(t, statements, 1)
+	require.Equal(t, int64(10), statements[0].CreateAt)
+	require.Equal(t, putTxHash, statements[0].CreateTxHash)
+	require.Equal(t, int64(20), statements[0].UpdateAt)
+	require.Equal(t, deleteTxHash, statements[0].UpdateTxHash)
+}
+
+func TestPermissionHistory(t *testing.T) {
+	ctx := context.Background()
+	m, db := newTestModule(t)
+	m.cfg.History = true
+	require.NoError(t, db.PrepareTables(ctx, []schema.Tabler{&models.PermissionHistory{}}))
+
+	newBlock := func(height int64) *tmctypes.ResultBlock {
+		block := newTestBlock(time.Unix(1700000000+height, 0))
+		block.Block.Height = height
+		return block
+	}
+	newEvent := func(action permissiontypes.ActionType) *permissiontypes.EventPutPolicy {
+		return &permissiontypes.EventPutPolicy{
+			PolicyId:     sdkmath.NewUint(7),
+			Principal:    &permissiontypes.Principal{Type: permissiontypes.PRINCIPAL_TYPE_GNFD_ACCOUNT, Value: "0x01"},
+			ResourceType: 1,
+			ResourceId:   sdkmath.NewUint(42),
+			Statements: []*permissiontypes.Statement{
+				{Effect: permissiontypes.EFFECT_ALLOW, Actions: []permissiontypes.ActionType{action}},
+			},
+		}
+	}
+
+	require.NoError(t, m.handlePutPolicy(ctx, newBlock(10), common.HexToHash("0x0a"), newEvent(permissiontypes.ACTION_GET_OBJECT)))
+	require.NoError(t, m.handleDeletePolicy(ctx, newBlock(20), common.HexToHash("0x14"), &permissiontypes.EventDeletePolicy{
+		PolicyId: sdkmath.NewUint(7),
+	}))
+	require.NoError(t, m.handlePutPolicy(ctx, newBlock(30), common.HexToHash("0x1e"), newEvent(permissiontypes.ACTION_LIST_OBJECT)))
+	// Processing a block again doesn't record its operations twice
+	require.NoError(t, m.handlePutPolicy(ctx, newBlock(30), common.HexToHash("0x1e"), newEvent(permissiontypes.ACTION_LIST_OBJECT)))
+
+	resourceID := common.BigToHash(sdkmath.NewUint(42).BigInt())
+	history, err := db.ListPermissionHistory(ctx, resourceID, 0, 100)
+	require.NoError(t, err)
+	require.Len(t, history, 3)
+
+	for i, expected := range []struct {
+		operation string
+		height    int64
+		txHash    common.Hash
+		action    int
+	}{
+		{models.PermissionHistoryPut, 10, common.HexToHash("0x0a"), 1 << 6},
+		{models.PermissionHistoryDelete, 20, common.HexToHash("0x14"), 1 << 6},
+		{models.PermissionHistoryPut, 30, common.HexToHash("0x1e"), 1 << 8},
+	} {
+		require.Equal(t, expected.operation, history[i].Operation)
+		require.Equal(t, expected.height, history[i].Height)
+		require.Equal(t, expected.txHash, history[i].TxHash)
+		require.Equal(t, "0x01", history[i].PrincipalValue)
+		require.Equal(t, common.BigToHash(sdkmath.NewUint(7).BigInt()), history[i].PolicyID)
+
+		var statements []*models.Statements
+		require.NoError(t, json.Unmarshal(history[i].Statements, &statements))
+		require.Len(t, statements, 1)
+		require.Equal(t, expected.action, statements[0].ActionValue)
+	}
+
+	// The heights are bounds of the range listed
+	history, err = db.ListPermissionHistory(ctx, resourceID, 20, 29)
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	require.Equal(t, models.PermissionHistoryDelete, history[0].Operation)
+}
@@ -0,0 +1,127 @@
+package permission
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	resourcetypes "github.com/evmos/evmos/v12/types/resource"
+	permissiontypes "github.com/evmos/evmos/v12/x/permission/types"
+
+	"github.com/forbole/juno/v4/common"
+	"github.com/forbole/juno/v4/database"
+	"github.com/forbole/juno/v4/models"
+)
+
+// ConsumeQuota consumes the payload size of the given sealed object out of the limit of the statement its
+// creator was granted the creation under, if any, and records that statement on the object.
+// The policy of the creator on the bucket is evaluated as Policy.Eval does on chain, ignoring what is expired
+// when the object was created: nothing is consumed when any of its statements denies the creation of the object.
+// Otherwise the size is consumed out of the first statement, in insertion order, allowing the creation and having a
+// limit; the chain consumes it out of every such statement, but an object records a single one. Nothing is
+// consumed either when the creation is granted through a group. An object having consumed its size already is left
+// untouched, so that handling its seal again doesn't count it twice.
+// An error is returned if the operation fails.
+func ConsumeQuota(ctx context.Context, db database.Database, objectID common.Hash) error {
+	object, err := db.GetObject(ctx, objectID)
+	if errors.Is(err, database.ErrObjectNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if object.QuotaStatementID != 0 {
+		return nil
+	}
+
+	statement, err := quotaStatement(ctx, db, object)
+	if err != nil || statement == nil {
+		return err
+	}
+
+	if err := db.ConsumeStatementQuota(ctx, statement.ID, object.PayloadSize); err != nil {
+		return fmt.Errorf("failed to consume quota of statement %d: %s", statement.ID, err)
+	}
+	return db.UpdateObject(ctx, &models.Object{ObjectID: object.ObjectID, QuotaStatementID: statement.ID}, "quota_statement_id")
+}
+
+// ReleaseQuota gives back the payload size of the given object to the statement it has been consumed from, if
+// any, once the object is deleted.
+// An error is returned if the operation fails.
+func ReleaseQuota(ctx context.Context, db database.Database, object *models.Object) error {
+	if object.QuotaStatementID == 0 {
+		return nil
+	}
+
+	if err := db.ReleaseStatementQuota(ctx, object.QuotaStatementID, object.PayloadSize); err != nil {
+		return fmt.Errorf("failed to release quota of statement %d: %s", object.QuotaStatementID, err)
+	}
+	return db.UpdateObject(ctx, &models.Object{ObjectID: object.ObjectID}, "quota_statement_id")
+}
+
+// carryQuotas carries the size consumed out of the limits of the previous statements of a policy put again over
+// to its new statements, matching them by position, and moves the objects having consumed it along.
+// The objects of a previous statement removed, or left without a limited statement at its position, consume no
+// limit anymore.
+// An error is returned if the operation fails.
+func carryQuotas(ctx context.Context, db database.Database, previous, statements []*models.Statements) error {
+	for index, statement := range previous {
+		// No object consumes anything out of a statement without a limit
+		if statement.LimitSize == 0 {
+			continue
+		}
+
+		var toID uint64
+		if !statement.Removed && index < len(statements) && statements[index].LimitSize != 0 {
+			toID = statements[index].ID
+		}
+		if err := db.MoveStatementQuota(ctx, statement.ID, toID, statement.ConsumedSize); err != nil {
+			return fmt.Errorf("failed to carry quota of statement %d over: %s", statement.ID, err)
+		}
+	}
+	return nil
+}
+
+// quotaStatement returns the statement with a size limit the given object has been created under, as described
+// in ConsumeQuota, or nil if there is none
+func quotaStatement(ctx context.Context, db database.Database, object *models.Object) (*models.Statements, error) {
+	permissions, err := db.GetPermissionsByResource(ctx, resourcetypes.RESOURCE_TYPE_BUCKET.String(), object.BucketID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the policies of the bucket: %s", err)
+	}
+
+	createdAt := time.Unix(object.CreateTime, 0)
+	grn := fmt.Sprintf("grn:o::%s/%s", object.BucketName, object.ObjectName)
+	for _, permission := range permissions {
+		if permissiontypes.PrincipalType(permission.PrincipalType) != permissiontypes.PRINCIPAL_TYPE_GNFD_ACCOUNT ||
+			common.HexToAddress(permission.PrincipalValue) != object.Creator || isExpired(permission.ExpirationTime, createdAt) {
+			continue
+		}
+
+		statements, err := db.GetStatementsByPolicyID(ctx, permission.PolicyID, false)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the statements of policy %s: %s", permission.PolicyID, err)
+		}
+
+		var quota *models.Statements
+		for _, statement := range statements {
+			if isExpired(statement.ExpirationTime, createdAt) {
+				continue
+			}
+			switch evalStatement(statement, grn, permissiontypes.ACTION_CREATE_OBJECT) {
+			case permissiontypes.EFFECT_DENY:
+				// An explicit deny prevails over the statements allowing the creation
+				return nil, nil
+			case permissiontypes.EFFECT_ALLOW:
+				if quota == nil && statement.LimitSize != 0 {
+					quota = statement
+				}
+			}
+		}
+		if quota != nil {
+			return quota, nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,203 @@
+package permission
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	sdkmath "cosmossdk.io/math"
+	mechaincommon "github.com/evmos/evmos/v12/types/common"
+	permissiontypes "github.com/evmos/evmos/v12/x/permission/types"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm/schema"
+
+	"github.com/forbole/juno/v4/common"
+	"github.com/forbole/juno/v4/models"
+)
+
+func TestStatementQuota(t *testing.T) {
+	ctx := context.Background()
+	m, db := newTestModule(t)
+	require.NoError(t, db.PrepareTables(ctx, []schema.Tabler{&models.Object{}}))
+
+	alice, bob := common.HexToAddress("0x01"), common.HexToAddress("0x02")
+	bucketID := common.BigToHash(sdkmath.NewUint(1).BigInt())
+	expired := time.Unix(100, 0)
+
+	require.NoError(t, m.handlePutPolicy(ctx, newTestBlock(time.Unix(50, 0)), common.Hash{}, &permissiontypes.EventPutPolicy{
+		PolicyId:     sdkmath.NewUint(7),
+		Principal:    &permissiontypes.Principal{Type: permissiontypes.PRINCIPAL_TYPE_GNFD_ACCOUNT, Value: alice.String()},
+		ResourceType: 1,
+		ResourceId:   sdkmath.NewUint(1),
+		Statements: []*permissiontypes.Statement{
+			{
+				// expired by the time the objects get created
+				Effect: permissiontypes.EFFECT_ALLOW, Actions: []permissiontypes.ActionType{permissiontypes.ACTION_CREATE_OBJECT},
+				Resources: []string{".*"}, ExpirationTime: &expired, LimitSize: &mechaincommon.UInt64Value{Value: 10},
+			},
+			{
+				// no resources, so never applying to the creation of an object
+				Effect: permissiontypes.EFFECT_ALLOW, Actions: []permissiontypes.ActionType{permissiontypes.ACTION_CREATE_OBJECT},
+				LimitSize: &mechaincommon.UInt64Value{Value: 50},
+			},
+			{
+				Effect: permissiontypes.EFFECT_ALLOW, Actions: []permissiontypes.ActionType{permissiontypes.ACTION_CREATE_OBJECT},
+				Resources: []string{"^grn:o::bucket/logs/.*"}, LimitSize: &mechaincommon.UInt64Value{Value: 100},
+			},
+			{
+				// matching every object, but only applying to the ones the previous statement doesn't match
+				Effect: permissiontypes.EFFECT_ALLOW, Actions: []permissiontypes.ActionType{permissiontypes.ACTION_TYPE_ALL},
+				Resources: []string{".*"}, LimitSize: &mechaincommon.UInt64Value{Value: 1000},
+			},
+		},
+	}))
+
+	objects := []*models.Object{
+		{ObjectID: common.HexToHash("0x0a"), BucketID: bucketID, BucketName: "bucket", ObjectName: "logs/a", Creator: alice, PayloadSize: 30},
+		{ObjectID: common.HexToHash("0x0b"), BucketID: bucketID, BucketName: "bucket", ObjectName: "data/b", Creator: alice, PayloadSize: 200},
+		{ObjectID: common.HexToHash("0x0c"), BucketID: bucketID, BucketName: "bucket", ObjectName: "logs/c", Creator: bob, PayloadSize: 10},
+	}
+	for _, object := range objects {
+		object.CreateTime = 150
+		require.NoError(t, db.SaveObject(ctx, object))
+		require.NoError(t, ConsumeQuota(ctx, db, object.ObjectID))
+	}
+	// Sealing an object again doesn't consume its size twice
+	require.NoError(t, ConsumeQuota(ctx, db, objects[0].ObjectID))
+
+	usage := func() []uint64 {
+		statements, err := db.GetStatementQuotaUsage(ctx, common.BigToHash(sdkmath.NewUint(7).BigInt()))
+		require.NoError(t, err)
+		consumed := make([]uint64, 0, len(statements))
+		for _, statement := range statements {
+			consumed = append(consumed, statement.ConsumedSize)
+		}
+		return consumed
+	}
+	require.Equal(t, []uint64{0, 0, 30, 200}, usage())
+
+	// Deleting an object gives its size back, once
+	for i := 0; i < 2; i++ {
+		stored, err := db.GetObject(ctx, objects[0].ObjectID)
+		require.NoError(t, err)
+		require.NoError(t, ReleaseQuota(ctx, db, stored))
+	}
+	require.Equal(t, []uint64{0, 0, 0, 200}, usage())
+}
+
+func TestStatementQuotaPolicyPutAgain(t *testing.T) {
+	ctx := context.Background()
+	m, db := newTestModule(t)
+	require.NoError(t, db.PrepareTables(ctx, []schema.Tabler{&models.Object{}}))
+
+	alice := common.HexToAddress("0x01")
+	policyID := common.BigToHash(sdkmath.NewUint(8).BigInt())
+	putPolicy := func(blockTime int64, limitSize uint64) {
+		statement := &permissiontypes.Statement{
+			Effect: permissiontypes.EFFECT_ALLOW, Actions: []permissiontypes.ActionType{permissiontypes.ACTION_CREATE_OBJECT},
+			Resources: []string{".*"},
+		}
+		if limitSize != 0 {
+			statement.LimitSize = &mechaincommon.UInt64Value{Value: limitSize}
+		}
+		require.NoError(t, m.handlePutPolicy(ctx, newTestBlock(time.Unix(blockTime, 0)), common.Hash{}, &permissiontypes.EventPutPolicy{
+			PolicyId:     sdkmath.NewUint(8),
+			Principal:    &permissiontypes.Principal{Type: permissiontypes.PRINCIPAL_TYPE_GNFD_ACCOUNT, Value: alice.String()},
+			ResourceType: 1,
+			ResourceId:   sdkmath.NewUint(1),
+			Statements:   []*permissiontypes.Statement{statement},
+		}))
+	}
+	// usage returns the size consumed out of the single statement of the policy, checking the object points at it
+	usage := func(objectID common.Hash) uint64 {
+		statements, err := db.GetStatementQuotaUsage(ctx, policyID)
+		require.NoError(t, err)
+		require.Len(t, statements, 1)
+		object, err := db.GetObject(ctx, objectID)
+		require.NoError(t, err)
+		require.Equal(t, statements[0].ID, object.QuotaStatementID)
+		return statements[0].ConsumedSize
+	}
+
+	putPolicy(50, 100)
+	object := &models.Object{
+		ObjectID: common.HexToHash("0x0a"), BucketID: common.BigToHash(sdkmath.NewUint(1).BigInt()), BucketName: "bucket",
+		ObjectName: "a", Creator: alice, PayloadSize: 30, CreateTime: 150,
+	}
+	require.NoError(t, db.SaveObject(ctx, object))
+	require.NoError(t, ConsumeQuota(ctx, db, object.ObjectID))
+	require.Equal(t, uint64(30), usage(object.ObjectID))
+
+	// Putting the policy again keeps the size consumed, the object following its statement
+	putPolicy(200, 100)
+	require.Equal(t, uint64(30), usage(object.ObjectID))
+
+	// Deleting the object gives its size back to the new statement
+	stored, err := db.GetObject(ctx, object.ObjectID)
+	require.NoError(t, err)
+	require.NoError(t, ReleaseQuota(ctx, db, stored))
+	statements, err := db.GetStatementQuotaUsage(ctx, policyID)
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), statements[0].ConsumedSize)
+
+	// Putting it without a limit leaves the objects consuming none
+	require.NoError(t, ConsumeQuota(ctx, db, object.ObjectID))
+	putPolicy(300, 0)
+	stored, err = db.GetObject(ctx, object.ObjectID)
+	require.NoError(t, err)
+	require.Zero(t, stored.QuotaStatementID)
+}
+
+func TestStatementQuotaDenyPrevails(t *testing.T) {
+	ctx := context.Background()
+	m, db := newTestModule(t)
+	require.NoError(t, db.PrepareTables(ctx, []schema.Tabler{&models.Object{}}))
+
+	alice := common.HexToAddress("0x01")
+	require.NoError(t, m.handlePutPolicy(ctx, newTestBlock(time.Unix(50, 0)), common.Hash{}, &permissiontypes.EventPutPolicy{
+		PolicyId:     sdkmath.NewUint(9),
+		Principal:    &permissiontypes.Principal{Type: permissiontypes.PRINCIPAL_TYPE_GNFD_ACCOUNT, Value: alice.String()},
+		ResourceType: 1,
+		ResourceId:   sdkmath.NewUint(1),
+		Statements: []*permissiontypes.Statement{
+			{
+				// allowing every creation, but without a limit
+				Effect: permissiontypes.EFFECT_ALLOW, Actions: []permissiontypes.ActionType{permissiontypes.ACTION_CREATE_OBJECT},
+				Resources: []string{".*"},
+			},
+			{
+				Effect: permissiontypes.EFFECT_ALLOW, Actions: []permissiontypes.ActionType{permissiontypes.ACTION_CREATE_OBJECT},
+				Resources: []string{".*"}, LimitSize: &mechaincommon.UInt64Value{Value: 500},
+			},
+			{
+				Effect: permissiontypes.EFFECT_DENY, Actions: []permissiontypes.ActionType{permissiontypes.ACTION_CREATE_OBJECT},
+				Resources: []string{"^grn:o::bucket/secret/.*"},
+			},
+		},
+	}))
+
+	objects := []*models.Object{
+		{ObjectID: common.HexToHash("0x0a"), ObjectName: "logs/a", PayloadSize: 30},
+		{ObjectID: common.HexToHash("0x0b"), ObjectName: "secret/b", PayloadSize: 200},
+	}
+	for _, object := range objects {
+		object.BucketID, object.BucketName = common.BigToHash(sdkmath.NewUint(1).BigInt()), "bucket"
+		object.Creator, object.CreateTime = alice, 150
+		require.NoError(t, db.SaveObject(ctx, object))
+		require.NoError(t, ConsumeQuota(ctx, db, object.ObjectID))
+	}
+
+	// The limited statement is consumed even though an earlier one allows the creation, and nothing is consumed
+	// for the creation denied by a later one
+	statements, err := db.GetStatementQuotaUsage(ctx, common.BigToHash(sdkmath.NewUint(9).BigInt()))
+	require.NoError(t, err)
+	consumed := make([]uint64, 0, len(statements))
+	for _, statement := range statements {
+		consumed = append(consumed, statement.ConsumedSize)
+	}
+	require.Equal(t, []uint64{30}, consumed)
+
+	denied, err := db.GetObject(ctx, objects[1].ObjectID)
+	require.NoError(t, err)
+	require.Zero(t, denied.QuotaStatementID)
+}
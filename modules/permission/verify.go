@@ -0,0 +1,186 @@
+package permission
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"time"
+
+	permissiontypes "github.com/evmos/evmos/v12/x/permission/types"
+
+	"github.com/forbole/juno/v4/common"
+	"github.com/forbole/juno/v4/models"
+	"github.com/forbole/juno/v4/modules/group"
+)
+
+// VerifyPermission tells, out of the indexed policies, the effect of the policies attached to the given resource
+// on the principal performing the given action at the given time, evaluating them as the chain does:
+// the policy of the principal itself prevails, then the policies of the groups the principal is a member of,
+// any of them denying the action prevailing over the ones allowing it.
+// EFFECT_UNSPECIFIED is returned when no policy tells anything about the action.
+// An error is returned if the policies can't be read.
+func (m *Module) VerifyPermission(ctx context.Context, principal common.Address, resourceType string, resourceID common.Hash,
+	action permissiontypes.ActionType, at time.Time) (permissiontypes.Effect, error) {
+	return m.VerifyPermissionOnResource(ctx, principal, resourceType, resourceID, "", action, at)
+}
+
+// VerifyPermissionOnResource behaves like VerifyPermission for the sub-resource with the given name, such as
+// an object of a bucket, only the statements whose resource patterns match it being taken into account.
+// The whole resource is meant when the name is empty.
+func (m *Module) VerifyPermissionOnResource(ctx context.Context, principal common.Address, resourceType string, resourceID common.Hash,
+	resource string, action permissiontypes.ActionType, at time.Time) (permissiontypes.Effect, error) {
+	permissions, err := m.db.GetPermissionsByResource(ctx, resourceType, resourceID)
+	if err != nil {
+		return permissiontypes.EFFECT_UNSPECIFIED, fmt.Errorf("failed to get the policies of the resource: %s", err)
+	}
+
+	var groupPermissions []*models.Permission
+	for _, permission := range permissions {
+		switch permissiontypes.PrincipalType(permission.PrincipalType) {
+		case permissiontypes.PRINCIPAL_TYPE_GNFD_ACCOUNT:
+			if common.HexToAddress(permission.PrincipalValue) != principal {
+				continue
+			}
+			effect, err := m.evalPermission(ctx, permission, resource, action, at)
+			if err != nil || effect != permissiontypes.EFFECT_UNSPECIFIED {
+				return effect, err
+			}
+		case permissiontypes.PRINCIPAL_TYPE_GNFD_GROUP:
+			groupPermissions = append(groupPermissions, permission)
+		}
+	}
+	if len(groupPermissions) == 0 {
+		return permissiontypes.EFFECT_UNSPECIFIED, nil
+	}
+
+	groups, err := m.memberGroups(ctx, principal, at)
+	if err != nil {
+		return permissiontypes.EFFECT_UNSPECIFIED, err
+	}
+
+	allowed := false
+	for _, permission := range groupPermissions {
+		groupID, ok := new(big.Int).SetString(permission.PrincipalValue, 10)
+		if !ok || !groups[common.BigToHash(groupID)] {
+			continue
+		}
+
+		effect, err := m.evalPermission(ctx, permission, resource, action, at)
+		if err != nil {
+			return permissiontypes.EFFECT_UNSPECIFIED, err
+		}
+		switch effect {
+		case permissiontypes.EFFECT_DENY:
+			return permissiontypes.EFFECT_DENY, nil
+		case permissiontypes.EFFECT_ALLOW:
+			allowed = true
+		}
+	}
+	if allowed {
+		return permissiontypes.EFFECT_ALLOW, nil
+	}
+	return permissiontypes.EFFECT_UNSPECIFIED, nil
+}
+
+// memberGroups returns the ids of the groups the given account is a member of at the given time
+func (m *Module) memberGroups(ctx context.Context, account common.Address, at time.Time) (map[common.Hash]bool, error) {
+	if !m.running(group.ModuleName) {
+		return nil, errors.New("the groups are not indexed, the group module is not running")
+	}
+
+	rows, err := m.db.ListGroupsByAccount(ctx, account)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the groups of the account: %s", err)
+	}
+
+	groups := make(map[common.Hash]bool, len(rows))
+	for _, row := range rows {
+		// The rows of the groups owned by the account don't make it a member of them
+		if row.AccountID != account || isExpired(row.ExpirationTime, at) {
+			continue
+		}
+		groups[row.GroupID] = true
+	}
+	return groups, nil
+}
+
+// evalPermission returns the effect of the given policy on the action, as Policy.Eval does on chain: any of its
+// statements denying the action prevails over the ones allowing it, the expired ones being ignored
+func (m *Module) evalPermission(ctx context.Context, permission *models.Permission, resource string,
+	action permissiontypes.ActionType, at time.Time) (permissiontypes.Effect, error) {
+	if isExpired(permission.ExpirationTime, at) {
+		return permissiontypes.EFFECT_UNSPECIFIED, nil
+	}
+
+	statements, err := m.db.GetStatementsByPolicyID(ctx, permission.PolicyID, false)
+	if err != nil {
+		return permissiontypes.EFFECT_UNSPECIFIED, fmt.Errorf("failed to get the statements of policy %s: %s", permission.PolicyID, err)
+	}
+
+	allowed := false
+	for _, statement := range statements {
+		if isExpired(statement.ExpirationTime, at) {
+			continue
+		}
+		switch evalStatement(statement, resource, action) {
+		case permissiontypes.EFFECT_DENY:
+			return permissiontypes.EFFECT_DENY, nil
+		case permissiontypes.EFFECT_ALLOW:
+			allowed = true
+		}
+	}
+	if allowed {
+		return permissiontypes.EFFECT_ALLOW, nil
+	}
+	return permissiontypes.EFFECT_UNSPECIFIED, nil
+}
+
+// evalStatement returns the effect of the given statement on the action, as Statement.Eval does on chain.
+// When a sub-resource is given, only the statements having a resource pattern matching it apply.
+func evalStatement(statement *models.Statements, resource string, action permissiontypes.ActionType) permissiontypes.Effect {
+	if resource != "" {
+		if len(statement.Resources) == 0 || !matchesAny(statement.Resources, resource) {
+			return permissiontypes.EFFECT_UNSPECIFIED
+		}
+	}
+
+	if !hasAction(statement, action) {
+		return permissiontypes.EFFECT_UNSPECIFIED
+	}
+	return permissiontypes.Effect(permissiontypes.Effect_value[statement.Effect])
+}
+
+// hasAction tells whether the given statement applies to the action, recorded as handlePutPolicy does
+func hasAction(statement *models.Statements, action permissiontypes.ActionType) bool {
+	if statement.ActionValue&(1<<actionTypeMap[permissiontypes.ACTION_TYPE_ALL]) != 0 {
+		return true
+	}
+	if bit, ok := actionTypeMap[action]; ok {
+		return statement.ActionValue&(1<<bit) != 0
+	}
+	for _, unknown := range statement.UnknownActions {
+		if unknown == int32(action) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesAny tells whether the given resource name matches any of the patterns, the invalid ones matching nothing
+func matchesAny(patterns []string, resource string) bool {
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err == nil && re.MatchString(resource) {
+			return true
+		}
+	}
+	return false
+}
+
+// isExpired tells whether the given expiration time, in seconds, is before the given time, a zero one never
+// expiring
+func isExpired(expirationTime int64, at time.Time) bool {
+	return expirationTime != 0 && expirationTime < at.Unix()
+}
@@ -0,0 +1,102 @@
+package permission
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	sdkmath "cosmossdk.io/math"
+	permissiontypes "github.com/evmos/evmos/v12/x/permission/types"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm/schema"
+
+	"github.com/forbole/juno/v4/common"
+	"github.com/forbole/juno/v4/models"
+)
+
+func TestVerifyPermission(t *testing.T) {
+	ctx := context.Background()
+	m, db := newTestModule(t)
+	require.NoError(t, db.PrepareTables(ctx, []schema.Tabler{&models.Group{}}))
+
+	alice, bob, carol, dave, eve := common.HexToAddress("0x01"), common.HexToAddress("0x02"), common.HexToAddress("0x03"),
+		common.HexToAddress("0x04"), common.HexToAddress("0x05")
+	owner := common.HexToAddress("0x0f")
+	expired, at := time.Unix(1000, 0), time.Unix(2000, 0)
+
+	// bob is a member of both groups, carol was a member of the first one and alice is a member of the second one
+	require.NoError(t, db.CreateGroup(ctx, []*models.Group{
+		{Owner: owner, GroupID: common.BigToHash(sdkmath.NewUint(5).BigInt()), AccountID: bob},
+		{Owner: owner, GroupID: common.BigToHash(sdkmath.NewUint(5).BigInt()), AccountID: carol, ExpirationTime: expired.Unix()},
+		{Owner: owner, GroupID: common.BigToHash(sdkmath.NewUint(6).BigInt()), AccountID: bob},
+		{Owner: owner, GroupID: common.BigToHash(sdkmath.NewUint(6).BigInt()), AccountID: alice},
+		{Owner: dave, GroupID: common.BigToHash(sdkmath.NewUint(5).BigInt()), AccountID: common.Address{}},
+	}))
+
+	putPolicy := func(policyID uint64, principal *permissiontypes.Principal, expiration *time.Time, statements ...*permissiontypes.Statement) {
+		require.NoError(t, m.handlePutPolicy(ctx, newTestBlock(time.Unix(100, 0)), common.Hash{}, &permissiontypes.EventPutPolicy{
+			PolicyId:       sdkmath.NewUint(policyID),
+			Principal:      principal,
+			ResourceType:   1,
+			ResourceId:     sdkmath.NewUint(42),
+			Statements:     statements,
+			ExpirationTime: expiration,
+		}))
+	}
+	account := func(address common.Address) *permissiontypes.Principal {
+		return &permissiontypes.Principal{Type: permissiontypes.PRINCIPAL_TYPE_GNFD_ACCOUNT, Value: address.String()}
+	}
+	group := func(groupID string) *permissiontypes.Principal {
+		return &permissiontypes.Principal{Type: permissiontypes.PRINCIPAL_TYPE_GNFD_GROUP, Value: groupID}
+	}
+	statement := func(effect permissiontypes.Effect, resources []string, expiration *time.Time, actions ...permissiontypes.ActionType) *permissiontypes.Statement {
+		return &permissiontypes.Statement{Effect: effect, Actions: actions, Resources: resources, ExpirationTime: expiration}
+	}
+
+	putPolicy(1, account(alice), nil,
+		statement(permissiontypes.EFFECT_ALLOW, nil, nil, permissiontypes.ACTION_GET_OBJECT, permissiontypes.ACTION_UPDATE_BUCKET_INFO),
+		statement(permissiontypes.EFFECT_DENY, nil, nil, permissiontypes.ACTION_DELETE_OBJECT),
+		statement(permissiontypes.EFFECT_ALLOW, nil, nil, permissiontypes.ACTION_DELETE_OBJECT),
+		statement(permissiontypes.EFFECT_ALLOW, nil, &expired, permissiontypes.ACTION_COPY_OBJECT),
+		statement(permissiontypes.EFFECT_ALLOW, []string{"^grn:o::bucket/public/.*"}, nil, permissiontypes.ACTION_EXECUTE_OBJECT),
+	)
+	putPolicy(2, group("5"), nil, statement(permissiontypes.EFFECT_ALLOW, nil, nil, permissiontypes.ACTION_TYPE_ALL))
+	putPolicy(3, group("6"), nil, statement(permissiontypes.EFFECT_DENY, nil, nil, permissiontypes.ACTION_UPDATE_BUCKET_INFO))
+	putPolicy(4, account(eve), &expired, statement(permissiontypes.EFFECT_ALLOW, nil, nil, permissiontypes.ACTION_GET_OBJECT))
+
+	for _, tc := range []struct {
+		name      string
+		principal common.Address
+		resource  string
+		action    permissiontypes.ActionType
+		effect    permissiontypes.Effect
+	}{
+		{"allowed action", alice, "", permissiontypes.ACTION_GET_OBJECT, permissiontypes.EFFECT_ALLOW},
+		{"deny prevails over allow", alice, "", permissiontypes.ACTION_DELETE_OBJECT, permissiontypes.EFFECT_DENY},
+		{"action not in any statement", alice, "", permissiontypes.ACTION_LIST_OBJECT, permissiontypes.EFFECT_UNSPECIFIED},
+		{"expired statement", alice, "", permissiontypes.ACTION_COPY_OBJECT, permissiontypes.EFFECT_UNSPECIFIED},
+		{"account policy prevails over group ones", alice, "", permissiontypes.ACTION_UPDATE_BUCKET_INFO, permissiontypes.EFFECT_ALLOW},
+		{"statement with resources on the whole resource", alice, "", permissiontypes.ACTION_EXECUTE_OBJECT, permissiontypes.EFFECT_ALLOW},
+		{"matching sub-resource", alice, "grn:o::bucket/public/a", permissiontypes.ACTION_EXECUTE_OBJECT, permissiontypes.EFFECT_ALLOW},
+		{"sub-resource not matching", alice, "grn:o::bucket/private/a", permissiontypes.ACTION_EXECUTE_OBJECT, permissiontypes.EFFECT_UNSPECIFIED},
+		{"statement without resources on a sub-resource", alice, "grn:o::bucket/public/a", permissiontypes.ACTION_GET_OBJECT, permissiontypes.EFFECT_UNSPECIFIED},
+		{"group allowing every action", bob, "", permissiontypes.ACTION_GET_OBJECT, permissiontypes.EFFECT_ALLOW},
+		{"group deny prevails over group allow", bob, "", permissiontypes.ACTION_UPDATE_BUCKET_INFO, permissiontypes.EFFECT_DENY},
+		{"expired membership", carol, "", permissiontypes.ACTION_GET_OBJECT, permissiontypes.EFFECT_UNSPECIFIED},
+		{"group owner not member", dave, "", permissiontypes.ACTION_GET_OBJECT, permissiontypes.EFFECT_UNSPECIFIED},
+		{"expired policy", eve, "", permissiontypes.ACTION_GET_OBJECT, permissiontypes.EFFECT_UNSPECIFIED},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			effect, err := m.VerifyPermissionOnResource(ctx, tc.principal, "RESOURCE_TYPE_BUCKET",
+				common.BigToHash(sdkmath.NewUint(42).BigInt()), tc.resource, tc.action, at)
+			require.NoError(t, err)
+			require.Equal(t, tc.effect, effect)
+		})
+	}
+
+	// No policy is attached to other resources
+	effect, err := m.VerifyPermission(ctx, alice, "RESOURCE_TYPE_OBJECT", common.BigToHash(sdkmath.NewUint(42).BigInt()),
+		permissiontypes.ACTION_GET_OBJECT, at)
+	require.NoError(t, err)
+	require.Equal(t, permissiontypes.EFFECT_UNSPECIFIED, effect)
+}
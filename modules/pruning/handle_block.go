@@ -1,6 +1,7 @@
 package pruning
 
 import (
+	"context"
 	"fmt"
 
 	tmctypes "github.com/cometbft/cometbft/rpc/core/types"
@@ -13,7 +14,7 @@ import (
 
 // HandleBlock implements modules.BlockModule
 func (m *Module) HandleBlock(
-	block *tmctypes.ResultBlock, _ *tmctypes.ResultBlockResults, _ []*types.Tx, _ modules.GetTmcValidators,
+	ctx context.Context, block *tmctypes.ResultBlock, _ *tmctypes.ResultBlockResults, _ []*types.Tx, _ modules.GetTmcValidators,
 ) error {
 	if block.Block.Height%m.cfg.Interval != 0 {
 		// Not an interval height, so just skip
@@ -26,7 +27,7 @@ func (m *Module) HandleBlock(
 	}
 
 	// Get last pruned height
-	var height, err = pruningDb.GetLastPruned()
+	var height, err = pruningDb.GetLastPruned(ctx)
 	if err != nil {
 		return err
 	}
@@ -42,11 +43,11 @@ func (m *Module) HandleBlock(
 
 		// Prune the height
 		log.Debugw("pruning", "module", "pruning", "height", height)
-		err = pruningDb.Prune(height)
+		err = pruningDb.Prune(ctx, height)
 		if err != nil {
 			return fmt.Errorf("error while pruning height %d: %s", height, err.Error())
 		}
 	}
 
-	return pruningDb.StoreLastPruned(height)
+	return pruningDb.StoreLastPruned(ctx, height)
 }
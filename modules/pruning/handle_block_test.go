@@ -0,0 +1,50 @@
+package pruning
+
+import (
+	"context"
+	"testing"
+
+	tmctypes "github.com/cometbft/cometbft/rpc/core/types"
+	tmtypes "github.com/cometbft/cometbft/types"
+	"github.com/stretchr/testify/require"
+
+	"github.com/forbole/juno/v4/database"
+	"github.com/forbole/juno/v4/database/sqlite/sqlitetest"
+	"github.com/forbole/juno/v4/models"
+)
+
+func newTestModule(t *testing.T, cfg *Config) *Module {
+	t.Helper()
+
+	db := sqlitetest.NewDatabase(t, &models.Tx{}, &models.Message{}, &models.CommitSig{}, &models.BlockResult{})
+	return &Module{cfg: cfg, db: db}
+}
+
+func newTestBlock(height int64) *tmctypes.ResultBlock {
+	return &tmctypes.ResultBlock{Block: &tmtypes.Block{Header: tmtypes.Header{Height: height}}}
+}
+
+func TestHandleBlock(t *testing.T) {
+	ctx := context.Background()
+	m := newTestModule(t, NewConfig(5, 5, 10))
+	pruningDb := m.db.(database.PruningDb)
+
+	// The bookkeeping table is created along with the module ones, so a fresh database can be pruned right away
+	require.NoError(t, m.PrepareTables())
+	require.NoError(t, m.HandleBlock(ctx, newTestBlock(10), nil, nil, nil))
+
+	height, err := pruningDb.GetLastPruned(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(5), height)
+
+	// Heights which are not a multiple of the interval are skipped
+	require.NoError(t, m.HandleBlock(ctx, newTestBlock(15), nil, nil, nil))
+	height, err = pruningDb.GetLastPruned(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(5), height)
+
+	require.NoError(t, m.HandleBlock(ctx, newTestBlock(20), nil, nil, nil))
+	height, err = pruningDb.GetLastPruned(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(15), height)
+}
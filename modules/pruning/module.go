@@ -1,7 +1,12 @@
 package pruning
 
 import (
+	"context"
+
+	"gorm.io/gorm/schema"
+
 	"github.com/forbole/juno/v4/database"
+	"github.com/forbole/juno/v4/models"
 	"github.com/forbole/juno/v4/modules"
 	"github.com/forbole/juno/v4/types/config"
 )
@@ -10,6 +15,7 @@ var (
 	_ modules.Module                     = &Module{}
 	_ modules.BlockModule                = &Module{}
 	_ modules.AdditionalOperationsModule = &Module{}
+	_ modules.PrepareTablesModule        = &Module{}
 )
 
 // Module represents the pruning module allowing to clean the database periodically
@@ -45,3 +51,13 @@ func (m *Module) Name() string {
 func (m *Module) RunAdditionalOperations() error {
 	return RunAdditionalOperations(m.cfg)
 }
+
+// PrepareTables implements modules.PrepareTablesModule
+func (m *Module) PrepareTables() error {
+	return m.db.PrepareTables(context.TODO(), []schema.Tabler{&models.Pruning{}})
+}
+
+// AutoMigrate implements modules.PrepareTablesModule
+func (m *Module) AutoMigrate() error {
+	return m.db.AutoMigrate(context.TODO(), []schema.Tabler{&models.Pruning{}})
+}
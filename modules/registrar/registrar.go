@@ -1,6 +1,9 @@
 package registrar
 
 import (
+	"fmt"
+	"strings"
+
 	"cosmossdk.io/simapp/params"
 	sdk "github.com/cosmos/cosmos-sdk/types"
 
@@ -16,6 +19,7 @@ import (
 	"github.com/forbole/juno/v4/modules/payment"
 	"github.com/forbole/juno/v4/modules/permission"
 	"github.com/forbole/juno/v4/modules/pruning"
+	"github.com/forbole/juno/v4/modules/statistics"
 	storageprovider "github.com/forbole/juno/v4/modules/storage_provider"
 	"github.com/forbole/juno/v4/modules/telemetry"
 	"github.com/forbole/juno/v4/modules/validator"
@@ -85,6 +89,11 @@ func NewDefaultRegistrar(parser messages.MessageAddressesParser) *DefaultRegistr
 	}
 }
 
+// AddressesParser returns the parser used to tell the addresses involved in each message
+func (r *DefaultRegistrar) AddressesParser() messages.MessageAddressesParser {
+	return r.parser
+}
+
 // BuildModules implements Registrar
 func (r *DefaultRegistrar) BuildModules(ctx Context) modules.Modules {
 	return modules.Modules{
@@ -95,11 +104,12 @@ func (r *DefaultRegistrar) BuildModules(ctx Context) modules.Modules {
 		pruning.NewModule(ctx.JunoConfig, ctx.Database),
 		telemetry.NewModule(ctx.JunoConfig),
 		epoch.NewModule(ctx.Database),
-		payment.NewModule(ctx.Database),
-		permission.NewModule(ctx.Database),
+		payment.NewModule(ctx.JunoConfig, ctx.Database, ctx.Proxy),
+		permission.NewModule(ctx.JunoConfig, ctx.Database),
 		group.NewModule(ctx.Database),
 		storageprovider.NewModule(ctx.Database),
 		virtualgroup.NewModule(ctx.Database),
+		statistics.NewModule(ctx.JunoConfig, ctx.Database),
 	}
 }
 
@@ -119,3 +129,47 @@ func GetModules(mods modules.Modules, names []string) []modules.Module {
 	}
 	return modulesImpls
 }
+
+// FilterModules returns the modules of mods that are included, all of them when include is empty, and not
+// excluded. An error is returned if a given name is not the one of a module of mods. Each kept module depending
+// on modules not kept is told about them, and a warning log is printed for each of those.
+func FilterModules(mods modules.Modules, include, exclude []string) (modules.Modules, error) {
+	for _, name := range append(append([]string{}, include...), exclude...) {
+		if _, found := mods.FindByName(name); !found {
+			return nil, fmt.Errorf("module %s is filtered but not configured", name)
+		}
+	}
+
+	var filtered modules.Modules
+	for _, module := range mods {
+		if (len(include) == 0 || hasName(include, module)) && !hasName(exclude, module) {
+			filtered = append(filtered, module)
+		}
+	}
+
+	for _, module := range filtered {
+		dependent, ok := module.(modules.DependentModule)
+		if !ok {
+			continue
+		}
+		var missing []string
+		for _, dependency := range dependent.Dependencies() {
+			if _, found := filtered.FindByName(dependency); !found {
+				log.Warnw("Module depends on a module not running", "module", module.Name(), "dependency", dependency)
+				missing = append(missing, dependency)
+			}
+		}
+		dependent.SetMissingDependencies(missing)
+	}
+	return filtered, nil
+}
+
+// hasName tells whether the name of the given module is among names
+func hasName(names []string, module modules.Module) bool {
+	for _, name := range names {
+		if strings.EqualFold(name, module.Name()) {
+			return true
+		}
+	}
+	return false
+}
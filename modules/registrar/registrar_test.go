@@ -0,0 +1,71 @@
+package registrar
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/forbole/juno/v4/modules"
+)
+
+// namedModule is a module relying on the modules named by dependencies
+type namedModule struct {
+	name         string
+	dependencies []string
+	missing      []string
+}
+
+func (m *namedModule) Name() string { return m.name }
+
+func (m *namedModule) Dependencies() []string { return m.dependencies }
+
+func (m *namedModule) SetMissingDependencies(names []string) { m.missing = names }
+
+func names(mods modules.Modules) []string {
+	var names []string
+	for _, module := range mods {
+		names = append(names, module.Name())
+	}
+	return names
+}
+
+func TestFilterModules(t *testing.T) {
+	permission := &namedModule{name: "permission", dependencies: []string{"bucket", "group"}}
+	mods := modules.Modules{
+		&namedModule{name: "bucket"},
+		permission,
+		&namedModule{name: "statistics"},
+	}
+
+	for _, tc := range []struct {
+		name     string
+		include  []string
+		exclude  []string
+		expected []string
+		missing  []string
+		err      string
+	}{
+		{name: "no filter", expected: []string{"bucket", "permission", "statistics"}, missing: []string{"group"}},
+		{name: "include", include: []string{"Permission"}, expected: []string{"permission"}, missing: []string{"bucket", "group"}},
+		{name: "exclude", exclude: []string{"statistics"}, expected: []string{"bucket", "permission"}, missing: []string{"group"}},
+		{
+			name: "include and exclude", include: []string{"bucket", "statistics"}, exclude: []string{"statistics"},
+			expected: []string{"bucket"},
+		},
+		{name: "unknown included module", include: []string{"payment"}, err: "module payment is filtered but not configured"},
+		{name: "unknown excluded module", exclude: []string{"payment"}, err: "module payment is filtered but not configured"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			filtered, err := FilterModules(mods, tc.include, tc.exclude)
+			if tc.err != "" {
+				require.EqualError(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, names(filtered))
+			if _, found := filtered.FindByName(permission.name); found {
+				require.Equal(t, tc.missing, permission.missing)
+			}
+		})
+	}
+}
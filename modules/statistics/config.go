@@ -0,0 +1,40 @@
+package statistics
+
+import (
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultInterval is the number of minutes between two computations of the statistics when no other one is configured
+const DefaultInterval = 10
+
+type Config struct {
+	// Interval is the number of minutes between two computations of the statistics
+	Interval int `yaml:"interval"`
+}
+
+// NewConfig allows to build a new Config instance
+func NewConfig(interval int) *Config {
+	return &Config{
+		Interval: interval,
+	}
+}
+
+// ParseConfig reads the statistics config, returning the default one when not set
+func ParseConfig(bz []byte) (*Config, error) {
+	type T struct {
+		Config *Config `yaml:"statistics"`
+	}
+	var cfg T
+	err := yaml.Unmarshal(bz, &cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Config == nil {
+		cfg.Config = NewConfig(DefaultInterval)
+	}
+	if cfg.Config.Interval <= 0 {
+		cfg.Config.Interval = DefaultInterval
+	}
+	return cfg.Config, nil
+}
@@ -0,0 +1,47 @@
+package statistics
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-co-op/gocron"
+
+	"github.com/forbole/juno/v4/database"
+	"github.com/forbole/juno/v4/log"
+	"github.com/forbole/juno/v4/models"
+)
+
+// RegisterPeriodicOperations implements modules.PeriodicOperationsModule
+func (m *Module) RegisterPeriodicOperations(scheduler *gocron.Scheduler) error {
+	log.Debugw("setting up periodic tasks", "module", ModuleName)
+
+	_, err := scheduler.Every(m.cfg.Interval).Minutes().Do(func() {
+		if err := m.updateStatistics(database.ReadFromPrimary(context.Background())); err != nil {
+			log.Errorw("failed to update the statistics", "module", ModuleName, "err", err)
+		}
+	})
+	return err
+}
+
+// updateStatistics computes the statistics of the objects stored up to the last block and saves them
+func (m *Module) updateStatistics(ctx context.Context) error {
+	// Read the height first, so that the counts include at least every object stored up to it
+	height, err := m.db.GetLastBlockHeight(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get the last block height: %s", err)
+	}
+
+	total, sealed, removed, err := m.db.CountObjects(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to count the objects: %s", err)
+	}
+
+	return m.db.SaveDBStatistics(ctx, &models.DataStat{
+		BlockHeight:      int64(height),
+		ObjectTotalCount: total,
+		ObjectSealCount:  sealed,
+		ObjectDelCount:   removed,
+		UpdateTime:       time.Now().Unix(),
+	})
+}
@@ -0,0 +1,56 @@
+package statistics
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/forbole/juno/v4/common"
+	"github.com/forbole/juno/v4/database/sqlite/sqlitetest"
+	"github.com/forbole/juno/v4/models"
+)
+
+func newTestModule(t *testing.T) *Module {
+	t.Helper()
+
+	m := &Module{cfg: NewConfig(DefaultInterval), db: sqlitetest.NewDatabase(t, &models.Block{}, &models.Object{})}
+	require.NoError(t, m.PrepareTables())
+	return m
+}
+
+func TestUpdateStatistics(t *testing.T) {
+	ctx := context.Background()
+	m := newTestModule(t)
+
+	require.NoError(t, m.db.SaveBlock(ctx, &models.Block{
+		BlockID: models.BlockID{Hash: common.HexToHash("0x01")},
+		Header:  models.Header{Height: 42},
+	}))
+	require.NoError(t, m.db.SaveObject(ctx, &models.Object{ObjectID: common.HexToHash("0x01"), Status: "OBJECT_STATUS_SEALED"}))
+	require.NoError(t, m.db.SaveObject(ctx, &models.Object{ObjectID: common.HexToHash("0x02"), Status: "OBJECT_STATUS_CREATED"}))
+	require.NoError(t, m.db.SaveObject(ctx, &models.Object{ObjectID: common.HexToHash("0x03"), Status: "OBJECT_STATUS_SEALED", Removed: true}))
+
+	require.NoError(t, m.updateStatistics(ctx))
+
+	stat, err := m.db.GetDataStat(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(42), stat.BlockHeight)
+	require.Equal(t, int64(3), stat.ObjectTotalCount)
+	require.Equal(t, int64(1), stat.ObjectSealCount)
+	require.Equal(t, int64(1), stat.ObjectDelCount)
+	require.NotZero(t, stat.UpdateTime)
+}
+
+func TestParseConfig(t *testing.T) {
+	cfg, err := ParseConfig([]byte(`
+statistics:
+  interval: 30
+`))
+	require.NoError(t, err)
+	require.Equal(t, 30, cfg.Interval)
+
+	cfg, err = ParseConfig([]byte(`invalid_field: yes`))
+	require.NoError(t, err)
+	require.Equal(t, DefaultInterval, cfg.Interval)
+}
@@ -0,0 +1,63 @@
+package statistics
+
+import (
+	"context"
+
+	"gorm.io/gorm/schema"
+
+	"github.com/forbole/juno/v4/database"
+	"github.com/forbole/juno/v4/models"
+	"github.com/forbole/juno/v4/modules"
+	"github.com/forbole/juno/v4/types/config"
+)
+
+const (
+	ModuleName = "statistics"
+)
+
+var (
+	_ modules.Module                   = &Module{}
+	_ modules.PrepareTablesModule      = &Module{}
+	_ modules.PeriodicOperationsModule = &Module{}
+)
+
+// Module represents the module computing the statistics of the stored objects on a periodic basis
+type Module struct {
+	cfg *Config
+	db  database.Database
+}
+
+// NewModule builds a new Module instance
+func NewModule(cfg config.Config, db database.Database) *Module {
+	bz, err := cfg.GetBytes()
+	if err != nil {
+		panic(err)
+	}
+
+	statisticsCfg, err := ParseConfig(bz)
+	if err != nil {
+		panic(err)
+	}
+
+	return &Module{
+		cfg: statisticsCfg,
+		db:  db,
+	}
+}
+
+// Name implements modules.Module
+func (m *Module) Name() string {
+	return ModuleName
+}
+
+// PrepareTables implements modules.PrepareTablesModule.
+// The counts used to be stored as strings, the data-stat migration moving the columns of an existing table to their
+// numeric type.
+func (m *Module) PrepareTables() error {
+	return m.db.PrepareTables(context.TODO(), []schema.Tabler{&models.DataStat{}})
+}
+
+// AutoMigrate implements modules.PrepareTablesModule
+func (m *Module) AutoMigrate() error {
+	return m.db.AutoMigrate(context.TODO(), []schema.Tabler{&models.DataStat{}})
+}
@@ -128,7 +128,12 @@ func (m *Module) handleEditStorageProvider(ctx context.Context, block *tmctypes.
 		Removed:      false,
 	}
 
-	return m.db.UpdateStorageProvider(ctx, storageProvider)
+	// The event carries the whole edited storage provider, so fields cleared by the edit are written as well
+	return m.updateStorageProvider(ctx, block, storageProvider,
+		"operator_address", "seal_address", "approval_address", "gc_address", "endpoint",
+		"moniker", "identity", "website", "security_contact", "details", "bls_key",
+		"update_at", "update_tx_hash",
+	)
 }
 
 func (m *Module) handleSpStoragePriceUpdate(ctx context.Context, block *tmctypes.ResultBlock, txHash common.Hash, spStoragePriceUpdate *sptypes.EventSpStoragePriceUpdate) error {
@@ -144,7 +149,8 @@ func (m *Module) handleSpStoragePriceUpdate(ctx context.Context, block *tmctypes
 		Removed:      false,
 	}
 
-	return m.db.UpdateStorageProvider(ctx, storageProvider)
+	return m.updateStorageProvider(ctx, block, storageProvider,
+		"update_time_sec", "read_price", "free_read_quota", "store_price", "update_at", "update_tx_hash")
 }
 
 func (m *Module) handleCompleteStorageProviderExit(ctx context.Context, block *tmctypes.ResultBlock, txHash common.Hash, completeStorageProviderExit *vgtypes.EventCompleteStorageProviderExit) error {
@@ -155,5 +161,17 @@ func (m *Module) handleCompleteStorageProviderExit(ctx context.Context, block *t
 		UpdateTxHash: txHash,
 		Removed:      true,
 	}
-	return m.db.UpdateStorageProvider(ctx, data)
+	return m.updateStorageProvider(ctx, block, data, "removed", "update_at", "update_tx_hash")
+}
+
+// updateStorageProvider writes the given columns of the storage provider, unless a later block has updated it already
+func (m *Module) updateStorageProvider(ctx context.Context, block *tmctypes.ResultBlock, storageProvider *models.StorageProvider, columns ...string) error {
+	updated, err := m.db.UpdateStorageProviderAt(ctx, block.Block.Height, storageProvider, columns...)
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		log.Debugw("skipping stale storage provider update", "sp_id", storageProvider.SpId, "height", block.Block.Height)
+	}
+	return nil
 }
@@ -0,0 +1,75 @@
+package validator
+
+import (
+	"context"
+	"fmt"
+
+	tmctypes "github.com/cometbft/cometbft/rpc/core/types"
+	tmtypes "github.com/cometbft/cometbft/types"
+
+	"github.com/forbole/juno/v4/common"
+	"github.com/forbole/juno/v4/models"
+	"github.com/forbole/juno/v4/modules"
+	"github.com/forbole/juno/v4/types"
+)
+
+// HandleBlock implements modules.BlockModule.
+// The validator set is only fetched when the last commit of the block has been signed by a validator
+// that has not been seen yet, in which case every new validator of that set gets saved.
+func (m *Module) HandleBlock(
+	ctx context.Context, block *tmctypes.ResultBlock, _ *tmctypes.ResultBlockResults, _ []*types.Tx, getTmcValidators modules.GetTmcValidators,
+) error {
+	commit := block.Block.LastCommit
+	if getTmcValidators == nil || commit == nil || !m.hasUnknownSigners(commit.Signatures) {
+		return nil
+	}
+
+	// The last commit is signed by the validator set of the previous height
+	vals, err := getTmcValidators(commit.Height)
+	if err != nil {
+		return fmt.Errorf("failed to get validators at height %d: %s", commit.Height, err)
+	}
+
+	var validators []*models.Validator
+	for _, val := range vals.Validators {
+		address := common.BytesToAddress(val.Address)
+		if !m.isKnown(address) {
+			validators = append(validators, models.NewValidator(
+				address, models.BytesToPubkey(val.PubKey.Bytes()), uint64(commit.Height),
+			))
+		}
+	}
+
+	if err := m.db.SaveValidators(ctx, validators); err != nil {
+		return fmt.Errorf("failed to save validators at height %d: %s", commit.Height, err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, validator := range validators {
+		m.known[validator.ConsensusAddress] = struct{}{}
+	}
+	return nil
+}
+
+// hasUnknownSigners tells whether any of the given signatures comes from a validator that has not been seen yet
+func (m *Module) hasUnknownSigners(signatures []tmtypes.CommitSig) bool {
+	for _, sig := range signatures {
+		if sig.Absent() {
+			continue
+		}
+		if !m.isKnown(common.BytesToAddress(sig.ValidatorAddress)) {
+			return true
+		}
+	}
+	return false
+}
+
+// isKnown tells whether the validator having the given consensus address has already been saved
+func (m *Module) isKnown(address common.Address) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, ok := m.known[address]
+	return ok
+}
@@ -0,0 +1,97 @@
+package validator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cometbft/cometbft/crypto/ed25519"
+	tmctypes "github.com/cometbft/cometbft/rpc/core/types"
+	tmtypes "github.com/cometbft/cometbft/types"
+	"github.com/stretchr/testify/require"
+
+	"github.com/forbole/juno/v4/common"
+	"github.com/forbole/juno/v4/database"
+	"github.com/forbole/juno/v4/database/sqlite/sqlitetest"
+	"github.com/forbole/juno/v4/models"
+)
+
+func newTestDb(t *testing.T) *database.Impl {
+	t.Helper()
+
+	db := sqlitetest.NewDatabase(t, &models.Validator{})
+	return db
+}
+
+// newTestBlock returns the block at the given height, whose last commit has been signed by the given validators
+func newTestBlock(height int64, signers ...*tmtypes.Validator) *tmctypes.ResultBlock {
+	commit := &tmtypes.Commit{Height: height - 1}
+	for _, signer := range signers {
+		commit.Signatures = append(commit.Signatures, tmtypes.CommitSig{
+			BlockIDFlag:      tmtypes.BlockIDFlagCommit,
+			ValidatorAddress: signer.Address,
+			Signature:        []byte{0x01},
+		})
+	}
+	// Validators that didn't sign must not be looked up
+	commit.Signatures = append(commit.Signatures, tmtypes.NewCommitSigAbsent())
+
+	return &tmctypes.ResultBlock{
+		Block: &tmtypes.Block{Header: tmtypes.Header{Height: height}, LastCommit: commit},
+	}
+}
+
+// validatorSets serves the validator set of each height, counting the requests
+type validatorSets struct {
+	sets     map[int64][]*tmtypes.Validator
+	requests int
+}
+
+func (v *validatorSets) get(height int64) (*tmctypes.ResultValidators, error) {
+	v.requests++
+	set := v.sets[height]
+	return &tmctypes.ResultValidators{BlockHeight: height, Validators: set, Count: len(set), Total: len(set)}, nil
+}
+
+func TestHandleBlockSavesNewValidators(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDb(t)
+	m := NewModule(db)
+
+	first := tmtypes.NewValidator(ed25519.GenPrivKey().PubKey(), 10)
+	second := tmtypes.NewValidator(ed25519.GenPrivKey().PubKey(), 10)
+	joining := tmtypes.NewValidator(ed25519.GenPrivKey().PubKey(), 10)
+	sets := &validatorSets{sets: map[int64][]*tmtypes.Validator{
+		9:  {first, second},
+		10: {first, second},
+		11: {first, second, joining},
+	}}
+
+	require.NoError(t, m.HandleBlock(ctx, newTestBlock(10, first, second), nil, nil, sets.get))
+	require.Equal(t, 1, sets.requests)
+
+	// Known signers don't need the validator set
+	require.NoError(t, m.HandleBlock(ctx, newTestBlock(11, first, second), nil, nil, sets.get))
+	require.Equal(t, 1, sets.requests)
+
+	// The validator set update introduces a new validator
+	require.NoError(t, m.HandleBlock(ctx, newTestBlock(12, first, second, joining), nil, nil, sets.get))
+	require.Equal(t, 2, sets.requests)
+
+	var count int64
+	require.NoError(t, db.Db.Table((&models.Validator{}).TableName()).Count(&count).Error)
+	require.Equal(t, int64(3), count)
+
+	stored, err := db.GetValidatorByConsAddr(ctx, common.BytesToAddress(joining.Address).Hex())
+	require.NoError(t, err)
+	require.Equal(t, uint64(11), stored.FirstSeenHeight)
+	require.Equal(t, models.BytesToPubkey(joining.PubKey.Bytes()), stored.ConsensusPubkey)
+
+	// After a restart every validator is seen as new again, without changing the stored rows
+	restarted := NewModule(db)
+	require.NoError(t, restarted.HandleBlock(ctx, newTestBlock(12, first, second, joining), nil, nil, sets.get))
+	require.NoError(t, db.Db.Table((&models.Validator{}).TableName()).Count(&count).Error)
+	require.Equal(t, int64(3), count)
+	stored, err = db.GetValidatorByConsAddr(ctx, common.BytesToAddress(first.Address).Hex())
+	require.NoError(t, err)
+	require.Equal(t, uint64(9), stored.FirstSeenHeight)
+}
@@ -2,9 +2,11 @@ package validator
 
 import (
 	"context"
+	"sync"
 
 	"gorm.io/gorm/schema"
 
+	"github.com/forbole/juno/v4/common"
 	"github.com/forbole/juno/v4/database"
 	"github.com/forbole/juno/v4/models"
 	"github.com/forbole/juno/v4/modules"
@@ -13,17 +15,23 @@ import (
 var (
 	_ modules.Module              = &Module{}
 	_ modules.PrepareTablesModule = &Module{}
+	_ modules.BlockModule         = &Module{}
 )
 
 // Module represents the basic module which is required by both explorer and storage-provider
 type Module struct {
 	db database.Database
+
+	mu sync.Mutex
+	// known contains the consensus addresses of the validators saved since the module was built
+	known map[common.Address]struct{}
 }
 
 // NewModule builds a new Module instance
 func NewModule(db database.Database) *Module {
 	return &Module{
-		db: db,
+		db:    db,
+		known: make(map[common.Address]struct{}),
 	}
 }
 
@@ -8,11 +8,13 @@ import (
 	tmctypes "github.com/cometbft/cometbft/rpc/core/types"
 	sdk "github.com/cosmos/cosmos-sdk/types"
 	"github.com/cosmos/gogoproto/proto"
+	storagetypes "github.com/evmos/evmos/v12/x/storage/types"
 	vgtypes "github.com/evmos/evmos/v12/x/virtualgroup/types"
 
 	"github.com/forbole/juno/v4/common"
 	"github.com/forbole/juno/v4/log"
 	"github.com/forbole/juno/v4/models"
+	"github.com/forbole/juno/v4/modules/bucket"
 )
 
 var (
@@ -37,6 +39,9 @@ var virtualGroupEvents = map[string]bool{
 	EventCreateGlobalVirtualGroupFamily: true,
 	EventDeleteGlobalVirtualGroupFamily: true,
 	EventUpdateGlobalVirtualGroupFamily: true,
+
+	// The local virtual groups of a bucket go along with it
+	bucket.EventDeleteBucket: true,
 }
 
 func (m *Module) ExtractEventStatements(ctx context.Context, block *tmctypes.ResultBlock, txHash common.Hash, event sdk.Event) (map[string][]interface{}, error) {
@@ -118,7 +123,14 @@ func (m *Module) HandleEvent(ctx context.Context, block *tmctypes.ResultBlock, t
 			return errors.New("update vgf event assert error")
 		}
 		data := m.handleUpdateGlobalVirtualGroupFamily(ctx, block, txHash, updateGlobalVirtualGroupFamily)
-		return m.db.UpdateVGF(ctx, data)
+		return m.updateVGF(ctx, block, data, "primary_sp_id", "global_virtual_group_ids", "update_at", "update_tx_hash", "update_time")
+	case bucket.EventDeleteBucket:
+		deleteBucket, ok := typedEvent.(*storagetypes.EventDeleteBucket)
+		if !ok {
+			log.Errorw("type assert error", "type", "EventDeleteBucket", "event", typedEvent)
+			return errors.New("delete bucket event assert error")
+		}
+		return m.handleDeleteBucket(ctx, block, txHash, deleteBucket)
 	}
 
 	return nil
@@ -155,7 +167,7 @@ func (m *Module) handleUpdateLocalVirtualGroup(ctx context.Context, block *tmcty
 		UpdateTime:   block.Block.Time.UTC().Unix(),
 	}
 
-	return m.db.UpdateLVG(ctx, lvgGroup)
+	return m.updateLVG(ctx, block, lvgGroup, "global_virtual_group_id", "stored_size", "update_at", "update_tx_hash", "update_time")
 }
 
 func (m *Module) handleDeleteLocalVirtualGroup(ctx context.Context, block *tmctypes.ResultBlock, txHash common.Hash, deleteLocalVirtualGroup *vgtypes.EventDeleteLocalVirtualGroup) error {
@@ -169,7 +181,13 @@ func (m *Module) handleDeleteLocalVirtualGroup(ctx context.Context, block *tmcty
 		UpdateTime:   block.Block.Time.UTC().Unix(),
 	}
 
-	return m.db.UpdateLVG(ctx, data)
+	return m.updateLVG(ctx, block, data, "removed", "update_at", "update_tx_hash", "update_time")
+}
+
+// handleDeleteBucket removes the local virtual groups of the deleted bucket, the ones updated after the deletion
+// being left untouched
+func (m *Module) handleDeleteBucket(ctx context.Context, block *tmctypes.ResultBlock, txHash common.Hash, deleteBucket *storagetypes.EventDeleteBucket) error {
+	return m.db.RemoveLVGsByBucket(ctx, common.BigToHash(deleteBucket.BucketId.BigInt()), block.Block.Height, txHash, block.Block.Time.UTC().Unix())
 }
 
 func (m *Module) handleCreateGlobalVirtualGroup(ctx context.Context, block *tmctypes.ResultBlock, txHash common.Hash, createGlobalVirtualGroup *vgtypes.EventCreateGlobalVirtualGroup) error {
@@ -204,7 +222,7 @@ func (m *Module) handleDeleteGlobalVirtualGroup(ctx context.Context, block *tmct
 		UpdateTime:   block.Block.Time.UTC().Unix(),
 	}
 
-	return m.db.UpdateGVG(ctx, gvgGroup)
+	return m.updateGVG(ctx, block, gvgGroup, "removed", "update_at", "update_tx_hash", "update_time")
 }
 
 func (m *Module) handleUpdateGlobalVirtualGroup(ctx context.Context, block *tmctypes.ResultBlock, txHash common.Hash, updateGlobalVirtualGroup *vgtypes.EventUpdateGlobalVirtualGroup) error {
@@ -220,7 +238,7 @@ func (m *Module) handleUpdateGlobalVirtualGroup(ctx context.Context, block *tmct
 		UpdateTime:   block.Block.Time.UTC().Unix(),
 	}
 
-	return m.db.UpdateGVG(ctx, gvgGroup)
+	return m.updateGVG(ctx, block, gvgGroup, "stored_size", "total_deposit", "primary_sp_id", "secondary_sp_ids", "update_at", "update_tx_hash", "update_time")
 }
 
 func (m *Module) handleCreateGlobalVirtualGroupFamily(ctx context.Context, block *tmctypes.ResultBlock, txHash common.Hash, createGlobalVirtualGroupFamily *vgtypes.EventCreateGlobalVirtualGroupFamily) error {
@@ -251,7 +269,7 @@ func (m *Module) handleDeleteGlobalVirtualGroupFamily(ctx context.Context, block
 		UpdateTxHash: txHash,
 		UpdateTime:   block.Block.Time.UTC().Unix(),
 	}
-	return m.db.UpdateVGF(ctx, data)
+	return m.updateVGF(ctx, block, data, "removed", "update_at", "update_tx_hash", "update_time")
 }
 
 func (m *Module) handleUpdateGlobalVirtualGroupFamily(ctx context.Context, block *tmctypes.ResultBlock, txHash common.Hash, updateGlobalVirtualGroupFamily *vgtypes.EventUpdateGlobalVirtualGroupFamily) *models.GlobalVirtualGroupFamily {
@@ -265,3 +283,39 @@ func (m *Module) handleUpdateGlobalVirtualGroupFamily(ctx context.Context, block
 		UpdateTime:   block.Block.Time.UTC().Unix(),
 	}
 }
+
+// updateLVG writes the given columns of the local virtual group, unless a later block has updated it already
+func (m *Module) updateLVG(ctx context.Context, block *tmctypes.ResultBlock, lvg *models.LocalVirtualGroup, columns ...string) error {
+	updated, err := m.db.UpdateLVGAt(ctx, block.Block.Height, lvg, columns...)
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		log.Debugw("skipping stale lvg update", "lvg_id", lvg.LocalVirtualGroupId, "bucket_id", lvg.BucketID, "height", block.Block.Height)
+	}
+	return nil
+}
+
+// updateGVG writes the given columns of the global virtual group, unless a later block has updated it already
+func (m *Module) updateGVG(ctx context.Context, block *tmctypes.ResultBlock, gvg *models.GlobalVirtualGroup, columns ...string) error {
+	updated, err := m.db.UpdateGVGAt(ctx, block.Block.Height, gvg, columns...)
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		log.Debugw("skipping stale gvg update", "gvg_id", gvg.GlobalVirtualGroupId, "height", block.Block.Height)
+	}
+	return nil
+}
+
+// updateVGF writes the given columns of the global virtual group family, unless a later block has updated it already
+func (m *Module) updateVGF(ctx context.Context, block *tmctypes.ResultBlock, vgf *models.GlobalVirtualGroupFamily, columns ...string) error {
+	updated, err := m.db.UpdateVGFAt(ctx, block.Block.Height, vgf, columns...)
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		log.Debugw("skipping stale vgf update", "vgf_id", vgf.GlobalVirtualGroupFamilyId, "height", block.Block.Height)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package virtualgroup
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	sdkmath "cosmossdk.io/math"
+	tmctypes "github.com/cometbft/cometbft/rpc/core/types"
+	tmtypes "github.com/cometbft/cometbft/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	storagetypes "github.com/evmos/evmos/v12/x/storage/types"
+	"github.com/stretchr/testify/require"
+
+	"github.com/forbole/juno/v4/common"
+	"github.com/forbole/juno/v4/database"
+	"github.com/forbole/juno/v4/database/sqlite/sqlitetest"
+	"github.com/forbole/juno/v4/models"
+)
+
+func newTestModule(t *testing.T) (*Module, *database.Impl) {
+	t.Helper()
+
+	db := sqlitetest.NewDatabase(t, &models.LocalVirtualGroup{})
+	return NewModule(db), db
+}
+
+func newTestBlock(height int64) *tmctypes.ResultBlock {
+	return &tmctypes.ResultBlock{
+		Block: &tmtypes.Block{Header: tmtypes.Header{Height: height, Time: time.Unix(1700000000+height, 0)}},
+	}
+}
+
+func TestDeleteBucket(t *testing.T) {
+	ctx := context.Background()
+	m, db := newTestModule(t)
+
+	bucketID := func(id uint64) common.Hash { return common.BigToHash(sdkmath.NewUint(id).BigInt()) }
+	deleteBucket := func(height int64, id uint64) {
+		sdkEvent, err := sdk.TypedEventToEvent(&storagetypes.EventDeleteBucket{BucketId: sdkmath.NewUint(id), BucketName: "bucket"})
+		require.NoError(t, err)
+		require.NoError(t, m.HandleEvent(ctx, newTestBlock(height), common.BigToHash(sdkmath.NewInt(height).BigInt()), sdkEvent))
+	}
+
+	for _, id := range []uint64{1, 2} {
+		require.NoError(t, db.SaveLVG(ctx, &models.LocalVirtualGroup{LocalVirtualGroupId: 1, BucketID: bucketID(id), StoredSize: 100, UpdateAt: 10}))
+	}
+	deleteBucket(20, 1)
+	lvg, err := db.GetLVG(ctx, bucketID(1), 1)
+	require.NoError(t, err)
+	require.True(t, lvg.Removed)
+	require.Equal(t, int64(20), lvg.UpdateAt)
+
+	// The groups of the other buckets are left untouched
+	lvg, err = db.GetLVG(ctx, bucketID(2), 1)
+	require.NoError(t, err)
+	require.False(t, lvg.Removed)
+
+	// As are the groups updated after a stale deletion
+	require.NoError(t, db.SaveLVG(ctx, &models.LocalVirtualGroup{LocalVirtualGroupId: 1, BucketID: bucketID(3), UpdateAt: 50}))
+	deleteBucket(40, 3)
+	lvg, err = db.GetLVG(ctx, bucketID(3), 1)
+	require.NoError(t, err)
+	require.False(t, lvg.Removed)
+	require.Equal(t, int64(50), lvg.UpdateAt)
+}
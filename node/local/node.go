@@ -325,6 +325,11 @@ func (cp *Node) LatestHeight() (int64, error) {
 	return cp.blockStore.Height(), nil
 }
 
+// EarliestHeight implements node.Node
+func (cp *Node) EarliestHeight() (int64, error) {
+	return cp.blockStore.Base(), nil
+}
+
 // ChainID implements node.Node
 func (cp *Node) ChainID() (string, error) {
 	return cp.genesisDoc.ChainID, nil
@@ -5,6 +5,7 @@ import (
 
 	constypes "github.com/cometbft/cometbft/consensus/types"
 	tmctypes "github.com/cometbft/cometbft/rpc/core/types"
+	"google.golang.org/grpc"
 
 	"github.com/forbole/juno/v4/types"
 )
@@ -20,6 +21,10 @@ type Node interface {
 	// is returned if the query fails.
 	LatestHeight() (int64, error)
 
+	// EarliestHeight returns the height of the earliest block the node still has, the ones before
+	// having been pruned. An error is returned if the query fails.
+	EarliestHeight() (int64, error)
+
 	// ChainID returns the network ID
 	ChainID() (string, error)
 
@@ -61,3 +66,10 @@ type Node interface {
 	// Stop defers the node stop execution to the client.
 	Stop()
 }
+
+// GrpcNode is implemented by the nodes exposing a gRPC connection, through which the state of the chain modules
+// can be queried
+type GrpcNode interface {
+	// GrpcConnection returns the connection the gRPC query clients of the chain modules are built on
+	GrpcConnection() grpc.ClientConnInterface
+}
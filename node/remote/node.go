@@ -29,7 +29,8 @@ import (
 )
 
 var (
-	_ node.Node = &Node{}
+	_ node.Node     = &Node{}
+	_ node.GrpcNode = &Node{}
 )
 
 // Node implements a wrapper around both a Tendermint RPCConfig client and a
@@ -74,15 +75,33 @@ func NewNode(cfg *Details, codec codec.Codec) (*Node, error) {
 		WithTxConfig(txConfig).
 		WithClient(rpcClient)
 
+	var grpcConnection *grpc.ClientConn
+	if cfg.GRPC != nil {
+		// The connection is established lazily, on the first query
+		grpcConnection, err = CreateGrpcConnection(cfg.GRPC)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return &Node{
 		ctx:   context.Background(),
 		codec: codec,
 
 		client:          rpcClient,
 		txServiceClient: tx.NewServiceClient(clientCtx),
+		grpcConnection:  grpcConnection,
 	}, nil
 }
 
+// GrpcConnection implements node.GrpcNode
+func (cp *Node) GrpcConnection() grpc.ClientConnInterface {
+	if cp.grpcConnection == nil {
+		return nil
+	}
+	return cp.grpcConnection
+}
+
 // Genesis implements node.Node
 func (cp *Node) Genesis() (*tmctypes.ResultGenesis, error) {
 	res, err := cp.client.Genesis(cp.ctx)
@@ -158,6 +177,16 @@ func (cp *Node) LatestHeight() (int64, error) {
 	return height, nil
 }
 
+// EarliestHeight implements node.Node
+func (cp *Node) EarliestHeight() (int64, error) {
+	status, err := cp.client.Status(cp.ctx)
+	if err != nil {
+		return -1, err
+	}
+
+	return status.SyncInfo.EarliestBlockHeight, nil
+}
+
 // ChainID implements node.Node
 func (cp *Node) ChainID() (string, error) {
 	status, err := cp.client.Status(cp.ctx)
@@ -267,6 +296,9 @@ func (cp *Node) Stop() {
 		panic(fmt.Errorf("error while stopping proxy: %s", err))
 	}
 
+	if cp.grpcConnection == nil {
+		return
+	}
 	err = cp.grpcConnection.Close()
 	if err != nil {
 		panic(fmt.Errorf("error while closing gRPC connection: %s", err))
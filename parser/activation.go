@@ -0,0 +1,29 @@
+package parser
+
+import (
+	"strings"
+
+	"github.com/forbole/juno/v4/modules"
+	"github.com/forbole/juno/v4/types/config"
+)
+
+// ActivationHeight returns the height from which the given module gets the chain data, the one configured for it
+// if any, or the one it tells as a modules.ActivationHeightModule. It is zero for the modules always active.
+func ActivationHeight(module modules.Module) uint64 {
+	for name, height := range config.Cfg.Parser.ModuleActivationHeights {
+		if strings.EqualFold(name, module.Name()) {
+			return height
+		}
+	}
+
+	if module, ok := module.(modules.ActivationHeightModule); ok {
+		return module.ActivationHeight()
+	}
+	return 0
+}
+
+// ModuleActive tells whether the given module gets the data of the given height, which it doesn't when below
+// its activation height
+func ModuleActive(module modules.Module, height int64) bool {
+	return height >= 0 && uint64(height) >= ActivationHeight(module)
+}
@@ -0,0 +1,67 @@
+package parser
+
+import (
+	"context"
+	"testing"
+
+	tmctypes "github.com/cometbft/cometbft/rpc/core/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/stretchr/testify/require"
+
+	"github.com/forbole/juno/v4/common"
+	"github.com/forbole/juno/v4/modules"
+	"github.com/forbole/juno/v4/types/config"
+)
+
+// gatedModule is a heightsModule activated at a given height, recording the heights of the events it handles
+type gatedModule struct {
+	heightsModule
+	bucketEventModule
+
+	activation   uint64
+	eventHeights []int64
+}
+
+func (m *gatedModule) Name() string { return m.heightsModule.Name() }
+
+func (m *gatedModule) ActivationHeight() uint64 { return m.activation }
+
+func (m *gatedModule) HandleEvent(_ context.Context, block *tmctypes.ResultBlock, _ common.Hash, _ sdk.Event) error {
+	m.eventHeights = append(m.eventHeights, block.Block.Height)
+	return nil
+}
+
+func TestModuleActivationHeight(t *testing.T) {
+	parserCfg := config.Cfg.Parser
+	t.Cleanup(func() { config.Cfg.Parser = parserCfg })
+
+	for _, tc := range []struct {
+		name        string
+		activations map[string]uint64
+		gated       []int64
+		ungated     []int64
+	}{
+		{name: "own activation height", gated: []int64{3, 4, 5}, ungated: []int64{1, 2, 3, 4, 5}},
+		{
+			name: "configured activation heights", activations: map[string]uint64{"Gated": 0, "ungated": 4},
+			gated: []int64{1, 2, 3, 4, 5}, ungated: []int64{4, 5},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			config.Cfg.Parser.ModuleActivationHeights = tc.activations
+
+			gated := &gatedModule{heightsModule: heightsModule{name: "gated"}, activation: 3}
+			ungated := &heightsModule{name: "ungated"}
+			indexer := newTestIndexer(t)
+			indexer.Node = &mockNode{eventTypes: []string{"save"}}
+			indexer.Modules = append([]modules.Module{gated, ungated}, indexer.Modules...)
+
+			for height := uint64(1); height <= 5; height++ {
+				require.NoError(t, indexer.Process(height))
+			}
+			require.Equal(t, tc.gated, gated.heights)
+			require.Equal(t, tc.gated, gated.eventHeights)
+			require.Equal(t, tc.ungated, ungated.heights)
+		})
+	}
+}
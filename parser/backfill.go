@@ -0,0 +1,138 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/forbole/juno/v4/database"
+	"github.com/forbole/juno/v4/log"
+	"github.com/forbole/juno/v4/types"
+)
+
+// DefaultBackfillMaxGaps is the max number of missing heights backfilled at once when no other limit is configured
+const DefaultBackfillMaxGaps = 100
+
+// InFlightHeights is the set of the heights being processed by the workers, shared with the Backfiller so that it
+// leaves them alone
+type InFlightHeights struct {
+	mu      sync.Mutex
+	heights map[uint64]struct{}
+}
+
+// NewInFlightHeights returns an empty InFlightHeights
+func NewInFlightHeights() *InFlightHeights {
+	return &InFlightHeights{heights: make(map[uint64]struct{})}
+}
+
+// Add records the given height as being processed, returning false if it already was
+func (h *InFlightHeights) Add(height uint64) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.heights[height]; ok {
+		return false
+	}
+	h.heights[height] = struct{}{}
+	return true
+}
+
+// Remove records the given height as no longer being processed
+func (h *InFlightHeights) Remove(height uint64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.heights, height)
+}
+
+// Backfiller looks for the heights missing below the last stored block, left behind by crashes or node failures, and hands
+// them to the workers through its own queue, which they only read while the main one is empty
+type Backfiller struct {
+	db       database.Database
+	queue    types.HeightQueue
+	inFlight *InFlightHeights
+
+	floor   uint64
+	maxGaps int
+}
+
+// NewBackfiller returns a Backfiller filling the heights missing from floor on, at most maxGaps of them at once
+func NewBackfiller(db database.Database, queue types.HeightQueue, inFlight *InFlightHeights, floor uint64, maxGaps int) *Backfiller {
+	if maxGaps <= 0 {
+		maxGaps = DefaultBackfillMaxGaps
+	}
+	// Height 0 stands for the genesis, which is never stored as a block
+	if floor == 0 {
+		floor = 1
+	}
+	return &Backfiller{
+		db:       db,
+		queue:    queue,
+		inFlight: inFlight,
+		floor:    floor,
+		maxGaps:  maxGaps,
+	}
+}
+
+// Start backfills the missing heights every interval, until ctx is done
+func (b *Backfiller) Start(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			count, err := b.Backfill(ctx)
+			if err != nil {
+				log.Errorw("failed to backfill missing heights", "err", err)
+				continue
+			}
+			if count > 0 {
+				log.Infow("backfilling missing heights", "count", count)
+			}
+		case <-ctx.Done():
+			log.Infow("Receive cancel signal, backfiller will stop")
+			return
+		}
+	}
+}
+
+// Backfill enqueues the heights missing between the floor and the last stored block, skipping the ones being processed
+// already, and returns how many got enqueued. It blocks until the workers take them, or ctx is done.
+func (b *Backfiller) Backfill(ctx context.Context) (int, error) {
+	// The replicas may lag behind, making stored heights look missing
+	dbCtx := database.ReadFromPrimary(ctx)
+	// The epoch is only stored with the block transaction or the epoch window, unlike the blocks
+	lastHeight, err := b.db.GetLastBlockHeight(dbCtx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get last block height: %s", err)
+	}
+	if lastHeight < b.floor {
+		return 0, nil
+	}
+
+	missingHeights, err := b.db.GetMissingHeights(dbCtx, b.floor, lastHeight)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get missing heights: %s", err)
+	}
+	if len(missingHeights) > b.maxGaps {
+		missingHeights = missingHeights[:b.maxGaps]
+	}
+
+	var count int
+	for _, height := range missingHeights {
+		if !b.inFlight.Add(height) {
+			continue
+		}
+
+		log.BackfillGapsFound.Inc()
+		select {
+		case b.queue <- height:
+			count++
+		case <-ctx.Done():
+			b.inFlight.Remove(height)
+			return count, ctx.Err()
+		}
+	}
+	return count, nil
+}
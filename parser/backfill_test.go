@@ -0,0 +1,150 @@
+package parser
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cosmossdk.io/simapp/params"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/forbole/juno/v4/log"
+	"github.com/forbole/juno/v4/models"
+	"github.com/forbole/juno/v4/modules"
+	"github.com/forbole/juno/v4/types"
+	"github.com/forbole/juno/v4/types/config"
+)
+
+// newGappedIndexer returns a test indexer having stored the blocks up to the epoch but the missing ones
+func newGappedIndexer(t *testing.T, epoch int64, missing ...int64) *Impl {
+	t.Helper()
+
+	ctx := context.Background()
+	indexer := newTestIndexer(t)
+	for height := int64(1); height <= epoch; height++ {
+		if !containsHeight(missing, height) {
+			require.NoError(t, indexer.DB.SaveBlock(ctx, models.NewBlockFromTmBlock(newHashedTestBlock(height), 0)))
+		}
+	}
+	require.NoError(t, indexer.DB.SaveEpoch(ctx, &models.Epoch{OneRowId: true, BlockHeight: epoch}))
+	return indexer
+}
+
+func containsHeight(heights []int64, height int64) bool {
+	for _, h := range heights {
+		if h == height {
+			return true
+		}
+	}
+	return false
+}
+
+func TestBackfill(t *testing.T) {
+	ctx := context.Background()
+	indexer := newGappedIndexer(t, 10, 4, 7, 8)
+	queue, inFlight := types.NewQueue(10), NewInFlightHeights()
+
+	// The heights being processed are skipped, and at most max gaps of them are looked at
+	inFlight.Add(7)
+	count, err := NewBackfiller(indexer.DB, queue, inFlight, 0, 2).Backfill(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+	require.Equal(t, uint64(4), <-queue)
+
+	// The enqueued ones are not enqueued again
+	count, err = NewBackfiller(indexer.DB, queue, inFlight, 0, 2).Backfill(ctx)
+	require.NoError(t, err)
+	require.Zero(t, count)
+
+	// The heights below the floor are left
+	count, err = NewBackfiller(indexer.DB, queue, NewInFlightHeights(), 5, 0).Backfill(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+	require.Equal(t, []uint64{7, 8}, []uint64{<-queue, <-queue})
+
+	// Nothing is missing past the last stored block, or before any block is stored
+	count, err = NewBackfiller(indexer.DB, queue, NewInFlightHeights(), 11, 0).Backfill(ctx)
+	require.NoError(t, err)
+	require.Zero(t, count)
+	withoutBlocks := newTestIndexer(t)
+	count, err = NewBackfiller(withoutBlocks.DB, queue, NewInFlightHeights(), 0, 0).Backfill(ctx)
+	require.NoError(t, err)
+	require.Zero(t, count)
+
+	// The heights are backfilled even though no epoch is stored
+	for _, height := range []int64{1, 3} {
+		require.NoError(t, withoutBlocks.DB.SaveBlock(ctx, models.NewBlockFromTmBlock(newHashedTestBlock(height), 0)))
+	}
+	count, err = NewBackfiller(withoutBlocks.DB, queue, NewInFlightHeights(), 0, 0).Backfill(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+	require.Equal(t, uint64(2), <-queue)
+}
+
+func TestBackfillWhileFollowingTip(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	const tip = 60
+	missing := []int64{5, 12, 13}
+	indexer := newGappedIndexer(t, 20, missing...)
+	mock := &mockNode{}
+	indexer.Node = mock
+	filled := testutil.ToFloat64(log.BackfillGapsFilled)
+
+	queue, backfillQueue, inFlight := types.NewQueue(25), types.NewQueue(25), NewInFlightHeights()
+	parserCtx := NewContext(&params.EncodingConfig{Codec: indexer.codec}, mock, indexer.DB, nil, indexer)
+	for i := 0; i < 2; i++ {
+		worker := NewWorker(parserCtx, queue, i, false)
+		worker.SetIndexer(indexer)
+		worker.SetBackfill(backfillQueue, inFlight)
+		go worker.Start(ctx)
+	}
+	go NewBackfiller(indexer.DB, backfillQueue, inFlight, 0, 2).Start(ctx, 5*time.Millisecond)
+
+	// New blocks keep flowing while the gaps get filled
+	go func() {
+		for height := uint64(21); height <= tip; height++ {
+			queue <- height
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	for _, height := range append(missing, tip) {
+		require.Eventually(t, func() bool {
+			has, err := indexer.DB.HasBlock(ctx, uint64(height))
+			return err == nil && has
+		}, 10*time.Second, 5*time.Millisecond, "height %d", height)
+	}
+	require.GreaterOrEqual(t, testutil.ToFloat64(log.BackfillGapsFilled)-filled, float64(len(missing)))
+}
+
+func TestFailedBackfilledHeightEnqueuedAgain(t *testing.T) {
+	parserCfg := config.Cfg.Parser
+	t.Cleanup(func() { config.Cfg.Parser = parserCfg })
+	config.Cfg.Parser.BlockTransaction = true
+	config.Cfg.Parser.FailedBlockRetryInterval = 0
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	indexer := newTestIndexer(t)
+	indexer.Node = &mockNode{eventTypes: []string{"save"}}
+	indexer.Modules = []modules.Module{&flakyEventModule{failures: 1}}
+
+	// Without the failed blocks retrier, a height caught up with at start is processed again from the main queue
+	queue, backfillQueue, inFlight := types.NewQueue(25), types.NewQueue(25), NewInFlightHeights()
+	worker := NewWorker(NewContext(&params.EncodingConfig{Codec: indexer.codec}, indexer.Node, indexer.DB, nil, indexer), queue, 0, true)
+	worker.SetIndexer(indexer)
+	worker.SetBackfill(backfillQueue, inFlight)
+	worker.SetFailedBlocks(NewFailedBlocks(indexer.DB))
+	go worker.Start(ctx)
+
+	require.True(t, inFlight.Add(1))
+	backfillQueue <- 1
+	require.Eventually(t, func() bool {
+		has, err := indexer.DB.HasBlock(ctx, 1)
+		require.NoError(t, err)
+		return has
+	}, 5*time.Second, time.Millisecond)
+}
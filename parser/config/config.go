@@ -2,16 +2,114 @@ package config
 
 import "time"
 
+const (
+	// NewBlocksModePoll makes the new heights get followed by polling the node
+	NewBlocksModePoll = "poll"
+
+	// NewBlocksModeSubscribe makes the new heights get followed through a subscription to the new blocks
+	NewBlocksModeSubscribe = "subscribe"
+)
+
 type Config struct {
 	GenesisFilePath string         `yaml:"genesis_file_path,omitempty"`
 	Workers         int64          `yaml:"workers"`
 	StartHeight     uint64         `yaml:"start_height"`
+	EndHeight       uint64         `yaml:"end_height,omitempty"`
 	AvgBlockTime    *time.Duration `yaml:"average_block_time"`
 	ParseNewBlocks  bool           `yaml:"listen_new_blocks"`
 	ParseOldBlocks  bool           `yaml:"parse_old_blocks"`
 	ParseGenesis    bool           `yaml:"parse_genesis"`
 	FastSync        bool           `yaml:"fast_sync,omitempty"`
 	ConcurrentSync  bool           `yaml:"concurrent_sync,omitempty"`
+
+	// StoreBlockResults makes the results of each parsed block get stored inside the database
+	StoreBlockResults bool `yaml:"store_block_results,omitempty"`
+
+	// BlockTransaction makes everything written while processing a block, including the modules writes,
+	// happen inside a single database transaction, committed along with the epoch
+	BlockTransaction bool `yaml:"block_transaction,omitempty"`
+
+	// EpochWindow makes the workers process the heights following the epoch concurrently, at most EpochWindow
+	// of them ahead of it, while the epoch only advances once every height up to it has been processed
+	EpochWindow int `yaml:"epoch_window,omitempty"`
+
+	// BackfillInterval makes the heights missing between BackfillFloor and the last stored block get parsed every
+	// BackfillInterval, at most BackfillMaxGaps of them each time, while no new height is waiting
+	BackfillInterval time.Duration `yaml:"backfill_interval,omitempty"`
+	BackfillFloor    uint64        `yaml:"backfill_floor,omitempty"`
+	BackfillMaxGaps  int           `yaml:"backfill_max_gaps,omitempty"`
+
+	// BackfillWorkers is the number of workers taking the low priority heights, the missing ones, before the new
+	// ones, which the other workers only leave them for while no new height is waiting. The default is used when
+	// zero, and one worker at least is always left to the new heights.
+	BackfillWorkers int `yaml:"backfill_workers,omitempty"`
+
+	// NodeMaxAttempts is the max number of times the node gets queried for the data of a height while it fails
+	// transiently, NodeRetryDelay the wait before the first retry, doubled on every following one up to
+	// NodeRetryMaxDelay, and NodeRetryBudget the max time spent querying a height. The defaults are used when zero.
+	NodeMaxAttempts   int           `yaml:"node_max_attempts,omitempty"`
+	NodeRetryDelay    time.Duration `yaml:"node_retry_delay,omitempty"`
+	NodeRetryMaxDelay time.Duration `yaml:"node_retry_max_delay,omitempty"`
+	NodeRetryBudget   time.Duration `yaml:"node_retry_budget,omitempty"`
+
+	// ReorgMaxDepth makes the parent hash of every parsed block get checked against the stored block before it,
+	// rolling the heights after the fork point back on mismatch as long as it is at most ReorgMaxDepth heights
+	// below. The check is disabled when zero.
+	ReorgMaxDepth uint64 `yaml:"reorg_max_depth,omitempty"`
+
+	// ModuleErrorThreshold is the number of errors, or panics, of a single module handler after which the parser
+	// halts. The parser never halts because of the modules when zero.
+	ModuleErrorThreshold int `yaml:"module_error_threshold,omitempty"`
+
+	// FailedBlockRetryInterval makes the heights recorded as failed get enqueued again every
+	// FailedBlockRetryInterval, until they have been retried FailedBlockMaxRetries times, the default being used
+	// when zero. Without an interval the failed heights are only recorded.
+	FailedBlockRetryInterval time.Duration `yaml:"failed_block_retry_interval,omitempty"`
+	FailedBlockMaxRetries    int           `yaml:"failed_block_max_retries,omitempty"`
+
+	// NewBlocksMode tells how the new heights are followed: NewBlocksModePoll querying the latest height of the
+	// node every average block time, or NewBlocksModeSubscribe receiving the new blocks through the node
+	// websocket, polling only while it is down. The default is NewBlocksModePoll.
+	NewBlocksMode string `yaml:"new_blocks_mode,omitempty"`
+
+	// AdaptivePollInterval makes the node get polled for the new heights around the time the next block is
+	// expected, according to the average time between the parsed blocks, waiting at least PollIntervalFloor and
+	// at most PollIntervalCeiling. The defaults are used when zero.
+	AdaptivePollInterval bool          `yaml:"adaptive_poll_interval,omitempty"`
+	PollIntervalFloor    time.Duration `yaml:"poll_interval_floor,omitempty"`
+	PollIntervalCeiling  time.Duration `yaml:"poll_interval_ceiling,omitempty"`
+
+	// ModuleActivationHeights overrides the activation height of the modules by name, the heights below it being
+	// skipped for the module, whether or not it has one itself
+	ModuleActivationHeights map[string]uint64 `yaml:"module_activation_heights,omitempty"`
+
+	// Modules filters the modules run by the parser out of the configured ones
+	Modules ModulesFilter `yaml:"modules,omitempty"`
+
+	// DrainTimeout is how long the heights being processed are waited for on shutdown, the default being
+	// used when zero
+	DrainTimeout time.Duration `yaml:"drain_timeout,omitempty"`
+
+	// ParallelTxs makes the txs of a block get handled across ParallelTxWorkers goroutines, the default being used
+	// when zero. The txs touching the same resources are still handled one after the other, in their order, and
+	// every tx is handled serially while a module is order sensitive. It doesn't apply with BlockTransaction,
+	// whose single database transaction can't be shared by the goroutines.
+	ParallelTxs       bool `yaml:"parallel_txs,omitempty"`
+	ParallelTxWorkers int  `yaml:"parallel_tx_workers,omitempty"`
+
+	// BlockProgress makes the txs and events of a block handled so far get recorded as they get handled, so that
+	// processing the block again after a crash resumes after them. It doesn't apply with BlockTransaction, and
+	// the txs get handled serially with it.
+	BlockProgress bool `yaml:"block_progress,omitempty"`
+}
+
+// ModulesFilter filters the modules run by the parser by name
+type ModulesFilter struct {
+	// Include are the names of the only modules to run, all of them being run when empty
+	Include []string `yaml:"include,omitempty"`
+
+	// Exclude are the names of the modules never to run
+	Exclude []string `yaml:"exclude,omitempty"`
 }
 
 // NewParsingConfig allows to build a new Config instance
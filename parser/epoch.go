@@ -0,0 +1,163 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/forbole/juno/v4/database"
+	"github.com/forbole/juno/v4/log"
+	"github.com/forbole/juno/v4/models"
+)
+
+// EpochCommitter advances the epoch while the heights following it get processed concurrently, in any order.
+// The epoch only moves to a height once every height up to it has been processed, so that everything at or
+// below the epoch is known to be stored: heights completed past one still being processed, or failing, are
+// held until it completes. At most window heights are processed ahead of the epoch, which bounds the held ones.
+type EpochCommitter struct {
+	db     database.Database
+	window uint64
+
+	// completed receives the heights processed by the workers, consumed by Run
+	completed chan uint64
+	// held are the heights completed past next, owned by Run
+	held map[uint64]struct{}
+
+	mu sync.Mutex
+	// next is the first height not processed yet, the epoch being the one before it
+	next uint64
+	// advanced is closed, and replaced, every time next moves forward
+	advanced chan struct{}
+}
+
+// NewEpochCommitter returns an EpochCommitter whose epoch advances from the height before next,
+// holding at most window heights ahead of it
+func NewEpochCommitter(db database.Database, next uint64, window int) *EpochCommitter {
+	if window <= 0 {
+		window = 1
+	}
+	return &EpochCommitter{
+		db:        db,
+		window:    uint64(window),
+		completed: make(chan uint64, window),
+		held:      make(map[uint64]struct{}, window),
+		next:      next,
+		advanced:  make(chan struct{}),
+	}
+}
+
+// Next returns the first height that has not been processed yet
+func (c *EpochCommitter) Next() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.next
+}
+
+// Wait blocks until the given height is inside the window following the epoch, so that it can be processed.
+// An error is returned if ctx is done first.
+func (c *EpochCommitter) Wait(ctx context.Context, height uint64) error {
+	for {
+		c.mu.Lock()
+		next, advanced := c.next, c.advanced
+		c.mu.Unlock()
+
+		if height < next+c.window {
+			return nil
+		}
+
+		select {
+		case <-advanced:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Done records the given height as processed
+func (c *EpochCommitter) Done(height uint64) {
+	c.completed <- height
+}
+
+// Run advances the epoch as the processed heights get recorded, until ctx is done.
+// The heights recorded by then still move the epoch before Run returns, so that it is up to date on shutdown.
+func (c *EpochCommitter) Run(ctx context.Context) {
+	// Stopping must not abort an epoch being saved, whose block is read from the primary as the replicas may
+	// not have it yet
+	dbCtx := database.ReadFromPrimary(context.WithoutCancel(ctx))
+	for {
+		select {
+		case height := <-c.completed:
+			c.complete(dbCtx, height)
+		case <-ctx.Done():
+			log.Infow("Receive cancel signal, epoch committer will stop")
+			c.flush(dbCtx)
+			return
+		}
+	}
+}
+
+// flush completes the heights recorded but not consumed yet
+func (c *EpochCommitter) flush(ctx context.Context) {
+	for {
+		select {
+		case height := <-c.completed:
+			c.complete(ctx, height)
+		default:
+			return
+		}
+	}
+}
+
+// complete holds the given height, moving the epoch forward if it was the first one not processed yet
+func (c *EpochCommitter) complete(ctx context.Context, height uint64) {
+	c.mu.Lock()
+	next := c.next
+	c.mu.Unlock()
+
+	// A height processed twice, like a failed one enqueued again, may complete after the epoch passed it
+	if height < next {
+		return
+	}
+
+	c.held[height] = struct{}{}
+	for {
+		if _, ok := c.held[next]; !ok {
+			break
+		}
+		delete(c.held, next)
+		next++
+	}
+	if next == c.next {
+		return
+	}
+
+	// The epoch stays behind if it fails to be saved, which is safe, and catches up on the next advance
+	if err := c.saveEpoch(ctx, next-1); err != nil {
+		log.Errorw("failed to advance epoch", "height", next-1, "err", err)
+	}
+
+	c.mu.Lock()
+	c.next = next
+	close(c.advanced)
+	c.advanced = make(chan struct{})
+	c.mu.Unlock()
+}
+
+// saveEpoch stores the block at the given height as the epoch
+func (c *EpochCommitter) saveEpoch(ctx context.Context, height uint64) error {
+	block, err := c.db.GetBlockByHeight(ctx, height)
+	if err != nil {
+		return fmt.Errorf("failed to get block: %s", err)
+	}
+
+	err = c.db.SaveEpoch(ctx, &models.Epoch{
+		OneRowId:    true,
+		BlockHeight: int64(block.Height),
+		BlockHash:   block.Hash,
+		UpdateTime:  int64(block.Timestamp),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save epoch: %s", err)
+	}
+	return nil
+}
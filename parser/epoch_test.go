@@ -0,0 +1,230 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"cosmossdk.io/simapp/params"
+	tmctypes "github.com/cometbft/cometbft/rpc/core/types"
+	tmtypes "github.com/cometbft/cometbft/types"
+	"github.com/stretchr/testify/require"
+
+	"github.com/forbole/juno/v4/common"
+	"github.com/forbole/juno/v4/database"
+	"github.com/forbole/juno/v4/models"
+	"github.com/forbole/juno/v4/node"
+	"github.com/forbole/juno/v4/types"
+	"github.com/forbole/juno/v4/types/config"
+)
+
+var errNodeUnavailable = errors.New("node unavailable")
+
+// newHashedTestBlock returns a test block whose hash, unlike the one of newTestBlock, depends on its height
+func newHashedTestBlock(height int64) *tmctypes.ResultBlock {
+	block := newTestBlock(height)
+	block.Block.ValidatorsHash = common.HexToHash("0x01").Bytes()
+	return block
+}
+
+// mockNode serves empty blocks after a random latency, failing on the failing height until it gets released
+type mockNode struct {
+	node.Node
+
+	// earliest and latest are the heights of the first and last blocks the node has
+	earliest, latest int64
+	// eventTypes are the types of the events emitted by the single tx of each block, which has none when empty
+	eventTypes []string
+	// txHeights are the heights of the served txs, by hash
+	txHeights sync.Map
+
+	failing  uint64
+	released atomic.Bool
+	// highest is the highest height requested so far
+	highest atomic.Uint64
+}
+
+func (n *mockNode) ChainID() (string, error) { return "test", nil }
+
+func (n *mockNode) EarliestHeight() (int64, error) { return n.earliest, nil }
+
+func (n *mockNode) LatestHeight() (int64, error) { return n.latest, nil }
+
+func (n *mockNode) Block(height int64) (*tmctypes.ResultBlock, error) {
+	time.Sleep(time.Duration(rand.Intn(3000)) * time.Microsecond)
+	for {
+		highest := n.highest.Load()
+		if uint64(height) <= highest || n.highest.CompareAndSwap(highest, uint64(height)) {
+			break
+		}
+	}
+
+	if uint64(height) == n.failing && !n.released.Load() {
+		return nil, errNodeUnavailable
+	}
+
+	block := newHashedTestBlock(height)
+	if len(n.eventTypes) > 0 {
+		rawTx := tmtypes.Tx(fmt.Sprintf("tx-%d", height))
+		n.txHeights.Store(fmt.Sprintf("%X", rawTx.Hash()), height)
+		block.Block.Txs = tmtypes.Txs{rawTx}
+	}
+	return block, nil
+}
+
+func (n *mockNode) BlockResults(height int64) (*tmctypes.ResultBlockResults, error) {
+	time.Sleep(time.Duration(rand.Intn(3000)) * time.Microsecond)
+	return &tmctypes.ResultBlockResults{Height: height}, nil
+}
+
+func (n *mockNode) Tx(hash string) (*types.Tx, error) {
+	height, ok := n.txHeights.Load(hash)
+	if !ok {
+		return nil, fmt.Errorf("tx %s not found", hash)
+	}
+	return newTestTx(height.(int64), n.eventTypes...), nil
+}
+
+// epochChecker records every saved epoch, checking that all the heights up to it have been stored
+type epochChecker struct {
+	database.Database
+
+	mu         sync.Mutex
+	saved      []int64
+	violations []string
+}
+
+func (c *epochChecker) SaveEpoch(ctx context.Context, epoch *models.Epoch) error {
+	for height := uint64(1); height <= uint64(epoch.BlockHeight); height++ {
+		has, err := c.Database.HasBlock(ctx, height)
+		if err != nil {
+			return err
+		}
+		if !has {
+			c.mu.Lock()
+			c.violations = append(c.violations, fmt.Sprintf("epoch %d saved before height %d", epoch.BlockHeight, height))
+			c.mu.Unlock()
+		}
+	}
+
+	c.mu.Lock()
+	c.saved = append(c.saved, epoch.BlockHeight)
+	c.mu.Unlock()
+	return c.Database.SaveEpoch(ctx, epoch)
+}
+
+func TestEpochCommitter(t *testing.T) {
+	ctx := context.Background()
+	indexer := newTestIndexer(t)
+	for height := int64(1); height <= 5; height++ {
+		require.NoError(t, indexer.DB.SaveBlock(ctx, models.NewBlockFromTmBlock(newHashedTestBlock(height), 0)))
+	}
+	epochs := NewEpochCommitter(indexer.DB, 1, 3)
+
+	require.NoError(t, epochs.Wait(ctx, 3))
+	waitCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, epochs.Wait(waitCtx, 4), context.DeadlineExceeded)
+
+	// The heights completed past the first one not processed yet are held
+	epochs.complete(ctx, 3)
+	epochs.complete(ctx, 2)
+	require.Equal(t, uint64(1), epochs.Next())
+	epoch, err := indexer.DB.GetEpoch(ctx)
+	require.NoError(t, err)
+	require.Zero(t, epoch.BlockHeight)
+
+	epochs.complete(ctx, 1)
+	require.Equal(t, uint64(4), epochs.Next())
+	require.NoError(t, epochs.Wait(ctx, 6))
+	epoch, err = indexer.DB.GetEpoch(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(3), epoch.BlockHeight)
+	block := models.NewBlockFromTmBlock(newHashedTestBlock(3), 0)
+	require.Equal(t, block.Hash, epoch.BlockHash)
+	require.Equal(t, int64(block.Timestamp), epoch.UpdateTime)
+
+	// A height processed again after the epoch passed it is ignored
+	epochs.complete(ctx, 2)
+	require.Equal(t, uint64(4), epochs.Next())
+	require.Empty(t, epochs.held)
+}
+
+func TestConcurrentWorkersAdvanceEpochInOrder(t *testing.T) {
+	avgBlockTime := 5 * time.Millisecond
+	config.Cfg.Parser.AvgBlockTime = &avgBlockTime
+	t.Cleanup(func() { config.Cfg.Parser.AvgBlockTime = nil })
+
+	const (
+		failing = 7
+		window  = 10
+		last    = 60
+		workers = 4
+	)
+
+	for _, concurrentSync := range []bool{false, true} {
+		t.Run(fmt.Sprintf("concurrent sync %t", concurrentSync), func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			indexer := newTestIndexer(t)
+			mock := &mockNode{failing: failing}
+			indexer.Node = mock
+			checker := &epochChecker{Database: indexer.DB}
+			epochs := NewEpochCommitter(checker, 1, window)
+			go epochs.Run(ctx)
+
+			queue := types.NewQueue(25)
+			parserCtx := NewContext(&params.EncodingConfig{Codec: indexer.codec}, mock, indexer.DB, nil, indexer)
+			for i := 0; i < workers; i++ {
+				worker := NewWorker(parserCtx, queue, i, concurrentSync)
+				worker.SetIndexer(indexer)
+				worker.SetEpochCommitter(epochs)
+				go worker.Start(ctx)
+			}
+			go func() {
+				for height := uint64(1); height <= last; height++ {
+					if epochs.Wait(ctx, height) != nil {
+						return
+					}
+					queue <- height
+				}
+			}()
+
+			// The heights of the window following the failing one get processed and held
+			require.Eventually(t, func() bool {
+				has, err := indexer.DB.HasBlock(ctx, failing+window-1)
+				return err == nil && has
+			}, 10*time.Second, 5*time.Millisecond)
+			require.Eventually(t, func() bool {
+				epoch, err := indexer.DB.GetEpoch(ctx)
+				return err == nil && epoch.BlockHeight == failing-1
+			}, 10*time.Second, 5*time.Millisecond)
+
+			// while nothing past the window gets fetched, and the epoch stays behind the failing height
+			time.Sleep(50 * time.Millisecond)
+			require.Less(t, mock.highest.Load(), uint64(failing+window))
+			require.Equal(t, uint64(failing), epochs.Next())
+			epoch, err := indexer.DB.GetEpoch(ctx)
+			require.NoError(t, err)
+			require.Equal(t, int64(failing-1), epoch.BlockHeight)
+
+			// Once the failing height succeeds the epoch catches up
+			mock.released.Store(true)
+			require.Eventually(t, func() bool {
+				epoch, err := indexer.DB.GetEpoch(ctx)
+				return err == nil && epoch.BlockHeight == last
+			}, 10*time.Second, 5*time.Millisecond)
+
+			checker.mu.Lock()
+			defer checker.mu.Unlock()
+			require.Empty(t, checker.violations)
+			require.IsIncreasing(t, checker.saved)
+		})
+	}
+}
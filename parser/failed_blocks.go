@@ -0,0 +1,206 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-co-op/gocron"
+
+	"github.com/forbole/juno/v4/database"
+	"github.com/forbole/juno/v4/log"
+	"github.com/forbole/juno/v4/models"
+	"github.com/forbole/juno/v4/modules"
+	"github.com/forbole/juno/v4/types"
+)
+
+const (
+	// DefaultFailedBlockMaxRetries is the max number of times a failed height is retried when no other limit
+	// is configured
+	DefaultFailedBlockMaxRetries = 5
+
+	// failedBlocksPageSize is the number of failed blocks read at once while retrying them
+	failedBlocksPageSize = 100
+)
+
+// FailedBlocks records into the dead-letter table of the database the heights the workers fail to process,
+// deleting their rows once they succeed
+type FailedBlocks struct {
+	db database.Database
+
+	mu sync.Mutex
+	// recorded are the heights whose failure got recorded by this process
+	recorded map[uint64]struct{}
+	// retrying are the heights re-enqueued by a FailedBlockRetrier, not processed again yet
+	retrying map[uint64]struct{}
+}
+
+// NewFailedBlocks returns a FailedBlocks recording the failures into db
+func NewFailedBlocks(db database.Database) *FailedBlocks {
+	return &FailedBlocks{
+		db:       db,
+		recorded: make(map[uint64]struct{}),
+		retrying: make(map[uint64]struct{}),
+	}
+}
+
+// Fail records that the given height failed to be processed with err, counting a retry if it had failed already
+func (f *FailedBlocks) Fail(ctx context.Context, height uint64, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.retrying, height)
+
+	failed := &models.FailedBlock{
+		Height:        height,
+		Module:        failedModule(err),
+		Error:         err.Error(),
+		FirstFailedAt: time.Now().Unix(),
+	}
+	if err := f.db.SaveFailedBlock(ctx, failed); err != nil {
+		log.Errorw("failed to record failed block", "height", height, "err", err)
+		return
+	}
+	f.recorded[height] = struct{}{}
+}
+
+// Succeed deletes the failure recorded for the given height, if any
+func (f *FailedBlocks) Succeed(ctx context.Context, height uint64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.retrying, height)
+
+	if _, ok := f.recorded[height]; !ok {
+		return
+	}
+	if err := f.db.DeleteFailedBlock(ctx, height); err != nil {
+		log.Errorw("failed to delete failed block", "height", height, "err", err)
+		return
+	}
+	delete(f.recorded, height)
+}
+
+// retry records the given height as re-enqueued, returning false if it already was and is not processed yet
+func (f *FailedBlocks) retry(height uint64) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.retrying[height]; ok {
+		return false
+	}
+	f.retrying[height] = struct{}{}
+	// The failure may have been recorded by a previous run, and has to be deleted once the height succeeds
+	f.recorded[height] = struct{}{}
+	return true
+}
+
+var (
+	_ modules.Module                   = &FailedBlockRetrier{}
+	_ modules.PeriodicOperationsModule = &FailedBlockRetrier{}
+)
+
+// FailedBlockRetrier re-enqueues on a periodic basis the heights recorded into the dead-letter table, until
+// they have been retried maxRetries times, after which they are left there to be looked at.
+// With an epoch committer, a height given up on past the epoch is never skipped: the epoch, and so the
+// parsing, stays halted at it until it gets processed, which is logged as an error on every retry.
+type FailedBlockRetrier struct {
+	db     database.Database
+	queue  types.HeightQueue
+	failed *FailedBlocks
+	epochs *EpochCommitter
+
+	interval   time.Duration
+	maxRetries int
+}
+
+// NewFailedBlockRetrier returns a FailedBlockRetrier enqueuing the heights failed into queue every interval
+func NewFailedBlockRetrier(
+	db database.Database, queue types.HeightQueue, failed *FailedBlocks, interval time.Duration, maxRetries int,
+) *FailedBlockRetrier {
+	if maxRetries <= 0 {
+		maxRetries = DefaultFailedBlockMaxRetries
+	}
+	return &FailedBlockRetrier{
+		db:         db,
+		queue:      queue,
+		failed:     failed,
+		interval:   interval,
+		maxRetries: maxRetries,
+	}
+}
+
+// SetEpochCommitter sets the committer advancing the epoch, which the heights given up on may hold back
+func (r *FailedBlockRetrier) SetEpochCommitter(epochs *EpochCommitter) {
+	r.epochs = epochs
+}
+
+// Name implements modules.Module
+func (r *FailedBlockRetrier) Name() string {
+	return "failed_block_retrier"
+}
+
+// RegisterPeriodicOperations implements modules.PeriodicOperationsModule
+func (r *FailedBlockRetrier) RegisterPeriodicOperations(scheduler *gocron.Scheduler) error {
+	log.Debugw("setting up periodic tasks", "module", r.Name())
+
+	_, err := scheduler.Every(r.interval).Do(func() {
+		count, err := r.Retry(database.ReadFromPrimary(context.Background()))
+		if err != nil {
+			log.Errorw("failed to retry failed blocks", "err", err)
+			return
+		}
+		if count > 0 {
+			log.Infow("retrying failed blocks", "count", count)
+		}
+	})
+	return err
+}
+
+// Retry enqueues the failed heights retried less than the max number of times, skipping the ones enqueued
+// already, and returns how many got enqueued. The failures of the heights stored since are deleted.
+// It blocks until the workers take the heights, or ctx is done.
+func (r *FailedBlockRetrier) Retry(ctx context.Context) (int, error) {
+	count := 0
+	for offset := 0; ; {
+		// The page may be smaller than asked for when the database has a lower max page size
+		failedBlocks, err := r.db.ListFailedBlocks(ctx, failedBlocksPageSize, offset)
+		if err != nil {
+			return count, fmt.Errorf("failed to list failed blocks: %s", err)
+		}
+		if len(failedBlocks) == 0 {
+			return count, nil
+		}
+
+		for _, failed := range failedBlocks {
+			// The height may have been processed by another way, like a backfill, or a restart
+			stored, err := blockStored(ctx, r.db, failed.Height)
+			if err != nil {
+				return count, fmt.Errorf("failed to check block: %s", err)
+			}
+			if stored {
+				if err = r.db.DeleteFailedBlock(ctx, failed.Height); err != nil {
+					return count, fmt.Errorf("failed to delete failed block: %s", err)
+				}
+				continue
+			}
+			offset++
+
+			if failed.RetryCount >= r.maxRetries {
+				if r.epochs != nil && failed.Height >= r.epochs.Next() {
+					log.Errorw("failed block retried the max number of times holds the epoch back, parsing is halted until it gets processed",
+						"height", failed.Height, "retries", failed.RetryCount, "err", failed.Error)
+				}
+				continue
+			}
+			if !r.failed.retry(failed.Height) {
+				continue
+			}
+			select {
+			case r.queue <- failed.Height:
+				count++
+			case <-ctx.Done():
+				return count, ctx.Err()
+			}
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package parser
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"cosmossdk.io/simapp/params"
+	tmctypes "github.com/cometbft/cometbft/rpc/core/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/stretchr/testify/require"
+
+	"github.com/forbole/juno/v4/common"
+	"github.com/forbole/juno/v4/database"
+	"github.com/forbole/juno/v4/models"
+	"github.com/forbole/juno/v4/modules"
+	"github.com/forbole/juno/v4/types"
+	"github.com/forbole/juno/v4/types/config"
+)
+
+// flakyEventModule fails the first failures "save" events it handles
+type flakyEventModule struct {
+	bucketEventModule
+
+	failures int32
+	handled  atomic.Int32
+}
+
+func (m *flakyEventModule) Name() string { return "flaky" }
+
+func (m *flakyEventModule) HandleEvent(_ context.Context, _ *tmctypes.ResultBlock, _ common.Hash, event sdk.Event) error {
+	if event.Type == "save" && m.handled.Add(1) <= m.failures {
+		return errEventFailed
+	}
+	return nil
+}
+
+func TestFailedBlockRetried(t *testing.T) {
+	parserCfg := config.Cfg.Parser
+	t.Cleanup(func() { config.Cfg.Parser = parserCfg })
+	// The failing height has to leave nothing behind, or it would be skipped as processed already
+	config.Cfg.Parser.BlockTransaction = true
+	config.Cfg.Parser.FailedBlockRetryInterval = time.Minute
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	indexer := newTestIndexer(t)
+	indexer.Node = &mockNode{eventTypes: []string{"save"}}
+	indexer.Modules = []modules.Module{&flakyEventModule{failures: 2}}
+
+	queue := types.NewQueue(25)
+	failed := NewFailedBlocks(indexer.DB)
+	retrier := NewFailedBlockRetrier(indexer.DB, queue, failed, time.Minute, 0)
+	worker := NewWorker(NewContext(&params.EncodingConfig{Codec: indexer.codec}, indexer.Node, indexer.DB, nil, indexer), queue, 0, true)
+	worker.SetIndexer(indexer)
+	worker.SetFailedBlocks(failed)
+	go worker.Start(ctx)
+
+	// waitFailedBlocks waits for the failed blocks to match the given retry counts, keyed by height
+	waitFailedBlocks := func(retryCounts map[uint64]int) []*models.FailedBlock {
+		var failedBlocks []*models.FailedBlock
+		require.Eventually(t, func() bool {
+			var err error
+			failedBlocks, err = indexer.DB.ListFailedBlocks(ctx, 10, 0)
+			require.NoError(t, err)
+			if len(failedBlocks) != len(retryCounts) {
+				return false
+			}
+			for _, failedBlock := range failedBlocks {
+				if retryCount, ok := retryCounts[failedBlock.Height]; !ok || retryCount != failedBlock.RetryCount {
+					return false
+				}
+			}
+			return true
+		}, 5*time.Second, time.Millisecond)
+		return failedBlocks
+	}
+
+	// The height failing is recorded, rather than re-enqueued by the worker
+	queue <- 1
+	failedBlocks := waitFailedBlocks(map[uint64]int{1: 0})
+	require.Equal(t, "flaky", failedBlocks[0].Module)
+	require.Equal(t, errEventFailed.Error(), failedBlocks[0].Error)
+	firstFailedAt := failedBlocks[0].FirstFailedAt
+
+	// Failing again counts a retry
+	count, err := retrier.Retry(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+	failedBlocks = waitFailedBlocks(map[uint64]int{1: 1})
+	require.Equal(t, firstFailedAt, failedBlocks[0].FirstFailedAt)
+
+	// Succeeding deletes the failure
+	count, err = retrier.Retry(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+	waitFailedBlocks(nil)
+	has, err := indexer.DB.HasBlock(ctx, 1)
+	require.NoError(t, err)
+	require.True(t, has)
+}
+
+func TestFailedBlockRetrierSkips(t *testing.T) {
+	ctx := context.Background()
+	indexer := newTestIndexer(t)
+	queue := types.NewQueue(25)
+	retrier := NewFailedBlockRetrier(indexer.DB, queue, NewFailedBlocks(indexer.DB), time.Minute, 2)
+
+	require.NoError(t, indexer.DB.SaveBlock(ctx, models.NewBlockFromTmBlock(newHashedTestBlock(1), 0)))
+	for _, failed := range []*models.FailedBlock{
+		{Height: 1, Error: "stored since"},
+		{Height: 2, Error: "retried enough", RetryCount: 2},
+		{Height: 3, Error: "to retry", RetryCount: 1},
+	} {
+		require.NoError(t, indexer.DB.SaveFailedBlock(ctx, failed))
+	}
+
+	// Only the height neither stored nor retried enough gets enqueued, a single time until processed again
+	for _, expected := range []int{1, 0} {
+		count, err := retrier.Retry(ctx)
+		require.NoError(t, err)
+		require.Equal(t, expected, count)
+	}
+	require.Equal(t, uint64(3), <-queue)
+
+	failedBlocks, err := indexer.DB.ListFailedBlocks(ctx, 10, 0)
+	require.NoError(t, err)
+	require.Len(t, failedBlocks, 2)
+	require.Equal(t, uint64(2), failedBlocks[0].Height)
+	require.Equal(t, uint64(3), failedBlocks[1].Height)
+}
+
+func TestFailedBlockRetrierPages(t *testing.T) {
+	ctx := context.Background()
+	indexer := newTestIndexer(t)
+	// The pages are clamped below the size the retrier asks for
+	indexer.DB.(*database.Impl).MaxPageSize = 2
+	queue := types.NewQueue(25)
+	retrier := NewFailedBlockRetrier(indexer.DB, queue, NewFailedBlocks(indexer.DB), time.Minute, 2)
+
+	require.NoError(t, indexer.DB.SaveBlock(ctx, models.NewBlockFromTmBlock(newHashedTestBlock(2), 0)))
+	for height := uint64(1); height <= 5; height++ {
+		require.NoError(t, indexer.DB.SaveFailedBlock(ctx, &models.FailedBlock{Height: height, Error: "to retry"}))
+	}
+
+	// Every height not stored gets enqueued, past the first page
+	count, err := retrier.Retry(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 4, count)
+	for _, expected := range []uint64{1, 3, 4, 5} {
+		require.Equal(t, expected, <-queue)
+	}
+}
+
+func TestFailedBlockRetriedAdvancesEpoch(t *testing.T) {
+	parserCfg := config.Cfg.Parser
+	t.Cleanup(func() { config.Cfg.Parser = parserCfg })
+	config.Cfg.Parser.BlockTransaction = true
+	config.Cfg.Parser.FailedBlockRetryInterval = time.Minute
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	indexer := newTestIndexer(t)
+	indexer.Node = &mockNode{eventTypes: []string{"save"}}
+	indexer.Modules = []modules.Module{&flakyEventModule{failures: 1}}
+
+	epochs := NewEpochCommitter(indexer.DB, 1, 3)
+	go epochs.Run(ctx)
+
+	// The retried heights are enqueued on the backfill queue, as when parsing
+	queue, backfillQueue, inFlight := types.NewQueue(25), types.NewQueue(25), NewInFlightHeights()
+	failed := NewFailedBlocks(indexer.DB)
+	retrier := NewFailedBlockRetrier(indexer.DB, backfillQueue, failed, time.Minute, 0)
+	retrier.SetEpochCommitter(epochs)
+	worker := NewWorker(NewContext(&params.EncodingConfig{Codec: indexer.codec}, indexer.Node, indexer.DB, nil, indexer), queue, 0, true)
+	worker.SetIndexer(indexer)
+	worker.SetEpochCommitter(epochs)
+	worker.SetBackfill(backfillQueue, inFlight)
+	worker.SetFailedBlocks(failed)
+	go worker.Start(ctx)
+
+	// The failed height holds the epoch back, the following one being held
+	queue <- 1
+	queue <- 2
+	require.Eventually(t, func() bool {
+		failedBlocks, err := indexer.DB.ListFailedBlocks(ctx, 10, 0)
+		require.NoError(t, err)
+		has, err := indexer.DB.HasBlock(ctx, 2)
+		require.NoError(t, err)
+		return len(failedBlocks) == 1 && has
+	}, 5*time.Second, time.Millisecond)
+	require.Equal(t, uint64(1), epochs.Next())
+
+	// Once retried successfully, the epoch moves past both
+	count, err := retrier.Retry(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+	require.Eventually(t, func() bool { return epochs.Next() == 3 }, 5*time.Second, time.Millisecond)
+}
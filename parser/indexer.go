@@ -3,9 +3,13 @@ package parser
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"sync"
 	"time"
 
+	abci "github.com/cometbft/cometbft/abci/types"
+	tmjson "github.com/cometbft/cometbft/libs/json"
 	tmctypes "github.com/cometbft/cometbft/rpc/core/types"
 	tmtypes "github.com/cometbft/cometbft/types"
 	"github.com/cosmos/cosmos-sdk/codec"
@@ -20,6 +24,7 @@ import (
 	"github.com/forbole/juno/v4/modules"
 	"github.com/forbole/juno/v4/node"
 	"github.com/forbole/juno/v4/types"
+	"github.com/forbole/juno/v4/types/config"
 )
 
 type Indexer interface {
@@ -50,7 +55,7 @@ type Indexer interface {
 	// in the order in which they have been registered.
 	HandleGenesis(genesisDoc *tmtypes.GenesisDoc, appState map[string]json.RawMessage) error
 
-	HandleBlock(block *tmctypes.ResultBlock, events *tmctypes.ResultBlockResults, txs []*types.Tx, getTmcValidators modules.GetTmcValidators)
+	HandleBlock(ctx context.Context, block *tmctypes.ResultBlock, events *tmctypes.ResultBlockResults, txs []*types.Tx, getTmcValidators modules.GetTmcValidators)
 
 	// HandleTx accepts the transaction and calls the tx handlers.
 	HandleTx(tx *types.Tx)
@@ -74,11 +79,14 @@ type Indexer interface {
 
 func DefaultIndexer(codec codec.Codec, proxy node.Node, db database.Database, modules []modules.Module) Indexer {
 	return &Impl{
-		Ctx:     context.TODO(),
+		// The handlers write what they read, which the replicas may not have received yet
+		Ctx:     database.ReadFromPrimary(context.TODO()),
 		codec:   codec,
 		Node:    proxy,
 		DB:      db,
 		Modules: modules,
+
+		moduleErrors: newModuleErrorCounter(),
 	}
 }
 
@@ -91,9 +99,34 @@ type Impl struct {
 
 	Node node.Node
 	DB   database.Database
+
+	// Source provides the heights to process, which are queried from Node when nil
+	Source BlockSource
+
+	// Metrics records where the time processing the blocks goes, DefaultMetrics being used when nil
+	Metrics Metrics
+
+	// timings accumulates the time spent by the module handlers, when processing a single block
+	timings *blockTimings
+
+	// moduleErrors counts the errors of the module handlers, halting the parser once one has too many of them
+	moduleErrors *moduleErrorCounter
+
+	// reorgMu makes the rollbacks of the reorganizations found by the workers happen one at a time
+	reorgMu sync.Mutex
 }
 
+// ExportEpoch stores the given block as the last one processed
 func (i *Impl) ExportEpoch(block *tmctypes.ResultBlock) error {
+	err := i.DB.SaveEpoch(i.Ctx, &models.Epoch{
+		OneRowId:    true,
+		BlockHeight: block.Block.Height,
+		BlockHash:   common.BytesToHash(block.BlockID.Hash),
+		UpdateTime:  block.Block.Time.Unix(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save epoch: %s", err)
+	}
 	return nil
 }
 
@@ -101,8 +134,12 @@ func (i *Impl) HandleGenesis(genesisDoc *tmtypes.GenesisDoc, appState map[string
 	// Call the genesis handlers
 	for _, module := range i.Modules {
 		if genesisModule, ok := module.(modules.GenesisModule); ok {
-			if err := genesisModule.HandleGenesis(genesisDoc, appState); err != nil {
+			err := i.callModule(module, "HandleGenesis", func() error {
+				return genesisModule.HandleGenesis(genesisDoc, appState)
+			})
+			if err != nil {
 				log.Errorw("error while handling genesis", "module", module, "err", err)
+				i.moduleErrors.record(module, err)
 			}
 		}
 	}
@@ -110,12 +147,15 @@ func (i *Impl) HandleGenesis(genesisDoc *tmtypes.GenesisDoc, appState map[string
 	return nil
 }
 
-func (i *Impl) HandleBlock(block *tmctypes.ResultBlock, events *tmctypes.ResultBlockResults, txs []*types.Tx, getTmcValidators modules.GetTmcValidators) {
+func (i *Impl) HandleBlock(ctx context.Context, block *tmctypes.ResultBlock, events *tmctypes.ResultBlockResults, txs []*types.Tx, getTmcValidators modules.GetTmcValidators) {
 	for _, module := range i.Modules {
-		if blockModule, ok := module.(modules.BlockModule); ok {
-			err := blockModule.HandleBlock(block, events, txs, getTmcValidators)
+		if blockModule, ok := module.(modules.BlockModule); ok && ModuleActive(module, block.Block.Height) {
+			err := i.callModule(module, "HandleBlock", func() error {
+				return blockModule.HandleBlock(ctx, block, events, txs, getTmcValidators)
+			})
 			if err != nil {
 				log.Errorw("error while handling block", "module", module.Name(), "height", block.Block.Height, "err", err)
+				i.moduleErrors.record(module, err)
 			}
 		}
 	}
@@ -124,11 +164,14 @@ func (i *Impl) HandleBlock(block *tmctypes.ResultBlock, events *tmctypes.ResultB
 func (i *Impl) HandleTx(tx *types.Tx) {
 	// Call the tx handlers
 	for _, module := range i.Modules {
-		if transactionModule, ok := module.(modules.TransactionModule); ok {
-			err := transactionModule.HandleTx(tx)
+		if transactionModule, ok := module.(modules.TransactionModule); ok && ModuleActive(module, tx.Height) {
+			err := i.callModule(module, "HandleTx", func() error {
+				return transactionModule.HandleTx(tx)
+			})
 			if err != nil {
 				log.Errorw("error while handling transaction", "module", module.Name(), "height", tx.Height,
 					"txHash", tx.TxHash, "err", err)
+				i.moduleErrors.record(module, err)
 			}
 		}
 	}
@@ -137,11 +180,14 @@ func (i *Impl) HandleTx(tx *types.Tx) {
 func (i *Impl) HandleMessage(block *tmctypes.ResultBlock, index int, msg sdk.Msg, tx *types.Tx) {
 	// Allow modules to handle the message
 	for _, module := range i.Modules {
-		if messageModule, ok := module.(modules.MessageModule); ok {
-			err := messageModule.HandleMsg(block, index, msg, tx)
+		if messageModule, ok := module.(modules.MessageModule); ok && ModuleActive(module, tx.Height) {
+			err := i.callModule(module, "HandleMsg", func() error {
+				return messageModule.HandleMsg(block, index, msg, tx)
+			})
 			if err != nil {
 				log.Errorw("error while handling message", "module", module, "height", tx.Height,
 					"txHash", tx.TxHash, "msg", proto.MessageName(msg), "err", err)
+				i.moduleErrors.record(module, err)
 			}
 		}
 	}
@@ -156,11 +202,14 @@ func (i *Impl) HandleMessage(block *tmctypes.ResultBlock, index int, msg sdk.Msg
 			}
 
 			for _, module := range i.Modules {
-				if messageModule, ok := module.(modules.AuthzMessageModule); ok {
-					err = messageModule.HandleMsgExec(index, msgExec, authzIndex, executedMsg, tx)
+				if messageModule, ok := module.(modules.AuthzMessageModule); ok && ModuleActive(module, tx.Height) {
+					err = i.callModule(module, "HandleMsgExec", func() error {
+						return messageModule.HandleMsgExec(index, msgExec, authzIndex, executedMsg, tx)
+					})
 					if err != nil {
 						log.Errorw("error while handling message", "module", module, "height", tx.Height,
 							"txHash", tx.TxHash, "msg", proto.MessageName(executedMsg), "err", err)
+						i.moduleErrors.record(module, err)
 					}
 				}
 			}
@@ -169,13 +218,23 @@ func (i *Impl) HandleMessage(block *tmctypes.ResultBlock, index int, msg sdk.Msg
 }
 
 // HandleEvent accepts the transaction and handles events contained inside the transaction.
+// The modules are only given the events from their activation height on, like every other handler.
+// An error returned by a module fails the event, while a module panicking only gets its panic counted,
+// the following modules still handling the event.
 func (i *Impl) HandleEvent(ctx context.Context, block *tmctypes.ResultBlock, txHash common.Hash, event sdk.Event) error {
 	for _, module := range i.Modules {
-		if eventModule, ok := module.(modules.EventModule); ok {
-			err := eventModule.HandleEvent(ctx, block, txHash, event)
+		if eventModule, ok := module.(modules.EventModule); ok && ModuleActive(module, block.Block.Height) {
+			err := i.callModule(module, "HandleEvent", func() error {
+				return eventModule.HandleEvent(ctx, block, txHash, event)
+			})
 			if err != nil {
 				log.Errorw("failed to handle event", "module", module.Name(), "event", event, "error", err)
-				return err
+				i.moduleErrors.record(module, err)
+
+				var panicErr *ModulePanicError
+				if !errors.As(err, &panicErr) {
+					return &ModuleError{Module: module.Name(), Err: err}
+				}
 			}
 		}
 	}
@@ -187,55 +246,223 @@ func (i *Impl) HandleEvent(ctx context.Context, block *tmctypes.ResultBlock, txH
 func (i *Impl) Process(height uint64) error {
 	log.Debugw("processing block", "height", height)
 
-	block, err := i.Node.Block(int64(height))
+	// Nothing gets processed anymore once a module has failed too many times
+	if err := i.moduleErrors.err(); err != nil {
+		return err
+	}
+
+	start := time.Now()
+	block, blockResults, txs, err := i.fetchBlock(height)
 	if err != nil {
-		return fmt.Errorf("failed to get block from node: %s", err)
+		return err
 	}
+	fetch := time.Since(start)
 
 	log.WorkerLatencyHist.Observe(float64(time.Since(block.Block.Time).Milliseconds()))
 
-	blockResults, err := i.Node.BlockResults(int64(height))
+	if maxDepth := config.Cfg.Parser.ReorgMaxDepth; maxDepth > 0 {
+		if err = i.checkReorg(block, maxDepth); err != nil {
+			return err
+		}
+	}
+
+	// The time spent exporting the block, apart from the one spent by the modules, goes to the database.
+	// The txs only being fetched while exported, the time spent getting them goes to the fetch.
+	start = time.Now()
+	timings := &blockTimings{}
+	if err = i.blockIndexer(i.Ctx, timings).export(block, blockResults, txs); err != nil {
+		return err
+	}
+
+	// With parallel_txs the time spent by the modules adds up the one of every goroutine, and may exceed the total
+	modulesTime := timings.modulesTime()
+	dbTime := time.Since(start) - modulesTime - txs.elapsed
+	if dbTime < 0 {
+		dbTime = 0
+	}
+	i.metrics().BlockProcessed(fetch+txs.elapsed, modulesTime, dbTime)
+
+	log.DBLatencyHist.Observe(float64(time.Since(block.Block.Time).Milliseconds()))
+
+	return nil
+}
+
+// export stores the given block along with its results and txs, inside a single database transaction
+// when the parser config asks for it
+func (i *Impl) export(block *tmctypes.ResultBlock, blockResults *tmctypes.ResultBlockResults, txs *BlockTxs) error {
+	if config.Cfg.Parser.BlockTransaction {
+		return i.exportBlockInTx(block, blockResults, txs, i.source().Validators)
+	}
+	return i.exportBlock(block, blockResults, txs, i.source().Validators)
+}
+
+// fetchBlock gets the block at the given height along with its results and txs from the source of the indexer
+func (i *Impl) fetchBlock(height uint64) (*tmctypes.ResultBlock, *tmctypes.ResultBlockResults, *BlockTxs, error) {
+	return i.source().Fetch(height)
+}
+
+// source returns the source the indexer gets the heights from, the node unless another one is set
+func (i *Impl) source() BlockSource {
+	if i.Source != nil {
+		return i.Source
+	}
+	source := NewNodeSource(i.Node)
+	source.metrics = i.metrics()
+	return source
+}
+
+// metrics returns the metrics of the indexer, DefaultMetrics unless others are set
+func (i *Impl) metrics() Metrics {
+	return metricsOrDefault(i.Metrics)
+}
+
+// blockIndexer returns a copy of the indexer writing through ctx, recording the time spent by the module
+// handlers into timings
+func (i *Impl) blockIndexer(ctx context.Context, timings *blockTimings) *Impl {
+	return &Impl{
+		Ctx:          ctx,
+		Modules:      i.Modules,
+		codec:        i.codec,
+		Node:         i.Node,
+		DB:           i.DB,
+		Source:       i.Source,
+		Metrics:      i.Metrics,
+		timings:      timings,
+		moduleErrors: i.moduleErrors,
+	}
+}
+
+// callModule calls the given handler of module like callModule does, recording the time it takes
+func (i *Impl) callModule(module modules.Module, handler string, call func() error) error {
+	start := time.Now()
+	err := callModule(module, handler, call)
+	elapsed := time.Since(start)
+
+	i.metrics().ModuleHandled(module.Name(), handler, elapsed)
+	i.timings.addModules(elapsed)
+	return err
+}
+
+// exportBlock stores the given block along with its txs and events, calling the modules handlers.
+// The txs are decoded, stored and handled TxBatchSize at a time, unless a block module requires all of them.
+func (i *Impl) exportBlock(
+	block *tmctypes.ResultBlock, blockResults *tmctypes.ResultBlockResults, txs *BlockTxs, getTmcValidators modules.GetTmcValidators,
+) error {
+	if config.Cfg.Parser.StoreBlockResults {
+		err := i.storeBlockResults(blockResults)
+		if err != nil {
+			return err
+		}
+	}
+
+	blockTxs, err := i.blockModulesTxs(txs)
+	if err != nil {
+		return err
+	}
+
+	err = i.ExportBlock(block, blockResults, blockTxs, getTmcValidators)
+	if err != nil {
+		return err
+	}
+
+	height := uint64(blockResults.Height)
+	err = i.archiveBlockEvents(i.Ctx, height, models.EventOriginBeginBlock, blockResults.BeginBlockEvents)
 	if err != nil {
-		return fmt.Errorf("failed to get block results from node: %s", err)
+		return err
 	}
 
-	txs, err := i.Node.Txs(block)
+	progress, err := i.loadBlockProgress(block)
 	if err != nil {
-		return fmt.Errorf("failed to get transactions for block: %s", err)
+		return err
 	}
 
-	err = i.ExportBlock(block, blockResults, txs, nil)
+	err = txs.Batches(TxBatchSize, func(first int, batch []*types.Tx) error {
+		err := i.saveTxs(block, first, batch)
+		if err != nil {
+			return err
+		}
+
+		if progress != nil {
+			err = i.handleTxsWithProgress(block, batch, first, progress)
+		} else {
+			err = i.handleTxsBatch(block, batch)
+		}
+		if err != nil {
+			return err
+		}
+
+		return i.archiveTxsEvents(i.Ctx, height, first, batch)
+	})
 	if err != nil {
 		return err
 	}
 
-	err = i.ExportTxs(block, txs)
+	err = i.archiveBlockEvents(i.Ctx, height, models.EventOriginEndBlock, blockResults.EndBlockEvents)
 	if err != nil {
 		return err
 	}
+	return progress.clear()
+}
+
+// exportBlockInTx behaves like exportBlock, but writes everything inside a single transaction which also
+// updates the epoch. The event handlers join the transaction through the context they are given, so a
+// failing one leaves nothing of the block behind.
+func (i *Impl) exportBlockInTx(
+	block *tmctypes.ResultBlock, blockResults *tmctypes.ResultBlockResults, txs *BlockTxs, getTmcValidators modules.GetTmcValidators,
+) error {
+	tx := i.DB.Begin(i.Ctx)
+	if tx.Db.Error != nil {
+		return fmt.Errorf("failed to begin block transaction: %s", tx.Db.Error)
+	}
+
+	// Once the transaction has been committed, rolling it back is a no-op
+	defer func() {
+		if err := tx.Rollback(); err != nil && !errors.Is(err, database.ErrTxFinished) {
+			log.Errorw("failed to rollback block transaction", "height", block.Block.Height, "err", err)
+		}
+	}()
 
-	err = i.ExportEventsByTxs(i.Ctx, block, txs)
+	// The indexer shared by the workers is left untouched, the block one writing through the transaction
+	blockIndexer := i.blockIndexer(database.ContextWithTx(i.Ctx, tx), i.timings)
+
+	err := blockIndexer.exportBlock(block, blockResults, txs, getTmcValidators)
 	if err != nil {
 		return err
 	}
 
-	log.DBLatencyHist.Observe(float64(time.Since(block.Block.Time).Milliseconds()))
+	// The epoch committer advances the epoch itself, once the heights before this one are stored as well
+	if config.Cfg.Parser.EpochWindow <= 0 {
+		err = blockIndexer.ExportEpoch(block)
+		if err != nil {
+			return err
+		}
+	}
+
+	// A module reaching the error threshold while handling the block leaves nothing of it behind
+	if err = i.moduleErrors.err(); err != nil {
+		return err
+	}
 
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("failed to commit block %d: %s", block.Block.Height, err)
+	}
 	return nil
 }
 
 // ExportBlock accepts a finalized block and persists then inside the database.
+// The gas used by the block is summed out of its results, the given txs being the ones handed to the block modules.
 // An error is returned if write fails.
 func (i *Impl) ExportBlock(
 	block *tmctypes.ResultBlock, events *tmctypes.ResultBlockResults, txs []*types.Tx, getTmcValidators modules.GetTmcValidators,
 ) error {
 	// Save the block
-	err := i.DB.SaveBlock(i.Ctx, models.NewBlockFromTmBlock(block, SumGasTxs(txs)))
+	err := i.DB.SaveBlock(i.Ctx, models.NewBlockFromTmBlock(block, SumGasResults(events)))
 	if err != nil {
 		return fmt.Errorf("failed to persist block: %s", err)
 	}
 
-	i.HandleBlock(block, events, txs, getTmcValidators)
+	i.HandleBlock(i.Ctx, block, events, txs, getTmcValidators)
 
 	return nil
 }
@@ -269,7 +496,7 @@ func (i *Impl) ExportCommit(block *tmctypes.ResultBlock, getTmcValidators module
 		))
 	}
 
-	err := i.DB.SaveCommitSignatures(context.TODO(), signatures)
+	err := i.DB.SaveCommitSignatures(i.Ctx, signatures)
 	if err != nil {
 		return fmt.Errorf("error while saving commit signatures: %s", err)
 	}
@@ -280,14 +507,27 @@ func (i *Impl) ExportCommit(block *tmctypes.ResultBlock, getTmcValidators module
 // ExportTxs accepts a slice of transactions and persists then inside the database.
 // An error is returned if write fails.
 func (i *Impl) ExportTxs(block *tmctypes.ResultBlock, txs []*types.Tx) error {
-	// handle all transactions inside the block
-	for ind, tx := range txs {
-		// save the transaction
-		err := i.DB.SaveTx(context.TODO(), uint64(block.Block.Time.UTC().UnixNano()), ind, tx)
-		if err != nil {
-			return fmt.Errorf("error while storing tx with hash %s, %s", tx.TxHash, err)
-		}
+	err := i.saveTxs(block, 0, txs)
+	if err != nil {
+		return err
+	}
+
+	return i.handleTxs(block, txs)
+}
+
+// saveTxs stores the given transactions of block, found inside it from the index first on, all at once
+func (i *Impl) saveTxs(block *tmctypes.ResultBlock, first int, txs []*types.Tx) error {
+	err := i.DB.SaveTxsFrom(i.Ctx, uint64(block.Block.Time.UTC().UnixNano()), first, txs)
+	if err != nil {
+		return fmt.Errorf("error while storing txs of block %d, %s", block.Block.Height, err)
+	}
+	return nil
+}
 
+// handleTxs calls the tx and message handlers of the modules for every transaction of the given block
+func (i *Impl) handleTxs(block *tmctypes.ResultBlock, txs []*types.Tx) error {
+	// handle all transactions inside the block
+	for _, tx := range txs {
 		// call the tx handlers
 		i.HandleTx(tx)
 
@@ -315,8 +555,8 @@ func (i *Impl) ExportEvents(ctx context.Context, block *tmctypes.ResultBlock, bl
 	txsResults := blockResults.TxsResults
 
 	for _, tx := range txsResults {
-		for _, event := range tx.Events {
-			if err := i.HandleEvent(ctx, block, common.Hash{}, sdk.Event(event)); err != nil {
+		for index, event := range tx.Events {
+			if err := i.HandleEvent(modules.WithEventIndex(ctx, index), block, common.Hash{}, sdk.Event(event)); err != nil {
 				return err
 			}
 		}
@@ -327,8 +567,8 @@ func (i *Impl) ExportEvents(ctx context.Context, block *tmctypes.ResultBlock, bl
 func (i *Impl) ExportEventsByTxs(ctx context.Context, block *tmctypes.ResultBlock, txs []*types.Tx) error {
 	for _, tx := range txs {
 		txHash := common.HexToHash(tx.TxHash)
-		for _, event := range tx.Events {
-			if err := i.HandleEvent(ctx, block, txHash, sdk.Event(event)); err != nil {
+		for index, event := range tx.Events {
+			if err := i.HandleEvent(modules.WithEventIndex(ctx, index), block, txHash, sdk.Event(event)); err != nil {
 				return err
 			}
 		}
@@ -336,10 +576,47 @@ func (i *Impl) ExportEventsByTxs(ctx context.Context, block *tmctypes.ResultBloc
 	return nil
 }
 
-// Processed tells whether the current Indexer has already processed the given height of Block
+// storeBlockResults saves the given block results inside the database, encoded as the node returns them
+func (i *Impl) storeBlockResults(blockResults *tmctypes.ResultBlockResults) error {
+	bz, err := tmjson.Marshal(blockResults)
+	if err != nil {
+		return fmt.Errorf("failed to encode block results: %s", err)
+	}
+
+	err = i.DB.SaveBlockResult(i.Ctx, uint64(blockResults.Height), bz)
+	if err != nil {
+		return fmt.Errorf("failed to store block results: %s", err)
+	}
+	return nil
+}
+
+// archiveBlockEvents stores the raw events emitted by the block at the given height outside its txs, which the
+// database skips unless it archives them
+func (i *Impl) archiveBlockEvents(ctx context.Context, height uint64, origin models.EventOrigin, events []abci.Event) error {
+	err := i.DB.SaveBlockEvents(ctx, height, origin, toSDKEvents(events))
+	if err != nil {
+		return fmt.Errorf("failed to archive %s events: %s", origin, err)
+	}
+	return nil
+}
+
+// archiveTxsEvents stores the raw events emitted by the given txs, found inside their block from the index first on,
+// which the database skips unless it archives them
+func (i *Impl) archiveTxsEvents(ctx context.Context, height uint64, first int, txs []*types.Tx) error {
+	for index, tx := range txs {
+		err := i.DB.SaveEvents(ctx, height, uint32(first+index), common.HexToHash(tx.TxHash), toSDKEvents(tx.Events))
+		if err != nil {
+			return fmt.Errorf("failed to archive events of tx %s: %s", tx.TxHash, err)
+		}
+	}
+	return nil
+}
+
+// Processed tells whether the current Indexer has already processed the given height of Block, which a
+// block whose progress is still recorded hasn't, its export having to be resumed.
 // An error is returned if the operation fails.
 func (i *Impl) Processed(ctx context.Context, height uint64) (bool, error) {
-	return i.DB.HasBlock(ctx, height)
+	return blockStored(ctx, i.DB, height)
 }
 
 // GetBlockRecordNum returns total number of blocks stored in database.
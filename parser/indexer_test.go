@@ -0,0 +1,150 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	abci "github.com/cometbft/cometbft/abci/types"
+	tmctypes "github.com/cometbft/cometbft/rpc/core/types"
+	tmtypes "github.com/cometbft/cometbft/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	sdktx "github.com/cosmos/cosmos-sdk/types/tx"
+	"github.com/stretchr/testify/require"
+
+	"github.com/forbole/juno/v4/common"
+	"github.com/forbole/juno/v4/database"
+	"github.com/forbole/juno/v4/database/sqlite/sqlitetest"
+	"github.com/forbole/juno/v4/models"
+	"github.com/forbole/juno/v4/modules"
+	"github.com/forbole/juno/v4/types"
+)
+
+var errEventFailed = errors.New("event failed")
+
+// bucketEventModule saves a bucket for every "save" event, and fails on the "fail" ones
+type bucketEventModule struct {
+	db database.Database
+}
+
+func (m *bucketEventModule) Name() string { return "bucket_event" }
+
+func (m *bucketEventModule) HandleEvent(ctx context.Context, _ *tmctypes.ResultBlock, _ common.Hash, event sdk.Event) error {
+	switch event.Type {
+	case "save":
+		return m.db.SaveBucket(ctx, &models.Bucket{
+			BucketID:   common.HexToHash(string(event.Attributes[0].Value)),
+			BucketName: string(event.Attributes[0].Value),
+		})
+	case "fail":
+		return errEventFailed
+	}
+	return nil
+}
+
+func (m *bucketEventModule) ExtractEventStatements(context.Context, *tmctypes.ResultBlock, common.Hash, sdk.Event) (map[string][]interface{}, error) {
+	return nil, nil
+}
+
+func (m *bucketEventModule) SetCtx(string, interface{}) {}
+
+func (m *bucketEventModule) GetCtx(string) interface{} { return nil }
+
+func (m *bucketEventModule) ClearCtx() {}
+
+func newTestIndexer(t testing.TB) *Impl {
+	t.Helper()
+
+	db := sqlitetest.NewDatabase(t, &models.Tx{}, &models.Block{}, &models.Epoch{}, &models.Bucket{}, &models.FailedBlock{})
+	return &Impl{
+		Ctx:     context.Background(),
+		codec:   db.EncodingConfig.Codec,
+		DB:      db,
+		Modules: []modules.Module{&bucketEventModule{db: db}},
+	}
+}
+
+func newTestBlock(height int64) *tmctypes.ResultBlock {
+	return &tmctypes.ResultBlock{
+		BlockID: tmtypes.BlockID{Hash: common.HexToHash("0x01").Bytes()},
+		Block: &tmtypes.Block{
+			Header:     tmtypes.Header{Height: height, Time: time.Unix(1700000000, 0)},
+			LastCommit: &tmtypes.Commit{},
+		},
+	}
+}
+
+// newTestTx returns a tx without messages, emitting the events of the given types
+func newTestTx(height int64, eventTypes ...string) *types.Tx {
+	var events []abci.Event
+	for i, eventType := range eventTypes {
+		events = append(events, abci.Event{
+			Type:       eventType,
+			Attributes: []abci.EventAttribute{{Key: "id", Value: fmt.Sprintf("0x%02x", i+1)}},
+		})
+	}
+
+	return &types.Tx{
+		Tx: &sdktx.Tx{
+			Body:     &sdktx.TxBody{},
+			AuthInfo: &sdktx.AuthInfo{Fee: &sdktx.Fee{}},
+		},
+		TxResponse: &sdk.TxResponse{
+			TxHash: common.HexToHash("0x02").Hex(),
+			Height: height,
+			Events: events,
+		},
+	}
+}
+
+func TestExportBlockInTx(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("commit", func(t *testing.T) {
+		indexer := newTestIndexer(t)
+		block := newTestBlock(10)
+		require.NoError(t, indexer.exportBlockInTx(block, &tmctypes.ResultBlockResults{Height: 10}, NewBlockTxs([]*types.Tx{newTestTx(10, "save")}), nil))
+
+		has, err := indexer.DB.HasBlock(ctx, 10)
+		require.NoError(t, err)
+		require.True(t, has)
+
+		_, total, err := indexer.DB.GetTxsByHeight(ctx, 10, 10, 0)
+		require.NoError(t, err)
+		require.Equal(t, int64(1), total)
+
+		epoch, err := indexer.DB.GetEpoch(ctx)
+		require.NoError(t, err)
+		require.Equal(t, int64(10), epoch.BlockHeight)
+		require.Equal(t, common.BytesToHash(block.BlockID.Hash), epoch.BlockHash)
+
+		var buckets int64
+		require.NoError(t, indexer.DB.(*database.Impl).Db.Table((&models.Bucket{}).TableName()).Count(&buckets).Error)
+		require.Equal(t, int64(1), buckets)
+	})
+
+	t.Run("handler error", func(t *testing.T) {
+		indexer := newTestIndexer(t)
+		err := indexer.exportBlockInTx(newTestBlock(10), &tmctypes.ResultBlockResults{Height: 10}, NewBlockTxs([]*types.Tx{newTestTx(10, "save", "fail")}), nil)
+		require.ErrorIs(t, err, errEventFailed)
+
+		// Nothing of the block must have been stored, including what the module saved before failing
+		has, err := indexer.DB.HasBlock(ctx, 10)
+		require.NoError(t, err)
+		require.False(t, has)
+
+		_, total, err := indexer.DB.GetTxsByHeight(ctx, 10, 10, 0)
+		require.NoError(t, err)
+		require.Zero(t, total)
+
+		epoch, err := indexer.DB.GetEpoch(ctx)
+		require.NoError(t, err)
+		require.Zero(t, epoch.BlockHeight)
+
+		var buckets int64
+		require.NoError(t, indexer.DB.(*database.Impl).Db.Table((&models.Bucket{}).TableName()).Count(&buckets).Error)
+		require.Zero(t, buckets)
+	})
+}
@@ -0,0 +1,223 @@
+package parser
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/forbole/juno/v4/database"
+	"github.com/forbole/juno/v4/log"
+	"github.com/forbole/juno/v4/node"
+)
+
+// DefaultHeightsInterval is how often the heights of the chain and of the epoch get reported
+const DefaultHeightsInterval = 10 * time.Second
+
+// Metrics records how the parser keeps up with the chain, and where the time processing the blocks goes
+type Metrics interface {
+	// SetHeights records the latest height of the chain, and the height of the stored epoch
+	SetHeights(chain, epoch uint64)
+
+	// BlockProcessed records that a block got processed, along with the time spent fetching it, inside the
+	// module handlers, and writing it to the database
+	BlockProcessed(fetch, modules, db time.Duration)
+
+	// ModuleHandled records the time spent inside the given handler of a module
+	ModuleHandled(module, handler string, elapsed time.Duration)
+
+	// NodeRetried records that the given node query got retried after a transient failure
+	NodeRetried(query string)
+}
+
+var (
+	_ Metrics = &PrometheusMetrics{}
+	_ Metrics = NoopMetrics{}
+)
+
+// DefaultMetrics is the Metrics implementation used when no other one is set, exported along with the other
+// Telemetry metrics
+var DefaultMetrics Metrics = NewPrometheusMetrics(prometheus.DefaultRegisterer)
+
+// metricsOrDefault returns the given metrics, or DefaultMetrics when nil
+func metricsOrDefault(metrics Metrics) Metrics {
+	if metrics == nil {
+		return DefaultMetrics
+	}
+	return metrics
+}
+
+// PrometheusMetrics implements Metrics through Prometheus collectors
+type PrometheusMetrics struct {
+	chainHeight     prometheus.Gauge
+	epochHeight     prometheus.Gauge
+	lag             prometheus.Gauge
+	blocksProcessed prometheus.Counter
+	stageLatency    *prometheus.HistogramVec
+	moduleLatency   *prometheus.HistogramVec
+	nodeRetries     *prometheus.CounterVec
+}
+
+// NewPrometheusMetrics returns a PrometheusMetrics whose collectors are registered into registerer
+func NewPrometheusMetrics(registerer prometheus.Registerer) *PrometheusMetrics {
+	factory := promauto.With(registerer)
+	return &PrometheusMetrics{
+		chainHeight: factory.NewGauge(prometheus.GaugeOpts{
+			Namespace: log.Namespace,
+			Subsystem: "parser",
+			Name:      "chain_height",
+			Help:      "Latest height of the chain.",
+		}),
+		epochHeight: factory.NewGauge(prometheus.GaugeOpts{
+			Namespace: log.Namespace,
+			Subsystem: "parser",
+			Name:      "epoch_height",
+			Help:      "Height of the stored epoch, or of the last stored block when no epoch is stored.",
+		}),
+		lag: factory.NewGauge(prometheus.GaugeOpts{
+			Namespace: log.Namespace,
+			Subsystem: "parser",
+			Name:      "lag",
+			Help:      "Number of heights between the epoch and the latest height of the chain.",
+		}),
+		blocksProcessed: factory.NewCounter(prometheus.CounterOpts{
+			Namespace: log.Namespace,
+			Subsystem: "parser",
+			Name:      "blocks_processed",
+			Help:      "Count of processed blocks.",
+		}),
+		stageLatency: factory.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: log.Namespace,
+			Subsystem: "parser",
+			Name:      "stage_latency",
+			Help:      "Time spent processing a block, in seconds, by stage: fetch, modules or db.",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 3, 12),
+		}, []string{"stage"}),
+		moduleLatency: factory.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: log.Namespace,
+			Subsystem: "parser",
+			Name:      "module_latency",
+			Help:      "Time spent inside the handlers of each module, in seconds.",
+			Buckets:   prometheus.ExponentialBuckets(0.0001, 3, 12),
+		}, []string{"module", "handler"}),
+		nodeRetries: factory.NewCounterVec(prometheus.CounterOpts{
+			Namespace: log.Namespace,
+			Subsystem: "parser",
+			Name:      "node_retries",
+			Help:      "Count of node queries retried after a transient failure.",
+		}, []string{"query"}),
+	}
+}
+
+// SetHeights implements Metrics
+func (m *PrometheusMetrics) SetHeights(chain, epoch uint64) {
+	m.chainHeight.Set(float64(chain))
+	m.epochHeight.Set(float64(epoch))
+	lag := 0.0
+	if chain > epoch {
+		lag = float64(chain - epoch)
+	}
+	m.lag.Set(lag)
+}
+
+// BlockProcessed implements Metrics
+func (m *PrometheusMetrics) BlockProcessed(fetch, modules, db time.Duration) {
+	m.blocksProcessed.Inc()
+	m.stageLatency.WithLabelValues("fetch").Observe(fetch.Seconds())
+	m.stageLatency.WithLabelValues("modules").Observe(modules.Seconds())
+	m.stageLatency.WithLabelValues("db").Observe(db.Seconds())
+}
+
+// ModuleHandled implements Metrics
+func (m *PrometheusMetrics) ModuleHandled(module, handler string, elapsed time.Duration) {
+	m.moduleLatency.WithLabelValues(module, handler).Observe(elapsed.Seconds())
+}
+
+// NodeRetried implements Metrics
+func (m *PrometheusMetrics) NodeRetried(query string) {
+	m.nodeRetries.WithLabelValues(query).Inc()
+}
+
+// NoopMetrics implements Metrics recording nothing
+type NoopMetrics struct{}
+
+// SetHeights implements Metrics
+func (NoopMetrics) SetHeights(uint64, uint64) {}
+
+// BlockProcessed implements Metrics
+func (NoopMetrics) BlockProcessed(time.Duration, time.Duration, time.Duration) {}
+
+// ModuleHandled implements Metrics
+func (NoopMetrics) ModuleHandled(string, string, time.Duration) {}
+
+// NodeRetried implements Metrics
+func (NoopMetrics) NodeRetried(string) {}
+
+// ReportHeights reports into metrics every interval the latest height of the node and the height of the stored
+// epoch, until ctx is done
+func ReportHeights(ctx context.Context, node node.Node, db database.Database, metrics Metrics, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := reportHeights(ctx, node, db, metrics); err != nil {
+			log.Errorw("failed to report heights", "err", err)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// reportHeights reports into metrics the latest height of the node and the height of the stored epoch, the one
+// of the last stored block being used when no epoch is stored
+func reportHeights(ctx context.Context, node node.Node, db database.Database, metrics Metrics) error {
+	chain, err := node.LatestHeight()
+	if err != nil {
+		return err
+	}
+
+	epoch, err := db.GetEpoch(ctx)
+	if err != nil {
+		return err
+	}
+	height := uint64(epoch.BlockHeight)
+	if height == 0 {
+		if height, err = db.GetLastBlockHeight(ctx); err != nil {
+			return err
+		}
+	}
+
+	metrics.SetHeights(uint64(chain), height)
+	return nil
+}
+
+// blockTimings accumulates the time spent inside the module handlers while processing a single block, whose txs
+// may be handled concurrently
+type blockTimings struct {
+	mu      sync.Mutex
+	modules time.Duration
+}
+
+// addModules adds the given time spent inside a module handler. Nothing is recorded for a nil blockTimings.
+func (t *blockTimings) addModules(elapsed time.Duration) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.modules += elapsed
+}
+
+// modulesTime returns the time spent inside the module handlers so far
+func (t *blockTimings) modulesTime() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.modules
+}
@@ -0,0 +1,88 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	tmctypes "github.com/cometbft/cometbft/rpc/core/types"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/forbole/juno/v4/types/config"
+)
+
+// refusingNode refuses the connection the first time the block results are queried
+type refusingNode struct {
+	*mockNode
+	refused atomic.Bool
+}
+
+func (n *refusingNode) BlockResults(height int64) (*tmctypes.ResultBlockResults, error) {
+	if n.refused.CompareAndSwap(false, true) {
+		return nil, fmt.Errorf("failed to query block results: %w", syscall.ECONNREFUSED)
+	}
+	return n.mockNode.BlockResults(height)
+}
+
+// gatherSeries returns the value of every series gathered from registry, keyed by name and labels
+func gatherSeries(t *testing.T, registry *prometheus.Registry) map[string]float64 {
+	families, err := registry.Gather()
+	require.NoError(t, err)
+
+	series := make(map[string]float64)
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			key := family.GetName()
+			for _, label := range metric.GetLabel() {
+				key += fmt.Sprintf(",%s=%s", label.GetName(), label.GetValue())
+			}
+
+			switch {
+			case metric.GetCounter() != nil:
+				series[key] = metric.GetCounter().GetValue()
+			case metric.GetGauge() != nil:
+				series[key] = metric.GetGauge().GetValue()
+			case metric.GetHistogram() != nil:
+				series[key] = float64(metric.GetHistogram().GetSampleCount())
+			}
+		}
+	}
+	return series
+}
+
+func TestMetricsRecorded(t *testing.T) {
+	parserCfg := config.Cfg.Parser
+	t.Cleanup(func() { config.Cfg.Parser = parserCfg })
+	config.Cfg.Parser.NodeRetryDelay = time.Millisecond
+
+	ctx := context.Background()
+	registry := prometheus.NewRegistry()
+	indexer := newTestIndexer(t)
+	indexer.Node = &refusingNode{mockNode: &mockNode{latest: 5, eventTypes: []string{"save"}}}
+	indexer.Metrics = NewPrometheusMetrics(registry)
+
+	for height := uint64(1); height <= 3; height++ {
+		require.NoError(t, indexer.Process(height))
+	}
+	require.NoError(t, reportHeights(ctx, indexer.Node, indexer.DB, indexer.Metrics))
+
+	series := gatherSeries(t, registry)
+	for key, value := range map[string]float64{
+		"juno_parser_blocks_processed":                                       3,
+		"juno_parser_stage_latency,stage=fetch":                              3,
+		"juno_parser_stage_latency,stage=modules":                            3,
+		"juno_parser_stage_latency,stage=db":                                 3,
+		"juno_parser_module_latency,handler=HandleEvent,module=bucket_event": 3,
+		"juno_parser_node_retries,query=block results":                       1,
+		"juno_parser_chain_height":                                           5,
+		"juno_parser_epoch_height":                                           3,
+		"juno_parser_lag":                                                    2,
+	} {
+		require.Contains(t, series, key)
+		require.Equal(t, value, series[key], key)
+	}
+}
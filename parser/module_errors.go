@@ -0,0 +1,140 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/forbole/juno/v4/log"
+	"github.com/forbole/juno/v4/modules"
+	"github.com/forbole/juno/v4/types/config"
+)
+
+// ModulePanicError is the error a panic raised by a module handler gets turned into
+type ModulePanicError struct {
+	Module  string
+	Handler string
+	Value   interface{}
+}
+
+func (e *ModulePanicError) Error() string {
+	return fmt.Sprintf("module %s panicked in %s: %v", e.Module, e.Handler, e.Value)
+}
+
+// ModuleError is an error returned by a module handler, failing the height being processed
+type ModuleError struct {
+	Module string
+	Err    error
+}
+
+func (e *ModuleError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ModuleError) Unwrap() error {
+	return e.Err
+}
+
+// failedModule returns the name of the module whose handler caused the given error, empty if none did
+func failedModule(err error) string {
+	var moduleErr *ModuleError
+	if errors.As(err, &moduleErr) {
+		return moduleErr.Module
+	}
+	return ""
+}
+
+// callModule calls the given handler of module, turning a panic it raises into a ModulePanicError,
+// so that a broken module doesn't take the whole parser down
+func callModule(module modules.Module, handler string, call func() error) (err error) {
+	defer func() {
+		if value := recover(); value != nil {
+			err = &ModulePanicError{Module: module.Name(), Handler: handler, Value: value}
+			log.Errorw("module panicked", "module", module.Name(), "handler", handler, "panic", value,
+				"stack", string(debug.Stack()))
+		}
+	}()
+	return call()
+}
+
+// moduleErrorCounter counts the errors returned, and the panics raised, by the handlers of each module.
+// Once a module reaches the threshold of the parser config, the parser halts: no height gets processed anymore,
+// so that a broken module doesn't leave its data silently incomplete for every height following.
+type moduleErrorCounter struct {
+	mu     sync.Mutex
+	counts map[string]int
+	// halted is the error telling why the parser halted, nil until it does
+	halted error
+}
+
+// newModuleErrorCounter returns a moduleErrorCounter with no error counted yet
+func newModuleErrorCounter() *moduleErrorCounter {
+	return &moduleErrorCounter{counts: make(map[string]int)}
+}
+
+// record counts the given error of module, if any. Nothing is counted but the metrics for a nil counter.
+func (c *moduleErrorCounter) record(module modules.Module, err error) {
+	if err == nil {
+		return
+	}
+
+	kind := "error"
+	var panicErr *ModulePanicError
+	if errors.As(err, &panicErr) {
+		kind = "panic"
+	}
+	log.ModuleErrors.WithLabelValues(module.Name(), kind).Inc()
+
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.counts[module.Name()]++
+	threshold := config.Cfg.Parser.ModuleErrorThreshold
+	if c.halted == nil && threshold > 0 && c.counts[module.Name()] >= threshold {
+		c.halted = fmt.Errorf("parser halted after %d errors of module %s, the last one being: %s",
+			c.counts[module.Name()], module.Name(), err)
+		log.Errorw("module error threshold reached, halting the parser", "module", module.Name(), "threshold", threshold)
+	}
+}
+
+// err returns an error if a module has reached the error threshold, nil otherwise
+func (c *moduleErrorCounter) err() error {
+	if c == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.halted
+}
+
+// summary returns an error telling how many errors each module has had, nil if none has had any
+func (c *moduleErrorCounter) summary() error {
+	if c == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.counts) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(c.counts))
+	for name := range c.counts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	counts := make([]string, len(names))
+	for index, name := range names {
+		counts[index] = fmt.Sprintf("%s: %d", name, c.counts[name])
+	}
+	return fmt.Errorf("module errors occurred (%s)", strings.Join(counts, ", "))
+}
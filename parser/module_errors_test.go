@@ -0,0 +1,99 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	tmctypes "github.com/cometbft/cometbft/rpc/core/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/forbole/juno/v4/common"
+	"github.com/forbole/juno/v4/log"
+	"github.com/forbole/juno/v4/models"
+	"github.com/forbole/juno/v4/modules"
+	"github.com/forbole/juno/v4/types/config"
+)
+
+// panickingModule dereferences a nil bucket on every "save" event
+type panickingModule struct {
+	bucketEventModule
+}
+
+func (m *panickingModule) Name() string { return "panicking" }
+
+func (m *panickingModule) HandleEvent(_ context.Context, _ *tmctypes.ResultBlock, _ common.Hash, event sdk.Event) error {
+	if event.Type == "save" {
+		var bucket *models.Bucket
+		return errors.New(bucket.BucketName)
+	}
+	return nil
+}
+
+func TestModulePanicIsolated(t *testing.T) {
+	parserCfg := config.Cfg.Parser
+	t.Cleanup(func() { config.Cfg.Parser = parserCfg })
+
+	for _, tc := range []struct {
+		name             string
+		blockTransaction bool
+	}{
+		{name: "without block transaction"},
+		{name: "with block transaction", blockTransaction: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			blockTransaction := tc.blockTransaction
+			config.Cfg.Parser.BlockTransaction = blockTransaction
+			config.Cfg.Parser.ModuleErrorThreshold = 2
+
+			ctx := context.Background()
+			indexer := newTestIndexer(t)
+			indexer.Node = &mockNode{eventTypes: []string{"save"}}
+			indexer.Modules = append([]modules.Module{&panickingModule{}}, indexer.Modules...)
+			indexer.moduleErrors = newModuleErrorCounter()
+			panics := testutil.ToFloat64(log.ModuleErrors.WithLabelValues("panicking", "panic"))
+
+			// The module following the panicking one still handles the event, and the block gets stored
+			require.NoError(t, indexer.Process(1))
+			has, err := indexer.DB.HasBlock(ctx, 1)
+			require.NoError(t, err)
+			require.True(t, has)
+			_, err = indexer.DB.GetBucketByName(ctx, "0x01")
+			require.NoError(t, err)
+			require.Equal(t, panics+1, testutil.ToFloat64(log.ModuleErrors.WithLabelValues("panicking", "panic")))
+
+			// The second panic reaches the threshold, halting the parser
+			err = indexer.Process(2)
+			if blockTransaction {
+				require.ErrorContains(t, err, "parser halted after 2 errors of module panicking")
+			} else {
+				require.NoError(t, err)
+			}
+			require.ErrorContains(t, indexer.Process(3), "parser halted")
+
+			has, err = indexer.DB.HasBlock(ctx, 2)
+			require.NoError(t, err)
+			require.Equal(t, !blockTransaction, has)
+			has, err = indexer.DB.HasBlock(ctx, 3)
+			require.NoError(t, err)
+			require.False(t, has)
+		})
+	}
+}
+
+func TestCallModuleRecoversPanic(t *testing.T) {
+	module := &panickingModule{}
+
+	err := callModule(module, "HandleBlock", func() error {
+		var block *tmctypes.ResultBlock
+		return errors.New(block.Block.ChainID)
+	})
+	var panicErr *ModulePanicError
+	require.ErrorAs(t, err, &panicErr)
+	require.Equal(t, "panicking", panicErr.Module)
+	require.Equal(t, "HandleBlock", panicErr.Handler)
+
+	require.ErrorIs(t, callModule(module, "HandleBlock", func() error { return errEventFailed }), errEventFailed)
+}
@@ -0,0 +1,25 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/forbole/juno/v4/modules"
+	"github.com/forbole/juno/v4/modules/registrar"
+)
+
+func TestFilteredModulesNotInvoked(t *testing.T) {
+	included, excluded := &heightsModule{name: "included"}, &heightsModule{name: "excluded"}
+	indexer := newTestIndexer(t)
+	indexer.Node = &mockNode{eventTypes: []string{"save"}}
+
+	mods, err := registrar.FilterModules(append(modules.Modules{included, excluded}, indexer.Modules...), nil, []string{"excluded"})
+	require.NoError(t, err)
+	indexer.Modules = mods
+
+	require.NoError(t, indexer.Process(1))
+	require.NoError(t, indexer.Process(2))
+	require.Equal(t, []int64{1, 2}, included.heights)
+	require.Empty(t, excluded.heights)
+}
@@ -0,0 +1,147 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/forbole/juno/v4/log"
+	parserconfig "github.com/forbole/juno/v4/parser/config"
+)
+
+const (
+	// DefaultNodeMaxAttempts is the max number of times a node query is made for a height when no other
+	// limit is configured
+	DefaultNodeMaxAttempts = 5
+
+	// DefaultNodeRetryDelay is the wait before the first retry of a node query when no other one is configured
+	DefaultNodeRetryDelay = 200 * time.Millisecond
+
+	// DefaultNodeRetryMaxDelay caps the wait between two retries of a node query when no other cap is configured
+	DefaultNodeRetryMaxDelay = 5 * time.Second
+
+	// DefaultNodeRetryBudget is the max time spent querying the node for a height when no other one is configured
+	DefaultNodeRetryBudget = 30 * time.Second
+)
+
+var (
+	// permanentNodeErrors are the messages of the errors the node returns for the heights it can't serve
+	// however many times it is asked, like the ones it has pruned or hasn't reached yet
+	permanentNodeErrors = []string{"is not available", "lowest height is", "must be less than or equal to"}
+
+	// retryableNodeErrors are the messages of the transient errors which don't wrap a typed one,
+	// the RPC client reporting most of the failures as plain strings
+	retryableNodeErrors = []string{
+		"timeout", "timed out", "429", "too many requests", "503", "service unavailable",
+		"connection reset", "connection refused", "broken pipe", "eof",
+	}
+)
+
+// isRetryableNodeError tells whether the given error, returned by a node query, is expected to go away when
+// the query is made again
+func isRetryableNodeError(err error) bool {
+	msg := strings.ToLower(err.Error())
+	for _, permanent := range permanentNodeErrors {
+		if strings.Contains(msg, permanent) {
+			return false
+		}
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	for _, retryable := range retryableNodeErrors {
+		if strings.Contains(msg, retryable) {
+			return true
+		}
+	}
+	return false
+}
+
+// nodeRetrier runs the node queries made for a single height, sharing its attempts and its budget among them
+type nodeRetrier struct {
+	metrics Metrics
+
+	height      uint64
+	maxAttempts int
+	baseDelay   time.Duration
+	maxDelay    time.Duration
+	deadline    time.Time
+
+	// attempts is the number of failed queries so far
+	attempts int
+}
+
+// newNodeRetrier returns a nodeRetrier for the given height, applying the retry policy of cfg and counting the
+// retries into metrics
+func newNodeRetrier(cfg parserconfig.Config, height uint64, metrics Metrics) *nodeRetrier {
+	r := &nodeRetrier{
+		metrics:     metricsOrDefault(metrics),
+		height:      height,
+		maxAttempts: cfg.NodeMaxAttempts,
+		baseDelay:   cfg.NodeRetryDelay,
+		maxDelay:    cfg.NodeRetryMaxDelay,
+	}
+	if r.maxAttempts <= 0 {
+		r.maxAttempts = DefaultNodeMaxAttempts
+	}
+	if r.baseDelay <= 0 {
+		r.baseDelay = DefaultNodeRetryDelay
+	}
+	if r.maxDelay <= 0 {
+		r.maxDelay = DefaultNodeRetryMaxDelay
+	}
+
+	budget := cfg.NodeRetryBudget
+	if budget <= 0 {
+		budget = DefaultNodeRetryBudget
+	}
+	r.deadline = time.Now().Add(budget)
+	return r
+}
+
+// do runs the given node query, running it again with a jittered exponential backoff while it fails with a
+// retryable error, until the attempts or the budget of the height run out
+func (r *nodeRetrier) do(name string, query func() error) error {
+	for {
+		err := query()
+		if err == nil || !isRetryableNodeError(err) {
+			return err
+		}
+
+		r.attempts++
+		if r.attempts >= r.maxAttempts {
+			return fmt.Errorf("%s failed %d times: %w", name, r.attempts, err)
+		}
+		delay := r.delay(r.attempts - 1)
+		if time.Now().Add(delay).After(r.deadline) {
+			return fmt.Errorf("%s failed, retry budget exhausted after %d attempts: %w", name, r.attempts, err)
+		}
+
+		log.Debugw("retrying node query", "query", name, "height", r.height, "attempt", r.attempts+1, "err", err)
+		r.metrics.NodeRetried(name)
+		time.Sleep(delay)
+	}
+}
+
+// delay returns the wait before the given retry, picked at random between half and the whole of its
+// exponential backoff so that the workers don't hit the node again all at once
+func (r *nodeRetrier) delay(retry int) time.Duration {
+	delay := r.maxDelay
+	if retry < 32 && r.baseDelay<<retry < r.maxDelay {
+		delay = r.baseDelay << retry
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
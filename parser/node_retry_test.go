@@ -0,0 +1,108 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	tmctypes "github.com/cometbft/cometbft/rpc/core/types"
+	"github.com/stretchr/testify/require"
+
+	"github.com/forbole/juno/v4/types/config"
+)
+
+// flakyNode fails the block queries with err until it has been asked failures times
+type flakyNode struct {
+	mockNode
+
+	err      error
+	failures int64
+	calls    atomic.Int64
+}
+
+func (n *flakyNode) Block(height int64) (*tmctypes.ResultBlock, error) {
+	if n.calls.Add(1) <= n.failures {
+		return nil, n.err
+	}
+	return n.mockNode.Block(height)
+}
+
+func TestIsRetryableNodeError(t *testing.T) {
+	for _, tc := range []struct {
+		err       error
+		retryable bool
+	}{
+		{context.DeadlineExceeded, true},
+		{fmt.Errorf("post failed: %w", syscall.ECONNRESET), true},
+		{errors.New("error in json rpc client, with http response metadata: (Status: 429 Too Many Requests)"), true},
+		{errors.New("dial tcp 127.0.0.1:26657: i/o timeout"), true},
+		{errors.New("height 12 is not available, lowest height is 1000"), false},
+		{errors.New("height 2000 must be less than or equal to the current blockchain height 1500"), false},
+		{errors.New("invalid block"), false},
+	} {
+		require.Equal(t, tc.retryable, isRetryableNodeError(tc.err), tc.err.Error())
+	}
+}
+
+func TestNodeRetry(t *testing.T) {
+	parserCfg := config.Cfg.Parser
+	config.Cfg.Parser.NodeMaxAttempts = 4
+	config.Cfg.Parser.NodeRetryDelay = time.Millisecond
+	config.Cfg.Parser.NodeRetryMaxDelay = 5 * time.Millisecond
+	t.Cleanup(func() { config.Cfg.Parser = parserCfg })
+
+	timeout := fmt.Errorf("post failed: %w", context.DeadlineExceeded)
+	pruned := errors.New("height 1 is not available, lowest height is 1000")
+
+	t.Run("transient failures are retried", func(t *testing.T) {
+		indexer := newTestIndexer(t)
+		node := &flakyNode{err: timeout, failures: 2}
+		indexer.Node = node
+
+		require.NoError(t, indexer.Process(1))
+		require.Equal(t, int64(3), node.calls.Load())
+		has, err := indexer.DB.HasBlock(context.Background(), 1)
+		require.NoError(t, err)
+		require.True(t, has)
+	})
+
+	t.Run("permanent failures are not retried", func(t *testing.T) {
+		indexer := newTestIndexer(t)
+		node := &flakyNode{err: pruned, failures: 2}
+		indexer.Node = node
+
+		require.ErrorContains(t, indexer.Process(1), "is not available")
+		require.Equal(t, int64(1), node.calls.Load())
+	})
+
+	t.Run("retries stop at the max attempts", func(t *testing.T) {
+		indexer := newTestIndexer(t)
+		node := &flakyNode{err: timeout, failures: 10}
+		indexer.Node = node
+
+		require.ErrorContains(t, indexer.Process(1), "block failed 4 times")
+		require.Equal(t, int64(4), node.calls.Load())
+	})
+
+	t.Run("retries stop once the budget is spent", func(t *testing.T) {
+		config.Cfg.Parser.NodeMaxAttempts = 100
+		config.Cfg.Parser.NodeRetryBudget = 20 * time.Millisecond
+		defer func() {
+			config.Cfg.Parser.NodeMaxAttempts = 4
+			config.Cfg.Parser.NodeRetryBudget = 0
+		}()
+
+		indexer := newTestIndexer(t)
+		node := &flakyNode{err: timeout, failures: 1000}
+		indexer.Node = node
+
+		start := time.Now()
+		require.ErrorContains(t, indexer.Process(1), "retry budget exhausted")
+		require.Less(t, time.Since(start), time.Second)
+		require.Less(t, node.calls.Load(), int64(100))
+	})
+}
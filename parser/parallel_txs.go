@@ -0,0 +1,170 @@
+package parser
+
+import (
+	"sync"
+
+	tmctypes "github.com/cometbft/cometbft/rpc/core/types"
+
+	"github.com/forbole/juno/v4/modules"
+	"github.com/forbole/juno/v4/types"
+	"github.com/forbole/juno/v4/types/config"
+)
+
+// DefaultParallelTxWorkers is the number of goroutines handling the txs of a block with parallel_txs when no
+// other number is configured
+const DefaultParallelTxWorkers = 4
+
+// resourceAttributes are the keys of the event attributes identifying the resources a tx touches. The txs whose
+// events share the value of one of them are handled one after the other, in their order.
+var resourceAttributes = map[string]bool{
+	"bucket_name":                    true,
+	"bucket_id":                      true,
+	"object_id":                      true,
+	"group_id":                       true,
+	"policy_id":                      true,
+	"resource_id":                    true,
+	"global_virtual_group_id":        true,
+	"global_virtual_group_family_id": true,
+	"account":                        true,
+}
+
+// parallelTxWorkers returns the number of goroutines handling the txs of a block, which is one unless
+// parallel_txs is enabled and no module is order sensitive.
+// With block_transaction every write of the block goes through a single database transaction, whose savepoints
+// would interleave across the goroutines, so the txs are handled serially too.
+func (i *Impl) parallelTxWorkers() int {
+	if !config.Cfg.Parser.ParallelTxs || config.Cfg.Parser.BlockTransaction {
+		return 1
+	}
+
+	for _, module := range i.Modules {
+		if orderModule, ok := module.(modules.OrderSensitiveModule); ok && orderModule.OrderSensitive() {
+			return 1
+		}
+	}
+
+	workers := config.Cfg.Parser.ParallelTxWorkers
+	if workers <= 0 {
+		workers = DefaultParallelTxWorkers
+	}
+	return workers
+}
+
+// handleTxsBatch calls the tx, message and event handlers of the modules for the given txs of a block, across
+// the goroutines of parallelTxWorkers
+func (i *Impl) handleTxsBatch(block *tmctypes.ResultBlock, txs []*types.Tx) error {
+	workers := i.parallelTxWorkers()
+	if workers <= 1 || len(txs) <= 1 {
+		if err := i.handleTxs(block, txs); err != nil {
+			return err
+		}
+		return i.ExportEventsByTxs(i.Ctx, block, txs)
+	}
+
+	// Like when serial, the tx and message handlers of every tx are called before the event handlers of any
+	groups := txGroups(txs)
+	err := runTxGroups(groups, workers, func(index int) error {
+		return i.handleTxs(block, txs[index:index+1])
+	})
+	if err != nil {
+		return err
+	}
+	return runTxGroups(groups, workers, func(index int) error {
+		return i.ExportEventsByTxs(i.Ctx, block, txs[index:index+1])
+	})
+}
+
+// txGroups splits the indexes of the given txs into the groups of txs whose events share a resource, directly
+// or through other txs. The groups are sorted by their first tx, and their txs keep their order.
+func txGroups(txs []*types.Tx) [][]int {
+	parents := make([]int, len(txs))
+	for index := range parents {
+		parents[index] = index
+	}
+	var root func(index int) int
+	root = func(index int) int {
+		if parents[index] != index {
+			parents[index] = root(parents[index])
+		}
+		return parents[index]
+	}
+
+	// Every tx joins the group of the first tx touching the same resource, the lowest index being the root
+	owners := make(map[string]int)
+	for index, tx := range txs {
+		for _, event := range tx.Events {
+			for _, attr := range event.Attributes {
+				if !resourceAttributes[attr.Key] {
+					continue
+				}
+
+				resource := attr.Key + "=" + attr.Value
+				owner, found := owners[resource]
+				if !found {
+					owners[resource] = index
+					continue
+				}
+
+				first, second := root(owner), root(index)
+				if first > second {
+					first, second = second, first
+				}
+				parents[second] = first
+			}
+		}
+	}
+
+	var groups [][]int
+	groupOf := make(map[int]int)
+	for index := range txs {
+		txRoot := root(index)
+		group, found := groupOf[txRoot]
+		if !found {
+			group = len(groups)
+			groupOf[txRoot] = group
+			groups = append(groups, nil)
+		}
+		groups[group] = append(groups[group], index)
+	}
+	return groups
+}
+
+// runTxGroups calls handle on the txs of the given groups across workers goroutines, the txs of a group one
+// after the other, a group stopping at its first failing tx. The error returned is the one of the first tx
+// failing, in the order of the txs, so that it doesn't depend on the scheduling of the goroutines.
+func runTxGroups(groups [][]int, workers int, handle func(index int) error) error {
+	txs := 0
+	for _, group := range groups {
+		txs += len(group)
+	}
+	errs := make([]error, txs)
+
+	queue := make(chan []int, len(groups))
+	for _, group := range groups {
+		queue <- group
+	}
+	close(queue)
+
+	var wg sync.WaitGroup
+	for worker := 0; worker < workers && worker < len(groups); worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for group := range queue {
+				for _, index := range group {
+					if errs[index] = handle(index); errs[index] != nil {
+						break
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	abci "github.com/cometbft/cometbft/abci/types"
+	tmctypes "github.com/cometbft/cometbft/rpc/core/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/stretchr/testify/require"
+
+	"github.com/forbole/juno/v4/common"
+	"github.com/forbole/juno/v4/modules"
+	"github.com/forbole/juno/v4/types"
+	"github.com/forbole/juno/v4/types/config"
+)
+
+// staticSource serves the same txs at every height
+type staticSource struct {
+	txs []*types.Tx
+}
+
+func (s *staticSource) Fetch(height uint64) (*tmctypes.ResultBlock, *tmctypes.ResultBlockResults, *BlockTxs, error) {
+	return newHashedTestBlock(int64(height)), &tmctypes.ResultBlockResults{Height: int64(height)}, NewBlockTxs(s.txs), nil
+}
+
+func (s *staticSource) Validators(int64) (*tmctypes.ResultValidators, error) { return nil, nil }
+
+// newAppendTx returns the tx at the given index of a block, appending suffix to the name of the given bucket
+func newAppendTx(index int, bucketID, suffix string) *types.Tx {
+	tx := newTestTx(1)
+	tx.TxHash = common.BytesToHash([]byte{byte(index + 1)}).Hex()
+	tx.Events = []abci.Event{{
+		Type:       "append",
+		Attributes: []abci.EventAttribute{{Key: "bucket_id", Value: bucketID}, {Key: "suffix", Value: suffix}},
+	}}
+	return tx
+}
+
+// appendModule appends the suffix of the "append" events to the name of their bucket, taking some time between
+// reading and writing it, and records how many events it handles at once
+type appendModule struct {
+	bucketEventModule
+
+	mu    sync.Mutex
+	names map[string]string
+
+	orderSensitive bool
+	handling       atomic.Int64
+	maxHandling    atomic.Int64
+}
+
+func (m *appendModule) Name() string { return "append" }
+
+func (m *appendModule) OrderSensitive() bool { return m.orderSensitive }
+
+func (m *appendModule) HandleEvent(_ context.Context, _ *tmctypes.ResultBlock, _ common.Hash, event sdk.Event) error {
+	handling := m.handling.Add(1)
+	defer m.handling.Add(-1)
+	for {
+		max := m.maxHandling.Load()
+		if handling <= max || m.maxHandling.CompareAndSwap(max, handling) {
+			break
+		}
+	}
+
+	m.mu.Lock()
+	name := m.names[event.Attributes[0].Value]
+	m.mu.Unlock()
+
+	time.Sleep(time.Duration(1+rand.Intn(5)) * time.Millisecond)
+
+	m.mu.Lock()
+	m.names[event.Attributes[0].Value] = name + event.Attributes[1].Value
+	m.mu.Unlock()
+	return nil
+}
+
+func TestTxGroups(t *testing.T) {
+	txs := []*types.Tx{
+		newAppendTx(0, "1", "a"), newAppendTx(1, "2", "b"), newAppendTx(2, "3", "c"),
+		newAppendTx(3, "1", "d"), newAppendTx(4, "4", "e"),
+	}
+	// The last tx touches both the buckets 2 and 4, joining their groups
+	txs = append(txs, newAppendTx(5, "2", "f"))
+	txs[5].Events[0].Attributes = append(txs[5].Events[0].Attributes, abci.EventAttribute{Key: "bucket_id", Value: "4"})
+
+	require.Equal(t, [][]int{{0, 3}, {1, 4, 5}, {2}}, txGroups(txs))
+}
+
+func TestParallelTxs(t *testing.T) {
+	parserCfg := config.Cfg.Parser
+	t.Cleanup(func() { config.Cfg.Parser = parserCfg })
+
+	var txs []*types.Tx
+	for index, bucketID := range []string{"1", "2", "1", "3", "2", "1", "4", "3", "4", "1"} {
+		txs = append(txs, newAppendTx(index, bucketID, fmt.Sprintf("%d", index)))
+	}
+
+	process := func(t *testing.T, parallel, orderSensitive bool) *appendModule {
+		config.Cfg.Parser.ParallelTxs = parallel
+		module := &appendModule{names: make(map[string]string), orderSensitive: orderSensitive}
+		indexer := newTestIndexer(t)
+		indexer.Source = &staticSource{txs: txs}
+		indexer.Modules = []modules.Module{module}
+
+		require.NoError(t, indexer.Process(1))
+		_, total, err := indexer.DB.GetTxsByHeight(indexer.Ctx, 1, 20, 0)
+		require.NoError(t, err)
+		require.Equal(t, int64(len(txs)), total)
+		return module
+	}
+
+	serial := process(t, false, false)
+	require.Equal(t, map[string]string{"1": "0259", "2": "14", "3": "37", "4": "68"}, serial.names)
+	require.Equal(t, int64(1), serial.maxHandling.Load())
+
+	t.Run("same state as serial", func(t *testing.T) {
+		parallel := process(t, true, false)
+		require.Equal(t, serial.names, parallel.names)
+		require.Greater(t, parallel.maxHandling.Load(), int64(1))
+	})
+
+	t.Run("order sensitive module", func(t *testing.T) {
+		orderSensitive := process(t, true, true)
+		require.Equal(t, serial.names, orderSensitive.names)
+		require.Equal(t, int64(1), orderSensitive.maxHandling.Load())
+	})
+
+	t.Run("block transaction", func(t *testing.T) {
+		config.Cfg.Parser.ParallelTxs = true
+		config.Cfg.Parser.BlockTransaction = true
+		require.Equal(t, 1, (&Impl{}).parallelTxWorkers())
+	})
+}
@@ -0,0 +1,176 @@
+package parser
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/forbole/juno/v4/database"
+	"github.com/forbole/juno/v4/log"
+)
+
+const (
+	// DefaultPollIntervalFloor is the min wait between two polls when no other one is configured
+	DefaultPollIntervalFloor = 200 * time.Millisecond
+
+	// DefaultPollIntervalCeiling is the max wait between two polls when no other one is configured
+	DefaultPollIntervalCeiling = 30 * time.Second
+
+	// blockTimeSmoothing is the weight of every new time between two blocks in their moving average
+	blockTimeSmoothing = 0.2
+
+	// blockTimeHaltFactor is how many times longer than the average the time between two blocks must be to be
+	// taken for a chain halt, which is left out of the average
+	blockTimeHaltFactor = 10
+
+	// pollBehindHeights is how far below the latest height of the node the epoch must be for the parser to be
+	// behind, polling again right away
+	pollBehindHeights = 20
+)
+
+// BlockTimes tracks the exponential moving average of the time between two blocks, out of the timestamps of
+// the blocks observed, telling when the next block is expected
+type BlockTimes struct {
+	mu sync.Mutex
+
+	average time.Duration
+
+	// lastHeight is the highest height observed, produced at lastTime
+	lastHeight uint64
+	lastTime   time.Time
+}
+
+// NewBlockTimes returns a BlockTimes whose average starts from the given one
+func NewBlockTimes(initial time.Duration) *BlockTimes {
+	log.AverageBlockTime.Set(initial.Seconds())
+	return &BlockTimes{average: initial}
+}
+
+// Observe records that the block at the given height was produced at timestamp. The heights below the highest
+// observed one are ignored, and the time since it is split evenly among the heights skipped.
+func (b *BlockTimes) Observe(height uint64, timestamp time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if height <= b.lastHeight {
+		return
+	}
+	if b.lastHeight > 0 {
+		elapsed := timestamp.Sub(b.lastTime) / time.Duration(height-b.lastHeight)
+		if elapsed > 0 && elapsed < blockTimeHaltFactor*b.average {
+			b.average = time.Duration(blockTimeSmoothing*float64(elapsed) + (1-blockTimeSmoothing)*float64(b.average))
+			log.AverageBlockTime.Set(b.average.Seconds())
+		}
+	}
+	b.lastHeight, b.lastTime = height, timestamp
+}
+
+// Average returns the average time between two blocks
+func (b *BlockTimes) Average() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.average
+}
+
+// NextPoll returns how long to wait from now before the block following latest, the latest height of the
+// chain, is expected, at least floor and at most ceiling. The heights between the highest observed one and latest
+// are expected to have been produced on average. Once the block is late the wait grows with the delay, so that a
+// halted chain is polled less and less.
+func (b *BlockTimes) NextPoll(now time.Time, latest uint64, floor, ceiling time.Duration) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	wait := b.average
+	if !b.lastTime.IsZero() {
+		blocks := uint64(1)
+		if latest > b.lastHeight {
+			blocks += latest - b.lastHeight
+		}
+		wait = b.lastTime.Add(time.Duration(blocks) * b.average).Sub(now)
+		if wait < 0 {
+			wait = -wait / 2
+		}
+	}
+
+	if wait < floor {
+		return floor
+	}
+	if wait > ceiling {
+		return ceiling
+	}
+	return wait
+}
+
+// AdaptivePoller tells how long to wait before polling the node for the new heights again, according to the
+// times between the stored blocks
+type AdaptivePoller struct {
+	db    database.Database
+	times *BlockTimes
+
+	floor   time.Duration
+	ceiling time.Duration
+}
+
+// NewAdaptivePoller returns an AdaptivePoller whose average block time starts from avgBlockTime, waiting at
+// least floor and at most ceiling between two polls, the defaults being used when zero
+func NewAdaptivePoller(db database.Database, avgBlockTime, floor, ceiling time.Duration) *AdaptivePoller {
+	if floor <= 0 {
+		floor = DefaultPollIntervalFloor
+	}
+	if ceiling <= 0 {
+		ceiling = DefaultPollIntervalCeiling
+	}
+	return &AdaptivePoller{
+		db:      db,
+		times:   NewBlockTimes(avgBlockTime),
+		floor:   floor,
+		ceiling: ceiling,
+	}
+}
+
+// Wait returns how long to wait before polling again, after a poll finding latest as the latest height of the
+// node, enqueued telling whether new heights were found. The parser being behind polls again right away, as
+// long as it keeps finding new heights.
+func (p *AdaptivePoller) Wait(ctx context.Context, latest uint64, enqueued bool) time.Duration {
+	lastHeight := p.observeLastBlock(ctx)
+
+	if enqueued && p.parsedHeight(ctx, lastHeight)+pollBehindHeights < latest {
+		return 0
+	}
+	return p.times.NextPoll(time.Now(), latest, p.floor, p.ceiling)
+}
+
+// parsedHeight returns the height the parser has reached, which is the one of the epoch when it is tracked, and
+// lastHeight, the height of the last stored block, otherwise
+func (p *AdaptivePoller) parsedHeight(ctx context.Context, lastHeight uint64) uint64 {
+	epoch, err := p.db.GetEpoch(ctx)
+	if err != nil {
+		log.Errorw("failed to get epoch", "err", err)
+		return lastHeight
+	}
+	// No epoch is stored unless the block transaction or the epoch window are enabled
+	if epoch.BlockHeight == 0 {
+		return lastHeight
+	}
+	return uint64(epoch.BlockHeight)
+}
+
+// observeLastBlock records the time of the last stored block into the block times, returning its height,
+// 0 when there is none or it cannot be read
+func (p *AdaptivePoller) observeLastBlock(ctx context.Context) uint64 {
+	height, err := p.db.GetLastBlockHeight(ctx)
+	if err != nil {
+		log.Errorw("failed to get last block height", "err", err)
+		return 0
+	}
+	if height == 0 {
+		return 0
+	}
+	block, err := p.db.GetBlockByHeight(ctx, height)
+	if err != nil {
+		log.Errorw("failed to get last block", "height", height, "err", err)
+		return height
+	}
+	p.times.Observe(height, time.Unix(int64(block.Timestamp), 0))
+	return height
+}
@@ -0,0 +1,76 @@
+package parser
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/forbole/juno/v4/log"
+	"github.com/forbole/juno/v4/models"
+)
+
+func TestBlockTimes(t *testing.T) {
+	floor, ceiling := 100*time.Millisecond, 30*time.Second
+	start := time.Unix(1700000000, 0)
+	at := func(d time.Duration) time.Time { return start.Add(d) }
+
+	times := NewBlockTimes(5 * time.Second)
+	require.Equal(t, 5*time.Second, times.NextPoll(start, 0, floor, ceiling))
+
+	// A block every second brings the average down to it
+	for height := uint64(1); height <= 30; height++ {
+		times.Observe(height, at(time.Duration(height)*time.Second))
+	}
+	require.InDelta(t, time.Second, times.Average(), float64(10*time.Millisecond))
+	require.InDelta(t, times.Average().Seconds(), testutil.ToFloat64(log.AverageBlockTime), 0.001)
+	last := at(30 * time.Second)
+
+	// The next block is waited for, the ones produced but not observed yet included
+	require.InDelta(t, 800*time.Millisecond, times.NextPoll(last.Add(200*time.Millisecond), 30, floor, ceiling), float64(10*time.Millisecond))
+	require.InDelta(t, 2800*time.Millisecond, times.NextPoll(last.Add(200*time.Millisecond), 32, floor, ceiling), float64(25*time.Millisecond))
+
+	// A late block is polled for sooner, never before the floor
+	require.InDelta(t, 250*time.Millisecond, times.NextPoll(last.Add(1500*time.Millisecond), 30, floor, ceiling), float64(10*time.Millisecond))
+	require.Equal(t, floor, times.NextPoll(last.Add(1100*time.Millisecond), 30, floor, ceiling))
+
+	// Without any new block for minutes, the chain is polled at the ceiling
+	require.InDelta(t, 10*time.Second, times.NextPoll(last.Add(21*time.Second), 30, floor, ceiling), float64(10*time.Millisecond))
+	require.Equal(t, ceiling, times.NextPoll(last.Add(5*time.Minute), 30, floor, ceiling))
+
+	// The halt is left out of the average once the chain resumes
+	times.Observe(31, last.Add(5*time.Minute))
+	times.Observe(32, last.Add(5*time.Minute+time.Second))
+	require.InDelta(t, time.Second, times.Average(), float64(10*time.Millisecond))
+	require.InDelta(t, 500*time.Millisecond, times.NextPoll(last.Add(5*time.Minute+1500*time.Millisecond), 32, floor, ceiling), float64(10*time.Millisecond))
+
+	// The heights observed late are ignored, and the time since the last one is split among the skipped ones
+	times.Observe(20, last.Add(6*time.Minute))
+	times.Observe(35, last.Add(5*time.Minute+7*time.Second))
+	require.InDelta(t, 1200*time.Millisecond, times.Average(), float64(10*time.Millisecond))
+}
+
+func TestAdaptivePollerBehind(t *testing.T) {
+	ctx := context.Background()
+	indexer := newTestIndexer(t)
+	poller := NewAdaptivePoller(indexer.DB, time.Second, 0, 0)
+	require.NoError(t, indexer.DB.SaveEpoch(ctx, &models.Epoch{OneRowId: true, BlockHeight: 10}))
+
+	// Far behind the chain, the node is polled again as long as new heights are found
+	require.Zero(t, poller.Wait(ctx, 100, true))
+	require.Equal(t, time.Second, poller.Wait(ctx, 100, false))
+	require.Equal(t, time.Second, poller.Wait(ctx, 25, true))
+}
+
+func TestAdaptivePollerWithoutEpoch(t *testing.T) {
+	ctx := context.Background()
+	indexer := newTestIndexer(t)
+	poller := NewAdaptivePoller(indexer.DB, time.Second, time.Millisecond, time.Millisecond)
+
+	// With no epoch stored, the parser is behind according to its last stored block
+	require.Zero(t, poller.Wait(ctx, 100, true))
+	require.NoError(t, indexer.DB.SaveBlock(ctx, models.NewBlockFromTmBlock(newHashedTestBlock(90), 0)))
+	require.Equal(t, time.Millisecond, poller.Wait(ctx, 100, true))
+}
@@ -0,0 +1,51 @@
+package parser
+
+import (
+	"context"
+	"time"
+
+	"github.com/forbole/juno/v4/log"
+	"github.com/forbole/juno/v4/types"
+)
+
+const (
+	// DefaultBackfillWorkers is the number of workers reserved to the low priority heights when no other number
+	// is configured
+	DefaultBackfillWorkers = 1
+
+	// DefaultQueueDepthInterval is how often the depths of the queues get reported
+	DefaultQueueDepthInterval = 5 * time.Second
+)
+
+// BackfillWorkers returns how many of the given number of workers get reserved to the low priority heights when
+// configured is asked for, leaving one worker at least to the high priority ones
+func BackfillWorkers(workers int64, configured int) int {
+	if configured <= 0 {
+		configured = DefaultBackfillWorkers
+	}
+	if workers <= 1 {
+		return 0
+	}
+	if int64(configured) >= workers {
+		return int(workers - 1)
+	}
+	return configured
+}
+
+// ReportQueueDepths reports every interval the number of heights waiting in the high and low priority queues,
+// until ctx is done
+func ReportQueueDepths(ctx context.Context, high, low types.HeightQueue, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		log.QueueDepth.WithLabelValues("high").Set(float64(len(high)))
+		log.QueueDepth.WithLabelValues("low").Set(float64(len(low)))
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
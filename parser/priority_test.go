@@ -0,0 +1,119 @@
+package parser
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"cosmossdk.io/simapp/params"
+	"github.com/stretchr/testify/require"
+
+	"github.com/forbole/juno/v4/types"
+)
+
+// timingIndexer records when each height got processed
+type timingIndexer struct {
+	*Impl
+
+	mu        sync.Mutex
+	processed map[uint64]time.Time
+}
+
+func (i *timingIndexer) Process(height uint64) error {
+	if err := i.Impl.Process(height); err != nil {
+		return err
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.processed[height] = time.Now()
+	return nil
+}
+
+// processedAt returns when the given height got processed, and whether it did
+func (i *timingIndexer) processedAt(height uint64) (time.Time, bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	at, ok := i.processed[height]
+	return at, ok
+}
+
+func TestTipBeatsBackfill(t *testing.T) {
+	const (
+		workers  = 4
+		backfill = 1000
+		tips     = 30
+		maxDelay = time.Second
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	base := newTestIndexer(t)
+	base.Node = &mockNode{}
+	indexer := &timingIndexer{Impl: base, processed: make(map[uint64]time.Time)}
+
+	high, low, inFlight := types.NewQueue(25), types.NewQueue(25), NewInFlightHeights()
+	parserCtx := NewContext(&params.EncodingConfig{Codec: base.codec}, base.Node, base.DB, nil, indexer)
+	var running ShutdownGroup
+	for i := 0; i < workers; i++ {
+		worker := NewWorker(parserCtx, high, i, true)
+		worker.SetIndexer(indexer)
+		worker.SetBackfill(low, inFlight)
+		if i < BackfillWorkers(workers, 0) {
+			worker.ReserveForBackfill()
+		}
+		running.Go(func() { worker.Start(ctx) })
+	}
+
+	// The missing heights keep the low priority queue full for the whole test
+	go func() {
+		for height := uint64(1); height <= backfill; height++ {
+			inFlight.Add(height)
+			select {
+			case low <- height:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	// The new heights come in a stream, each of them getting processed shortly after being enqueued
+	enqueuedAt := make(map[uint64]time.Time)
+	for height := uint64(backfill + 1); height <= backfill+tips; height++ {
+		enqueuedAt[height] = time.Now()
+		high <- height
+		time.Sleep(10 * time.Millisecond)
+	}
+	for height, enqueued := range enqueuedAt {
+		var processed time.Time
+		require.Eventually(t, func() bool {
+			var ok bool
+			processed, ok = indexer.processedAt(height)
+			return ok
+		}, 5*time.Second, time.Millisecond, "height %d", height)
+		require.Less(t, processed.Sub(enqueued), maxDelay, "height %d", height)
+	}
+
+	// Meanwhile the reserved worker kept processing the missing heights
+	_, ok := indexer.processedAt(1)
+	require.True(t, ok)
+
+	cancel()
+	require.True(t, running.Wait(5*time.Second))
+}
+
+func TestBackfillWorkers(t *testing.T) {
+	for _, tc := range []struct {
+		workers    int64
+		configured int
+		expected   int
+	}{
+		{workers: 4, configured: 0, expected: DefaultBackfillWorkers},
+		{workers: 4, configured: 2, expected: 2},
+		{workers: 4, configured: 10, expected: 3},
+		{workers: 1, configured: 1, expected: 0},
+	} {
+		require.Equal(t, tc.expected, BackfillWorkers(tc.workers, tc.configured), "%d workers, %d configured", tc.workers, tc.configured)
+	}
+}
@@ -0,0 +1,163 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	tmctypes "github.com/cometbft/cometbft/rpc/core/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+
+	"github.com/forbole/juno/v4/common"
+	"github.com/forbole/juno/v4/database"
+	"github.com/forbole/juno/v4/log"
+	"github.com/forbole/juno/v4/models"
+	"github.com/forbole/juno/v4/modules"
+	"github.com/forbole/juno/v4/types"
+	"github.com/forbole/juno/v4/types/config"
+)
+
+// blockProgress tracks how far the txs and events of the block being exported have been handled, recording it
+// in the database as they get handled so that exporting the block again resumes after them.
+// A nil blockProgress tracks nothing, the txs and events being all handled.
+type blockProgress struct {
+	indexer  *Impl
+	progress *models.BlockProgress
+
+	// events is the number of tx events of the block gone through so far
+	events int
+}
+
+// blockProgressEnabled tells whether the progress of the blocks gets recorded, which is useless when a block is
+// stored inside a single transaction, nothing of it being left behind by a crash
+func blockProgressEnabled() bool {
+	return config.Cfg.Parser.BlockProgress && !config.Cfg.Parser.BlockTransaction
+}
+
+// blockStored tells whether the given height is stored entirely. The block row being saved before its txs and
+// events get handled, a height whose progress is still recorded was left unfinished by a crash.
+func blockStored(ctx context.Context, db database.Database, height uint64) (bool, error) {
+	has, err := db.HasBlock(ctx, height)
+	if err != nil || !has || !blockProgressEnabled() {
+		return has, err
+	}
+
+	_, err = db.GetBlockProgress(ctx, height)
+	if errors.Is(err, database.ErrBlockProgressNotFound) {
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get progress of block %d: %s", height, err)
+	}
+	return false, nil
+}
+
+// loadBlockProgress returns the progress of the given block, starting from the one recorded by a previous
+// export of it, if any. It is nil when the progress of the blocks isn't recorded.
+func (i *Impl) loadBlockProgress(block *tmctypes.ResultBlock) (*blockProgress, error) {
+	if !blockProgressEnabled() {
+		return nil, nil
+	}
+
+	hash := common.BytesToHash(block.BlockID.Hash)
+	progress, err := i.DB.GetBlockProgress(i.Ctx, uint64(block.Block.Height))
+	if err != nil && !errors.Is(err, database.ErrBlockProgressNotFound) {
+		return nil, fmt.Errorf("failed to get progress of block %d: %s", block.Block.Height, err)
+	}
+
+	// The progress of another block at the same height, replaced by a reorganization, doesn't apply
+	if err != nil || progress.BlockHash != hash {
+		progress = &models.BlockProgress{Height: uint64(block.Block.Height), BlockHash: hash, TxIndex: -1, EventIndex: -1}
+	} else {
+		log.Infow("resuming block export", "height", block.Block.Height, "tx index", progress.TxIndex,
+			"event index", progress.EventIndex)
+	}
+	return &blockProgress{indexer: i, progress: progress}, nil
+}
+
+// txHandled tells whether the handlers of the tx at the given index of the block have been called already
+func (p *blockProgress) txHandled(index int) bool {
+	return p != nil && index <= p.progress.TxIndex
+}
+
+// eventHandled tells whether the event at the given index, among the tx events of the block, has been handled
+// already
+func (p *blockProgress) eventHandled(index int) bool {
+	return p != nil && index <= p.progress.EventIndex
+}
+
+// nextEvent returns the index of the next tx event of the block gone through
+func (p *blockProgress) nextEvent() int {
+	p.events++
+	return p.events - 1
+}
+
+// saveTx records that the handlers of the tx at the given index of the block have been called
+func (p *blockProgress) saveTx(index int) error {
+	p.progress.TxIndex = index
+	return p.save()
+}
+
+// saveEvent records that the tx event at the given index of the block has been handled
+func (p *blockProgress) saveEvent(index int) error {
+	p.progress.EventIndex = index
+	return p.save()
+}
+
+// save stores the progress of the block
+func (p *blockProgress) save() error {
+	p.progress.UpdateTime = time.Now().Unix()
+	err := p.indexer.DB.SaveBlockProgress(p.indexer.Ctx, p.progress)
+	if err != nil {
+		return fmt.Errorf("failed to save progress of block %d: %s", p.progress.Height, err)
+	}
+	return nil
+}
+
+// clear deletes the progress of the block, once every tx and event of it has been handled
+func (p *blockProgress) clear() error {
+	if p == nil {
+		return nil
+	}
+
+	err := p.indexer.DB.DeleteBlockProgress(p.indexer.Ctx, p.progress.Height)
+	if err != nil {
+		return fmt.Errorf("failed to delete progress of block %d: %s", p.progress.Height, err)
+	}
+	return nil
+}
+
+// handleTxsWithProgress calls the tx, message and event handlers of the modules for the given txs of block,
+// whose first one is at index first in the block, one after the other. The txs and events handled by a previous
+// export of the block are skipped, and every one handled gets recorded.
+func (i *Impl) handleTxsWithProgress(block *tmctypes.ResultBlock, txs []*types.Tx, first int, progress *blockProgress) error {
+	for offset := range txs {
+		if progress.txHandled(first + offset) {
+			continue
+		}
+		if err := i.handleTxs(block, txs[offset:offset+1]); err != nil {
+			return err
+		}
+		if err := progress.saveTx(first + offset); err != nil {
+			return err
+		}
+	}
+
+	for _, tx := range txs {
+		txHash := common.HexToHash(tx.TxHash)
+		for txEventIndex, event := range tx.Events {
+			index := progress.nextEvent()
+			if progress.eventHandled(index) {
+				continue
+			}
+			if err := i.HandleEvent(modules.WithEventIndex(i.Ctx, txEventIndex), block, txHash, sdk.Event(event)); err != nil {
+				return err
+			}
+			if err := progress.saveEvent(index); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"cosmossdk.io/simapp/params"
+	tmctypes "github.com/cometbft/cometbft/rpc/core/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm/schema"
+
+	"github.com/forbole/juno/v4/common"
+	"github.com/forbole/juno/v4/database"
+	"github.com/forbole/juno/v4/models"
+	"github.com/forbole/juno/v4/modules"
+	"github.com/forbole/juno/v4/types"
+	"github.com/forbole/juno/v4/types/config"
+)
+
+var errCrashed = errors.New("crashed")
+
+// crashingModule records the txs and events it handles, failing once it has handled crashAfter events
+type crashingModule struct {
+	bucketEventModule
+
+	crashAfter int
+	txs        []string
+	events     []string
+}
+
+func (m *crashingModule) Name() string { return "crashing" }
+
+func (m *crashingModule) HandleTx(tx *types.Tx) error {
+	m.txs = append(m.txs, tx.TxHash)
+	return nil
+}
+
+func (m *crashingModule) HandleEvent(_ context.Context, _ *tmctypes.ResultBlock, txHash common.Hash, event sdk.Event) error {
+	if m.crashAfter > 0 && len(m.events) == m.crashAfter {
+		m.crashAfter = 0
+		return errCrashed
+	}
+	m.events = append(m.events, fmt.Sprintf("%s/%s", txHash.Hex()[64:], event.Attributes[0].Value))
+	return nil
+}
+
+func TestBlockProgress(t *testing.T) {
+	parserCfg := config.Cfg.Parser
+	config.Cfg.Parser.BlockProgress = true
+	t.Cleanup(func() { config.Cfg.Parser = parserCfg })
+
+	var txs []*types.Tx
+	var events []string
+	for index := 0; index < 3; index++ {
+		tx := newTestTx(1, "a", "b", "c", "d")
+		tx.TxHash = common.BytesToHash([]byte{byte(index + 1)}).Hex()
+		txs = append(txs, tx)
+		for _, event := range tx.Events {
+			events = append(events, fmt.Sprintf("%02d/%s", index+1, event.Attributes[0].Value))
+		}
+	}
+
+	const crashAfter = 6
+	indexer := newTestIndexer(t)
+	require.NoError(t, indexer.DB.PrepareTables(context.Background(), []schema.Tabler{&models.BlockProgress{}}))
+	module := &crashingModule{crashAfter: crashAfter}
+	indexer.Source = &staticSource{txs: txs}
+	indexer.Modules = []modules.Module{module}
+
+	// The crash leaves the position of the last event handled behind
+	require.ErrorIs(t, indexer.Process(1), errCrashed)
+	require.Equal(t, events[:crashAfter], module.events)
+	progress, err := indexer.DB.GetBlockProgress(indexer.Ctx, 1)
+	require.NoError(t, err)
+	require.Equal(t, 2, progress.TxIndex)
+	require.Equal(t, crashAfter-1, progress.EventIndex)
+
+	// Processing the block again resumes right after it, every tx and event being handled once
+	require.NoError(t, indexer.Process(1))
+	require.Equal(t, events, module.events)
+	require.Len(t, module.txs, len(txs))
+	_, err = indexer.DB.GetBlockProgress(indexer.Ctx, 1)
+	require.ErrorIs(t, err, database.ErrBlockProgressNotFound)
+}
+
+func TestBlockProgressResumedByWorker(t *testing.T) {
+	parserCfg := config.Cfg.Parser
+	config.Cfg.Parser.BlockProgress = true
+	t.Cleanup(func() { config.Cfg.Parser = parserCfg })
+
+	tx := newTestTx(1, "a", "b", "c")
+	tx.TxHash = common.BytesToHash([]byte{1}).Hex()
+
+	indexer := newTestIndexer(t)
+	require.NoError(t, indexer.DB.PrepareTables(context.Background(), []schema.Tabler{&models.BlockProgress{}}))
+	module := &crashingModule{crashAfter: 1}
+	indexer.Source = &staticSource{txs: []*types.Tx{tx}}
+	indexer.Modules = []modules.Module{module}
+	worker := NewWorker(NewContext(&params.EncodingConfig{Codec: indexer.codec}, indexer.Node, indexer.DB, nil, indexer), types.NewQueue(1), 0, false)
+	worker.SetIndexer(indexer)
+
+	// The block row is stored before the crash, which leaves the height unprocessed
+	require.ErrorIs(t, worker.ProcessIfNotExists(1), errCrashed)
+	has, err := indexer.DB.HasBlock(indexer.Ctx, 1)
+	require.NoError(t, err)
+	require.True(t, has)
+	processed, err := indexer.Processed(indexer.Ctx, 1)
+	require.NoError(t, err)
+	require.False(t, processed)
+
+	// Processing it again resumes the export rather than skipping the height
+	require.NoError(t, worker.ProcessIfNotExists(1))
+	require.Len(t, module.events, 3)
+	processed, err = indexer.Processed(indexer.Ctx, 1)
+	require.NoError(t, err)
+	require.True(t, processed)
+}
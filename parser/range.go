@@ -0,0 +1,102 @@
+package parser
+
+import (
+	"fmt"
+	"sync"
+)
+
+// RangeSummary counts the heights of a fixed range parsed in one-shot mode, telling once every one of them
+// has either been processed, failed, or been skipped
+type RangeSummary struct {
+	mu        sync.Mutex
+	total     uint64
+	processed uint64
+	skipped   uint64
+	failed    []uint64
+
+	// done is closed once every height of the range is accounted for
+	done chan struct{}
+	// failure is closed on the first failed height
+	failure chan struct{}
+}
+
+// NewRangeSummary returns a RangeSummary for a range of total heights
+func NewRangeSummary(total uint64) *RangeSummary {
+	s := &RangeSummary{
+		total:   total,
+		done:    make(chan struct{}),
+		failure: make(chan struct{}),
+	}
+	if total == 0 {
+		close(s.done)
+	}
+	return s
+}
+
+// Succeed records the given height as processed
+func (s *RangeSummary) Succeed(height uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.processed++
+	s.checkDone()
+}
+
+// Fail records the given height as failed for good
+func (s *RangeSummary) Fail(height uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.failed) == 0 {
+		close(s.failure)
+	}
+	s.failed = append(s.failed, height)
+	s.checkDone()
+}
+
+// Skip records that count heights of the range won't be enqueued
+func (s *RangeSummary) Skip(count uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.skipped += count
+	s.checkDone()
+}
+
+// checkDone closes done once every height is accounted for. It must be called with mu held.
+func (s *RangeSummary) checkDone() {
+	if s.processed+uint64(len(s.failed))+s.skipped == s.total {
+		close(s.done)
+	}
+}
+
+// Done returns a channel closed once every height of the range has been processed, failed, or skipped
+func (s *RangeSummary) Done() <-chan struct{} {
+	return s.done
+}
+
+// Failure returns a channel closed once a height has failed
+func (s *RangeSummary) Failure() <-chan struct{} {
+	return s.failure
+}
+
+// Counts returns the number of heights processed, failed and skipped so far
+func (s *RangeSummary) Counts() (processed, failed, skipped uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.processed, uint64(len(s.failed)), s.skipped
+}
+
+// Err returns an error telling the failed heights, if any, and the number of skipped ones
+func (s *RangeSummary) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case len(s.failed) > 0:
+		return fmt.Errorf("%d heights failed, the first one being %d, and %d got skipped", len(s.failed), s.failed[0], s.skipped)
+	case s.skipped > 0:
+		return fmt.Errorf("%d heights got skipped", s.skipped)
+	}
+	return nil
+}
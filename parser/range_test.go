@@ -0,0 +1,89 @@
+package parser
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cosmossdk.io/simapp/params"
+	"github.com/stretchr/testify/require"
+
+	"github.com/forbole/juno/v4/types"
+)
+
+func TestRangeSummary(t *testing.T) {
+	const last = 10
+
+	for _, tc := range []struct {
+		name    string
+		failing uint64
+		err     string
+	}{
+		{name: "every height succeeds"},
+		{name: "a height fails", failing: 5, err: "1 heights failed, the first one being 5"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			indexer := newTestIndexer(t)
+			node := &mockNode{failing: tc.failing}
+			indexer.Node = node
+			summary := NewRangeSummary(last)
+
+			queue := types.NewQueue(25)
+			parserCtx := NewContext(&params.EncodingConfig{Codec: indexer.codec}, node, indexer.DB, nil, indexer)
+			for i := 0; i < 3; i++ {
+				worker := NewWorker(parserCtx, queue, i, false)
+				worker.SetIndexer(indexer)
+				worker.SetRangeSummary(summary)
+				go worker.Start(ctx)
+			}
+			for height := uint64(1); height <= last; height++ {
+				queue <- height
+			}
+
+			select {
+			case <-summary.Done():
+			case <-time.After(10 * time.Second):
+				t.Fatal("range not done")
+			}
+
+			processed, failed, skipped := summary.Counts()
+			require.Zero(t, skipped)
+			if tc.err == "" {
+				require.Equal(t, uint64(last), processed)
+				require.Zero(t, failed)
+				require.NoError(t, summary.Err())
+				return
+			}
+
+			// The failed height is given up on instead of being retried forever
+			require.Equal(t, uint64(last-1), processed)
+			require.Equal(t, uint64(1), failed)
+			require.EqualError(t, summary.Err(), tc.err+", and 0 got skipped")
+			select {
+			case <-summary.Failure():
+			default:
+				t.Fatal("failure not reported")
+			}
+			has, err := indexer.DB.HasBlock(ctx, tc.failing)
+			require.NoError(t, err)
+			require.False(t, has)
+		})
+	}
+}
+
+func TestRangeSummarySkip(t *testing.T) {
+	select {
+	case <-NewRangeSummary(0).Done():
+	default:
+		t.Fatal("empty range not done")
+	}
+
+	summary := NewRangeSummary(3)
+	summary.Succeed(1)
+	summary.Skip(2)
+	<-summary.Done()
+	require.EqualError(t, summary.Err(), "2 heights got skipped")
+}
@@ -0,0 +1,109 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+
+	tmctypes "github.com/cometbft/cometbft/rpc/core/types"
+
+	"github.com/forbole/juno/v4/common"
+	"github.com/forbole/juno/v4/database"
+	"github.com/forbole/juno/v4/log"
+	"github.com/forbole/juno/v4/types/config"
+)
+
+// checkReorg compares the parent hash of the given block with the hash of the block stored at the height before
+// it. On mismatch the chain the node follows has been reorganized: the fork point is looked for at most maxDepth
+// heights below, everything stored after it gets deleted, and the heights between it and the block are parsed
+// again. An error is returned if the fork point is deeper than that.
+func (i *Impl) checkReorg(block *tmctypes.ResultBlock, maxDepth uint64) error {
+	height := uint64(block.Block.Height)
+	if height <= 1 {
+		return nil
+	}
+
+	orphaned, err := i.parentOrphaned(block)
+	if err != nil || !orphaned {
+		return err
+	}
+
+	// A single rollback at a time, the workers finding the same fork checking again once the first one is done
+	i.reorgMu.Lock()
+	defer i.reorgMu.Unlock()
+	orphaned, err = i.parentOrphaned(block)
+	if err != nil || !orphaned {
+		return err
+	}
+
+	forkHeight, err := i.findForkPoint(height-1, maxDepth)
+	if err != nil {
+		return err
+	}
+
+	log.Warnw("chain reorganization detected, rolling back", "height", height, "fork height", forkHeight)
+	if err = i.DB.DeleteDataAfterHeight(i.Ctx, forkHeight); err != nil {
+		return fmt.Errorf("failed to delete data after fork height %d: %s", forkHeight, err)
+	}
+
+	for h := forkHeight + 1; h < height; h++ {
+		if err = i.reprocess(h); err != nil {
+			return fmt.Errorf("failed to process height %d again after rollback: %s", h, err)
+		}
+	}
+	return nil
+}
+
+// parentOrphaned tells whether the block stored at the height before the given block is not its parent.
+// It returns false when no block is stored there, like when the heights are processed concurrently.
+func (i *Impl) parentOrphaned(block *tmctypes.ResultBlock) (bool, error) {
+	parent, err := i.DB.GetBlockByHeight(i.Ctx, uint64(block.Block.Height)-1)
+	if errors.Is(err, database.ErrBlockNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get parent block: %s", err)
+	}
+	return parent.Hash != common.HexToHash(block.Block.LastBlockID.Hash.String()), nil
+}
+
+// findForkPoint walks back from the given height, whose stored block is known to be orphaned, and returns the
+// highest height whose stored block is the one of the node. An error is returned if the fork point is more than
+// maxDepth heights below the given one.
+func (i *Impl) findForkPoint(orphaned, maxDepth uint64) (uint64, error) {
+	for h := orphaned; h > 0 && orphaned-h < maxDepth; h-- {
+		stored, err := i.DB.GetBlockByHeight(i.Ctx, h-1)
+		if errors.Is(err, database.ErrBlockNotFound) {
+			continue
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to get block: %s", err)
+		}
+
+		var block *tmctypes.ResultBlock
+		err = newNodeRetrier(config.Cfg.Parser, h-1, i.metrics()).do("block", func() (err error) {
+			block, err = i.Node.Block(int64(h - 1))
+			return err
+		})
+		if err != nil {
+			return 0, fmt.Errorf("failed to get block from node: %s", err)
+		}
+		if stored.Hash == common.HexToHash(block.Block.Hash().String()) {
+			return h - 1, nil
+		}
+	}
+
+	if orphaned <= maxDepth {
+		return 0, nil
+	}
+	return 0, fmt.Errorf("no fork point found within %d heights below height %d, the rollback would be too deep: "+
+		"check the node, or increase the max reorg depth", maxDepth, orphaned+1)
+}
+
+// reprocess parses again the given height, rolled back after a reorganization
+func (i *Impl) reprocess(height uint64) error {
+	block, blockResults, txs, err := i.fetchBlock(height)
+	if err != nil {
+		return err
+	}
+	return i.export(block, blockResults, txs)
+}
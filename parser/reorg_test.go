@@ -0,0 +1,109 @@
+package parser
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	tmctypes "github.com/cometbft/cometbft/rpc/core/types"
+	tmtypes "github.com/cometbft/cometbft/types"
+	"github.com/stretchr/testify/require"
+
+	"github.com/forbole/juno/v4/common"
+	"github.com/forbole/juno/v4/database"
+	"github.com/forbole/juno/v4/models"
+	"github.com/forbole/juno/v4/types/config"
+)
+
+// reorgNode serves a chain whose blocks after forkHeight get replaced once it is reorganized
+type reorgNode struct {
+	mockNode
+
+	forkHeight int64
+	reorged    atomic.Bool
+}
+
+func (n *reorgNode) Block(height int64) (*tmctypes.ResultBlock, error) {
+	return n.block(height), nil
+}
+
+// block returns the block at the given height, whose parent is the block at the height before
+func (n *reorgNode) block(height int64) *tmctypes.ResultBlock {
+	block := newHashedTestBlock(height)
+	if n.reorged.Load() && height > n.forkHeight {
+		block.Block.AppHash = common.HexToHash("0x0b").Bytes()
+	}
+	if height > 1 {
+		block.Block.LastBlockID = tmtypes.BlockID{Hash: n.block(height - 1).Block.Hash()}
+	}
+	return block
+}
+
+// blockHash returns the hash of the block the node serves at the given height
+func (n *reorgNode) blockHash(height int64) common.Hash {
+	return common.HexToHash(n.block(height).Block.Hash().String())
+}
+
+func TestReorg(t *testing.T) {
+	parserCfg := config.Cfg.Parser
+	t.Cleanup(func() { config.Cfg.Parser = parserCfg })
+
+	for _, tc := range []struct {
+		name     string
+		maxDepth uint64
+		err      string
+	}{
+		{name: "rollback within max depth", maxDepth: 3},
+		{name: "rollback deeper than max depth", maxDepth: 1, err: "rollback would be too deep"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			config.Cfg.Parser.ReorgMaxDepth = tc.maxDepth
+			ctx := context.Background()
+			indexer := newTestIndexer(t)
+			node := &reorgNode{forkHeight: 3}
+			indexer.Node = node
+
+			for height := uint64(1); height <= 5; height++ {
+				require.NoError(t, indexer.Process(height))
+			}
+			require.NoError(t, indexer.DB.SaveBucket(ctx, &models.Bucket{
+				BucketID: common.HexToHash("0x01"), BucketName: "before", UpdateAt: 3,
+			}))
+			require.NoError(t, indexer.DB.SaveBucket(ctx, &models.Bucket{
+				BucketID: common.HexToHash("0x02"), BucketName: "orphaned", UpdateAt: 5,
+			}))
+			orphaned := map[int64]common.Hash{4: node.blockHash(4), 5: node.blockHash(5)}
+
+			// The node drops the blocks 4 and 5 for other ones, before serving block 6
+			node.reorged.Store(true)
+			err := indexer.Process(6)
+
+			if tc.err != "" {
+				require.ErrorContains(t, err, tc.err)
+				for height, hash := range orphaned {
+					block, err := indexer.DB.GetBlockByHeight(ctx, uint64(height))
+					require.NoError(t, err)
+					require.Equal(t, hash, block.Hash)
+				}
+				has, err := indexer.DB.HasBlock(ctx, 6)
+				require.NoError(t, err)
+				require.False(t, has)
+				return
+			}
+
+			require.NoError(t, err)
+			for height := int64(1); height <= 6; height++ {
+				block, err := indexer.DB.GetBlockByHeight(ctx, uint64(height))
+				require.NoError(t, err)
+				require.Equal(t, node.blockHash(height), block.Hash, "height %d", height)
+			}
+			require.NotEqual(t, orphaned[4], node.blockHash(4))
+
+			// The module rows written after the fork point are gone
+			_, err = indexer.DB.GetBucketByName(ctx, "before")
+			require.NoError(t, err)
+			_, err = indexer.DB.GetBucketByName(ctx, "orphaned")
+			require.ErrorIs(t, err, database.ErrBucketNotFound)
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+
+	tmctypes "github.com/cometbft/cometbft/rpc/core/types"
+
+	"github.com/forbole/juno/v4/database"
+	"github.com/forbole/juno/v4/log"
+	"github.com/forbole/juno/v4/modules"
+	"github.com/forbole/juno/v4/types"
+)
+
+// DefaultReparseProgressInterval is the number of heights after which the reparse progress gets logged
+// when no other interval is configured
+const DefaultReparseProgressInterval = 1000
+
+// ReparseConfig tells which heights get reparsed, and by which modules
+type ReparseConfig struct {
+	StartHeight uint64
+	EndHeight   uint64
+
+	// Modules are the names of the modules whose handlers get called again, all of them when empty
+	Modules []string
+
+	// AdvanceEpoch makes the epoch move to the end height once reparsed, if it is behind it.
+	// The epoch is left untouched otherwise.
+	AdvanceEpoch bool
+
+	// ProgressInterval is the number of heights after which the progress gets logged
+	ProgressInterval uint64
+
+	// Replay makes the heights get rebuilt out of the stored blocks, txs and block results instead of being
+	// fetched from the node, which is not queried at all
+	Replay bool
+}
+
+// Reparser fetches again the blocks of a range of heights to call the handlers of the selected modules on them.
+// Nothing but what the modules write gets stored, and the rows the modules wrote the first time are overwritten,
+// so that a fixed handler can be run again over the past heights without syncing the whole database again.
+type Reparser struct {
+	indexer *Impl
+	cfg     ReparseConfig
+}
+
+// NewReparser returns a Reparser running the modules of the given context selected by cfg.
+// An error is returned if the height range is empty, or if a selected module is not registered.
+func NewReparser(ctx *Context, cfg ReparseConfig) (*Reparser, error) {
+	if cfg.StartHeight == 0 || cfg.StartHeight > cfg.EndHeight {
+		return nil, fmt.Errorf("invalid height range from %d to %d", cfg.StartHeight, cfg.EndHeight)
+	}
+	if cfg.ProgressInterval == 0 {
+		cfg.ProgressInterval = DefaultReparseProgressInterval
+	}
+
+	selected, err := selectModules(ctx.Modules, cfg.Modules)
+	if err != nil {
+		return nil, err
+	}
+
+	indexer := &Impl{
+		Ctx:     database.ReadFromPrimary(context.TODO()),
+		codec:   ctx.EncodingConfig.Codec,
+		Node:    ctx.Node,
+		DB:      ctx.Database,
+		Modules: selected,
+
+		moduleErrors: newModuleErrorCounter(),
+	}
+	if cfg.Replay {
+		indexer.Source = NewDatabaseSource(indexer.Ctx, ctx.Database, ctx.EncodingConfig.Codec)
+	}
+
+	return &Reparser{
+		indexer: indexer,
+		cfg:     cfg,
+	}, nil
+}
+
+// selectModules returns the modules having the given names, all of them when no name is given
+func selectModules(registered []modules.Module, names []string) ([]modules.Module, error) {
+	if len(names) == 0 {
+		return registered, nil
+	}
+
+	var selected []modules.Module
+	for _, name := range names {
+		module, found := modules.Modules(registered).FindByName(name)
+		if !found {
+			return nil, fmt.Errorf("module %s is not registered", name)
+		}
+		selected = append(selected, module)
+	}
+	return selected, nil
+}
+
+// Run reparses every height of the range in order. Once ctx is done, the height being reparsed is completed
+// and the ones after it are left, an error telling the last reparsed height being returned.
+// An error is returned as well if the node doesn't have some of the heights, or if reparsing one fails.
+// When replaying, the heights whose results were not stored fail instead.
+func (r *Reparser) Run(ctx context.Context) error {
+	if !r.cfg.Replay {
+		if err := r.checkNodeRange(); err != nil {
+			return err
+		}
+	}
+
+	total := r.cfg.EndHeight - r.cfg.StartHeight + 1
+	log.Infow("reparsing blocks", "start height", r.cfg.StartHeight, "end height", r.cfg.EndHeight,
+		"modules", len(r.indexer.Modules), "replay", r.cfg.Replay)
+
+	var block *tmctypes.ResultBlock
+	var err error
+	for height := r.cfg.StartHeight; height <= r.cfg.EndHeight; height++ {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("reparse stopped after height %d: %w", height-1, ctx.Err())
+		default:
+		}
+
+		block, err = r.reparse(height)
+		if err != nil {
+			return fmt.Errorf("failed to reparse height %d: %s", height, err)
+		}
+
+		if done := height - r.cfg.StartHeight + 1; done%r.cfg.ProgressInterval == 0 || done == total {
+			log.Infow("reparse progress", "height", height, "done", done, "total", total)
+		}
+	}
+
+	// The errors of the modules don't stop the reparse, but the data they left out must not go unnoticed,
+	// nor the epoch be advanced over it
+	if err := r.indexer.moduleErrors.summary(); err != nil {
+		return fmt.Errorf("reparse of heights %d to %d incomplete: %w", r.cfg.StartHeight, r.cfg.EndHeight, err)
+	}
+
+	if r.cfg.AdvanceEpoch {
+		return r.advanceEpoch(block)
+	}
+	return nil
+}
+
+// checkNodeRange makes sure the node has every height of the range
+func (r *Reparser) checkNodeRange() error {
+	earliest, err := r.indexer.Node.EarliestHeight()
+	if err != nil {
+		return fmt.Errorf("failed to get earliest height from node: %s", err)
+	}
+	if r.cfg.StartHeight < uint64(earliest) {
+		return fmt.Errorf("the node has pruned the heights before %d, so the reparse can't start at height %d: "+
+			"use an archive node or a later start height", earliest, r.cfg.StartHeight)
+	}
+
+	latest, err := r.indexer.Node.LatestHeight()
+	if err != nil {
+		return fmt.Errorf("failed to get latest height from node: %s", err)
+	}
+	if r.cfg.EndHeight > uint64(latest) {
+		return fmt.Errorf("the reparse can't end at height %d, above the latest height %d of the node", r.cfg.EndHeight, latest)
+	}
+	return nil
+}
+
+// reparse fetches the block at the given height and calls the handlers of the selected modules on it
+func (r *Reparser) reparse(height uint64) (*tmctypes.ResultBlock, error) {
+	block, blockResults, txs, err := r.indexer.fetchBlock(height)
+	if err != nil {
+		return nil, err
+	}
+
+	blockTxs, err := r.indexer.blockModulesTxs(txs)
+	if err != nil {
+		return nil, err
+	}
+	r.indexer.HandleBlock(r.indexer.Ctx, block, blockResults, blockTxs, r.indexer.source().Validators)
+
+	return block, txs.Batches(TxBatchSize, func(_ int, batch []*types.Tx) error {
+		return r.indexer.handleTxsBatch(block, batch)
+	})
+}
+
+// advanceEpoch moves the epoch to the given block, unless it is already past it
+func (r *Reparser) advanceEpoch(block *tmctypes.ResultBlock) error {
+	epoch, err := r.indexer.DB.GetEpoch(database.ReadFromPrimary(r.indexer.Ctx))
+	if err != nil {
+		return fmt.Errorf("failed to get epoch: %s", err)
+	}
+	if epoch.BlockHeight >= block.Block.Height {
+		return nil
+	}
+	return r.indexer.ExportEpoch(block)
+}
@@ -0,0 +1,141 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"cosmossdk.io/simapp/params"
+	tmctypes "github.com/cometbft/cometbft/rpc/core/types"
+	"github.com/stretchr/testify/require"
+
+	"github.com/forbole/juno/v4/common"
+	"github.com/forbole/juno/v4/models"
+	"github.com/forbole/juno/v4/modules"
+	"github.com/forbole/juno/v4/types"
+)
+
+// heightsModule records the heights of the blocks it handles, calling onBlock on each of them and returning err
+type heightsModule struct {
+	name    string
+	heights []int64
+	onBlock func(height int64)
+	err     error
+}
+
+func (m *heightsModule) Name() string { return m.name }
+
+func (m *heightsModule) HandleBlock(_ context.Context, block *tmctypes.ResultBlock, _ *tmctypes.ResultBlockResults, _ []*types.Tx, _ modules.GetTmcValidators) error {
+	m.heights = append(m.heights, block.Block.Height)
+	if m.onBlock != nil {
+		m.onBlock(block.Block.Height)
+	}
+	return m.err
+}
+
+func newTestReparser(t *testing.T, node *mockNode, cfg ReparseConfig, mods ...modules.Module) (*Reparser, *Impl) {
+	t.Helper()
+
+	indexer := newTestIndexer(t)
+	mods = append(mods, indexer.Modules...)
+	reparser, err := NewReparser(NewContext(&params.EncodingConfig{Codec: indexer.codec}, node, indexer.DB, mods, nil), cfg)
+	require.NoError(t, err)
+	return reparser, indexer
+}
+
+func TestReparse(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("selected modules", func(t *testing.T) {
+		selected, other := &heightsModule{name: "selected"}, &heightsModule{name: "other"}
+		node := &mockNode{earliest: 1, latest: 10, eventTypes: []string{"save"}}
+		reparser, indexer := newTestReparser(t, node, ReparseConfig{
+			StartHeight: 3, EndHeight: 5, Modules: []string{"selected", "bucket_event"}, ProgressInterval: 2,
+		}, selected, other)
+		require.NoError(t, indexer.DB.SaveEpoch(ctx, &models.Epoch{OneRowId: true, BlockHeight: 4}))
+
+		require.NoError(t, reparser.Run(ctx))
+		require.Equal(t, []int64{3, 4, 5}, selected.heights)
+		require.Empty(t, other.heights)
+
+		// The events got handled again, overwriting the same bucket
+		bucket, err := indexer.DB.GetBucketByID(ctx, common.HexToHash("0x01"))
+		require.NoError(t, err)
+		require.Equal(t, "0x01", bucket.BucketName)
+
+		// Neither the blocks nor the epoch are touched
+		has, err := indexer.DB.HasBlock(ctx, 3)
+		require.NoError(t, err)
+		require.False(t, has)
+		epoch, err := indexer.DB.GetEpoch(ctx)
+		require.NoError(t, err)
+		require.Equal(t, int64(4), epoch.BlockHeight)
+	})
+
+	t.Run("advance epoch", func(t *testing.T) {
+		node := &mockNode{earliest: 1, latest: 10}
+		reparser, indexer := newTestReparser(t, node, ReparseConfig{StartHeight: 1, EndHeight: 6, AdvanceEpoch: true})
+		require.NoError(t, reparser.Run(ctx))
+
+		epoch, err := indexer.DB.GetEpoch(ctx)
+		require.NoError(t, err)
+		require.Equal(t, int64(6), epoch.BlockHeight)
+		require.Equal(t, common.BytesToHash(newHashedTestBlock(6).BlockID.Hash), epoch.BlockHash)
+	})
+
+	t.Run("module errors", func(t *testing.T) {
+		// The failing module doesn't stop the reparse, but it fails once done, without advancing the epoch
+		module := &heightsModule{name: "failing", err: errors.New("failed")}
+		reparser, indexer := newTestReparser(t, &mockNode{earliest: 1, latest: 10}, ReparseConfig{
+			StartHeight: 2, EndHeight: 4, AdvanceEpoch: true,
+		}, module)
+		require.NoError(t, indexer.DB.SaveEpoch(ctx, &models.Epoch{OneRowId: true, BlockHeight: 1}))
+
+		err := reparser.Run(ctx)
+		require.ErrorContains(t, err, "reparse of heights 2 to 4 incomplete")
+		require.ErrorContains(t, err, "failing: 3")
+		require.Equal(t, []int64{2, 3, 4}, module.heights)
+		epoch, err := indexer.DB.GetEpoch(ctx)
+		require.NoError(t, err)
+		require.Equal(t, int64(1), epoch.BlockHeight)
+	})
+
+	t.Run("pruned heights", func(t *testing.T) {
+		module := &heightsModule{name: "module"}
+		reparser, _ := newTestReparser(t, &mockNode{earliest: 5, latest: 10}, ReparseConfig{StartHeight: 3, EndHeight: 6}, module)
+		require.ErrorContains(t, reparser.Run(ctx), "pruned the heights before 5")
+		require.Empty(t, module.heights)
+	})
+
+	t.Run("above latest height", func(t *testing.T) {
+		reparser, _ := newTestReparser(t, &mockNode{earliest: 1, latest: 10}, ReparseConfig{StartHeight: 3, EndHeight: 11})
+		require.ErrorContains(t, reparser.Run(ctx), "above the latest height 10")
+	})
+
+	t.Run("interrupted", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		// Interrupting while a block is being reparsed lets it complete
+		module := &heightsModule{name: "module", onBlock: func(height int64) {
+			if height == 4 {
+				cancel()
+			}
+		}}
+		reparser, _ := newTestReparser(t, &mockNode{earliest: 1, latest: 10}, ReparseConfig{StartHeight: 2, EndHeight: 8}, module)
+		err := reparser.Run(ctx)
+		require.ErrorIs(t, err, context.Canceled)
+		require.ErrorContains(t, err, "stopped after height 4")
+		require.Equal(t, []int64{2, 3, 4}, module.heights)
+	})
+
+	t.Run("invalid config", func(t *testing.T) {
+		indexer := newTestIndexer(t)
+		parserCtx := NewContext(&params.EncodingConfig{Codec: indexer.codec}, &mockNode{}, indexer.DB, indexer.Modules, nil)
+
+		_, err := NewReparser(parserCtx, ReparseConfig{StartHeight: 5, EndHeight: 4})
+		require.ErrorContains(t, err, "invalid height range")
+		_, err = NewReparser(parserCtx, ReparseConfig{StartHeight: 1, EndHeight: 4, Modules: []string{"unknown"}})
+		require.ErrorContains(t, err, "module unknown is not registered")
+	})
+}
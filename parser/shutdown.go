@@ -0,0 +1,44 @@
+package parser
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultDrainTimeout is how long the workers are waited for on shutdown when no other timeout is configured
+const DefaultDrainTimeout = 30 * time.Second
+
+// ShutdownGroup tracks the goroutines that have to stop before the process exits, like the workers finishing
+// the heights they are processing once their context is done
+type ShutdownGroup struct {
+	wg sync.WaitGroup
+}
+
+// Go runs f on a new goroutine tracked by the group
+func (g *ShutdownGroup) Go(f func()) {
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		f()
+	}()
+}
+
+// Wait blocks until every goroutine of the group has returned, at most timeout.
+// It returns false if some of them were still running when the timeout passed.
+func (g *ShutdownGroup) Wait(timeout time.Duration) bool {
+	stopped := make(chan struct{})
+	go func() {
+		g.wg.Wait()
+		close(stopped)
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-stopped:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
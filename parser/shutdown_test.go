@@ -0,0 +1,126 @@
+package parser
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"cosmossdk.io/simapp/params"
+	tmctypes "github.com/cometbft/cometbft/rpc/core/types"
+	"github.com/stretchr/testify/require"
+
+	"github.com/forbole/juno/v4/types"
+)
+
+// recordingNode records the heights whose block got fetched
+type recordingNode struct {
+	*mockNode
+
+	mu      sync.Mutex
+	fetched map[int64]struct{}
+}
+
+func (n *recordingNode) Block(height int64) (*tmctypes.ResultBlock, error) {
+	n.mu.Lock()
+	n.fetched[height] = struct{}{}
+	n.mu.Unlock()
+	return n.mockNode.Block(height)
+}
+
+func TestShutdownDrainsInFlightHeights(t *testing.T) {
+	const workers = 4
+
+	stopCtx, stop := context.WithCancel(context.Background())
+	defer stop()
+	epochsCtx, stopEpochs := context.WithCancel(context.Background())
+	defer stopEpochs()
+
+	indexer := newTestIndexer(t)
+	node := &recordingNode{mockNode: &mockNode{}, fetched: make(map[int64]struct{})}
+	indexer.Node = node
+	epochs := NewEpochCommitter(indexer.DB, 1, 10)
+	epochsStopped := make(chan struct{})
+	go func() {
+		defer close(epochsStopped)
+		epochs.Run(epochsCtx)
+	}()
+
+	var running ShutdownGroup
+	queue := types.NewQueue(25)
+	parserCtx := NewContext(&params.EncodingConfig{Codec: indexer.codec}, node, indexer.DB, nil, indexer)
+	for i := 0; i < workers; i++ {
+		worker := NewWorker(parserCtx, queue, i, true)
+		worker.SetIndexer(indexer)
+		worker.SetEpochCommitter(epochs)
+		running.Go(func() { worker.Start(stopCtx) })
+	}
+	go func() {
+		for height := uint64(1); ; height++ {
+			if epochs.Wait(stopCtx, height) != nil {
+				return
+			}
+			select {
+			case queue <- height:
+			case <-stopCtx.Done():
+				return
+			}
+		}
+	}()
+
+	// Stop mid-stream, with heights being processed and others waiting in the queue
+	require.Eventually(t, func() bool { return epochs.Next() > 20 }, 10*time.Second, time.Millisecond)
+	stop()
+	require.True(t, running.Wait(5*time.Second))
+	stopEpochs()
+	<-epochsStopped
+
+	// Every height fetched before stopping got stored, and no other one
+	node.mu.Lock()
+	fetched := make(map[int64]struct{}, len(node.fetched))
+	for height := range node.fetched {
+		fetched[height] = struct{}{}
+	}
+	node.mu.Unlock()
+
+	ctx := context.Background()
+	storedHeights := func() map[int64]struct{} {
+		stored := make(map[int64]struct{})
+		for height := int64(1); height <= int64(node.highest.Load())+workers; height++ {
+			has, err := indexer.DB.HasBlock(ctx, uint64(height))
+			require.NoError(t, err)
+			if has {
+				stored[height] = struct{}{}
+			}
+		}
+		return stored
+	}
+	stored := storedHeights()
+	require.Equal(t, fetched, stored)
+
+	// The epoch got flushed up to the last of the contiguous stored heights
+	contiguous := int64(0)
+	for {
+		if _, ok := stored[contiguous+1]; !ok {
+			break
+		}
+		contiguous++
+	}
+	epoch, err := indexer.DB.GetEpoch(ctx)
+	require.NoError(t, err)
+	require.Equal(t, contiguous, epoch.BlockHeight)
+
+	// Nothing gets written once drained
+	time.Sleep(50 * time.Millisecond)
+	require.Equal(t, stored, storedHeights())
+}
+
+func TestShutdownGroupWaitTimeout(t *testing.T) {
+	var group ShutdownGroup
+	release := make(chan struct{})
+	group.Go(func() { <-release })
+
+	require.False(t, group.Wait(10*time.Millisecond))
+	close(release)
+	require.True(t, group.Wait(time.Second))
+}
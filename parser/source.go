@@ -0,0 +1,247 @@
+package parser
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	tmjson "github.com/cometbft/cometbft/libs/json"
+	tmctypes "github.com/cometbft/cometbft/rpc/core/types"
+	tmtypes "github.com/cometbft/cometbft/types"
+	"github.com/cosmos/cosmos-sdk/codec"
+	codectypes "github.com/cosmos/cosmos-sdk/codec/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	sdktx "github.com/cosmos/cosmos-sdk/types/tx"
+
+	"github.com/forbole/juno/v4/database"
+	"github.com/forbole/juno/v4/models"
+	"github.com/forbole/juno/v4/node"
+	"github.com/forbole/juno/v4/types"
+	"github.com/forbole/juno/v4/types/config"
+)
+
+// ErrValidatorsNotArchived is returned when asking a DatabaseSource for the validators of a height,
+// which are not stored along with the blocks
+var ErrValidatorsNotArchived = errors.New("the validators are not archived, and can only be queried from a node")
+
+// BlockSource provides the data of the heights the parser processes
+type BlockSource interface {
+	// Fetch returns the block at the given height, along with its results and txs.
+	// An error is returned if they can't be provided.
+	Fetch(height uint64) (*tmctypes.ResultBlock, *tmctypes.ResultBlockResults, *BlockTxs, error)
+
+	// Validators returns the validators of the block at the given height
+	Validators(height int64) (*tmctypes.ResultValidators, error)
+}
+
+var (
+	_ BlockSource = &NodeSource{}
+	_ BlockSource = &DatabaseSource{}
+)
+
+// NodeSource provides the heights as the node returns them
+type NodeSource struct {
+	node    node.Node
+	metrics Metrics
+}
+
+// NewNodeSource returns a NodeSource querying the given node
+func NewNodeSource(node node.Node) *NodeSource {
+	return &NodeSource{node: node, metrics: DefaultMetrics}
+}
+
+// Fetch implements BlockSource, retrying the queries failing with a transient error as the parser config tells.
+// The txs are queried one at a time while being iterated over, each of them getting its own retries.
+func (s *NodeSource) Fetch(height uint64) (*tmctypes.ResultBlock, *tmctypes.ResultBlockResults, *BlockTxs, error) {
+	retrier := newNodeRetrier(config.Cfg.Parser, height, s.metrics)
+
+	var block *tmctypes.ResultBlock
+	err := retrier.do("block", func() (err error) {
+		block, err = s.node.Block(int64(height))
+		return err
+	})
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to get block from node: %s", err)
+	}
+
+	var blockResults *tmctypes.ResultBlockResults
+	err = retrier.do("block results", func() (err error) {
+		blockResults, err = s.node.BlockResults(int64(height))
+		return err
+	})
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to get block results from node: %s", err)
+	}
+
+	txs := newLazyBlockTxs(len(block.Block.Txs), func(index int) (tx *types.Tx, err error) {
+		hash := fmt.Sprintf("%X", block.Block.Txs[index].Hash())
+		err = newNodeRetrier(config.Cfg.Parser, height, s.metrics).do("tx", func() (err error) {
+			tx, err = s.node.Tx(hash)
+			return err
+		})
+		return tx, err
+	})
+
+	return block, blockResults, txs, nil
+}
+
+// Validators implements BlockSource
+func (s *NodeSource) Validators(height int64) (*tmctypes.ResultValidators, error) {
+	return s.node.Validators(height)
+}
+
+// DatabaseSource rebuilds the heights out of the stored blocks, txs and block results, so that they can be
+// replayed without querying a node. Only the heights parsed while storing their block results can be rebuilt.
+// NOTE. The rebuilt blocks carry neither their raw txs nor their last commit, which are not stored.
+type DatabaseSource struct {
+	ctx   context.Context
+	db    database.Database
+	codec codec.Codec
+}
+
+// NewDatabaseSource returns a DatabaseSource reading from db, decoding the stored messages with codec
+func NewDatabaseSource(ctx context.Context, db database.Database, codec codec.Codec) *DatabaseSource {
+	return &DatabaseSource{
+		ctx:   ctx,
+		db:    db,
+		codec: codec,
+	}
+}
+
+// Fetch implements BlockSource.
+// An error wrapping ErrBlockNotFound or ErrBlockResultNotFound is returned if the height can't be rebuilt.
+func (s *DatabaseSource) Fetch(height uint64) (*tmctypes.ResultBlock, *tmctypes.ResultBlockResults, *BlockTxs, error) {
+	stored, err := s.db.GetBlockByHeight(s.ctx, height)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to get block %d from database: %w", height, err)
+	}
+
+	bz, err := s.db.GetBlockResult(s.ctx, height)
+	if errors.Is(err, database.ErrBlockResultNotFound) {
+		return nil, nil, nil, fmt.Errorf("the results of block %d were never archived, parse it again from a node "+
+			"with store_block_results enabled: %w", height, err)
+	}
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to get block results %d from database: %s", height, err)
+	}
+	var blockResults tmctypes.ResultBlockResults
+	if err = tmjson.Unmarshal(bz, &blockResults); err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to decode block results %d: %s", height, err)
+	}
+
+	block := stored.ToTmBlock()
+	block.Block.LastCommit = &tmtypes.Commit{}
+
+	txs, err := s.txs(height, &blockResults)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return block, &blockResults, NewBlockTxs(txs), nil
+}
+
+// Validators implements BlockSource
+func (s *DatabaseSource) Validators(int64) (*tmctypes.ResultValidators, error) {
+	return nil, ErrValidatorsNotArchived
+}
+
+// txs rebuilds the stored txs of the given height, whose events are the ones of the given results
+func (s *DatabaseSource) txs(height uint64, blockResults *tmctypes.ResultBlockResults) ([]*types.Tx, error) {
+	var txs []*types.Tx
+	for {
+		rows, total, err := s.db.GetTxsByHeight(s.ctx, height, database.DefaultMaxPageSize, len(txs))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get txs of block %d from database: %s", height, err)
+		}
+
+		for _, row := range rows {
+			tx, err := s.tx(row)
+			if err != nil {
+				return nil, fmt.Errorf("failed to rebuild tx %s: %s", row.Hash.Hex(), err)
+			}
+			if int(row.TxIndex) < len(blockResults.TxsResults) {
+				tx.Events = blockResults.TxsResults[row.TxIndex].Events
+			}
+			txs = append(txs, tx)
+		}
+
+		if len(rows) == 0 || int64(len(txs)) >= total {
+			return txs, nil
+		}
+	}
+}
+
+// tx rebuilds the given stored tx
+func (s *DatabaseSource) tx(row *models.Tx) (*types.Tx, error) {
+	msgs, err := row.DecodeMessages()
+	if err != nil {
+		return nil, err
+	}
+	body := &sdktx.TxBody{Memo: row.Memo, Messages: make([]*codectypes.Any, len(msgs))}
+	for index, msg := range msgs {
+		body.Messages[index] = &codectypes.Any{}
+		if err = s.codec.UnmarshalJSON(msg, body.Messages[index]); err != nil {
+			return nil, fmt.Errorf("failed to decode message %d: %s", index, err)
+		}
+	}
+
+	authInfo := &sdktx.AuthInfo{Fee: &sdktx.Fee{}}
+	// Rows stored without a fee hold an empty array
+	if strings.HasPrefix(strings.TrimSpace(row.Fee), "{") {
+		if err = s.codec.UnmarshalJSON([]byte(row.Fee), authInfo.Fee); err != nil {
+			return nil, fmt.Errorf("failed to decode fee: %s", err)
+		}
+	}
+	infos, err := row.DecodeSignerInfos()
+	if err != nil {
+		return nil, err
+	}
+	for index, info := range infos {
+		signerInfo := &sdktx.SignerInfo{}
+		if err = s.codec.UnmarshalJSON(info, signerInfo); err != nil {
+			return nil, fmt.Errorf("failed to decode signer info %d: %s", index, err)
+		}
+		authInfo.SignerInfos = append(authInfo.SignerInfos, signerInfo)
+	}
+
+	sigs, err := row.DecodeSignatures()
+	if err != nil {
+		return nil, err
+	}
+	signatures := make([][]byte, len(sigs))
+	for index, sig := range sigs {
+		if signatures[index], err = base64.StdEncoding.DecodeString(sig); err != nil {
+			return nil, fmt.Errorf("failed to decode signature %d: %s", index, err)
+		}
+	}
+
+	rawLogs, err := row.DecodeLogs()
+	if err != nil {
+		return nil, err
+	}
+	logs := make(sdk.ABCIMessageLogs, len(rawLogs))
+	for index, rawLog := range rawLogs {
+		if err = json.Unmarshal(rawLog, &logs[index]); err != nil {
+			return nil, fmt.Errorf("failed to decode log %d: %s", index, err)
+		}
+	}
+
+	return &types.Tx{
+		Tx: &sdktx.Tx{Body: body, AuthInfo: authInfo, Signatures: signatures},
+		TxResponse: &sdk.TxResponse{
+			Height:    int64(row.Height),
+			TxHash:    strings.ToUpper(hex.EncodeToString(row.Hash.Bytes())),
+			Codespace: row.Codespace,
+			Code:      row.ToTmTx().TxResult.Code,
+			RawLog:    row.RawLog,
+			Logs:      logs,
+			GasWanted: int64(row.GasWanted),
+			GasUsed:   int64(row.GasUsed),
+			Timestamp: time.Unix(int64(row.Timestamp), 0).UTC().Format(time.RFC3339),
+		},
+	}, nil
+}
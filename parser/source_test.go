@@ -0,0 +1,106 @@
+package parser
+
+import (
+	"context"
+	"testing"
+
+	"cosmossdk.io/simapp/params"
+	abci "github.com/cometbft/cometbft/abci/types"
+	tmctypes "github.com/cometbft/cometbft/rpc/core/types"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm/schema"
+
+	"github.com/forbole/juno/v4/database"
+	"github.com/forbole/juno/v4/models"
+	"github.com/forbole/juno/v4/modules"
+	"github.com/forbole/juno/v4/types"
+	"github.com/forbole/juno/v4/types/config"
+)
+
+// archivingNode returns block results holding the events of the txs, like a real node does
+type archivingNode struct {
+	*mockNode
+}
+
+func (n *archivingNode) BlockResults(height int64) (*tmctypes.ResultBlockResults, error) {
+	results := &tmctypes.ResultBlockResults{Height: height}
+	if len(n.eventTypes) > 0 {
+		results.TxsResults = []*abci.ResponseDeliverTx{{Events: newTestTx(height, n.eventTypes...).Events}}
+	}
+	return results, nil
+}
+
+func TestReplay(t *testing.T) {
+	parserCfg := config.Cfg.Parser
+	t.Cleanup(func() { config.Cfg.Parser = parserCfg })
+
+	ctx := context.Background()
+	indexer := newTestIndexer(t)
+	require.NoError(t, indexer.DB.PrepareTables(ctx, []schema.Tabler{&models.BlockResult{}}))
+	indexer.Node = &archivingNode{mockNode: &mockNode{eventTypes: []string{"save"}}}
+
+	// The results of the heights 1 to 3 get archived, while the ones of height 4 don't
+	config.Cfg.Parser.StoreBlockResults = true
+	for height := uint64(1); height <= 3; height++ {
+		require.NoError(t, indexer.Process(height))
+	}
+	config.Cfg.Parser.StoreBlockResults = false
+	require.NoError(t, indexer.Process(4))
+
+	// The module table is wiped, and no node is left to query
+	require.NoError(t, indexer.DB.(*database.Impl).Db.Exec("DELETE FROM buckets").Error)
+	_, err := indexer.DB.GetBucketByName(ctx, "0x01")
+	require.ErrorIs(t, err, database.ErrBucketNotFound)
+
+	newReplayer := func(endHeight uint64, blocks *heightsModule) *Reparser {
+		mods := []modules.Module{blocks, &bucketEventModule{db: indexer.DB}}
+		reparser, err := NewReparser(NewContext(&params.EncodingConfig{Codec: indexer.codec}, nil, indexer.DB, mods, nil),
+			ReparseConfig{StartHeight: 1, EndHeight: endHeight, Replay: true})
+		require.NoError(t, err)
+		return reparser
+	}
+
+	blocks := &heightsModule{name: "blocks"}
+	require.NoError(t, newReplayer(3, blocks).Run(ctx))
+	require.Equal(t, []int64{1, 2, 3}, blocks.heights)
+	_, err = indexer.DB.GetBucketByName(ctx, "0x01")
+	require.NoError(t, err)
+
+	// A height whose results were never archived fails clearly
+	blocks = &heightsModule{name: "blocks"}
+	require.ErrorContains(t, newReplayer(4, blocks).Run(ctx), "the results of block 4 were never archived")
+	require.Equal(t, []int64{1, 2, 3}, blocks.heights)
+}
+
+func TestDatabaseSourceTxs(t *testing.T) {
+	ctx := context.Background()
+	indexer := newTestIndexer(t)
+	require.NoError(t, indexer.DB.PrepareTables(ctx, []schema.Tabler{&models.BlockResult{}}))
+
+	block := newHashedTestBlock(7)
+	tx := newTestTx(7, "save", "other")
+	tx.Code, tx.GasUsed, tx.Body.Memo = 3, 42, "memo"
+	require.NoError(t, indexer.DB.SaveBlock(ctx, models.NewBlockFromTmBlock(block, 0)))
+	require.NoError(t, indexer.DB.SaveTxs(ctx, uint64(block.Block.Time.Unix()), []*types.Tx{tx}))
+	indexer.Ctx = ctx
+	require.NoError(t, indexer.storeBlockResults(&tmctypes.ResultBlockResults{
+		Height:     7,
+		TxsResults: []*abci.ResponseDeliverTx{{Events: tx.Events}},
+	}))
+
+	rebuiltBlock, _, blockTxs, err := NewDatabaseSource(ctx, indexer.DB, indexer.codec).Fetch(7)
+	require.NoError(t, err)
+	txs, err := blockTxs.All()
+	require.NoError(t, err)
+	require.Equal(t, int64(7), rebuiltBlock.Block.Height)
+	require.Equal(t, block.Block.Hash(), rebuiltBlock.BlockID.Hash)
+	require.Len(t, txs, 1)
+	require.Equal(t, tx.TxHash, "0x"+txs[0].TxHash)
+	require.Equal(t, uint32(3), txs[0].Code)
+	require.Equal(t, int64(42), txs[0].GasUsed)
+	require.Equal(t, "memo", txs[0].Body.Memo)
+	require.Equal(t, tx.Events, txs[0].Events)
+
+	_, _, _, err = NewDatabaseSource(ctx, indexer.DB, indexer.codec).Fetch(8)
+	require.ErrorIs(t, err, database.ErrBlockNotFound)
+}
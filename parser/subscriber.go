@@ -0,0 +1,154 @@
+package parser
+
+import (
+	"context"
+	"time"
+
+	tmctypes "github.com/cometbft/cometbft/rpc/core/types"
+	tmtypes "github.com/cometbft/cometbft/types"
+
+	"github.com/forbole/juno/v4/log"
+	"github.com/forbole/juno/v4/node"
+	"github.com/forbole/juno/v4/types"
+	"github.com/forbole/juno/v4/types/config"
+)
+
+const (
+	// DefaultSubscriptionStallBlocks is the number of average block times without any new block after which
+	// the subscription is deemed dropped
+	DefaultSubscriptionStallBlocks = 3
+
+	// newBlocksSubscriber is the name the new blocks are subscribed to with
+	newBlocksSubscriber = "juno-new-blocks"
+)
+
+// BlockSubscriber enqueues the new heights as the node subscription to the new blocks delivers them. While the
+// subscription is down, or stalls, the node gets polled for its latest height instead while subscribing again.
+// Every height is enqueued once and in order, the ones delivered twice or late being skipped and the ones the
+// subscription misses being filled in.
+type BlockSubscriber struct {
+	node  node.Node
+	queue types.HeightQueue
+
+	// next is the next height to be enqueued
+	next uint64
+
+	pollInterval time.Duration
+	stallTimeout time.Duration
+}
+
+// NewBlockSubscriber returns a BlockSubscriber enqueuing into queue the heights starting from next
+func NewBlockSubscriber(node node.Node, queue types.HeightQueue, next uint64) *BlockSubscriber {
+	avgBlockTime := config.GetAvgBlockTime()
+	return &BlockSubscriber{
+		node:         node,
+		queue:        queue,
+		next:         next,
+		pollInterval: avgBlockTime,
+		stallTimeout: DefaultSubscriptionStallBlocks * avgBlockTime,
+	}
+}
+
+// Start subscribes to the new blocks, enqueuing their heights until ctx is done
+func (s *BlockSubscriber) Start(ctx context.Context) {
+	events, cancel := s.subscribe()
+	defer func() { cancel() }()
+
+	// The heights produced before subscribing are caught up with first
+	if !s.poll(ctx) {
+		return
+	}
+
+	timer := time.NewTimer(s.timeout(events))
+	defer timer.Stop()
+
+	for {
+		select {
+		case event := <-events:
+			height, ok := newBlockHeight(event)
+			if !ok {
+				log.Errorw("unexpected new block event", "data", event.Data)
+				continue
+			}
+			if !s.enqueueUpTo(ctx, uint64(height)) {
+				return
+			}
+
+		case <-timer.C:
+			if events != nil {
+				log.Errorw("no new block received, subscribing again", "timeout", s.stallTimeout)
+			}
+			if !s.poll(ctx) {
+				return
+			}
+			cancel()
+			events, cancel = s.subscribe()
+
+		case <-ctx.Done():
+			return
+		}
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(s.timeout(events))
+	}
+}
+
+// subscribe subscribes to the new blocks, returning a nil channel if it fails
+func (s *BlockSubscriber) subscribe() (<-chan tmctypes.ResultEvent, context.CancelFunc) {
+	events, cancel, err := s.node.SubscribeNewBlocks(newBlocksSubscriber)
+	if err != nil {
+		log.Errorw("failed to subscribe to new blocks, polling instead", "err", err, "interval", s.pollInterval)
+		if cancel != nil {
+			cancel()
+		}
+		return nil, func() {}
+	}
+	return events, cancel
+}
+
+// timeout returns how long to wait for a new block on the given subscription before polling
+func (s *BlockSubscriber) timeout(events <-chan tmctypes.ResultEvent) time.Duration {
+	if events == nil {
+		return s.pollInterval
+	}
+	return s.stallTimeout
+}
+
+// poll enqueues the heights up to the latest one of the node, returning false if ctx got done meanwhile.
+// A failure to get the latest height is logged and left to the next poll.
+func (s *BlockSubscriber) poll(ctx context.Context) bool {
+	latest, err := s.node.LatestHeight()
+	if err != nil {
+		log.Errorw("failed to get last block from RPCConfig client", "err", err)
+		return ctx.Err() == nil
+	}
+	return s.enqueueUpTo(ctx, uint64(latest))
+}
+
+// enqueueUpTo enqueues the heights from the next one up to the given one, returning false if ctx got done
+// meanwhile. Nothing is enqueued if height has been already.
+func (s *BlockSubscriber) enqueueUpTo(ctx context.Context, height uint64) bool {
+	for ; s.next <= height; s.next++ {
+		log.Debugw("enqueueing new block", "height", s.next)
+		select {
+		case s.queue <- s.next:
+		case <-ctx.Done():
+			return false
+		}
+	}
+	return true
+}
+
+// newBlockHeight returns the height of the block carried by the given new block event
+func newBlockHeight(event tmctypes.ResultEvent) (int64, bool) {
+	data, ok := event.Data.(tmtypes.EventDataNewBlock)
+	if !ok || data.Block == nil {
+		return 0, false
+	}
+	return data.Block.Height, true
+}
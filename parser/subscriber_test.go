@@ -0,0 +1,198 @@
+package parser
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	tmctypes "github.com/cometbft/cometbft/rpc/core/types"
+	rpctypes "github.com/cometbft/cometbft/rpc/jsonrpc/types"
+	tmtypes "github.com/cometbft/cometbft/types"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+
+	"github.com/forbole/juno/v4/node/remote"
+	"github.com/forbole/juno/v4/types"
+	"github.com/forbole/juno/v4/types/config"
+)
+
+// websocketNode fakes the RPC endpoint of a node, answering the status queries with its latest height and
+// handing every subscription made through its websocket over to the test
+type websocketNode struct {
+	t        *testing.T
+	upgrader websocket.Upgrader
+
+	mu      sync.Mutex
+	latest  int64
+	dropped *websocket.Conn
+
+	// writeMu serializes the writes to the connections, which are shared by their subscriptions
+	writeMu sync.Mutex
+
+	subscriptions chan *subscription
+}
+
+// subscription is a new blocks subscription made to a websocketNode
+type subscription struct {
+	node  *websocketNode
+	conn  *websocket.Conn
+	id    interface{}
+	query string
+}
+
+func newWebsocketNode(t *testing.T, latest int64) (*websocketNode, *httptest.Server) {
+	n := &websocketNode{t: t, latest: latest, subscriptions: make(chan *subscription, 100)}
+	server := httptest.NewServer(n)
+	t.Cleanup(server.Close)
+	return n, server
+}
+
+func (n *websocketNode) setLatest(latest int64) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.latest = latest
+}
+
+func (n *websocketNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/websocket" {
+		n.serveWebsocket(w, r)
+		return
+	}
+
+	var request rpctypes.RPCRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	n.mu.Lock()
+	status := &tmctypes.ResultStatus{SyncInfo: tmctypes.SyncInfo{LatestBlockHeight: n.latest}}
+	n.mu.Unlock()
+	require.NoError(n.t, json.NewEncoder(w).Encode(rpctypes.NewRPCSuccessResponse(request.ID, status)))
+}
+
+func (n *websocketNode) serveWebsocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := n.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	for {
+		var request rpctypes.RPCRequest
+		if err = conn.ReadJSON(&request); err != nil {
+			return
+		}
+		var params struct {
+			Query string `json:"query"`
+		}
+		require.NoError(n.t, json.Unmarshal(request.Params, &params))
+
+		sub := &subscription{node: n, conn: conn, id: request.ID, query: params.Query}
+		sub.send(&tmctypes.ResultSubscribe{})
+		n.subscriptions <- sub
+	}
+}
+
+// drop closes the connection of the given subscription, as the node would while restarting
+func (n *websocketNode) drop(sub *subscription) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.dropped = sub.conn
+	require.NoError(n.t, sub.conn.Close())
+}
+
+// next returns the next subscription made through a connection not dropped, failing the test if none is made
+// in time. The subscriber subscribing again whenever no block comes, several subscriptions may be waiting.
+func (n *websocketNode) next() *subscription {
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case sub := <-n.subscriptions:
+			n.mu.Lock()
+			dropped := sub.conn == n.dropped
+			n.mu.Unlock()
+			if !dropped {
+				return sub
+			}
+		case <-timeout:
+			n.t.Fatal("no subscription made")
+			return nil
+		}
+	}
+}
+
+func (s *subscription) send(result interface{}) {
+	s.node.writeMu.Lock()
+	defer s.node.writeMu.Unlock()
+	// The writes failing once the connection is dropped are left to the subscriber
+	_ = s.conn.WriteJSON(rpctypes.NewRPCSuccessResponse(s.id.(rpctypes.JSONRPCIntID), result))
+}
+
+// sendBlocks sends through sub a new block event for each of the given heights, the node status following.
+// NOTE. The client dropping the events it can't deliver right away, the missed ones are polled.
+func (n *websocketNode) sendBlocks(sub *subscription, heights ...int64) {
+	for _, height := range heights {
+		n.mu.Lock()
+		if height > n.latest {
+			n.latest = height
+		}
+		n.mu.Unlock()
+		sub.send(&tmctypes.ResultEvent{
+			Query: sub.query,
+			Data:  tmtypes.EventDataNewBlock{Block: &tmtypes.Block{Header: tmtypes.Header{Height: height}}},
+		})
+	}
+}
+
+// requireHeights requires the given heights to be taken from queue in order, and nothing more
+func requireHeights(t *testing.T, queue types.HeightQueue, heights ...uint64) {
+	for _, height := range heights {
+		select {
+		case got := <-queue:
+			require.Equal(t, height, got)
+		case <-time.After(10 * time.Second):
+			t.Fatalf("height %d not enqueued", height)
+		}
+	}
+	select {
+	case got := <-queue:
+		t.Fatalf("unexpected height %d enqueued", got)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestBlockSubscriber(t *testing.T) {
+	parserCfg := config.Cfg.Parser
+	t.Cleanup(func() { config.Cfg.Parser = parserCfg })
+	avgBlockTime := 100 * time.Millisecond
+	config.Cfg.Parser.AvgBlockTime = &avgBlockTime
+
+	fake, server := newWebsocketNode(t, 2)
+	client, err := remote.NewNode(remote.NewDetails(remote.NewRPCConfig("juno", server.URL, 10), remote.DefaultGrpcConfig()), nil)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	queue := types.NewQueue(25)
+	go NewBlockSubscriber(client, queue, 1).Start(ctx)
+
+	// The heights produced before subscribing are polled, the ones delivered twice or late being skipped
+	sub := fake.next()
+	requireHeights(t, queue, 1, 2)
+	fake.sendBlocks(sub, 2, 3, 3, 1)
+	requireHeights(t, queue, 3)
+
+	// While the websocket is down the node gets polled, until the subscription is made again
+	fake.setLatest(5)
+	fake.drop(sub)
+	requireHeights(t, queue, 4, 5)
+
+	// The height missed by the new subscription is filled in
+	sub = fake.next()
+	fake.sendBlocks(sub, 7, 6, 8, 7)
+	requireHeights(t, queue, 6, 7, 8)
+}
@@ -0,0 +1,113 @@
+package parser
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/forbole/juno/v4/modules"
+	"github.com/forbole/juno/v4/types"
+)
+
+// TxBatchSize is the number of txs of a block decoded and handled together, bounding the txs held in memory
+const TxBatchSize = 100
+
+// BlockTxs provides the txs of a block in their order. Unless built out of already decoded txs, they are only
+// decoded while being iterated over, so that the blocks with many txs are never held in memory as a whole.
+type BlockTxs struct {
+	count int
+	get   func(index int) (*types.Tx, error)
+
+	// elapsed is the time spent getting the txs so far
+	elapsed time.Duration
+}
+
+// NewBlockTxs returns the BlockTxs of the given already decoded txs
+func NewBlockTxs(txs []*types.Tx) *BlockTxs {
+	return newLazyBlockTxs(len(txs), func(index int) (*types.Tx, error) {
+		return txs[index], nil
+	})
+}
+
+// newLazyBlockTxs returns the BlockTxs of count txs, getting the one at a given index with get
+func newLazyBlockTxs(count int, get func(index int) (*types.Tx, error)) *BlockTxs {
+	return &BlockTxs{count: count, get: get}
+}
+
+// Len returns the number of txs of the block
+func (t *BlockTxs) Len() int {
+	if t == nil {
+		return 0
+	}
+	return t.count
+}
+
+// Batches calls fn with the txs of the block in their order, at most size of them at a time, along with the index
+// of the first one inside the block. The txs of a batch are decoded right before fn is called with them, and
+// aren't referenced anymore once it returns.
+// An error is returned if a tx can't be decoded, or fn fails.
+func (t *BlockTxs) Batches(size int, fn func(first int, batch []*types.Tx) error) error {
+	if size <= 0 {
+		size = TxBatchSize
+	}
+
+	for start := 0; start < t.Len(); start += size {
+		end := start + size
+		if end > t.Len() {
+			end = t.Len()
+		}
+
+		batch := make([]*types.Tx, 0, end-start)
+		for index := start; index < end; index++ {
+			tx, err := t.tx(index)
+			if err != nil {
+				return err
+			}
+			batch = append(batch, tx)
+		}
+
+		if err := fn(start, batch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// All returns every tx of the block at once, for the handlers which can't do without them.
+// An error is returned if a tx can't be decoded.
+func (t *BlockTxs) All() ([]*types.Tx, error) {
+	txs := make([]*types.Tx, 0, t.Len())
+	err := t.Batches(TxBatchSize, func(_ int, batch []*types.Tx) error {
+		txs = append(txs, batch...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return txs, nil
+}
+
+// tx returns the tx at the given index, recording the time it takes
+func (t *BlockTxs) tx(index int) (*types.Tx, error) {
+	start := time.Now()
+	defer func() { t.elapsed += time.Since(start) }()
+
+	tx, err := t.get(index)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tx %d of block: %s", index, err)
+	}
+	return tx, nil
+}
+
+// blockModulesTxs returns every tx of the block for the block modules requiring them, and none when no
+// enabled module does, sparing the decoding of all of them at once
+func (i *Impl) blockModulesTxs(txs *BlockTxs) ([]*types.Tx, error) {
+	for _, module := range i.Modules {
+		if _, ok := module.(modules.BlockModule); !ok {
+			continue
+		}
+		if txsModule, ok := module.(modules.BlockTxsModule); ok && txsModule.RequiresBlockTxs() {
+			return txs.All()
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,112 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	tmctypes "github.com/cometbft/cometbft/rpc/core/types"
+	tmtypes "github.com/cometbft/cometbft/types"
+	"github.com/stretchr/testify/require"
+
+	"github.com/forbole/juno/v4/common"
+	"github.com/forbole/juno/v4/modules"
+	"github.com/forbole/juno/v4/types"
+)
+
+// crowdedNode serves blocks holding txs txs each, counting the txs it has been asked
+type crowdedNode struct {
+	*mockNode
+
+	txs     int
+	fetched atomic.Int64
+}
+
+func (n *crowdedNode) Block(height int64) (*tmctypes.ResultBlock, error) {
+	block := newHashedTestBlock(height)
+	for index := 0; index < n.txs; index++ {
+		block.Block.Txs = append(block.Block.Txs, tmtypes.Tx(fmt.Sprintf("tx-%d-%d", height, index)))
+	}
+	return block, nil
+}
+
+func (n *crowdedNode) Tx(hash string) (*types.Tx, error) {
+	n.fetched.Add(1)
+	tx := newTestTx(1)
+	tx.TxHash = common.HexToHash(hash).Hex()
+	return tx, nil
+}
+
+// pendingTxsModule records the max number of txs fetched from node but not handled yet, and optionally
+// requires all the txs of the blocks
+type pendingTxsModule struct {
+	node *crowdedNode
+
+	requiresTxs bool
+	blockTxs    int
+	handled     int64
+	maxPending  int64
+}
+
+func (m *pendingTxsModule) Name() string { return "pending_txs" }
+
+func (m *pendingTxsModule) RequiresBlockTxs() bool { return m.requiresTxs }
+
+func (m *pendingTxsModule) HandleBlock(_ context.Context, _ *tmctypes.ResultBlock, _ *tmctypes.ResultBlockResults, txs []*types.Tx, _ modules.GetTmcValidators) error {
+	m.blockTxs = len(txs)
+	return nil
+}
+
+func (m *pendingTxsModule) HandleTx(*types.Tx) error {
+	if pending := m.node.fetched.Load() - m.handled; pending > m.maxPending {
+		m.maxPending = pending
+	}
+	m.handled++
+	return nil
+}
+
+func TestProcessStreamsTxs(t *testing.T) {
+	const txs = 5000
+
+	for _, tc := range []struct {
+		name        string
+		requiresTxs bool
+		maxPending  int64
+	}{
+		{name: "txs handled by batches", maxPending: TxBatchSize},
+		{name: "block module requiring all the txs", requiresTxs: true, maxPending: txs},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			indexer := newTestIndexer(t)
+			node := &crowdedNode{mockNode: &mockNode{}, txs: txs}
+			module := &pendingTxsModule{node: node, requiresTxs: tc.requiresTxs}
+			indexer.Node = node
+			indexer.Modules = []modules.Module{module}
+
+			require.NoError(t, indexer.Process(1))
+			require.Equal(t, int64(txs), module.handled)
+			require.Equal(t, tc.maxPending, module.maxPending)
+			if tc.requiresTxs {
+				require.Equal(t, txs, module.blockTxs)
+			} else {
+				require.Zero(t, module.blockTxs)
+			}
+
+			_, total, err := indexer.DB.GetTxsByHeight(indexer.Ctx, 1, 10, 0)
+			require.NoError(t, err)
+			require.Equal(t, int64(txs), total)
+		})
+	}
+}
+
+func BenchmarkProcessManyTxs(b *testing.B) {
+	indexer := newTestIndexer(b)
+	indexer.Node = &crowdedNode{mockNode: &mockNode{}, txs: 5000}
+	indexer.Modules = nil
+
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		require.NoError(b, indexer.Process(uint64(n+1)))
+	}
+}
@@ -1,6 +1,7 @@
 package parser
 
 import (
+	abci "github.com/cometbft/cometbft/abci/types"
 	tmctypes "github.com/cometbft/cometbft/rpc/core/types"
 	tmtypes "github.com/cometbft/cometbft/types"
 	sdk "github.com/cosmos/cosmos-sdk/types"
@@ -30,3 +31,26 @@ func SumGasTxs(txs []*types.Tx) uint64 {
 
 	return totalGas
 }
+
+// SumGasResults returns the total gas consumed by the transactions of a block, out of its results.
+func SumGasResults(results *tmctypes.ResultBlockResults) uint64 {
+	var totalGas uint64
+	if results == nil {
+		return totalGas
+	}
+
+	for _, result := range results.TxsResults {
+		totalGas += uint64(result.GasUsed)
+	}
+
+	return totalGas
+}
+
+// toSDKEvents converts the given ABCI events into SDK ones
+func toSDKEvents(events []abci.Event) []sdk.Event {
+	converted := make([]sdk.Event, len(events))
+	for index, event := range events {
+		converted[index] = sdk.Event(event)
+	}
+	return converted
+}
@@ -20,6 +20,8 @@ import (
 // aggregating block and associated data and exporting it to a database.
 type Worker struct {
 	ctx context.Context
+	// stop is closed once the worker has to stop, ctx staying alive so that the height in progress completes
+	stop <-chan struct{}
 
 	index int
 
@@ -31,12 +33,30 @@ type Worker struct {
 	db      database.Database
 	indexer Indexer
 
+	// epochs is told about every processed height when the epoch advances through an EpochCommitter
+	epochs *EpochCommitter
+
+	// backfill queues the low priority heights, the missing ones found by a Backfiller or at start, which leave
+	// alone the inFlight ones. They are only taken while no height is waiting in the main queue, unless the
+	// worker is reserved to them.
+	backfill types.HeightQueue
+	inFlight *InFlightHeights
+	reserved bool
+
+	// summary records whether each height succeeds when parsing a fixed range, a failed height not being retried
+	summary *RangeSummary
+
+	// failed records the heights failing to be processed into the dead-letter table
+	failed *FailedBlocks
+
 	concurrentSync bool
 }
 
 // NewWorker allows to create a new Worker implementation.
 func NewWorker(ctx *Context, queue types.HeightQueue, index int, concurrentSync bool) *Worker {
 	return &Worker{
+		// Replaced once started, the heights being processed without starting the worker too
+		ctx:            database.ReadFromPrimary(context.Background()),
 		index:          index,
 		codec:          ctx.EncodingConfig.Codec,
 		node:           ctx.Node,
@@ -52,9 +72,41 @@ func (w *Worker) SetIndexer(indexer Indexer) {
 	w.indexer = indexer
 }
 
+// SetEpochCommitter makes the worker record every height it processes into the given EpochCommitter
+func (w *Worker) SetEpochCommitter(epochs *EpochCommitter) {
+	w.epochs = epochs
+}
+
+// SetBackfill makes the worker process the heights of the given backfill queue while the main one is empty,
+// recording the heights it processes into inFlight
+func (w *Worker) SetBackfill(backfill types.HeightQueue, inFlight *InFlightHeights) {
+	w.backfill = backfill
+	w.inFlight = inFlight
+}
+
+// ReserveForBackfill makes the worker take the heights of the backfill queue before the ones of the main queue,
+// so that the missing heights still get processed while new ones keep coming
+func (w *Worker) ReserveForBackfill() {
+	w.reserved = true
+}
+
+// SetRangeSummary makes the worker record into summary whether each height it processes succeeds, giving up on
+// the failed heights instead of retrying them
+func (w *Worker) SetRangeSummary(summary *RangeSummary) {
+	w.summary = summary
+}
+
+// SetFailedBlocks makes the worker record into failed the heights it fails to process, and their success once
+// processed again
+func (w *Worker) SetFailedBlocks(failed *FailedBlocks) {
+	w.failed = failed
+}
+
 // Start starts a worker by listening for new jobs (block heights) from the
 // given worker queue. Any failed job is logged and re-enqueued.
+// Once ctx is done the worker finishes the height it is processing, if any, and returns.
 func (w *Worker) Start(ctx context.Context) {
+	w.ctx, w.stop = database.ReadFromPrimary(context.WithoutCancel(ctx)), ctx.Done()
 	log.WorkerCount.Inc()
 	chainID, err := w.node.ChainID()
 	if err != nil {
@@ -62,6 +114,22 @@ func (w *Worker) Start(ctx context.Context) {
 	}
 
 	for {
+		// A height waiting in a queue is left alone once stopping, even if it was enqueued first
+		if ctx.Err() != nil {
+			log.Infow("Receive cancel signal, worker will stop")
+			return
+		}
+
+		if w.reserved {
+			select {
+			case i := <-w.backfill:
+				w.processBackfilled(i)
+				continue
+			default:
+			}
+		}
+
+		// The backfilled heights are only taken while no height is waiting in the main queue
 		select {
 		case i, ok := <-w.queue:
 			if !ok {
@@ -69,28 +137,24 @@ func (w *Worker) Start(ctx context.Context) {
 				log.Infow("block queue has been closed, worker will stop")
 				return
 			}
-			//process height at 'i'
-			{
-				if err := w.ProcessIfNotExists(i); err != nil {
-					if w.concurrentSync {
-						// re-enqueue any failed job after average block time
-						// TODO: Implement exponential backoff or max retries for a block height.
-						go func() {
-							log.Errorw("re-enqueueing failed block", "height", i, "err", err)
-							w.queue <- i
-						}()
-						continue
-					}
-
-					for err != nil {
-						log.Errorw("error while process block", "height", i, "err", err)
-						time.Sleep(config.GetAvgBlockTime())
-						err = w.ProcessIfNotExists(i)
-					}
-				} else {
-					log.WorkerHeight.WithLabelValues(fmt.Sprintf("%d", w.index), chainID).Set(float64(i))
-				}
+			w.processQueued(i, chainID)
+			continue
+		case <-ctx.Done():
+			log.Infow("Receive cancel signal, worker will stop")
+			return
+		default:
+		}
+
+		select {
+		case i, ok := <-w.queue:
+			if !ok {
+				//channel has been closed
+				log.Infow("block queue has been closed, worker will stop")
+				return
 			}
+			w.processQueued(i, chainID)
+		case i := <-w.backfill:
+			w.processBackfilled(i)
 		case <-ctx.Done():
 			log.Infow("Receive cancel signal, worker will stop")
 			return
@@ -98,6 +162,111 @@ func (w *Worker) Start(ctx context.Context) {
 	}
 }
 
+// processQueued processes the given height taken from the main queue. A failed height is either processed
+// again until it succeeds, re-enqueued when syncing concurrently, or given up on when parsing a fixed range.
+func (w *Worker) processQueued(i uint64, chainID string) {
+	if w.inFlight != nil {
+		w.inFlight.Add(i)
+		defer w.inFlight.Remove(i)
+	}
+
+	if err := w.ProcessIfNotExists(i); err != nil {
+		w.recordFailure(i, err)
+
+		// The transient node failures have been retried already, so the height is not expected to succeed
+		if w.summary != nil {
+			log.Errorw("failed to process block of the range", "height", i, "err", err)
+			w.summary.Fail(i)
+			return
+		}
+
+		// The failed blocks retrier re-enqueues the height itself, a limited number of times
+		if w.concurrentSync && w.failed != nil && config.Cfg.Parser.FailedBlockRetryInterval > 0 {
+			log.Errorw("failed to process block, leaving it to the failed blocks retrier", "height", i, "err", err)
+			return
+		}
+
+		if w.concurrentSync {
+			// re-enqueue any failed job after average block time
+			// TODO: Implement exponential backoff or max retries for a block height.
+			go func() {
+				log.Errorw("re-enqueueing failed block", "height", i, "err", err)
+				select {
+				case w.queue <- i:
+				case <-w.stop:
+				}
+			}()
+			return
+		}
+
+		for err != nil {
+			log.Errorw("error while process block", "height", i, "err", err)
+			select {
+			case <-time.After(config.GetAvgBlockTime()):
+			case <-w.stop:
+				// The height is left to the next start, and holds the epoch back until then
+				return
+			}
+			err = w.ProcessIfNotExists(i)
+			if err != nil {
+				w.recordFailure(i, err)
+			}
+		}
+	} else {
+		log.WorkerHeight.WithLabelValues(fmt.Sprintf("%d", w.index), chainID).Set(float64(i))
+	}
+
+	// A failed height only gets here once processed again, holding the epoch back until then
+	if w.failed != nil {
+		w.failed.Succeed(w.ctx, i)
+	}
+	if w.epochs != nil {
+		w.epochs.Done(i)
+	}
+	if w.summary != nil {
+		w.summary.Succeed(i)
+	}
+}
+
+// processBackfilled processes the given height taken from the backfill queue. A failed height is left to
+// the failed blocks retrier when enabled, and enqueued again on the main queue otherwise, as the heights caught
+// up with at start are never looked for again.
+// The heights retried by the retrier may be past the epoch, which they hold back until they succeed here.
+func (w *Worker) processBackfilled(i uint64) {
+	defer w.inFlight.Remove(i)
+
+	if err := w.ProcessIfNotExists(i); err != nil {
+		w.recordFailure(i, err)
+		if w.failed != nil && config.Cfg.Parser.FailedBlockRetryInterval > 0 {
+			log.Errorw("failed to backfill block, leaving it to the failed blocks retrier", "height", i, "err", err)
+			return
+		}
+
+		log.Errorw("re-enqueueing failed backfilled block", "height", i, "err", err)
+		go func() {
+			select {
+			case w.queue <- i:
+			case <-w.stop:
+			}
+		}()
+		return
+	}
+	log.BackfillGapsFilled.Inc()
+	if w.failed != nil {
+		w.failed.Succeed(w.ctx, i)
+	}
+	if w.epochs != nil {
+		w.epochs.Done(i)
+	}
+}
+
+// recordFailure records into the dead-letter table that the given height failed with err, if the worker has one
+func (w *Worker) recordFailure(height uint64, err error) {
+	if w.failed != nil {
+		w.failed.Fail(w.ctx, height, err)
+	}
+}
+
 // ProcessIfNotExists defines the job consumer workflow. It will fetch a block for a given
 // height and associated metadata and export it to a database if it does not exist yet. It returns an
 // error if any export process fails.
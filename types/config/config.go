@@ -58,6 +58,9 @@ func (c Config) GetBytes() ([]byte, error) {
 type ChainConfig struct {
 	Bech32Prefix string   `yaml:"bech32_prefix"`
 	Modules      []string `yaml:"modules"`
+
+	// ChainID is the id of the chain to be indexed. When set, the parser refuses to run against a node of another chain
+	ChainID string `yaml:"chain_id,omitempty"`
 }
 
 // NewChainConfig returns a new ChainConfig instance